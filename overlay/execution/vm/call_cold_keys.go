@@ -0,0 +1,35 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+// UseCallColdKeys makes DELEGATECALL and STATICCALL charge DELEGATECALL_COLD and
+// STATICCALL_COLD for cold targets when those keys are set, instead of the CALL_COLD
+// cost shared by all CALL variants. Either opcode falls back to CALL_COLD when its
+// own key is absent. The table must be a copy (see GetBaseJumpTable).
+func UseCallColdKeys(jt *JumpTable) {
+	for op, key := range map[OpCode]string{
+		DELEGATECALL: GasKeyDelegateCallCold,
+		STATICCALL:   GasKeyStaticCallCold,
+	} {
+		// STATICCALL is not defined before Byzantium
+		if jt.IsDefined(op) && jt[op].dynamicGas != nil {
+			jt[op].dynamicGas = withCallColdKey(jt[op].dynamicGas, key)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// TestUseCallColdKeys verifies that DELEGATECALL_COLD and STATICCALL_COLD only change
+// the cold cost seen by their own opcode, that CALL keeps reading CALL_COLD, and that
+// the EVM's schedule is restored after each gas function returns.
+func TestUseCallColdKeys(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+
+	// Stand-in for the patched EIP-2929 gas functions, which charge CALL_COLD for cold targets
+	coldCost := func(evm *EVM, _ *CallContext, _ mdgas.MdGas, _ uint64) (mdgas.MdGas, error) {
+		return mdgas.MdGas{Regular: evm.GasSchedule.GetOr(GasKeyCallCold, params.ColdAccountAccessCostEIP2929)}, nil
+	}
+
+	jt := GetBaseJumpTable(rules)
+	for _, op := range []OpCode{CALL, CALLCODE, DELEGATECALL, STATICCALL} {
+		jt[op].dynamicGas = coldCost
+	}
+
+	UseCallColdKeys(jt)
+
+	tests := []struct {
+		name      string
+		overrides map[string]uint64
+		want      map[OpCode]uint64
+	}{
+		{
+			name:      "DELEGATECALL_COLD only",
+			overrides: map[string]uint64{GasKeyDelegateCallCold: 100},
+			want:      map[OpCode]uint64{CALL: 2600, CALLCODE: 2600, DELEGATECALL: 100, STATICCALL: 2600},
+		},
+		{
+			name:      "both keys with CALL_COLD",
+			overrides: map[string]uint64{GasKeyCallCold: 5000, GasKeyDelegateCallCold: 100, GasKeyStaticCallCold: 200},
+			want:      map[OpCode]uint64{CALL: 5000, CALLCODE: 5000, DELEGATECALL: 100, STATICCALL: 200},
+		},
+		{
+			name:      "unset keys follow CALL_COLD",
+			overrides: map[string]uint64{GasKeyCallCold: 5000},
+			want:      map[OpCode]uint64{CALL: 5000, CALLCODE: 5000, DELEGATECALL: 5000, STATICCALL: 5000},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule := &GasSchedule{Overrides: tc.overrides}
+			evm := &EVM{GasSchedule: schedule}
+
+			for op, want := range tc.want {
+				gas, err := jt[op].dynamicGas(evm, &CallContext{}, mdgas.MdGas{}, 0)
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", op, err)
+				}
+
+				if gas.Regular != want {
+					t.Errorf("%s cold cost = %d, want %d", op, gas.Regular, want)
+				}
+
+				if evm.GasSchedule != schedule {
+					t.Fatalf("%s did not restore the EVM gas schedule", op)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import "github.com/erigontech/erigon/execution/protocol/params"
+
+// DisableEIP2929 reverts the EIP-2929 changes made to a jump table, restoring the
+// Istanbul flat-cost gas functions for state access opcodes: SLOAD, SSTORE, BALANCE,
+// EXTCODE*, the CALL family and SELFDESTRUCT no longer distinguish cold and warm
+// accesses. Later forks' changes that build on EIP-2929 (the EIP-3529 refund changes
+// and EIP-7702 delegation access costs) are reverted with it; UseEIP3529Refunds
+// restores the refund changes.
+//
+// Used by gas simulation to compare the Berlin access model against pre-Berlin costs
+// on post-Berlin blocks. The table must be a copy (see GetBaseJumpTable).
+func DisableEIP2929(jt *JumpTable) {
+	jt[SLOAD].constantGas = params.SloadGasEIP2200
+	jt[SLOAD].dynamicGas = nil
+	jt[SSTORE].dynamicGas = gasSStoreEIP2200
+
+	jt[BALANCE].constantGas = params.BalanceGasEIP1884
+	jt[BALANCE].dynamicGas = nil
+	jt[EXTCODESIZE].constantGas = params.ExtcodeSizeGasEIP150
+	jt[EXTCODESIZE].dynamicGas = nil
+	jt[EXTCODEHASH].constantGas = params.ExtcodeHashGasEIP1884
+	jt[EXTCODEHASH].dynamicGas = nil
+	jt[EXTCODECOPY].constantGas = params.ExtcodeCopyBaseEIP150
+	jt[EXTCODECOPY].dynamicGas = gasExtCodeCopy
+
+	jt[CALL].constantGas = params.CallGasEIP150
+	jt[CALL].dynamicGas = gasCall
+	jt[CALLCODE].constantGas = params.CallGasEIP150
+	jt[CALLCODE].dynamicGas = gasCallCode
+	jt[DELEGATECALL].constantGas = params.CallGasEIP150
+	jt[DELEGATECALL].dynamicGas = gasDelegateCall
+	jt[STATICCALL].constantGas = params.CallGasEIP150
+	jt[STATICCALL].dynamicGas = gasStaticCall
+
+	jt[SELFDESTRUCT].dynamicGas = gasSelfdestruct
+}
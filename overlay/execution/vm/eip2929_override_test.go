@@ -0,0 +1,66 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// TestDisableEIP2929 verifies that state access opcodes revert to flat costs with
+// no cold/warm dynamic component, and that the shared fork table is not modified.
+func TestDisableEIP2929(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+
+	jt := GetBaseJumpTable(rules)
+	DisableEIP2929(jt)
+
+	flat := map[OpCode]uint64{
+		SLOAD:        params.SloadGasEIP2200,
+		BALANCE:      params.BalanceGasEIP1884,
+		EXTCODESIZE:  params.ExtcodeSizeGasEIP150,
+		EXTCODEHASH:  params.ExtcodeHashGasEIP1884,
+		EXTCODECOPY:  params.ExtcodeCopyBaseEIP150,
+		CALL:         params.CallGasEIP150,
+		CALLCODE:     params.CallGasEIP150,
+		DELEGATECALL: params.CallGasEIP150,
+		STATICCALL:   params.CallGasEIP150,
+	}
+
+	for op, want := range flat {
+		if jt[op].constantGas != want {
+			t.Errorf("%s constant gas = %d, want %d", op, jt[op].constantGas, want)
+		}
+	}
+
+	// Opcodes whose only dynamic cost was the cold access surcharge
+	for _, op := range []OpCode{SLOAD, BALANCE, EXTCODESIZE, EXTCODEHASH} {
+		if jt[op].dynamicGas != nil {
+			t.Errorf("%s still has a dynamic (cold/warm) gas function", op)
+		}
+	}
+
+	// The fork's own table keeps the EIP-2929 costs
+	if base := GetBaseJumpTable(rules); base[SLOAD].constantGas != 0 || base[SLOAD].dynamicGas == nil {
+		t.Error("DisableEIP2929 modified the shared fork jump table")
+	}
+}
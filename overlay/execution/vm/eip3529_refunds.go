@@ -0,0 +1,57 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import "github.com/erigontech/erigon/execution/protocol/params"
+
+// eip3529ClearingReduction is how much EIP-3529 lowered the refund for clearing a
+// storage slot (SSTORE_CLEARS_SCHEDULE, 15,000 to 4,800).
+const eip3529ClearingReduction = params.SstoreClearsScheduleRefundEIP2200 - params.SstoreClearsScheduleRefundEIP3529
+
+// UseEIP3529Refunds applies the EIP-3529 refunds to the Istanbul SSTORE and
+// SELFDESTRUCT gas functions installed by DisableEIP2929: clearing a slot refunds
+// 4,800 instead of 15,000 (and recreating it takes back as much), and SELFDESTRUCT
+// refunds nothing. Refunds for restoring a slot's original value are unchanged.
+//
+// Used by gas simulation to disable the access list of a post-London block without
+// also reverting its refund rules. The table must be a copy (see GetBaseJumpTable).
+func UseEIP3529Refunds(jt *JumpTable) {
+	jt[SSTORE].dynamicGas = withRefundAdjustment(jt[SSTORE].dynamicGas, eip3529SstoreRefund)
+	jt[SELFDESTRUCT].dynamicGas = withRefundAdjustment(jt[SELFDESTRUCT].dynamicGas, eip3529SelfdestructRefund)
+}
+
+// eip3529SstoreRefund adjusts the refund an EIP-2200 SSTORE added to the EIP-3529
+// clearing refund. The change is +15,000 when the SSTORE clears a slot and negative
+// when it recreates one (-15,000, or -10,800 when that also restores the original
+// value); the other changes are restores, which EIP-3529 left as they were.
+func eip3529SstoreRefund(evm *EVM, before, after uint64) {
+	switch {
+	case after > before && after-before == params.SstoreClearsScheduleRefundEIP2200:
+		evm.IntraBlockState().SubRefund(eip3529ClearingReduction)
+	case after < before:
+		evm.IntraBlockState().AddRefund(eip3529ClearingReduction)
+	}
+}
+
+// eip3529SelfdestructRefund removes the refund a pre-London SELFDESTRUCT added.
+func eip3529SelfdestructRefund(evm *EVM, before, after uint64) {
+	if after > before {
+		evm.IntraBlockState().SubRefund(after - before)
+	}
+}
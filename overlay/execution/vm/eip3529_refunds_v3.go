@@ -0,0 +1,36 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && !erigon_main
+
+package vm
+
+// withRefundAdjustment wraps a gas function so that adjust can correct the refund
+// counter change it made. adjust is given the counter before and after fn ran.
+func withRefundAdjustment(fn gasFunc, adjust func(evm *EVM, before, after uint64)) gasFunc {
+	return func(evm *EVM, callContext *CallContext, scopeGas uint64, memorySize uint64) (uint64, error) {
+		before := evm.IntraBlockState().GetRefund()
+
+		gas, err := fn(evm, callContext, scopeGas, memorySize)
+		if err != nil {
+			return gas, err
+		}
+
+		adjust(evm, before, evm.IntraBlockState().GetRefund())
+
+		return gas, nil
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import (
+	"github.com/erigontech/erigon/common/math"
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// UseForceAllCold makes every storage slot and account access charge the EIP-2929
+// cold cost, as if the access list were empty at each access: a slot or address
+// that is already warm (accessed earlier, declared in the access list, or warm from
+// the start of the transaction, like the sender and precompiles) is charged its
+// cold surcharge on top of the warm cost. The access list itself is unchanged.
+//
+// Used by gas simulation to model the worst case of a cold-access repricing. Only
+// meaningful for EIP-2929 tables; the table must be a copy (see GetBaseJumpTable).
+func UseForceAllCold(jt *JumpTable) {
+	for op, surcharge := range map[OpCode]func(*GasSchedule) uint64{
+		SLOAD:  sloadColdSurcharge,
+		SSTORE: sstoreColdSurcharge,
+	} {
+		if jt.IsDefined(op) && jt[op].dynamicGas != nil {
+			jt[op].dynamicGas = withColdSlot(jt[op].dynamicGas, surcharge)
+		}
+	}
+
+	// SELFDESTRUCT has no warm cost, so its surcharge is the full cold cost below
+	for op, arg := range warmAddressArgs {
+		if op != SELFDESTRUCT && jt.IsDefined(op) && jt[op].dynamicGas != nil {
+			jt[op].dynamicGas = withColdAddress(jt[op].dynamicGas, arg, accountColdSurcharge)
+		}
+	}
+
+	if jt.IsDefined(SELFDESTRUCT) && jt[SELFDESTRUCT].dynamicGas != nil {
+		jt[SELFDESTRUCT].dynamicGas = withColdAddress(jt[SELFDESTRUCT].dynamicGas, 0, selfdestructColdSurcharge)
+	}
+}
+
+// sloadColdSurcharge is the extra cost of a cold SLOAD: SLOAD_COLD instead of SLOAD_WARM.
+func sloadColdSurcharge(g *GasSchedule) uint64 {
+	return math.SafeSubClamp(g.GetOr(GasKeySloadCold, params.ColdSloadCostEIP2929), g.GetOr(GasKeySloadWarm, params.WarmStorageReadCostEIP2929))
+}
+
+// sstoreColdSurcharge is the extra cost of a cold SSTORE, which adds SLOAD_COLD to
+// the cost of the write.
+func sstoreColdSurcharge(g *GasSchedule) uint64 {
+	return g.GetOr(GasKeySloadCold, params.ColdSloadCostEIP2929)
+}
+
+// accountColdSurcharge is the extra cost of a cold account access: CALL_COLD
+// instead of the warm cost charged as constant gas.
+func accountColdSurcharge(g *GasSchedule) uint64 {
+	return math.SafeSubClamp(g.GetOr(GasKeyCallCold, params.ColdAccountAccessCostEIP2929), g.GetOr(GasKeyCallWarm, params.WarmStorageReadCostEIP2929))
+}
+
+// selfdestructColdSurcharge is the extra cost of a SELFDESTRUCT to a cold
+// beneficiary, which has no warm cost.
+func selfdestructColdSurcharge(g *GasSchedule) uint64 {
+	return g.GetOr(GasKeyCallCold, params.ColdAccountAccessCostEIP2929)
+}
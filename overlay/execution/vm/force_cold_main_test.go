@@ -0,0 +1,93 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/state"
+)
+
+// TestUseForceAllCold runs the same access twice within a transaction and checks
+// that the repeated access, warm under EIP-2929, is charged the cold cost again.
+func TestUseForceAllCold(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true,
+		IsShanghai: true, IsCancun: true}
+
+	tests := []struct {
+		name  string
+		op    OpCode
+		stack []uint64 // pushed in order, so the last entry is the top of the stack
+		warm  uint64   // cost of the repeated access under EIP-2929
+		cold  uint64   // cost of the repeated access with every access cold
+	}{
+		{name: "SLOAD", op: SLOAD, stack: []uint64{1}, warm: 100, cold: 2100},
+		// The stored value is 3, so writing it again is a noop
+		{name: "SSTORE", op: SSTORE, stack: []uint64{3, 1}, warm: 100, cold: 2100 + 100},
+		{name: "BALANCE", op: BALANCE, stack: []uint64{0xdead}, warm: 0, cold: 2500},
+		{name: "CALL", op: CALL, stack: []uint64{0, 0, 0, 0, 0, 0xdead, 0}, warm: 0, cold: 2500},
+		// A warm beneficiary costs nothing extra, so forcing it cold adds CALL_COLD once
+		{name: "SELFDESTRUCT", op: SELFDESTRUCT, stack: []uint64{0xdead}, warm: 0, cold: 2600},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, force := range []bool{false, true} {
+				jt := GetBaseJumpTable(rules)
+				if force {
+					UseForceAllCold(jt)
+				}
+
+				ibs := state.New(&driftStateReader{value: *uint256.NewInt(3)})
+				evm := &EVM{intraBlockState: ibs, chainRules: rules}
+
+				access := func() uint64 {
+					callContext := &CallContext{gas: math.MaxUint64}
+					for _, v := range tc.stack {
+						callContext.Stack.Push(uint256.NewInt(v))
+					}
+
+					gas, err := jt[tc.op].dynamicGas(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, 0)
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+
+					return gas.Regular
+				}
+
+				first, second := access(), access()
+
+				want := tc.warm
+				if force {
+					want = tc.cold
+				}
+
+				if first != tc.cold || second != want {
+					t.Errorf("force=%v: accesses charged %d then %d, want %d then %d", force, first, second, tc.cold, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && !erigon_main
+
+package vm
+
+import "github.com/erigontech/erigon/common"
+
+// withColdSlot wraps a storage gas function so that a slot of the executing
+// contract that is already warm is charged surcharge on top of fn's cost.
+func withColdSlot(fn gasFunc, surcharge func(*GasSchedule) uint64) gasFunc {
+	return func(evm *EVM, callContext *CallContext, scopeGas uint64, memorySize uint64) (uint64, error) {
+		loc := callContext.Stack.Peek()
+		_, warm := evm.IntraBlockState().SlotInAccessList(callContext.Address(), loc.Bytes32())
+
+		return withColdSurcharge(fn, warm, surcharge, evm, callContext, scopeGas, memorySize)
+	}
+}
+
+// withColdAddress wraps an account-access gas function so that a target, read
+// from stack position arg, that is already warm is charged surcharge on top of
+// fn's cost.
+func withColdAddress(fn gasFunc, arg int, surcharge func(*GasSchedule) uint64) gasFunc {
+	return func(evm *EVM, callContext *CallContext, scopeGas uint64, memorySize uint64) (uint64, error) {
+		addr := common.Address(callContext.Stack.Back(arg).Bytes20())
+		warm := evm.IntraBlockState().AddressInAccessList(addr)
+
+		return withColdSurcharge(fn, warm, surcharge, evm, callContext, scopeGas, memorySize)
+	}
+}
+
+// withColdSurcharge runs fn, adding the cold surcharge if the access is warm. The
+// surcharge is taken from the available gas before fn runs, like a cold access,
+// so that CALL variants allot the child frame what a cold call would.
+func withColdSurcharge(fn gasFunc, warm bool, surcharge func(*GasSchedule) uint64,
+	evm *EVM, callContext *CallContext, scopeGas uint64, memorySize uint64) (uint64, error) {
+	if !warm {
+		return fn(evm, callContext, scopeGas, memorySize)
+	}
+
+	extra := surcharge(evm.GasSchedule)
+	if scopeGas < extra {
+		return 0, ErrOutOfGas
+	}
+
+	gas, err := fn(evm, callContext, scopeGas-extra, memorySize)
+	if err != nil {
+		return 0, err
+	}
+
+	if gas+extra < gas {
+		return 0, ErrGasUintOverflow
+	}
+
+	return gas + extra, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/state"
+	"github.com/erigontech/erigon/execution/types/accounts"
+)
+
+// driftStateReader serves an existing account whose storage slots all hold value.
+// Only the reads made by the gas functions under test are implemented.
+type driftStateReader struct {
+	state.StateReader
+	value uint256.Int
+}
+
+func (r *driftStateReader) ReadAccountData(accounts.Address) (*accounts.Account, error) {
+	return &accounts.Account{Initialised: true, Nonce: 1}, nil
+}
+
+func (r *driftStateReader) ReadAccountStorage(accounts.Address, accounts.StorageKey) (uint256.Int, bool, error) {
+	return r.value, true, nil
+}
+
+// driftDefaults sets every dynamic gas key the patched functions read to its
+// standard (Cancun) value, as a client sending the full default schedule would.
+func driftDefaults() *GasSchedule {
+	return &GasSchedule{Overrides: map[string]uint64{
+		GasKeySloadCold:        params.ColdSloadCostEIP2929,
+		GasKeySloadWarm:        params.WarmStorageReadCostEIP2929,
+		GasKeySstoreSet:        params.SstoreSetGasEIP2200,
+		GasKeySstoreReset:      params.SstoreResetGasEIP2200,
+		GasKeySstoreNoop:       params.WarmStorageReadCostEIP2929,
+		GasKeyCallCold:         params.ColdAccountAccessCostEIP2929,
+		GasKeyCallWarm:         params.WarmStorageReadCostEIP2929,
+		GasKeyCallValueXfer:    params.CallValueTransferGas,
+		GasKeyCallNewAccount:   params.CallNewAccountGas,
+		GasKeyKeccak256Word:    params.Keccak256WordGas,
+		GasKeyMemory:           params.MemoryGas,
+		GasKeyCopy:             params.CopyGas,
+		GasKeyLog:              params.LogGas,
+		GasKeyLogTopic:         params.LogTopicGas,
+		GasKeyLogData:          params.LogDataGas,
+		GasKeyExpByte:          params.ExpByteEIP160,
+		GasKeyDelegateCallCold: params.ColdAccountAccessCostEIP2929,
+		GasKeyStaticCallCold:   params.ColdAccountAccessCostEIP2929,
+	}}
+}
+
+// TestDynamicGasDrift guards the patched upstream gas functions against drift after
+// a rebase. Each case runs an opcode's dynamic gas function from the stock Cancun
+// JumpTable over a representative EVM state, with no schedule (the upstream
+// constants) and with every key set to its default, and checks both against the
+// cost given by the EIPs. A mismatch means upstream changed a formula that the
+// overrides hook into, or that a GetOr fallback no longer matches upstream.
+func TestDynamicGasDrift(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true,
+		IsShanghai: true, IsCancun: true}
+
+	target := uint256.NewInt(0xdead)
+
+	tests := []struct {
+		name       string
+		op         OpCode
+		stack      []uint64 // pushed in order, so the last entry is the top of the stack
+		memorySize uint64
+		stored     uint64 // value of every storage slot before the transaction
+		warm       bool   // pre-warm the slot or target address
+		want       uint64
+	}{
+		{name: "SLOAD cold", op: SLOAD, stack: []uint64{1}, want: 2100},
+		{name: "SLOAD warm", op: SLOAD, stack: []uint64{1}, warm: true, want: 100},
+		{name: "SSTORE cold set", op: SSTORE, stack: []uint64{7, 1}, want: 2100 + 20000},
+		{name: "SSTORE warm set", op: SSTORE, stack: []uint64{7, 1}, warm: true, want: 20000},
+		{name: "SSTORE cold reset", op: SSTORE, stack: []uint64{7, 1}, stored: 3, want: 2100 + 2900},
+		{name: "SSTORE warm reset", op: SSTORE, stack: []uint64{7, 1}, stored: 3, warm: true, want: 2900},
+		{name: "SSTORE cold noop", op: SSTORE, stack: []uint64{3, 1}, stored: 3, want: 2100 + 100},
+		{name: "SSTORE warm noop", op: SSTORE, stack: []uint64{0, 1}, warm: true, want: 100},
+		{name: "EXP one byte", op: EXP, stack: []uint64{0xff, 2}, want: 10 + 50},
+		{name: "EXP two bytes", op: EXP, stack: []uint64{0x1234, 2}, want: 10 + 100},
+		// 64 bytes over 2 fresh words: memory 2*3, hashing 2*6
+		{name: "KECCAK256", op: KECCAK256, stack: []uint64{64, 0}, memorySize: 64, want: 6 + 12},
+		// 64 bytes over 2 fresh words: memory 6, base 375, topics n*375, data 64*8
+		{name: "LOG0", op: LOG0, stack: []uint64{64, 0}, memorySize: 64, want: 6 + 375 + 512},
+		{name: "LOG2", op: LOG2, stack: []uint64{0, 0, 64, 0}, memorySize: 64, want: 6 + 375 + 750 + 512},
+		{name: "LOG4", op: LOG4, stack: []uint64{0, 0, 0, 0, 64, 0}, memorySize: 64, want: 6 + 375 + 1500 + 512},
+		// 100 bytes into 4 fresh words: memory 4*3, copy 4*3
+		{name: "CALLDATACOPY", op: CALLDATACOPY, stack: []uint64{100, 0, 0}, memorySize: 128, want: 12 + 12},
+		{name: "CODECOPY", op: CODECOPY, stack: []uint64{100, 0, 0}, memorySize: 128, want: 12 + 12},
+		{name: "RETURNDATACOPY", op: RETURNDATACOPY, stack: []uint64{100, 0, 0}, memorySize: 128, want: 12 + 12},
+		{name: "MCOPY", op: MCOPY, stack: []uint64{100, 0, 0}, memorySize: 128, want: 12 + 12},
+		{name: "EXTCODECOPY cold", op: EXTCODECOPY, stack: []uint64{100, 0, 0, 0xdead}, memorySize: 128, want: 12 + 12 + 2500},
+		{name: "EXTCODECOPY warm", op: EXTCODECOPY, stack: []uint64{100, 0, 0, 0xdead}, memorySize: 128, warm: true, want: 12 + 12},
+		// Calls forward no gas, so only the access, value and memory costs remain
+		{name: "CALL cold", op: CALL, stack: []uint64{0, 0, 0, 0, 0, 0xdead, 0}, want: 2500},
+		{name: "CALL warm", op: CALL, stack: []uint64{0, 0, 0, 0, 0, 0xdead, 0}, warm: true, want: 0},
+		{name: "CALL warm with value", op: CALL, stack: []uint64{0, 0, 0, 0, 1, 0xdead, 0}, warm: true, want: 9000},
+		{name: "CALLCODE cold with value", op: CALLCODE, stack: []uint64{0, 0, 0, 0, 1, 0xdead, 0}, want: 2500 + 9000},
+		{name: "DELEGATECALL cold", op: DELEGATECALL, stack: []uint64{0, 0, 0, 0, 0xdead, 0}, want: 2500},
+		{name: "DELEGATECALL warm", op: DELEGATECALL, stack: []uint64{0, 0, 0, 0, 0xdead, 0}, warm: true, want: 0},
+		{name: "STATICCALL cold", op: STATICCALL, stack: []uint64{0, 0, 0, 0, 0xdead, 0}, want: 2500},
+		// 96 bytes of return data into 3 fresh words: memory 3*3
+		{name: "STATICCALL warm with memory", op: STATICCALL, stack: []uint64{96, 0, 0, 0, 0xdead, 0}, memorySize: 96, warm: true, want: 9},
+	}
+
+	schedules := map[string]func() *GasSchedule{
+		"stock":    func() *GasSchedule { return nil },
+		"defaults": driftDefaults,
+	}
+
+	jt := GetBaseJumpTable(rules)
+
+	for _, tc := range tests {
+		for scheduleName, schedule := range schedules {
+			t.Run(tc.name+"/"+scheduleName, func(t *testing.T) {
+				ibs := state.New(&driftStateReader{value: *uint256.NewInt(tc.stored)})
+				evm := &EVM{GasSchedule: schedule(), intraBlockState: ibs, chainRules: rules}
+
+				callContext := &CallContext{gas: math.MaxUint64}
+				for _, v := range tc.stack {
+					callContext.Stack.Push(uint256.NewInt(v))
+				}
+
+				if tc.warm {
+					ibs.AddAddressToAccessList(accounts.InternAddress(target.Bytes20()))
+					ibs.AddSlotToAccessList(callContext.Address(), callContext.peekStorageKey())
+				}
+
+				fn := jt[tc.op].dynamicGas
+				if fn == nil {
+					t.Fatalf("%s has no dynamic gas function", tc.op)
+				}
+
+				gas, err := fn(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, tc.memorySize)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if gas.Regular != tc.want {
+					t.Errorf("%s gas = %d, want %d", tc.op, gas.Regular, tc.want)
+				}
+			})
+		}
+	}
+}
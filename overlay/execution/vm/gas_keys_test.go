@@ -0,0 +1,87 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestGasKeyListsComplete verifies that every GasKey* constant declared in the
+// package appears in exactly one of the exported key lists, so the key catalog
+// stays complete when a key is added.
+func TestGasKeyListsComplete(t *testing.T) {
+	listed := make(map[string]int)
+	for _, keys := range [][]string{DynamicGasKeys, PrecompileGasKeys, IntrinsicGasKeys} {
+		for _, key := range keys {
+			listed[key]++
+		}
+	}
+
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+
+	var declared int
+	for _, path := range files {
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.ValueSpec)
+			if !ok {
+				return true
+			}
+
+			for i, name := range spec.Names {
+				if !strings.HasPrefix(name.Name, "GasKey") || i >= len(spec.Values) {
+					continue
+				}
+
+				lit, ok := spec.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+
+				key, _ := strconv.Unquote(lit.Value)
+				declared++
+
+				if listed[key] != 1 {
+					t.Errorf("%s (%s) appears in %d key lists, want 1", name.Name, key, listed[key])
+				}
+			}
+
+			return true
+		})
+	}
+
+	if declared != len(listed) {
+		t.Errorf("found %d GasKey constants, key lists hold %d keys", declared, len(listed))
+	}
+}
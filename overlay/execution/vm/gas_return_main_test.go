@@ -0,0 +1,79 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// TestReturnRevertMemoryOverride verifies that RETURN and REVERT charge memory
+// expansion through memoryGasCost, so a MEMORY override changes the linear part of
+// their dynamic gas while the quadratic part stays fixed.
+func TestReturnRevertMemoryOverride(t *testing.T) {
+	const (
+		memorySize = 1024 * 32 // 1024 words returned from fresh memory
+		words      = memorySize / 32
+		quadratic  = words * words / params.QuadCoeffDiv
+	)
+
+	tests := []struct {
+		name     string
+		schedule *GasSchedule
+		want     uint64
+	}{
+		{
+			name:     "standard MEMORY",
+			schedule: nil,
+			want:     words*params.MemoryGas + quadratic,
+		},
+		{
+			name:     "custom MEMORY",
+			schedule: &GasSchedule{Overrides: map[string]uint64{GasKeyMemory: 10}},
+			want:     words*10 + quadratic,
+		},
+		{
+			name:     "zero MEMORY leaves only the quadratic part",
+			schedule: &GasSchedule{Overrides: map[string]uint64{GasKeyMemory: 0}},
+			want:     quadratic,
+		},
+	}
+
+	ops := map[string]gasFunc{"RETURN": gasReturn, "REVERT": gasRevert}
+
+	for _, tc := range tests {
+		for opName, fn := range ops {
+			t.Run(opName+"/"+tc.name, func(t *testing.T) {
+				evm := &EVM{GasSchedule: tc.schedule}
+
+				gas, err := fn(evm, &CallContext{}, mdgas.MdGas{Regular: math.MaxUint64}, memorySize)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if gas.Regular != tc.want {
+					t.Errorf("%s gas = %d, want %d", opName, gas.Regular, tc.want)
+				}
+			})
+		}
+	}
+}
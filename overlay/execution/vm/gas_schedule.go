@@ -16,11 +16,21 @@
 
 package vm
 
+import (
+	"maps"
+
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
 // GasSchedule holds configurable gas costs for simulation.
 // When set on the EVM, gas functions use GetOr() to read overridden values
 // instead of hardcoded params.X constants.
 type GasSchedule struct {
 	Overrides map[string]uint64
+
+	// derived caches the schedules derived by derivedSchedule, keyed by the
+	// per-opcode key. A schedule belongs to a single EVM, so no locking.
+	derived map[string]*GasSchedule
 }
 
 // GetOr returns the override value if set, otherwise the default.
@@ -36,6 +46,57 @@ func (g *GasSchedule) GetOr(key string, defaultVal uint64) uint64 {
 	return defaultVal
 }
 
+// SstoreNoopCost returns the cost of an SSTORE that writes back the current value.
+// EIP-2200 charges SLOAD_GAS (the warm read cost since EIP-2929) for this case, so
+// it follows SLOAD_WARM unless SSTORE_NOOP is overridden to price it independently.
+func (g *GasSchedule) SstoreNoopCost() uint64 {
+	return g.GetOr(GasKeySstoreNoop, g.GetOr(GasKeySloadWarm, params.WarmStorageReadCostEIP2929))
+}
+
+// callColdSchedule returns a copy of the schedule with CALL_COLD replaced by the value
+// of key, or nil if key is not overridden. The patched CALL-variant gas functions all
+// read CALL_COLD, so a per-opcode cold cost is applied by running that opcode's gas
+// function against the derived schedule (see UseCallColdKeys).
+func (g *GasSchedule) callColdSchedule(key string) *GasSchedule {
+	return g.derivedSchedule(key, GasKeyCallCold)
+}
+
+// memorySchedule returns a copy of the schedule with MEMORY replaced by the value of
+// key, or nil if key is not overridden (see UseMemoryKeys).
+func (g *GasSchedule) memorySchedule(key string) *GasSchedule {
+	return g.derivedSchedule(key, GasKeyMemory)
+}
+
+// derivedSchedule returns a copy of the schedule with target replaced by the value of
+// the per-opcode key, or nil if key is not overridden. Derived schedules are cached,
+// so an opcode executed repeatedly does not copy the overrides each time.
+func (g *GasSchedule) derivedSchedule(key, target string) *GasSchedule {
+	if g == nil {
+		return nil
+	}
+
+	value, ok := g.Overrides[key]
+	if !ok {
+		return nil
+	}
+
+	if derived, ok := g.derived[key]; ok {
+		return derived
+	}
+
+	overrides := maps.Clone(g.Overrides)
+	overrides[target] = value
+	derived := &GasSchedule{Overrides: overrides}
+
+	if g.derived == nil {
+		g.derived = make(map[string]*GasSchedule, 2)
+	}
+
+	g.derived[key] = derived
+
+	return derived
+}
+
 // Gas parameter keys for dynamic gas components.
 //
 // These are NOT opcode names. Constant-gas opcodes (ADD, MUL, PUSH, etc.) use
@@ -51,7 +112,10 @@ const (
 	GasKeySloadWarm            = "SLOAD_WARM"
 	GasKeySstoreSet            = "SSTORE_SET"
 	GasKeySstoreReset          = "SSTORE_RESET"
+	GasKeySstoreNoop           = "SSTORE_NOOP"
 	GasKeyCallCold             = "CALL_COLD"
+	GasKeyDelegateCallCold     = "DELEGATECALL_COLD"
+	GasKeyStaticCallCold       = "STATICCALL_COLD"
 	GasKeyCallWarm             = "CALL_WARM"
 	GasKeyCallValueXfer        = "CALL_VALUE_XFER"
 	GasKeyCallNewAccount       = "CALL_NEW_ACCOUNT"
@@ -66,3 +130,21 @@ const (
 	GasKeyInitCodeWord         = "INIT_CODE_WORD"
 	GasKeyCreateData           = "CREATE_DATA"
 )
+
+// GasKeyMemoryPrefix prefixes the per-opcode memory expansion coefficients,
+// MEMORY_<OPCODE> (e.g. MEMORY_MCOPY). An opcode whose key is unset uses MEMORY.
+const GasKeyMemoryPrefix = GasKeyMemory + "_"
+
+// GasKeyRefundCapDiv is the EIP-3529 refund cap divisor. The EVM keeps applying the
+// standard divisor; the override is applied when the simulation reports net gas.
+const GasKeyRefundCapDiv = "REFUND_CAP_DIV"
+
+// DynamicGasKeys lists every dynamic gas parameter key understood by the patched
+// gas functions.
+var DynamicGasKeys = []string{
+	GasKeySloadCold, GasKeySloadWarm, GasKeySstoreSet, GasKeySstoreReset, GasKeySstoreNoop,
+	GasKeyCallCold, GasKeyDelegateCallCold, GasKeyStaticCallCold, GasKeyCallWarm,
+	GasKeyCallValueXfer, GasKeyCallNewAccount, GasKeyKeccak256Word, GasKeyMemory, GasKeyCopy,
+	GasKeyLog, GasKeyLogTopic, GasKeyLogData, GasKeyExpByte, GasKeyCreateBySelfDestruct,
+	GasKeyInitCodeWord, GasKeyCreateData, GasKeyRefundCapDiv,
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/state"
+)
+
+// TestSstoreNoopExecution runs the London SSTORE gas function on a write of a
+// slot's current value and checks the no-op part of its cost follows SSTORE_NOOP,
+// or SLOAD_WARM when SSTORE_NOOP is unset.
+func TestSstoreNoopExecution(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+
+	tests := []struct {
+		name     string
+		schedule *GasSchedule
+		wantNoop uint64
+	}{
+		{name: "default", schedule: nil, wantNoop: params.WarmStorageReadCostEIP2929},
+		{name: "SSTORE_NOOP", schedule: &GasSchedule{Overrides: map[string]uint64{GasKeySstoreNoop: 800}}, wantNoop: 800},
+		{name: "follows SLOAD_WARM", schedule: &GasSchedule{Overrides: map[string]uint64{GasKeySloadWarm: 300}}, wantNoop: 300},
+		{
+			name:     "SSTORE_NOOP over SLOAD_WARM",
+			schedule: &GasSchedule{Overrides: map[string]uint64{GasKeySloadWarm: 300, GasKeySstoreNoop: 50}},
+			wantNoop: 50,
+		},
+	}
+
+	jt := GetBaseJumpTable(rules)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Every slot holds 1, so storing 1 writes the current value
+			evm := &EVM{intraBlockState: state.New(&driftStateReader{value: *uint256.NewInt(1)}), chainRules: rules}
+			evm.GasSchedule = tc.schedule
+
+			callContext := &CallContext{gas: math.MaxUint64}
+			callContext.Stack.Push(uint256.NewInt(1)) // value
+			callContext.Stack.Push(uint256.NewInt(1)) // slot
+
+			gas, err := jt[SSTORE].dynamicGas(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The slot is cold on first access
+			if want := params.ColdSloadCostEIP2929 + tc.wantNoop; gas.Regular != want {
+				t.Errorf("SSTORE gas = %d, want %d", gas.Regular, want)
+			}
+		})
+	}
+}
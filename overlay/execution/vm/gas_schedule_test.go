@@ -0,0 +1,67 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// TestSstoreNoopCost verifies the cost charged when an SSTORE writes back the
+// slot's current value (e.g. the second of two identical writes to a warm slot).
+func TestSstoreNoopCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule *GasSchedule
+		want     uint64
+	}{
+		{
+			name:     "nil schedule uses warm read cost",
+			schedule: nil,
+			want:     params.WarmStorageReadCostEIP2929,
+		},
+		{
+			name:     "empty schedule uses warm read cost",
+			schedule: &GasSchedule{Overrides: map[string]uint64{}},
+			want:     params.WarmStorageReadCostEIP2929,
+		},
+		{
+			name:     "follows SLOAD_WARM when SSTORE_NOOP absent",
+			schedule: &GasSchedule{Overrides: map[string]uint64{GasKeySloadWarm: 250}},
+			want:     250,
+		},
+		{
+			name:     "SSTORE_NOOP takes precedence",
+			schedule: &GasSchedule{Overrides: map[string]uint64{GasKeySloadWarm: 250, GasKeySstoreNoop: 42}},
+			want:     42,
+		},
+		{
+			name:     "SSTORE_NOOP can be zero",
+			schedule: &GasSchedule{Overrides: map[string]uint64{GasKeySstoreNoop: 0}},
+			want:     0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.schedule.SstoreNoopCost(); got != tc.want {
+				t.Errorf("SstoreNoopCost() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -36,17 +36,20 @@ const (
 	GasKeyTxAuthCost       = "TX_AUTH_COST"
 )
 
+// IntrinsicGasKeys lists every intrinsic gas override key.
+var IntrinsicGasKeys = []string{
+	GasKeyTxBase, GasKeyTxCreateBase, GasKeyTxDataZero, GasKeyTxDataNonZero,
+	GasKeyTxAccessListAddr, GasKeyTxAccessListKey, GasKeyTxInitCodeWord,
+	GasKeyTxFloorPerToken, GasKeyTxAuthCost,
+}
+
 // HasIntrinsicOverrides returns true if any intrinsic gas keys are overridden.
 func (g *GasSchedule) HasIntrinsicOverrides() bool {
 	if g == nil || g.Overrides == nil {
 		return false
 	}
 
-	for _, key := range []string{
-		GasKeyTxBase, GasKeyTxCreateBase, GasKeyTxDataZero, GasKeyTxDataNonZero,
-		GasKeyTxAccessListAddr, GasKeyTxAccessListKey, GasKeyTxInitCodeWord,
-		GasKeyTxFloorPerToken, GasKeyTxAuthCost,
-	} {
+	for _, key := range IntrinsicGasKeys {
 		if _, ok := g.Overrides[key]; ok {
 			return true
 		}
@@ -97,38 +100,12 @@ func CalcCustomIntrinsicGas(
 	// Bump the required gas by the amount of transactional data
 	dataLen := uint64(len(data))
 	if dataLen > 0 {
-		// Zero and non-zero bytes are priced differently
-		var nz uint64
-		for _, b := range data {
-			if b != 0 {
-				nz++
-			}
-		}
-
-		// Make sure we don't exceed uint64 for all data combinations
-		nonZeroGas := schedule.GetOr(GasKeyTxDataNonZero, params.TxDataNonZeroGasFrontier)
-		if isEIP2028 {
-			nonZeroGas = schedule.GetOr(GasKeyTxDataNonZero, params.TxDataNonZeroGasEIP2028)
-		}
-
-		product, overflow := math.SafeMul(nz, nonZeroGas)
+		calldataCost, nz, overflow := callcalldataCost(schedule, data, isEIP2028)
 		if overflow {
 			return 0, 0
 		}
 
-		gas, overflow = math.SafeAdd(gas, product)
-		if overflow {
-			return 0, 0
-		}
-
-		z := dataLen - nz
-
-		product, overflow = math.SafeMul(z, schedule.GetOr(GasKeyTxDataZero, params.TxDataZeroGas))
-		if overflow {
-			return 0, 0
-		}
-
-		gas, overflow = math.SafeAdd(gas, product)
+		gas, overflow = math.SafeAdd(gas, calldataCost)
 		if overflow {
 			return 0, 0
 		}
@@ -136,7 +113,7 @@ func CalcCustomIntrinsicGas(
 		if isContractCreation && isEIP3860 {
 			numWords := intrinsicToWordSize(dataLen)
 
-			product, overflow = math.SafeMul(numWords, schedule.GetOr(GasKeyTxInitCodeWord, params.InitCodeWordGas))
+			product, overflow := math.SafeMul(numWords, schedule.GetOr(GasKeyTxInitCodeWord, params.InitCodeWordGas))
 			if overflow {
 				return 0, 0
 			}
@@ -197,3 +174,46 @@ func CalcCustomIntrinsicGas(
 
 	return gas, floorGas7623
 }
+
+// CalldataGas returns the data cost of intrinsic gas: TX_DATA_ZERO per zero byte
+// plus TX_DATA_NONZERO per non-zero byte, as CalcCustomIntrinsicGas charges it. It
+// returns 0 if the cost overflows.
+func CalldataGas(schedule *GasSchedule, data []byte, isEIP2028 bool) uint64 {
+	gas, _, overflow := calldataGas(schedule, data, isEIP2028)
+	if overflow {
+		return 0
+	}
+
+	return gas
+}
+
+// calldataGas returns the data cost of intrinsic gas and the number of non-zero
+// bytes in data.
+func calldataGas(schedule *GasSchedule, data []byte, isEIP2028 bool) (gas, nz uint64, overflow bool) {
+	// Zero and non-zero bytes are priced differently
+	for _, b := range data {
+		if b != 0 {
+			nz++
+		}
+	}
+
+	// Make sure we don't exceed uint64 for all data combinations
+	nonZeroGas := schedule.GetOr(GasKeyTxDataNonZero, params.TxDataNonZeroGasFrontier)
+	if isEIP2028 {
+		nonZeroGas = schedule.GetOr(GasKeyTxDataNonZero, params.TxDataNonZeroGasEIP2028)
+	}
+
+	nonZero, overflow := math.SafeMul(nz, nonZeroGas)
+	if overflow {
+		return 0, 0, true
+	}
+
+	zero, overflow := math.SafeMul(uint64(len(data))-nz, schedule.GetOr(GasKeyTxDataZero, params.TxDataZeroGas))
+	if overflow {
+		return 0, 0, true
+	}
+
+	gas, overflow = math.SafeAdd(nonZero, zero)
+
+	return gas, nz, overflow
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// TestCalcCustomIntrinsicGasInitCode verifies that contract-creation transactions
+// (nil to) are charged the overridden TX_INIT_CODE_WORD per word of init code once
+// EIP-3860 is active, and not at all before it.
+func TestCalcCustomIntrinsicGasInitCode(t *testing.T) {
+	const initCodeWordGas = 10
+
+	schedule := &GasSchedule{Overrides: map[string]uint64{GasKeyTxInitCodeWord: initCodeWordGas}}
+
+	tests := []struct {
+		name               string
+		dataLen            int
+		isContractCreation bool
+		isEIP3860          bool
+		wantInitCodeWords  uint64
+	}{
+		{name: "creation post-3860, 32 words", dataLen: 1024, isContractCreation: true, isEIP3860: true, wantInitCodeWords: 32},
+		{name: "creation post-3860, 64 words", dataLen: 2048, isContractCreation: true, isEIP3860: true, wantInitCodeWords: 64},
+		{name: "creation post-3860, partial word rounds up", dataLen: 1025, isContractCreation: true, isEIP3860: true, wantInitCodeWords: 33},
+		{name: "creation pre-3860", dataLen: 2048, isContractCreation: true, isEIP3860: false},
+		{name: "call post-3860", dataLen: 2048, isContractCreation: false, isEIP3860: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte{0x01}, tc.dataLen)
+
+			gas, _ := CalcCustomIntrinsicGas(
+				schedule, data, 0, 0,
+				tc.isContractCreation, true, true, tc.isEIP3860, false, false, 0,
+			)
+
+			base := params.TxGas
+			if tc.isContractCreation {
+				base = params.TxGasContractCreation
+			}
+
+			want := base + uint64(tc.dataLen)*params.TxDataNonZeroGasEIP2028 + tc.wantInitCodeWords*initCodeWordGas
+			if gas != want {
+				t.Errorf("intrinsic gas = %d, want %d", gas, want)
+			}
+		})
+	}
+}
+
+// TestCalldataGasMatchesIntrinsic verifies that CalldataGas is the part of
+// CalcCustomIntrinsicGas that the calldata adds, with and without overrides.
+func TestCalldataGasMatchesIntrinsic(t *testing.T) {
+	// 3 zero bytes and 5 non-zero bytes
+	data := []byte{0x00, 0x01, 0x00, 0xff, 0x02, 0x00, 0x03, 0x04}
+
+	schedules := []*GasSchedule{
+		nil,
+		{Overrides: map[string]uint64{GasKeyTxDataZero: 1, GasKeyTxDataNonZero: 100}},
+	}
+
+	for _, schedule := range schedules {
+		for _, isEIP2028 := range []bool{false, true} {
+			withData, _ := CalcCustomIntrinsicGas(schedule, data, 0, 0, false, true, isEIP2028, true, false, false, 0)
+			without, _ := CalcCustomIntrinsicGas(schedule, nil, 0, 0, false, true, isEIP2028, true, false, false, 0)
+
+			if got := CalldataGas(schedule, data, isEIP2028); got != withData-without {
+				t.Errorf("schedule %v, EIP-2028 %v: CalldataGas = %d, want %d", schedule, isEIP2028, got, withData-without)
+			}
+		}
+	}
+
+	overflowing := &GasSchedule{Overrides: map[string]uint64{GasKeyTxDataNonZero: 1 << 62}}
+	if got := CalldataGas(overflowing, data, true); got != 0 {
+		t.Errorf("overflowing CalldataGas = %d, want 0", got)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import "reflect"
+
+// IsDefined reports whether an opcode is assigned in the jump table. Opcodes a
+// fork has not activated yet (e.g. CLZ before Osaka) hold an opUndefined
+// placeholder rather than being nil, so a nil check alone is not enough.
+func (jt *JumpTable) IsDefined(op OpCode) bool {
+	entry := jt[op]
+	if entry == nil || entry.execute == nil {
+		return false
+	}
+
+	return reflect.ValueOf(entry.execute).Pointer() != reflect.ValueOf(opUndefined).Pointer()
+}
+
+// HasDynamicGas reports whether an opcode has a dynamic gas function.
+func (jt *JumpTable) HasDynamicGas(op OpCode) bool {
+	return jt[op] != nil && jt[op].dynamicGas != nil
+}
+
+// HasMemorySize reports whether an opcode has a memory size function.
+func (jt *JumpTable) HasMemorySize(op OpCode) bool {
+	return jt[op] != nil && jt[op].memorySize != nil
+}
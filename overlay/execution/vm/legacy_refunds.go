@@ -0,0 +1,33 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import "github.com/erigontech/erigon/execution/protocol/params"
+
+// UseLegacyRefunds reverts the EIP-3529 refund changes made to a jump table: SSTORE
+// clearing a slot refunds the EIP-2200 amount again and SELFDESTRUCT refunds the
+// destructed account. The EIP-2929 cold/warm costs are kept. The refund cap divisor
+// is applied by ApplyMessage, outside the jump table, and is not changed.
+//
+// Used by gas simulation to quantify how much EIP-3529 reduced refunds on
+// post-London blocks. The table must be a copy (see GetBaseJumpTable).
+func UseLegacyRefunds(jt *JumpTable) {
+	jt[SSTORE].dynamicGas = makeGasSStoreFunc(params.SstoreClearsScheduleRefundEIP2200)
+	jt[SELFDESTRUCT].dynamicGas = makeSelfdestructGasFn(true)
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/state"
+)
+
+// TestUseLegacyRefunds runs the London SSTORE and SELFDESTRUCT gas functions on a
+// slot being cleared and an account being destructed, under the EIP-3529 refunds and
+// with UseLegacyRefunds, and checks the refund each leaves in the counter.
+func TestUseLegacyRefunds(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+
+	tests := []struct {
+		name             string
+		legacy           bool
+		wantSstore       uint64 // refund for clearing a slot
+		wantSelfdestruct uint64
+	}{
+		{name: "EIP-3529 refunds", legacy: false, wantSstore: params.SstoreClearsScheduleRefundEIP3529, wantSelfdestruct: 0},
+		{name: "legacy refunds", legacy: true, wantSstore: params.SstoreClearsScheduleRefundEIP2200, wantSelfdestruct: params.SelfdestructRefundGas},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			jt := GetBaseJumpTable(rules)
+			if tc.legacy {
+				UseLegacyRefunds(jt)
+			}
+
+			// Every slot holds 1, so storing 0 clears a clean slot
+			newEVM := func() *EVM {
+				return &EVM{intraBlockState: state.New(&driftStateReader{value: *uint256.NewInt(1)}), chainRules: rules}
+			}
+
+			evm := newEVM()
+			callContext := &CallContext{gas: math.MaxUint64}
+			callContext.Stack.Push(uint256.NewInt(0)) // value
+			callContext.Stack.Push(uint256.NewInt(1)) // slot
+
+			if _, err := jt[SSTORE].dynamicGas(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, 0); err != nil {
+				t.Fatalf("SSTORE: unexpected error: %v", err)
+			}
+
+			if got := evm.IntraBlockState().GetRefund(); got != tc.wantSstore {
+				t.Errorf("SSTORE refund = %d, want %d", got, tc.wantSstore)
+			}
+
+			evm = newEVM()
+			callContext = &CallContext{gas: math.MaxUint64}
+			callContext.Stack.Push(uint256.NewInt(0xbe)) // beneficiary
+
+			if _, err := jt[SELFDESTRUCT].dynamicGas(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, 0); err != nil {
+				t.Fatalf("SELFDESTRUCT: unexpected error: %v", err)
+			}
+
+			if got := evm.IntraBlockState().GetRefund(); got != tc.wantSelfdestruct {
+				t.Errorf("SELFDESTRUCT refund = %d, want %d", got, tc.wantSelfdestruct)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import "math/bits"
+
+// LinearGas is a generic dynamic gas model for opcodes whose cost grows linearly with
+// a size operand, such as KECCAK256 and LOGn. It charges
+//
+//	Base + PerWord*words + PerItem*size
+//
+// plus memory expansion, on top of the opcode's constant gas. size is the stack
+// operand SizeArg positions from the top (0 is the top) and words is size rounded up
+// to 32-byte words. Opcodes with fewer operands than SizeArg+1 charge size 0.
+type LinearGas struct {
+	Base    uint64
+	PerWord uint64
+	PerItem uint64
+	SizeArg int
+}
+
+// cost returns the linear part of the model for a size operand, reporting overflow.
+func (l LinearGas) cost(size uint64) (uint64, bool) {
+	words := size / 32
+	if size%32 != 0 {
+		words++
+	}
+
+	hi, perWord := bits.Mul64(words, l.PerWord)
+	if hi != 0 {
+		return 0, true
+	}
+
+	hi, perItem := bits.Mul64(size, l.PerItem)
+	if hi != 0 {
+		return 0, true
+	}
+
+	total, carry := bits.Add64(l.Base, perWord, 0)
+	total, carry2 := bits.Add64(total, perItem, 0)
+
+	return total, carry != 0 || carry2 != 0
+}
+
+// size reads the model's size operand from the stack.
+func (l LinearGas) size(callContext *CallContext) (uint64, bool) {
+	if l.SizeArg < 0 || callContext.Stack.Len() <= l.SizeArg {
+		return 0, false
+	}
+
+	return callContext.Stack.Back(l.SizeArg).Uint64WithOverflow()
+}
+
+// SetLinearGas replaces an opcode's dynamic gas function with a linear model, so a
+// new linear-gas model needs configuration rather than a new gas function. The
+// table must be a copy (see GetBaseJumpTable).
+func SetLinearGas(jt *JumpTable, op OpCode, model LinearGas) {
+	jt[op].dynamicGas = makeLinearGas(model)
+}
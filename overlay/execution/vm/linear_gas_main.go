@@ -0,0 +1,48 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import "github.com/erigontech/erigon/execution/protocol/mdgas"
+
+// makeLinearGas returns a gas function charging memory expansion plus the model's
+// linear cost (see LinearGas).
+func makeLinearGas(model LinearGas) gasFunc {
+	return func(evm *EVM, callContext *CallContext, availableGas mdgas.MdGas, memorySize uint64) (mdgas.MdGas, error) {
+		size, overflow := model.size(callContext)
+		if overflow {
+			return mdgas.MdGas{}, ErrGasUintOverflow
+		}
+
+		gas, err := memoryGasCost(evm, callContext, memorySize)
+		if err != nil {
+			return mdgas.MdGas{}, err
+		}
+
+		linear, overflow := model.cost(size)
+		if overflow {
+			return mdgas.MdGas{}, ErrGasUintOverflow
+		}
+
+		if gas+linear < gas {
+			return mdgas.MdGas{}, ErrGasUintOverflow
+		}
+
+		return mdgas.MdGas{Regular: gas + linear}, nil
+	}
+}
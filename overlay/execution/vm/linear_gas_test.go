@@ -0,0 +1,64 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// TestLinearGasFormulas verifies that linear models reproduce the dynamic (non-memory)
+// part of the KECCAK256 and LOGn gas formulas.
+func TestLinearGasFormulas(t *testing.T) {
+	keccak := LinearGas{PerWord: params.Keccak256WordGas, SizeArg: 1}
+
+	for _, size := range []uint64{0, 1, 31, 32, 33, 1000} {
+		words := (size + 31) / 32
+
+		got, overflow := keccak.cost(size)
+		if overflow {
+			t.Fatalf("KECCAK256 size %d overflowed", size)
+		}
+
+		if want := params.Keccak256WordGas * words; got != want {
+			t.Errorf("KECCAK256 size %d: linear gas = %d, want %d", size, got, want)
+		}
+	}
+
+	for topics := uint64(0); topics <= 4; topics++ {
+		log := LinearGas{Base: params.LogGas + topics*params.LogTopicGas, PerItem: params.LogDataGas, SizeArg: 1}
+
+		for _, size := range []uint64{0, 1, 32, 100} {
+			got, overflow := log.cost(size)
+			if overflow {
+				t.Fatalf("LOG%d size %d overflowed", topics, size)
+			}
+
+			if want := params.LogGas + topics*params.LogTopicGas + size*params.LogDataGas; got != want {
+				t.Errorf("LOG%d size %d: linear gas = %d, want %d", topics, size, got, want)
+			}
+		}
+	}
+
+	if _, overflow := (LinearGas{PerItem: 2}).cost(math.MaxUint64); !overflow {
+		t.Error("expected overflow for a huge size")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import "github.com/erigontech/erigon/execution/protocol/params"
+
+// MemoryKey returns the schedule key of an opcode's memory expansion coefficient,
+// MEMORY_<OPCODE>.
+func MemoryKey(op OpCode) string {
+	return GasKeyMemoryPrefix + op.String()
+}
+
+// UseMemoryKeys makes each memory-expanding opcode charge memory expansion with its
+// MEMORY_<OPCODE> coefficient when that key is set, instead of the MEMORY coefficient
+// shared by all of them. The table must be a copy (see GetBaseJumpTable).
+//
+// Every memory-expanding opcode is wrapped, including those without a key of their
+// own: an expansion is charged coef*(newWords-oldWords) + quad(newWords) -
+// quad(oldWords) at the coefficient of the opcode that expands, whatever
+// coefficients priced the memory it grows from.
+func UseMemoryKeys(jt *JumpTable) {
+	for i := range jt {
+		op := OpCode(i)
+		if jt.IsDefined(op) && jt[op].dynamicGas != nil && jt[op].memorySize != nil {
+			jt[op].dynamicGas = withMemoryKey(jt[op].dynamicGas, MemoryKey(op))
+		}
+	}
+}
+
+// rebaseMemoryCost sets the cost recorded for the current memory to its cost at the
+// schedule's MEMORY coefficient. memoryGasCost charges an expansion as the new total
+// less the recorded cost, so this keeps the charge independent of the coefficients
+// of earlier expansions.
+func rebaseMemoryCost(schedule *GasSchedule, callContext *CallContext) {
+	words := uint64(callContext.Memory.Len()) / 32
+	callContext.Memory.lastGasCost = words*schedule.GetOr(GasKeyMemory, params.MemoryGas) + words*words/params.QuadCoeffDiv
+}
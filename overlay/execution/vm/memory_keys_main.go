@@ -0,0 +1,42 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import "github.com/erigontech/erigon/execution/protocol/mdgas"
+
+// withMemoryKey wraps a memory-expanding gas function so that it reads MEMORY from
+// the schedule derived for key, charging expansion from the current memory size (see
+// rebaseMemoryCost). The EVM's schedule is restored before returning.
+func withMemoryKey(fn gasFunc, key string) gasFunc {
+	return func(evm *EVM, callContext *CallContext, availableGas mdgas.MdGas, memorySize uint64) (mdgas.MdGas, error) {
+		derived := evm.GasSchedule.memorySchedule(key)
+		if derived == nil {
+			rebaseMemoryCost(evm.GasSchedule, callContext)
+			return fn(evm, callContext, availableGas, memorySize)
+		}
+
+		rebaseMemoryCost(derived, callContext)
+
+		schedule := evm.GasSchedule
+		evm.GasSchedule = derived
+		defer func() { evm.GasSchedule = schedule }()
+
+		return fn(evm, callContext, availableGas, memorySize)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// TestUseMemoryKeys verifies that MEMORY_MCOPY changes the linear part of MCOPY's
+// memory expansion while MSTORE keeps charging the global MEMORY coefficient.
+func TestUseMemoryKeys(t *testing.T) {
+	const (
+		memorySize = 1024 * 32 // 1024 words expanded from fresh memory
+		words      = memorySize / 32
+		quadratic  = words * words / params.QuadCoeffDiv
+	)
+
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true,
+		IsShanghai: true, IsCancun: true}
+
+	jt := GetBaseJumpTable(rules)
+	UseMemoryKeys(jt)
+
+	tests := []struct {
+		name      string
+		overrides map[string]uint64
+		want      map[OpCode]uint64
+	}{
+		{
+			name:      "MEMORY_MCOPY only",
+			overrides: map[string]uint64{MemoryKey(MCOPY): 10},
+			want:      map[OpCode]uint64{MCOPY: words*10 + quadratic, MSTORE: words*params.MemoryGas + quadratic},
+		},
+		{
+			name:      "MEMORY_MCOPY with MEMORY",
+			overrides: map[string]uint64{GasKeyMemory: 5, MemoryKey(MCOPY): 10},
+			want:      map[OpCode]uint64{MCOPY: words*10 + quadratic, MSTORE: words*5 + quadratic},
+		},
+		{
+			name:      "unset keys follow MEMORY",
+			overrides: map[string]uint64{GasKeyMemory: 5},
+			want:      map[OpCode]uint64{MCOPY: words*5 + quadratic, MSTORE: words*5 + quadratic},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule := &GasSchedule{Overrides: tc.overrides}
+			evm := &EVM{GasSchedule: schedule}
+
+			for op, want := range tc.want {
+				// A zero-length copy, so MCOPY charges memory expansion only
+				callContext := &CallContext{}
+				callContext.Stack.Push(uint256.NewInt(0)) // size
+				callContext.Stack.Push(uint256.NewInt(0)) // offset
+				callContext.Stack.Push(uint256.NewInt(0)) // destination
+
+				gas, err := jt[op].dynamicGas(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, memorySize)
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", op, err)
+				}
+
+				if gas.Regular != want {
+					t.Errorf("%s gas = %d, want %d", op, gas.Regular, want)
+				}
+
+				if evm.GasSchedule != schedule {
+					t.Fatalf("%s: EVM schedule not restored", op)
+				}
+			}
+		})
+	}
+}
+
+// TestMemoryKeysSharedMemory verifies that opcodes with different coefficients each
+// pay only for the words they add to the same memory: MCOPY then MSTORE, and MSTORE
+// then MCOPY.
+func TestMemoryKeysSharedMemory(t *testing.T) {
+	const coef = 10 // MEMORY_MCOPY
+
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true,
+		IsShanghai: true, IsCancun: true}
+
+	jt := GetBaseJumpTable(rules)
+	UseMemoryKeys(jt)
+
+	quad := func(words uint64) uint64 { return words * words / params.QuadCoeffDiv }
+
+	type step struct {
+		op    OpCode
+		words uint64 // memory size after the step
+		want  uint64
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "MCOPY then MSTORE",
+			steps: []step{
+				{op: MCOPY, words: 32, want: 32*coef + quad(32)},
+				{op: MSTORE, words: 64, want: 32*params.MemoryGas + quad(64) - quad(32)},
+			},
+		},
+		{
+			name: "MSTORE then MCOPY",
+			steps: []step{
+				{op: MSTORE, words: 32, want: 32*params.MemoryGas + quad(32)},
+				{op: MCOPY, words: 64, want: 32*coef + quad(64) - quad(32)},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			evm := &EVM{GasSchedule: &GasSchedule{Overrides: map[string]uint64{MemoryKey(MCOPY): coef}}}
+			callContext := &CallContext{}
+
+			for _, s := range tc.steps {
+				// A zero-length copy, so MCOPY charges memory expansion only
+				callContext.Stack.Push(uint256.NewInt(0)) // size
+				callContext.Stack.Push(uint256.NewInt(0)) // offset
+				callContext.Stack.Push(uint256.NewInt(0)) // destination
+
+				memorySize := s.words * 32
+
+				gas, err := jt[s.op].dynamicGas(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, memorySize)
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", s.op, err)
+				}
+
+				if gas.Regular != s.want {
+					t.Errorf("%s gas = %d, want %d", s.op, gas.Regular, s.want)
+				}
+
+				callContext.Memory.Resize(memorySize)
+			}
+		})
+	}
+}
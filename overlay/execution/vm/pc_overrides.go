@@ -0,0 +1,143 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import "maps"
+
+// pcOverrides holds gas overrides scoped to program counters and tracks the PC of
+// the opcode about to run, which gas functions are not otherwise given.
+type pcOverrides struct {
+	overrides map[uint64]map[string]uint64
+
+	// pc is the PC of the next opcode of the running frame. The interpreter advances
+	// the PC after executing an opcode (PUSHn and JUMPs adjust it during execution),
+	// so it is read back after each execute; frames start at 0.
+	pc uint64
+
+	// Schedules derived from base with a PC's overrides layered on top
+	base    *GasSchedule
+	derived map[uint64]*GasSchedule
+}
+
+// pcFrameOpcodes start a new frame whose code runs from PC 0.
+var pcFrameOpcodes = map[OpCode]bool{
+	CALL: true, CALLCODE: true, DELEGATECALL: true, STATICCALL: true, CREATE: true, CREATE2: true,
+}
+
+// UsePCOverrides scopes gas overrides to program counters: overrides[pc] applies
+// only to the opcode executed at that PC, in any frame. A key naming the opcode
+// replaces its constant gas; any other key (e.g. SSTORE_SET) is layered on top of the
+// EVM's GasSchedule while that opcode's dynamic gas is computed.
+//
+// This is opt-in and not free: every opcode pays an extra function call to track the
+// PC, and the opcodes that may be overridden a map lookup per execution. Their
+// constant gas is charged by the wrapped dynamic gas function instead, deducted from
+// the available gas first, so CALL-family opcodes forward the same gas to the child.
+// Apply it last, so the wrapped functions are the final ones. The table must be a
+// copy (see GetBaseJumpTable).
+func UsePCOverrides(jt *JumpTable, overrides map[uint64]map[string]uint64) {
+	usePCOverrides(jt, overrides)
+}
+
+// usePCOverrides installs the PC overrides and returns the tracker.
+func usePCOverrides(jt *JumpTable, overrides map[uint64]map[string]uint64) *pcOverrides {
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	p := &pcOverrides{overrides: overrides, derived: make(map[uint64]*GasSchedule, len(overrides))}
+
+	opcodes := make(map[string]bool, len(jt))
+	for i := range jt {
+		opcodes[OpCode(i).String()] = true
+	}
+
+	// Opcodes named by a key need their constant gas wrapped; any other key is a
+	// dynamic gas key, which may be read by any dynamic gas function
+	named := make(map[string]bool)
+	dynamic := false
+
+	for _, keys := range overrides {
+		for key := range keys {
+			named[key] = true
+			dynamic = dynamic || !opcodes[key]
+		}
+	}
+
+	for i := range jt {
+		op := OpCode(i)
+		if !jt.IsDefined(op) {
+			continue
+		}
+
+		jt[op].execute = trackPC(jt[op].execute, p, pcFrameOpcodes[op])
+
+		if named[op.String()] || (dynamic && jt[op].dynamicGas != nil) {
+			constantGas := jt[op].constantGas
+			jt[op].constantGas = 0
+			jt[op].dynamicGas = withPCOverrides(jt[op].dynamicGas, constantGas, op.String(), p)
+		}
+	}
+
+	return p
+}
+
+// trackPC wraps an execution function to record the PC of the frame's next opcode.
+func trackPC(fn executionFunc, p *pcOverrides, entersFrame bool) executionFunc {
+	return func(pc *uint64, interpreter *EVMInterpreter, callContext *CallContext) ([]byte, error) {
+		if entersFrame {
+			p.pc = 0
+		}
+
+		ret, err := fn(pc, interpreter, callContext)
+		p.pc = *pc + 1
+
+		return ret, err
+	}
+}
+
+// current returns the overrides for the PC of the opcode about to run, or nil.
+func (p *pcOverrides) current() map[string]uint64 {
+	return p.overrides[p.pc]
+}
+
+// schedule returns base with the current PC's overrides layered on top. Derived
+// schedules are cached per PC until base changes.
+func (p *pcOverrides) schedule(base *GasSchedule) *GasSchedule {
+	if base != p.base {
+		clear(p.derived)
+		p.base = base
+	}
+
+	if derived, ok := p.derived[p.pc]; ok {
+		return derived
+	}
+
+	overrides := make(map[string]uint64)
+	if base != nil {
+		maps.Copy(overrides, base.Overrides)
+	}
+
+	maps.Copy(overrides, p.current())
+
+	derived := &GasSchedule{Overrides: overrides}
+	p.derived[p.pc] = derived
+
+	return derived
+}
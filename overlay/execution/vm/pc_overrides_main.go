@@ -0,0 +1,69 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"github.com/erigontech/erigon/common/math"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+)
+
+// withPCOverrides wraps an opcode's gas function (fn may be nil) so that it charges
+// the constant gas, replaced by the current PC's override for name if set, and runs
+// fn against the EVM's schedule with the PC's overrides layered on top. The constant
+// is taken from the available gas before fn runs, as the interpreter would. The EVM's
+// schedule is restored before returning.
+func withPCOverrides(fn gasFunc, constantGas uint64, name string, p *pcOverrides) gasFunc {
+	return func(evm *EVM, callContext *CallContext, availableGas mdgas.MdGas, memorySize uint64) (mdgas.MdGas, error) {
+		at := p.current()
+
+		constant := constantGas
+		if value, ok := at[name]; ok {
+			constant = value
+		}
+
+		if fn == nil {
+			return mdgas.MdGas{Regular: constant}, nil
+		}
+
+		// The interpreter deducts constant gas before running the dynamic gas function,
+		// so CALL variants forward 63/64 of the gas left after it
+		if availableGas.Regular < constant {
+			return mdgas.MdGas{}, ErrOutOfGas
+		}
+		availableGas.Regular -= constant
+
+		if at != nil {
+			schedule := evm.GasSchedule
+			evm.GasSchedule = p.schedule(schedule)
+			defer func() { evm.GasSchedule = schedule }()
+		}
+
+		gas, err := fn(evm, callContext, availableGas, memorySize)
+		if err != nil {
+			return mdgas.MdGas{}, err
+		}
+
+		var overflow bool
+		if gas.Regular, overflow = math.SafeAdd(gas.Regular, constant); overflow {
+			return mdgas.MdGas{}, ErrGasUintOverflow
+		}
+
+		return gas, nil
+	}
+}
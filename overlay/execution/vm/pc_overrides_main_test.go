@@ -0,0 +1,182 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/state"
+)
+
+// TestUsePCOverridesSstore overrides SSTORE_SET for the SSTORE at PC 0x42 only, and
+// checks that an SSTORE at another PC and the EVM's schedule are unaffected.
+func TestUsePCOverridesSstore(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true,
+		IsShanghai: true, IsCancun: true}
+
+	jt := GetBaseJumpTable(rules)
+	p := usePCOverrides(jt, map[uint64]map[string]uint64{0x42: {GasKeySstoreSet: 40000}})
+
+	for pc, want := range map[uint64]uint64{
+		0x42: 2100 + 40000, // cold slot, overridden SSTORE_SET
+		0x43: 2100 + 20000, // cold slot, standard SSTORE_SET
+	} {
+		evm := &EVM{intraBlockState: state.New(&driftStateReader{}), chainRules: rules}
+
+		callContext := &CallContext{gas: math.MaxUint64}
+		callContext.Stack.Push(uint256.NewInt(7)) // value
+		callContext.Stack.Push(uint256.NewInt(1)) // slot
+
+		p.pc = pc
+
+		gas, err := jt[SSTORE].dynamicGas(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, 0)
+		if err != nil {
+			t.Fatalf("pc %#x: unexpected error: %v", pc, err)
+		}
+
+		if gas.Regular != want {
+			t.Errorf("pc %#x: SSTORE gas = %d, want %d", pc, gas.Regular, want)
+		}
+
+		if evm.GasSchedule != nil {
+			t.Errorf("pc %#x: EVM schedule not restored: %v", pc, evm.GasSchedule)
+		}
+	}
+}
+
+// TestUsePCOverridesConstantGas verifies that an opcode name key replaces the
+// constant gas at its PC only, with the constant charged by the wrapped gas function.
+func TestUsePCOverridesConstantGas(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+
+	jt := GetBaseJumpTable(rules)
+	p := usePCOverrides(jt, map[uint64]map[string]uint64{0x10: {"ADD": 10}})
+
+	if jt[ADD].constantGas != 0 {
+		t.Fatalf("ADD constant gas = %d, want 0 (moved into the dynamic gas)", jt[ADD].constantGas)
+	}
+
+	if jt[MUL].constantGas != 5 || jt[MUL].dynamicGas != nil {
+		t.Error("MUL was wrapped without an override naming it")
+	}
+
+	for pc, want := range map[uint64]uint64{0x10: 10, 0x11: 3} {
+		p.pc = pc
+
+		gas, err := jt[ADD].dynamicGas(&EVM{}, &CallContext{}, mdgas.MdGas{}, 0)
+		if err != nil {
+			t.Fatalf("pc %#x: unexpected error: %v", pc, err)
+		}
+
+		if gas.Regular != want {
+			t.Errorf("pc %#x: ADD gas = %d, want %d", pc, gas.Regular, want)
+		}
+	}
+}
+
+// TestTrackPC verifies the tracked PC across PUSHn immediates, jumps and a call into
+// a child frame.
+func TestTrackPC(t *testing.T) {
+	p := &pcOverrides{}
+
+	// Stand-ins for execution functions, which adjust the PC the way the interpreter's
+	// instructions do before it advances past the opcode
+	push2 := trackPC(func(pc *uint64, _ *EVMInterpreter, _ *CallContext) ([]byte, error) {
+		*pc += 2
+		return nil, nil
+	}, p, false)
+	jump := func(dest uint64) executionFunc {
+		return trackPC(func(pc *uint64, _ *EVMInterpreter, _ *CallContext) ([]byte, error) {
+			*pc = dest - 1
+			return nil, nil
+		}, p, false)
+	}
+
+	var seenInChild uint64
+	call := trackPC(func(pc *uint64, _ *EVMInterpreter, _ *CallContext) ([]byte, error) {
+		seenInChild = p.pc
+		p.pc = 7 // the child frame ran until PC 7
+		return nil, nil
+	}, p, true)
+
+	pc := uint64(0)
+	steps := []struct {
+		fn   executionFunc
+		want uint64 // tracked PC after the step
+	}{
+		{push2, 3},   // PUSH2 at 0, next opcode at 3
+		{jump(9), 9}, // JUMP at 3 to 9
+		{call, 10},   // CALL at 9 returns to 10
+		{push2, 13},  // PUSH2 at 10
+		{jump(4), 4}, // JUMPI at 13 taken to 4
+	}
+
+	for i, step := range steps {
+		if _, err := step.fn(&pc, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if p.pc != step.want {
+			t.Fatalf("step %d: tracked pc = %d, want %d", i, p.pc, step.want)
+		}
+
+		pc = p.pc
+	}
+
+	if seenInChild != 0 {
+		t.Errorf("child frame started at pc %d, want 0", seenInChild)
+	}
+}
+
+// TestPCOverridesDeductConstantGas verifies that the wrapped gas function sees the
+// available gas less the constant gas, as it would without PC overrides, so CALL
+// variants forward the same gas to the child frame.
+func TestPCOverridesDeductConstantGas(t *testing.T) {
+	p := &pcOverrides{overrides: map[uint64]map[string]uint64{0x10: {"CALL": 500}}}
+
+	var seen uint64
+	fn := withPCOverrides(func(_ *EVM, _ *CallContext, availableGas mdgas.MdGas, _ uint64) (mdgas.MdGas, error) {
+		seen = availableGas.Regular
+		return mdgas.MdGas{}, nil
+	}, 100, "CALL", p)
+
+	for pc, want := range map[uint64]uint64{0x10: 10000 - 500, 0x11: 10000 - 100} {
+		p.pc = pc
+
+		if _, err := fn(&EVM{}, &CallContext{}, mdgas.MdGas{Regular: 10000}, 0); err != nil {
+			t.Fatalf("pc %#x: unexpected error: %v", pc, err)
+		}
+
+		if seen != want {
+			t.Errorf("pc %#x: available gas = %d, want %d", pc, seen, want)
+		}
+	}
+
+	p.pc = 0x10
+	if _, err := fn(&EVM{}, &CallContext{}, mdgas.MdGas{Regular: 499}, 0); err != ErrOutOfGas {
+		t.Errorf("err = %v, want %v", err, ErrOutOfGas)
+	}
+}
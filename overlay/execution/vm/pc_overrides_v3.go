@@ -0,0 +1,66 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && !erigon_main
+
+package vm
+
+import "github.com/erigontech/erigon/common/math"
+
+// withPCOverrides wraps an opcode's gas function (fn may be nil) so that it charges
+// the constant gas, replaced by the current PC's override for name if set, and runs
+// fn against the EVM's schedule with the PC's overrides layered on top. The constant
+// is taken from the available gas before fn runs, as the interpreter would. The EVM's
+// schedule is restored before returning.
+func withPCOverrides(fn gasFunc, constantGas uint64, name string, p *pcOverrides) gasFunc {
+	return func(evm *EVM, callContext *CallContext, scopeGas uint64, memorySize uint64) (uint64, error) {
+		at := p.current()
+
+		constant := constantGas
+		if value, ok := at[name]; ok {
+			constant = value
+		}
+
+		if fn == nil {
+			return constant, nil
+		}
+
+		// The interpreter deducts constant gas before running the dynamic gas function,
+		// so CALL variants forward 63/64 of the gas left after it
+		if scopeGas < constant {
+			return 0, ErrOutOfGas
+		}
+		scopeGas -= constant
+
+		if at != nil {
+			schedule := evm.GasSchedule
+			evm.GasSchedule = p.schedule(schedule)
+			defer func() { evm.GasSchedule = schedule }()
+		}
+
+		gas, err := fn(evm, callContext, scopeGas, memorySize)
+		if err != nil {
+			return 0, err
+		}
+
+		var overflow bool
+		if gas, overflow = math.SafeAdd(gas, constant); overflow {
+			return 0, ErrGasUintOverflow
+		}
+
+		return gas, nil
+	}
+}
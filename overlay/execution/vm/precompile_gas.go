@@ -18,6 +18,8 @@ package vm
 
 import (
 	"encoding/binary"
+	"math"
+	"math/big"
 
 	"github.com/erigontech/erigon/execution/protocol/params"
 )
@@ -45,7 +47,11 @@ const (
 	GasKeyPCIdBase    = "PC_ID_BASE"
 	GasKeyPCIdPerWord = "PC_ID_PER_WORD"
 
-	GasKeyPCModexpMinGas = "PC_MODEXP_MIN_GAS"
+	GasKeyPCModexpMinGas            = "PC_MODEXP_MIN_GAS"
+	GasKeyPCModexpMinComplexity     = "PC_MODEXP_MIN_COMPLEXITY"
+	GasKeyPCModexpComplexityMul     = "PC_MODEXP_COMPLEXITY_MUL"
+	GasKeyPCModexpExpByteIterations = "PC_MODEXP_EXP_BYTE_ITERATIONS"
+	GasKeyPCModexpDivisor           = "PC_MODEXP_DIVISOR"
 
 	GasKeyPCBn254PairingBase    = "PC_BN254_PAIRING_BASE"
 	GasKeyPCBn254PairingPerPair = "PC_BN254_PAIRING_PER_PAIR"
@@ -60,6 +66,17 @@ const (
 	GasKeyPCBls12G2MsmMulGas = "PC_BLS12_G2MSM_MUL_GAS"
 )
 
+// PrecompileGasKeys lists every precompile gas override key.
+var PrecompileGasKeys = []string{
+	GasKeyPCEcrec, GasKeyPCBn254Add, GasKeyPCBn254Mul, GasKeyPCBls12G1Add, GasKeyPCBls12G2Add,
+	GasKeyPCBls12MapFpToG1, GasKeyPCBls12MapFp2ToG2, GasKeyPCKzgPointEvaluation, GasKeyPCP256Verify,
+	GasKeyPCSha256Base, GasKeyPCSha256PerWord, GasKeyPCRipemd160Base, GasKeyPCRipemd160PerWord,
+	GasKeyPCIdBase, GasKeyPCIdPerWord, GasKeyPCModexpMinGas, GasKeyPCModexpMinComplexity,
+	GasKeyPCModexpComplexityMul, GasKeyPCModexpExpByteIterations, GasKeyPCModexpDivisor,
+	GasKeyPCBn254PairingBase, GasKeyPCBn254PairingPerPair, GasKeyPCBlake2fBase, GasKeyPCBlake2fPerRound,
+	GasKeyPCBls12PairingBase, GasKeyPCBls12PairingPerPair, GasKeyPCBls12G1MsmMulGas, GasKeyPCBls12G2MsmMulGas,
+}
+
 // PrecompileGasWithOverrides calculates precompile gas cost with optional overrides.
 // Fixed-gas precompiles: single key (PC_<name>) overrides the flat cost.
 // Variable-gas precompiles: parameter keys (PC_<name>_BASE, etc.) override formula inputs.
@@ -82,7 +99,7 @@ func PrecompileGasWithOverrides(schedule *GasSchedule, name string, input []byte
 	case "ID":
 		return precompileBasePerWord(schedule, GasKeyPCIdBase, GasKeyPCIdPerWord, input, params.IdentityBaseGas, params.IdentityPerWordGas)
 	case "MODEXP":
-		return precompileModexp(schedule, defaultGas)
+		return precompileModexp(schedule, input, defaultGas)
 	case "BN254_PAIRING":
 		return precompileBasePerPair(schedule, GasKeyPCBn254PairingBase, GasKeyPCBn254PairingPerPair, input, 192, params.Bn254PairingBaseGasIstanbul, params.Bn254PairingPerPointGasIstanbul)
 	case "BLAKE2F":
@@ -107,16 +124,29 @@ func precompileBasePerWord(schedule *GasSchedule, baseKey, perWordKey string, in
 	return base + perWord*words
 }
 
-// precompileBasePerPair computes base + perPair * (len(input) / pairSize).
+// precompileInputUnits returns the number of whole unitSize-byte units (pairs or
+// points) in input. Stock RequiredGas truncates inputs that are not a multiple of
+// unitSize and Run then rejects them, consuming all gas, so pricing truncates too
+// and a malformed input costs the same as under stock Erigon.
+func precompileInputUnits(input []byte, unitSize int) uint64 {
+	if unitSize <= 0 {
+		return 0
+	}
+	return uint64(len(input) / unitSize)
+}
+
+// precompileBasePerPair computes base + perPair * (len(input) / pairSize), with
+// trailing bytes of a partial pair ignored (see precompileInputUnits).
 // Used by BN254_PAIRING (pairSize=192), BLS12_PAIRING_CHECK (pairSize=384).
 func precompileBasePerPair(schedule *GasSchedule, baseKey, perPairKey string, input []byte, pairSize int, defaultBase, defaultPerPair uint64) uint64 {
 	base := schedule.GetOr(baseKey, defaultBase)
 	perPair := schedule.GetOr(perPairKey, defaultPerPair)
-	pairs := uint64(len(input) / pairSize)
+	pairs := precompileInputUnits(input, pairSize)
 	return base + perPair*pairs
 }
 
 // precompileBlake2f computes base + perRound * rounds, where rounds is read from input[0:4].
+// Inputs that are not exactly 213 bytes cost 0, as under stock RequiredGas; Run rejects them.
 func precompileBlake2f(schedule *GasSchedule, input []byte) uint64 {
 	if len(input) != 213 {
 		return 0
@@ -127,10 +157,11 @@ func precompileBlake2f(schedule *GasSchedule, input []byte) uint64 {
 	return base + perRound*rounds
 }
 
-// precompileMsm computes k * mulGas * discount[k] / 1000.
+// precompileMsm computes k * mulGas * discount[k] / 1000, where k counts whole
+// points (see precompileInputUnits).
 // The discount table is not overridable — only the per-point mulGas is.
 func precompileMsm(schedule *GasSchedule, mulGasKey string, input []byte, pointSize int, defaultMulGas uint64) uint64 {
-	k := len(input) / pointSize
+	k := int(precompileInputUnits(input, pointSize))
 	if k == 0 {
 		return 0
 	}
@@ -155,15 +186,103 @@ func precompileMsm(schedule *GasSchedule, mulGasKey string, input []byte, pointS
 	return (uint64(k) * mulGas * discount) / 1000
 }
 
-// precompileModexp applies the MODEXP min gas override.
-// The complex EIP-2565/7883 formula itself is not overridable — only the floor value is.
-// The fallback of 200 (EIP-2565 minimum) is a conservative safety net — it can never
-// produce a wrong result because post-Osaka defaultGas from RequiredGas() is always >= 500.
-// The fork-correct value (200 or 500) is set in GasScheduleForRules().
-func precompileModexp(schedule *GasSchedule, defaultGas uint64) uint64 {
+// modexpFormulaKeys are the parameters of the EIP-2565 / EIP-7883 MODEXP formula.
+var modexpFormulaKeys = []string{
+	GasKeyPCModexpMinComplexity, GasKeyPCModexpComplexityMul, GasKeyPCModexpExpByteIterations, GasKeyPCModexpDivisor,
+}
+
+// precompileModexp prices MODEXP with the min gas override applied as a floor. With
+// no formula parameter overridden, the stock defaultGas is used; it comes from
+// RequiredGas, which already handles short or malformed length headers (missing
+// bytes read as zero). The fallback of 200 (EIP-2565 minimum) is a conservative
+// safety net — it can never produce a wrong result because post-Osaka defaultGas
+// from RequiredGas() is always >= 500. The fork-correct value (200 or 500) is set in
+// GasScheduleForRules().
+func precompileModexp(schedule *GasSchedule, input []byte, defaultGas uint64) uint64 {
+	gas := defaultGas
+	for _, key := range modexpFormulaKeys {
+		if _, ok := schedule.Overrides[key]; ok {
+			gas = modexpFormulaGas(schedule, input)
+			break
+		}
+	}
+
 	minGas := schedule.GetOr(GasKeyPCModexpMinGas, 200)
-	if defaultGas < minGas {
+	if gas < minGas {
 		return minGas
 	}
-	return defaultGas
+	return gas
+}
+
+// modexpFormulaGas computes the EIP-2565 formula generalized by the EIP-7883
+// changes, before the min gas floor:
+//
+//	max(complexity, MIN_COMPLEXITY) * max(iterations, 1) / DIVISOR
+//
+// where complexity is ceil(max(baseLen, modLen) / 8)^2, multiplied by
+// COMPLEXITY_MUL when the longer operand exceeds 32 bytes, and an exponent longer
+// than 32 bytes adds EXP_BYTE_ITERATIONS iterations per extra byte. Unset
+// parameters take their EIP-2565 values (0, 1, 8 and 3); EIP-7883 uses 16, 2, 16
+// and 1. A DIVISOR of 0 is treated as 1. Costs beyond uint64 saturate.
+func modexpFormulaGas(schedule *GasSchedule, input []byte) uint64 {
+	var (
+		baseLen = new(big.Int).SetBytes(getData(input, 0, 32))
+		expLen  = new(big.Int).SetBytes(getData(input, 32, 32))
+		modLen  = new(big.Int).SetBytes(getData(input, 64, 32))
+		big32   = big.NewInt(32)
+	)
+
+	if len(input) > 96 {
+		input = input[96:]
+	} else {
+		input = input[:0]
+	}
+
+	// The first 32 bytes of the exponent, which follows the base
+	expHead := new(big.Int)
+	if big.NewInt(int64(len(input))).Cmp(baseLen) > 0 {
+		headLen := uint64(32)
+		if expLen.Cmp(big32) < 0 {
+			headLen = expLen.Uint64()
+		}
+		expHead.SetBytes(getData(input, baseLen.Uint64(), headLen))
+	}
+
+	maxLen := baseLen
+	if modLen.Cmp(baseLen) > 0 {
+		maxLen = modLen
+	}
+
+	words := new(big.Int).Add(maxLen, big.NewInt(7))
+	words.Rsh(words, 3)
+
+	complexity := new(big.Int).Mul(words, words)
+	if maxLen.Cmp(big32) > 0 {
+		complexity.Mul(complexity, new(big.Int).SetUint64(schedule.GetOr(GasKeyPCModexpComplexityMul, 1)))
+	}
+	if minComplexity := new(big.Int).SetUint64(schedule.GetOr(GasKeyPCModexpMinComplexity, 0)); complexity.Cmp(minComplexity) < 0 {
+		complexity = minComplexity
+	}
+
+	iterations := new(big.Int)
+	if expLen.Cmp(big32) > 0 {
+		iterations.Sub(expLen, big32)
+		iterations.Mul(iterations, new(big.Int).SetUint64(schedule.GetOr(GasKeyPCModexpExpByteIterations, 8)))
+	}
+	if bitLen := expHead.BitLen(); bitLen > 1 {
+		iterations.Add(iterations, big.NewInt(int64(bitLen-1)))
+	}
+	if iterations.Sign() == 0 {
+		iterations.SetUint64(1)
+	}
+
+	gas := complexity.Mul(complexity, iterations)
+	if divisor := schedule.GetOr(GasKeyPCModexpDivisor, 3); divisor > 1 {
+		gas.Div(gas, new(big.Int).SetUint64(divisor))
+	}
+
+	if !gas.IsUint64() {
+		return math.MaxUint64
+	}
+	return gas.Uint64()
 }
@@ -0,0 +1,161 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+// TestPrecompileGasMalformedInputs verifies that the override path prices inputs
+// that are not a whole number of pairs or points (or not 213 bytes for BLAKE2F)
+// exactly as stock RequiredGas does, and that overrides apply to the same
+// truncated pair count.
+func TestPrecompileGasMalformedInputs(t *testing.T) {
+	stock := []struct {
+		name     string
+		contract PrecompiledContract
+		lengths  []int
+	}{
+		{name: "BN254_PAIRING", contract: &bn256PairingIstanbul{}, lengths: []int{0, 1, 191, 192, 193, 383, 500}},
+		{name: "BLS12_PAIRING_CHECK", contract: &bls12381Pairing{}, lengths: []int{0, 1, 383, 384, 385, 1000}},
+		{name: "BLS12_G1MSM", contract: &bls12381G1MultiExp{}, lengths: []int{0, 159, 160, 161, 330}},
+		{name: "BLS12_G2MSM", contract: &bls12381G2MultiExp{}, lengths: []int{0, 287, 288, 289, 600}},
+		{name: "BLAKE2F", contract: &blake2F{}, lengths: []int{0, 212, 213, 214}},
+	}
+
+	// An empty schedule takes the override path with every parameter at its default
+	schedule := &GasSchedule{Overrides: map[string]uint64{}}
+
+	for _, tc := range stock {
+		for _, n := range tc.lengths {
+			input := make([]byte, n)
+			if n > 0 {
+				input[n-1] = 1 // BLAKE2F reads its rounds from the first bytes; keep them small
+			}
+
+			want := tc.contract.RequiredGas(input)
+			if got := PrecompileGasWithOverrides(schedule, tc.name, input, want); got != want {
+				t.Errorf("%s with %d bytes: got %d, want stock %d", tc.name, n, got, want)
+			}
+		}
+	}
+
+	// Overrides price the truncated pair count: 193 bytes is one BN254 pair
+	overrides := &GasSchedule{Overrides: map[string]uint64{
+		GasKeyPCBn254PairingBase:    100,
+		GasKeyPCBn254PairingPerPair: 10,
+	}}
+
+	if got := PrecompileGasWithOverrides(overrides, "BN254_PAIRING", make([]byte, 193), 0); got != 110 {
+		t.Errorf("BN254_PAIRING override with 193 bytes: got %d, want 110", got)
+	}
+
+	if got := precompileInputUnits(make([]byte, 10), 0); got != 0 {
+		t.Errorf("zero unit size: got %d units, want 0", got)
+	}
+}
+
+// modexpTestInput encodes a MODEXP call: the three 32-byte lengths followed by the
+// base, exponent and modulus.
+func modexpTestInput(base, exp, mod []byte) []byte {
+	input := make([]byte, 0, 96+len(base)+len(exp)+len(mod))
+	for _, n := range []int{len(base), len(exp), len(mod)} {
+		input = append(input, new(big.Int).SetInt64(int64(n)).FillBytes(make([]byte, 32))...)
+	}
+
+	input = append(input, base...)
+	input = append(input, exp...)
+
+	return append(input, mod...)
+}
+
+// TestPrecompileModexpFormula checks the MODEXP formula parameters against the
+// EIP-2565 and EIP-7883 gas of their test vectors.
+func TestPrecompileModexpFormula(t *testing.T) {
+	secp256k1P := bytes.Repeat([]byte{0xff}, 32)
+	secp256k1P[27], secp256k1P[30], secp256k1P[31] = 0xfe, 0xfc, 0x2f
+
+	vectors := []struct {
+		name    string
+		input   []byte
+		eip2565 uint64
+		eip7883 uint64
+	}{
+		{
+			// EIP-198 example 1: 3^(p-2) mod p for the secp256k1 field prime
+			name:    "eip_example1",
+			input:   modexpTestInput([]byte{3}, append(bytes.Clone(secp256k1P[:31]), 0x2d), secp256k1P),
+			eip2565: 1360,
+			eip7883: 4080,
+		},
+		{
+			name:    "nagydani_1_square",
+			input:   modexpTestInput(bytes.Repeat([]byte{0xe0}, 64), []byte{2}, bytes.Repeat([]byte{0xf0}, 64)),
+			eip2565: 200,
+			eip7883: 500,
+		},
+		{
+			name:    "nagydani_5_pow0x10001",
+			input:   modexpTestInput(bytes.Repeat([]byte{0xc5}, 1024), []byte{1, 0, 1}, bytes.Repeat([]byte{0xd7}, 1024)),
+			eip2565: 87381,
+			eip7883: 524288,
+		},
+		{
+			// A 64-byte exponent: 32 extra bytes plus a full 256-bit head
+			name:    "long_exponent",
+			input:   modexpTestInput([]byte{2}, bytes.Repeat([]byte{0xff}, 64), []byte{7}),
+			eip2565: 200,
+			eip7883: 12272,
+		},
+	}
+
+	eip2565 := &GasSchedule{Overrides: map[string]uint64{
+		GasKeyPCModexpMinGas:            200,
+		GasKeyPCModexpMinComplexity:     0,
+		GasKeyPCModexpComplexityMul:     1,
+		GasKeyPCModexpExpByteIterations: 8,
+		GasKeyPCModexpDivisor:           3,
+	}}
+	eip7883 := &GasSchedule{Overrides: map[string]uint64{
+		GasKeyPCModexpMinGas:            500,
+		GasKeyPCModexpMinComplexity:     16,
+		GasKeyPCModexpComplexityMul:     2,
+		GasKeyPCModexpExpByteIterations: 16,
+		GasKeyPCModexpDivisor:           1,
+	}}
+
+	for _, v := range vectors {
+		// defaultGas is ignored once a formula parameter is set
+		if got := PrecompileGasWithOverrides(eip2565, "MODEXP", v.input, 1); got != v.eip2565 {
+			t.Errorf("%s: EIP-2565 gas = %d, want %d", v.name, got, v.eip2565)
+		}
+
+		if got := PrecompileGasWithOverrides(eip7883, "MODEXP", v.input, 1); got != v.eip7883 {
+			t.Errorf("%s: EIP-7883 gas = %d, want %d", v.name, got, v.eip7883)
+		}
+	}
+
+	// Without formula parameters the stock gas is kept, floored by the min gas
+	minOnly := &GasSchedule{Overrides: map[string]uint64{GasKeyPCModexpMinGas: 500}}
+	for defaultGas, want := range map[uint64]uint64{1360: 1360, 200: 500} {
+		if got := PrecompileGasWithOverrides(minOnly, "MODEXP", vectors[0].input, defaultGas); got != want {
+			t.Errorf("min gas only with stock %d: got %d, want %d", defaultGas, got, want)
+		}
+	}
+}
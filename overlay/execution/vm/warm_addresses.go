@@ -0,0 +1,64 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package vm
+
+import "github.com/erigontech/erigon/common"
+
+// warmAddressArgs maps each account-access opcode to the stack position of the
+// address it accesses.
+var warmAddressArgs = map[OpCode]int{
+	BALANCE:      0,
+	EXTCODESIZE:  0,
+	EXTCODECOPY:  0,
+	EXTCODEHASH:  0,
+	CALL:         1,
+	CALLCODE:     1,
+	DELEGATECALL: 1,
+	STATICCALL:   1,
+	SELFDESTRUCT: 0, // beneficiary
+}
+
+// UseWarmAddresses makes addrs behave as if they were in the EIP-2929 access list
+// from the start of the transaction. Account-access, CALL-variant and SELFDESTRUCT
+// opcodes add a listed target to the access list before computing their gas, so it
+// is charged the warm cost even on first access.
+//
+// The addition is journaled like any other, so a reverted frame drops it again.
+// Since it is repeated on every access rather than done once, the next access after
+// the revert is still charged warm.
+//
+// The state already pre-warms the fork's precompiles when the transaction starts;
+// this covers addresses it does not know about, such as a precompile moved to a new
+// address. The table must be a copy (see GetBaseJumpTable).
+func UseWarmAddresses(jt *JumpTable, addrs []common.Address) {
+	if len(addrs) == 0 {
+		return
+	}
+
+	warm := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		warm[addr] = struct{}{}
+	}
+
+	for op, arg := range warmAddressArgs {
+		if jt.IsDefined(op) && jt[op].dynamicGas != nil {
+			jt[op].dynamicGas = withWarmAddresses(jt[op].dynamicGas, warm, arg)
+		}
+	}
+}
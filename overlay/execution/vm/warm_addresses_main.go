@@ -0,0 +1,39 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/types/accounts"
+)
+
+// withWarmAddresses wraps an account-access gas function so that a target in warm,
+// read from stack position arg, is added to the access list before fn runs. This
+// happens on every access, so a revert that dropped the address does not make it cold.
+func withWarmAddresses(fn gasFunc, warm map[common.Address]struct{}, arg int) gasFunc {
+	return func(evm *EVM, callContext *CallContext, availableGas mdgas.MdGas, memorySize uint64) (mdgas.MdGas, error) {
+		addr := common.Address(callContext.Stack.Back(arg).Bytes20())
+		if _, ok := warm[addr]; ok {
+			evm.IntraBlockState().AddAddressToAccessList(accounts.InternAddress(addr))
+		}
+
+		return fn(evm, callContext, availableGas, memorySize)
+	}
+}
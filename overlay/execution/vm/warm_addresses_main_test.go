@@ -0,0 +1,126 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package vm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/mdgas"
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/state"
+)
+
+// TestUseWarmAddresses runs the London account-access gas functions against a
+// precompile moved to a new address and verifies that the first access is charged
+// no cold-access cost, while an unlisted address still is.
+func TestUseWarmAddresses(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+
+	moved := common.HexToAddress("0x0000000000000000000000000000000000000100")
+	unlisted := common.HexToAddress("0x0000000000000000000000000000000000000200")
+
+	jt := GetBaseJumpTable(rules)
+	UseWarmAddresses(jt, []common.Address{moved})
+
+	surcharge := params.ColdAccountAccessCostEIP2929 - params.WarmStorageReadCostEIP2929
+
+	tests := []struct {
+		op    OpCode
+		stack []uint64 // pushed in order, with the target pushed after these
+		above []uint64 // pushed after the target
+		cold  uint64   // cost for a cold target
+	}{
+		{op: BALANCE, cold: surcharge},
+		{op: EXTCODEHASH, cold: surcharge},
+		{op: CALL, stack: []uint64{0, 0, 0, 0, 0}, above: []uint64{0}, cold: surcharge},
+		{op: STATICCALL, stack: []uint64{0, 0, 0, 0}, above: []uint64{0}, cold: surcharge},
+		// SELFDESTRUCT has no warm cost, so a cold beneficiary costs the full access
+		{op: SELFDESTRUCT, cold: params.ColdAccountAccessCostEIP2929},
+	}
+
+	for _, tc := range tests {
+		for target, want := range map[common.Address]uint64{moved: 0, unlisted: tc.cold} {
+			evm := &EVM{intraBlockState: state.New(&driftStateReader{}), chainRules: rules}
+
+			callContext := &CallContext{gas: math.MaxUint64}
+			for _, v := range tc.stack {
+				callContext.Stack.Push(uint256.NewInt(v))
+			}
+
+			callContext.Stack.Push(new(uint256.Int).SetBytes(target[:]))
+			for _, v := range tc.above {
+				callContext.Stack.Push(uint256.NewInt(v))
+			}
+
+			gas, err := jt[tc.op].dynamicGas(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, 0)
+			if err != nil {
+				t.Fatalf("%s %s: unexpected error: %v", tc.op, target, err)
+			}
+
+			if gas.Regular != want {
+				t.Errorf("%s %s: first access charged %d, want %d", tc.op, target, gas.Regular, want)
+			}
+		}
+	}
+}
+
+// TestUseWarmAddressesAfterRevert accesses a moved precompile, reverts the state to
+// before the access, as a failed frame does, and verifies that the next access is
+// still charged warm.
+func TestUseWarmAddressesAfterRevert(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+
+	moved := common.HexToAddress("0x0000000000000000000000000000000000000100")
+
+	jt := GetBaseJumpTable(rules)
+	UseWarmAddresses(jt, []common.Address{moved})
+
+	ibs := state.New(&driftStateReader{})
+	evm := &EVM{intraBlockState: ibs, chainRules: rules}
+
+	balance := func() uint64 {
+		callContext := &CallContext{gas: math.MaxUint64}
+		callContext.Stack.Push(new(uint256.Int).SetBytes(moved[:]))
+
+		gas, err := jt[BALANCE].dynamicGas(evm, callContext, mdgas.MdGas{Regular: math.MaxUint64}, 0)
+		if err != nil {
+			t.Fatalf("BALANCE: unexpected error: %v", err)
+		}
+
+		return gas.Regular
+	}
+
+	snapshot := ibs.Snapshot()
+	if got := balance(); got != 0 {
+		t.Fatalf("BALANCE in the reverted frame charged %d, want 0", got)
+	}
+
+	ibs.RevertToSnapshot(snapshot, nil)
+
+	if got := balance(); got != 0 {
+		t.Errorf("BALANCE after the revert charged %d, want 0", got)
+	}
+}
@@ -9,25 +9,32 @@ import (
 	"github.com/erigontech/erigon/execution/chain"
 	"github.com/erigontech/erigon/execution/protocol/rules"
 	"github.com/erigontech/erigon/node"
+	"github.com/erigontech/erigon/node/ethconfig"
 	"github.com/erigontech/erigon/node/xatu"
 	"github.com/erigontech/erigon/rpc"
 )
 
 // initXatu initializes the Xatu service when built with the embedded tag.
 // Returns the APIs to register and any error.
-// If xatuConfigPath is "simulation", enables simulation-only mode (no config file needed).
+// If config.XatuConfig is "simulation", enables simulation-only mode (no config file needed).
 func initXatu(
 	stack *node.Node,
 	chainKv kv.TemporalRoDB,
 	blockReader services.FullBlockReader,
 	chainConfig *chain.Config,
 	engine rules.EngineReader,
-	xatuConfigPath string,
+	config *ethconfig.Config,
 	logger log.Logger,
 ) ([]rpc.API, error) {
 	xatuConfig := xatu.Config{
-		ConfigPath:     xatuConfigPath,
-		SimulationOnly: xatuConfigPath == "simulation",
+		ConfigPath:       config.XatuConfig,
+		SimulationOnly:   config.XatuConfig == "simulation",
+		MinSupportedFork: config.XatuMinSupportedFork,
+		MaxResponseBytes: config.XatuMaxResponseBytes,
+
+		MaxConcurrentExecutions: config.XatuMaxConcurrentExecutions,
+		ResultCacheSize:         config.XatuResultCacheSize,
+		ResultCacheTTL:          config.XatuResultCacheTTL,
 	}
 
 	svc, err := xatu.New(stack, chainKv, blockReader, chainConfig, engine, xatuConfig, logger)
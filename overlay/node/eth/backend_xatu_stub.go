@@ -9,6 +9,7 @@ import (
 	"github.com/erigontech/erigon/execution/chain"
 	"github.com/erigontech/erigon/execution/protocol/rules"
 	"github.com/erigontech/erigon/node"
+	"github.com/erigontech/erigon/node/ethconfig"
 	"github.com/erigontech/erigon/rpc"
 )
 
@@ -20,7 +21,7 @@ func initXatu(
 	_ services.FullBlockReader,
 	_ *chain.Config,
 	_ rules.EngineReader,
-	_ string,
+	_ *ethconfig.Config,
 	_ log.Logger,
 ) ([]rpc.API, error) {
 	return nil, nil
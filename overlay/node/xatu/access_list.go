@@ -0,0 +1,307 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/erigontech/erigon/execution/tracing"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// AccessListEntry is an address together with the storage slots accessed on it.
+// The format mirrors an EIP-2930 access list entry.
+type AccessListEntry struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
+}
+
+// AccessListDiff compares the addresses and storage slots touched by the original
+// and simulated executions. When the simulated execution diverges (e.g. a call is
+// skipped because it ran out of gas), the touched set changes, which in turn
+// changes which later accesses are cold or warm.
+//
+// An address can appear in more than one bucket when the address itself is shared
+// but some of its slots were only touched by one execution.
+type AccessListDiff struct {
+	OriginalOnly  []AccessListEntry `json:"originalOnly"`
+	SimulatedOnly []AccessListEntry `json:"simulatedOnly"`
+	Both          []AccessListEntry `json:"both"`
+}
+
+// accessTracker records the addresses and storage slots touched during execution.
+// It observes the same operations that populate the EIP-2929 access list
+// (account-access opcodes, SLOAD/SSTORE and call frame targets).
+type accessTracker struct {
+	addresses map[string]struct{}
+	slots     map[string]map[string]struct{}
+
+	// Addresses and slots warm from the start of the transaction: the sender,
+	// recipient, precompiles and the declared access list
+	warmAddresses map[string]struct{}
+	warmSlots     map[string]map[string]struct{}
+}
+
+// newAccessTracker creates an empty access tracker.
+func newAccessTracker() *accessTracker {
+	return &accessTracker{
+		addresses:     make(map[string]struct{}, 16),
+		slots:         make(map[string]map[string]struct{}, 16),
+		warmAddresses: make(map[string]struct{}, 32),
+		warmSlots:     make(map[string]map[string]struct{}, 4),
+	}
+}
+
+// startTx records the precompiles, the coinbase (see warmCoinbase) and the
+// transaction's declared access list as warm from the start of the transaction.
+func (a *accessTracker) startTx(txn erigontypes.Transaction, precompiles vm.PrecompiledContracts, coinbase string) {
+	a.warmImplicit(precompiles, coinbase)
+
+	if txn != nil {
+		a.warmAccessList(txn.GetAccessList())
+	}
+}
+
+// warmImplicit records the precompiles and the coinbase (see warmCoinbase) as warm.
+func (a *accessTracker) warmImplicit(precompiles vm.PrecompiledContracts, coinbase string) {
+	for addr := range precompiles {
+		a.warmAddresses[normalizeAddress(addr.String())] = struct{}{}
+	}
+
+	if coinbase != "" {
+		a.warmAddresses[coinbase] = struct{}{}
+	}
+}
+
+// warmAccessList records the entries of a declared access list as warm.
+func (a *accessTracker) warmAccessList(accessList erigontypes.AccessList) {
+	for _, tuple := range accessList {
+		addr := normalizeAddress(tuple.Address.String())
+		a.warmAddresses[addr] = struct{}{}
+
+		for _, key := range tuple.StorageKeys {
+			addSlot(a.warmSlots, addr, "0x"+hex.EncodeToString(key[:]))
+		}
+	}
+}
+
+// enter records a call frame target as accessed. The top-level frame's sender and
+// recipient are warm from the start of the transaction, and an address created by
+// CREATE/CREATE2 is warmed by its creation rather than accessed cold.
+func (a *accessTracker) enter(depth int, typ byte, from, to string) {
+	if depth == 0 {
+		a.warmAddresses[normalizeAddress(from)] = struct{}{}
+		a.warmAddresses[normalizeAddress(to)] = struct{}{}
+	}
+
+	if typ == 0xF0 || typ == 0xF5 { // CREATE, CREATE2
+		a.warmAddresses[normalizeAddress(to)] = struct{}{}
+	}
+
+	a.touchAddress(normalizeAddress(to))
+}
+
+// touchAddress records an address access.
+func (a *accessTracker) touchAddress(addr string) {
+	a.addresses[addr] = struct{}{}
+}
+
+// touchSlot records a storage slot access on the given address.
+func (a *accessTracker) touchSlot(addr, slot string) {
+	a.touchAddress(addr)
+	addSlot(a.slots, addr, slot)
+}
+
+// addSlot adds a storage slot of the given address to a slot set.
+func addSlot(slots map[string]map[string]struct{}, addr, slot string) {
+	keys, ok := slots[addr]
+	if !ok {
+		keys = make(map[string]struct{}, 4)
+		slots[addr] = keys
+	}
+
+	keys[slot] = struct{}{}
+}
+
+// recordOpcode records the access made by an opcode, if any.
+func (a *accessTracker) recordOpcode(opcode byte, scope tracing.OpContext) {
+	recordOpcodeAccess(a, opcode, scope)
+}
+
+// accessRecorder receives the addresses and storage slots accessed during execution.
+type accessRecorder interface {
+	touchAddress(addr string)
+	touchSlot(addr, slot string)
+}
+
+// recordOpcodeAccess reports the access made by an opcode, if any, to r.
+// Addresses are read from the stack before the opcode executes.
+func recordOpcodeAccess(r accessRecorder, opcode byte, scope tracing.OpContext) {
+	stack := scope.StackData()
+
+	switch opcode {
+	case 0x54, 0x55: // SLOAD, SSTORE
+		if len(stack) > 0 {
+			slot := stack[len(stack)-1].Bytes32()
+			r.touchSlot(normalizeAddress(scope.Address().String()), "0x"+hex.EncodeToString(slot[:]))
+		}
+	case 0x31, 0x3B, 0x3C, 0x3F, 0xFF: // BALANCE, EXTCODESIZE, EXTCODECOPY, EXTCODEHASH, SELFDESTRUCT
+		if len(stack) > 0 {
+			addr := stack[len(stack)-1].Bytes20()
+			r.touchAddress("0x" + hex.EncodeToString(addr[:]))
+		}
+	case 0xF1, 0xF2, 0xF4, 0xFA: // CALL, CALLCODE, DELEGATECALL, STATICCALL
+		if len(stack) > 1 {
+			addr := stack[len(stack)-2].Bytes20()
+			r.touchAddress("0x" + hex.EncodeToString(addr[:]))
+		}
+	}
+}
+
+// coldAccessCount returns the number of accesses that are cold under EIP-2929:
+// the first access to each distinct address and storage slot, other than those
+// warm from the start of the transaction or by their creation. It depends only
+// on which state the transaction touches, not on the gas charged for it. Returns 0
+// for a nil tracker.
+func (a *accessTracker) coldAccessCount() uint64 {
+	if a == nil {
+		return 0
+	}
+
+	var count uint64
+
+	for addr := range a.addresses {
+		if _, ok := a.warmAddresses[addr]; !ok {
+			count++
+		}
+	}
+
+	for addr, keys := range a.slots {
+		for key := range keys {
+			if _, ok := a.warmSlots[addr][key]; !ok {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// reset clears all recorded accesses.
+func (a *accessTracker) reset() {
+	clear(a.addresses)
+	clear(a.slots)
+	clear(a.warmAddresses)
+	clear(a.warmSlots)
+}
+
+// warmCoinbase returns the block's coinbase if it is warm from the start of the
+// transaction (EIP-3651, from Shanghai), or "" otherwise.
+func warmCoinbase(env *tracing.VMContext) string {
+	if env == nil || env.ChainConfig == nil || !env.ChainConfig.IsShanghai(env.Time) {
+		return ""
+	}
+
+	return normalizeAddress(env.Coinbase.String())
+}
+
+// normalizeAddress lower-cases a hex address so that checksummed and
+// stack-derived representations compare equal.
+func normalizeAddress(addr string) string {
+	return strings.ToLower(addr)
+}
+
+// diffAccessLists buckets the accesses of two executions into original-only,
+// simulated-only and shared sets. Output is sorted for deterministic responses.
+func diffAccessLists(original, simulated *accessTracker) *AccessListDiff {
+	if original == nil || simulated == nil {
+		return nil
+	}
+
+	originalOnly := make(map[string][]string)
+	simulatedOnly := make(map[string][]string)
+	both := make(map[string][]string)
+
+	bucketAddresses(original.addresses, simulated.addresses, originalOnly, simulatedOnly, both)
+
+	for addr, keys := range original.slots {
+		for key := range keys {
+			if _, ok := simulated.slots[addr][key]; ok {
+				both[addr] = append(both[addr], key)
+			} else {
+				originalOnly[addr] = append(originalOnly[addr], key)
+			}
+		}
+	}
+
+	for addr, keys := range simulated.slots {
+		for key := range keys {
+			if _, ok := original.slots[addr][key]; !ok {
+				simulatedOnly[addr] = append(simulatedOnly[addr], key)
+			}
+		}
+	}
+
+	return &AccessListDiff{
+		OriginalOnly:  toAccessListEntries(originalOnly),
+		SimulatedOnly: toAccessListEntries(simulatedOnly),
+		Both:          toAccessListEntries(both),
+	}
+}
+
+// bucketAddresses splits address-level accesses into the three diff buckets.
+// Slots are appended afterwards, so entries start out without storage keys.
+func bucketAddresses(original, simulated map[string]struct{}, originalOnly, simulatedOnly, both map[string][]string) {
+	for addr := range original {
+		if _, ok := simulated[addr]; ok {
+			both[addr] = nil
+		} else {
+			originalOnly[addr] = nil
+		}
+	}
+
+	for addr := range simulated {
+		if _, ok := original[addr]; !ok {
+			simulatedOnly[addr] = nil
+		}
+	}
+}
+
+// toAccessListEntries converts an address->slots map into sorted entries.
+func toAccessListEntries(m map[string][]string) []AccessListEntry {
+	entries := make([]AccessListEntry, 0, len(m))
+
+	for addr, keys := range m {
+		if keys == nil {
+			keys = []string{}
+		}
+
+		sort.Strings(keys)
+		entries = append(entries, AccessListEntry{Address: addr, StorageKeys: keys})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Address < entries[j].Address
+	})
+
+	return entries
+}
@@ -0,0 +1,199 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/hex"
+
+	"github.com/erigontech/erigon/common/math"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// AccessListValue weighs the intrinsic gas a transaction paid for its declared
+// EIP-2930 access list against the cold-access gas the list saved during execution.
+//
+// Savings are estimated per declared entry that execution actually accessed: each
+// address saves the cold account access surcharge (CALL_COLD - SLOAD_WARM) and each
+// storage key the cold SLOAD surcharge (SLOAD_COLD - SLOAD_WARM). Addresses that
+// are warm anyway (sender, recipient, precompiles and, from Shanghai, the coinbase)
+// save nothing.
+type AccessListValue struct {
+	Addresses           int    `json:"addresses"`           // Declared addresses, as charged (duplicates included)
+	StorageKeys         int    `json:"storageKeys"`         // Declared storage keys, as charged (duplicates included)
+	AccessedAddresses   int    `json:"accessedAddresses"`   // Declared addresses accessed that were not otherwise warm
+	AccessedStorageKeys int    `json:"accessedStorageKeys"` // Declared storage keys accessed
+	IntrinsicCost       uint64 `json:"intrinsicCost"`       // TX_ACCESS_LIST_ADDR and TX_ACCESS_LIST_KEY gas paid
+	GasSaved            uint64 `json:"gasSaved"`            // Estimated cold-access gas avoided
+	NetGas              int64  `json:"netGas"`              // GasSaved - IntrinsicCost; positive if the list paid off
+
+	// Unused lists the declared entries execution never accessed (or that were warm anyway).
+	Unused []AccessListEntry `json:"unused"`
+}
+
+// AccessListAnalysis holds the access list value under both gas schedules.
+type AccessListAnalysis struct {
+	Original  AccessListValue `json:"original"`
+	Simulated AccessListValue `json:"simulated"`
+}
+
+// accessListUsage records which entries of a transaction's declared access list
+// are accessed during execution.
+type accessListUsage struct {
+	addresses map[string]bool            // Declared address -> accessed
+	slots     map[string]map[string]bool // Declared address -> storage key -> accessed
+	prewarmed map[string]struct{}        // Addresses warm regardless of the access list
+
+	declaredAddresses int
+	declaredKeys      int
+}
+
+// newAccessListUsage creates an empty access list usage tracker.
+func newAccessListUsage() *accessListUsage {
+	return &accessListUsage{
+		addresses: make(map[string]bool, 8),
+		slots:     make(map[string]map[string]bool, 8),
+		prewarmed: make(map[string]struct{}, 32),
+	}
+}
+
+// start seeds the tracker with the transaction's access list, and the precompiles
+// and coinbase (see warmCoinbase), which are warm from the start of the transaction.
+func (u *accessListUsage) start(accessList erigontypes.AccessList, precompiles vm.PrecompiledContracts, coinbase string) {
+	for addr := range precompiles {
+		u.prewarmed[normalizeAddress(addr.String())] = struct{}{}
+	}
+
+	if coinbase != "" {
+		u.prewarmed[coinbase] = struct{}{}
+	}
+
+	u.declaredAddresses = len(accessList)
+	u.declaredKeys = accessList.StorageKeys()
+
+	for _, tuple := range accessList {
+		addr := normalizeAddress(tuple.Address.String())
+		if _, ok := u.addresses[addr]; !ok {
+			u.addresses[addr] = false
+		}
+
+		for _, key := range tuple.StorageKeys {
+			keys, ok := u.slots[addr]
+			if !ok {
+				keys = make(map[string]bool, len(tuple.StorageKeys))
+				u.slots[addr] = keys
+			}
+
+			slot := "0x" + hex.EncodeToString(key[:])
+			if _, ok := keys[slot]; !ok {
+				keys[slot] = false
+			}
+		}
+	}
+}
+
+// enter records a call frame. The top-level frame's sender and recipient are warm
+// regardless of the access list.
+func (u *accessListUsage) enter(depth int, from, to string) {
+	if depth == 0 {
+		u.prewarmed[normalizeAddress(from)] = struct{}{}
+		u.prewarmed[normalizeAddress(to)] = struct{}{}
+	}
+
+	u.touchAddress(normalizeAddress(to))
+}
+
+// touchAddress marks a declared address as accessed.
+func (u *accessListUsage) touchAddress(addr string) {
+	if _, ok := u.addresses[addr]; ok {
+		u.addresses[addr] = true
+	}
+}
+
+// touchSlot marks a declared storage key as accessed.
+func (u *accessListUsage) touchSlot(addr, slot string) {
+	u.touchAddress(addr)
+
+	if _, ok := u.slots[addr][slot]; ok {
+		u.slots[addr][slot] = true
+	}
+}
+
+// value computes the access list value with the costs of the given schedule,
+// falling back to defaults for keys it does not override.
+func (u *accessListUsage) value(schedule *CustomGasSchedule, defaults map[string]uint64) AccessListValue {
+	cost := func(key string) uint64 {
+		if schedule != nil {
+			if v, ok := schedule.Overrides[key]; ok {
+				return v
+			}
+		}
+
+		return defaults[key]
+	}
+
+	result := AccessListValue{
+		Addresses:   u.declaredAddresses,
+		StorageKeys: u.declaredKeys,
+		IntrinsicCost: uint64(u.declaredAddresses)*cost(vm.GasKeyTxAccessListAddr) +
+			uint64(u.declaredKeys)*cost(vm.GasKeyTxAccessListKey),
+	}
+
+	warm := cost(vm.GasKeySloadWarm)
+	addressSaving := math.SafeSubClamp(cost(vm.GasKeyCallCold), warm)
+	slotSaving := math.SafeSubClamp(cost(vm.GasKeySloadCold), warm)
+
+	unused := make(map[string][]string)
+
+	for addr, accessed := range u.addresses {
+		_, prewarmed := u.prewarmed[addr]
+
+		if accessed && !prewarmed {
+			result.AccessedAddresses++
+			result.GasSaved += addressSaving
+		} else {
+			unused[addr] = nil
+		}
+	}
+
+	for addr, keys := range u.slots {
+		for slot, accessed := range keys {
+			if accessed {
+				result.AccessedStorageKeys++
+				result.GasSaved += slotSaving
+			} else {
+				unused[addr] = append(unused[addr], slot)
+			}
+		}
+	}
+
+	result.NetGas = int64(result.GasSaved) - int64(result.IntrinsicCost)
+	result.Unused = toAccessListEntries(unused)
+
+	return result
+}
+
+// reset clears the tracker for the next transaction.
+func (u *accessListUsage) reset() {
+	clear(u.addresses)
+	clear(u.slots)
+	clear(u.prewarmed)
+	u.declaredAddresses = 0
+	u.declaredKeys = 0
+}
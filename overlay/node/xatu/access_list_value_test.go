@@ -0,0 +1,121 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/chain"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// TestAccessListValue traces a transaction whose access list declares a called
+// contract with one used and one unused slot, an address that is never accessed
+// and its own recipient, and checks the cost/benefit under both schedules.
+func TestAccessListValue(t *testing.T) {
+	var (
+		sender    = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		contract  = common.HexToAddress("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+		unused    = common.HexToAddress("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+		recipient = common.HexToAddress("0xcccccccccccccccccccccccccccccccccccccccc")
+		usedKey   = common.HexToHash("0x01")
+		unusedKey = common.HexToHash("0x02")
+	)
+
+	accessList := erigontypes.AccessList{
+		{Address: contract, StorageKeys: []common.Hash{usedKey, unusedKey}},
+		{Address: unused},
+		{Address: recipient},
+	}
+
+	usage := newAccessListUsage()
+	usage.start(accessList, nil, "")
+
+	// The recipient calls the contract, which reads one declared slot
+	usage.enter(0, sender.String(), recipient.String())
+	usage.enter(1, recipient.String(), contract.String())
+	usage.touchSlot(normalizeAddress(contract.String()), usedKey.Hex())
+
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+	defaults := GasScheduleForRules(rules).Overrides
+
+	got := usage.value(nil, defaults)
+
+	// 3 addresses * 2400 + 2 keys * 1900 paid; the recipient was warm anyway, so only
+	// the contract (2600 - 100) and its used slot (2100 - 100) saved anything
+	want := AccessListValue{
+		Addresses:           3,
+		StorageKeys:         2,
+		AccessedAddresses:   1,
+		AccessedStorageKeys: 1,
+		IntrinsicCost:       11000,
+		GasSaved:            4500,
+		NetGas:              -6500,
+	}
+
+	if got.Addresses != want.Addresses || got.StorageKeys != want.StorageKeys ||
+		got.AccessedAddresses != want.AccessedAddresses || got.AccessedStorageKeys != want.AccessedStorageKeys ||
+		got.IntrinsicCost != want.IntrinsicCost || got.GasSaved != want.GasSaved || got.NetGas != want.NetGas {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	wantUnused := []string{normalizeAddress(contract.String()), normalizeAddress(unused.String()), normalizeAddress(recipient.String())}
+	if len(got.Unused) != len(wantUnused) {
+		t.Fatalf("got %d unused entries, want %d: %+v", len(got.Unused), len(wantUnused), got.Unused)
+	}
+
+	for i, addr := range wantUnused {
+		if got.Unused[i].Address != addr {
+			t.Errorf("unused entry %d: got %s, want %s", i, got.Unused[i].Address, addr)
+		}
+	}
+
+	if keys := got.Unused[0].StorageKeys; len(keys) != 1 || keys[0] != unusedKey.Hex() {
+		t.Errorf("unused keys of contract = %v, want [%s]", keys, unusedKey.Hex())
+	}
+
+	// Free access list addresses make the same list worth it
+	simulated := usage.value(&CustomGasSchedule{Overrides: map[string]uint64{"TX_ACCESS_LIST_ADDR": 0}}, defaults)
+	if simulated.IntrinsicCost != 3800 || simulated.NetGas != 700 {
+		t.Errorf("simulated cost = %d, net = %d, want 3800, 700", simulated.IntrinsicCost, simulated.NetGas)
+	}
+}
+
+// TestAccessListValueWarmCoinbase checks that declaring the coinbase saves nothing
+// once it is warm from the start of the transaction (EIP-3651).
+func TestAccessListValueWarmCoinbase(t *testing.T) {
+	coinbase := common.HexToAddress("0xc0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0")
+	accessList := erigontypes.AccessList{{Address: coinbase}}
+
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+	defaults := GasScheduleForRules(rules).Overrides
+
+	for warm, want := range map[string]uint64{"": 2500, normalizeAddress(coinbase.String()): 0} {
+		usage := newAccessListUsage()
+		usage.start(accessList, nil, warm)
+		usage.touchAddress(normalizeAddress(coinbase.String()))
+
+		if got := usage.value(nil, defaults).GasSaved; got != want {
+			t.Errorf("coinbase warm %q: saved %d, want %d", warm, got, want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxAddressGasBlocks bounds the block range of a single address simulation.
+const maxAddressGasBlocks = 1000
+
+// AddressTxGas is the gas of one transaction touching the analyzed address.
+type AddressTxGas struct {
+	BlockNumber  uint64  `json:"blockNumber"`
+	Hash         string  `json:"hash"`
+	Index        uint64  `json:"index"`
+	Direct       bool    `json:"direct"` // The transaction's to is the address
+	OriginalGas  uint64  `json:"originalGas"`
+	SimulatedGas uint64  `json:"simulatedGas"`
+	DeltaPercent float64 `json:"deltaPercent"`
+	// Contract is the gas used by the address's own frames (with internal calls).
+	Contract *ContractGas `json:"contract,omitempty"`
+}
+
+// SimulateAddressGasResult is the result of xatu_simulateAddressGas.
+type SimulateAddressGasResult struct {
+	Address   string `json:"address"`
+	FromBlock uint64 `json:"fromBlock"`
+	ToBlock   uint64 `json:"toBlock"`
+	TxCount   int    `json:"txCount"`
+	// OriginalGas and SimulatedGas total the gas of the matching transactions.
+	OriginalGas  uint64  `json:"originalGas"`
+	SimulatedGas uint64  `json:"simulatedGas"`
+	DeltaPercent float64 `json:"deltaPercent"`
+	// ContractGas totals the gas used by the address's own frames, i.e. the part
+	// of the transactions' gas spent in its code. Only set with internal calls.
+	ContractGas  *ContractGas   `json:"contractGas,omitempty"`
+	Transactions []AddressTxGas `json:"transactions"`
+}
+
+// add accumulates a matching transaction.
+func (r *SimulateAddressGasResult) add(tx AddressTxGas) {
+	r.TxCount++
+	r.OriginalGas += tx.OriginalGas
+	r.SimulatedGas += tx.SimulatedGas
+
+	if tx.Contract != nil {
+		if r.ContractGas == nil {
+			r.ContractGas = &ContractGas{}
+		}
+
+		r.ContractGas.OriginalGas += tx.Contract.OriginalGas
+		r.ContractGas.SimulatedGas += tx.Contract.SimulatedGas
+	}
+
+	r.Transactions = append(r.Transactions, tx)
+}
+
+// parseAddress normalizes a 0x-prefixed, 40 hex digit address.
+func parseAddress(address string) (string, error) {
+	digits, ok := strings.CutPrefix(normalizeAddress(address), "0x")
+	if !ok || len(digits) != 40 {
+		return "", fmt.Errorf("invalid address %q", address)
+	}
+
+	for _, c := range digits {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return "", fmt.Errorf("invalid address %q", address)
+		}
+	}
+
+	return "0x" + digits, nil
+}
+
+// SimulateAddressGas simulates, over a block range, the transactions touching an
+// address and aggregates their gas deltas, e.g. for a protocol team analyzing its
+// contract under a repricing.
+//
+// By default only transactions whose to is the address are simulated, which is a
+// cheap filter on the block body. With includeInternalCalls, transactions reaching
+// the address through internal calls are included too; these can only be found by
+// tracing, so every transaction in the range is simulated, which is much more
+// expensive. The gas spent in the address's own frames is then also reported.
+func (s *Service) SimulateAddressGas(
+	ctx context.Context,
+	address string,
+	fromBlock, toBlock uint64,
+	schedule *CustomGasSchedule,
+	includeInternalCalls *bool,
+) (*SimulateAddressGasResult, error) {
+	addr, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if toBlock < fromBlock {
+		return nil, fmt.Errorf("to block %d is before from block %d", toBlock, fromBlock)
+	}
+
+	if blocks := toBlock - fromBlock + 1; blocks > maxAddressGasBlocks || blocks == 0 {
+		return nil, fmt.Errorf("range spans more than %d blocks", maxAddressGasBlocks)
+	}
+
+	if err := schedule.validateRelativeOverrides(); err != nil {
+		return nil, err
+	}
+
+	internal := includeInternalCalls != nil && *includeInternalCalls
+
+	result := &SimulateAddressGasResult{
+		Address:      addr,
+		FromBlock:    fromBlock,
+		ToBlock:      toBlock,
+		Transactions: make([]AddressTxGas, 0),
+	}
+
+	for blockNum := fromBlock; blockNum <= toBlock; blockNum++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if err := s.simulateAddressGasInBlock(ctx, addr, blockNum, schedule, internal, result); err != nil {
+			return nil, fmt.Errorf("failed to simulate block %d: %w", blockNum, err)
+		}
+	}
+
+	result.DeltaPercent = deltaPercent(result.OriginalGas, result.SimulatedGas)
+
+	return result, nil
+}
+
+// simulateAddressGasInBlock simulates the transactions of a block touching addr
+// and adds them to result.
+func (s *Service) simulateAddressGasInBlock(
+	ctx context.Context,
+	addr string,
+	blockNum uint64,
+	schedule *CustomGasSchedule,
+	internal bool,
+	result *SimulateAddressGasResult,
+) error {
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	block, err := s.blockReader.BlockByNumber(ctx, tx, blockNum)
+	if err != nil {
+		return fmt.Errorf("failed to get block: %w", err)
+	}
+
+	if block == nil {
+		return fmt.Errorf("block not found")
+	}
+
+	header := block.Header()
+	opts := executionOptions{GasSchedule: schedule}
+
+	if err := s.checkSupportedFork(ctx, opts, blockNum, header.Time); err != nil {
+		return err
+	}
+
+	if err := s.resolveRelativeOverrides(ctx, &opts, blockNum, header.Time); err != nil {
+		return err
+	}
+
+	txNumReader := s.txNumsReader(ctx)
+	tracerCfg := SimulationTracerConfig{TrackContracts: internal}
+
+	for txIndex, txn := range block.Transactions() {
+		to := txn.GetTo()
+		direct := to != nil && normalizeAddress(to.String()) == addr
+
+		if !direct && !internal {
+			continue
+		}
+
+		dualResult, err := s.executeTransactionDual(ctx, tx, header, block, txIndex, txNumReader, opts, tracerCfg)
+		if err != nil {
+			return fmt.Errorf("failed to execute tx %d: %w", txIndex, err)
+		}
+
+		contract, called := dualResult.ContractBreakdown[addr]
+		if !direct && !called {
+			continue
+		}
+
+		entry := AddressTxGas{
+			BlockNumber:  blockNum,
+			Hash:         txn.Hash().Hex(),
+			Index:        uint64(txIndex),
+			Direct:       direct,
+			OriginalGas:  dualResult.Original.GasUsed,
+			SimulatedGas: dualResult.Simulated.GasUsed,
+			DeltaPercent: deltaPercent(dualResult.Original.GasUsed, dualResult.Simulated.GasUsed),
+		}
+
+		if internal {
+			entry.Contract = &contract
+		}
+
+		result.add(entry)
+	}
+
+	return nil
+}
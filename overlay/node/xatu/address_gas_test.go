@@ -0,0 +1,83 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"testing"
+)
+
+// TestSimulateAddressGasValidation checks the requests rejected before any block
+// is read.
+func TestSimulateAddressGasValidation(t *testing.T) {
+	const addr = "0x00000000000000000000000000000000000000AA"
+
+	tests := []struct {
+		name     string
+		address  string
+		from, to uint64
+	}{
+		{name: "short address", address: "0xaa", from: 1, to: 1},
+		{name: "no prefix", address: "00000000000000000000000000000000000000aa", from: 1, to: 1},
+		{name: "non-hex address", address: "0x00000000000000000000000000000000000000zz", from: 1, to: 1},
+		{name: "reversed range", address: addr, from: 10, to: 9},
+		{name: "range too large", address: addr, from: 1, to: maxAddressGasBlocks + 1},
+	}
+
+	s := &Service{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.SimulateAddressGas(context.Background(), tt.address, tt.from, tt.to, nil, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if got, err := parseAddress(addr); err != nil || got != "0x00000000000000000000000000000000000000aa" {
+		t.Errorf("parseAddress = %q, %v", got, err)
+	}
+}
+
+// TestSimulateAddressGasAggregation sums direct and internal transactions, with
+// the address's own frames totalled separately.
+func TestSimulateAddressGasAggregation(t *testing.T) {
+	result := &SimulateAddressGasResult{}
+
+	result.add(AddressTxGas{Direct: true, OriginalGas: 50000, SimulatedGas: 60000,
+		Contract: &ContractGas{OriginalGas: 20000, SimulatedGas: 30000}})
+	result.add(AddressTxGas{OriginalGas: 100000, SimulatedGas: 105000,
+		Contract: &ContractGas{OriginalGas: 5000, SimulatedGas: 10000}})
+
+	if result.TxCount != 2 || result.OriginalGas != 150000 || result.SimulatedGas != 165000 {
+		t.Errorf("totals = %d txs, %d -> %d", result.TxCount, result.OriginalGas, result.SimulatedGas)
+	}
+
+	if result.ContractGas == nil || *result.ContractGas != (ContractGas{OriginalGas: 25000, SimulatedGas: 40000}) {
+		t.Errorf("contract gas = %+v, want 25000 -> 40000", result.ContractGas)
+	}
+
+	// Without internal calls no per-contract gas is reported
+	direct := &SimulateAddressGasResult{}
+	direct.add(AddressTxGas{Direct: true, OriginalGas: 21000, SimulatedGas: 21000})
+
+	if direct.ContractGas != nil {
+		t.Errorf("contract gas = %+v, want nil", direct.ContractGas)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+	"github.com/erigontech/erigon/execution/vm/evmtypes"
+	"github.com/holiman/uint256"
+)
+
+// Blob gas (EIP-4844) is priced outside the EVM: each blob consumes a fixed amount of
+// blob gas, paid at a blob base fee derived from the block's excess blob gas. The
+// BLOB_* keys reprice it by recomputing the blob base fee into the block context,
+// which BLOBBASEFEE reads and ApplyMessage charges the blob fee at, and by replacing
+// the blob gas of the message ApplyMessage charges for (see blobGasMessage). The
+// block's excess blob gas is taken as-is.
+
+// Blob gas override keys.
+const (
+	GasKeyBlobGasPerBlob            = "BLOB_GAS_PER_BLOB"
+	GasKeyBlobBaseFeeUpdateFraction = "BLOB_BASE_FEE_UPDATE_FRACTION"
+)
+
+// blobGasKeys lists every blob gas override key.
+var blobGasKeys = []string{GasKeyBlobGasPerBlob, GasKeyBlobBaseFeeUpdateFraction}
+
+// EIP-4844 blob pricing parameters. The update fraction was raised by EIP-7691 in
+// Prague; later blob parameter forks set it through the chain config.
+const (
+	defaultBlobGasPerBlob           uint64 = 1 << 17
+	blobBaseFeeUpdateFractionCancun uint64 = 3338477
+	blobBaseFeeUpdateFractionPrague uint64 = 5007716
+	minBlobBaseFee                  int64  = 1
+)
+
+// blobPricing holds the parameters of the blob base fee formula.
+type blobPricing struct {
+	GasPerBlob     uint64
+	UpdateFraction uint64
+}
+
+// defaultBlobPricingForRules returns the blob pricing a fork introduced. It is used
+// for the xatu_getGasSchedule defaults, which only depend on the fork rules.
+func defaultBlobPricingForRules(rules *chain.Rules) blobPricing {
+	if rules.IsPrague {
+		return blobPricing{GasPerBlob: defaultBlobGasPerBlob, UpdateFraction: blobBaseFeeUpdateFractionPrague}
+	}
+
+	return blobPricing{GasPerBlob: defaultBlobGasPerBlob, UpdateFraction: blobBaseFeeUpdateFractionCancun}
+}
+
+// hasBlobOverrides returns true if any blob gas keys are overridden.
+func (c *CustomGasSchedule) hasBlobOverrides() bool {
+	if c == nil {
+		return false
+	}
+
+	for _, key := range blobGasKeys {
+		if _, ok := c.Overrides[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// blobPricing returns the blob pricing with the schedule's overrides applied over
+// defaults. An update fraction of 0 is ignored, as the formula divides by it.
+func (c *CustomGasSchedule) blobPricing(defaults blobPricing) blobPricing {
+	if c == nil {
+		return defaults
+	}
+
+	if gasPerBlob, ok := c.Overrides[GasKeyBlobGasPerBlob]; ok {
+		defaults.GasPerBlob = gasPerBlob
+	}
+
+	if fraction, ok := c.Overrides[GasKeyBlobBaseFeeUpdateFraction]; ok && fraction > 0 {
+		defaults.UpdateFraction = fraction
+	}
+
+	return defaults
+}
+
+// blobGas returns the blob gas used by a transaction carrying blobs blobs.
+func (p blobPricing) blobGas(blobs int) uint64 {
+	return uint64(blobs) * p.GasPerBlob
+}
+
+// baseFee returns the blob base fee for the excess blob gas:
+// fake_exponential(MIN_BASE_FEE_PER_BLOB_GAS, excessBlobGas, updateFraction). A small
+// update fraction can push the fee beyond 256 bits, which is rejected.
+func (p blobPricing) baseFee(excessBlobGas uint64) (*uint256.Int, error) {
+	fee, ok := fakeExponential(big.NewInt(minBlobBaseFee), new(big.Int).SetUint64(excessBlobGas),
+		new(big.Int).SetUint64(p.UpdateFraction), 256)
+	if !ok {
+		return nil, fmt.Errorf("blob base fee overflows with update fraction %d", p.UpdateFraction)
+	}
+
+	result, _ := uint256.FromBig(fee)
+
+	return result, nil
+}
+
+// fakeExponential approximates factor * e ** (numerator / denominator) using Taylor
+// expansion, as specified by EIP-4844. It gives up, returning false, once the result
+// exceeds maxBits bits, which bounds the work for large exponents.
+func fakeExponential(factor, numerator, denominator *big.Int, maxBits int) (*big.Int, bool) {
+	output := new(big.Int)
+	accum := new(big.Int).Mul(factor, denominator)
+	limit := maxBits + denominator.BitLen()
+
+	for i := int64(1); accum.Sign() > 0; i++ {
+		output.Add(output, accum)
+		if output.BitLen() > limit {
+			return nil, false
+		}
+
+		accum.Mul(accum, numerator)
+		accum.Div(accum, denominator)
+		accum.Div(accum, big.NewInt(i))
+	}
+
+	output.Div(output, denominator)
+
+	return output, output.BitLen() <= maxBits
+}
+
+// blobPricingFor returns the blob pricing a transaction executes with: the chain
+// config's update fraction at the block, with the schedule's overrides applied.
+func blobPricingFor(schedule *CustomGasSchedule, cfg *chain.Config, header *erigontypes.Header) blobPricing {
+	defaults := blobPricing{
+		GasPerBlob:     defaultBlobGasPerBlob,
+		UpdateFraction: cfg.GetBlobGasPriceUpdateFraction(header.Time),
+	}
+
+	return schedule.blobPricing(defaults)
+}
+
+// applyBlobPricing reprices blob gas in the block context when the schedule overrides
+// it, and returns the blob gas msg uses under the executed pricing.
+func applyBlobPricing(
+	blockCtx *evmtypes.BlockContext,
+	msg protocol.Message,
+	header *erigontypes.Header,
+	cfg *chain.Config,
+	schedule *CustomGasSchedule,
+) (uint64, error) {
+	if !schedule.hasBlobOverrides() || header.ExcessBlobGas == nil {
+		return msg.BlobGas(), nil
+	}
+
+	pricing := blobPricingFor(schedule, cfg, header)
+
+	fee, err := pricing.baseFee(*header.ExcessBlobGas)
+	if err != nil {
+		return 0, err
+	}
+
+	blockCtx.BlobBaseFee = fee
+
+	return pricing.blobGas(len(msg.BlobHashes())), nil
+}
+
+// blobGasMessage replaces the blob gas of a message, so ApplyMessage charges the
+// blob fee for the repriced amount.
+type blobGasMessage struct {
+	protocol.Message
+	blobGas uint64
+}
+
+// BlobGas returns the repriced blob gas.
+func (m blobGasMessage) BlobGas() uint64 {
+	return m.blobGas
+}
+
+// withBlobGas returns msg charging blobGas, or msg itself if that is its own amount.
+func withBlobGas(msg protocol.Message, blobGas uint64) protocol.Message {
+	if msg.BlobGas() == blobGas {
+		return msg
+	}
+
+	return blobGasMessage{Message: msg, blobGas: blobGas}
+}
+
+// setBlobGas reports an execution's blob gas and the fee paid for it. Transactions
+// without blobs are left without blob fields.
+func (d *TxGasDetail) setBlobGas(r *executionResult) {
+	if r.BlobGasUsed == 0 || r.BlobBaseFee == nil {
+		return
+	}
+
+	d.BlobGasUsed = r.BlobGasUsed
+	d.BlobBaseFee = formatWei(r.BlobBaseFee.ToBig())
+	d.BlobFee = formatWei(new(big.Int).Mul(new(big.Int).SetUint64(r.BlobGasUsed), r.BlobBaseFee.ToBig()))
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol"
+)
+
+// TestFakeExponential checks the EIP-4844 fake_exponential test vectors, and that
+// a result beyond the bit limit is reported instead of computed.
+func TestFakeExponential(t *testing.T) {
+	tests := []struct {
+		factor, numerator, denominator int64
+		want                           int64
+	}{
+		{1, 0, 1, 1},
+		{38493, 0, 1000, 38493},
+		{0, 1234, 2345, 0},
+		{1, 2, 1, 6},
+		{1, 4, 2, 6},
+		{1, 3, 1, 16},
+		{1, 6, 2, 18},
+		{1, 8, 2, 40},
+		{1, 10, 2, 108},
+		{1, 5, 2, 11},
+		{1, 50000000, 2225652, 5709098764},
+		{1, 380928, 3338477, 1},
+	}
+
+	for _, tt := range tests {
+		got, ok := fakeExponential(big.NewInt(tt.factor), big.NewInt(tt.numerator), big.NewInt(tt.denominator), 256)
+		if !ok || got.Int64() != tt.want {
+			t.Errorf("fakeExponential(%d, %d, %d) = %v, %v, want %d",
+				tt.factor, tt.numerator, tt.denominator, got, ok, tt.want)
+		}
+	}
+
+	// e^50000000 does not fit in 256 bits
+	if _, ok := fakeExponential(big.NewInt(1), big.NewInt(50000000), big.NewInt(1), 256); ok {
+		t.Error("expected an overflow")
+	}
+}
+
+// TestBlobPricingOverrides prices a 3-blob transaction at the same excess blob gas
+// under the default Cancun pricing and under overridden blob pricing, and checks the
+// blob gas, base fee and fee reported for each execution.
+func TestBlobPricingOverrides(t *testing.T) {
+	const excessBlobGas = 50_000_000
+	const blobs = 3
+
+	defaults := blobPricing{GasPerBlob: defaultBlobGasPerBlob, UpdateFraction: blobBaseFeeUpdateFractionCancun}
+
+	var standard *CustomGasSchedule
+	if standard.hasBlobOverrides() || standard.blobPricing(defaults) != defaults {
+		t.Fatal("nil schedule changed the blob pricing")
+	}
+
+	custom := &CustomGasSchedule{Overrides: map[string]uint64{
+		GasKeyBlobGasPerBlob:            2 * defaultBlobGasPerBlob,
+		GasKeyBlobBaseFeeUpdateFraction: 2225652,
+		"SLOAD_COLD":                    2100,
+	}}
+
+	if !custom.hasBlobOverrides() {
+		t.Fatal("blob overrides not detected")
+	}
+
+	if other := (&CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 2100}}); other.hasBlobOverrides() {
+		t.Error("non-blob overrides detected as blob overrides")
+	}
+
+	repriced := custom.blobPricing(defaults)
+	if repriced != (blobPricing{GasPerBlob: 262144, UpdateFraction: 2225652}) {
+		t.Fatalf("repriced = %+v", repriced)
+	}
+
+	detail := func(pricing blobPricing) TxGasDetail {
+		fee, err := pricing.baseFee(excessBlobGas)
+		if err != nil {
+			t.Fatalf("baseFee: %v", err)
+		}
+
+		return newTxGasDetail(&executionResult{GasUsed: 21000, BlobGasUsed: pricing.blobGas(blobs), BlobBaseFee: fee})
+	}
+
+	original, simulated := detail(defaults), detail(repriced)
+
+	// 3 blobs × 131072 blob gas at fake_exponential(1, 50000000, 3338477) = 3194333 wei
+	if original.BlobGasUsed != 393216 || original.BlobBaseFee != "0x30bddd" || original.BlobFee != "0x124732e0000" {
+		t.Errorf("original = %+v", original)
+	}
+
+	// 3 blobs × 262144 blob gas at fake_exponential(1, 50000000, 2225652) = 5709098764 wei
+	if simulated.BlobGasUsed != 786432 || simulated.BlobBaseFee != "0x15449ef0c" || simulated.BlobFee != "0xff37734900000" {
+		t.Errorf("simulated = %+v", simulated)
+	}
+
+	// A zero update fraction is ignored; one that overflows the fee is rejected
+	zero := &CustomGasSchedule{Overrides: map[string]uint64{GasKeyBlobBaseFeeUpdateFraction: 0}}
+	if got := zero.blobPricing(defaults); got != defaults {
+		t.Errorf("zero update fraction = %+v, want the defaults", got)
+	}
+
+	if _, err := (blobPricing{GasPerBlob: defaultBlobGasPerBlob, UpdateFraction: 1}).baseFee(excessBlobGas); err == nil {
+		t.Error("expected an overflow error for an update fraction of 1")
+	}
+
+	// Transactions without blobs report no blob fields
+	plain := newTxGasDetail(&executionResult{GasUsed: 21000, BlobBaseFee: uint256.NewInt(3194333)})
+	if plain.BlobGasUsed != 0 || plain.BlobBaseFee != "" || plain.BlobFee != "" {
+		t.Errorf("non-blob transaction = %+v", plain)
+	}
+}
+
+// blobMessage is a message carrying the standard blob gas of its blobs.
+type blobMessage struct {
+	protocol.Message
+	blobs int
+}
+
+func (m blobMessage) BlobGas() uint64 { return uint64(m.blobs) * defaultBlobGasPerBlob }
+
+// TestBlobGasCharged checks that ApplyMessage is handed the repriced blob gas, which
+// it charges the blob fee for, rather than the transaction's standard amount.
+func TestBlobGasCharged(t *testing.T) {
+	msg := blobMessage{blobs: 3}
+	repriced := (&CustomGasSchedule{Overrides: map[string]uint64{GasKeyBlobGasPerBlob: 2 * defaultBlobGasPerBlob}}).
+		blobPricing(blobPricing{GasPerBlob: defaultBlobGasPerBlob, UpdateFraction: blobBaseFeeUpdateFractionCancun})
+
+	charged := withBlobGas(msg, repriced.blobGas(msg.blobs))
+	if got := charged.BlobGas(); got != 786432 {
+		t.Errorf("charged blob gas = %d, want 786432", got)
+	}
+
+	if got := withBlobGas(msg, msg.BlobGas()); got != protocol.Message(msg) {
+		t.Error("standard blob gas should leave the message unchanged")
+	}
+}
+
+// TestGasScheduleForBlockBlobFraction checks that the block-aware schedule reports
+// the update fraction the chain config sets at the block.
+func TestGasScheduleForBlockBlobFraction(t *testing.T) {
+	cfg := &chain.Config{
+		ChainID:               big.NewInt(1),
+		HomesteadBlock:        big.NewInt(0),
+		TangerineWhistleBlock: big.NewInt(0),
+		SpuriousDragonBlock:   big.NewInt(0),
+		ByzantiumBlock:        big.NewInt(0),
+		ConstantinopleBlock:   big.NewInt(0),
+		PetersburgBlock:       big.NewInt(0),
+		IstanbulBlock:         big.NewInt(0),
+		BerlinBlock:           big.NewInt(0),
+		LondonBlock:           big.NewInt(0),
+		ShanghaiTime:          big.NewInt(0),
+		CancunTime:            big.NewInt(0),
+	}
+
+	params := gasScheduleForBlock(cfg, 1, 100).Parameters
+	if got, want := params[GasKeyBlobBaseFeeUpdateFraction].Value, cfg.GetBlobGasPriceUpdateFraction(100); got != want {
+		t.Errorf("update fraction = %d, want the chain config's %d", got, want)
+	}
+}
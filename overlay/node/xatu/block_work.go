@@ -0,0 +1,231 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// defaultWorkWeights is the default work model: the approximate CPU cost of each
+// opcode relative to ADD (1), for operations that are much heavier than their
+// neighbours. Precompiles (PC_<name>) are weighted per call, regardless of input
+// size. Values are rough orders of magnitude from EVM benchmarks, not measurements
+// of this node; callers can replace any of them (see WorkModel).
+var defaultWorkWeights = map[string]float64{
+	// Arithmetic
+	"DIV": 2, "SDIV": 2, "MOD": 2, "SMOD": 2, "ADDMOD": 3, "MULMOD": 4, "EXP": 10,
+	"KECCAK256": 15,
+
+	// State access (trie/snapshot lookups dominate)
+	"SLOAD": 50, "SSTORE": 80, "BALANCE": 50, "EXTCODESIZE": 50, "EXTCODEHASH": 50,
+	"EXTCODECOPY": 60, "SELFDESTRUCT": 60, "BLOCKHASH": 20,
+
+	// Call frames
+	"CALL": 40, "CALLCODE": 40, "DELEGATECALL": 40, "STATICCALL": 40,
+	"CREATE": 150, "CREATE2": 170,
+
+	// Logs
+	"LOG0": 5, "LOG1": 6, "LOG2": 7, "LOG3": 8, "LOG4": 9,
+
+	// Precompiles
+	"PC_ECREC": 500, "PC_SHA256": 10, "PC_RIPEMD160": 15, "PC_ID": 2, "PC_MODEXP": 300,
+	"PC_BN254_ADD": 30, "PC_BN254_MUL": 400, "PC_BN254_PAIRING": 5000, "PC_BLAKE2F": 50,
+	"PC_KZG_POINT_EVALUATION": 5000, "PC_P256VERIFY": 700,
+	"PC_BLS12_G1ADD": 30, "PC_BLS12_G1MSM": 2000, "PC_BLS12_G2ADD": 50, "PC_BLS12_G2MSM": 4000,
+	"PC_BLS12_PAIRING_CHECK": 8000, "PC_BLS12_MAP_FP_TO_G1": 300, "PC_BLS12_MAP_FP2_TO_G2": 1000,
+}
+
+// WorkModel weights executed opcodes and precompile calls by their CPU cost, in
+// arbitrary work units. Weights are merged over defaultWorkWeights, and opcodes
+// without a weight count DefaultWeight units (1 if unset).
+type WorkModel struct {
+	Weights       map[string]float64 `json:"weights,omitempty"`
+	DefaultWeight float64            `json:"defaultWeight,omitempty"`
+}
+
+// weight returns the work units of one execution of the named opcode or precompile.
+func (m *WorkModel) weight(name string) float64 {
+	if m != nil {
+		if w, ok := m.Weights[name]; ok {
+			return w
+		}
+	}
+
+	if w, ok := defaultWorkWeights[name]; ok {
+		return w
+	}
+
+	if m != nil && m.DefaultWeight > 0 {
+		return m.DefaultWeight
+	}
+
+	return 1
+}
+
+// validate rejects negative weights.
+func (m *WorkModel) validate() error {
+	if m == nil {
+		return nil
+	}
+
+	if m.DefaultWeight < 0 {
+		return fmt.Errorf("default weight must not be negative")
+	}
+
+	for name, w := range m.Weights {
+		if w < 0 {
+			return fmt.Errorf("weight of %s must not be negative", name)
+		}
+	}
+
+	return nil
+}
+
+// BlockWork is the estimated EVM work of one execution of a block.
+type BlockWork struct {
+	GasUsed         uint64  `json:"gasUsed"`
+	Opcodes         uint64  `json:"opcodes"`         // Opcodes executed
+	PrecompileCalls uint64  `json:"precompileCalls"` // Precompile calls made
+	WorkUnits       float64 `json:"workUnits"`       // Opcodes and precompile calls weighted by the work model
+}
+
+// add accumulates count executions of the named opcode or precompile.
+func (w *BlockWork) add(name string, count uint64, model *WorkModel) {
+	if count == 0 {
+		return
+	}
+
+	if strings.HasPrefix(name, "PC_") {
+		w.PrecompileCalls += count
+	} else {
+		w.Opcodes += count
+	}
+
+	w.WorkUnits += float64(count) * model.weight(name)
+}
+
+// EstimateBlockWorkResult is the result of xatu_estimateBlockWork.
+//
+// Work units are a proxy for block processing cost, not a measurement: they count
+// what the EVM executed, weighted by a static per-opcode model, and ignore input
+// sizes, caching and I/O latency.
+type EstimateBlockWorkResult struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	TxCount     int    `json:"txCount"`
+	// GasLimit is the gas limit every transaction ran with in the Raised execution.
+	GasLimit uint64 `json:"gasLimit"`
+	// Original runs each transaction with its own gas limit; Raised with GasLimit,
+	// so transactions that ran out of gas on chain execute further.
+	Original BlockWork `json:"original"`
+	Raised   BlockWork `json:"raised"`
+	// WorkRatio is Raised.WorkUnits / Original.WorkUnits (0 when Original is 0).
+	WorkRatio float64 `json:"workRatio"`
+}
+
+// EstimateBlockWork re-executes a block with every transaction's gas limit raised
+// to gasLimitOverride (the block's gas limit if nil), as with MaxGasLimit, and
+// estimates the EVM work of both executions by counting opcodes weighted by the
+// work model (see WorkModel). It approximates how much more processing the block
+// could need under a higher gas limit; it does not measure processing time.
+func (s *Service) EstimateBlockWork(
+	ctx context.Context,
+	blockNumber uint64,
+	gasLimitOverride *uint64,
+	model *WorkModel,
+) (*EstimateBlockWorkResult, error) {
+	if gasLimitOverride != nil && *gasLimitOverride == 0 {
+		return nil, fmt.Errorf("gas limit override must be positive")
+	}
+
+	if err := model.validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	block, err := s.blockReader.BlockByNumber(ctx, tx, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block: %w", err)
+	}
+
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+
+	header := block.Header()
+	opts := executionOptions{MaxGasLimit: true}
+
+	if gasLimitOverride != nil {
+		opts.GasLimit = *gasLimitOverride
+	}
+
+	if err := s.checkSupportedFork(ctx, opts, blockNumber, header.Time); err != nil {
+		return nil, err
+	}
+
+	result := &EstimateBlockWorkResult{
+		BlockNumber: blockNumber,
+		TxCount:     len(block.Transactions()),
+		GasLimit:    opts.txGasLimit(header.GasLimit),
+	}
+
+	txNumReader := s.txNumsReader(ctx)
+	counts := make(map[string]OpcodeSummary, 64)
+
+	for txIndex := range block.Transactions() {
+		dualResult, err := s.executeTransactionDual(ctx, tx, header, block, txIndex, txNumReader, opts, SimulationTracerConfig{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute tx %d: %w", txIndex, err)
+		}
+
+		result.Original.GasUsed += dualResult.Original.GasUsed
+		result.Raised.GasUsed += dualResult.Simulated.GasUsed
+
+		for name, summary := range dualResult.OpcodeBreakdown {
+			existing := counts[name]
+			existing.merge(summary)
+			counts[name] = existing
+		}
+	}
+
+	result.addWork(counts, model)
+
+	return result, nil
+}
+
+// addWork weights the block's opcode counts by the work model. Names are visited in
+// sorted order so that the floating-point sums are reproducible.
+func (r *EstimateBlockWorkResult) addWork(counts map[string]OpcodeSummary, model *WorkModel) {
+	for _, name := range slices.Sorted(maps.Keys(counts)) {
+		r.Original.add(name, counts[name].OriginalCount, model)
+		r.Raised.add(name, counts[name].SimulatedCount, model)
+	}
+
+	if r.Original.WorkUnits > 0 {
+		r.WorkRatio = r.Raised.WorkUnits / r.Original.WorkUnits
+	}
+}
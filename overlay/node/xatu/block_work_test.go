@@ -0,0 +1,130 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"testing"
+)
+
+// TestWorkModelWeight checks that caller weights override the defaults, and that
+// unlisted opcodes fall back to DefaultWeight, then 1.
+func TestWorkModelWeight(t *testing.T) {
+	var defaults *WorkModel
+
+	if got := defaults.weight("SLOAD"); got != defaultWorkWeights["SLOAD"] {
+		t.Errorf("nil model SLOAD = %v, want %v", got, defaultWorkWeights["SLOAD"])
+	}
+
+	if got := defaults.weight("ADD"); got != 1 {
+		t.Errorf("nil model ADD = %v, want 1", got)
+	}
+
+	model := &WorkModel{Weights: map[string]float64{"SLOAD": 200, "PC_ID": 0}, DefaultWeight: 0.5}
+
+	tests := map[string]float64{
+		"SLOAD":  200,
+		"PC_ID":  0,
+		"SSTORE": defaultWorkWeights["SSTORE"],
+		"ADD":    0.5,
+	}
+
+	for name, want := range tests {
+		if got := model.weight(name); got != want {
+			t.Errorf("weight(%s) = %v, want %v", name, got, want)
+		}
+	}
+
+	if err := model.validate(); err != nil {
+		t.Errorf("validate: %v", err)
+	}
+
+	for _, bad := range []*WorkModel{
+		{DefaultWeight: -1},
+		{Weights: map[string]float64{"SLOAD": -1}},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) accepted a negative weight", bad)
+		}
+	}
+}
+
+// TestBlockWorkAggregation weights opcode counts, keeps precompile calls separate
+// and reports the raised execution's work relative to the original.
+func TestBlockWorkAggregation(t *testing.T) {
+	model := &WorkModel{Weights: map[string]float64{"SLOAD": 10, "PC_SHA256": 4}}
+	counts := map[string]OpcodeSummary{
+		"ADD":       {OriginalCount: 100, SimulatedCount: 300},
+		"SLOAD":     {OriginalCount: 5, SimulatedCount: 15},
+		"PC_SHA256": {OriginalCount: 2, SimulatedCount: 2},
+		"MUL":       {SimulatedCount: 10}, // only reached with the raised limit
+	}
+
+	result := &EstimateBlockWorkResult{}
+	result.addWork(counts, model)
+
+	wantOriginal := BlockWork{Opcodes: 105, PrecompileCalls: 2, WorkUnits: 100 + 50 + 8}
+	wantRaised := BlockWork{Opcodes: 325, PrecompileCalls: 2, WorkUnits: 300 + 150 + 8 + 10}
+
+	if result.Original != wantOriginal {
+		t.Errorf("original = %+v, want %+v", result.Original, wantOriginal)
+	}
+
+	if result.Raised != wantRaised {
+		t.Errorf("raised = %+v, want %+v", result.Raised, wantRaised)
+	}
+
+	if want := wantRaised.WorkUnits / wantOriginal.WorkUnits; result.WorkRatio != want {
+		t.Errorf("work ratio = %v, want %v", result.WorkRatio, want)
+	}
+
+	empty := &EstimateBlockWorkResult{}
+	empty.addWork(nil, nil)
+
+	if empty.WorkRatio != 0 {
+		t.Errorf("empty work ratio = %v, want 0", empty.WorkRatio)
+	}
+}
+
+// TestTxGasLimit checks that MaxGasLimit raises to GasLimit when set, otherwise to
+// the block's gas limit.
+func TestTxGasLimit(t *testing.T) {
+	if got := (executionOptions{MaxGasLimit: true}).txGasLimit(30_000_000); got != 30_000_000 {
+		t.Errorf("default = %d, want the block gas limit", got)
+	}
+
+	if got := (executionOptions{MaxGasLimit: true, GasLimit: 100_000_000}).txGasLimit(30_000_000); got != 100_000_000 {
+		t.Errorf("override = %d, want 100000000", got)
+	}
+}
+
+// TestEstimateBlockWorkValidation checks the requests rejected before any block
+// is read.
+func TestEstimateBlockWorkValidation(t *testing.T) {
+	s := &Service{}
+	zero := uint64(0)
+
+	if _, err := s.EstimateBlockWork(context.Background(), 1, &zero, nil); err == nil {
+		t.Error("expected an error for a zero gas limit override")
+	}
+
+	if _, err := s.EstimateBlockWork(context.Background(), 1, nil, &WorkModel{DefaultWeight: -1}); err == nil {
+		t.Error("expected an error for a negative weight")
+	}
+}
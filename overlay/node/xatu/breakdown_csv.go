@@ -0,0 +1,84 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Opcode breakdown output formats accepted by SimulateBlockGasRequest.Format.
+const (
+	breakdownFormatJSON = "json"
+	breakdownFormatCSV  = "csv"
+)
+
+// breakdownCSVHeader is the header row of the CSV opcode breakdown.
+var breakdownCSVHeader = []string{"opcode", "originalCount", "originalGas", "simulatedCount", "simulatedGas", "deltaGas"}
+
+// validateBreakdownFormat rejects unknown opcode breakdown formats. Empty means JSON.
+func validateBreakdownFormat(format string) error {
+	switch format {
+	case "", breakdownFormatJSON, breakdownFormatCSV:
+		return nil
+	default:
+		return fmt.Errorf("unsupported format %q (expected %q or %q)", format, breakdownFormatJSON, breakdownFormatCSV)
+	}
+}
+
+// formatBreakdownCSV renders an opcode breakdown as CSV with a header row, one row
+// per opcode sorted by name. deltaGas is simulatedGas - originalGas and may be negative.
+func formatBreakdownCSV(breakdown map[string]OpcodeSummary) (string, error) {
+	opcodes := make([]string, 0, len(breakdown))
+	for op := range breakdown {
+		opcodes = append(opcodes, op)
+	}
+
+	sort.Strings(opcodes)
+
+	var sb strings.Builder
+
+	w := csv.NewWriter(&sb)
+	if err := w.Write(breakdownCSVHeader); err != nil {
+		return "", err
+	}
+
+	for _, op := range opcodes {
+		s := breakdown[op]
+
+		row := []string{
+			op,
+			strconv.FormatUint(s.OriginalCount, 10),
+			strconv.FormatUint(s.OriginalGas, 10),
+			strconv.FormatUint(s.SimulatedCount, 10),
+			strconv.FormatUint(s.SimulatedGas, 10),
+			strconv.FormatInt(int64(s.SimulatedGas)-int64(s.OriginalGas), 10),
+		}
+		if err := w.Write(row); err != nil {
+			return "", err
+		}
+	}
+
+	w.Flush()
+
+	return sb.String(), w.Error()
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestFormatBreakdownCSV verifies that the CSV breakdown has a header row and parses
+// back to the same counts and gas, with a signed delta column.
+func TestFormatBreakdownCSV(t *testing.T) {
+	breakdown := map[string]OpcodeSummary{
+		"SLOAD":  {OriginalCount: 3, OriginalGas: 6300, SimulatedCount: 3, SimulatedGas: 15000},
+		"ADD":    {OriginalCount: 10, OriginalGas: 30, SimulatedCount: 10, SimulatedGas: 30},
+		"SSTORE": {OriginalCount: 1, OriginalGas: 20000, SimulatedCount: 1, SimulatedGas: 5000},
+	}
+
+	out, err := formatBreakdownCSV(breakdown)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+
+	if len(records) != len(breakdown)+1 {
+		t.Fatalf("got %d rows, want header + %d", len(records), len(breakdown))
+	}
+
+	if !reflect.DeepEqual(records[0], breakdownCSVHeader) {
+		t.Errorf("header = %v, want %v", records[0], breakdownCSVHeader)
+	}
+
+	parse := func(s string) uint64 {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			t.Fatalf("invalid number %q: %v", s, err)
+		}
+
+		return v
+	}
+
+	var opcodes []string
+
+	for _, row := range records[1:] {
+		opcodes = append(opcodes, row[0])
+
+		got := OpcodeSummary{
+			OriginalCount:  parse(row[1]),
+			OriginalGas:    parse(row[2]),
+			SimulatedCount: parse(row[3]),
+			SimulatedGas:   parse(row[4]),
+		}
+		if want := breakdown[row[0]]; got != want {
+			t.Errorf("%s round-tripped as %+v, want %+v", row[0], got, want)
+		}
+
+		delta, err := strconv.ParseInt(row[5], 10, 64)
+		if err != nil {
+			t.Fatalf("invalid delta %q: %v", row[5], err)
+		}
+
+		if want := int64(got.SimulatedGas) - int64(got.OriginalGas); delta != want {
+			t.Errorf("%s deltaGas = %d, want %d", row[0], delta, want)
+		}
+	}
+
+	if want := []string{"ADD", "SLOAD", "SSTORE"}; !reflect.DeepEqual(opcodes, want) {
+		t.Errorf("row order = %v, want %v", opcodes, want)
+	}
+}
+
+// TestValidateBreakdownFormat verifies that only JSON (the default) and CSV are accepted.
+func TestValidateBreakdownFormat(t *testing.T) {
+	for _, format := range []string{"", "json", "csv"} {
+		if err := validateBreakdownFormat(format); err != nil {
+			t.Errorf("format %q rejected: %v", format, err)
+		}
+	}
+
+	if err := validateBreakdownFormat("xml"); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/hex"
+
+	"github.com/erigontech/erigon/execution/tracing"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// CallClassification counts CALL-family opcodes (CALL, CALLCODE, DELEGATECALL,
+// STATICCALL) by the EIP-2929 state of their target. Self-calls are always warm,
+// so workloads dominated by them (e.g. proxies calling back into themselves) are
+// barely affected by a CALL_COLD override, while ones with many cold targets are.
+type CallClassification struct {
+	Self uint64 `json:"self"` // Target is the calling contract
+	Warm uint64 `json:"warm"` // Target was already accessed in this transaction
+	Cold uint64 `json:"cold"` // First access to the target
+}
+
+// CallClassifications holds the call classification of both executions.
+type CallClassifications struct {
+	Original  CallClassification `json:"original"`
+	Simulated CallClassification `json:"simulated"`
+}
+
+// callClassifier tracks which addresses are warm during a transaction and
+// classifies call targets against that set. The warm set mirrors the EIP-2929
+// access list: the sender, recipient, precompiles, coinbase (from Shanghai) and tx
+// access list start warm, and every accessed address becomes warm.
+type callClassifier struct {
+	warm   map[string]struct{}
+	counts CallClassification
+}
+
+// newCallClassifier creates an empty call classifier.
+func newCallClassifier() *callClassifier {
+	return &callClassifier{
+		warm: make(map[string]struct{}, 16),
+	}
+}
+
+// warmAddress marks an address as accessed.
+func (c *callClassifier) warmAddress(addr string) {
+	c.warm[normalizeAddress(addr)] = struct{}{}
+}
+
+// startTx seeds the warm set with the precompiles, the coinbase (see warmCoinbase)
+// and the transaction's access list.
+func (c *callClassifier) startTx(txn erigontypes.Transaction, precompiles vm.PrecompiledContracts, coinbase string) {
+	for addr := range precompiles {
+		c.warmAddress(addr.String())
+	}
+
+	if coinbase != "" {
+		c.warmAddress(coinbase)
+	}
+
+	if txn == nil {
+		return
+	}
+
+	for _, tuple := range txn.GetAccessList() {
+		c.warmAddress(tuple.Address.String())
+	}
+}
+
+// enter classifies a call frame. The top-level frame warms the sender and
+// recipient; CREATE frames warm the new contract without being classified.
+func (c *callClassifier) enter(depth int, typ byte, from, to string) {
+	from = normalizeAddress(from)
+	to = normalizeAddress(to)
+
+	if depth == 0 {
+		c.warmAddress(from)
+		c.warmAddress(to)
+		return
+	}
+
+	switch typ {
+	case 0xF1, 0xF2, 0xF4, 0xFA: // CALL, CALLCODE, DELEGATECALL, STATICCALL
+		_, warm := c.warm[to]
+
+		switch {
+		case to == from:
+			c.counts.Self++
+		case warm:
+			c.counts.Warm++
+		default:
+			c.counts.Cold++
+		}
+	}
+
+	c.warmAddress(to)
+}
+
+// recordOpcode warms the address accessed by a non-call opcode. Call targets are
+// warmed in enter, after they have been classified.
+func (c *callClassifier) recordOpcode(opcode byte, scope tracing.OpContext) {
+	switch opcode {
+	case 0x31, 0x3B, 0x3C, 0x3F, 0xFF: // BALANCE, EXTCODESIZE, EXTCODECOPY, EXTCODEHASH, SELFDESTRUCT
+		stack := scope.StackData()
+		if len(stack) > 0 {
+			addr := stack[len(stack)-1].Bytes20()
+			c.warmAddress("0x" + hex.EncodeToString(addr[:]))
+		}
+	}
+}
+
+// reset clears the warm set and counts.
+func (c *callClassifier) reset() {
+	clear(c.warm)
+	c.counts = CallClassification{}
+}
+
+// combineCallClassifications pairs the call classification of both tracers, or
+// returns nil if classification is disabled.
+func combineCallClassifications(original, simulated *SimulationTracer) *CallClassifications {
+	o, s := original.GetCallClassification(), simulated.GetCallClassification()
+	if o == nil || s == nil {
+		return nil
+	}
+
+	return &CallClassifications{Original: *o, Simulated: *s}
+}
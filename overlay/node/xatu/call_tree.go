@@ -0,0 +1,118 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"errors"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// Call frame statuses.
+const (
+	callStatusSuccess  = "success"
+	callStatusReverted = "reverted" // REVERT opcode
+	callStatusFailed   = "failed"   // Any other error, e.g. out of gas
+)
+
+// CallFrame is a call frame of an execution's call tree, in the style of the
+// callTracer output. The top-level frame is the transaction itself.
+type CallFrame struct {
+	Type    string       `json:"type"` // CALL, STATICCALL, DELEGATECALL, CALLCODE, CREATE, CREATE2 or SELFDESTRUCT
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Gas     uint64       `json:"gas"`     // Gas available to the frame
+	GasUsed uint64       `json:"gasUsed"` // Gas used by the frame, including its calls
+	Status  string       `json:"status"`  // "success", "reverted" or "failed"
+	Error   string       `json:"error,omitempty"`
+	Calls   []*CallFrame `json:"calls,omitempty"`
+}
+
+// CallTrees holds the call trees of both executions.
+type CallTrees struct {
+	Original  *CallFrame `json:"original"`
+	Simulated *CallFrame `json:"simulated"`
+}
+
+// callTreeBuilder builds an execution's call tree from the tracer's frame hooks.
+type callTreeBuilder struct {
+	root  *CallFrame
+	stack []*CallFrame // Frames entered and not yet exited
+}
+
+// newCallTreeBuilder creates an empty call tree builder.
+func newCallTreeBuilder() *callTreeBuilder {
+	return &callTreeBuilder{stack: make([]*CallFrame, 0, 16)}
+}
+
+// enter opens a frame as a call of the current frame, or as the root.
+func (b *callTreeBuilder) enter(typ, from, to string, gas uint64) {
+	frame := &CallFrame{Type: typ, From: from, To: to, Gas: gas}
+
+	if len(b.stack) == 0 {
+		b.root = frame
+	} else {
+		parent := b.stack[len(b.stack)-1]
+		parent.Calls = append(parent.Calls, frame)
+	}
+
+	b.stack = append(b.stack, frame)
+}
+
+// exit closes the current frame with the gas it used and its outcome.
+func (b *callTreeBuilder) exit(gasUsed uint64, err error, reverted bool) {
+	if len(b.stack) == 0 {
+		return
+	}
+
+	frame := b.stack[len(b.stack)-1]
+	b.stack = b.stack[:len(b.stack)-1]
+
+	frame.GasUsed = gasUsed
+
+	switch {
+	case err == nil && !reverted:
+		frame.Status = callStatusSuccess
+	case err == nil || errors.Is(err, vm.ErrExecutionReverted):
+		frame.Status = callStatusReverted
+	default:
+		frame.Status = callStatusFailed
+	}
+
+	if err != nil {
+		frame.Error = err.Error()
+	}
+}
+
+// reset clears the builder for the next transaction.
+func (b *callTreeBuilder) reset() {
+	b.root = nil
+	b.stack = b.stack[:0]
+}
+
+// combineCallTrees pairs the call trees of both tracers, or returns nil if call
+// tree tracking is disabled.
+func combineCallTrees(original, simulated *SimulationTracer) *CallTrees {
+	o, s := original.GetCallTree(), simulated.GetCallTree()
+	if o == nil || s == nil {
+		return nil
+	}
+
+	return &CallTrees{Original: o, Simulated: s}
+}
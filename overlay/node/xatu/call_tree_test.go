@@ -0,0 +1,89 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestCallTreeBuilder replays the frame hooks of a transaction that calls a router,
+// which staticcalls an oracle and delegatecalls a library that reverts, and then
+// calls a token that runs out of gas.
+func TestCallTreeBuilder(t *testing.T) {
+	const (
+		eoa     = "0x00000000000000000000000000000000000000e0"
+		router  = "0x00000000000000000000000000000000000000a0"
+		oracle  = "0x00000000000000000000000000000000000000b0"
+		library = "0x00000000000000000000000000000000000000c0"
+		token   = "0x00000000000000000000000000000000000000d0"
+	)
+
+	b := newCallTreeBuilder()
+
+	b.enter("CALL", eoa, router, 100000)
+	b.enter("STATICCALL", router, oracle, 60000)
+	b.enter("DELEGATECALL", oracle, library, 40000)
+	b.exit(1200, vm.ErrExecutionReverted, true)
+	b.exit(8000, nil, false)
+	b.enter("CALL", router, token, 20000)
+	b.exit(20000, vm.ErrOutOfGas, true)
+	b.exit(45000, nil, false)
+
+	want := &CallFrame{
+		Type: "CALL", From: eoa, To: router, Gas: 100000, GasUsed: 45000, Status: callStatusSuccess,
+		Calls: []*CallFrame{
+			{
+				Type: "STATICCALL", From: router, To: oracle, Gas: 60000, GasUsed: 8000, Status: callStatusSuccess,
+				Calls: []*CallFrame{
+					{
+						Type: "DELEGATECALL", From: oracle, To: library, Gas: 40000, GasUsed: 1200,
+						Status: callStatusReverted, Error: vm.ErrExecutionReverted.Error(),
+					},
+				},
+			},
+			{
+				Type: "CALL", From: router, To: token, Gas: 20000, GasUsed: 20000,
+				Status: callStatusFailed, Error: vm.ErrOutOfGas.Error(),
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(b.root, want) {
+		t.Errorf("call tree = %+v, want %+v", b.root, want)
+	}
+
+	// A revert reported without an error is still a revert
+	b.reset()
+	b.enter("CALL", eoa, router, 100000)
+	b.exit(30000, nil, true)
+
+	if b.root.Status != callStatusReverted || b.root.Error != "" || len(b.stack) != 0 {
+		t.Errorf("reverted root = %+v", b.root)
+	}
+
+	// Unmatched exits are ignored
+	b.exit(1, errors.New("unexpected"), false)
+	if b.root.GasUsed != 30000 {
+		t.Errorf("unmatched exit changed the root: %+v", b.root)
+	}
+}
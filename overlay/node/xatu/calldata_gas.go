@@ -0,0 +1,46 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// calcCalldataGas returns the calldata part of intrinsic gas: TX_DATA_ZERO per zero
+// byte plus TX_DATA_NONZERO per non-zero byte, using the fork's costs for keys the
+// schedule does not override. It is the standard data cost, as vm.CalldataGas
+// computes it for intrinsic gas; the EIP-7623 floor, when it applies, is not part
+// of it.
+func calcCalldataGas(data []byte, chainRules *chain.Rules, schedule *CustomGasSchedule) uint64 {
+	return vm.CalldataGas(schedule.ToVMGasSchedule(), data, chainRules.IsIstanbul)
+}
+
+// calcFloorGas returns the EIP-7623 calldata floor of a message under the schedule's
+// TX_BASE and TX_FLOOR_PER_TOKEN, or 0 before Prague.
+func calcFloorGas(data []byte, chainRules *chain.Rules, schedule *CustomGasSchedule) uint64 {
+	if !chainRules.IsPrague {
+		return 0
+	}
+
+	_, floor := vm.CalcCustomIntrinsicGas(schedule.ToVMGasSchedule(), data, 0, 0, false,
+		chainRules.IsHomestead, chainRules.IsIstanbul, chainRules.IsShanghai, true, false, 0)
+
+	return floor
+}
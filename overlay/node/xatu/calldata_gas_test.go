@@ -0,0 +1,85 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+func TestCalcCalldataGas(t *testing.T) {
+	istanbul := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true}
+
+	// 3 zero bytes and 5 non-zero bytes
+	data := []byte{0x00, 0x01, 0x00, 0xff, 0x02, 0x00, 0x03, 0x04}
+
+	if got := calcCalldataGas(data, istanbul, nil); got != 3*4+5*16 {
+		t.Errorf("Istanbul calldata gas = %d, want %d", got, 3*4+5*16)
+	}
+
+	frontier := &chain.Rules{}
+	if got := calcCalldataGas(data, frontier, nil); got != 3*4+5*68 {
+		t.Errorf("Frontier calldata gas = %d, want %d", got, 3*4+5*68)
+	}
+
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{
+		vm.GasKeyTxDataZero:    1,
+		vm.GasKeyTxDataNonZero: 100,
+	}}
+	if got := calcCalldataGas(data, istanbul, schedule); got != 3*1+5*100 {
+		t.Errorf("overridden calldata gas = %d, want %d", got, 3*1+5*100)
+	}
+
+	// A partial override keeps the fork cost for the other byte kind
+	delete(schedule.Overrides, vm.GasKeyTxDataZero)
+	if got := calcCalldataGas(data, istanbul, schedule); got != 3*4+5*100 {
+		t.Errorf("partially overridden calldata gas = %d, want %d", got, 3*4+5*100)
+	}
+
+	// The split is reported as part of the transaction's intrinsic gas
+	detail := newTxGasDetail(&executionResult{GasUsed: 30000, IntrinsicGas: 21000 + 3*4 + 5*100, CalldataGas: 3*4 + 5*100})
+	if detail.CalldataGas != 512 || detail.IntrinsicGas-detail.CalldataGas != 21000 {
+		t.Errorf("detail calldata gas = %d of intrinsic %d, want 512 of 21512", detail.CalldataGas, detail.IntrinsicGas)
+	}
+}
+
+func TestCalcFloorGas(t *testing.T) {
+	// 3 zero bytes and 5 non-zero bytes: 3 + 5*4 = 23 tokens
+	data := []byte{0x00, 0x01, 0x00, 0xff, 0x02, 0x00, 0x03, 0x04}
+
+	prague := &chain.Rules{IsHomestead: true, IsIstanbul: true, IsBerlin: true, IsLondon: true,
+		IsShanghai: true, IsCancun: true, IsPrague: true}
+	if got := calcFloorGas(data, prague, nil); got != 21000+23*10 {
+		t.Errorf("Prague floor = %d, want %d", got, 21000+23*10)
+	}
+
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{vm.GasKeyTxFloorPerToken: 20}}
+	if got := calcFloorGas(data, prague, schedule); got != 21000+23*20 {
+		t.Errorf("overridden floor = %d, want %d", got, 21000+23*20)
+	}
+
+	cancun := &chain.Rules{IsHomestead: true, IsIstanbul: true, IsBerlin: true, IsLondon: true,
+		IsShanghai: true, IsCancun: true}
+	if got := calcFloorGas(data, cancun, nil); got != 0 {
+		t.Errorf("Cancun floor = %d, want 0", got)
+	}
+}
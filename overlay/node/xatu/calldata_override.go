@@ -0,0 +1,61 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/erigontech/erigon/execution/protocol"
+)
+
+// calldataMessage replaces the calldata of a message. Sender, value, target,
+// nonce and gas limit are unchanged, so the transaction replays against the same
+// state with a different input. The signature no longer matches the data, which
+// is harmless since ApplyMessage does not verify it.
+type calldataMessage struct {
+	protocol.Message
+	data []byte
+}
+
+// Data returns the replacement calldata.
+func (m calldataMessage) Data() []byte {
+	return m.data
+}
+
+// parseCalldataOverride decodes a hex calldata override. A nil override returns
+// nil (the transaction's own calldata is used), while "0x" replaces it with
+// empty calldata.
+func parseCalldataOverride(override *string) ([]byte, error) {
+	if override == nil {
+		return nil, nil
+	}
+
+	data, err := hex.DecodeString(strings.TrimPrefix(*override, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid calldata override: %w", err)
+	}
+
+	if data == nil {
+		data = []byte{}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+)
+
+// TestCalldataOverrideExecution checks through executeMessage that a calldata
+// override runs the transaction as if it had been sent with that calldata: the
+// contract stores its first calldata word, and the gas used and calldata gas match
+// a call made with the override directly.
+func TestCalldataOverrideExecution(t *testing.T) {
+	run := func(data []byte, opts executionOptions) *executionResult {
+		c := newTestChain(t, map[common.Address][]byte{testContract: storeCalldata})
+		return c.call(testContract, data, opts)
+	}
+
+	override := word(0)
+
+	overridden := run(word(1), executionOptions{Calldata: override})
+	direct := run(override, executionOptions{})
+	original := run(word(1), executionOptions{})
+
+	if overridden.GasUsed != direct.GasUsed || overridden.CalldataGas != direct.CalldataGas {
+		t.Errorf("overridden call used %d gas (%d calldata), want %d (%d) as when sent with the override",
+			overridden.GasUsed, overridden.CalldataGas, direct.GasUsed, direct.CalldataGas)
+	}
+
+	if overridden.GasUsed == original.GasUsed {
+		t.Errorf("overridden call used %d gas, the same as the original calldata", overridden.GasUsed)
+	}
+}
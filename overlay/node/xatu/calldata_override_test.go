@@ -0,0 +1,90 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+func TestParseCalldataOverride(t *testing.T) {
+	if data, err := parseCalldataOverride(nil); err != nil || data != nil {
+		t.Errorf("nil override = %x, %v, want nil", data, err)
+	}
+
+	empty := "0x"
+	if data, err := parseCalldataOverride(&empty); err != nil || data == nil || len(data) != 0 {
+		t.Errorf("empty override = %x (nil %v), %v, want non-nil empty calldata", data, data == nil, err)
+	}
+
+	transfer := "0xa9059cbb"
+	if data, err := parseCalldataOverride(&transfer); err != nil || !bytes.Equal(data, []byte{0xa9, 0x05, 0x9c, 0xbb}) {
+		t.Errorf("override = %x, %v, want a9059cbb", data, err)
+	}
+
+	invalid := "0xzz"
+	if _, err := parseCalldataOverride(&invalid); err == nil {
+		t.Error("expected error for invalid hex")
+	}
+}
+
+// TestCalldataOverrideGasProfiles runs the same transaction with two calldatas and
+// verifies that both executions of each run see the override and that the two
+// inputs produce different intrinsic gas (zero bytes are cheaper than nonzero).
+func TestCalldataOverrideGasProfiles(t *testing.T) {
+	istanbul := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true}
+
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 100}}
+
+	run := func(calldata []byte) *dualExecutionResult {
+		opts := executionOptions{GasSchedule: schedule, Calldata: calldata}
+
+		execute := func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+			if !bytes.Equal(opts.Calldata, calldata) {
+				t.Errorf("execution calldata = %x, want %x", opts.Calldata, calldata)
+			}
+
+			msg := calldataMessage{data: opts.Calldata}
+			intrinsic := calcIntrinsicGas(msg.Data(), nil, false, istanbul, opts.GasSchedule)
+
+			return &executionResult{GasUsed: intrinsic, IntrinsicGas: intrinsic, Status: "success"}, nil
+		}
+
+		result, err := runDualExecution(opts, SimulationTracerConfig{}, execute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	zeros := run([]byte{0, 0, 0, 0})
+	nonzero := run([]byte{1, 2, 3, 4})
+
+	if zeros.Original.IntrinsicGas != 21000+4*4 {
+		t.Errorf("zero-byte calldata intrinsic gas = %d, want %d", zeros.Original.IntrinsicGas, 21000+4*4)
+	}
+
+	if nonzero.Original.IntrinsicGas != 21000+4*16 {
+		t.Errorf("nonzero calldata intrinsic gas = %d, want %d", nonzero.Original.IntrinsicGas, 21000+4*16)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+// Chain config sources reported in simulation results.
+const (
+	chainConfigSourceDB       = "database" // Read from the database (see chainConfigForExecution)
+	chainConfigSourceMemory   = "memory"   // The config passed at init, when the DB read failed
+	chainConfigSourceOverride = "override" // The request's ChainConfigOverride
+)
+
+// chainConfigCheckInterval is how often the database chain config is compared with
+// the in-memory config.
+const chainConfigCheckInterval = 10 * time.Minute
+
+// ChainConfigStatus is the result of xatu_chainConfigStatus.
+type ChainConfigStatus struct {
+	// Source is the config used for execution: "database", or "memory" if the
+	// database config could not be read.
+	Source string `json:"source"`
+	// Diverged lists the chain config fields (by JSON name) whose database value
+	// differs from the in-memory config, e.g. a fork time changed after startup.
+	// Checked at startup and every chainConfigCheckInterval.
+	Diverged []string `json:"diverged"`
+	// Error is the database read error when Source is "memory".
+	Error string `json:"error,omitempty"`
+}
+
+// ChainConfigStatus compares the in-memory chain config with the one read from the
+// database, which simulations execute with. A divergence means results could
+// differ from what the node's own execution uses the in-memory config for.
+func (s *Service) ChainConfigStatus(ctx context.Context) *ChainConfigStatus {
+	s.chainConfigForExecution(ctx)
+
+	s.chainConfigMu.Lock()
+	diverged := s.chainConfigDiff
+	s.chainConfigMu.Unlock()
+
+	status := &ChainConfigStatus{
+		Source:   chainConfigSourceMemory,
+		Diverged: diverged,
+	}
+
+	if s.dbChainConfig != nil {
+		status.Source = chainConfigSourceDB
+	}
+
+	if s.dbChainConfigErr != nil {
+		status.Error = s.dbChainConfigErr.Error()
+	}
+
+	if status.Diverged == nil {
+		status.Diverged = []string{}
+	}
+
+	return status
+}
+
+// runChainConfigCheck checks the chain config now and then every
+// chainConfigCheckInterval until ctx is done.
+func (s *Service) runChainConfigCheck(ctx context.Context) {
+	s.checkChainConfig(ctx)
+
+	ticker := time.NewTicker(chainConfigCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkChainConfig(ctx)
+		}
+	}
+}
+
+// checkChainConfig reads the chain config from the database and records how it
+// differs from the in-memory config. A failed read keeps the previous result.
+func (s *Service) checkChainConfig(ctx context.Context) {
+	cc, err := s.readDBChainConfig(ctx)
+	if err != nil {
+		s.log.Warn("Failed to read chain config from DB for the consistency check", "err", err)
+		return
+	}
+
+	s.recordChainConfigDiff(cc)
+}
+
+// recordChainConfigDiff records the fields where a database chain config differs
+// from the in-memory config, warning when they change.
+func (s *Service) recordChainConfigDiff(cc *chain.Config) {
+	diff := diffChainConfigs(s.chainConfig, cc)
+
+	s.chainConfigMu.Lock()
+	changed := !slices.Equal(diff, s.chainConfigDiff)
+	s.chainConfigDiff = diff
+	s.chainConfigMu.Unlock()
+
+	if changed && len(diff) > 0 {
+		s.log.Warn("Chain config in DB differs from in-memory config, simulations use the DB config",
+			"fields", diff)
+	}
+}
+
+// chainConfigSource returns which chain config an execution with opts uses.
+// The database config must already have been loaded (see chainConfigFor).
+func (s *Service) chainConfigSource(opts executionOptions) string {
+	switch {
+	case opts.ChainConfig != nil:
+		return chainConfigSourceOverride
+	case s.dbChainConfig != nil:
+		return chainConfigSourceDB
+	default:
+		return chainConfigSourceMemory
+	}
+}
+
+// diffChainConfigs returns the sorted JSON field names whose values differ between
+// two chain configs. Comparing the encoded form covers every fork block and time
+// without listing them, so new forks are picked up automatically.
+func diffChainConfigs(a, b *chain.Config) []string {
+	if a == nil || b == nil {
+		return nil
+	}
+
+	fieldsA, errA := chainConfigFields(a)
+	fieldsB, errB := chainConfigFields(b)
+	if errA != nil || errB != nil {
+		return nil
+	}
+
+	var diverged []string
+
+	for _, key := range slices.Sorted(maps.Keys(fieldsA)) {
+		if !bytes.Equal(fieldsA[key], fieldsB[key]) {
+			diverged = append(diverged, key)
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(fieldsB)) {
+		if _, ok := fieldsA[key]; !ok {
+			diverged = append(diverged, key)
+		}
+	}
+
+	slices.Sort(diverged)
+
+	return diverged
+}
+
+// chainConfigFields encodes a chain config as a map of its top-level JSON fields.
+func chainConfigFields(cfg *chain.Config) (map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/erigontech/erigon/common/log/v3"
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+// TestChainConfigDivergence loads a database config whose Prague time differs from
+// the in-memory config, and verifies that the divergence is reported, that a later
+// check clears it, and that fork rules are resolved with the database config.
+func TestChainConfigDivergence(t *testing.T) {
+	memoryPrague, dbPrague := uint64(100), uint64(200)
+
+	memory := &chain.Config{ChainID: big.NewInt(1), PragueTime: &memoryPrague}
+	db := &chain.Config{ChainID: big.NewInt(1), PragueTime: &dbPrague}
+
+	if diff := diffChainConfigs(memory, memory); len(diff) != 0 {
+		t.Errorf("identical configs diverge on %v", diff)
+	}
+
+	s := &Service{chainConfig: memory, log: log.New()}
+
+	// Simulate the lazy DB load and a check, so neither reads the DB
+	s.dbChainConfigOnce.Do(func() { s.dbChainConfig = db })
+	s.recordChainConfigDiff(db)
+
+	status := s.ChainConfigStatus(context.Background())
+	if status.Source != chainConfigSourceDB {
+		t.Errorf("source = %q, want %q", status.Source, chainConfigSourceDB)
+	}
+
+	if want := []string{"pragueTime"}; !slices.Equal(status.Diverged, want) {
+		t.Errorf("diverged = %v, want %v", status.Diverged, want)
+	}
+
+	// A later check sees the database config back in line
+	s.recordChainConfigDiff(memory)
+
+	if diverged := s.ChainConfigStatus(context.Background()).Diverged; len(diverged) != 0 {
+		t.Errorf("diverged after the configs match = %v, want none", diverged)
+	}
+
+	// At time 150 Prague is active in memory but not in the DB config
+	cfg := s.chainConfigFor(context.Background(), executionOptions{})
+	if cfg != db {
+		t.Fatal("chainConfigFor did not return the DB config")
+	}
+
+	if cfg.Rules(1, 150).IsPrague {
+		t.Error("fork rules resolved with the in-memory config, want the DB config")
+	}
+
+	if got := s.chainConfigSource(executionOptions{}); got != chainConfigSourceDB {
+		t.Errorf("chainConfigSource = %q, want %q", got, chainConfigSourceDB)
+	}
+
+	if got := s.chainConfigSource(executionOptions{ChainConfig: memory}); got != chainConfigSourceOverride {
+		t.Errorf("chainConfigSource with override = %q, want %q", got, chainConfigSourceOverride)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"reflect"
+
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+// chainRulesFlags reflects the boolean flags of rules (IsBerlin, IsLondon, ...)
+// into a map keyed by field name, so results report exactly which fork rules a
+// simulation ran under. Flags added upstream are picked up without changes here.
+func chainRulesFlags(rules *chain.Rules) map[string]bool {
+	v := reflect.ValueOf(rules).Elem()
+
+	flags := make(map[string]bool, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.IsExported() && field.Type.Kind() == reflect.Bool {
+			flags[field.Name] = v.Field(i).Bool()
+		}
+	}
+
+	return flags
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+// TestChainRulesFlags checks the flags reported for a block after Cancun, on a
+// chain that has not scheduled Prague.
+func TestChainRulesFlags(t *testing.T) {
+	cfg := &chain.Config{
+		ChainID:               big.NewInt(1),
+		HomesteadBlock:        big.NewInt(0),
+		TangerineWhistleBlock: big.NewInt(0),
+		SpuriousDragonBlock:   big.NewInt(0),
+		ByzantiumBlock:        big.NewInt(0),
+		ConstantinopleBlock:   big.NewInt(0),
+		PetersburgBlock:       big.NewInt(0),
+		IstanbulBlock:         big.NewInt(0),
+		BerlinBlock:           big.NewInt(0),
+		LondonBlock:           big.NewInt(0),
+		ShanghaiTime:          big.NewInt(10_000),
+		CancunTime:            big.NewInt(20_000),
+	}
+
+	flags := chainRulesFlags(cfg.Rules(1000, 25_000))
+
+	for _, name := range []string{"IsHomestead", "IsByzantium", "IsIstanbul", "IsBerlin", "IsLondon", "IsShanghai", "IsCancun"} {
+		if active, ok := flags[name]; !ok || !active {
+			t.Errorf("%s = %v (present %v), want true", name, active, ok)
+		}
+	}
+
+	for _, name := range []string{"IsPrague", "IsOsaka"} {
+		if active, ok := flags[name]; !ok || active {
+			t.Errorf("%s = %v (present %v), want false", name, active, ok)
+		}
+	}
+
+	// The flags agree with the fork the rules resolve to
+	if fork := forkOrder[forkIndex(cfg.Rules(1000, 25_000))].name; fork != "cancun" {
+		t.Errorf("rules resolve to fork %s, want cancun", fork)
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The compact schedule encoding is a transport optimization for embedding a
+// CustomGasSchedule in URLs or QR codes; the JSON form stays canonical. Layout,
+// base64url-encoded without padding:
+//
+//	version (1 byte)
+//	bitmap length (uvarint), presence bitmap over compactScheduleKeys (LSB first)
+//	values of the present keys (uvarint each, in compactScheduleKeys order)
+//	extra key count (uvarint), then per key: name length (uvarint), name, value (uvarint)
+//
+// Keys outside compactScheduleKeys (e.g. LINEAR_<OP>_* models) go in the extra
+// section by name, so any schedule round-trips losslessly.
+const compactScheduleVersion = 1
+
+// compactScheduleKeys fixes the bit position of each known gas key. It is
+// append-only: reordering or removing a key breaks previously encoded schedules.
+var compactScheduleKeys = []string{
+	"ADD", "SUB", "MUL", "DIV", "SDIV", "MOD", "SMOD", "ADDMOD", "MULMOD", "EXP_BYTE",
+	"SIGNEXTEND", "LT", "GT", "SLT", "SGT", "EQ", "ISZERO", "AND", "OR", "XOR", "NOT",
+	"BYTE", "SHL", "SHR", "SAR", "CLZ", "POP", "PUSH0", "PUSH1", "PUSH2", "PUSH3", "PUSH4",
+	"PUSH5", "PUSH6", "PUSH7", "PUSH8", "PUSH9", "PUSH10", "PUSH11", "PUSH12", "PUSH13",
+	"PUSH14", "PUSH15", "PUSH16", "PUSH17", "PUSH18", "PUSH19", "PUSH20", "PUSH21",
+	"PUSH22", "PUSH23", "PUSH24", "PUSH25", "PUSH26", "PUSH27", "PUSH28", "PUSH29",
+	"PUSH30", "PUSH31", "PUSH32", "DUP1", "DUP2", "DUP3", "DUP4", "DUP5", "DUP6", "DUP7",
+	"DUP8", "DUP9", "DUP10", "DUP11", "DUP12", "DUP13", "DUP14", "DUP15", "DUP16", "SWAP1",
+	"SWAP2", "SWAP3", "SWAP4", "SWAP5", "SWAP6", "SWAP7", "SWAP8", "SWAP9", "SWAP10",
+	"SWAP11", "SWAP12", "SWAP13", "SWAP14", "SWAP15", "SWAP16", "MLOAD", "MSTORE",
+	"MSTORE8", "MSIZE", "MCOPY", "MEMORY", "COPY", "SLOAD_COLD", "SLOAD_WARM", "SSTORE_SET",
+	"SSTORE_RESET", "SSTORE_NOOP", "REFUND_CAP_DIV", "TLOAD", "TSTORE", "CALL", "CALLCODE",
+	"DELEGATECALL", "STATICCALL", "CALL_COLD", "DELEGATECALL_COLD", "STATICCALL_COLD",
+	"CALL_VALUE_XFER", "CALL_NEW_ACCOUNT", "CREATE", "CREATE2", "INIT_CODE_WORD",
+	"CREATE_DATA", "CREATE_BY_SELFDESTRUCT", "EXTCODESIZE", "EXTCODECOPY", "EXTCODEHASH",
+	"CODESIZE", "CODECOPY", "CALLDATALOAD", "CALLDATASIZE", "CALLDATACOPY",
+	"RETURNDATASIZE", "RETURNDATACOPY", "BLOCKHASH", "COINBASE", "TIMESTAMP", "NUMBER",
+	"DIFFICULTY", "GASLIMIT", "CHAINID", "BASEFEE", "BLOBBASEFEE", "BLOBHASH", "BALANCE",
+	"SELFBALANCE", "ORIGIN", "CALLER", "CALLVALUE", "ADDRESS", "GASPRICE", "GAS", "JUMP",
+	"JUMPI", "JUMPDEST", "PC", "STOP", "RETURN", "REVERT", "INVALID", "LOG0", "LOG1",
+	"LOG2", "LOG3", "LOG4", "LOG", "LOG_TOPIC", "LOG_DATA", "KECCAK256", "KECCAK256_WORD",
+	"SELFDESTRUCT", "TX_BASE", "TX_CREATE_BASE", "TX_DATA_ZERO", "TX_DATA_NONZERO",
+	"TX_ACCESS_LIST_ADDR", "TX_ACCESS_LIST_KEY", "TX_INIT_CODE_WORD", "TX_FLOOR_PER_TOKEN",
+	"TX_AUTH_COST", "TX_INTRINSIC", "PC_ECREC", "PC_BN254_ADD", "PC_BN254_MUL",
+	"PC_BLS12_G1ADD", "PC_BLS12_G2ADD", "PC_BLS12_MAP_FP_TO_G1", "PC_BLS12_MAP_FP2_TO_G2",
+	"PC_KZG_POINT_EVALUATION", "PC_P256VERIFY", "PC_SHA256_BASE", "PC_SHA256_PER_WORD",
+	"PC_RIPEMD160_BASE", "PC_RIPEMD160_PER_WORD", "PC_ID_BASE", "PC_ID_PER_WORD",
+	"PC_MODEXP_MIN_GAS", "PC_BN254_PAIRING_BASE", "PC_BN254_PAIRING_PER_PAIR",
+	"PC_BLAKE2F_BASE", "PC_BLAKE2F_PER_ROUND", "PC_BLS12_PAIRING_CHECK_BASE",
+	"PC_BLS12_PAIRING_CHECK_PER_PAIR", "PC_BLS12_G1MSM_MUL_GAS", "PC_BLS12_G2MSM_MUL_GAS",
+	"BLOB_GAS_PER_BLOB", "BLOB_BASE_FEE_UPDATE_FRACTION", "TX_SIGNATURE",
+	"PC_MODEXP_MIN_COMPLEXITY", "PC_MODEXP_COMPLEXITY_MUL", "PC_MODEXP_EXP_BYTE_ITERATIONS",
+	"PC_MODEXP_DIVISOR",
+}
+
+// compactScheduleIndex maps a key to its position in compactScheduleKeys.
+var compactScheduleIndex = func() map[string]int {
+	index := make(map[string]int, len(compactScheduleKeys))
+	for i, key := range compactScheduleKeys {
+		index[key] = i
+	}
+
+	return index
+}()
+
+var errCompactScheduleTruncated = errors.New("compact schedule is truncated")
+
+// EncodeCompact returns the compact encoding of the schedule's overrides.
+// A nil schedule encodes as an empty schedule. Relative overrides have no compact
+// form, so a schedule with them returns an error rather than losing them.
+func (c *CustomGasSchedule) EncodeCompact() (string, error) {
+	var overrides map[string]uint64
+	if c != nil {
+		if len(c.RelativeOverrides) > 0 {
+			return "", errors.New("relative overrides cannot be encoded compactly")
+		}
+
+		overrides = c.Overrides
+	}
+
+	bitmap := make([]byte, (len(compactScheduleKeys)+7)/8)
+	values := make([]uint64, 0, len(overrides))
+	extras := make([]string, 0)
+
+	for i, key := range compactScheduleKeys {
+		if value, ok := overrides[key]; ok {
+			bitmap[i/8] |= 1 << (i % 8)
+			values = append(values, value)
+		}
+	}
+
+	for key := range overrides {
+		if _, ok := compactScheduleIndex[key]; !ok {
+			extras = append(extras, key)
+		}
+	}
+
+	sort.Strings(extras)
+
+	// Trailing empty bitmap bytes are implied
+	for len(bitmap) > 0 && bitmap[len(bitmap)-1] == 0 {
+		bitmap = bitmap[:len(bitmap)-1]
+	}
+
+	buf := []byte{compactScheduleVersion}
+	buf = binary.AppendUvarint(buf, uint64(len(bitmap)))
+	buf = append(buf, bitmap...)
+
+	for _, value := range values {
+		buf = binary.AppendUvarint(buf, value)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(extras)))
+	for _, key := range extras {
+		buf = binary.AppendUvarint(buf, uint64(len(key)))
+		buf = append(buf, key...)
+		buf = binary.AppendUvarint(buf, overrides[key])
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// DecodeCompact parses a schedule produced by EncodeCompact.
+func DecodeCompact(s string) (*CustomGasSchedule, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compact schedule: %w", err)
+	}
+
+	if len(buf) == 0 {
+		return nil, errCompactScheduleTruncated
+	}
+
+	if buf[0] != compactScheduleVersion {
+		return nil, fmt.Errorf("unsupported compact schedule version %d", buf[0])
+	}
+
+	r := compactReader{buf: buf[1:]}
+
+	bitmapLen, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	if bitmapLen > uint64((len(compactScheduleKeys)+7)/8) {
+		return nil, fmt.Errorf("compact schedule bitmap has %d bytes, at most %d known", bitmapLen, (len(compactScheduleKeys)+7)/8)
+	}
+
+	bitmap, err := r.bytes(bitmapLen)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]uint64)
+
+	for i := 0; i < len(bitmap)*8; i++ {
+		if bitmap[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+
+		if i >= len(compactScheduleKeys) {
+			return nil, fmt.Errorf("compact schedule references unknown key %d", i)
+		}
+
+		value, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		overrides[compactScheduleKeys[i]] = value
+	}
+
+	extraCount, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	for range extraCount {
+		nameLen, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		name, err := r.bytes(nameLen)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		overrides[string(name)] = value
+	}
+
+	if len(r.buf) != 0 {
+		return nil, fmt.Errorf("compact schedule has %d trailing bytes", len(r.buf))
+	}
+
+	return &CustomGasSchedule{Overrides: overrides}, nil
+}
+
+// compactReader consumes fields from an encoded compact schedule.
+type compactReader struct {
+	buf []byte
+}
+
+// uvarint reads an unsigned varint.
+func (r *compactReader) uvarint() (uint64, error) {
+	value, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		return 0, errCompactScheduleTruncated
+	}
+
+	r.buf = r.buf[n:]
+
+	return value, nil
+}
+
+// bytes reads n raw bytes.
+func (r *compactReader) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(r.buf)) {
+		return nil, errCompactScheduleTruncated
+	}
+
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+
+	return b, nil
+}
+
+// resolveGasSchedule returns the request's gas schedule, decoding the compact form
+// when given. Setting both forms is rejected as ambiguous.
+func resolveGasSchedule(schedule *CustomGasSchedule, compact string) (*CustomGasSchedule, error) {
+	if compact == "" {
+		return schedule, nil
+	}
+
+	if schedule != nil {
+		return nil, fmt.Errorf("gasSchedule and compactSchedule are mutually exclusive")
+	}
+
+	return DecodeCompact(compact)
+}
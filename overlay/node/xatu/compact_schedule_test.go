@@ -0,0 +1,132 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCompactScheduleRoundTrip(t *testing.T) {
+	full := &CustomGasSchedule{Overrides: make(map[string]uint64, len(compactScheduleKeys)+2)}
+	for i, key := range compactScheduleKeys {
+		full.Overrides[key] = uint64(i) * 1000
+	}
+
+	// Keys outside the fixed table and values needing the full varint width
+	full.Overrides["LINEAR_KECCAK256_PER_WORD"] = 7
+	full.Overrides["ADD"] = math.MaxUint64
+
+	tests := []struct {
+		name     string
+		schedule *CustomGasSchedule
+	}{
+		{"full", full},
+		{"single", &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 800}}},
+		{"empty", &CustomGasSchedule{Overrides: map[string]uint64{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := mustEncodeCompact(t, tt.schedule)
+
+			decoded, err := DecodeCompact(encoded)
+			if err != nil {
+				t.Fatalf("DecodeCompact: %v", err)
+			}
+
+			if !reflect.DeepEqual(decoded.Overrides, tt.schedule.Overrides) {
+				t.Errorf("round trip = %v, want %v", decoded.Overrides, tt.schedule.Overrides)
+			}
+		})
+	}
+}
+
+// mustEncodeCompact returns the compact encoding of schedule, failing the test on error.
+func mustEncodeCompact(t *testing.T, schedule *CustomGasSchedule) string {
+	t.Helper()
+
+	encoded, err := schedule.EncodeCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return encoded
+}
+
+// TestEncodeCompactRelativeOverrides verifies a schedule with relative overrides is
+// rejected instead of encoded without them.
+func TestEncodeCompactRelativeOverrides(t *testing.T) {
+	schedule := &CustomGasSchedule{
+		Overrides:         map[string]uint64{"SLOAD_COLD": 800},
+		RelativeOverrides: map[string]RelativeSpec{"SSTORE_RESET": {Base: "SLOAD_COLD", Offset: 100}},
+	}
+
+	if encoded, err := schedule.EncodeCompact(); err == nil {
+		t.Errorf("EncodeCompact() = %q, want an error", encoded)
+	}
+}
+
+func TestDecodeCompactErrors(t *testing.T) {
+	valid := mustEncodeCompact(t, &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 800, "LINEAR_MLOAD_BASE": 3}})
+
+	for _, input := range []string{
+		"",
+		"!!",
+		"Ag",                 // unsupported version
+		valid[:len(valid)-2], // truncated
+		valid + "AA",         // trailing bytes
+	} {
+		if _, err := DecodeCompact(input); err == nil {
+			t.Errorf("DecodeCompact(%q) succeeded, want error", input)
+		}
+	}
+}
+
+// TestCompactScheduleKeys verifies every documented gas key has a fixed position,
+// so new keys are appended to compactScheduleKeys when they are added.
+func TestCompactScheduleKeys(t *testing.T) {
+	if len(compactScheduleIndex) != len(compactScheduleKeys) {
+		t.Fatal("compactScheduleKeys contains duplicates")
+	}
+
+	for key := range gasDescriptions {
+		if _, ok := compactScheduleIndex[key]; !ok {
+			t.Errorf("gas key %s missing from compactScheduleKeys", key)
+		}
+	}
+}
+
+func TestResolveGasSchedule(t *testing.T) {
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 800}}
+
+	if got, err := resolveGasSchedule(schedule, ""); err != nil || got != schedule {
+		t.Errorf("resolveGasSchedule without compact form = %v, %v", got, err)
+	}
+
+	got, err := resolveGasSchedule(nil, mustEncodeCompact(t, schedule))
+	if err != nil || !reflect.DeepEqual(got, schedule) {
+		t.Errorf("resolveGasSchedule(compact) = %v, %v, want %v", got, err, schedule)
+	}
+
+	if _, err := resolveGasSchedule(schedule, mustEncodeCompact(t, schedule)); err == nil {
+		t.Error("expected an error when both forms are set")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/ethpandaops/execution-processor/pkg/ethereum/execution"
+)
+
+// maxTraceDivergences bounds the divergences listed by xatu_compareTrace. Once
+// traces diverge (e.g. on a different branch) every later opcode usually differs.
+const maxTraceDivergences = 100
+
+// ReferenceTrace is a struct log trace in geth's debug_traceTransaction format.
+// Fields that are not compared (stack, memory, storage, ...) are ignored.
+type ReferenceTrace struct {
+	Gas        uint64               `json:"gas"`
+	Failed     bool                 `json:"failed"`
+	StructLogs []ReferenceStructLog `json:"structLogs"`
+}
+
+// ReferenceStructLog is a single opcode of a ReferenceTrace.
+type ReferenceStructLog struct {
+	PC      uint64 `json:"pc"`
+	Op      string `json:"op"`
+	Gas     uint64 `json:"gas"`
+	GasCost uint64 `json:"gasCost"`
+}
+
+// TraceDivergence is a field of an opcode whose value differs between the
+// reference trace and the node's trace.
+type TraceDivergence struct {
+	Index     int    `json:"index"` // Position in the struct logs
+	Field     string `json:"field"` // "pc", "op", "gas" or "gasCost"
+	Reference string `json:"reference"`
+	Actual    string `json:"actual"`
+}
+
+// CompareTraceResult is the result of xatu_compareTrace.
+type CompareTraceResult struct {
+	TransactionHash  string `json:"transactionHash"`
+	Match            bool   `json:"match"` // Gas, status and every opcode agree
+	Opcodes          int    `json:"opcodes"`
+	ReferenceOpcodes int    `json:"referenceOpcodes"`
+	Gas              uint64 `json:"gas"`
+	ReferenceGas     uint64 `json:"referenceGas"`
+	Failed           bool   `json:"failed"`
+	ReferenceFailed  bool   `json:"referenceFailed"`
+	// Divergences lists the first maxTraceDivergences differing fields, in order.
+	Divergences []TraceDivergence `json:"divergences"`
+	Truncated   bool              `json:"truncated,omitempty"`
+}
+
+// CompareTrace traces a transaction as DebugTraceTransaction does and diffs the
+// struct logs against a reference trace produced by geth, opcode by opcode, as a
+// harness for checking that the tracer matches geth.
+//
+// Only pc, op, gas and gasCost are compared, as they are defined the same way by
+// both tracers. GasUsed is computed by this tracer alone and is not compared.
+// The tracer caps gasCost at the gas available to the opcode, while geth reports
+// the full cost of an opcode that runs out of gas, so a reference gasCost above
+// the available gas matches a capped one.
+func (s *Service) CompareTrace(ctx context.Context, txHash string, reference ReferenceTrace) (*CompareTraceResult, error) {
+	if txHash == "" {
+		return nil, fmt.Errorf("transaction hash is required")
+	}
+
+	tracer := NewStructLogTracer(StructLogConfig{
+		DisableStorage: true,
+		DisableStack:   true,
+		DisableMemory:  true,
+	})
+
+	trace, err := s.debugTraceTransaction(ctx, txHash, tracer)
+	if err != nil {
+		return nil, err
+	}
+
+	result := compareTraces(trace, &reference)
+	result.TransactionHash = txHash
+
+	return result, nil
+}
+
+// compareTraces diffs a trace against a reference trace (see CompareTrace).
+func compareTraces(trace *execution.TraceTransaction, reference *ReferenceTrace) *CompareTraceResult {
+	result := &CompareTraceResult{
+		Opcodes:          len(trace.Structlogs),
+		ReferenceOpcodes: len(reference.StructLogs),
+		Gas:              trace.Gas,
+		ReferenceGas:     reference.Gas,
+		Failed:           trace.Failed,
+		ReferenceFailed:  reference.Failed,
+		Divergences:      []TraceDivergence{},
+	}
+
+	diverge := func(index int, field, ref, actual string) {
+		if len(result.Divergences) == maxTraceDivergences {
+			result.Truncated = true
+			return
+		}
+
+		result.Divergences = append(result.Divergences, TraceDivergence{
+			Index: index, Field: field, Reference: ref, Actual: actual,
+		})
+	}
+
+	for i := range min(len(trace.Structlogs), len(reference.StructLogs)) {
+		log, ref := &trace.Structlogs[i], &reference.StructLogs[i]
+
+		if uint64(log.PC) != ref.PC {
+			diverge(i, "pc", strconv.FormatUint(ref.PC, 10), strconv.FormatUint(uint64(log.PC), 10))
+		}
+
+		if log.Op != ref.Op {
+			diverge(i, "op", ref.Op, log.Op)
+		}
+
+		if log.Gas != ref.Gas {
+			diverge(i, "gas", strconv.FormatUint(ref.Gas, 10), strconv.FormatUint(log.Gas, 10))
+		}
+
+		if !gasCostMatches(log.GasCost, log.Gas, ref.GasCost) {
+			diverge(i, "gasCost", strconv.FormatUint(ref.GasCost, 10), strconv.FormatUint(log.GasCost, 10))
+		}
+	}
+
+	result.Match = len(result.Divergences) == 0 &&
+		result.Opcodes == result.ReferenceOpcodes &&
+		result.Gas == result.ReferenceGas &&
+		result.Failed == result.ReferenceFailed
+
+	return result
+}
+
+// gasCostMatches reports whether a gas cost from the tracer matches geth's. The
+// tracer caps the cost of an opcode that runs out of gas at the gas available.
+func gasCostMatches(cost, available, reference uint64) bool {
+	if reference > available {
+		return cost == available
+	}
+
+	return cost == reference
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethpandaops/execution-processor/pkg/ethereum/execution"
+)
+
+// gethReferenceTrace is geth's debug_traceTransaction output for a call that
+// stores 1 into slot 0 and then runs out of gas on a second SSTORE.
+const gethReferenceTrace = `{
+	"gas": 50000,
+	"failed": true,
+	"returnValue": "",
+	"structLogs": [
+		{"pc": 0, "op": "PUSH1", "gas": 28000, "gasCost": 3, "depth": 1, "stack": []},
+		{"pc": 2, "op": "PUSH1", "gas": 27997, "gasCost": 3, "depth": 1, "stack": ["0x1"]},
+		{"pc": 4, "op": "SSTORE", "gas": 27994, "gasCost": 22100, "depth": 1, "stack": ["0x1", "0x0"]},
+		{"pc": 5, "op": "PUSH1", "gas": 5894, "gasCost": 3, "depth": 1, "stack": []},
+		{"pc": 7, "op": "PUSH1", "gas": 5891, "gasCost": 3, "depth": 1, "stack": ["0x2"]},
+		{"pc": 9, "op": "SSTORE", "gas": 5888, "gasCost": 22100, "depth": 1, "stack": ["0x2", "0x1"]}
+	]
+}`
+
+// TestCompareTraces verifies that a matching trace has no divergences, including
+// an out-of-gas opcode whose cost the tracer caps, and that differing fields are
+// reported by position.
+func TestCompareTraces(t *testing.T) {
+	var reference ReferenceTrace
+	if err := json.Unmarshal([]byte(gethReferenceTrace), &reference); err != nil {
+		t.Fatalf("failed to decode reference trace: %v", err)
+	}
+
+	trace := &execution.TraceTransaction{
+		Gas:    50000,
+		Failed: true,
+		Structlogs: []execution.StructLog{
+			{PC: 0, Op: "PUSH1", Gas: 28000, GasCost: 3, GasUsed: 3, Depth: 1},
+			{PC: 2, Op: "PUSH1", Gas: 27997, GasCost: 3, GasUsed: 3, Depth: 1},
+			{PC: 4, Op: "SSTORE", Gas: 27994, GasCost: 22100, GasUsed: 22100, Depth: 1},
+			{PC: 5, Op: "PUSH1", Gas: 5894, GasCost: 3, GasUsed: 3, Depth: 1},
+			{PC: 7, Op: "PUSH1", Gas: 5891, GasCost: 3, GasUsed: 3, Depth: 1},
+			{PC: 9, Op: "SSTORE", Gas: 5888, GasCost: 5888, GasUsed: 5888, Depth: 1}, // Capped at the gas available
+		},
+	}
+
+	result := compareTraces(trace, &reference)
+	if !result.Match || len(result.Divergences) != 0 {
+		t.Fatalf("expected traces to match, got %+v", result)
+	}
+
+	// A repriced SSTORE shifts the gas of every later opcode
+	trace.Structlogs[2].GasCost = 20000
+	for i := 3; i < len(trace.Structlogs); i++ {
+		trace.Structlogs[i].Gas += 2100
+	}
+	trace.Structlogs[5].GasCost = trace.Structlogs[5].Gas
+
+	result = compareTraces(trace, &reference)
+	if result.Match {
+		t.Fatal("expected traces to diverge")
+	}
+
+	want := []TraceDivergence{
+		{Index: 2, Field: "gasCost", Reference: "22100", Actual: "20000"},
+		{Index: 3, Field: "gas", Reference: "5894", Actual: "7994"},
+		{Index: 4, Field: "gas", Reference: "5891", Actual: "7991"},
+		{Index: 5, Field: "gas", Reference: "5888", Actual: "7988"}, // Both run out of gas, so the costs match
+	}
+
+	if len(result.Divergences) != len(want) {
+		t.Fatalf("got %d divergences, want %d: %+v", len(result.Divergences), len(want), result.Divergences)
+	}
+
+	for i := range want {
+		if result.Divergences[i] != want[i] {
+			t.Errorf("divergence %d = %+v, want %+v", i, result.Divergences[i], want[i])
+		}
+	}
+
+	// A missing opcode is reported through the counts
+	trace.Structlogs = trace.Structlogs[:5]
+	if result = compareTraces(trace, &reference); result.Match || result.Opcodes != 5 || result.ReferenceOpcodes != 6 {
+		t.Errorf("truncated trace: match = %v, opcodes = %d/%d", result.Match, result.Opcodes, result.ReferenceOpcodes)
+	}
+}
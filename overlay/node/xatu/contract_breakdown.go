@@ -0,0 +1,54 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+// ContractGas is the gas used by a contract's frames in both executions.
+// Gas is attributed to the contract whose code runs in the frame (the call's
+// target), so a DELEGATECALL is attributed to the implementation, not the proxy.
+type ContractGas struct {
+	OriginalGas  uint64 `json:"originalGas"`
+	SimulatedGas uint64 `json:"simulatedGas"`
+}
+
+// combineContractBreakdowns merges the per-contract gas of both tracers, or
+// returns nil if contract tracking was not enabled.
+func combineContractBreakdowns(original, simulated *SimulationTracer) map[string]ContractGas {
+	originalGas := original.GetContractBreakdown()
+	simulatedGas := simulated.GetContractBreakdown()
+
+	if originalGas == nil || simulatedGas == nil {
+		return nil
+	}
+
+	result := make(map[string]ContractGas, len(originalGas))
+
+	for addr, gas := range originalGas {
+		entry := result[addr]
+		entry.OriginalGas = gas
+		result[addr] = entry
+	}
+
+	for addr, gas := range simulatedGas {
+		entry := result[addr]
+		entry.SimulatedGas = gas
+		result[addr] = entry
+	}
+
+	return result
+}
@@ -19,6 +19,8 @@
 package xatu
 
 import (
+	"maps"
+
 	"github.com/erigontech/erigon/execution/chain"
 	"github.com/erigontech/erigon/execution/protocol/params"
 	"github.com/erigontech/erigon/execution/vm"
@@ -29,6 +31,10 @@ import (
 // Any key not present uses the default value from the current fork.
 type CustomGasSchedule struct {
 	Overrides map[string]uint64 `json:"overrides,omitempty"`
+	// RelativeOverrides set keys relative to another key's final value (see
+	// RelativeSpec). They are resolved into Overrides against the block's fork
+	// before execution.
+	RelativeOverrides map[string]RelativeSpec `json:"relativeOverrides,omitempty"`
 }
 
 // GasParameter represents a single gas parameter with its value and description.
@@ -148,7 +154,7 @@ var gasDescriptions = map[string]string{
 	"MSTORE8": "Store 1 byte to memory. Base cost only; memory expansion charged separately via MEMORY.",
 	"MSIZE":   "Get current memory size in bytes. Fixed cost.",
 	"MCOPY":   "Copy memory regions. Base cost only. Total = MCOPY + (COPY × words) + memory expansion. To change per-word cost, modify COPY instead.",
-	"MEMORY":  "Linear coefficient for memory expansion. Total cost = MEMORY × words + words²÷512. Applies to all memory-expanding operations. The quadratic part is fixed.",
+	"MEMORY":  "Linear coefficient for memory expansion. Total cost = MEMORY × words + words²÷512. Applies to all memory-expanding operations without a MEMORY_<OPCODE> override. The quadratic part is fixed.",
 	"COPY":    "Per-word (32 bytes) cost for ALL copy operations. Affects: CALLDATACOPY, CODECOPY, EXTCODECOPY, RETURNDATACOPY, MCOPY. Change this to adjust copy costs globally.",
 
 	// Storage
@@ -156,19 +162,25 @@ var gasDescriptions = map[string]string{
 	"SLOAD_WARM":   "Reading storage slot already accessed in transaction. Post-Berlin (EIP-2929).",
 	"SSTORE_SET":   "Writing to a storage slot that was zero (creating new storage).",
 	"SSTORE_RESET": "Writing to a storage slot that was non-zero (modifying existing storage).",
+	"SSTORE_NOOP":  "Writing a storage slot's current value back (no change). Follows SLOAD_WARM unless set. Post-Berlin (EIP-2929).",
+
+	// Refunds
+	"REFUND_CAP_DIV": "Refund cap divisor: refunds are capped at gas used ÷ REFUND_CAP_DIV (5 since London, EIP-3529; 2 before). Applied when reporting net gas; 0 disables refunds.",
 
 	// Transient Storage
 	"TLOAD":  "Load from transient storage. Cleared after transaction. (EIP-1153)",
 	"TSTORE": "Store to transient storage. Cleared after transaction. (EIP-1153)",
 
 	// Contract Calls
-	"CALL":             "Base cost for CALL. This is the warm access cost; first access to an address adds CALL_COLD.",
-	"CALLCODE":         "Base cost for CALLCODE. This is the warm access cost; first access to an address adds CALL_COLD.",
-	"DELEGATECALL":     "Base cost for DELEGATECALL. This is the warm access cost; first access to an address adds CALL_COLD.",
-	"STATICCALL":       "Base cost for STATICCALL. This is the warm access cost; first access to an address adds CALL_COLD.",
-	"CALL_COLD":        "Additional cost when calling an address not yet accessed in transaction. Post-Berlin (EIP-2929).",
-	"CALL_VALUE_XFER":  "Additional cost when CALL transfers ETH value.",
-	"CALL_NEW_ACCOUNT": "Additional cost when CALL sends value to a non-existent account, creating it.",
+	"CALL":              "Base cost for CALL. This is the warm access cost; first access to an address adds CALL_COLD.",
+	"CALLCODE":          "Base cost for CALLCODE. This is the warm access cost; first access to an address adds CALL_COLD.",
+	"DELEGATECALL":      "Base cost for DELEGATECALL. This is the warm access cost; first access to an address adds CALL_COLD.",
+	"STATICCALL":        "Base cost for STATICCALL. This is the warm access cost; first access to an address adds CALL_COLD.",
+	"CALL_COLD":         "Additional cost when calling an address not yet accessed in transaction. Post-Berlin (EIP-2929).",
+	"DELEGATECALL_COLD": "Cold access cost for DELEGATECALL targets. Follows CALL_COLD unless set. Post-Berlin (EIP-2929).",
+	"STATICCALL_COLD":   "Cold access cost for STATICCALL targets. Follows CALL_COLD unless set. Post-Berlin (EIP-2929).",
+	"CALL_VALUE_XFER":   "Additional cost when CALL transfers ETH value.",
+	"CALL_NEW_ACCOUNT":  "Additional cost when CALL sends value to a non-existent account, creating it.",
 
 	// Contract Creation
 	"CREATE":                 "Base cost only. Total = CREATE + (INIT_CODE_WORD × words) + memory expansion + (CREATE_DATA × code bytes).",
@@ -252,6 +264,10 @@ var gasDescriptions = map[string]string{
 	"TX_AUTH_COST":        "Per authorization in EIP-7702 SetCode transactions (25,000 gas). Prague+.",
 	"TX_INTRINSIC":        "Total intrinsic gas charged before EVM execution. Sum of TX_BASE + calldata costs + access list costs.",
 
+	// Blob gas (EIP-4844, priced outside the EVM)
+	"BLOB_GAS_PER_BLOB":             "Blob gas consumed per blob (131,072). Cancun+. Blob fee = blobs × BLOB_GAS_PER_BLOB × blob base fee.",
+	"BLOB_BASE_FEE_UPDATE_FRACTION": "Denominator of the blob base fee formula: fee = fake_exponential(1, excess blob gas, fraction). Lower values make the fee react faster to excess blob gas. Cancun+.",
+
 	// Precompiles - Fixed gas
 	"PC_ECREC":                "ECRECOVER precompile. Signature recovery. Fixed cost.",
 	"PC_BN254_ADD":            "BN254 point addition (alt_bn128). Fixed cost.",
@@ -271,6 +287,10 @@ var gasDescriptions = map[string]string{
 	"PC_ID_BASE":                      "Identity (data copy) base cost. Total = base + per_word * ceil(len/32).",
 	"PC_ID_PER_WORD":                  "Identity per-word (32 bytes) cost.",
 	"PC_MODEXP_MIN_GAS":               "MODEXP minimum gas (floor). Complex formula result is clamped to at least this value.",
+	"PC_MODEXP_MIN_COMPLEXITY":        "MODEXP minimum multiplication complexity (EIP-7883: 16). Setting any MODEXP formula key prices MODEXP by the formula, with unset keys at their EIP-2565 values.",
+	"PC_MODEXP_COMPLEXITY_MUL":        "MODEXP multiplier on the words² complexity when base or modulus exceeds 32 bytes (EIP-2565: 1, EIP-7883: 2).",
+	"PC_MODEXP_EXP_BYTE_ITERATIONS":   "MODEXP iterations charged per exponent byte beyond 32 (EIP-2565: 8, EIP-7883: 16).",
+	"PC_MODEXP_DIVISOR":               "MODEXP divisor of complexity × iterations (EIP-2565: 3, EIP-7883: 1).",
 	"PC_BN254_PAIRING_BASE":           "BN254 pairing check base cost. Total = base + per_pair * pairs.",
 	"PC_BN254_PAIRING_PER_PAIR":       "BN254 per-pair cost.",
 	"PC_BLAKE2F_BASE":                 "BLAKE2F base cost. Total = base + per_round * rounds.",
@@ -294,8 +314,8 @@ func GasScheduleForRules(rules *chain.Rules) *CustomGasSchedule {
 	jt := vm.GetBaseJumpTable(rules)
 	for i := 0; i < 256; i++ {
 		opcode := vm.OpCode(i)
-		if op := jt[opcode]; op != nil {
-			if gas := op.GetConstantGas(); gas > 0 || opcode == vm.STOP || opcode == vm.JUMPDEST {
+		if jt.IsDefined(opcode) {
+			if gas := jt[opcode].GetConstantGas(); gas > 0 || opcode == vm.STOP || opcode == vm.JUMPDEST {
 				schedule.Overrides[opcode.String()] = gas
 			}
 		}
@@ -325,6 +345,10 @@ func GasScheduleForRules(rules *chain.Rules) *CustomGasSchedule {
 		schedule.Overrides[vm.GasKeySloadCold] = params.ColdSloadCostEIP2929
 		schedule.Overrides[vm.GasKeySloadWarm] = params.WarmStorageReadCostEIP2929
 		schedule.Overrides[vm.GasKeyCallCold] = params.ColdAccountAccessCostEIP2929
+		// DELEGATECALL_COLD and STATICCALL_COLD are left out: unset, they follow
+		// CALL_COLD, so a schedule built from these defaults that raises CALL_COLD
+		// raises every call variant's cold cost. SSTORE_NOOP follows SLOAD_WARM
+		// the same way.
 		// Note: CALL_WARM is intentionally omitted from API response.
 		// The warm cost for CALL variants is controlled by their JumpTable constant gas
 		// (CALL, STATICCALL, DELEGATECALL, CALLCODE sliders). CALL_WARM only affects
@@ -340,6 +364,8 @@ func GasScheduleForRules(rules *chain.Rules) *CustomGasSchedule {
 		schedule.Overrides[vm.GasKeySstoreReset] = params.SstoreResetGasEIP2200
 	}
 
+	schedule.Overrides[vm.GasKeyRefundCapDiv] = refundQuotient(rules.IsLondon)
+
 	// Intrinsic gas defaults
 	schedule.Overrides[vm.GasKeyTxBase] = params.TxGas
 	schedule.Overrides[vm.GasKeyTxCreateBase] = params.TxGasContractCreation
@@ -361,6 +387,14 @@ func GasScheduleForRules(rules *chain.Rules) *CustomGasSchedule {
 		schedule.Overrides[vm.GasKeyTxAuthCost] = params.PerEmptyAccountCost
 	}
 
+	// Blob gas defaults. Blob parameter forks after Prague set the update fraction in
+	// the chain config, which the simulation uses; this is the fork's base value.
+	if rules.IsCancun {
+		blob := defaultBlobPricingForRules(rules)
+		schedule.Overrides[GasKeyBlobGasPerBlob] = blob.GasPerBlob
+		schedule.Overrides[GasKeyBlobBaseFeeUpdateFraction] = blob.UpdateFraction
+	}
+
 	// Precompile gas defaults (fork-aware)
 	precompiles := vm.Precompiles(rules)
 	for _, p := range precompiles {
@@ -375,9 +409,14 @@ func GasScheduleForRules(rules *chain.Rules) *CustomGasSchedule {
 			schedule.Overrides[vm.GasKeyPCIdBase] = params.IdentityBaseGas
 			schedule.Overrides[vm.GasKeyPCIdPerWord] = params.IdentityPerWordGas
 		case "MODEXP":
-			if rules.IsOsaka {
-				schedule.Overrides[vm.GasKeyPCModexpMinGas] = 500
-			} else {
+			// The formula parameters describe EIP-2565 onwards; EIP-198 pricing
+			// before Berlin only has the min gas key
+			switch {
+			case rules.IsOsaka:
+				maps.Copy(schedule.Overrides, modexpEIP7883)
+			case rules.IsBerlin:
+				maps.Copy(schedule.Overrides, modexpEIP2565)
+			default:
 				schedule.Overrides[vm.GasKeyPCModexpMinGas] = 200
 			}
 		case "BN254_PAIRING":
@@ -424,13 +463,32 @@ func GasScheduleResponseForRules(rules *chain.Rules) *GasScheduleResponse {
 	return response
 }
 
+// gasScheduleForBlock returns the default gas schedule in effect for a block. Fork
+// activation is inclusive: the block at a fork's activation number or timestamp
+// already runs under the new fork's rules.
+//
+// The blob base fee update fraction is the chain config's at the block, which blob
+// parameter forks change without new fork rules, as the simulation uses it.
+func gasScheduleForBlock(cfg *chain.Config, blockNum, blockTime uint64) *GasScheduleResponse {
+	response := GasScheduleResponseForRules(cfg.Rules(blockNum, blockTime))
+
+	if param, ok := response.Parameters[GasKeyBlobBaseFeeUpdateFraction]; ok {
+		param.Value = cfg.GetBlobGasPriceUpdateFraction(blockTime)
+		response.Parameters[GasKeyBlobBaseFeeUpdateFraction] = param
+	}
+
+	return response
+}
+
 // HasOverrides returns true if any custom values have been set.
 func (c *CustomGasSchedule) HasOverrides() bool {
-	return c != nil && len(c.Overrides) > 0
+	return c != nil && (len(c.Overrides) > 0 || len(c.RelativeOverrides) > 0)
 }
 
 // ToVMGasSchedule converts CustomGasSchedule to vm.GasSchedule.
 // The vm.GasSchedule is used by patched gas functions via GetOr().
+// Only concrete overrides are carried over; relative overrides must be resolved
+// first (see resolveFor).
 func (c *CustomGasSchedule) ToVMGasSchedule() *vm.GasSchedule {
 	if c == nil || len(c.Overrides) == 0 {
 		return nil
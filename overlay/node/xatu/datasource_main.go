@@ -27,6 +27,7 @@ import (
 	"github.com/ethpandaops/execution-processor/pkg/ethereum/execution"
 
 	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/db/kv"
 	"github.com/erigontech/erigon/db/kv/kvcache"
 	"github.com/erigontech/erigon/db/rawdb"
 	"github.com/erigontech/erigon/execution/chain"
@@ -63,6 +64,31 @@ func (s *Service) receiptsGenerator() *receipts.Generator {
 	return s.receiptsGen
 }
 
+// blockReceipts returns the receipts for a block. Receipts are served from the RCache
+// domain when it holds the full set; otherwise they are regenerated through the
+// receipts generator (same path as the eth_getBlockReceipts RPC), which re-executes
+// the block. rawdb.ReadReceiptsCacheV2 alone returns an empty/short list (with a nil
+// error) for blocks whose receipts are not cached, which silently breaks the
+// transaction processor when it backfills behind the receipt-cache window.
+func (s *Service) blockReceipts(ctx context.Context, tx kv.TemporalTx, block *erigontypes.Block) (erigontypes.Receipts, error) {
+	recs, _, err := receiptsWithFallback(len(block.Transactions()),
+		func() (erigontypes.Receipts, error) {
+			return rawdb.ReadReceiptsCacheV2(tx, block, s.blockReader.TxnumReader())
+		},
+		func() (erigontypes.Receipts, error) {
+			commitmentHistory, _, err := rawdb.ReadDBCommitmentHistoryEnabled(tx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read commitment history flag: %w", err)
+			}
+
+			return s.receiptsGenerator().GetReceipts(ctx, s.chainConfigForExecution(ctx), tx, block,
+				eth.ReceiptsOpts{CommitmentHistoryEnabled: commitmentHistory})
+		},
+	)
+
+	return recs, err
+}
+
 // Compile-time check that Service implements DataSource interface.
 var _ execution.DataSource = (*Service)(nil)
 
@@ -73,24 +99,11 @@ var _ execution.DataSource = (*Service)(nil)
 // Falls back to the in-memory config if the DB read fails.
 func (s *Service) chainConfigForExecution(ctx context.Context) *chain.Config {
 	s.dbChainConfigOnce.Do(func() {
-		tx, err := s.db.BeginTemporalRo(ctx)
+		cc, err := s.readDBChainConfig(ctx)
 		if err != nil {
 			s.dbChainConfigErr = err
 			return
 		}
-		defer tx.Rollback()
-
-		genesisHash, ok, err := s.blockReader.CanonicalHash(ctx, tx, 0)
-		if err != nil || !ok {
-			s.dbChainConfigErr = fmt.Errorf("failed to read genesis hash: %w (ok=%v)", err, ok)
-			return
-		}
-
-		cc, err := rawdb.ReadChainConfig(tx, genesisHash)
-		if err != nil || cc == nil {
-			s.dbChainConfigErr = fmt.Errorf("failed to read chain config from DB: %w", err)
-			return
-		}
 
 		s.dbChainConfig = cc
 
@@ -114,6 +127,28 @@ func (s *Service) chainConfigForExecution(ctx context.Context) *chain.Config {
 	return s.chainConfig
 }
 
+// readDBChainConfig reads the chain config stored in the database for the genesis
+// block.
+func (s *Service) readDBChainConfig(ctx context.Context) (*chain.Config, error) {
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	genesisHash, ok, err := s.blockReader.CanonicalHash(ctx, tx, 0)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("failed to read genesis hash: %w (ok=%v)", err, ok)
+	}
+
+	cc, err := rawdb.ReadChainConfig(tx, genesisHash)
+	if err != nil || cc == nil {
+		return nil, fmt.Errorf("failed to read chain config from DB: %w", err)
+	}
+
+	return cc, nil
+}
+
 // BlockNumber returns the current block number.
 func (s *Service) BlockNumber(ctx context.Context) (*uint64, error) {
 	tx, err := s.db.BeginTemporalRo(ctx)
@@ -205,19 +240,7 @@ func (s *Service) BlockReceipts(ctx context.Context, number *big.Int) ([]executi
 		return nil, fmt.Errorf("block %d not found", number)
 	}
 
-	// Use the receipts generator (same path as the eth_getBlockReceipts RPC):
-	// it serves receipts from the RCache domain when present and re-executes the
-	// block to regenerate them on a cache miss. rawdb.ReadReceiptsCacheV2 alone
-	// returns an empty/short list (with a nil error) for historical blocks whose
-	// receipts have aged out of the cache, which silently breaks the transaction
-	// processor when it backfills behind the receipt-cache window.
-	commitmentHistory, _, err := rawdb.ReadDBCommitmentHistoryEnabled(tx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read commitment history flag: %w", err)
-	}
-
-	recs, err := s.receiptsGenerator().GetReceipts(ctx, s.chainConfigForExecution(ctx), tx, block,
-		eth.ReceiptsOpts{CommitmentHistoryEnabled: commitmentHistory})
+	recs, err := s.blockReceipts(ctx, tx, block)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get receipts for block %d: %w", number, err)
 	}
@@ -253,14 +276,7 @@ func (s *Service) TransactionReceipt(ctx context.Context, hash string) (executio
 		return nil, fmt.Errorf("block %d not found for transaction %s", blockNum, hash)
 	}
 
-	// Regenerate receipts on an RCache-domain miss (see BlockReceipts).
-	commitmentHistory, _, err := rawdb.ReadDBCommitmentHistoryEnabled(tx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read commitment history flag: %w", err)
-	}
-
-	recs, err := s.receiptsGenerator().GetReceipts(ctx, s.chainConfigForExecution(ctx), tx, block,
-		eth.ReceiptsOpts{CommitmentHistoryEnabled: commitmentHistory})
+	recs, err := s.blockReceipts(ctx, tx, block)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get receipts for block %d: %w", blockNum, err)
 	}
@@ -291,6 +307,27 @@ func (s *Service) DebugTraceTransaction(
 	hash string,
 	blockNumber *big.Int,
 	opts execution.TraceOptions,
+) (*execution.TraceTransaction, error) {
+	tracer := NewStructLogTracer(StructLogConfig{
+		DisableStorage:   opts.DisableStorage,
+		DisableStack:     opts.DisableStack,
+		DisableMemory:    opts.DisableMemory,
+		EnableReturnData: opts.EnableReturnData,
+	})
+
+	start := time.Now()
+	trace, err := s.debugTraceTransaction(ctx, hash, tracer)
+	s.logTraceTransaction(hash, trace, time.Since(start), err)
+
+	return trace, err
+}
+
+// debugTraceTransaction implements DebugTraceTransaction, tracing the transaction
+// with the given tracer.
+func (s *Service) debugTraceTransaction(
+	ctx context.Context,
+	hash string,
+	tracer *StructLogTracer,
 ) (*execution.TraceTransaction, error) {
 	tx, err := s.db.BeginTemporalRo(ctx)
 	if err != nil {
@@ -374,14 +411,6 @@ func (s *Service) DebugTraceTransaction(
 		m.SetCheckNonce(false)
 	}
 
-	// Create structlog tracer
-	tracer := NewStructLogTracer(StructLogConfig{
-		DisableStorage:   opts.DisableStorage,
-		DisableStack:     opts.DisableStack,
-		DisableMemory:    opts.DisableMemory,
-		EnableReturnData: opts.EnableReturnData,
-	})
-
 	// Get the transaction for OnTxStart callback
 	txn := block.Transactions()[txIndex]
 
@@ -452,6 +481,9 @@ func (s *Service) executeWithTracer(
 		NoBaseFee: true,
 	})
 
+	// Let the tracer halt execution early (see StructLogConfig.StopAfterOpcodes)
+	tracer.abort = evm.Cancel
+
 	// Call OnTxStart to initialize the tracer with the VM context.
 	// This is required for the tracer to capture refund values via GetRefund().
 	hooks := tracer.Hooks()
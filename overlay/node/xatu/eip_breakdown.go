@@ -0,0 +1,115 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "strings"
+
+// EIPGas is the gas attributed to one EIP in both executions.
+type EIPGas struct {
+	OriginalGas  uint64 `json:"originalGas"`
+	SimulatedGas uint64 `json:"simulatedGas"`
+}
+
+// eipBase buckets costs from the original fee schedule, and costs without a
+// single defining EIP (e.g. intrinsic gas).
+const eipBase = "base"
+
+// entryEIPs maps opcode breakdown entries to the EIP that defines their cost in
+// current forks. This is the EIP that introduced the opcode, or the one that
+// repriced it: state access opcodes are attributed to EIP-2929 as a whole, since
+// their cost is its warm or cold access charge. Unlisted entries fall under eipBase.
+var entryEIPs = map[string]string{
+	// State access (EIP-2929 cold/warm access)
+	"SLOAD":        "EIP-2929",
+	"BALANCE":      "EIP-2929",
+	"EXTCODESIZE":  "EIP-2929",
+	"EXTCODECOPY":  "EIP-2929",
+	"EXTCODEHASH":  "EIP-2929",
+	"CALL":         "EIP-2929",
+	"CALLCODE":     "EIP-2929",
+	"DELEGATECALL": "EIP-2929",
+	"STATICCALL":   "EIP-2929",
+
+	// Net gas metering for SSTORE
+	"SSTORE": "EIP-2200",
+
+	// Opcodes introduced after Frontier
+	"SHL":            "EIP-145",
+	"SHR":            "EIP-145",
+	"SAR":            "EIP-145",
+	"REVERT":         "EIP-140",
+	"RETURNDATASIZE": "EIP-211",
+	"RETURNDATACOPY": "EIP-211",
+	"CREATE2":        "EIP-1014",
+	"CHAINID":        "EIP-1344",
+	"SELFBALANCE":    "EIP-1884",
+	"BASEFEE":        "EIP-3198",
+	"PUSH0":          "EIP-3855",
+	"TLOAD":          "EIP-1153",
+	"TSTORE":         "EIP-1153",
+	"MCOPY":          "EIP-5656",
+	"BLOBHASH":       "EIP-4844",
+	"BLOBBASEFEE":    "EIP-7516",
+	"CLZ":            "EIP-7939",
+
+	// Precompiles
+	"PC_BN254_ADD":            "EIP-1108",
+	"PC_BN254_MUL":            "EIP-1108",
+	"PC_BN254_PAIRING":        "EIP-1108",
+	"PC_MODEXP":               "EIP-2565",
+	"PC_BLAKE2F":              "EIP-152",
+	"PC_KZG_POINT_EVALUATION": "EIP-4844",
+	"PC_P256VERIFY":           "EIP-7951",
+}
+
+// eipForEntry returns the EIP an opcode breakdown entry is attributed to.
+func eipForEntry(name string) string {
+	if eip, ok := entryEIPs[name]; ok {
+		return eip
+	}
+
+	if strings.HasPrefix(name, "PC_BLS12_") {
+		return "EIP-2537"
+	}
+
+	return eipBase
+}
+
+// eipBreakdown re-buckets an opcode breakdown by EIP. Intrinsic gas not already in
+// the breakdown (as TX_INTRINSIC) is added under eipBase.
+func eipBreakdown(breakdown map[string]OpcodeSummary, originalIntrinsic, simulatedIntrinsic uint64) map[string]EIPGas {
+	result := make(map[string]EIPGas, 16)
+
+	for name, summary := range breakdown {
+		eip := eipForEntry(name)
+		gas := result[eip]
+		gas.OriginalGas += summary.OriginalGas
+		gas.SimulatedGas += summary.SimulatedGas
+		result[eip] = gas
+	}
+
+	if originalIntrinsic > 0 || simulatedIntrinsic > 0 {
+		base := result[eipBase]
+		base.OriginalGas += originalIntrinsic
+		base.SimulatedGas += simulatedIntrinsic
+		result[eipBase] = base
+	}
+
+	return result
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "testing"
+
+// TestEIPBreakdown verifies that cold-access gas (SLOAD and CALL) rolls up under
+// EIP-2929, that newer opcodes and precompiles go to the EIP introducing them, and
+// that the rest, including intrinsic gas, falls under base.
+func TestEIPBreakdown(t *testing.T) {
+	breakdown := map[string]OpcodeSummary{
+		"SLOAD":          {OriginalCount: 2, OriginalGas: 2200, SimulatedCount: 2, SimulatedGas: 8100},
+		"CALL":           {OriginalCount: 1, OriginalGas: 2600, SimulatedCount: 1, SimulatedGas: 2600},
+		"TSTORE":         {OriginalCount: 1, OriginalGas: 100, SimulatedCount: 1, SimulatedGas: 100},
+		"PC_BLS12_G1ADD": {OriginalCount: 1, OriginalGas: 375, SimulatedCount: 1, SimulatedGas: 375},
+		"ADD":            {OriginalCount: 3, OriginalGas: 9, SimulatedCount: 3, SimulatedGas: 9},
+	}
+
+	got := eipBreakdown(breakdown, 21000, 30000)
+
+	want := map[string]EIPGas{
+		"EIP-2929": {OriginalGas: 2200 + 2600, SimulatedGas: 8100 + 2600},
+		"EIP-1153": {OriginalGas: 100, SimulatedGas: 100},
+		"EIP-2537": {OriginalGas: 375, SimulatedGas: 375},
+		eipBase:    {OriginalGas: 9 + 21000, SimulatedGas: 9 + 30000},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("breakdown = %v, want %v", got, want)
+	}
+
+	for eip, gas := range want {
+		if got[eip] != gas {
+			t.Errorf("%s = %+v, want %+v", eip, got[eip], gas)
+		}
+	}
+
+	// Block breakdowns carry intrinsic gas as TX_INTRINSIC
+	block := eipBreakdown(map[string]OpcodeSummary{"TX_INTRINSIC": {OriginalGas: 21000, SimulatedGas: 21000}}, 0, 0)
+	if block[eipBase] != (EIPGas{OriginalGas: 21000, SimulatedGas: 21000}) {
+		t.Errorf("TX_INTRINSIC = %+v, want it under %s", block[eipBase], eipBase)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/hex"
+
+	"github.com/erigontech/erigon/execution/tracing"
+)
+
+// EmittedLog is an event emitted by a LOG0-LOG4 opcode, together with the gas the
+// opcode was charged. LOG gas is priced by topic count and data size (plus memory
+// expansion), so the dimensions here let the charged gas be checked.
+type EmittedLog struct {
+	Depth    int      `json:"depth"` // Call frame depth, 0 for the top-level frame
+	Address  string   `json:"address"`
+	Topics   []string `json:"topics"`
+	DataSize uint64   `json:"dataSize"`
+	Gas      uint64   `json:"gas"`
+	Reverted bool     `json:"reverted"` // True if the frame (or an ancestor) failed, discarding the log
+}
+
+// EmittedLogs holds the logs emitted by both executions.
+type EmittedLogs struct {
+	Original  []EmittedLog `json:"original"`
+	Simulated []EmittedLog `json:"simulated"`
+}
+
+// recordLog captures a LOG opcode from its stack operands (offset, size, topics...)
+// before it executes. depth is the opcode depth, one more than its frame's depth.
+func (t *SimulationTracer) recordLog(opcode byte, depth int, cost uint64, scope tracing.OpContext) {
+	topicCount := int(opcode - 0xA0)
+
+	stack := scope.StackData()
+	if len(stack) < 2+topicCount {
+		return
+	}
+
+	size := stack[len(stack)-2]
+	if !size.IsUint64() {
+		return // The LOG runs out of gas before emitting anything
+	}
+
+	topics := make([]string, topicCount)
+	for i := range topics {
+		topic := stack[len(stack)-3-i].Bytes32()
+		topics[i] = "0x" + hex.EncodeToString(topic[:])
+	}
+
+	t.logs = append(t.logs, EmittedLog{
+		Depth:    depth - 1,
+		Address:  normalizeAddress(scope.Address().String()),
+		Topics:   topics,
+		DataSize: size.Uint64(),
+		Gas:      cost,
+	})
+}
+
+// markLogsReverted flags all logs from index start onwards as reverted.
+// Called when a frame fails, since its logs and those of its children are discarded.
+func (t *SimulationTracer) markLogsReverted(start int) {
+	for i := start; i < len(t.logs); i++ {
+		t.logs[i].Reverted = true
+	}
+}
+
+// combineEmittedLogs pairs the logs of both executions, or returns nil if log
+// capture is disabled.
+func combineEmittedLogs(original, simulated *SimulationTracer) *EmittedLogs {
+	if !original.trackLogs || !simulated.trackLogs {
+		return nil
+	}
+
+	return &EmittedLogs{
+		Original:  original.GetLogs(),
+		Simulated: simulated.GetLogs(),
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erigontech/erigon/db/kv"
+	"github.com/erigontech/erigon/db/kv/rawdbv3"
+	"github.com/erigontech/erigon/execution/protocol"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+	"github.com/erigontech/erigon/rpc/ethapi"
+)
+
+// EstimateGasWithScheduleResult is the result of xatu_estimateGasWithSchedule.
+type EstimateGasWithScheduleResult struct {
+	BlockNumber     uint64                   `json:"blockNumber"`
+	Status          string                   `json:"status"` // Status of the simulated execution
+	Error           string                   `json:"error,omitempty"`
+	Original        TxGasDetail              `json:"original"`
+	Simulated       TxGasDetail              `json:"simulated"`
+	OpcodeBreakdown map[string]OpcodeSummary `json:"opcodeBreakdown"`
+}
+
+// EstimateGasWithSchedule runs a hypothetical (unmined) transaction built from call
+// args against the state at the end of blockNumber, with standard gas costs and with
+// the custom schedule, like eth_estimateGas but schedule-aware.
+//
+// The reported gas is the gas used by a single run with the gas limit from args
+// (the block gas limit if unset), not a binary search for the lowest gas limit that
+// succeeds. The two can differ when the 63/64 rule withholds gas from nested calls.
+// As with eth_call, the sender's balance and nonce are not checked.
+func (s *Service) EstimateGasWithSchedule(
+	ctx context.Context,
+	args ethapi.CallArgs,
+	blockNumber uint64,
+	schedule *CustomGasSchedule,
+) (*EstimateGasWithScheduleResult, error) {
+	if err := schedule.validateRelativeOverrides(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	block, err := s.blockReader.BlockByNumber(ctx, tx, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %d: %w", blockNumber, err)
+	}
+
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+
+	header := block.Header()
+	opts := executionOptions{GasSchedule: schedule}
+
+	if err := s.checkSupportedFork(ctx, opts, blockNumber, header.Time); err != nil {
+		return nil, err
+	}
+
+	if err := s.resolveRelativeOverrides(ctx, &opts, blockNumber, header.Time); err != nil {
+		return nil, err
+	}
+
+	txNumReader := s.txNumsReader(ctx)
+
+	dualResult, err := runDualExecution(opts, SimulationTracerConfig{}, func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+		// Each execution gets a fresh transaction so state changes don't leak between runs
+		dbTx, err := s.db.BeginTemporalRo(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer dbTx.Rollback()
+
+		return s.executeCall(ctx, dbTx, header, block, txNumReader, &args, tracer, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute call: %w", err)
+	}
+
+	return newEstimateGasWithScheduleResult(blockNumber, dualResult), nil
+}
+
+// newEstimateGasWithScheduleResult builds the response for a dual run of a call.
+func newEstimateGasWithScheduleResult(blockNumber uint64, dualResult *dualExecutionResult) *EstimateGasWithScheduleResult {
+	return &EstimateGasWithScheduleResult{
+		BlockNumber:     blockNumber,
+		Status:          dualResult.Simulated.Status,
+		Error:           executionError(dualResult.Simulated),
+		Original:        newTxGasDetail(dualResult.Original),
+		Simulated:       newTxGasDetail(dualResult.Simulated),
+		OpcodeBreakdown: dualResult.OpcodeBreakdown,
+	}
+}
+
+// executeCall executes a message built from call args on top of the state after
+// the given block.
+func (s *Service) executeCall(
+	ctx context.Context,
+	dbTx kv.TemporalTx,
+	header *erigontypes.Header,
+	block *erigontypes.Block,
+	txNumReader rawdbv3.TxNumsReader,
+	args *ethapi.CallArgs,
+	tracer *SimulationTracer,
+	opts executionOptions,
+) (result *executionResult, err error) {
+	// The call runs after the block's last transaction
+	txIndex := len(block.Transactions())
+
+	// Contain panics from custom gas functions to this call
+	defer s.recoverExecutionPanic(txIndex, &result, &err)
+
+	execChainConfig := s.chainConfigFor(ctx, opts)
+
+	statedb, blockCtx, chainRules, _, err := s.computeBlockContext(ctx, dbTx, header, txIndex, txNumReader, execChainConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Base fee checks are disabled (NoBaseFee), so gas price fields only need to be
+	// consistent with each other.
+	msg, err := args.ToMessage(header.GasLimit, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid call arguments: %w", err)
+	}
+
+	txCtx := protocol.NewEVMTxContext(msg)
+	intrinsicGas := calcIntrinsicGas(msg.Data(), msg.AccessList(), args.To == nil, chainRules, opts.GasSchedule)
+
+	// Gas bailout skips the balance check, as eth_call does for hypothetical senders
+	return s.executeMessage(ctx, statedb, blockCtx, txCtx, msg, header, chainRules, execChainConfig, intrinsicGas, true, tracer, opts)
+}
+
+// executionError returns the error that made an execution fail, or "" if it succeeded.
+func executionError(r *executionResult) string {
+	switch {
+	case r.ApplyErr != nil:
+		return r.ApplyErr.Error()
+	case r.Err != nil:
+		return r.Err.Error()
+	}
+
+	return ""
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/common/hexutil"
+	"github.com/erigontech/erigon/execution/vm"
+	"github.com/erigontech/erigon/rpc/ethapi"
+)
+
+// TestEstimateGasWithScheduleExecution runs a hypothetical call through both
+// executions of xatu_estimateGasWithSchedule, as executeCall applies it, and checks
+// that the response prices its cold SLOAD at the fork default and at the schedule's.
+func TestEstimateGasWithScheduleExecution(t *testing.T) {
+	// SLOAD(0)
+	c := newTestChain(t, map[common.Address][]byte{testContract: {0x60, 0x00, 0x54, 0x50, 0x00}})
+
+	gas := hexutil.Uint64(1_000_000)
+	args := ethapi.CallArgs{From: &testSender, To: &testContract, Gas: &gas}
+	opts := executionOptions{GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{vm.GasKeySloadCold: 5000}}}
+
+	dualResult, err := runDualExecution(opts, SimulationTracerConfig{}, func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+		return c.execute(args, true, tracer, opts), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := newEstimateGasWithScheduleResult(1, dualResult)
+
+	if result.Status != "success" || result.Error != "" {
+		t.Fatalf("status = %q (%q), want success", result.Status, result.Error)
+	}
+
+	if diff := result.Simulated.GasUsed - result.Original.GasUsed; diff != 5000-2100 {
+		t.Errorf("gas used %d original and %d simulated, want a difference of %d",
+			result.Original.GasUsed, result.Simulated.GasUsed, 5000-2100)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewTxGasDetail(t *testing.T) {
+	tests := []struct {
+		name   string
+		result executionResult
+		want   TxGasDetail
+	}{
+		{
+			name:   "call",
+			result: executionResult{GasUsed: 45000, IntrinsicGas: 21000},
+			want:   TxGasDetail{GasUsed: 45000, IntrinsicGas: 21000, ExecutionGas: 24000},
+		},
+		{
+			name:   "failed pre-execution",
+			result: executionResult{GasUsed: 0, IntrinsicGas: 21000},
+			want:   TxGasDetail{GasUsed: 0, IntrinsicGas: 21000, ExecutionGas: 0},
+		},
+		{
+			name:   "gas remaining",
+			result: executionResult{GasUsed: 45000, GasLimit: 100000, IntrinsicGas: 21000},
+			want:   TxGasDetail{GasUsed: 45000, GasLimit: 100000, GasRemaining: 55000, IntrinsicGas: 21000, ExecutionGas: 24000},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := newTxGasDetail(&tc.result)
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+
+			// A transaction's gas limit is fully accounted for
+			if got.GasLimit > 0 && got.GasUsed+got.GasRemaining != got.GasLimit {
+				t.Errorf("used %d + remaining %d != limit %d", got.GasUsed, got.GasRemaining, got.GasLimit)
+			}
+		})
+	}
+}
+
+func TestExecutionError(t *testing.T) {
+	tests := []struct {
+		name   string
+		result executionResult
+		want   string
+	}{
+		{name: "success", result: executionResult{Status: "success"}, want: ""},
+		{name: "reverted", result: executionResult{Err: errors.New("execution reverted")}, want: "execution reverted"},
+		{
+			name:   "pre-execution error takes precedence",
+			result: executionResult{ApplyErr: errors.New("intrinsic gas too low"), Err: errors.New("other")},
+			want:   "intrinsic gas too low",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := executionError(&tc.result); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"runtime"
+)
+
+// executionLimit returns the number of EVM executions allowed to run at once.
+// A limit of 0 or less defaults to GOMAXPROCS, one execution per usable CPU.
+func executionLimit(configured int) int {
+	if configured > 0 {
+		return configured
+	}
+
+	return runtime.GOMAXPROCS(0)
+}
+
+// acquireExecution waits for an execution slot, bounding the number of EVM
+// executions running at once across all requests (per-block parallelism alone
+// would oversubscribe the CPU under many concurrent requests). The returned
+// function releases the slot; callers defer it so the slot is freed on every
+// path, including errors and panics.
+func (s *Service) acquireExecution(ctx context.Context) (func(), error) {
+	select {
+	case s.executionSlots <- struct{}{}:
+		return func() { <-s.executionSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/common/hexutil"
+	"github.com/erigontech/erigon/common/log/v3"
+	"github.com/erigontech/erigon/db/datadir"
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol"
+	"github.com/erigontech/erigon/rpc/ethapi"
+)
+
+// TestExecutionSlots runs executeMessage on a service limited to one execution:
+// a call waits while the slot is held and gives up when its context ends, an
+// execution that fails to apply releases the slot for the next one, and
+// concurrent successful and failing executions all complete. It also checks that
+// the limit defaults to GOMAXPROCS.
+func TestExecutionSlots(t *testing.T) {
+	newSvc := func(limit int) *Service {
+		svc, err := newService(stubDB{}, nil, &chain.Config{ChainID: big.NewInt(1)}, nil, datadir.Dirs{},
+			Config{SimulationOnly: true, MaxConcurrentExecutions: limit}, log.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return svc
+	}
+
+	if got := cap(newSvc(0).executionSlots); got != runtime.GOMAXPROCS(0) {
+		t.Errorf("default limit = %d, want GOMAXPROCS (%d)", got, runtime.GOMAXPROCS(0))
+	}
+
+	svc := newSvc(1)
+	gas := hexutil.Uint64(100_000)
+
+	// Each execution gets its own chain, as concurrent requests have their own state
+	newChain := func() *testChain {
+		return newTestChain(t, map[common.Address][]byte{testContract: storeCalldata})
+	}
+
+	// A failing execution sends more than the sender's balance without a gas bailout
+	run := func(ctx context.Context, c *testChain, fail bool) (*executionResult, error) {
+		args := ethapi.CallArgs{From: &testSender, To: &testContract, Gas: &gas}
+		if fail {
+			args.Value = (*hexutil.Big)(big.NewInt(2e18))
+		}
+
+		msg, err := args.ToMessage(c.header.GasLimit, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return svc.executeMessage(ctx, c.statedb, c.blockCtx, protocol.NewEVMTxContext(msg),
+			msg, c.header, c.rules, c.config, 0, false, nil, executionOptions{})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Hold the only slot, as an execution in flight would
+	release, err := svc.acquireExecution(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waiting := make(chan error, 1)
+	waitingChain := newChain()
+	go func() {
+		_, err := run(ctx, waitingChain, false)
+		waiting <- err
+	}()
+
+	select {
+	case err := <-waiting:
+		t.Fatalf("execution over the limit finished while the slot was held: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer shortCancel()
+
+	if _, err := run(shortCtx, newChain(), false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("execution over the limit = %v, want context.DeadlineExceeded", err)
+	}
+
+	release()
+
+	if err := <-waiting; err != nil {
+		t.Fatalf("waiting execution failed after the slot was released: %v", err)
+	}
+
+	// A failed execution releases its slot, so the next one proceeds
+	failed, err := run(ctx, newChain(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if failed.ApplyErr == nil {
+		t.Fatal("expected the overfunded transfer to fail to apply")
+	}
+
+	if result, err := run(ctx, newChain(), false); err != nil || result.Status != "success" {
+		t.Fatalf("execution after a failed one = %v, %v, want success", result, err)
+	}
+
+	chains := make([]*testChain, 8)
+	for i := range chains {
+		chains[i] = newChain()
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(chains))
+	for i, c := range chains {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			result, err := run(ctx, c, i%2 == 1)
+			if err == nil && (i%2 == 1) != (result.ApplyErr != nil) {
+				err = errors.New("unexpected apply result")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent execution: %v", err)
+		}
+	}
+
+	// Every slot was released, so the limit is free again
+	if n := len(svc.executionSlots); n != 0 {
+		t.Errorf("%d slots held after all executions finished, want 0", n)
+	}
+}
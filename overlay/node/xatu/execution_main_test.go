@@ -0,0 +1,220 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/common/hexutil"
+	"github.com/erigontech/erigon/common/log/v3"
+	"github.com/erigontech/erigon/db/datadir"
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol"
+	erigonstate "github.com/erigontech/erigon/execution/state"
+	"github.com/erigontech/erigon/execution/tracing"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+	"github.com/erigontech/erigon/execution/types/accounts"
+	"github.com/erigontech/erigon/execution/vm/evmtypes"
+	"github.com/erigontech/erigon/rpc/ethapi"
+)
+
+var (
+	testSender   = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	testContract = common.HexToAddress("0x00000000000000000000000000000000000c0de0")
+	testCoinbase = common.HexToAddress("0x000000000000000000000000000000000000c01b")
+)
+
+// emptyStateReader serves an empty state. The accounts a test needs are created in
+// the IntraBlockState on top of it (see newTestChain).
+type emptyStateReader struct {
+	erigonstate.StateReader
+}
+
+func (emptyStateReader) ReadAccountData(accounts.Address) (*accounts.Account, error) {
+	return nil, nil
+}
+
+func (emptyStateReader) ReadAccountStorage(accounts.Address, accounts.StorageKey) (uint256.Int, bool, error) {
+	return uint256.Int{}, false, nil
+}
+
+// testChain executes calls on an in-memory state (Cancun unless built with
+// newTestChainWithConfig) through executeMessage, the path every simulation
+// endpoint takes, so tests see the gas the RPCs would report. Each call's writes
+// are kept for the next one, as between a block's transactions.
+type testChain struct {
+	t        *testing.T
+	s        *Service
+	config   *chain.Config
+	header   *erigontypes.Header
+	rules    *chain.Rules
+	statedb  *erigonstate.IntraBlockState
+	blockCtx evmtypes.BlockContext
+}
+
+// cancunConfig returns a chain config with every fork up to Cancun active from
+// genesis.
+func cancunConfig() *chain.Config {
+	return &chain.Config{
+		ChainID:               big.NewInt(1),
+		HomesteadBlock:        big.NewInt(0),
+		TangerineWhistleBlock: big.NewInt(0),
+		SpuriousDragonBlock:   big.NewInt(0),
+		ByzantiumBlock:        big.NewInt(0),
+		ConstantinopleBlock:   big.NewInt(0),
+		PetersburgBlock:       big.NewInt(0),
+		IstanbulBlock:         big.NewInt(0),
+		BerlinBlock:           big.NewInt(0),
+		LondonBlock:           big.NewInt(0),
+		ShanghaiTime:          big.NewInt(0),
+		CancunTime:            big.NewInt(0),
+	}
+}
+
+// newTestChain returns a Cancun chain with a funded sender and the given contracts,
+// keyed by address.
+func newTestChain(t *testing.T, contracts map[common.Address][]byte) *testChain {
+	t.Helper()
+
+	return newTestChainWithConfig(t, cancunConfig(), contracts)
+}
+
+// newTestChainWithConfig is newTestChain with the forks of config.
+func newTestChainWithConfig(t *testing.T, config *chain.Config, contracts map[common.Address][]byte) *testChain {
+	t.Helper()
+
+	s, err := newService(stubDB{}, nil, &chain.Config{ChainID: big.NewInt(1)}, nil, datadir.Dirs{},
+		Config{SimulationOnly: true}, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var excessBlobGas, blobGasUsed uint64
+	header := &erigontypes.Header{
+		Number:        big.NewInt(1),
+		Time:          1,
+		GasLimit:      30_000_000,
+		Difficulty:    big.NewInt(0),
+		BaseFee:       big.NewInt(0),
+		Coinbase:      testCoinbase,
+		ExcessBlobGas: &excessBlobGas,
+		BlobGasUsed:   &blobGasUsed,
+	}
+
+	statedb := erigonstate.New(emptyStateReader{})
+
+	sender := accounts.InternAddress(testSender)
+	if err := statedb.CreateAccount(sender, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := statedb.SetBalance(sender, *uint256.NewInt(1e18), tracing.BalanceChangeUnspecified); err != nil {
+		t.Fatal(err)
+	}
+
+	for addr, code := range contracts {
+		contract := accounts.InternAddress(addr)
+		if err := statedb.CreateAccount(contract, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := statedb.SetCode(contract, code); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &testChain{
+		t:       t,
+		s:       s,
+		config:  config,
+		header:  header,
+		rules:   config.Rules(header.Number.Uint64(), header.Time),
+		statedb: statedb,
+		blockCtx: protocol.NewEVMBlockContext(header, func(uint64) (common.Hash, error) {
+			return common.Hash{}, nil
+		}, nil, nil, config),
+	}
+
+	// Seeded accounts and code are committed, like the state before a block
+	c.finalize()
+
+	return c
+}
+
+// call executes a call from the test sender to to with data under opts, and commits
+// its writes to the state.
+func (c *testChain) call(to common.Address, data []byte, opts executionOptions) *executionResult {
+	c.t.Helper()
+
+	return c.callTraced(to, data, nil, opts)
+}
+
+// callTraced is call with a tracer attached to the execution.
+func (c *testChain) callTraced(to common.Address, data []byte, tracer *SimulationTracer, opts executionOptions) *executionResult {
+	c.t.Helper()
+
+	gas := hexutil.Uint64(1_000_000)
+	input := hexutil.Bytes(data)
+	args := ethapi.CallArgs{From: &testSender, To: &to, Gas: &gas, Data: &input}
+
+	result := c.execute(args, true, tracer, opts)
+	if result.ApplyErr != nil {
+		c.t.Fatalf("call to %s failed to apply: %v", to, result.ApplyErr)
+	}
+
+	return result
+}
+
+// execute applies the message built from args under opts, with the caller's
+// gasBailout default, and commits its writes. Unlike call, a pre-execution error
+// (e.g. insufficient funds) is left in the result's ApplyErr.
+func (c *testChain) execute(args ethapi.CallArgs, gasBailout bool, tracer *SimulationTracer, opts executionOptions) *executionResult {
+	c.t.Helper()
+
+	msg, err := args.ToMessage(c.header.GasLimit, nil)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	intrinsicGas := calcIntrinsicGas(msg.Data(), msg.AccessList(), false, c.rules, opts.GasSchedule)
+
+	result, err := c.s.executeMessage(context.Background(), c.statedb, c.blockCtx, protocol.NewEVMTxContext(msg),
+		msg, c.header, c.rules, c.config, intrinsicGas, gasBailout, tracer, opts)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	c.finalize()
+
+	return result
+}
+
+// finalize commits the pending writes, so the next call sees them as committed state.
+func (c *testChain) finalize() {
+	c.t.Helper()
+
+	if err := c.statedb.FinalizeTx(c.rules, erigonstate.NewNoopWriter()); err != nil {
+		c.t.Fatal(err)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+// executionOptions configures a single simulated execution.
+type executionOptions struct {
+	GasSchedule         *CustomGasSchedule // Custom gas costs (nil uses standard costs)
+	MaxGasLimit         bool               // Raise the tx gas limit to the block gas limit
+	GasLimit            uint64             // With MaxGasLimit, the gas limit to raise to (0 uses the block's)
+	EnforceBalanceCheck bool               // Keep the sender balance check that MaxGasLimit skips
+	EnforceGasCap       *bool              // Set the EIP-7825 gas cap check (nil keeps the default)
+	ChainConfig         *chain.Config      // Chain config override (nil uses the node's config)
+
+	DisableAccessList bool                // Use pre-Berlin flat costs for state access (no EIP-2929)
+	LegacyRefunds     bool                // Use pre-London refunds (no EIP-3529)
+	ForceAllCold      bool                // Charge every state access the EIP-2929 cold cost
+	Precompiles       precompileOverrides // Disabled or moved precompiles
+
+	Calldata []byte // Replaces the transaction's calldata (nil keeps it)
+
+	PCOverrides map[uint32]map[string]uint64 // Gas overrides scoped to program counters
+}
+
+// baseline returns the options for the original execution of a dual run.
+// Options that change gas accounting are cleared, while options describing the
+// execution environment (e.g. the chain config) apply to both executions.
+func (o executionOptions) baseline() executionOptions {
+	return executionOptions{
+		ChainConfig: o.ChainConfig,
+		Calldata:    o.Calldata,
+	}
+}
+
+// isBaseline reports whether the options would run identically to their baseline,
+// in which case the simulated execution of a dual run can be skipped.
+func (o executionOptions) isBaseline() bool {
+	return !o.MaxGasLimit && !o.DisableAccessList && !o.LegacyRefunds && !o.ForceAllCold && !o.Precompiles.enabled() && !o.GasSchedule.HasOverrides() &&
+		len(o.PCOverrides) == 0 && o.EnforceGasCap == nil
+}
+
+// txGasLimit returns the gas limit MaxGasLimit raises transactions to: GasLimit if
+// set, otherwise the block's gas limit.
+func (o executionOptions) txGasLimit(blockGasLimit uint64) uint64 {
+	if o.GasLimit > 0 {
+		return o.GasLimit
+	}
+
+	return blockGasLimit
+}
+
+// gasBailout returns whether ApplyMessage should skip the sender balance check, given
+// the caller's default. MaxGasLimit skips it unless EnforceBalanceCheck is set, since
+// the sender could afford the original gas limit but not necessarily the block's.
+// A calldata override always skips it: the sender may not afford the intrinsic gas
+// of the new calldata.
+func (o executionOptions) gasBailout(requested bool) bool {
+	if o.MaxGasLimit && !o.EnforceBalanceCheck {
+		return true
+	}
+
+	return requested || o.Calldata != nil
+}
+
+// gasCapCheck returns whether the message should check its gas limit against the
+// EIP-7825 cap, with set false when the message's own default applies. MaxGasLimit
+// disables the check by default, since the block gas limit it raises transactions
+// to exceeds the cap; EnforceGasCap overrides either default.
+func (o executionOptions) gasCapCheck() (check, set bool) {
+	if o.EnforceGasCap != nil {
+		return *o.EnforceGasCap, true
+	}
+
+	if o.MaxGasLimit {
+		return false, true
+	}
+
+	return false, false
+}
+
+// chainConfigFor returns the chain config to execute with: the request's override
+// if set, otherwise the node's config.
+func (s *Service) chainConfigFor(ctx context.Context, opts executionOptions) *chain.Config {
+	if opts.ChainConfig != nil {
+		return opts.ChainConfig
+	}
+
+	return s.chainConfigForExecution(ctx)
+}
+
+// validateChainConfigOverride checks that a user-supplied chain config is usable
+// for fork rule resolution. A nil override is valid (the node's config is used).
+func validateChainConfigOverride(cfg *chain.Config) error {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.ChainID == nil {
+		return fmt.Errorf("invalid chain config override: chainId is required")
+	}
+
+	if err := cfg.CheckConfigForkOrder(); err != nil {
+		return fmt.Errorf("invalid chain config override: %w", err)
+	}
+
+	return nil
+}
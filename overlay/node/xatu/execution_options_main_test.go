@@ -0,0 +1,102 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/erigontech/erigon/common/hexutil"
+	"github.com/erigontech/erigon/rpc/ethapi"
+)
+
+// TestEnforceBalanceCheckExecution applies a transaction whose sender can afford its
+// own gas limit but not the block's, and checks that MaxGasLimit lets it run unless
+// EnforceBalanceCheck keeps the balance check.
+func TestEnforceBalanceCheckExecution(t *testing.T) {
+	// 1000 gwei: the sender's 1 ether covers 100k gas but not the 30M block gas limit
+	gasPrice := (*hexutil.Big)(big.NewInt(1e12))
+	gas := hexutil.Uint64(100_000)
+
+	tests := []struct {
+		name      string
+		opts      executionOptions
+		wantError bool
+	}{
+		{name: "own gas limit", opts: executionOptions{EnforceBalanceCheck: true}},
+		{name: "maxGasLimit skips the check", opts: executionOptions{MaxGasLimit: true}},
+		{name: "maxGasLimit with enforcement", opts: executionOptions{MaxGasLimit: true, EnforceBalanceCheck: true}, wantError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestChain(t, nil)
+
+			args := ethapi.CallArgs{From: &testSender, To: &testContract, Gas: &gas, GasPrice: gasPrice}
+
+			result := c.execute(args, false, nil, tc.opts)
+			if gotError := result.ApplyErr != nil; gotError != tc.wantError {
+				t.Errorf("ApplyErr = %v, want an error: %v", result.ApplyErr, tc.wantError)
+			}
+		})
+	}
+}
+
+// TestEnforceGasCapExecution applies transactions over the EIP-7825 gas cap on an
+// Osaka chain and checks that EnforceGasCap decides whether they fail pre-execution,
+// whether MaxGasLimit raised the gas limit or the transaction set it.
+func TestEnforceGasCapExecution(t *testing.T) {
+	enforce, skip := true, false
+
+	const overCap = 20_000_000 // Above the 2^24 cap, below the 30M block gas limit
+
+	tests := []struct {
+		name      string
+		gas       hexutil.Uint64
+		opts      executionOptions
+		wantError bool
+	}{
+		{name: "maxGasLimit skips the check", gas: 100_000, opts: executionOptions{MaxGasLimit: true, GasLimit: overCap}},
+		{
+			name:      "maxGasLimit with enforcement",
+			gas:       100_000,
+			opts:      executionOptions{MaxGasLimit: true, GasLimit: overCap, EnforceGasCap: &enforce},
+			wantError: true,
+		},
+		{name: "own gas limit with enforcement", gas: overCap, opts: executionOptions{EnforceGasCap: &enforce}, wantError: true},
+		{name: "own gas limit without the check", gas: overCap, opts: executionOptions{EnforceGasCap: &skip}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config := cancunConfig()
+			config.PragueTime = big.NewInt(0)
+			config.OsakaTime = big.NewInt(0)
+
+			c := newTestChainWithConfig(t, config, nil)
+
+			args := ethapi.CallArgs{From: &testSender, To: &testContract, Gas: &tc.gas}
+
+			result := c.execute(args, true, nil, tc.opts)
+			if gotError := result.ApplyErr != nil; gotError != tc.wantError {
+				t.Errorf("ApplyErr = %v, want an error: %v", result.ApplyErr, tc.wantError)
+			}
+		})
+	}
+}
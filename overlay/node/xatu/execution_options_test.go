@@ -0,0 +1,120 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "testing"
+
+// TestGasBailout verifies when the sender balance check is skipped. Under MaxGasLimit
+// a sender that could only afford its original gas limit passes by default, and fails
+// pre-execution once EnforceBalanceCheck keeps the check.
+func TestGasBailout(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      executionOptions
+		requested bool
+		want      bool
+	}{
+		{name: "default checks balance", opts: executionOptions{}, want: false},
+		{name: "caller default is kept", opts: executionOptions{}, requested: true, want: true},
+		{name: "maxGasLimit skips the check", opts: executionOptions{MaxGasLimit: true}, want: true},
+		{
+			name: "maxGasLimit with enforcement checks balance",
+			opts: executionOptions{MaxGasLimit: true, EnforceBalanceCheck: true},
+			want: false,
+		},
+		{
+			name: "enforcement alone changes nothing",
+			opts: executionOptions{EnforceBalanceCheck: true},
+			want: false,
+		},
+		{
+			name: "calldata override still skips the check",
+			opts: executionOptions{MaxGasLimit: true, EnforceBalanceCheck: true, Calldata: []byte{0x01}},
+			want: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.opts.gasBailout(tc.requested); got != tc.want {
+				t.Errorf("gasBailout(%v) = %v, want %v", tc.requested, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestEnforceBalanceCheckOptions verifies that both request types pass the flag
+// through, and that it only applies to the simulated execution.
+func TestEnforceBalanceCheckOptions(t *testing.T) {
+	blockOpts := SimulateBlockGasRequest{MaxGasLimit: true, EnforceBalanceCheck: true}.options()
+	txOpts := SimulateTransactionGasRequest{MaxGasLimit: true, EnforceBalanceCheck: true}.options()
+
+	for name, opts := range map[string]executionOptions{"block": blockOpts, "transaction": txOpts} {
+		if opts.gasBailout(false) {
+			t.Errorf("%s: balance check skipped despite EnforceBalanceCheck", name)
+		}
+
+		if baseline := opts.baseline(); baseline.MaxGasLimit || baseline.EnforceBalanceCheck {
+			t.Errorf("%s: baseline = %+v, want the original execution unchanged", name, baseline)
+		}
+	}
+}
+
+// TestGasCapCheck verifies when the EIP-7825 gas cap check is set. A transaction
+// raised above the cap by MaxGasLimit skips the check by default and is rejected
+// pre-execution once EnforceGasCap keeps it; EnforceGasCap can also disable it.
+func TestGasCapCheck(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name      string
+		opts      executionOptions
+		wantCheck bool
+		wantSet   bool
+	}{
+		{name: "default keeps the message's check", opts: executionOptions{}},
+		{name: "maxGasLimit disables the check", opts: executionOptions{MaxGasLimit: true}, wantSet: true},
+		{
+			name:      "maxGasLimit with enforcement checks the cap",
+			opts:      executionOptions{MaxGasLimit: true, EnforceGasCap: &enabled},
+			wantCheck: true,
+			wantSet:   true,
+		},
+		{name: "enforcement can disable the check", opts: executionOptions{EnforceGasCap: &disabled}, wantSet: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			check, set := tc.opts.gasCapCheck()
+			if check != tc.wantCheck || set != tc.wantSet {
+				t.Errorf("gasCapCheck() = %v, %v, want %v, %v", check, set, tc.wantCheck, tc.wantSet)
+			}
+		})
+	}
+
+	// The flag only applies to the simulated execution, which must then run
+	opts := SimulateTransactionGasRequest{EnforceGasCap: &enabled}.options()
+	if opts.isBaseline() {
+		t.Error("EnforceGasCap must not skip the simulated execution")
+	}
+
+	if _, set := opts.baseline().gasCapCheck(); set {
+		t.Error("baseline sets the gas cap check")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// feeDelta estimates the change in fees paid, in wei, when a transaction's gas
+// usage moves from originalGas to simulatedGas at the given base fee. The result
+// is negative when the simulated schedule is cheaper.
+func feeDelta(originalGas, simulatedGas uint64, baseFee *big.Int) *big.Int {
+	delta := new(big.Int).SetUint64(simulatedGas)
+	delta.Sub(delta, new(big.Int).SetUint64(originalGas))
+
+	return delta.Mul(delta, baseFee)
+}
+
+// formatWei hex-encodes a signed wei amount ("-0x..." for negative values).
+func formatWei(v *big.Int) string {
+	return fmt.Sprintf("%#x", v)
+}
+
+// applyBaseFee records the block's base fee and derives the fee impact of each
+// transaction's gas delta, plus the block total. It is a reporting-only estimate:
+// both executions run with base fee checks disabled (NoBaseFee), so gas used is
+// unaffected by the base fee, and priority fees and the effect of the changed gas
+// usage on later blocks' base fees are ignored. Pre-London blocks (nil base fee)
+// are left without fee fields.
+func (r *SimulateBlockGasResult) applyBaseFee(baseFee *big.Int) {
+	if baseFee == nil {
+		return
+	}
+
+	r.BaseFee = formatWei(baseFee)
+
+	total := new(big.Int)
+
+	for i := range r.Transactions {
+		txn := &r.Transactions[i]
+		delta := feeDelta(txn.OriginalGas, txn.SimulatedGas, baseFee)
+		txn.FeeDelta = formatWei(delta)
+		total.Add(total, delta)
+	}
+
+	r.FeeDelta = formatWei(total)
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestApplyBaseFee(t *testing.T) {
+	result := &SimulateBlockGasResult{
+		Transactions: []TxSummary{
+			{OriginalGas: 21000, SimulatedGas: 25000},
+			{OriginalGas: 50000, SimulatedGas: 40000},
+			{OriginalGas: 30000, SimulatedGas: 30000},
+		},
+	}
+
+	result.applyBaseFee(big.NewInt(10))
+
+	if result.BaseFee != "0xa" {
+		t.Errorf("BaseFee = %q, want 0xa", result.BaseFee)
+	}
+
+	want := []string{"0x9c40", "-0x186a0", "0x0"}
+	for i, txn := range result.Transactions {
+		if txn.FeeDelta != want[i] {
+			t.Errorf("tx %d FeeDelta = %q, want %q", i, txn.FeeDelta, want[i])
+		}
+	}
+
+	// 40000 - 100000 + 0
+	if result.FeeDelta != "-0xea60" {
+		t.Errorf("FeeDelta = %q, want -0xea60", result.FeeDelta)
+	}
+}
+
+func TestApplyBaseFeePreLondon(t *testing.T) {
+	result := &SimulateBlockGasResult{
+		Transactions: []TxSummary{{OriginalGas: 21000, SimulatedGas: 25000}},
+	}
+
+	result.applyBaseFee(nil)
+
+	if result.BaseFee != "" || result.FeeDelta != "" || result.Transactions[0].FeeDelta != "" {
+		t.Errorf("expected no fee fields without a base fee, got %+v", result)
+	}
+}
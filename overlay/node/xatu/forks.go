@@ -0,0 +1,115 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+// forkDef pairs a fork name with its activation check, and a setter that
+// activates it in a set of rules.
+type forkDef struct {
+	name   string
+	active func(*chain.Rules) bool
+	enable func(*chain.Rules)
+}
+
+// forkOrder lists the forks that change EVM rules, oldest first.
+var forkOrder = []forkDef{
+	{"frontier", func(*chain.Rules) bool { return true }, func(*chain.Rules) {}},
+	{"homestead", func(r *chain.Rules) bool { return r.IsHomestead }, func(r *chain.Rules) { r.IsHomestead = true }},
+	{"tangerinewhistle", func(r *chain.Rules) bool { return r.IsTangerineWhistle }, func(r *chain.Rules) { r.IsTangerineWhistle = true }},
+	{"spuriousdragon", func(r *chain.Rules) bool { return r.IsSpuriousDragon }, func(r *chain.Rules) { r.IsSpuriousDragon = true }},
+	{"byzantium", func(r *chain.Rules) bool { return r.IsByzantium }, func(r *chain.Rules) { r.IsByzantium = true }},
+	{"constantinople", func(r *chain.Rules) bool { return r.IsConstantinople }, func(r *chain.Rules) { r.IsConstantinople = true }},
+	{"petersburg", func(r *chain.Rules) bool { return r.IsPetersburg }, func(r *chain.Rules) { r.IsPetersburg = true }},
+	{"istanbul", func(r *chain.Rules) bool { return r.IsIstanbul }, func(r *chain.Rules) { r.IsIstanbul = true }},
+	{"berlin", func(r *chain.Rules) bool { return r.IsBerlin }, func(r *chain.Rules) { r.IsBerlin = true }},
+	{"london", func(r *chain.Rules) bool { return r.IsLondon }, func(r *chain.Rules) { r.IsLondon = true }},
+	{"shanghai", func(r *chain.Rules) bool { return r.IsShanghai }, func(r *chain.Rules) { r.IsShanghai = true }},
+	{"cancun", func(r *chain.Rules) bool { return r.IsCancun }, func(r *chain.Rules) { r.IsCancun = true }},
+	{"prague", func(r *chain.Rules) bool { return r.IsPrague }, func(r *chain.Rules) { r.IsPrague = true }},
+	{"osaka", func(r *chain.Rules) bool { return r.IsOsaka }, func(r *chain.Rules) { r.IsOsaka = true }},
+}
+
+// parseFork returns the index of a fork name in forkOrder (case-insensitive),
+// or -1 for an empty name.
+func parseFork(name string) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+
+	name = strings.ToLower(name)
+	for i, fork := range forkOrder {
+		if fork.name == name {
+			return i, nil
+		}
+	}
+
+	names := make([]string, len(forkOrder))
+	for i, fork := range forkOrder {
+		names[i] = fork.name
+	}
+
+	return -1, fmt.Errorf("unknown fork %q (supported: %s)", name, strings.Join(names, ", "))
+}
+
+// forkIndex returns the index in forkOrder of the latest fork active under rules.
+func forkIndex(rules *chain.Rules) int {
+	latest := 0
+	for i, fork := range forkOrder {
+		if fork.active(rules) {
+			latest = i
+		}
+	}
+
+	return latest
+}
+
+// forkRules returns the rules with every fork up to and including forkOrder[idx]
+// active, to evaluate fork defaults without a chain config.
+func forkRules(idx int) *chain.Rules {
+	rules := &chain.Rules{}
+	for _, fork := range forkOrder[:idx+1] {
+		fork.enable(rules)
+	}
+
+	return rules
+}
+
+// checkSupportedFork returns an error if the block predates the configured minimum
+// supported fork. Simulations are allowed at any fork when no minimum is set.
+func (s *Service) checkSupportedFork(ctx context.Context, opts executionOptions, blockNum, blockTime uint64) error {
+	if s.minSupportedFork < 0 {
+		return nil
+	}
+
+	rules := s.chainConfigFor(ctx, opts).Rules(blockNum, blockTime)
+
+	if idx := forkIndex(rules); idx < s.minSupportedFork {
+		return fmt.Errorf("block %d is at fork %s, which predates the minimum supported fork %s",
+			blockNum, forkOrder[idx].name, forkOrder[s.minSupportedFork].name)
+	}
+
+	return nil
+}
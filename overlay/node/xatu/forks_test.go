@@ -0,0 +1,168 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+func TestParseFork(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int
+		wantErr bool
+	}{
+		{name: "", want: -1},
+		{name: "frontier", want: 0},
+		{name: "Berlin", want: 8},
+		{name: "osaka", want: len(forkOrder) - 1},
+		{name: "paris", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseFork(tc.name)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected error for %q", tc.name)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("parseFork(%q) = %d, want %d", tc.name, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestForkIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules *chain.Rules
+		want  string
+	}{
+		{name: "frontier", rules: &chain.Rules{}, want: "frontier"},
+		{name: "homestead", rules: &chain.Rules{IsHomestead: true}, want: "homestead"},
+		{
+			name: "berlin",
+			rules: &chain.Rules{
+				IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true,
+				IsByzantium: true, IsConstantinople: true, IsPetersburg: true,
+				IsIstanbul: true, IsBerlin: true,
+			},
+			want: "berlin",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := forkOrder[forkIndex(tc.rules)].name; got != tc.want {
+				t.Errorf("forkIndex() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestGasScheduleAtForkActivation verifies that the schedule for a fork's activation
+// block already includes the fork's new parameters, and the block before does not.
+func TestGasScheduleAtForkActivation(t *testing.T) {
+	const (
+		istanbulBlock = 100
+		berlinBlock   = 200
+		londonBlock   = 300
+		cancunTime    = 20_000
+		slotTime      = 12
+	)
+
+	cfg := &chain.Config{
+		ChainID:               big.NewInt(1),
+		HomesteadBlock:        big.NewInt(0),
+		TangerineWhistleBlock: big.NewInt(0),
+		SpuriousDragonBlock:   big.NewInt(0),
+		ByzantiumBlock:        big.NewInt(0),
+		ConstantinopleBlock:   big.NewInt(0),
+		PetersburgBlock:       big.NewInt(0),
+		IstanbulBlock:         big.NewInt(istanbulBlock),
+		BerlinBlock:           big.NewInt(berlinBlock),
+		LondonBlock:           big.NewInt(londonBlock),
+		ShanghaiTime:          big.NewInt(10_000),
+		CancunTime:            big.NewInt(cancunTime),
+	}
+
+	tests := []struct {
+		fork     string
+		before   [2]uint64 // block number, timestamp
+		at       [2]uint64
+		newParam []string
+	}{
+		{
+			fork:     "istanbul",
+			before:   [2]uint64{istanbulBlock - 1, 0},
+			at:       [2]uint64{istanbulBlock, 0},
+			newParam: []string{vm.GasKeySstoreSet, vm.GasKeySstoreReset},
+		},
+		{
+			fork:     "berlin",
+			before:   [2]uint64{berlinBlock - 1, 0},
+			at:       [2]uint64{berlinBlock, 0},
+			newParam: []string{vm.GasKeySloadCold, vm.GasKeySloadWarm, vm.GasKeyCallCold},
+		},
+		{
+			fork:     "cancun",
+			before:   [2]uint64{londonBlock + 1000, cancunTime - slotTime},
+			at:       [2]uint64{londonBlock + 1001, cancunTime},
+			newParam: []string{vm.TLOAD.String(), vm.TSTORE.String()},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.fork, func(t *testing.T) {
+			before := gasScheduleForBlock(cfg, tc.before[0], tc.before[1]).Parameters
+			at := gasScheduleForBlock(cfg, tc.at[0], tc.at[1]).Parameters
+
+			for _, name := range tc.newParam {
+				if _, ok := before[name]; ok {
+					t.Errorf("%s present one block before %s activation", name, tc.fork)
+				}
+
+				if _, ok := at[name]; !ok {
+					t.Errorf("%s missing at the %s activation block", name, tc.fork)
+				}
+			}
+		})
+	}
+
+	// Istanbul also reprices calldata (EIP-2028) rather than adding a key
+	if got := gasScheduleForBlock(cfg, istanbulBlock-1, 0).Parameters[vm.GasKeyTxDataNonZero].Value; got != 68 {
+		t.Errorf("TX_DATA_NONZERO before Istanbul = %d, want 68", got)
+	}
+
+	if got := gasScheduleForBlock(cfg, istanbulBlock, 0).Parameters[vm.GasKeyTxDataNonZero].Value; got != 16 {
+		t.Errorf("TX_DATA_NONZERO at Istanbul = %d, want 16", got)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+// GasAccounting reconciles one side (original or simulated) of a block's opcode
+// breakdown with the gas charged by the EVM.
+//
+// OpcodeBreakdown entries other than TX_INTRINSIC hold execution gas only. Intrinsic
+// gas is never folded into them and appears solely in the synthetic TX_INTRINSIC
+// entry, so summing every entry (TX_INTRINSIC included) gives the gas charged before
+// refunds, without double-counting:
+//
+//	sum(opcode gas) + TX_INTRINSIC gas == intrinsic gas + execution gas
+//
+// The block's GasUsed is net of refunds (and bounded below by the EIP-7623 calldata
+// floor), so it is not expected to equal the sum of the breakdown.
+type GasAccounting struct {
+	OpcodeGas    uint64 `json:"opcodeGas"`    // Sum of opcode and precompile entries, excluding TX_INTRINSIC
+	IntrinsicGas uint64 `json:"intrinsicGas"` // TX_INTRINSIC gas
+	ExecutionGas uint64 `json:"executionGas"` // Gas used by the top-level frames, before refunds
+	// UnattributedGas is ExecutionGas - OpcodeGas. It is nonzero when gas was consumed
+	// outside any traced opcode (e.g. the gas left in a frame that ran out of gas, or
+	// the code deposit of a contract creation transaction), or when the tracer totals
+	// drift from the EVM's.
+	UnattributedGas int64 `json:"unattributedGas"`
+}
+
+// BlockGasAccounting holds the gas accounting check for both executions of a block.
+type BlockGasAccounting struct {
+	Original  GasAccounting `json:"original"`
+	Simulated GasAccounting `json:"simulated"`
+}
+
+// add accumulates a transaction's execution with the given opcode gas.
+func (a *GasAccounting) add(result *executionResult, opcodeGas uint64) {
+	a.OpcodeGas += opcodeGas
+	a.IntrinsicGas += result.chargedIntrinsicGas()
+	a.ExecutionGas += result.ExecutionGas
+	a.UnattributedGas = int64(a.ExecutionGas) - int64(a.OpcodeGas)
+}
+
+// chargedIntrinsicGas returns the intrinsic gas actually charged for an execution.
+// A transaction that failed before the EVM ran (e.g. intrinsic gas too low) or
+// panicked was not charged any gas, so it contributes nothing to TX_INTRINSIC.
+func (r *executionResult) chargedIntrinsicGas() uint64 {
+	if r.ApplyErr != nil || r.Panicked {
+		return 0
+	}
+
+	return r.IntrinsicGas
+}
+
+// opcodeGasTotals sums a transaction's per-opcode gas for both executions. The
+// synthetic TX_SIGNATURE entry is skipped, as it is never charged.
+func opcodeGasTotals(breakdown map[string]OpcodeSummary) (original, simulated uint64) {
+	for name, summary := range breakdown {
+		if name == GasKeyTxSignature {
+			continue
+		}
+
+		original += summary.OriginalGas
+		simulated += summary.SimulatedGas
+	}
+
+	return original, simulated
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// maxGasBoundaryIterations bounds the block simulations of one xatu_findGasBoundary
+// call. Each halves the search range, so 32 locate the exact threshold for any
+// value below 2^32, well above any block gas limit.
+const maxGasBoundaryIterations = 32
+
+// FindGasBoundaryResult is the result of xatu_findGasBoundary.
+type FindGasBoundaryResult struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	TargetKey   string `json:"targetKey"`
+	// DefaultValue is the key's standard value at the block, the lower end of the search.
+	DefaultValue uint64 `json:"defaultValue"`
+	// UpperBound is the upper end of the search: the block gas limit, at which a
+	// single execution of a priced opcode can no longer succeed.
+	UpperBound uint64 `json:"upperBound"`
+	// MaxSafeValue is the highest value found at which every transaction keeps
+	// succeeding, and Threshold the lowest at which one flips from success to
+	// failure. They are adjacent unless the iteration bound was reached.
+	MaxSafeValue uint64 `json:"maxSafeValue"`
+	Threshold    uint64 `json:"threshold,omitempty"`
+	// Multiplier is MaxSafeValue / DefaultValue (0 when the default is 0).
+	Multiplier float64 `json:"multiplier"`
+	// Found is false when no transaction failed even at UpperBound, e.g. because
+	// the block never executes the key's opcode.
+	Found bool `json:"found"`
+	// Exact is set when MaxSafeValue and Threshold are adjacent.
+	Exact bool `json:"exact"`
+	// FlippedTx is the first transaction (by index) that fails at Threshold.
+	FlippedTx *TxSummary `json:"flippedTx,omitempty"`
+	// Simulations is the number of block simulations the search ran.
+	Simulations int `json:"simulations"`
+}
+
+// FindGasBoundary finds the highest value of targetKey at which every transaction
+// of the block that succeeded on chain still succeeds. It binary-searches the key's
+// override between its standard value and the block gas limit, simulating the
+// whole block (with SimulateBlockGas) at each step.
+//
+// This is expensive: a search runs up to maxGasBoundaryIterations + 1 block
+// simulations, each executing every transaction twice.
+func (s *Service) FindGasBoundary(ctx context.Context, blockNumber uint64, targetKey string) (*FindGasBoundaryResult, error) {
+	if targetKey == "" {
+		return nil, fmt.Errorf("target key is required")
+	}
+
+	header, err := s.headerByNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := s.chainConfigFor(ctx, executionOptions{}).Rules(blockNumber, header.Time)
+
+	defaultValue, ok := GasScheduleForRules(rules).Overrides[targetKey]
+	if !ok {
+		return nil, fmt.Errorf("gas key %s has no default value at block %d", targetKey, blockNumber)
+	}
+
+	firstFlip := func(value uint64) (*TxSummary, error) {
+		result, err := s.SimulateBlockGas(ctx, SimulateBlockGasRequest{
+			BlockNumber: blockNumber,
+			GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{targetKey: value}},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Dropped transactions could hide the first failure
+		if result.Truncated {
+			return nil, fmt.Errorf("block simulation was truncated (%s)", result.TruncationReason)
+		}
+
+		return firstStatusFlip(result.Transactions), nil
+	}
+
+	result := &FindGasBoundaryResult{
+		BlockNumber:  blockNumber,
+		TargetKey:    targetKey,
+		DefaultValue: defaultValue,
+		UpperBound:   max(header.GasLimit, defaultValue),
+	}
+
+	if err := result.search(firstFlip); err != nil {
+		return nil, fmt.Errorf("failed to find gas boundary for %s: %w", targetKey, err)
+	}
+
+	return result, nil
+}
+
+// headerByNumber reads a block's header in a short-lived transaction, so that it is
+// not held open across the simulations that follow.
+func (s *Service) headerByNumber(ctx context.Context, blockNumber uint64) (*erigontypes.Header, error) {
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	block, err := s.blockReader.BlockByNumber(ctx, tx, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %d: %w", blockNumber, err)
+	}
+
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+
+	return block.Header(), nil
+}
+
+// search binary-searches [DefaultValue, UpperBound] for the lowest value at which
+// firstFlip reports a failing transaction. The upper bound is simulated first, so a
+// block without a boundary costs a single simulation.
+func (r *FindGasBoundaryResult) search(firstFlip func(value uint64) (*TxSummary, error)) error {
+	flip, err := firstFlip(r.UpperBound)
+	r.Simulations++
+
+	if err != nil {
+		return err
+	}
+
+	if flip == nil {
+		r.MaxSafeValue = r.UpperBound
+		r.Multiplier = gasMultiplier(r.MaxSafeValue, r.DefaultValue)
+
+		return nil
+	}
+
+	// Invariant: lo keeps every transaction succeeding, hi flips one
+	lo, hi := r.DefaultValue, r.UpperBound
+	r.Found, r.FlippedTx = true, flip
+
+	for hi-lo > 1 && r.Simulations < maxGasBoundaryIterations+1 {
+		mid := lo + (hi-lo)/2
+
+		flip, err := firstFlip(mid)
+		r.Simulations++
+
+		if err != nil {
+			return err
+		}
+
+		if flip != nil {
+			hi, r.FlippedTx = mid, flip
+		} else {
+			lo = mid
+		}
+	}
+
+	r.MaxSafeValue, r.Threshold = lo, hi
+	r.Exact = hi-lo <= 1
+	r.Multiplier = gasMultiplier(r.MaxSafeValue, r.DefaultValue)
+
+	return nil
+}
+
+// firstStatusFlip returns the first transaction that succeeded in the original
+// execution but not in the simulated one, or nil if there is none.
+func firstStatusFlip(txs []TxSummary) *TxSummary {
+	for i := range txs {
+		if txs[i].OriginalStatus == "success" && txs[i].SimulatedStatus != "success" {
+			flip := txs[i]
+			return &flip
+		}
+	}
+
+	return nil
+}
+
+// gasMultiplier returns value / defaultValue, or 0 when the default is 0.
+func gasMultiplier(value, defaultValue uint64) float64 {
+	if defaultValue == 0 {
+		return 0
+	}
+
+	return float64(value) / float64(defaultValue)
+}
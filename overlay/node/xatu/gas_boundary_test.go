@@ -0,0 +1,99 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestGasBoundarySearch searches a block with one gas-sensitive transaction: ten
+// cold SLOADs under a 100000 gas limit, which succeed while 21000 + 10 * value fits.
+// A plain transfer and a transaction that already failed on chain never flip.
+func TestGasBoundarySearch(t *testing.T) {
+	block := func(value uint64) []TxSummary {
+		sensitive := "success"
+		if 21000+10*value > 100000 {
+			sensitive = "failed"
+		}
+
+		return []TxSummary{
+			{Index: 0, OriginalStatus: "success", SimulatedStatus: "success"},
+			{Index: 1, OriginalStatus: "failed", SimulatedStatus: "failed"},
+			{Index: 2, OriginalStatus: "success", SimulatedStatus: sensitive},
+		}
+	}
+
+	var tested []uint64
+
+	result := &FindGasBoundaryResult{TargetKey: "SLOAD_COLD", DefaultValue: 2100, UpperBound: 30_000_000}
+	err := result.search(func(value uint64) (*TxSummary, error) {
+		tested = append(tested, value)
+		return firstStatusFlip(block(value)), nil
+	})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+
+	if !result.Found || !result.Exact || result.MaxSafeValue != 7900 || result.Threshold != 7901 {
+		t.Errorf("boundary = %d..%d (found %v, exact %v), want 7900..7901",
+			result.MaxSafeValue, result.Threshold, result.Found, result.Exact)
+	}
+
+	if result.FlippedTx == nil || result.FlippedTx.Index != 2 {
+		t.Errorf("flipped tx = %+v, want index 2", result.FlippedTx)
+	}
+
+	if want := 7900.0 / 2100.0; result.Multiplier != want {
+		t.Errorf("multiplier = %v, want %v", result.Multiplier, want)
+	}
+
+	if result.Simulations != len(tested) || result.Simulations > maxGasBoundaryIterations+1 {
+		t.Errorf("ran %d simulations (%d recorded), bound is %d", result.Simulations, len(tested), maxGasBoundaryIterations+1)
+	}
+
+	if tested[0] != 30_000_000 {
+		t.Errorf("first simulation at %d, want the upper bound", tested[0])
+	}
+}
+
+// TestGasBoundaryNotFound checks that a block that never fails stops after one
+// simulation, and that simulation errors are returned.
+func TestGasBoundaryNotFound(t *testing.T) {
+	result := &FindGasBoundaryResult{DefaultValue: 3, UpperBound: 30_000_000}
+	if err := result.search(func(uint64) (*TxSummary, error) { return nil, nil }); err != nil {
+		t.Fatalf("search: %v", err)
+	}
+
+	if result.Found || result.Simulations != 1 || result.MaxSafeValue != 30_000_000 || result.Threshold != 0 {
+		t.Errorf("result = %+v, want no boundary after one simulation", result)
+	}
+
+	boom := errors.New("boom")
+	failing := &FindGasBoundaryResult{DefaultValue: 3, UpperBound: 30_000_000}
+
+	if err := failing.search(func(uint64) (*TxSummary, error) { return nil, boom }); !errors.Is(err, boom) {
+		t.Errorf("err = %v, want %v", err, boom)
+	}
+
+	if _, err := (&Service{}).FindGasBoundary(context.Background(), 1, ""); err == nil {
+		t.Error("expected an error for an empty target key")
+	}
+}
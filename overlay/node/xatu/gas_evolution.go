@@ -0,0 +1,125 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// gasKeySuccessors maps keys whose cost a fork moved to another key to that key.
+// Berlin (EIP-2929) split the cost of state access into cold and warm access
+// costs: SLOAD's constant gas was removed, and the account access opcodes keep
+// only the warm cost as their constant gas. A first access is charged the cold
+// cost, so from Berlin the evolution follows SLOAD_COLD or CALL_COLD (which the
+// call variants' cold keys follow when unset, see fallbackKeys).
+var gasKeySuccessors = map[string]string{
+	vm.SLOAD.String():        vm.GasKeySloadCold,
+	vm.BALANCE.String():      vm.GasKeyCallCold,
+	vm.EXTCODESIZE.String():  vm.GasKeyCallCold,
+	vm.EXTCODECOPY.String():  vm.GasKeyCallCold,
+	vm.EXTCODEHASH.String():  vm.GasKeyCallCold,
+	vm.CALL.String():         vm.GasKeyCallCold,
+	vm.CALLCODE.String():     vm.GasKeyCallCold,
+	vm.DELEGATECALL.String(): vm.GasKeyCallCold,
+	vm.STATICCALL.String():   vm.GasKeyCallCold,
+}
+
+// GasAtFork is a gas key's default value at one fork.
+type GasAtFork struct {
+	Fork string `json:"fork"`
+	// Value is the default at the fork, nil if the key is not applicable there
+	// (e.g. an opcode introduced by a later fork).
+	Value *uint64 `json:"value"`
+	// Status is "not applicable" when Value is nil.
+	Status string `json:"status,omitempty"`
+	// SourceKey is set when the value is read from a successor key (see
+	// gasKeySuccessors), e.g. SLOAD_COLD for SLOAD or CALL_COLD for BALANCE from
+	// Berlin.
+	SourceKey string `json:"sourceKey,omitempty"`
+	// Changed is set when the value differs from the previous fork's.
+	Changed bool `json:"changed"`
+}
+
+// GasEvolutionResult is the result of xatu_getGasEvolution.
+type GasEvolutionResult struct {
+	Key         string      `json:"key"`
+	Description string      `json:"description"`
+	Forks       []GasAtFork `json:"forks"` // One entry per fork in forkOrder, oldest first
+}
+
+// GetGasEvolution returns the default value of a gas key at every fork, evaluating
+// GasScheduleForRules for each fork's rules, to show how the parameter evolved. It
+// does not depend on the chain, so it is available before the node has synced.
+func (s *Service) GetGasEvolution(key string) (*GasEvolutionResult, error) {
+	return gasEvolution(key)
+}
+
+// gasEvolution builds the per-fork history of a key. A key with no default at any
+// fork is reported as unknown.
+func gasEvolution(key string) (*GasEvolutionResult, error) {
+	if key == "" {
+		return nil, fmt.Errorf("gas key is required")
+	}
+
+	result := &GasEvolutionResult{
+		Key:         key,
+		Description: gasKeyDescription(key, ""),
+		Forks:       make([]GasAtFork, 0, len(forkOrder)),
+	}
+
+	applicable := false
+
+	var previous *uint64
+
+	for i, fork := range forkOrder {
+		defaults := GasScheduleForRules(forkRules(i)).Overrides
+		entry := GasAtFork{Fork: fork.name}
+
+		// The successor takes over from the fork that introduced it
+		successor, hasSuccessor := gasKeySuccessors[key]
+		if value, ok := defaults[successor]; hasSuccessor && ok {
+			entry.Value = &value
+			entry.SourceKey = successor
+		} else if value, ok := defaults[key]; ok {
+			entry.Value = &value
+		}
+
+		if entry.Value == nil {
+			entry.Status = "not applicable"
+		} else {
+			applicable = true
+		}
+
+		if i > 0 {
+			entry.Changed = (previous == nil) != (entry.Value == nil) ||
+				(previous != nil && *previous != *entry.Value)
+		}
+
+		previous = entry.Value
+		result.Forks = append(result.Forks, entry)
+	}
+
+	if !applicable {
+		return nil, fmt.Errorf("unknown gas key %s: it has no default at any fork", key)
+	}
+
+	return result, nil
+}
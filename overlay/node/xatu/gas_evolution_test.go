@@ -0,0 +1,135 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestGasEvolutionSload checks SLOAD's history: 50 at Frontier, 200 from Tangerine
+// Whistle (EIP-150), 800 from Istanbul (EIP-1884) and the 2100 cold access cost
+// from Berlin (EIP-2929), which replaced the SLOAD key.
+func TestGasEvolutionSload(t *testing.T) {
+	result, err := gasEvolution(vm.SLOAD.String())
+	if err != nil {
+		t.Fatalf("gasEvolution: %v", err)
+	}
+
+	if len(result.Forks) != len(forkOrder) {
+		t.Fatalf("got %d forks, want %d", len(result.Forks), len(forkOrder))
+	}
+
+	byFork := make(map[string]GasAtFork, len(result.Forks))
+	for _, entry := range result.Forks {
+		byFork[entry.Fork] = entry
+	}
+
+	tests := []struct {
+		fork      string
+		want      uint64
+		sourceKey string
+		changed   bool
+	}{
+		{fork: "frontier", want: 50},
+		{fork: "homestead", want: 50},
+		{fork: "tangerinewhistle", want: 200, changed: true},
+		{fork: "istanbul", want: 800, changed: true},
+		{fork: "berlin", want: 2100, sourceKey: vm.GasKeySloadCold, changed: true},
+		{fork: "osaka", want: 2100, sourceKey: vm.GasKeySloadCold},
+	}
+
+	for _, tc := range tests {
+		got := byFork[tc.fork]
+		if got.Value == nil {
+			t.Errorf("%s: value not applicable, want %d", tc.fork, tc.want)
+			continue
+		}
+
+		if *got.Value != tc.want || got.SourceKey != tc.sourceKey || got.Changed != tc.changed {
+			t.Errorf("%s: got value %d from %q (changed %v), want %d from %q (changed %v)",
+				tc.fork, *got.Value, got.SourceKey, got.Changed, tc.want, tc.sourceKey, tc.changed)
+		}
+	}
+}
+
+// TestGasEvolutionNotApplicable checks that a key is reported as not applicable at
+// forks before the one that introduced it, and that unknown keys are rejected.
+func TestGasEvolutionNotApplicable(t *testing.T) {
+	result, err := gasEvolution(vm.TLOAD.String())
+	if err != nil {
+		t.Fatalf("gasEvolution: %v", err)
+	}
+
+	for _, entry := range result.Forks {
+		introduced := entry.Fork == "cancun" || entry.Fork == "prague" || entry.Fork == "osaka"
+
+		if introduced && (entry.Value == nil || *entry.Value != 100) {
+			t.Errorf("%s: TLOAD = %v, want 100", entry.Fork, entry.Value)
+		}
+
+		if !introduced && (entry.Value != nil || entry.Status != "not applicable") {
+			t.Errorf("%s: TLOAD = %v (%q), want not applicable", entry.Fork, entry.Value, entry.Status)
+		}
+
+		if entry.Changed != (entry.Fork == "cancun") {
+			t.Errorf("%s: changed = %v", entry.Fork, entry.Changed)
+		}
+	}
+
+	if _, err := gasEvolution("NOT_A_KEY"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+// TestGasEvolutionAccountAccess checks that the account access opcodes follow
+// CALL_COLD from Berlin rather than showing their warm constant gas as a drop, and
+// that the key is never reported as removed.
+func TestGasEvolutionAccountAccess(t *testing.T) {
+	for _, op := range []vm.OpCode{vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODECOPY, vm.EXTCODEHASH,
+		vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL} {
+		result, err := gasEvolution(op.String())
+		if err != nil {
+			t.Fatalf("gasEvolution(%s): %v", op, err)
+		}
+
+		berlin := false
+		for _, entry := range result.Forks {
+			berlin = berlin || entry.Fork == "berlin"
+
+			// STATICCALL and DELEGATECALL are introduced by later forks
+			if entry.Value == nil {
+				if berlin {
+					t.Errorf("%s at %s: not applicable", op, entry.Fork)
+				}
+				continue
+			}
+
+			if berlin && (*entry.Value != 2600 || entry.SourceKey != vm.GasKeyCallCold) {
+				t.Errorf("%s at %s: got %d from %q, want 2600 from %s",
+					op, entry.Fork, *entry.Value, entry.SourceKey, vm.GasKeyCallCold)
+			}
+
+			if !berlin && entry.SourceKey != "" {
+				t.Errorf("%s at %s: read from %q before Berlin", op, entry.Fork, entry.SourceKey)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// GasKeyEntry is a gas override key with its description.
+type GasKeyEntry struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+}
+
+// GasKeyCatalog is the result of xatu_listGasKeys: every override key the
+// simulation understands, grouped by how it is applied. Unlike xatu_getGasSchedule
+// it is static, listing keys for all forks without values.
+type GasKeyCatalog struct {
+	Opcode     []GasKeyEntry `json:"opcode"`     // Constant gas of an opcode, keyed by its name
+	Dynamic    []GasKeyEntry `json:"dynamic"`    // Parameters of the patched dynamic gas functions
+	Precompile []GasKeyEntry `json:"precompile"` // Precompile costs and formula parameters
+	Intrinsic  []GasKeyEntry `json:"intrinsic"`  // Transaction costs charged before EVM execution
+	Blob       []GasKeyEntry `json:"blob"`       // Blob gas pricing, applied outside the EVM
+	Synthetic  []GasKeyEntry `json:"synthetic"`  // Non-consensus costs, shown in the breakdown only
+	// Patterns are key families with an <OPCODE> placeholder.
+	Patterns []GasKeyEntry `json:"patterns"`
+}
+
+// catalogDescriptions describes keys that are deliberately left out of
+// xatu_getGasSchedule, and therefore have no entry in gasDescriptions.
+var catalogDescriptions = map[string]string{
+	vm.GasKeyCallWarm: "Warm account access cost (100 gas), used to derive the cold surcharge (CALL_COLD - CALL_WARM). Override the opcode base costs instead to change warm access. Post-Berlin (EIP-2929).",
+	GasKeyTxSignature: "Speculative, non-consensus cost of recovering the sender from the transaction signature, which no fork meters. Only adds a TX_SIGNATURE breakdown entry; gas used is unchanged.",
+}
+
+// linearGasPatterns describes the LINEAR_<OPCODE>_* keys (see linearGasModels).
+var linearGasPatterns = []GasKeyEntry{
+	{Key: linearGasPrefix + "<OPCODE>" + linearGasBase, Description: "Linear dynamic gas model: fixed cost added to the opcode's constant gas."},
+	{Key: linearGasPrefix + "<OPCODE>" + linearGasPerWord, Description: "Linear dynamic gas model: cost per 32-byte word of the size operand."},
+	{Key: linearGasPrefix + "<OPCODE>" + linearGasPerItem, Description: "Linear dynamic gas model: cost per unit (e.g. byte) of the size operand."},
+	{Key: linearGasPrefix + "<OPCODE>" + linearGasSizeArg, Description: "Linear dynamic gas model: stack position of the size operand (default 1)."},
+}
+
+// gasKeyDescription returns the description of a key, falling back to fallback.
+func gasKeyDescription(key, fallback string) string {
+	if desc, ok := gasDescriptions[key]; ok {
+		return desc
+	}
+
+	if desc, ok := catalogDescriptions[key]; ok {
+		return desc
+	}
+
+	return fallback
+}
+
+// gasKeyEntries builds sorted catalog entries for keys.
+func gasKeyEntries(keys []string) []GasKeyEntry {
+	entries := make([]GasKeyEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, GasKeyEntry{Key: key, Description: gasKeyDescription(key, "")})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries
+}
+
+// newGasKeyCatalog assembles the catalog from the opcode table and the key lists of
+// the vm package. Opcodes are listed in opcode order.
+func newGasKeyCatalog() *GasKeyCatalog {
+	opcodes := make([]string, 0, len(opcodeMap))
+	for name := range opcodeMap {
+		opcodes = append(opcodes, name)
+	}
+
+	sort.Slice(opcodes, func(i, j int) bool {
+		return opcodeMap[opcodes[i]] < opcodeMap[opcodes[j]]
+	})
+
+	opcodeEntries := make([]GasKeyEntry, 0, len(opcodes))
+	for _, name := range opcodes {
+		opcodeEntries = append(opcodeEntries, GasKeyEntry{
+			Key:         name,
+			Description: gasKeyDescription(name, "Constant gas cost of "+name+"."),
+		})
+	}
+
+	return &GasKeyCatalog{
+		Opcode:     opcodeEntries,
+		Dynamic:    gasKeyEntries(vm.DynamicGasKeys),
+		Precompile: gasKeyEntries(vm.PrecompileGasKeys),
+		Intrinsic:  gasKeyEntries(vm.IntrinsicGasKeys),
+		Blob:       gasKeyEntries(blobGasKeys),
+		Synthetic:  gasKeyEntries(syntheticGasKeys),
+		Patterns:   slices.Concat(linearGasPatterns, memoryKeyPatterns),
+	}
+}
+
+// ListGasKeys returns the catalog of gas override keys. It does not depend on the
+// chain, so it is available before the node has synced.
+func (s *Service) ListGasKeys() *GasKeyCatalog {
+	return newGasKeyCatalog()
+}
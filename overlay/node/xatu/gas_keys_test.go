@@ -0,0 +1,65 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "testing"
+
+// TestGasKeyCatalogComplete verifies that every documented override key is in the
+// catalog exactly once, and that all non-opcode keys carry a description.
+func TestGasKeyCatalogComplete(t *testing.T) {
+	catalog := newGasKeyCatalog()
+
+	seen := make(map[string]string)
+	for group, entries := range map[string][]GasKeyEntry{
+		"opcode":     catalog.Opcode,
+		"dynamic":    catalog.Dynamic,
+		"precompile": catalog.Precompile,
+		"intrinsic":  catalog.Intrinsic,
+		"blob":       catalog.Blob,
+		"synthetic":  catalog.Synthetic,
+	} {
+		for _, entry := range entries {
+			if other, ok := seen[entry.Key]; ok {
+				t.Errorf("%s is listed under both %s and %s", entry.Key, other, group)
+			}
+			seen[entry.Key] = group
+
+			if group != "opcode" && entry.Description == "" {
+				t.Errorf("%s key %s has no description", group, entry.Key)
+			}
+		}
+	}
+
+	for key := range gasDescriptions {
+		// TX_INTRINSIC is a breakdown entry, not an override
+		if key == "TX_INTRINSIC" {
+			continue
+		}
+
+		if _, ok := seen[key]; !ok {
+			t.Errorf("documented key %s is missing from the catalog", key)
+		}
+	}
+
+	for name := range opcodeMap {
+		if seen[name] != "opcode" {
+			t.Errorf("opcode %s is not listed as an opcode key", name)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "fmt"
+
+// GasLimitStop records where a block simulation with StopIfExceedsLimit stopped.
+type GasLimitStop struct {
+	// TxIndex is the index in the block of the transaction that pushed the
+	// simulated gas over the block gas limit. It is the last one simulated.
+	TxIndex uint64 `json:"txIndex"`
+	// SimulatedGasUsed is the block's simulated gas used up to and including it.
+	SimulatedGasUsed uint64 `json:"simulatedGasUsed"`
+	// SkippedTxs is the number of selected transactions left unsimulated.
+	SkippedTxs uint64 `json:"skippedTxs"`
+}
+
+// stopAtGasLimit reports whether a block simulation should stop after txIndex,
+// because the simulated gas exceeds the block gas limit and the repricing already
+// breaks the block. The result is then marked truncated, with the stop recorded in
+// GasLimitStop; remaining is the number of selected transactions not yet simulated.
+func (r *SimulateBlockGasResult) stopAtGasLimit(txIndex, remaining int) bool {
+	if r.Simulated.GasUsed <= r.Simulated.GasLimit {
+		return false
+	}
+
+	r.GasLimitStop = &GasLimitStop{
+		TxIndex:          uint64(txIndex),
+		SimulatedGasUsed: r.Simulated.GasUsed,
+		SkippedTxs:       uint64(remaining),
+	}
+
+	r.Truncated = true
+	r.TruncationReason = fmt.Sprintf(
+		"simulated gas %d exceeded the block gas limit %d at tx %d: skipped %d transactions",
+		r.Simulated.GasUsed, r.Simulated.GasLimit, txIndex, remaining,
+	)
+
+	return true
+}
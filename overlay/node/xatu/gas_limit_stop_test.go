@@ -0,0 +1,82 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestStopAtGasLimit simulates a block of ten 21000 gas transfers under a
+// repricing that makes each cost 30000, so the simulated gas crosses the 100000
+// block gas limit at the fourth transaction.
+func TestStopAtGasLimit(t *testing.T) {
+	const (
+		txCount  = 10
+		gasLimit = 100000
+	)
+
+	run := func(stop bool) *SimulateBlockGasResult {
+		result := &SimulateBlockGasResult{
+			Original:        BlockGasSummary{GasLimit: gasLimit},
+			Simulated:       BlockGasSummary{GasLimit: gasLimit},
+			OpcodeBreakdown: make(map[string]OpcodeSummary),
+		}
+
+		for i := 0; i < txCount; i++ {
+			result.addDualResult(fmt.Sprintf("0x%064x", i), i, &dualExecutionResult{
+				Original:  &executionResult{GasUsed: 21000, IntrinsicGas: 21000, Status: "success"},
+				Simulated: &executionResult{GasUsed: 30000, IntrinsicGas: 30000, Status: "success"},
+			})
+
+			if stop && result.stopAtGasLimit(i, txCount-i-1) {
+				break
+			}
+		}
+
+		return result
+	}
+
+	full := run(false)
+	if full.GasLimitStop != nil || len(full.Transactions) != txCount {
+		t.Fatalf("simulation without StopIfExceedsLimit stopped: %+v", full.GasLimitStop)
+	}
+
+	partial := run(true)
+
+	want := GasLimitStop{TxIndex: 3, SimulatedGasUsed: 120000, SkippedTxs: 6}
+	if partial.GasLimitStop == nil || *partial.GasLimitStop != want {
+		t.Fatalf("GasLimitStop = %+v, want %+v", partial.GasLimitStop, want)
+	}
+
+	if !partial.Truncated || partial.TruncationReason == "" {
+		t.Error("partial result is not marked truncated")
+	}
+
+	if len(partial.Transactions) != 4 || partial.Original.GasUsed != 84000 {
+		t.Errorf("partial result has %d transactions and %d original gas, want 4 and 84000",
+			len(partial.Transactions), partial.Original.GasUsed)
+	}
+
+	// A block that stays under the limit is simulated in full
+	result := &SimulateBlockGasResult{Simulated: BlockGasSummary{GasLimit: gasLimit, GasUsed: gasLimit}}
+	if result.stopAtGasLimit(0, 5) || result.Truncated {
+		t.Error("stopped at exactly the gas limit")
+	}
+}
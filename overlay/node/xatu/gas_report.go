@@ -0,0 +1,204 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GasReportParameter is a gas parameter of the report's schedule: the value the
+// simulation used, the fork default, and whether the request overrode it.
+type GasReportParameter struct {
+	Value       uint64 `json:"value"`
+	Default     uint64 `json:"default"`
+	Overridden  bool   `json:"overridden"`
+	Description string `json:"description"`
+}
+
+// GasReportOpcode is an opcode breakdown entry tagged with its category.
+type GasReportOpcode struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	OpcodeSummary
+}
+
+// CategoryGas is the gas used by a category of opcodes in both executions, and its
+// share of the block's gas used.
+type CategoryGas struct {
+	OriginalGas         uint64  `json:"originalGas"`
+	SimulatedGas        uint64  `json:"simulatedGas"`
+	OriginalGasPercent  float64 `json:"originalGasPercent"`
+	SimulatedGasPercent float64 `json:"simulatedGasPercent"`
+}
+
+// GasReportResult is the result of xatu_gasReport.
+type GasReportResult struct {
+	BlockNumber uint64                        `json:"blockNumber"`
+	Schedule    map[string]GasReportParameter `json:"schedule"`
+	Original    BlockGasSummary               `json:"original"`
+	Simulated   BlockGasSummary               `json:"simulated"`
+	// Opcodes is the block's opcode breakdown, sorted by simulated gas (largest first).
+	Opcodes    []GasReportOpcode      `json:"opcodes"`
+	Categories map[string]CategoryGas `json:"categories"`
+	// StatusFlips lists the transactions whose status differs between executions.
+	StatusFlips []TxSummary `json:"statusFlips"`
+	// Truncated is set when the block simulation was truncated (see SimulateBlockGasResult).
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// GasReport returns, in one response, what a dashboard needs on initial load: the
+// block's gas schedule with the overrides highlighted, the block simulation totals,
+// the categorised opcode breakdown and the transactions whose status flipped.
+// It composes xatu_getGasSchedule and xatu_simulateBlockGas.
+func (s *Service) GasReport(ctx context.Context, blockNumber uint64, schedule *CustomGasSchedule) (*GasReportResult, error) {
+	defaults, err := s.GetGasSchedule(ctx, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas schedule: %w", err)
+	}
+
+	block, err := s.SimulateBlockGas(ctx, SimulateBlockGasRequest{
+		BlockNumber: blockNumber,
+		GasSchedule: schedule,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate block: %w", err)
+	}
+
+	return newGasReport(defaults, schedule, block), nil
+}
+
+// newGasReport assembles a gas report from the fork's default schedule, the
+// requested overrides and the block simulation result.
+func newGasReport(defaults *GasScheduleResponse, schedule *CustomGasSchedule, block *SimulateBlockGasResult) *GasReportResult {
+	report := &GasReportResult{
+		BlockNumber: block.BlockNumber,
+		Schedule:    make(map[string]GasReportParameter, len(defaults.Parameters)),
+		Original:    block.Original,
+		Simulated:   block.Simulated,
+		Opcodes:     make([]GasReportOpcode, 0, len(block.OpcodeBreakdown)),
+		Categories:  make(map[string]CategoryGas, 16),
+		StatusFlips: []TxSummary{},
+		Truncated:   block.Truncated,
+	}
+
+	for name, param := range defaults.Parameters {
+		report.Schedule[name] = GasReportParameter{
+			Value:       param.Value,
+			Default:     param.Value,
+			Description: param.Description,
+		}
+	}
+
+	if schedule != nil {
+		for name, value := range schedule.Overrides {
+			param, ok := report.Schedule[name]
+			if !ok {
+				// Not a parameter of this fork; reported so the override is not silently lost
+				param.Description = gasDescriptions[name]
+			}
+
+			param.Value = value
+			param.Overridden = true
+			report.Schedule[name] = param
+		}
+	}
+
+	for name, summary := range block.OpcodeBreakdown {
+		category := opcodeCategory(name)
+
+		report.Opcodes = append(report.Opcodes, GasReportOpcode{
+			Name:          name,
+			Category:      category,
+			OpcodeSummary: summary,
+		})
+
+		total := report.Categories[category]
+		total.OriginalGas += summary.OriginalGas
+		total.SimulatedGas += summary.SimulatedGas
+		report.Categories[category] = total
+	}
+
+	for category, total := range report.Categories {
+		total.OriginalGasPercent = gasPercent(total.OriginalGas, block.Original.GasUsed)
+		total.SimulatedGasPercent = gasPercent(total.SimulatedGas, block.Simulated.GasUsed)
+		report.Categories[category] = total
+	}
+
+	sort.Slice(report.Opcodes, func(i, j int) bool {
+		if report.Opcodes[i].SimulatedGas != report.Opcodes[j].SimulatedGas {
+			return report.Opcodes[i].SimulatedGas > report.Opcodes[j].SimulatedGas
+		}
+
+		return report.Opcodes[i].Name < report.Opcodes[j].Name
+	})
+
+	for _, tx := range block.Transactions {
+		if tx.OriginalStatus != tx.SimulatedStatus {
+			report.StatusFlips = append(report.StatusFlips, tx)
+		}
+	}
+
+	return report
+}
+
+// opcodeCategory groups an opcode breakdown entry (an opcode, precompile or
+// intrinsic gas entry) for reporting. The groups follow the sections of
+// gasDescriptions, with some merged.
+func opcodeCategory(name string) string {
+	switch {
+	case strings.HasPrefix(name, "PC_"):
+		return "precompile"
+	case strings.HasPrefix(name, "TX_"):
+		return "intrinsic"
+	case strings.HasPrefix(name, "PUSH"), strings.HasPrefix(name, "DUP"), strings.HasPrefix(name, "SWAP"), name == "POP":
+		return "stack"
+	case strings.HasPrefix(name, "LOG"):
+		return "log"
+	}
+
+	switch name {
+	case "ADD", "SUB", "MUL", "DIV", "SDIV", "MOD", "SMOD", "ADDMOD", "MULMOD", "EXP", "SIGNEXTEND":
+		return "arithmetic"
+	case "LT", "GT", "SLT", "SGT", "EQ", "ISZERO", "AND", "OR", "XOR", "NOT", "BYTE", "SHL", "SHR", "SAR", "CLZ":
+		return "bitwise"
+	case "MLOAD", "MSTORE", "MSTORE8", "MSIZE", "MCOPY":
+		return "memory"
+	case "SLOAD", "SSTORE", "TLOAD", "TSTORE":
+		return "storage"
+	case "CALL", "CALLCODE", "DELEGATECALL", "STATICCALL":
+		return "call"
+	case "CREATE", "CREATE2", "SELFDESTRUCT":
+		return "create"
+	case "KECCAK256":
+		return "hashing"
+	case "JUMP", "JUMPI", "JUMPDEST", "PC", "STOP", "RETURN", "REVERT", "INVALID":
+		return "control"
+	case "EXTCODESIZE", "EXTCODECOPY", "EXTCODEHASH", "CODESIZE", "CODECOPY",
+		"CALLDATALOAD", "CALLDATASIZE", "CALLDATACOPY", "RETURNDATASIZE", "RETURNDATACOPY",
+		"BLOCKHASH", "COINBASE", "TIMESTAMP", "NUMBER", "DIFFICULTY", "GASLIMIT", "CHAINID",
+		"BASEFEE", "BLOBBASEFEE", "BLOBHASH", "BALANCE", "SELFBALANCE", "ORIGIN", "CALLER",
+		"CALLVALUE", "ADDRESS", "GASPRICE", "GAS":
+		return "environment"
+	}
+
+	return "other"
+}
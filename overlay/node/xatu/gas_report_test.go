@@ -0,0 +1,100 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "testing"
+
+func TestNewGasReport(t *testing.T) {
+	defaults := &GasScheduleResponse{Parameters: map[string]GasParameter{
+		"SLOAD_COLD": {Value: 2100, Description: "cold"},
+		"ADD":        {Value: 3, Description: "add"},
+	}}
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 4200, "CLZ": 5}}
+
+	block := &SimulateBlockGasResult{
+		BlockNumber: 100,
+		Original:    BlockGasSummary{GasUsed: 10000},
+		Simulated:   BlockGasSummary{GasUsed: 20000},
+		Transactions: []TxSummary{
+			{Hash: "0x01", OriginalStatus: "success", SimulatedStatus: "success"},
+			{Hash: "0x02", OriginalStatus: "success", SimulatedStatus: "failed"},
+		},
+		OpcodeBreakdown: map[string]OpcodeSummary{
+			"SLOAD":        {OriginalGas: 2100, SimulatedGas: 4200},
+			"SSTORE":       {OriginalGas: 2900, SimulatedGas: 2900},
+			"ADD":          {OriginalGas: 3, SimulatedGas: 3},
+			"TX_INTRINSIC": {OriginalGas: 5000, SimulatedGas: 12897},
+		},
+	}
+
+	report := newGasReport(defaults, schedule, block)
+
+	if p := report.Schedule["SLOAD_COLD"]; p.Value != 4200 || p.Default != 2100 || !p.Overridden {
+		t.Errorf("SLOAD_COLD = %+v, want overridden 2100 -> 4200", p)
+	}
+
+	if p := report.Schedule["ADD"]; p.Value != 3 || p.Overridden {
+		t.Errorf("ADD = %+v, want default 3", p)
+	}
+
+	if p, ok := report.Schedule["CLZ"]; !ok || p.Value != 5 || p.Default != 0 || !p.Overridden {
+		t.Errorf("CLZ = %+v (present %v), want override without a fork default", p, ok)
+	}
+
+	if len(report.Opcodes) != 4 || report.Opcodes[0].Name != "TX_INTRINSIC" || report.Opcodes[1].Name != "SLOAD" {
+		t.Errorf("opcodes not sorted by simulated gas: %+v", report.Opcodes)
+	}
+
+	storage := report.Categories["storage"]
+	if storage.OriginalGas != 5000 || storage.SimulatedGas != 7100 {
+		t.Errorf("storage category = %+v, want 5000/7100", storage)
+	}
+
+	if storage.OriginalGasPercent != 50 {
+		t.Errorf("storage original percent = %v, want 50", storage.OriginalGasPercent)
+	}
+
+	if len(report.StatusFlips) != 1 || report.StatusFlips[0].Hash != "0x02" {
+		t.Errorf("status flips = %+v, want only 0x02", report.StatusFlips)
+	}
+}
+
+func TestOpcodeCategory(t *testing.T) {
+	tests := map[string]string{
+		"PUSH32":       "stack",
+		"SWAP16":       "stack",
+		"POP":          "stack",
+		"LOG3":         "log",
+		"SLOAD":        "storage",
+		"TSTORE":       "storage",
+		"STATICCALL":   "call",
+		"PC_SHA256":    "precompile",
+		"TX_INTRINSIC": "intrinsic",
+		"PC":           "control",
+		"KECCAK256":    "hashing",
+		"CALLDATALOAD": "environment",
+		"UNKNOWN":      "other",
+	}
+
+	for name, want := range tests {
+		if got := opcodeCategory(name); got != want {
+			t.Errorf("opcodeCategory(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
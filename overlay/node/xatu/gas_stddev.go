@@ -0,0 +1,54 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "math"
+
+// gasVariance is a running variance of an opcode's single charges (Welford's
+// algorithm), so the spread is known without storing every charge.
+type gasVariance struct {
+	n    uint64
+	mean float64
+	m2   float64 // Sum of squared deviations from the mean
+}
+
+// observe adds weight charges of the same cost, as when opcodes are sampled.
+func (v *gasVariance) observe(cost, weight uint64) {
+	x := float64(cost)
+	v.n += weight
+
+	delta := x - v.mean
+	v.mean += delta * float64(weight) / float64(v.n)
+	v.m2 += delta * (x - v.mean) * float64(weight)
+}
+
+// stdDev returns the population standard deviation of the observed charges.
+func (v gasVariance) stdDev() float64 {
+	if v.n == 0 {
+		return 0
+	}
+
+	return math.Sqrt(v.m2 / float64(v.n))
+}
+
+// gasStdDevOf returns the standard deviation of an opcode's charges, or 0 when
+// TrackGasStdDev is disabled or the opcode was never charged.
+func (t *SimulationTracer) gasStdDevOf(opName string) float64 {
+	return t.gasVariances[opName].stdDev()
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"math"
+	"testing"
+)
+
+// TestGasStdDev records a 50/50 mix of cold (2100) and warm (100) SLOADs, whose
+// standard deviation is half the spread, 1000, both charge by charge and sampled.
+func TestGasStdDev(t *testing.T) {
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{TrackGasStdDev: true})
+
+	for i := range 10 {
+		tracer.recordGas("SLOAD", []uint64{2100, 100}[i%2])
+	}
+
+	// Sampled charges stand for several of the same cost
+	tracer.recordWeightedGas("MLOAD", 2100, 4)
+	tracer.recordWeightedGas("MLOAD", 100, 4)
+
+	tracer.recordGas("ADD", 3)
+	tracer.recordGas("ADD", 3)
+
+	for opcode, want := range map[string]float64{"SLOAD": 1000, "MLOAD": 1000, "ADD": 0, "MUL": 0} {
+		if got := tracer.gasStdDevOf(opcode); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s standard deviation = %v, want %v", opcode, got, want)
+		}
+	}
+
+	tracer.Reset()
+
+	if got := tracer.gasStdDevOf("SLOAD"); got != 0 {
+		t.Errorf("standard deviation after reset = %v, want 0", got)
+	}
+
+	// Off by default
+	untracked := NewSimulationTracer(nil, SimulationTracerConfig{})
+	untracked.recordGas("SLOAD", 2100)
+	untracked.recordGas("SLOAD", 100)
+
+	if got := untracked.gasStdDevOf("SLOAD"); got != 0 {
+		t.Errorf("untracked standard deviation = %v, want 0", got)
+	}
+}
@@ -12,7 +12,12 @@ import (
 // calcIntrinsicGasForTx calculates intrinsic gas for a transaction, optionally
 // applying custom gas schedule overrides. Uses fixedgas.IntrinsicGas (v3 branch).
 func calcIntrinsicGasForTx(txn erigontypes.Transaction, chainRules *chain.Rules, gasSchedule *CustomGasSchedule) uint64 {
-	accessList := txn.GetAccessList()
+	return calcIntrinsicGas(txn.GetData(), txn.GetAccessList(), txn.GetTo() == nil, chainRules, gasSchedule)
+}
+
+// calcIntrinsicGas calculates intrinsic gas from the fields of a transaction or
+// call message that it depends on.
+func calcIntrinsicGas(data []byte, accessList erigontypes.AccessList, isCreate bool, chainRules *chain.Rules, gasSchedule *CustomGasSchedule) uint64 {
 	var accessListLen, storageKeysLen uint64
 	if accessList != nil {
 		accessListLen = uint64(len(accessList))
@@ -20,8 +25,8 @@ func calcIntrinsicGasForTx(txn erigontypes.Transaction, chainRules *chain.Rules,
 	}
 
 	intrinsicGas, _, _ := fixedgas.IntrinsicGas(
-		txn.GetData(), accessListLen, storageKeysLen,
-		txn.GetTo() == nil, chainRules.IsHomestead, chainRules.IsIstanbul,
+		data, accessListLen, storageKeysLen,
+		isCreate, chainRules.IsHomestead, chainRules.IsIstanbul,
 		chainRules.IsShanghai, chainRules.IsPrague, false, 0,
 	)
 
@@ -29,8 +34,8 @@ func calcIntrinsicGasForTx(txn erigontypes.Transaction, chainRules *chain.Rules,
 		vmSchedule := gasSchedule.ToVMGasSchedule()
 		if vmSchedule != nil && vmSchedule.HasIntrinsicOverrides() {
 			intrinsicGas, _ = vm.CalcCustomIntrinsicGas(
-				vmSchedule, txn.GetData(), accessListLen, storageKeysLen,
-				txn.GetTo() == nil, chainRules.IsHomestead, chainRules.IsIstanbul,
+				vmSchedule, data, accessListLen, storageKeysLen,
+				isCreate, chainRules.IsHomestead, chainRules.IsIstanbul,
 				chainRules.IsShanghai, chainRules.IsPrague, false, 0,
 			)
 		}
@@ -19,19 +19,103 @@
 package xatu
 
 import (
+	"github.com/erigontech/erigon/common"
 	"github.com/erigontech/erigon/execution/chain"
 	"github.com/erigontech/erigon/execution/vm"
 )
 
+// JumpTableOptions swaps gas functions in a custom JumpTable.
+type JumpTableOptions struct {
+	// DisableEIP2929 installs the pre-Berlin flat-cost gas functions for state access
+	// opcodes, regardless of the block's fork. No-op before Berlin. From London on,
+	// their refunds follow EIP-3529 (see vm.UseEIP3529Refunds) unless LegacyRefunds
+	// is set.
+	DisableEIP2929 bool
+
+	// LegacyRefunds installs the pre-London SSTORE and SELFDESTRUCT refunds, reverting
+	// EIP-3529 (see vm.UseLegacyRefunds). No-op before London. With DisableEIP2929,
+	// the flat-cost gas functions keep their own pre-London refunds.
+	LegacyRefunds bool
+
+	// ForceAllCold charges every storage slot and account access the cold cost, even
+	// when it is warm (see vm.UseForceAllCold). No-op before Berlin or with
+	// DisableEIP2929, which have no cold/warm distinction.
+	ForceAllCold bool
+
+	// WarmAddresses are treated as warm from the start of the transaction, like the
+	// fork's precompiles (e.g. the destinations of moved precompiles). No-op before Berlin.
+	WarmAddresses []common.Address
+
+	// PCOverrides scopes gas overrides to the opcode at a program counter (see
+	// vm.UsePCOverrides). Every opcode pays a PC tracking cost when set.
+	PCOverrides map[uint64]map[string]uint64
+}
+
+// enabled reports whether any option changes the fork's JumpTable.
+func (o JumpTableOptions) enabled() bool {
+	return o.DisableEIP2929 || o.LegacyRefunds || o.ForceAllCold || len(o.WarmAddresses) > 0 || len(o.PCOverrides) > 0
+}
+
 // BuildCustomJumpTable creates a custom JumpTable with constant gas costs overridden.
 // Dynamic gas overrides (SLOAD, SSTORE, CALL, etc.) are handled by setting evm.GasSchedule
-// which the patched gas functions read via GetOr().
-func BuildCustomJumpTable(chainRules *chain.Rules, schedule *CustomGasSchedule) *vm.JumpTable {
-	if schedule == nil || !schedule.HasOverrides() {
-		return vm.GetBaseJumpTable(chainRules)
+// which the patched gas functions read via GetOr(). LINEAR_<OPCODE>_* keys install a
+// configurable linear dynamic gas function (see linearGasModels).
+//
+// Gas function swaps from opts are applied first, so constant gas overrides (e.g. a
+// flat SLOAD cost with EIP-2929 disabled) take precedence. PC overrides are applied
+// last, as they wrap the final gas functions.
+//
+// schedule must be concrete: relative overrides are resolved beforehand (see
+// CustomGasSchedule.resolveFor), as executeMessage does.
+//
+// Before Berlin, or with EIP-2929 disabled, state access has no cold/warm split: a
+// plain SLOAD override is the whole flat cost and SLOAD_COLD/SLOAD_WARM (like the
+// other *_COLD/*_WARM keys) are ignored.
+func BuildCustomJumpTable(chainRules *chain.Rules, schedule *CustomGasSchedule, opts JumpTableOptions) *vm.JumpTable {
+	jt := vm.GetBaseJumpTable(chainRules)
+
+	if opts.DisableEIP2929 && chainRules.IsBerlin {
+		vm.DisableEIP2929(jt)
+
+		if chainRules.IsLondon && !opts.LegacyRefunds {
+			vm.UseEIP3529Refunds(jt)
+		}
+	} else if opts.LegacyRefunds && chainRules.IsLondon {
+		vm.UseLegacyRefunds(jt)
 	}
 
-	jt := vm.GetBaseJumpTable(chainRules)
+	if len(opts.WarmAddresses) > 0 && chainRules.IsBerlin {
+		vm.UseWarmAddresses(jt, opts.WarmAddresses)
+	}
+
+	// Wrapped before the per-opcode cold keys, so the surcharge uses their cold cost
+	if opts.ForceAllCold && chainRules.IsBerlin && !opts.DisableEIP2929 {
+		vm.UseForceAllCold(jt)
+	}
+
+	if schedule.HasOverrides() {
+		applyScheduleOverrides(jt, schedule, chainRules.IsBerlin && !opts.DisableEIP2929)
+	}
+
+	if len(opts.PCOverrides) > 0 {
+		vm.UsePCOverrides(jt, opts.PCOverrides)
+	}
+
+	return jt
+}
+
+// applyScheduleOverrides applies a schedule's constant gas, per-opcode cold cost and
+// linear gas overrides to jt. Cold cost overrides are only installed when jt uses
+// the EIP-2929 access list.
+func applyScheduleOverrides(jt *vm.JumpTable, schedule *CustomGasSchedule, accessList bool) {
+	// Per-opcode cold costs for DELEGATECALL/STATICCALL, read via evm.GasSchedule
+	if accessList {
+		if _, ok := schedule.Overrides[vm.GasKeyDelegateCallCold]; ok {
+			vm.UseCallColdKeys(jt)
+		} else if _, ok := schedule.Overrides[vm.GasKeyStaticCallCold]; ok {
+			vm.UseCallColdKeys(jt)
+		}
+	}
 
 	// Apply constant-gas opcode overrides only
 	// Dynamic gas (SLOAD, SSTORE, CALL, etc.) is handled by evm.GasSchedule
@@ -40,12 +124,23 @@ func BuildCustomJumpTable(chainRules *chain.Rules, schedule *CustomGasSchedule)
 		if !ok {
 			continue // Not a direct opcode name (e.g., SLOAD_COLD)
 		}
-		if jt[opcode] != nil {
+		// Skip opcodes the fork has not activated (e.g. CLZ before Osaka)
+		if jt.IsDefined(opcode) {
 			jt[opcode].SetConstantGas(gas)
 		}
 	}
 
-	return jt
+	// Configured linear gas models replace the opcode's dynamic gas function
+	for opcode, model := range linearGasModels(schedule.Overrides) {
+		if jt.IsDefined(opcode) {
+			vm.SetLinearGas(jt, opcode, model)
+		}
+	}
+
+	// Per-opcode memory coefficients wrap the final dynamic gas, including linear models
+	if len(memoryKeyOpcodes(schedule.Overrides)) > 0 {
+		vm.UseMemoryKeys(jt)
+	}
 }
 
 // opcodeFromString converts an opcode name string to vm.OpCode.
@@ -0,0 +1,129 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestBuildCustomJumpTableDisableEIP2929 compares the SLOAD cost of a storage-heavy
+// workload (repeated reads) with the access list enabled and disabled.
+func TestBuildCustomJumpTableDisableEIP2929(t *testing.T) {
+	berlin := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true}
+
+	const sloads = 10
+
+	enabled := BuildCustomJumpTable(berlin, nil, JumpTableOptions{})
+	disabled := BuildCustomJumpTable(berlin, nil, JumpTableOptions{DisableEIP2929: true})
+
+	// With EIP-2929 the SLOAD cost is entirely dynamic (cold/warm)
+	if got := enabled[vm.SLOAD].GetConstantGas(); got != 0 {
+		t.Errorf("EIP-2929 SLOAD constant gas = %d, want 0", got)
+	}
+
+	// Without it every read pays the flat Istanbul cost
+	if got := sloads * disabled[vm.SLOAD].GetConstantGas(); got != sloads*params.SloadGasEIP2200 {
+		t.Errorf("flat SLOAD cost for %d reads = %d, want %d", sloads, got, sloads*params.SloadGasEIP2200)
+	}
+
+	if got := disabled[vm.CALL].GetConstantGas(); got != params.CallGasEIP150 {
+		t.Errorf("flat CALL cost = %d, want %d", got, params.CallGasEIP150)
+	}
+
+	// Constant gas overrides still apply on top of the flat costs
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD": 1000}}
+	if got := BuildCustomJumpTable(berlin, schedule, JumpTableOptions{DisableEIP2929: true})[vm.SLOAD].GetConstantGas(); got != 1000 {
+		t.Errorf("overridden flat SLOAD cost = %d, want 1000", got)
+	}
+
+	// No-op before Berlin
+	istanbul := *berlin
+	istanbul.IsBerlin = false
+
+	if got := BuildCustomJumpTable(&istanbul, nil, JumpTableOptions{DisableEIP2929: true})[vm.SLOAD].GetConstantGas(); got != params.SloadGasEIP2200 {
+		t.Errorf("pre-Berlin SLOAD cost = %d, want %d", got, params.SloadGasEIP2200)
+	}
+}
+
+// TestBuildCustomJumpTablePreBerlinSload verifies that before Berlin a plain SLOAD
+// override is the flat cost, and the cold/warm keys have no effect on the JumpTable.
+func TestBuildCustomJumpTablePreBerlinSload(t *testing.T) {
+	istanbul := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true}
+
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{
+		"SLOAD":                   1000,
+		vm.GasKeySloadCold:        5000,
+		vm.GasKeySloadWarm:        7,
+		vm.GasKeyDelegateCallCold: 9000,
+	}}
+
+	jt := BuildCustomJumpTable(istanbul, schedule, JumpTableOptions{})
+	if got := jt[vm.SLOAD].GetConstantGas(); got != 1000 {
+		t.Errorf("pre-Berlin SLOAD cost = %d, want 1000", got)
+	}
+
+	// Without an SLOAD override the cold/warm keys leave the flat Istanbul cost alone
+	delete(schedule.Overrides, "SLOAD")
+
+	jt = BuildCustomJumpTable(istanbul, schedule, JumpTableOptions{})
+	if got := jt[vm.SLOAD].GetConstantGas(); got != params.SloadGasEIP2200 {
+		t.Errorf("pre-Berlin SLOAD cost with cold/warm keys = %d, want %d", got, params.SloadGasEIP2200)
+	}
+}
+
+// TestCLZGasByFork verifies that CLZ only appears in the gas schedule once Osaka
+// activates it, and that a CLZ override against an earlier fork is skipped.
+func TestCLZGasByFork(t *testing.T) {
+	prague := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true,
+		IsShanghai: true, IsCancun: true, IsPrague: true}
+
+	osaka := *prague
+	osaka.IsOsaka = true
+
+	if gas, ok := GasScheduleForRules(prague).Overrides["CLZ"]; ok {
+		t.Errorf("pre-Osaka schedule has CLZ = %d, want absent", gas)
+	}
+
+	// EIP-7939 prices CLZ like MUL (GasFastStep)
+	if gas, ok := GasScheduleForRules(&osaka).Overrides["CLZ"]; !ok || gas != 5 {
+		t.Errorf("Osaka schedule CLZ = %d (present %v), want 5", gas, ok)
+	}
+
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{"CLZ": 1, "ADD": 7}}
+
+	jt := BuildCustomJumpTable(prague, schedule, JumpTableOptions{})
+	if jt.IsDefined(vm.CLZ) {
+		t.Error("CLZ override must not define the opcode before Osaka")
+	}
+
+	if got := jt[vm.ADD].GetConstantGas(); got != 7 {
+		t.Errorf("ADD override alongside CLZ = %d, want 7", got)
+	}
+
+	if got := BuildCustomJumpTable(&osaka, schedule, JumpTableOptions{})[vm.CLZ].GetConstantGas(); got != 1 {
+		t.Errorf("Osaka CLZ override = %d, want 1", got)
+	}
+}
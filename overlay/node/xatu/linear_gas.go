@@ -0,0 +1,95 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"strings"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// Schedule keys configuring a linear dynamic gas model for an opcode, as
+// LINEAR_<OPCODE>_<PARAM> (e.g. LINEAR_KECCAK256_PER_WORD). Setting any of them
+// replaces the opcode's dynamic gas with base + perWord*words + perItem*size plus
+// memory expansion (see vm.LinearGas); unset parameters are 0, except SIZE_ARG,
+// the stack position of the size operand, which defaults to 1 (offset, size).
+const (
+	linearGasPrefix  = "LINEAR_"
+	linearGasBase    = "_BASE"
+	linearGasPerWord = "_PER_WORD"
+	linearGasPerItem = "_PER_ITEM"
+	linearGasSizeArg = "_SIZE_ARG"
+
+	defaultLinearGasSizeArg = 1
+)
+
+// linearGasModels collects the linear gas models configured in a schedule, keyed by
+// opcode. Keys naming an unknown opcode or parameter are ignored, like other keys
+// that are neither opcodes nor dynamic gas parameters.
+func linearGasModels(overrides map[string]uint64) map[vm.OpCode]vm.LinearGas {
+	var models map[vm.OpCode]vm.LinearGas
+
+	for key, value := range overrides {
+		op, param, ok := parseLinearGasKey(key)
+		if !ok {
+			continue
+		}
+
+		if models == nil {
+			models = make(map[vm.OpCode]vm.LinearGas)
+		}
+
+		model, ok := models[op]
+		if !ok {
+			model.SizeArg = defaultLinearGasSizeArg
+		}
+
+		switch param {
+		case linearGasBase:
+			model.Base = value
+		case linearGasPerWord:
+			model.PerWord = value
+		case linearGasPerItem:
+			model.PerItem = value
+		case linearGasSizeArg:
+			model.SizeArg = int(min(value, 1023)) // clamp to the stack limit
+		}
+
+		models[op] = model
+	}
+
+	return models
+}
+
+// parseLinearGasKey splits a LINEAR_<OPCODE>_<PARAM> key into its opcode and parameter.
+func parseLinearGasKey(key string) (vm.OpCode, string, bool) {
+	name, ok := strings.CutPrefix(key, linearGasPrefix)
+	if !ok {
+		return 0, "", false
+	}
+
+	for _, param := range []string{linearGasBase, linearGasPerWord, linearGasPerItem, linearGasSizeArg} {
+		if opName, ok := strings.CutSuffix(name, param); ok {
+			op, ok := opcodeFromString(opName)
+			return op, param, ok
+		}
+	}
+
+	return 0, "", false
+}
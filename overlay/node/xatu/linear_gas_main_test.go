@@ -0,0 +1,48 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// TestLinearGasExecution checks through executeMessage that a linear gas model in
+// the schedule replaces KECCAK256's word cost: hashing 64 bytes (2 words) at 100
+// per word costs 2*(100-6) more than the fork's Keccak256WordGas.
+func TestLinearGasExecution(t *testing.T) {
+	// KECCAK256(0, 64)
+	hashCode := []byte{0x60, 0x40, 0x60, 0x00, 0x20, 0x50, 0x00}
+
+	gasUsed := func(opts executionOptions) uint64 {
+		c := newTestChain(t, map[common.Address][]byte{testContract: hashCode})
+		return c.call(testContract, nil, opts).GasUsed
+	}
+
+	base := gasUsed(executionOptions{})
+	linear := gasUsed(executionOptions{GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{
+		"LINEAR_KECCAK256_PER_WORD": 100,
+	}}})
+
+	if want := base + 2*(100-params.Keccak256WordGas); linear != want {
+		t.Errorf("gas used with the linear model = %d, want %d (fork default %d)", linear, want, base)
+	}
+}
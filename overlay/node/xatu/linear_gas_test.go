@@ -0,0 +1,60 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestLinearGasModels verifies that LINEAR_<OPCODE>_<PARAM> keys are grouped per
+// opcode, with the size operand defaulting to stack position 1.
+func TestLinearGasModels(t *testing.T) {
+	models := linearGasModels(map[string]uint64{
+		"LINEAR_KECCAK256_PER_WORD":    6,
+		"LINEAR_LOG2_BASE":             1125,
+		"LINEAR_LOG2_PER_ITEM":         8,
+		"LINEAR_CALLDATACOPY_BASE":     3,
+		"LINEAR_CALLDATACOPY_SIZE_ARG": 2,
+		"LINEAR_NOTANOPCODE_BASE":      1,
+		"LINEAR_KECCAK256_UNKNOWN":     1,
+		"SLOAD_COLD":                   2100,
+	})
+
+	want := map[vm.OpCode]vm.LinearGas{
+		vm.KECCAK256:    {PerWord: 6, SizeArg: 1},
+		vm.LOG2:         {Base: 1125, PerItem: 8, SizeArg: 1},
+		vm.CALLDATACOPY: {Base: 3, SizeArg: 2},
+	}
+
+	if len(models) != len(want) {
+		t.Fatalf("got %d models, want %d: %+v", len(models), len(want), models)
+	}
+
+	for op, w := range want {
+		if got := models[op]; got != w {
+			t.Errorf("%s model = %+v, want %+v", op, got, w)
+		}
+	}
+
+	if models := linearGasModels(map[string]uint64{"ADD": 5}); models != nil {
+		t.Errorf("expected no models, got %+v", models)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// memoryKeyPatterns describes the MEMORY_<OPCODE> keys (see memoryKeyOpcodes).
+var memoryKeyPatterns = []GasKeyEntry{
+	{Key: vm.GasKeyMemoryPrefix + "<OPCODE>", Description: "Linear memory expansion coefficient for one opcode, e.g. MEMORY_MCOPY. Follows MEMORY unless set."},
+}
+
+// memoryKeyOpcodes returns the opcodes with a MEMORY_<OPCODE> coefficient in a
+// schedule, in opcode order. Keys naming an unknown opcode are ignored, like other
+// keys that are neither opcodes nor dynamic gas parameters.
+func memoryKeyOpcodes(overrides map[string]uint64) []vm.OpCode {
+	var ops []vm.OpCode
+
+	for key := range overrides {
+		name, ok := strings.CutPrefix(key, vm.GasKeyMemoryPrefix)
+		if !ok {
+			continue
+		}
+
+		if op, ok := opcodeFromString(name); ok {
+			ops = append(ops, op)
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i] < ops[j]
+	})
+
+	return ops
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestMemoryKeyOpcodes verifies that MEMORY_<OPCODE> keys select their opcodes while
+// MEMORY itself and keys naming unknown opcodes are ignored.
+func TestMemoryKeyOpcodes(t *testing.T) {
+	ops := memoryKeyOpcodes(map[string]uint64{
+		"MEMORY_MSTORE":      2,
+		"MEMORY_MCOPY":       10,
+		"MEMORY":             3,
+		"MEMORY_NOTANOPCODE": 1,
+		"SLOAD_COLD":         2100,
+	})
+
+	if want := []vm.OpCode{vm.MSTORE, vm.MCOPY}; !slices.Equal(ops, want) {
+		t.Errorf("opcodes = %v, want %v", ops, want)
+	}
+
+	if ops := memoryKeyOpcodes(map[string]uint64{"MEMORY": 3}); ops != nil {
+		t.Errorf("expected no opcodes, got %v", ops)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"github.com/erigontech/erigon/execution/tracing"
+)
+
+// opcodeMemorySize returns the size of the frame's memory once the opcode has run,
+// as far as the tracer can tell before it runs. Expansions by most opcodes are seen
+// by the next opcode of the frame; RETURN and REVERT end the frame, so the size they
+// expand to is derived from their operands. Their memory expansion has already been
+// charged when the tracer observes them, so the expansion does happen.
+func opcodeMemorySize(opcode byte, scope tracing.OpContext) uint64 {
+	size := uint64(len(scope.MemoryData()))
+
+	if opcode != 0xF3 && opcode != 0xFD { // RETURN, REVERT
+		return size
+	}
+
+	stack := scope.StackData()
+	if len(stack) < 2 {
+		return size
+	}
+
+	offset, length := &stack[len(stack)-1], &stack[len(stack)-2]
+	if length.IsZero() || !offset.IsUint64() || !length.IsUint64() {
+		return size
+	}
+
+	end := offset.Uint64() + length.Uint64()
+	if end < offset.Uint64() || end > (1<<64)-32 {
+		return size
+	}
+
+	return max(size, (end+31)/32*32)
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// NormalizeScheduleResult is the result of xatu_normalizeSchedule.
+type NormalizeScheduleResult struct {
+	BlockNumber uint64             `json:"blockNumber"`
+	Schedule    *CustomGasSchedule `json:"schedule"`
+	Removed     []string           `json:"removed"` // Sorted keys dropped as equal to their default
+}
+
+// fallbackKeys maps the keys that follow another key when unset to that key: an
+// unset DELEGATECALL_COLD charges CALL_COLD. These keys have no fork default of
+// their own in GasScheduleForRules.
+var fallbackKeys = map[string]string{
+	vm.GasKeyDelegateCallCold: vm.GasKeyCallCold,
+	vm.GasKeyStaticCallCold:   vm.GasKeyCallCold,
+	vm.GasKeySstoreNoop:       vm.GasKeySloadWarm,
+}
+
+// Normalize returns a copy of the schedule without the overrides that equal their
+// fork default (from GasScheduleForRules), since those change nothing. Keys with
+// no default at the fork, and relative overrides, are kept as they are. A key that
+// falls back to another key (see fallbackKeys) is compared with that key's value
+// in the schedule, or its default. A nil schedule normalizes to an empty one.
+func (c *CustomGasSchedule) Normalize(rules *chain.Rules) *CustomGasSchedule {
+	normalized := &CustomGasSchedule{Overrides: make(map[string]uint64)}
+	if c == nil {
+		return normalized
+	}
+
+	defaults := GasScheduleForRules(rules).Overrides
+	for key, value := range c.Overrides {
+		// Without the key, the gas functions read its fallback (set or at its
+		// default) if it has one, else the key's default
+		effective, ok := defaults[key]
+		if fallbackKey, has := fallbackKeys[key]; has {
+			effective, ok = defaults[fallbackKey]
+			if fallback, set := c.Overrides[fallbackKey]; set {
+				effective, ok = fallback, true
+			}
+		}
+
+		if ok && effective == value {
+			continue
+		}
+
+		normalized.Overrides[key] = value
+	}
+
+	if len(c.RelativeOverrides) > 0 {
+		normalized.RelativeOverrides = maps.Clone(c.RelativeOverrides)
+	}
+
+	return normalized
+}
+
+// NormalizeSchedule strips the no-op overrides of a schedule against the fork of
+// a block, producing a minimal schedule for sharing and diffing.
+func (s *Service) NormalizeSchedule(ctx context.Context, blockNumber uint64, schedule *CustomGasSchedule) (*NormalizeScheduleResult, error) {
+	header, err := s.headerByNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := s.chainConfigFor(ctx, executionOptions{}).Rules(blockNumber, header.Time)
+	normalized := schedule.Normalize(rules)
+
+	removed := make([]string, 0)
+	if schedule != nil {
+		for key := range schedule.Overrides {
+			if _, ok := normalized.Overrides[key]; !ok {
+				removed = append(removed, key)
+			}
+		}
+	}
+	slices.Sort(removed)
+
+	return &NormalizeScheduleResult{
+		BlockNumber: blockNumber,
+		Schedule:    normalized,
+		Removed:     removed,
+	}, nil
+}
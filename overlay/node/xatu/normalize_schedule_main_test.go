@@ -0,0 +1,64 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestNormalizePreservesResults executes a cold DELEGATECALL under schedules that
+// set CALL_COLD, with and without DELEGATECALL_COLD at its fork default, and checks
+// that each normalized schedule charges the same gas as the schedule it came from.
+func TestNormalizePreservesResults(t *testing.T) {
+	// DELEGATECALL(gas, 0xdead, 0, 0, 0, 0) to a cold, empty account
+	code := []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH2), 0xde, 0xad, byte(vm.GAS), byte(vm.DELEGATECALL), byte(vm.STOP),
+	}
+
+	schedules := map[string]*CustomGasSchedule{
+		"DELEGATECALL_COLD at its default": {Overrides: map[string]uint64{
+			vm.GasKeyCallCold: 5000, vm.GasKeyDelegateCallCold: 2600,
+		}},
+		"DELEGATECALL_COLD equal to CALL_COLD": {Overrides: map[string]uint64{
+			vm.GasKeyCallCold: 5000, vm.GasKeyDelegateCallCold: 5000,
+		}},
+		"unchanged keys only": {Overrides: map[string]uint64{
+			vm.GasKeyCallCold: 2600, vm.GasKeyDelegateCallCold: 2600,
+		}},
+	}
+
+	for name, schedule := range schedules {
+		t.Run(name, func(t *testing.T) {
+			c := newTestChain(t, map[common.Address][]byte{testContract: code})
+			normalized := schedule.Normalize(c.rules)
+
+			before := c.call(testContract, nil, executionOptions{GasSchedule: schedule})
+			after := c.call(testContract, nil, executionOptions{GasSchedule: normalized})
+
+			if before.GasUsed != after.GasUsed {
+				t.Errorf("gas used = %d before normalizing, %d after (normalized to %v)",
+					before.GasUsed, after.GasUsed, normalized.Overrides)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestNormalizeAllDefaults checks that a schedule setting every key to its fork
+// default normalizes to an empty override map.
+func TestNormalizeAllDefaults(t *testing.T) {
+	rules := forkRules(len(forkOrder) - 1)
+	schedule := &CustomGasSchedule{Overrides: maps.Clone(GasScheduleForRules(rules).Overrides)}
+
+	if got := schedule.Normalize(rules).Overrides; len(got) != 0 {
+		t.Errorf("normalized overrides = %v, want none", got)
+	}
+}
+
+// TestNormalizeKeepsChanges checks that overrides differing from the default,
+// keys without a default at the fork, and relative overrides survive.
+func TestNormalizeKeepsChanges(t *testing.T) {
+	berlin := &chain.Rules{
+		IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true,
+		IsByzantium: true, IsConstantinople: true, IsPetersburg: true,
+		IsIstanbul: true, IsBerlin: true,
+	}
+
+	schedule := &CustomGasSchedule{
+		Overrides: map[string]uint64{
+			vm.GasKeySloadCold:  2100, // Default
+			vm.GasKeySloadWarm:  200,
+			vm.TLOAD.String():   100,   // Not defined before Cancun
+			vm.ADD.String():     3,     // Default
+			vm.GasKeyCallWarm:   100,   // Left out of the schedule defaults
+			vm.GasKeySstoreSet:  20000, // Default
+			vm.GasKeySstoreNoop: 100,   // Differs from SLOAD_WARM, which it follows
+		},
+		RelativeOverrides: map[string]RelativeSpec{
+			vm.GasKeyCallCold: {Base: vm.GasKeySloadCold, Multiplier: 2},
+		},
+	}
+
+	got := schedule.Normalize(berlin)
+
+	want := map[string]uint64{
+		vm.GasKeySloadWarm:  200,
+		vm.TLOAD.String():   100,
+		vm.GasKeyCallWarm:   100,
+		vm.GasKeySstoreNoop: 100,
+	}
+
+	if !maps.Equal(got.Overrides, want) {
+		t.Errorf("normalized overrides = %v, want %v", got.Overrides, want)
+	}
+
+	if len(got.RelativeOverrides) != 1 {
+		t.Errorf("relative overrides = %v, want the original one", got.RelativeOverrides)
+	}
+
+	// The input is left untouched
+	if len(schedule.Overrides) != 7 {
+		t.Errorf("input schedule modified: %v", schedule.Overrides)
+	}
+
+	if got := (*CustomGasSchedule)(nil).Normalize(berlin); got == nil || len(got.Overrides) != 0 {
+		t.Errorf("nil schedule normalized to %+v, want an empty schedule", got)
+	}
+}
+
+// TestNormalizeFallbackKeys checks that DELEGATECALL_COLD and STATICCALL_COLD at
+// their default are kept while CALL_COLD is changed, since dropping them would make
+// those calls charge CALL_COLD instead.
+func TestNormalizeFallbackKeys(t *testing.T) {
+	berlin := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true}
+
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{
+		vm.GasKeyCallCold:         5000,
+		vm.GasKeyDelegateCallCold: 2600,
+		vm.GasKeyStaticCallCold:   5000, // same as its fallback, so a no-op
+	}}
+
+	got := schedule.Normalize(berlin).Overrides
+	want := map[string]uint64{vm.GasKeyCallCold: 5000, vm.GasKeyDelegateCallCold: 2600}
+
+	if !maps.Equal(got, want) {
+		t.Errorf("Normalize() = %v, want %v", got, want)
+	}
+}
+
+// TestScheduleDefaultsOmitFallbackKeys checks that DELEGATECALL_COLD and
+// STATICCALL_COLD have no fork default, so they follow CALL_COLD, and that at
+// CALL_COLD's default they normalize away.
+func TestScheduleDefaultsOmitFallbackKeys(t *testing.T) {
+	rules := forkRules(len(forkOrder) - 1)
+
+	defaults := GasScheduleForRules(rules).Overrides
+	for key := range fallbackKeys {
+		if value, ok := defaults[key]; ok {
+			t.Errorf("defaults set %s = %d, want it to follow %s", key, value, fallbackKeys[key])
+		}
+	}
+
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{
+		vm.GasKeyDelegateCallCold: defaults[vm.GasKeyCallCold],
+		vm.GasKeyStaticCallCold:   defaults[vm.GasKeyCallCold] + 1,
+	}}
+
+	want := map[string]uint64{vm.GasKeyStaticCallCold: defaults[vm.GasKeyCallCold] + 1}
+	if got := schedule.Normalize(rules).Overrides; !maps.Equal(got, want) {
+		t.Errorf("Normalize() = %v, want %v", got, want)
+	}
+}
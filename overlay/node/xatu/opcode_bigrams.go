@@ -0,0 +1,193 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "sort"
+
+const (
+	// maxTrackedOpcodePairs bounds the number of distinct pairs a tracker keeps.
+	// There are at most 256*256 pairs, but real contracts use a small fraction;
+	// once the bound is reached, a pair not seen before replaces the pair with
+	// the least gas, so the pairs with the most gas are kept.
+	maxTrackedOpcodePairs = 4096
+
+	// topOpcodePairs is the number of pairs returned per execution.
+	topOpcodePairs = 32
+)
+
+// OpcodePair is an adjacent pair of opcodes executed in the same call frame,
+// with the number of times the pair occurred and the gas charged to its second opcode.
+type OpcodePair struct {
+	Prev  string `json:"prev"`
+	Curr  string `json:"curr"`
+	Count uint64 `json:"count"`
+	Gas   uint64 `json:"gas"`
+}
+
+// OpcodePairs holds the top opcode pairs (by gas) of both executions.
+type OpcodePairs struct {
+	Original  []OpcodePair `json:"original"`
+	Simulated []OpcodePair `json:"simulated"`
+}
+
+// opcodePairStats is the running count and gas of a single pair.
+type opcodePairStats struct {
+	count uint64
+	gas   uint64
+}
+
+// bigramTracker counts adjacent opcode pairs within a call frame.
+// The sequence is broken at call boundaries, so a pair never spans a parent
+// and child frame. Gas for CALL-family opcodes is only known once the call
+// resolves, so their pair is recorded with zero gas and topped up later.
+type bigramTracker struct {
+	pairs   map[uint16]*opcodePairStats
+	prev    byte
+	hasPrev bool
+	pending *opcodePairStats
+}
+
+// newBigramTracker creates an empty bigram tracker.
+func newBigramTracker() *bigramTracker {
+	return &bigramTracker{
+		pairs: make(map[uint16]*opcodePairStats, 256),
+	}
+}
+
+// observe records the pair formed by the previous opcode and this one.
+func (b *bigramTracker) observe(opcode byte, cost uint64) {
+	isCall := opcode == 0xF1 || opcode == 0xF2 || opcode == 0xF4 || opcode == 0xFA
+
+	if b.hasPrev {
+		key := uint16(b.prev)<<8 | uint16(opcode)
+
+		stats, ok := b.pairs[key]
+		if !ok {
+			if len(b.pairs) >= maxTrackedOpcodePairs {
+				b.evictLeastGas()
+			}
+
+			stats = &opcodePairStats{}
+			b.pairs[key] = stats
+		}
+
+		stats.count++
+
+		if isCall {
+			b.pending = stats
+		} else {
+			stats.gas += cost
+		}
+	}
+
+	b.prev = opcode
+	b.hasPrev = true
+}
+
+// evictLeastGas drops the pair with the least gas (and then the lowest count),
+// other than a CALL-family pair still waiting for its gas.
+func (b *bigramTracker) evictLeastGas() {
+	var (
+		evict uint16
+		least *opcodePairStats
+	)
+
+	for key, stats := range b.pairs {
+		if stats == b.pending {
+			continue
+		}
+
+		if least == nil || stats.gas < least.gas || (stats.gas == least.gas && stats.count < least.count) {
+			evict, least = key, stats
+		}
+	}
+
+	if least != nil {
+		delete(b.pairs, evict)
+	}
+}
+
+// addPendingGas attributes the resolved cost of a CALL-family opcode to its pair.
+func (b *bigramTracker) addPendingGas(cost uint64) {
+	if b.pending != nil {
+		b.pending.gas += cost
+		b.pending = nil
+	}
+}
+
+// breakSequence forgets the previous opcode, so the next opcode starts a new sequence.
+func (b *bigramTracker) breakSequence() {
+	b.hasPrev = false
+}
+
+// reset clears all recorded pairs.
+func (b *bigramTracker) reset() {
+	clear(b.pairs)
+	b.hasPrev = false
+	b.pending = nil
+}
+
+// top returns the n pairs with the most gas, breaking ties by count and then by name.
+func (b *bigramTracker) top(n int) []OpcodePair {
+	result := make([]OpcodePair, 0, len(b.pairs))
+
+	for key, stats := range b.pairs {
+		result = append(result, OpcodePair{
+			Prev:  opcodeStrings[byte(key>>8)],
+			Curr:  opcodeStrings[byte(key)],
+			Count: stats.count,
+			Gas:   stats.gas,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Gas != result[j].Gas {
+			return result[i].Gas > result[j].Gas
+		}
+
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+
+		if result[i].Prev != result[j].Prev {
+			return result[i].Prev < result[j].Prev
+		}
+
+		return result[i].Curr < result[j].Curr
+	})
+
+	if len(result) > n {
+		result = result[:n]
+	}
+
+	return result
+}
+
+// combineOpcodePairs returns the top pairs of both tracers, or nil if pair
+// tracking was not enabled.
+func combineOpcodePairs(original, simulated *SimulationTracer) *OpcodePairs {
+	if original.bigrams == nil || simulated.bigrams == nil {
+		return nil
+	}
+
+	return &OpcodePairs{
+		Original:  original.GetOpcodePairs(topOpcodePairs),
+		Simulated: simulated.GetOpcodePairs(topOpcodePairs),
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "math"
+
+const (
+	// unseenMinGas is the sentinel minimum for an opcode that has not been charged
+	// yet. Any observed cost replaces it.
+	unseenMinGas = math.MaxUint64
+
+	// maxSampleRate caps the opcode sampling rate. A sampled charge is weighted by
+	// the rate, and the cap keeps cost * rate far from overflowing.
+	maxSampleRate = 1_000_000
+)
+
+// gasRange is the cheapest and most expensive single charge observed for an opcode.
+// The spread exposes dynamic-gas opcodes hitting different paths (e.g. warm vs
+// cold SLOAD, or SSTORE set vs reset).
+type gasRange struct {
+	min uint64
+	max uint64
+}
+
+// observe widens the range to include cost.
+func (r *gasRange) observe(cost uint64) {
+	if cost < r.min {
+		r.min = cost
+	}
+
+	if cost > r.max {
+		r.max = cost
+	}
+}
+
+// recordGas attributes a single charge to an opcode, updating its total and
+// its observed cost range, and to the contract executing the current frame.
+func (t *SimulationTracer) recordGas(opName string, cost uint64) {
+	t.recordWeightedGas(opName, cost, 1)
+}
+
+// recordWeightedGas records a charge standing for weight charges of the same cost,
+// as when opcodes are sampled. The cost range still observes the single charge.
+func (t *SimulationTracer) recordWeightedGas(opName string, cost, weight uint64) {
+	total := cost * weight
+
+	t.gasUsed[opName] += total
+	t.totalGasUsed += total
+
+	r, ok := t.gasRanges[opName]
+	if !ok {
+		r = gasRange{min: unseenMinGas}
+	}
+
+	r.observe(cost)
+	t.gasRanges[opName] = r
+
+	if t.gasVariances != nil {
+		v := t.gasVariances[opName]
+		v.observe(cost, weight)
+		t.gasVariances[opName] = v
+	}
+
+	if t.contractGas != nil && len(t.callStack) > 0 {
+		t.contractGas[t.callStack[len(t.callStack)-1].contract] += total
+	}
+}
+
+// resolvePendingCall attributes cost to the pending CALL-family opcode and clears
+// the pending state.
+func (t *SimulationTracer) resolvePendingCall(cost uint64) {
+	t.recordGas(t.pendingCallType, cost)
+
+	if t.bigrams != nil {
+		t.bigrams.addPendingGas(cost)
+	}
+
+	t.pendingCallCost = 0
+	t.pendingCallDepth = 0
+	t.pendingCallType = ""
+}
+
+// gasRangeOf returns the observed cost range for an opcode. An opcode that was
+// counted but never charged (e.g. a CALL still pending resolution) reports 0..0.
+func (t *SimulationTracer) gasRangeOf(opName string) (uint64, uint64) {
+	r, ok := t.gasRanges[opName]
+	if !ok {
+		return 0, 0
+	}
+
+	return r.min, r.max
+}
+
+// mergeRange combines two min/max pairs, ignoring a side with a zero count
+// since its range was never observed.
+func mergeRange(minA, maxA, countA, minB, maxB, countB uint64) (uint64, uint64) {
+	switch {
+	case countA == 0:
+		return minB, maxB
+	case countB == 0:
+		return minA, maxA
+	}
+
+	return min(minA, minB), max(maxA, maxB)
+}
+
+// merge accumulates another summary of the same opcode into s: counts and gas
+// are summed and the cost ranges are widened.
+func (s *OpcodeSummary) merge(o OpcodeSummary) {
+	s.OriginalMinGas, s.OriginalMaxGas = mergeRange(
+		s.OriginalMinGas, s.OriginalMaxGas, s.OriginalCount,
+		o.OriginalMinGas, o.OriginalMaxGas, o.OriginalCount,
+	)
+	s.SimulatedMinGas, s.SimulatedMaxGas = mergeRange(
+		s.SimulatedMinGas, s.SimulatedMaxGas, s.SimulatedCount,
+		o.SimulatedMinGas, o.SimulatedMaxGas, o.SimulatedCount,
+	)
+
+	s.OriginalCount += o.OriginalCount
+	s.OriginalGas += o.OriginalGas
+	s.SimulatedCount += o.SimulatedCount
+	s.SimulatedGas += o.SimulatedGas
+}
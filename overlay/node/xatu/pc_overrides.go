@@ -0,0 +1,67 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// validatePCOverrides checks that every PC-scoped key is an opcode name or a dynamic
+// gas key the gas functions read. Intrinsic, precompile and linear model keys do not
+// belong to a single instruction, and REFUND_CAP_DIV is applied after execution.
+// DELEGATECALL_COLD and STATICCALL_COLD are only read when set schedule-wide (see
+// vm.UseCallColdKeys), so PC-scoped CALL_COLD is the way to price one cold call.
+func validatePCOverrides(overrides map[uint32]map[string]uint64) error {
+	for pc, keys := range overrides {
+		for key := range keys {
+			if _, ok := opcodeFromString(key); ok {
+				continue
+			}
+
+			if key == vm.GasKeyDelegateCallCold || key == vm.GasKeyStaticCallCold {
+				return fmt.Errorf("pc override at %d: %q cannot be scoped to a PC, use %q", pc, key, vm.GasKeyCallCold)
+			}
+
+			if key != vm.GasKeyRefundCapDiv && slices.Contains(vm.DynamicGasKeys, key) {
+				continue
+			}
+
+			return fmt.Errorf("pc override at %d: %q is not an opcode or dynamic gas key", pc, key)
+		}
+	}
+
+	return nil
+}
+
+// vmPCOverrides converts request PC overrides to the form taken by vm.UsePCOverrides.
+func vmPCOverrides(overrides map[uint32]map[string]uint64) map[uint64]map[string]uint64 {
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	converted := make(map[uint64]map[string]uint64, len(overrides))
+	for pc, keys := range overrides {
+		converted[uint64(pc)] = keys
+	}
+
+	return converted
+}
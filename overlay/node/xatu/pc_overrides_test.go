@@ -0,0 +1,69 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestValidatePCOverrides verifies which keys may be scoped to a PC.
+func TestValidatePCOverrides(t *testing.T) {
+	valid := map[uint32]map[string]uint64{
+		0x42: {"SSTORE": 0, vm.GasKeySstoreSet: 40000},
+		0x50: {vm.GasKeySloadCold: 4200},
+	}
+	if err := validatePCOverrides(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, key := range []string{vm.GasKeyTxBase, vm.GasKeyRefundCapDiv, "PC_ECREC", "LINEAR_KECCAK256_BASE", "SSTOR",
+		vm.GasKeyDelegateCallCold, vm.GasKeyStaticCallCold} {
+		if err := validatePCOverrides(map[uint32]map[string]uint64{1: {key: 1}}); err == nil {
+			t.Errorf("%s: expected an error", key)
+		}
+	}
+}
+
+// TestBuildCustomJumpTablePCOverrides verifies that PC overrides are applied after
+// the schedule's constant gas overrides, which they wrap.
+func TestBuildCustomJumpTablePCOverrides(t *testing.T) {
+	rules := &chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true, IsLondon: true}
+
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{"ADD": 7}}
+	opts := JumpTableOptions{PCOverrides: vmPCOverrides(map[uint32]map[string]uint64{0x10: {"ADD": 1}})}
+
+	if !opts.enabled() {
+		t.Fatal("PC overrides should enable the custom JumpTable")
+	}
+
+	jt := BuildCustomJumpTable(rules, schedule, opts)
+
+	// The constant gas of a PC-overridden opcode moves into its dynamic gas function
+	if got := jt[vm.ADD].GetConstantGas(); got != 0 {
+		t.Errorf("ADD constant gas = %d, want 0", got)
+	}
+
+	if got := jt[vm.MUL].GetConstantGas(); got != 5 {
+		t.Errorf("MUL constant gas = %d, want 5", got)
+	}
+}
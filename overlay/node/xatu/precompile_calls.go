@@ -0,0 +1,83 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// PrecompileCall is a call to a precompile with the input size that drives its
+// gas and the gas computed for it, so variable-gas precompiles can be checked
+// against their formula (e.g. BN254_PAIRING against its pair count).
+type PrecompileCall struct {
+	Depth      int    `json:"depth"` // Depth of the precompile's frame
+	Name       string `json:"name"`  // Precompile name, e.g. "MODEXP"
+	Address    string `json:"address"`
+	InputSize  int    `json:"inputSize"`
+	DefaultGas uint64 `json:"defaultGas"` // Standard gas for the input (RequiredGas)
+	Gas        uint64 `json:"gas"`        // Gas with the execution's overrides applied
+	GasUsed    uint64 `json:"gasUsed"`    // Gas the frame consumed, all of it on failure
+	Failed     bool   `json:"failed"`
+}
+
+// PrecompileCalls holds the precompile calls of both executions.
+type PrecompileCalls struct {
+	Original  []PrecompileCall `json:"original"`
+	Simulated []PrecompileCall `json:"simulated"`
+}
+
+// recordPrecompileCall captures a precompile frame as it is entered, computing its
+// gas as RunPrecompiledContract does with the tracer's schedule.
+func (t *SimulationTracer) recordPrecompileCall(depth int, address string, p vm.PrecompiledContract, input []byte) {
+	defaultGas := p.RequiredGas(input)
+
+	t.precompileCalls = append(t.precompileCalls, PrecompileCall{
+		Depth:      depth,
+		Name:       p.Name(),
+		Address:    normalizeAddress(address),
+		InputSize:  len(input),
+		DefaultGas: defaultGas,
+		Gas:        vm.PrecompileGasWithOverrides(t.schedule.ToVMGasSchedule(), p.Name(), input, defaultGas),
+	})
+}
+
+// finishPrecompileCall records the outcome of the last precompile call. Precompile
+// frames have no children, so their exit immediately follows their entry.
+func (t *SimulationTracer) finishPrecompileCall(gasUsed uint64, failed bool) {
+	if len(t.precompileCalls) == 0 {
+		return
+	}
+
+	call := &t.precompileCalls[len(t.precompileCalls)-1]
+	call.GasUsed = gasUsed
+	call.Failed = failed
+}
+
+// combinePrecompileCalls pairs the precompile calls of both executions, or returns
+// nil if precompile call capture is disabled.
+func combinePrecompileCalls(original, simulated *SimulationTracer) *PrecompileCalls {
+	if !original.trackPrecompileCalls || !simulated.trackPrecompileCalls {
+		return nil
+	}
+
+	return &PrecompileCalls{
+		Original:  original.GetPrecompileCalls(),
+		Simulated: simulated.GetPrecompileCalls(),
+	}
+}
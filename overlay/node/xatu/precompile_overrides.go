@@ -0,0 +1,177 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// precompileOverrides changes the set of precompiles active during a simulated
+// execution. A disabled precompile's address executes as a regular account (empty
+// unless state says otherwise); a moved precompile is served from a new address and
+// its original address becomes a regular account, as in eth_simulateV1's
+// movePrecompileToAddress.
+//
+// Precompiles are referenced by address ("0x01") or by name ("ECREC" or "PC_ECREC").
+// Addresses warmed by EIP-2929 are still derived from the fork rules, so a
+// disabled precompile's address starts warm. A moved precompile's destination is
+// warmed as well (see movedAddresses), so calling it never incurs CALL_COLD.
+type precompileOverrides struct {
+	Disabled []string
+	Moved    map[string]string // precompile -> destination address
+}
+
+// enabled reports whether any precompile is disabled or moved.
+func (o precompileOverrides) enabled() bool {
+	return len(o.Disabled) > 0 || len(o.Moved) > 0
+}
+
+// validate checks that move destinations are well-formed addresses. Whether the
+// referenced precompiles exist depends on the block's fork and is checked in apply.
+func (o precompileOverrides) validate() error {
+	for from, to := range o.Moved {
+		if _, ok := parsePrecompileAddress(to); !ok {
+			return fmt.Errorf("invalid precompile override for %s: %q is not an address", from, to)
+		}
+	}
+
+	return nil
+}
+
+// apply returns the active precompiles with the overrides applied. The input set is
+// not modified.
+func (o precompileOverrides) apply(active vm.PrecompiledContracts) (vm.PrecompiledContracts, error) {
+	if !o.enabled() {
+		return active, nil
+	}
+
+	result := maps.Clone(active)
+
+	for _, ref := range o.Disabled {
+		key, _, err := resolvePrecompile(active, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(result, key)
+	}
+
+	// Sorted so that conflicting moves fail with a stable error
+	sources := make([]string, 0, len(o.Moved))
+	for ref := range o.Moved {
+		sources = append(sources, ref)
+	}
+	sort.Strings(sources)
+
+	moved := make(vm.PrecompiledContracts, len(o.Moved))
+	for _, ref := range sources {
+		key, p, err := resolvePrecompile(active, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(result, key)
+
+		addr, _ := parsePrecompileAddress(o.Moved[ref])
+		dest := precompileKey(addr)
+		if _, ok := moved[dest]; ok {
+			return nil, fmt.Errorf("more than one precompile moved to %s", o.Moved[ref])
+		}
+
+		moved[dest] = p
+	}
+
+	for dest, p := range moved {
+		if _, ok := result[dest]; ok {
+			return nil, fmt.Errorf("cannot move precompile %s onto active precompile %s", p.Name(), dest)
+		}
+
+		result[dest] = p
+	}
+
+	return result, nil
+}
+
+// movedAddresses returns the destinations of moved precompiles, sorted. The state
+// only pre-warms the fork's precompile addresses, so these are warmed through the
+// JumpTable instead (see JumpTableOptions.WarmAddresses).
+func (o precompileOverrides) movedAddresses() []common.Address {
+	if len(o.Moved) == 0 {
+		return nil
+	}
+
+	addrs := make([]common.Address, 0, len(o.Moved))
+	for _, to := range o.Moved {
+		if addr, ok := parsePrecompileAddress(to); ok {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+
+	return addrs
+}
+
+// resolvePrecompile finds an active precompile by address or name.
+func resolvePrecompile(active vm.PrecompiledContracts, ref string) (precompileAddress, vm.PrecompiledContract, error) {
+	if addr, ok := parsePrecompileAddress(ref); ok {
+		key := precompileKey(addr)
+		if p, ok := active[key]; ok {
+			return key, p, nil
+		}
+
+		return key, nil, fmt.Errorf("%s is not an active precompile", ref)
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(ref), "PC_")
+	for key, p := range active {
+		if p.Name() == name {
+			return key, p, nil
+		}
+	}
+
+	var zero precompileAddress
+	return zero, nil, fmt.Errorf("unknown or inactive precompile %q", ref)
+}
+
+// parsePrecompileAddress parses a 0x-prefixed hex address. Short forms such as
+// "0x01" are left-padded, matching how precompile addresses are usually written.
+func parsePrecompileAddress(s string) (common.Address, bool) {
+	digits, ok := strings.CutPrefix(strings.ToLower(s), "0x")
+	if !ok || digits == "" || len(digits) > 40 {
+		return common.Address{}, false
+	}
+
+	for _, c := range digits {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return common.Address{}, false
+		}
+	}
+
+	return common.HexToAddress(s), true
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// accessCode returns bytecode that runs op (BALANCE or SELFDESTRUCT) on the 2-byte
+// address addr.
+func accessCode(op byte, addr uint16) []byte {
+	return []byte{0x61, byte(addr >> 8), byte(addr), op, 0x50, 0x00}
+}
+
+// TestPrecompileWarmthExecution checks through executeMessage that precompiles are
+// warm from the start of the transaction: the fork's own, and a moved one even
+// after a reverted frame accessed it and when it is a SELFDESTRUCT beneficiary.
+func TestPrecompileWarmthExecution(t *testing.T) {
+	const (
+		balance      = 0x31
+		selfdestruct = 0xff
+	)
+
+	movedTo := common.HexToAddress("0x0000000000000000000000000000000000000100")
+	reverter := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	moved := executionOptions{Precompiles: precompileOverrides{Moved: map[string]string{"0x01": movedTo.Hex()}}}
+
+	// Calls reverter, which reads the balance of 0x0100 and reverts, then reads it again
+	revertThenAccess := []byte{
+		0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, // CALL arguments
+		0x61, 0x0b, 0x0b, 0x5a, 0xf1, 0x50, // CALL(gas, reverter)
+		0x61, 0x01, 0x00, 0x31, 0x50, 0x00, // BALANCE(0x0100)
+	}
+	reverterCode := []byte{0x61, 0x01, 0x00, 0x31, 0x50, 0x60, 0x00, 0x60, 0x00, 0xfd}
+
+	gasUsed := func(code []byte, opts executionOptions) uint64 {
+		c := newTestChain(t, map[common.Address][]byte{testContract: code, reverter: reverterCode})
+		return c.call(testContract, nil, opts).GasUsed
+	}
+
+	surcharge := params.ColdAccountAccessCostEIP2929 - params.WarmStorageReadCostEIP2929
+
+	tests := []struct {
+		name       string
+		warm, cold uint64 // gas used with the target warm and cold
+		want       uint64 // cold minus warm
+	}{
+		{
+			name: "fork precompile",
+			warm: gasUsed(accessCode(balance, 0x0001), executionOptions{}),
+			cold: gasUsed(accessCode(balance, 0xdead), executionOptions{}),
+			want: surcharge,
+		},
+		{
+			name: "moved precompile",
+			warm: gasUsed(accessCode(balance, 0x0100), moved),
+			cold: gasUsed(accessCode(balance, 0x0100), executionOptions{}),
+			want: surcharge,
+		},
+		{
+			// Without the move both reads are cold, as the revert drops the first
+			name: "moved precompile after revert",
+			warm: gasUsed(revertThenAccess, moved),
+			cold: gasUsed(revertThenAccess, executionOptions{}),
+			want: 2 * surcharge,
+		},
+		{
+			name: "moved precompile as beneficiary",
+			warm: gasUsed(accessCode(selfdestruct, 0x0100), moved),
+			cold: gasUsed(accessCode(selfdestruct, 0x0100), executionOptions{}),
+			want: params.ColdAccountAccessCostEIP2929,
+		},
+	}
+
+	for _, tc := range tests {
+		if tc.cold-tc.warm != tc.want {
+			t.Errorf("%s: gas used %d cold and %d warm, want a difference of %d", tc.name, tc.cold, tc.warm, tc.want)
+		}
+	}
+}
+
+// TestPrecompileDisableExecution checks through executeMessage that a disabled
+// precompile's address runs as an empty account: a STATICCALL to SHA256 with no
+// input no longer charges its base cost, while the address stays warm.
+func TestPrecompileDisableExecution(t *testing.T) {
+	// STATICCALL(gas, 0x02, 0, 0, 0, 0)
+	callSha256 := []byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x02, 0x5a, 0xfa, 0x50, 0x00}
+
+	gasUsed := func(opts executionOptions) uint64 {
+		c := newTestChain(t, map[common.Address][]byte{testContract: callSha256})
+		return c.call(testContract, nil, opts).GasUsed
+	}
+
+	enabled := gasUsed(executionOptions{})
+	disabled := gasUsed(executionOptions{Precompiles: precompileOverrides{Disabled: []string{"0x02"}}})
+
+	if enabled-disabled != params.Sha256BaseGas {
+		t.Errorf("gas used %d with SHA256 and %d disabled, want a difference of %d", enabled, disabled, params.Sha256BaseGas)
+	}
+}
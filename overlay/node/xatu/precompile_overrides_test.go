@@ -0,0 +1,116 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+var (
+	ecrecoverAddr = common.HexToAddress("0x0000000000000000000000000000000000000001")
+	sha256Addr    = common.HexToAddress("0x0000000000000000000000000000000000000002")
+)
+
+func berlinPrecompiles() vm.PrecompiledContracts {
+	return vm.Precompiles(&chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true,
+		IsByzantium: true, IsConstantinople: true, IsPetersburg: true, IsIstanbul: true, IsBerlin: true})
+}
+
+// TestPrecompileOverridesDisable verifies that a disabled ECRECOVER is removed from
+// the set the EVM resolves precompiles from, so a call to 0x01 takes the regular
+// account path (an empty account: no code, succeeds without output) instead of
+// running the precompile.
+func TestPrecompileOverridesDisable(t *testing.T) {
+	for _, ref := range []string{"0x01", "0x0000000000000000000000000000000000000001", "ECREC", "pc_ecrec"} {
+		t.Run(ref, func(t *testing.T) {
+			active := berlinPrecompiles()
+
+			got, err := precompileOverrides{Disabled: []string{ref}}.apply(active)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := got[precompileKey(ecrecoverAddr)]; ok {
+				t.Error("ECRECOVER is still active")
+			}
+
+			if len(got) != len(active)-1 {
+				t.Errorf("got %d precompiles, want %d", len(got), len(active)-1)
+			}
+
+			if _, ok := active[precompileKey(ecrecoverAddr)]; !ok {
+				t.Error("input precompile set was modified")
+			}
+		})
+	}
+}
+
+// TestPrecompileOverridesMove verifies that a moved precompile is served from its
+// destination and its original address becomes a regular account.
+func TestPrecompileOverridesMove(t *testing.T) {
+	dest := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+	got, err := precompileOverrides{Moved: map[string]string{"ECREC": dest.Hex()}}.apply(berlinPrecompiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, ok := got[precompileKey(dest)]
+	if !ok || p.Name() != "ECREC" {
+		t.Fatalf("expected ECREC at %s", dest.Hex())
+	}
+
+	if _, ok := got[precompileKey(ecrecoverAddr)]; ok {
+		t.Error("ECRECOVER is still active at its original address")
+	}
+
+	// The destination is not pre-warmed by the state, so it is warmed via the JumpTable
+	warm := precompileOverrides{Moved: map[string]string{"ECREC": dest.Hex(), "0x02": "0x0b"}}.movedAddresses()
+	if len(warm) != 2 || warm[0] != common.HexToAddress("0x0b") || warm[1] != dest {
+		t.Errorf("movedAddresses = %v, want [0x0b %s]", warm, dest.Hex())
+	}
+}
+
+func TestPrecompileOverridesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides precompileOverrides
+	}{
+		{name: "unknown name", overrides: precompileOverrides{Disabled: []string{"NOPE"}}},
+		{name: "inactive address", overrides: precompileOverrides{Disabled: []string{"0x0a"}}},
+		{name: "onto active precompile", overrides: precompileOverrides{Moved: map[string]string{"0x01": sha256Addr.Hex()}}},
+		{name: "shared destination", overrides: precompileOverrides{Moved: map[string]string{"0x01": "0xaa", "0x02": "0xaa"}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.overrides.apply(berlinPrecompiles()); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if err := (precompileOverrides{Moved: map[string]string{"0x01": "not-an-address"}}).validate(); err == nil {
+		t.Error("expected validate to reject a malformed destination")
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// inlinePresetSource names the request's inline gas schedule in preset key reports.
+const inlinePresetSource = "inline"
+
+// NamedSchedules is the registry of gas schedule presets, keyed by name. Each preset
+// bundles the parameter values one EIP specifies, as written in the EIP rather than
+// read from the running fork's defaults, so a proposal touching several EIPs can be
+// modeled by listing their presets in a request. Presets of activated EIPs apply
+// their values to older blocks; the others model the proposal on any block.
+// Presets must not be mutated.
+var NamedSchedules = map[string]*CustomGasSchedule{
+	// Calldata repricing (Istanbul): 16 per nonzero byte
+	"eip2028": {Overrides: map[string]uint64{
+		vm.GasKeyTxDataNonZero: 16,
+	}},
+	// Cold/warm state access (Berlin): SSTORE_RESET drops to 5000 - COLD_SLOAD_COST,
+	// and a no-op SSTORE costs a warm read
+	"eip2929": {Overrides: map[string]uint64{
+		vm.GasKeySloadCold:   2100,
+		vm.GasKeySloadWarm:   100,
+		vm.GasKeySstoreReset: 2900,
+		vm.GasKeySstoreNoop:  100,
+		vm.GasKeyCallCold:    2600,
+		vm.GasKeyCallWarm:    100,
+	}},
+	// Reduced refund cap (London): gas used / 5
+	"eip3529": {Overrides: map[string]uint64{
+		vm.GasKeyRefundCapDiv: 5,
+	}},
+	// Calldata gas reduction (proposed): 3 per byte, zero or not. The per-block
+	// calldata limit it adds is not modeled.
+	"eip4488": {Overrides: map[string]uint64{
+		vm.GasKeyTxDataZero:    3,
+		vm.GasKeyTxDataNonZero: 3,
+	}},
+	// Calldata cost floor (Prague): 10 per token
+	"eip7623": {Overrides: map[string]uint64{
+		vm.GasKeyTxFloorPerToken: 10,
+	}},
+	// MODEXP repricing (Osaka)
+	"eip7883": {Overrides: modexpEIP7883},
+}
+
+// modexpEIP2565 and modexpEIP7883 are the MODEXP formula parameters of each pricing
+// (see vm.PrecompileGasWithOverrides).
+var (
+	modexpEIP2565 = map[string]uint64{
+		vm.GasKeyPCModexpMinGas:            200,
+		vm.GasKeyPCModexpMinComplexity:     0,
+		vm.GasKeyPCModexpComplexityMul:     1,
+		vm.GasKeyPCModexpExpByteIterations: 8,
+		vm.GasKeyPCModexpDivisor:           3,
+	}
+	modexpEIP7883 = map[string]uint64{
+		vm.GasKeyPCModexpMinGas:            500,
+		vm.GasKeyPCModexpMinComplexity:     16,
+		vm.GasKeyPCModexpComplexityMul:     2,
+		vm.GasKeyPCModexpExpByteIterations: 16,
+		vm.GasKeyPCModexpDivisor:           1,
+	}
+)
+
+// Merge returns a new schedule with the overrides of other layered on top of c.
+// Keys set in both take other's value, whether concrete or relative. Either
+// schedule may be nil.
+func (c *CustomGasSchedule) Merge(other *CustomGasSchedule) *CustomGasSchedule {
+	merged := &CustomGasSchedule{Overrides: make(map[string]uint64)}
+
+	if c != nil {
+		maps.Copy(merged.Overrides, c.Overrides)
+
+		if len(c.RelativeOverrides) > 0 {
+			merged.RelativeOverrides = maps.Clone(c.RelativeOverrides)
+		}
+	}
+
+	if other != nil {
+		for key, value := range other.Overrides {
+			merged.Overrides[key] = value
+			delete(merged.RelativeOverrides, key)
+		}
+
+		for key, spec := range other.RelativeOverrides {
+			if merged.RelativeOverrides == nil {
+				merged.RelativeOverrides = make(map[string]RelativeSpec)
+			}
+
+			merged.RelativeOverrides[key] = spec
+			delete(merged.Overrides, key)
+		}
+	}
+
+	return merged
+}
+
+// resolvePresets merges the named presets in order, followed by the inline schedule,
+// so later sources win. It also reports, per source, the keys whose final value came
+// from it; a key overridden by a later source is attributed to that source only.
+// Every listed preset is reported, even if it ended up contributing nothing; the
+// inline schedule is reported as "inline" when it set any key. With no presets the inline schedule is
+// returned unchanged.
+func resolvePresets(
+	presets []string,
+	inline *CustomGasSchedule,
+) (*CustomGasSchedule, map[string][]string, error) {
+	if len(presets) == 0 {
+		return inline, nil, nil
+	}
+
+	for _, name := range presets {
+		if _, ok := NamedSchedules[name]; !ok {
+			return nil, nil, fmt.Errorf("unknown gas schedule preset %q (available: %v)",
+				name, slices.Sorted(maps.Keys(NamedSchedules)))
+		}
+	}
+
+	var merged *CustomGasSchedule
+
+	sources := make(map[string]string)
+
+	apply := func(source string, schedule *CustomGasSchedule) {
+		merged = merged.Merge(schedule)
+
+		if schedule != nil {
+			for key := range schedule.Overrides {
+				sources[key] = source
+			}
+
+			for key := range schedule.RelativeOverrides {
+				sources[key] = source
+			}
+		}
+	}
+
+	for _, name := range presets {
+		apply(name, NamedSchedules[name])
+	}
+
+	apply(inlinePresetSource, inline)
+
+	contributed := make(map[string][]string, len(presets)+1)
+	for _, name := range presets {
+		contributed[name] = []string{}
+	}
+
+	for key, source := range sources {
+		contributed[source] = append(contributed[source], key)
+	}
+
+	for _, keys := range contributed {
+		slices.Sort(keys)
+	}
+
+	return merged, contributed, nil
+}
+
+// resolveBaseSchedule layers a schedule over the full schedule named by base: a
+// fork's defaults (e.g. "london", see GasScheduleForRules) or a NamedSchedules
+// preset. Keys the base sets are applied even where they match the block's own
+// fork, so a block can run under another fork's defaults; keys it does not set
+// (e.g. opcodes the base fork lacks) keep the block's fork defaults. An empty base
+// returns the schedule unchanged.
+func resolveBaseSchedule(base string, schedule *CustomGasSchedule) (*CustomGasSchedule, error) {
+	if base == "" {
+		return schedule, nil
+	}
+
+	if preset, ok := NamedSchedules[base]; ok {
+		return preset.Merge(schedule), nil
+	}
+
+	idx, err := parseFork(base)
+	if err != nil {
+		return nil, fmt.Errorf("unknown base schedule %q: not a fork or preset (presets: %v)",
+			base, slices.Sorted(maps.Keys(NamedSchedules)))
+	}
+
+	return GasScheduleForRules(forkRules(idx)).Merge(schedule), nil
+}
+
+// baseScheduleModes reports whether a base fork predates EIP-2929 and EIP-3529. Its
+// schedule has no cold access costs and prices refunds for the pre-London rules, so
+// the simulation disables the access list and uses the legacy refunds to match.
+// Presets and later forks need neither.
+func baseScheduleModes(base string) (disableAccessList, legacyRefunds bool) {
+	if _, ok := NamedSchedules[base]; ok || base == "" {
+		return false, false
+	}
+
+	idx, err := parseFork(base)
+	if err != nil {
+		return false, false
+	}
+
+	rules := forkRules(idx)
+
+	return !rules.IsBerlin, !rules.IsLondon
+}
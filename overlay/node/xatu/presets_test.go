@@ -0,0 +1,266 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestMerge verifies that the argument's overrides win and neither input is modified.
+func TestMerge(t *testing.T) {
+	base := &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 2100, "ADD": 3}}
+	other := &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 800}}
+
+	merged := base.Merge(other)
+
+	want := map[string]uint64{"SLOAD_COLD": 800, "ADD": 3}
+	if !reflect.DeepEqual(merged.Overrides, want) {
+		t.Fatalf("merged = %v, want %v", merged.Overrides, want)
+	}
+
+	if base.Overrides["SLOAD_COLD"] != 2100 || len(other.Overrides) != 1 {
+		t.Fatal("merge modified its inputs")
+	}
+
+	var nilSchedule *CustomGasSchedule
+	if got := nilSchedule.Merge(nil); got == nil || len(got.Overrides) != 0 {
+		t.Fatalf("nil merge = %v, want an empty schedule", got)
+	}
+}
+
+// TestResolvePresets verifies preset ordering, inline precedence and the per-source
+// key report.
+func TestResolvePresets(t *testing.T) {
+	inline := &CustomGasSchedule{Overrides: map[string]uint64{
+		vm.GasKeySloadCold:    800,
+		vm.GasKeySstoreSet:    10000,
+		vm.GasKeyRefundCapDiv: 2,
+	}}
+
+	merged, keys, err := resolvePresets([]string{"eip2929", "eip3529"}, inline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := merged.Overrides[vm.GasKeySloadCold]; got != 800 {
+		t.Errorf("SLOAD_COLD = %d, want the inline 800", got)
+	}
+
+	if got := merged.Overrides[vm.GasKeyCallCold]; got != 2600 {
+		t.Errorf("CALL_COLD = %d, want the eip2929 2600", got)
+	}
+
+	if got := merged.Overrides[vm.GasKeyRefundCapDiv]; got != 2 {
+		t.Errorf("REFUND_CAP_DIV = %d, want the inline 2", got)
+	}
+
+	want := map[string][]string{
+		"eip2929": {
+			vm.GasKeyCallCold, vm.GasKeyCallWarm, vm.GasKeySloadWarm,
+			vm.GasKeySstoreNoop, vm.GasKeySstoreReset,
+		},
+		"eip3529": {},
+		"inline":  {vm.GasKeyRefundCapDiv, vm.GasKeySloadCold, vm.GasKeySstoreSet},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("preset keys = %v, want %v", keys, want)
+	}
+
+	if len(NamedSchedules["eip2929"].Overrides) != 6 {
+		t.Error("resolving presets modified the registry")
+	}
+}
+
+// TestResolvePresetsErrors verifies unknown presets are rejected and that requests
+// without presets keep their inline schedule.
+func TestResolvePresetsErrors(t *testing.T) {
+	if _, _, err := resolvePresets([]string{"eip2929", "eip9999"}, nil); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+
+	inline := &CustomGasSchedule{Overrides: map[string]uint64{"ADD": 5}}
+
+	merged, keys, err := resolvePresets(nil, inline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merged != inline || keys != nil {
+		t.Errorf("got (%v, %v), want the inline schedule and no key report", merged, keys)
+	}
+}
+
+// TestEIP7883Preset prices MODEXP calls under the eip7883 preset on a pre-Osaka
+// block and verifies that it matches the Osaka precompile, and so charges more than
+// the block's own EIP-2565 pricing, which the EIP-2565 parameters reproduce.
+func TestEIP7883Preset(t *testing.T) {
+	modexpInput := func(base, exp, mod []byte) []byte {
+		var input []byte
+		for _, n := range []int{len(base), len(exp), len(mod)} {
+			input = append(input, big.NewInt(int64(n)).FillBytes(make([]byte, 32))...)
+		}
+
+		return append(append(append(input, base...), exp...), mod...)
+	}
+
+	inputs := map[string][]byte{
+		"eip_example1":          modexpInput([]byte{3}, bytes.Repeat([]byte{0xfe}, 32), bytes.Repeat([]byte{0xff}, 32)),
+		"nagydani_5_pow0x10001": modexpInput(bytes.Repeat([]byte{0xc5}, 1024), []byte{1, 0, 1}, bytes.Repeat([]byte{0xd7}, 1024)),
+		"long_exponent":         modexpInput([]byte{2}, bytes.Repeat([]byte{0xff}, 64), []byte{7}),
+	}
+
+	forks := propertyForks()
+	cancun, osaka := forks[len(forks)-2].rules, forks[len(forks)-1].rules
+
+	modexp := func(rules *chain.Rules) vm.PrecompiledContract {
+		for _, p := range vm.Precompiles(rules) {
+			if p.Name() == "MODEXP" {
+				return p
+			}
+		}
+
+		t.Fatal("no MODEXP precompile")
+
+		return nil
+	}
+
+	preset := NamedSchedules["eip7883"].ToVMGasSchedule()
+	eip2565 := (&CustomGasSchedule{Overrides: modexpEIP2565}).ToVMGasSchedule()
+
+	for name, input := range inputs {
+		stock := modexp(cancun).RequiredGas(input)
+
+		if got := vm.PrecompileGasWithOverrides(eip2565, "MODEXP", input, stock); got != stock {
+			t.Errorf("%s: EIP-2565 parameters give %d, want the stock %d", name, got, stock)
+		}
+
+		got := vm.PrecompileGasWithOverrides(preset, "MODEXP", input, stock)
+		if want := modexp(osaka).RequiredGas(input); got != want {
+			t.Errorf("%s: eip7883 preset gives %d, want the Osaka %d", name, got, want)
+		}
+
+		if got <= stock {
+			t.Errorf("%s: eip7883 preset gives %d, want more than the EIP-2565 %d", name, got, stock)
+		}
+	}
+}
+
+// TestResolveBaseSchedule simulates a Cancun block from London defaults plus two
+// overrides: London's keys and the overrides apply, while Cancun-only opcodes and
+// keys, which London lacks, keep the block's defaults.
+func TestResolveBaseSchedule(t *testing.T) {
+	var cancun *chain.Rules
+	for _, fork := range propertyForks() {
+		if fork.name == "cancun" {
+			cancun = fork.rules
+		}
+	}
+
+	inline := &CustomGasSchedule{Overrides: map[string]uint64{vm.GasKeySloadCold: 800, "ADD": 5}}
+
+	merged, err := resolveBaseSchedule("london", inline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]uint64{vm.GasKeySloadCold: 800, "ADD": 5, vm.GasKeySloadWarm: 100, "MUL": 5} {
+		if got, ok := merged.Overrides[key]; !ok || got != want {
+			t.Errorf("%s = %d (set %v), want %d", key, got, ok, want)
+		}
+	}
+
+	for _, key := range []string{"TLOAD", "MCOPY", GasKeyBlobGasPerBlob} {
+		if _, ok := merged.Overrides[key]; ok {
+			t.Errorf("%s is set, want the block's default", key)
+		}
+	}
+
+	jt := BuildCustomJumpTable(cancun, merged, JumpTableOptions{})
+	if got := jt[vm.ADD].GetConstantGas(); got != 5 {
+		t.Errorf("ADD constant gas = %d, want 5", got)
+	}
+
+	if got := jt[vm.TLOAD].GetConstantGas(); got != 100 {
+		t.Errorf("TLOAD constant gas = %d, want the Cancun 100", got)
+	}
+
+	// An older base reprices opcodes the block's fork changed, and runs without the
+	// access list and with the legacy refunds it was priced for
+	istanbul, err := resolveBaseSchedule("istanbul", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disableAccessList, legacyRefunds := baseScheduleModes("istanbul")
+	if !disableAccessList || !legacyRefunds {
+		t.Fatalf("Istanbul base modes = %v, %v, want true, true", disableAccessList, legacyRefunds)
+	}
+
+	istanbulJT := BuildCustomJumpTable(cancun, istanbul,
+		JumpTableOptions{DisableEIP2929: disableAccessList, LegacyRefunds: legacyRefunds})
+	if got := istanbulJT[vm.SLOAD].GetConstantGas(); got != 800 {
+		t.Errorf("SLOAD constant gas from an Istanbul base = %d, want 800", got)
+	}
+
+	for base, want := range map[string][2]bool{
+		"berlin":  {false, true},
+		"london":  {false, false},
+		"eip2929": {false, false},
+		"":        {false, false},
+	} {
+		if disableAccessList, legacyRefunds := baseScheduleModes(base); disableAccessList != want[0] || legacyRefunds != want[1] {
+			t.Errorf("baseScheduleModes(%q) = %v, %v, want %v, %v", base, disableAccessList, legacyRefunds, want[0], want[1])
+		}
+	}
+
+	if preset, err := resolveBaseSchedule("eip2929", inline); err != nil || preset.Overrides[vm.GasKeyCallCold] != 2600 {
+		t.Errorf("preset base = %v, %v, want eip2929 with CALL_COLD 2600", preset, err)
+	}
+
+	if got, err := resolveBaseSchedule("", inline); err != nil || got != inline {
+		t.Errorf("empty base = %v, %v, want the schedule unchanged", got, err)
+	}
+
+	if _, err := resolveBaseSchedule("eip9999", inline); err == nil {
+		t.Error("expected an error for an unknown base")
+	}
+}
+
+// TestEIP4488Preset checks that the eip4488 preset prices calldata at its proposed
+// 3 gas per byte, zero or not, rather than the current 4 and 16.
+func TestEIP4488Preset(t *testing.T) {
+	data := []byte{0, 0, 1, 2, 3}
+
+	gas, _ := vm.CalcCustomIntrinsicGas(NamedSchedules["eip4488"].ToVMGasSchedule(), data, 0, 0, false,
+		true, true, true, true, false, 0)
+	if want := uint64(21000 + 3*len(data)); gas != want {
+		t.Errorf("intrinsic gas = %d, want %d", gas, want)
+	}
+
+	current, _ := vm.CalcCustomIntrinsicGas(nil, data, 0, 0, false, true, true, true, true, false, 0)
+	if want := uint64(21000 + 2*4 + 3*16); current != want {
+		t.Errorf("intrinsic gas without the preset = %d, want %d", current, want)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erigontech/erigon/db/kv"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// maxPrewarmBlocks bounds the number of blocks a single prewarm request may read.
+const maxPrewarmBlocks = 256
+
+// PrewarmResult is the result of xatu_prewarm.
+type PrewarmResult struct {
+	StartBlock uint64 `json:"startBlock"`
+	EndBlock   uint64 `json:"endBlock"`
+	Warmed     int    `json:"warmed"` // Blocks read successfully
+	Failed     int    `json:"failed"` // Blocks that could not be read (missing or pruned)
+}
+
+// Prewarm loads the blocks from startBlock to endBlock (inclusive) and their
+// receipts, so that an interactive session simulating the range finds them cached.
+// Receipts are read as blockReceipts reads them: from the receipt cache domain, or
+// regenerated through the receipts generator (which keeps them in its own cache)
+// when the domain does not hold them. Regenerating re-executes the block, so a cold
+// range takes about as long as simulating it once.
+//
+// Prewarming is best effort. Blocks whose block or receipts cannot be loaded are
+// counted as failed and skipped; only an invalid range or a cancelled context
+// fails the request.
+func (s *Service) Prewarm(ctx context.Context, startBlock, endBlock uint64) (*PrewarmResult, error) {
+	if endBlock < startBlock {
+		return nil, fmt.Errorf("end block %d is before start block %d", endBlock, startBlock)
+	}
+
+	if endBlock-startBlock >= maxPrewarmBlocks {
+		return nil, fmt.Errorf("range covers more than %d blocks", maxPrewarmBlocks)
+	}
+
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	return prewarmRange(ctx, startBlock, endBlock, func(blockNum uint64) error {
+		return s.prewarmBlock(ctx, tx, blockNum)
+	})
+}
+
+// prewarmRange calls warm for every block in the range and counts the outcomes.
+// It stops early only if ctx is cancelled.
+func prewarmRange(ctx context.Context, startBlock, endBlock uint64, warm func(blockNum uint64) error) (*PrewarmResult, error) {
+	result := &PrewarmResult{StartBlock: startBlock, EndBlock: endBlock}
+
+	for blockNum := startBlock; ; blockNum++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if err := warm(blockNum); err != nil {
+			result.Failed++
+		} else {
+			result.Warmed++
+		}
+
+		// Checked before incrementing, so an end block of MaxUint64 cannot wrap
+		if blockNum == endBlock {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// prewarmBlock loads a block and its receipts.
+func (s *Service) prewarmBlock(ctx context.Context, tx kv.TemporalTx, blockNum uint64) error {
+	return warmBlock(blockNum,
+		func(blockNum uint64) (*erigontypes.Block, error) {
+			return s.blockReader.BlockByNumber(ctx, tx, blockNum)
+		},
+		func(block *erigontypes.Block) (erigontypes.Receipts, error) {
+			return s.blockReceipts(ctx, tx, block)
+		},
+	)
+}
+
+// warmBlock loads a block with readBlock and its receipts with readReceipts. It
+// fails unless both load in full, so a block is only reported warm when every
+// receipt a simulation needs is cached.
+func warmBlock(
+	blockNum uint64,
+	readBlock func(blockNum uint64) (*erigontypes.Block, error),
+	readReceipts func(block *erigontypes.Block) (erigontypes.Receipts, error),
+) error {
+	block, err := readBlock(blockNum)
+	if err != nil {
+		return fmt.Errorf("failed to get block %d: %w", blockNum, err)
+	}
+
+	if block == nil {
+		return fmt.Errorf("block %d not found", blockNum)
+	}
+
+	receipts, err := readReceipts(block)
+	if err != nil {
+		return fmt.Errorf("failed to read receipts of block %d: %w", blockNum, err)
+	}
+
+	if len(receipts) != len(block.Transactions()) {
+		return fmt.Errorf("block %d has %d receipts for %d transactions", blockNum, len(receipts), len(block.Transactions()))
+	}
+
+	return nil
+}
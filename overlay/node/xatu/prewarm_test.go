@@ -0,0 +1,130 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// TestPrewarmRange verifies that every block in the range is warmed once, that
+// failures are counted without aborting, and that a range ending at MaxUint64
+// terminates.
+func TestPrewarmRange(t *testing.T) {
+	var warmed []uint64
+	result, err := prewarmRange(context.Background(), 100, 104, func(blockNum uint64) error {
+		warmed = append(warmed, blockNum)
+		if blockNum == 102 {
+			return errors.New("block 102 not found")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(warmed) != 5 || warmed[0] != 100 || warmed[4] != 104 {
+		t.Errorf("warmed blocks = %v, want 100..104", warmed)
+	}
+
+	want := PrewarmResult{StartBlock: 100, EndBlock: 104, Warmed: 4, Failed: 1}
+	if *result != want {
+		t.Errorf("result = %+v, want %+v", *result, want)
+	}
+
+	result, err = prewarmRange(context.Background(), math.MaxUint64-1, math.MaxUint64, func(uint64) error { return nil })
+	if err != nil || result.Warmed != 2 {
+		t.Errorf("range ending at MaxUint64: result = %+v, err = %v; want 2 warmed", result, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := prewarmRange(ctx, 1, 10, func(uint64) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled context: err = %v, want context.Canceled", err)
+	}
+}
+
+// TestWarmBlock verifies that a block only counts as warm when it and all of its
+// receipts load.
+func TestWarmBlock(t *testing.T) {
+	block := erigontypes.NewBlockWithHeader(&erigontypes.Header{Number: big.NewInt(7)})
+
+	readBlock := func(uint64) (*erigontypes.Block, error) { return block, nil }
+	receipts := func(recs erigontypes.Receipts, err error) func(*erigontypes.Block) (erigontypes.Receipts, error) {
+		return func(*erigontypes.Block) (erigontypes.Receipts, error) { return recs, err }
+	}
+
+	tests := []struct {
+		name         string
+		readBlock    func(uint64) (*erigontypes.Block, error)
+		readReceipts func(*erigontypes.Block) (erigontypes.Receipts, error)
+		wantErr      bool
+	}{
+		{name: "warm", readBlock: readBlock, readReceipts: receipts(erigontypes.Receipts{}, nil)},
+		{
+			name:         "block error",
+			readBlock:    func(uint64) (*erigontypes.Block, error) { return nil, errors.New("pruned") },
+			readReceipts: receipts(erigontypes.Receipts{}, nil),
+			wantErr:      true,
+		},
+		{
+			name:         "block missing",
+			readBlock:    func(uint64) (*erigontypes.Block, error) { return nil, nil },
+			readReceipts: receipts(erigontypes.Receipts{}, nil),
+			wantErr:      true,
+		},
+		{name: "receipts error", readBlock: readBlock, readReceipts: receipts(nil, errors.New("regeneration failed")), wantErr: true},
+		{name: "receipt count mismatch", readBlock: readBlock, readReceipts: receipts(erigontypes.Receipts{{}}, nil), wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := warmBlock(7, tc.readBlock, tc.readReceipts); (err != nil) != tc.wantErr {
+				t.Errorf("warmBlock() err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrewarmValidation(t *testing.T) {
+	s := &Service{}
+
+	tests := []struct {
+		name       string
+		start, end uint64
+	}{
+		{name: "inverted range", start: 10, end: 1},
+		{name: "too many blocks", start: 0, end: maxPrewarmBlocks},
+		{name: "whole chain", start: 0, end: math.MaxUint64},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := s.Prewarm(context.Background(), tc.start, tc.end); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/erigontech/erigon/db/kv"
+	"github.com/erigontech/erigon/db/kv/rawdbv3"
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol"
+	erigonstate "github.com/erigontech/erigon/execution/state"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+	"github.com/erigontech/erigon/execution/vm/evmtypes"
+)
+
+// SimulateRawTransactionGasResult is the result of xatu_simulateRawTransactionGas.
+type SimulateRawTransactionGasResult struct {
+	TransactionHash string                   `json:"transactionHash"`
+	Sender          string                   `json:"sender"`
+	BlockNumber     uint64                   `json:"blockNumber"`
+	Status          string                   `json:"status"` // Status of the simulated execution
+	Error           string                   `json:"error,omitempty"`
+	Original        TxGasDetail              `json:"original"`
+	Simulated       TxGasDetail              `json:"simulated"`
+	OpcodeBreakdown map[string]OpcodeSummary `json:"opcodeBreakdown"`
+}
+
+// SimulateRawTransactionGas runs a raw signed transaction (as it would sit in the
+// mempool) against the state at the end of blockNumber, with standard gas costs and
+// with the custom schedule, for gas analysis before submission.
+//
+// The sender is recovered from the signature with the signer of the block's fork.
+// As with replays, the nonce is not checked, so a transaction queued behind others
+// from the same sender still runs; the sender's balance is checked.
+//
+// The block's withdrawals are credited before the transaction runs, so a sender
+// funded by one can pay for it. The post-block system calls (the EIP-7002 and
+// EIP-7251 request dequeues) are not applied: they only change their system
+// contracts' storage, which a transaction reading those contracts would see as of
+// before the calls.
+func (s *Service) SimulateRawTransactionGas(
+	ctx context.Context,
+	rawTx string,
+	blockNumber uint64,
+	schedule *CustomGasSchedule,
+) (*SimulateRawTransactionGasResult, error) {
+	if err := schedule.validateRelativeOverrides(); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	block, err := s.blockReader.BlockByNumber(ctx, tx, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %d: %w", blockNumber, err)
+	}
+
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+
+	header := block.Header()
+	opts := executionOptions{GasSchedule: schedule}
+
+	signer := erigontypes.MakeSigner(s.chainConfigFor(ctx, opts), blockNumber, header.Time)
+
+	txn, sender, err := decodeRawTransaction(rawTx, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSupportedFork(ctx, opts, blockNumber, header.Time); err != nil {
+		return nil, err
+	}
+
+	if err := s.resolveRelativeOverrides(ctx, &opts, blockNumber, header.Time); err != nil {
+		return nil, err
+	}
+
+	txNumReader := s.txNumsReader(ctx)
+
+	dualResult, err := runDualExecution(opts, SimulationTracerConfig{}, func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+		// Each execution gets a fresh transaction so state changes don't leak between runs
+		dbTx, err := s.db.BeginTemporalRo(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer dbTx.Rollback()
+
+		return s.executeRawTransaction(ctx, dbTx, header, block, txNumReader, txn, tracer, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute transaction: %w", err)
+	}
+
+	return &SimulateRawTransactionGasResult{
+		TransactionHash: txn.Hash().Hex(),
+		Sender:          sender,
+		BlockNumber:     blockNumber,
+		Status:          dualResult.Simulated.Status,
+		Error:           executionError(dualResult.Simulated),
+		Original:        newTxGasDetail(dualResult.Original),
+		Simulated:       newTxGasDetail(dualResult.Simulated),
+		OpcodeBreakdown: dualResult.OpcodeBreakdown,
+	}, nil
+}
+
+// decodeRawTransaction decodes a hex-encoded signed transaction (RLP for legacy
+// transactions, the EIP-2718 envelope for typed ones) and recovers its sender.
+func decodeRawTransaction(rawTx string, signer *erigontypes.Signer) (erigontypes.Transaction, string, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(rawTx, "0x"))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid raw transaction hex: %w", err)
+	}
+
+	if len(data) == 0 {
+		return nil, "", fmt.Errorf("raw transaction is required")
+	}
+
+	txn, err := erigontypes.DecodeTransaction(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode raw transaction: %w", err)
+	}
+
+	sender, err := txn.Sender(*signer)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to recover transaction sender: %w", err)
+	}
+
+	return txn, normalizeAddress(sender.String()), nil
+}
+
+// executeRawTransaction executes a decoded transaction on top of the state after
+// the given block, as executeSingleTransaction does for a transaction in the block.
+// The state includes the block's withdrawals but not its post-block system calls
+// (see SimulateRawTransactionGas).
+func (s *Service) executeRawTransaction(
+	ctx context.Context,
+	dbTx kv.TemporalTx,
+	header *erigontypes.Header,
+	block *erigontypes.Block,
+	txNumReader rawdbv3.TxNumsReader,
+	txn erigontypes.Transaction,
+	tracer *SimulationTracer,
+	opts executionOptions,
+) (result *executionResult, err error) {
+	// The transaction runs after the block's last transaction
+	txIndex := len(block.Transactions())
+
+	// Contain panics from custom gas functions to this transaction
+	defer s.recoverExecutionPanic(txIndex, &result, &err)
+
+	execChainConfig := s.chainConfigFor(ctx, opts)
+
+	statedb, blockCtx, chainRules, signer, err := s.computeBlockContext(ctx, dbTx, header, txIndex, txNumReader, execChainConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.executeRawOnState(ctx, statedb, blockCtx, chainRules, signer, execChainConfig, header, block.Withdrawals(), txn, tracer, opts)
+}
+
+// executeRawOnState credits withdrawals to statedb, which holds the state after the
+// block's transactions, and executes txn on top of it.
+func (s *Service) executeRawOnState(
+	ctx context.Context,
+	statedb *erigonstate.IntraBlockState,
+	blockCtx evmtypes.BlockContext,
+	chainRules *chain.Rules,
+	signer *erigontypes.Signer,
+	execChainConfig *chain.Config,
+	header *erigontypes.Header,
+	withdrawals []*erigontypes.Withdrawal,
+	txn erigontypes.Transaction,
+	tracer *SimulationTracer,
+	opts executionOptions,
+) (*executionResult, error) {
+	if err := applyWithdrawals(statedb, withdrawals); err != nil {
+		return nil, err
+	}
+
+	msg, err := txn.AsMessage(*signer, header.BaseFee, chainRules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert transaction to message: %w", err)
+	}
+
+	// Disable nonce verification, as for replays
+	msg.SetCheckNonce(false)
+
+	txCtx := protocol.NewEVMTxContext(msg)
+	intrinsicGas := calcIntrinsicGasForTx(txn, chainRules, opts.GasSchedule)
+
+	return s.executeMessage(ctx, statedb, blockCtx, txCtx, msg, header, chainRules, execChainConfig, intrinsicGas, false, tracer, opts)
+}
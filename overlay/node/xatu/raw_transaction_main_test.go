@@ -0,0 +1,70 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// TestExecuteRawTransaction runs the EIP-155 example transfer, whose sender holds
+// nothing, on a chain where the block's withdrawals fund the sender. It fails the
+// balance check without the withdrawal and transfers with it.
+func TestExecuteRawTransaction(t *testing.T) {
+	signer := erigontypes.LatestSignerForChainID(big.NewInt(1))
+
+	txn, sender, err := decodeRawTransaction(eip155ExampleTx, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 ether covers the 1 ether value and 21000 gas at 20 gwei
+	withdrawal := &erigontypes.Withdrawal{Index: 1, Address: common.HexToAddress(sender), Amount: 2e9}
+
+	for _, tc := range []struct {
+		name        string
+		withdrawals []*erigontypes.Withdrawal
+		wantApplied bool
+	}{
+		{name: "unfunded sender"},
+		{name: "funded by a withdrawal", withdrawals: []*erigontypes.Withdrawal{withdrawal}, wantApplied: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestChain(t, nil)
+
+			result, err := c.s.executeRawOnState(context.Background(), c.statedb, c.blockCtx, c.rules, signer, c.config,
+				c.header, tc.withdrawals, txn, nil, executionOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if applied := result.ApplyErr == nil; applied != tc.wantApplied {
+				t.Fatalf("ApplyErr = %v, want the transaction applied: %v", result.ApplyErr, tc.wantApplied)
+			}
+
+			if tc.wantApplied && (result.Status != "success" || result.GasUsed != 21000) {
+				t.Errorf("status = %s, gas used = %d, want success, 21000", result.Status, result.GasUsed)
+			}
+		})
+	}
+}
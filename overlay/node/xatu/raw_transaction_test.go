@@ -0,0 +1,73 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// eip155ExampleTx is the signed example transaction from EIP-155: a 1 ETH transfer
+// on chain 1, signed with the private key 0x4646...46.
+const eip155ExampleTx = "0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+
+// TestDecodeRawTransaction verifies that a raw signed transaction decodes and its
+// sender is recovered, and that malformed input fails with a clear error.
+func TestDecodeRawTransaction(t *testing.T) {
+	signer := erigontypes.LatestSignerForChainID(big.NewInt(1))
+
+	txn, sender, err := decodeRawTransaction(eip155ExampleTx, signer)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if sender != "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f" {
+		t.Errorf("sender = %s, want 0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f", sender)
+	}
+
+	if txn.GetNonce() != 9 || txn.GetGasLimit() != 21000 {
+		t.Errorf("nonce = %d, gas = %d, want 9, 21000", txn.GetNonce(), txn.GetGasLimit())
+	}
+
+	if got := txn.Hash().Hex(); got != "0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788" {
+		t.Errorf("hash = %s", got)
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{name: "empty", raw: "0x", wantErr: "raw transaction is required"},
+		{name: "invalid hex", raw: "0xzz", wantErr: "invalid raw transaction hex"},
+		{name: "not a transaction", raw: "0xdeadbeef", wantErr: "failed to decode raw transaction"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := decodeRawTransaction(tc.raw, signer)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// ReceiptComparison compares the original execution with the transaction's
+// on-chain receipt. A log count mismatch means the re-execution diverged from
+// the canonical one. GasUsed is expected to differ when CalldataOverride is set.
+type ReceiptComparison struct {
+	Status            uint64 `json:"status"`
+	GasUsed           uint64 `json:"gasUsed"`
+	CumulativeGasUsed uint64 `json:"cumulativeGasUsed"`
+	LogCount          int    `json:"logCount"`
+	// ExecutedLogCount is the number of logs the original execution kept, i.e.
+	// emitted by LOG opcodes in frames that did not revert.
+	ExecutedLogCount int  `json:"executedLogCount"`
+	LogCountMatches  bool `json:"logCountMatches"`
+	GasUsedMatches   bool `json:"gasUsedMatches"`
+}
+
+// receiptAt returns the receipt of the transaction at txIndex in a block with
+// txCount transactions. A transaction missing from the block and a receipt missing
+// for a transaction in it are reported as different errors.
+func receiptAt(receipts erigontypes.Receipts, txCount, txIndex int) (*erigontypes.Receipt, error) {
+	if txIndex < 0 || txIndex >= txCount {
+		return nil, fmt.Errorf("tx %d not found: the block has %d transactions", txIndex, txCount)
+	}
+
+	if txIndex >= len(receipts) || receipts[txIndex] == nil {
+		return nil, fmt.Errorf("receipt for tx %d not found", txIndex)
+	}
+
+	return receipts[txIndex], nil
+}
+
+// newReceiptComparison compares a receipt with the logs and gas used of the
+// original execution.
+func newReceiptComparison(receipt *erigontypes.Receipt, logs []EmittedLog, gasUsed uint64) *ReceiptComparison {
+	executed := keptLogCount(logs)
+
+	return &ReceiptComparison{
+		Status:            receipt.Status,
+		GasUsed:           receipt.GasUsed,
+		CumulativeGasUsed: receipt.CumulativeGasUsed,
+		LogCount:          len(receipt.Logs),
+		ExecutedLogCount:  executed,
+		LogCountMatches:   executed == len(receipt.Logs),
+		GasUsedMatches:    gasUsed == receipt.GasUsed,
+	}
+}
+
+// keptLogCount counts the logs that were not discarded by a reverting frame.
+func keptLogCount(logs []EmittedLog) int {
+	count := 0
+
+	for _, l := range logs {
+		if !l.Reverted {
+			count++
+		}
+	}
+
+	return count
+}
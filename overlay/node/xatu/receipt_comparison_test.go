@@ -0,0 +1,118 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// TestReceiptComparison compares an event-emitting transaction's receipt with
+// the logs captured during re-execution, including logs from a reverted frame.
+func TestReceiptComparison(t *testing.T) {
+	receipt := &erigontypes.Receipt{
+		Status:            erigontypes.ReceiptStatusSuccessful,
+		GasUsed:           52000,
+		CumulativeGasUsed: 121000,
+		Logs:              []*erigontypes.Log{{}, {}},
+	}
+
+	tests := []struct {
+		name        string
+		logs        []EmittedLog
+		gasUsed     uint64
+		wantLogs    int
+		wantMatches bool
+		wantGas     bool
+	}{
+		{
+			name:        "matching",
+			logs:        []EmittedLog{{Depth: 0}, {Depth: 1}},
+			gasUsed:     52000,
+			wantLogs:    2,
+			wantMatches: true,
+			wantGas:     true,
+		},
+		{
+			name:        "reverted frame logs excluded",
+			logs:        []EmittedLog{{Depth: 0}, {Depth: 1, Reverted: true}, {Depth: 1}},
+			gasUsed:     52000,
+			wantLogs:    2,
+			wantMatches: true,
+			wantGas:     true,
+		},
+		{
+			name:        "diverged",
+			logs:        []EmittedLog{{Depth: 0}},
+			gasUsed:     48000,
+			wantLogs:    1,
+			wantMatches: false,
+			wantGas:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newReceiptComparison(receipt, tt.logs, tt.gasUsed)
+
+			if got.LogCount != 2 || got.CumulativeGasUsed != 121000 || got.Status != 1 {
+				t.Fatalf("unexpected receipt fields: %+v", got)
+			}
+
+			if got.ExecutedLogCount != tt.wantLogs {
+				t.Errorf("ExecutedLogCount = %d, want %d", got.ExecutedLogCount, tt.wantLogs)
+			}
+
+			if got.LogCountMatches != tt.wantMatches {
+				t.Errorf("LogCountMatches = %v, want %v", got.LogCountMatches, tt.wantMatches)
+			}
+
+			if got.GasUsedMatches != tt.wantGas {
+				t.Errorf("GasUsedMatches = %v, want %v", got.GasUsedMatches, tt.wantGas)
+			}
+		})
+	}
+}
+
+// TestReceiptAt checks that a missing transaction and a missing receipt are
+// reported as different errors.
+func TestReceiptAt(t *testing.T) {
+	receipts := erigontypes.Receipts{{GasUsed: 21000}, nil}
+
+	if receipt, err := receiptAt(receipts, 3, 0); err != nil || receipt.GasUsed != 21000 {
+		t.Errorf("receiptAt(0) = %+v, %v; want the first receipt", receipt, err)
+	}
+
+	_, missingTx := receiptAt(receipts, 3, 3)
+	_, missingReceipt := receiptAt(receipts, 3, 2)
+	_, nilReceipt := receiptAt(receipts, 3, 1)
+
+	if missingTx == nil || missingReceipt == nil || nilReceipt == nil {
+		t.Fatalf("errors = %v, %v, %v; want all three to fail", missingTx, missingReceipt, nilReceipt)
+	}
+
+	if missingTx.Error() == missingReceipt.Error() {
+		t.Errorf("missing transaction and missing receipt share the error %q", missingTx)
+	}
+
+	if nilReceipt.Error() != missingReceipt.Error() {
+		t.Errorf("nil receipt error = %q, want %q", nilReceipt, missingReceipt)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"github.com/erigontech/erigon/diagnostics/metrics"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+var (
+	receiptCacheHits   = metrics.GetOrCreateCounter(`xatu_receipt_cache_total{result="hit"}`)
+	receiptCacheMisses = metrics.GetOrCreateCounter(`xatu_receipt_cache_total{result="miss"}`)
+)
+
+// receiptsWithFallback serves a block's receipts from the receipt cache when it holds
+// a receipt for every transaction, and regenerates them otherwise. The cache returns
+// an empty or short list (with a nil error) when it is cold, e.g. on a freshly-started
+// node or for blocks behind the cache window, so a nil error alone is not a hit.
+//
+// The returned bool reports whether the cache was hit.
+func receiptsWithFallback(
+	txCount int,
+	readCache func() (erigontypes.Receipts, error),
+	regenerate func() (erigontypes.Receipts, error),
+) (erigontypes.Receipts, bool, error) {
+	cached, err := readCache()
+	if err == nil && len(cached) == txCount {
+		receiptCacheHits.Inc()
+		return cached, true, nil
+	}
+
+	receiptCacheMisses.Inc()
+
+	recs, err := regenerate()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return recs, false, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"errors"
+	"testing"
+
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// TestReceiptsWithFallback simulates cold, partial and warm receipt caches and
+// verifies that receipts are regenerated unless the cache holds a full set.
+func TestReceiptsWithFallback(t *testing.T) {
+	full := erigontypes.Receipts{{GasUsed: 21000}, {GasUsed: 50000}, {GasUsed: 30000}}
+
+	tests := []struct {
+		name        string
+		txCount     int
+		cached      erigontypes.Receipts
+		cacheErr    error
+		wantHit     bool
+		wantRegen   bool
+		wantRecsLen int
+	}{
+		{name: "cold cache", txCount: 3, cached: nil, wantRegen: true, wantRecsLen: 3},
+		{name: "partial cache", txCount: 3, cached: full[:1], wantRegen: true, wantRecsLen: 3},
+		{name: "cache read error", txCount: 3, cacheErr: errors.New("boom"), wantRegen: true, wantRecsLen: 3},
+		{name: "warm cache", txCount: 3, cached: full, wantHit: true, wantRecsLen: 3},
+		{name: "empty block", txCount: 0, cached: nil, wantHit: true, wantRecsLen: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			regenerated := false
+
+			recs, hit, err := receiptsWithFallback(tc.txCount,
+				func() (erigontypes.Receipts, error) { return tc.cached, tc.cacheErr },
+				func() (erigontypes.Receipts, error) {
+					regenerated = true
+					return full[:tc.txCount], nil
+				},
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if hit != tc.wantHit {
+				t.Errorf("hit = %v, want %v", hit, tc.wantHit)
+			}
+
+			if regenerated != tc.wantRegen {
+				t.Errorf("regenerated = %v, want %v", regenerated, tc.wantRegen)
+			}
+
+			if len(recs) != tc.wantRecsLen {
+				t.Errorf("got %d receipts, want %d", len(recs), tc.wantRecsLen)
+			}
+		})
+	}
+
+	// Regeneration errors are returned to the caller
+	_, _, err := receiptsWithFallback(1,
+		func() (erigontypes.Receipts, error) { return nil, nil },
+		func() (erigontypes.Receipts, error) { return nil, errors.New("exec failed") },
+	)
+	if err == nil {
+		t.Error("expected regeneration error")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// REFUND_CAP_DIV is applied in the reporting layer rather than in the EVM: the cap is
+// computed inside Erigon's ApplyMessage, which reads the divisor from params directly.
+// The simulated execution therefore runs with the standard cap, and its net gas is
+// recomputed afterwards from the pre-refund gas and the uncapped refund counter.
+// State and execution paths are unaffected, since refunds are applied after execution.
+
+// refundQuotient returns the standard refund cap divisor for a fork.
+func refundQuotient(isLondon bool) uint64 {
+	if isLondon {
+		return params.RefundQuotientEIP3529
+	}
+
+	return params.RefundQuotient
+}
+
+// cappedRefund returns the refund granted for gasUsed (pre-refund) with the given
+// refund counter and cap divisor. A divisor of 0 disables refunds.
+func cappedRefund(gasUsed, refund, divisor uint64) uint64 {
+	if divisor == 0 {
+		return 0
+	}
+
+	return min(refund, gasUsed/divisor)
+}
+
+// refundCapDivisor returns the REFUND_CAP_DIV override, if set.
+func (c *CustomGasSchedule) refundCapDivisor() (uint64, bool) {
+	if c == nil {
+		return 0, false
+	}
+
+	divisor, ok := c.Overrides[vm.GasKeyRefundCapDiv]
+
+	return divisor, ok
+}
+
+// refundCapDivisor returns the refund cap divisor the simulated execution's net gas
+// is recomputed with, if it differs from the EVM's: the REFUND_CAP_DIV override, or
+// the pre-London divisor with LegacyRefunds.
+func (o executionOptions) refundCapDivisor() (uint64, bool) {
+	if divisor, ok := o.GasSchedule.refundCapDivisor(); ok {
+		return divisor, true
+	}
+
+	if o.LegacyRefunds {
+		return params.RefundQuotient, true
+	}
+
+	return 0, false
+}
+
+// applyRefundCapOverride recomputes the net gas of an execution with a custom refund
+// cap divisor. Executions that failed before or during EVM setup are left unchanged.
+func applyRefundCapOverride(result *executionResult, divisor uint64) {
+	if result.ApplyErr != nil || result.Panicked {
+		return
+	}
+
+	gasUsed := result.IntrinsicGas + result.ExecutionGas
+	custom := gasUsed - cappedRefund(gasUsed, result.Refund, divisor)
+
+	// The EIP-7623 calldata floor applies after refunds, so it bounds the recomputed
+	// net gas from below even where it did not bind under the standard divisor
+	result.GasUsed = max(custom, result.FloorGas)
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/protocol/params"
+)
+
+// TestLegacyRefundsExecution clears a storage slot through executeMessage and checks
+// the refund counter follows EIP-3529, or EIP-2200 with LegacyRefunds.
+func TestLegacyRefundsExecution(t *testing.T) {
+	// Stores the first calldata word to slot 1
+	code := []byte{0x60, 0x00, 0x35, 0x60, 0x01, 0x55, 0x00}
+
+	zero := make([]byte, 32)
+	one := make([]byte, 32)
+	one[31] = 1
+
+	tests := []struct {
+		name string
+		opts executionOptions
+		want uint64
+	}{
+		{name: "EIP-3529 refunds", opts: executionOptions{}, want: params.SstoreClearsScheduleRefundEIP3529},
+		{name: "legacy refunds", opts: executionOptions{LegacyRefunds: true}, want: params.SstoreClearsScheduleRefundEIP2200},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestChain(t, map[common.Address][]byte{testContract: code})
+
+			c.call(testContract, one, tc.opts)
+
+			result := c.call(testContract, zero, tc.opts)
+			if result.Refund != tc.want {
+				t.Errorf("Refund = %d, want %d", result.Refund, tc.want)
+			}
+		})
+	}
+}
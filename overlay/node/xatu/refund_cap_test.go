@@ -0,0 +1,172 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestApplyRefundCapOverride verifies net gas under the default EIP-3529 divisor and
+// overridden divisors for a transaction with pre-refund gas of 100,000.
+func TestApplyRefundCapOverride(t *testing.T) {
+	newResult := func(refund uint64) *executionResult {
+		// 21,000 intrinsic + 79,000 execution = 100,000 pre-refund. With the
+		// standard divisor the refund is capped at 20,000.
+		return &executionResult{
+			GasUsed:        100000 - min(refund, 20000),
+			IntrinsicGas:   21000,
+			ExecutionGas:   79000,
+			Refund:         refund,
+			RefundQuotient: 5,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		refund  uint64
+		divisor uint64
+		want    uint64
+	}{
+		{name: "default divisor is unchanged", refund: 40000, divisor: 5, want: 80000},
+		{name: "stricter cap", refund: 40000, divisor: 10, want: 90000},
+		{name: "looser cap", refund: 40000, divisor: 2, want: 60000},
+		{name: "refund below either cap", refund: 4800, divisor: 10, want: 95200},
+		{name: "zero disables refunds", refund: 40000, divisor: 0, want: 100000},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := newResult(tc.refund)
+			applyRefundCapOverride(result, tc.divisor)
+
+			if result.GasUsed != tc.want {
+				t.Errorf("GasUsed = %d, want %d", result.GasUsed, tc.want)
+			}
+		})
+	}
+
+	// The calldata floor bounds net gas from below when it set the EVM's result
+	floored := newResult(40000)
+	floored.GasUsed = 85000 // floor above the standard net gas of 80,000
+	floored.FloorGas = 85000
+	applyRefundCapOverride(floored, 2)
+
+	if floored.GasUsed != 85000 {
+		t.Errorf("floored GasUsed = %d, want 85000", floored.GasUsed)
+	}
+
+	// A floor below the standard net gas still binds when the custom divisor grants
+	// a larger refund
+	looser := newResult(40000)
+	looser.FloorGas = 70000 // below the standard 80,000, above the custom 60,000
+	applyRefundCapOverride(looser, 2)
+
+	if looser.GasUsed != 70000 {
+		t.Errorf("looser GasUsed = %d, want 70000", looser.GasUsed)
+	}
+
+	// Pre-execution failures are left untouched
+	failed := &executionResult{GasUsed: 0, ApplyErr: errors.New("intrinsic gas too low"), Refund: 100}
+	applyRefundCapOverride(failed, 1)
+
+	if failed.GasUsed != 0 {
+		t.Errorf("failed GasUsed = %d, want 0", failed.GasUsed)
+	}
+}
+
+func TestRefundCapDivisor(t *testing.T) {
+	var nilSchedule *CustomGasSchedule
+	if _, ok := nilSchedule.refundCapDivisor(); ok {
+		t.Error("expected no divisor for nil schedule")
+	}
+
+	schedule := &CustomGasSchedule{Overrides: map[string]uint64{vm.GasKeyRefundCapDiv: 0}}
+	if divisor, ok := schedule.refundCapDivisor(); !ok || divisor != 0 {
+		t.Errorf("refundCapDivisor() = %d, %v; want 0, true", divisor, ok)
+	}
+
+	if refundQuotient(true) != 5 || refundQuotient(false) != 2 {
+		t.Errorf("unexpected standard divisors: london=%d, pre-london=%d", refundQuotient(true), refundQuotient(false))
+	}
+}
+
+// TestLegacyRefunds checks how runDualExecution re-caps the refund of a London
+// transaction that clears a storage slot, with 40,000 gas before refunds, under the
+// EIP-3529 and the pre-London refund regimes. The refunds themselves come from the
+// SSTORE gas functions, covered by TestLegacyRefundsExecution.
+func TestLegacyRefunds(t *testing.T) {
+	// Stand-in for the EVM with the refunds TestLegacyRefundsExecution observes,
+	// capped by ApplyMessage with the London divisor either way. The executions
+	// have no opcodes, so all pre-refund gas is reported as intrinsic.
+	execute := func(_ *SimulationTracer, opts executionOptions) (*executionResult, error) {
+		refund := params.SstoreClearsScheduleRefundEIP3529
+		if opts.LegacyRefunds {
+			refund = params.SstoreClearsScheduleRefundEIP2200
+		}
+
+		return &executionResult{
+			GasUsed:        40000 - min(refund, 40000/params.RefundQuotientEIP3529),
+			IntrinsicGas:   40000,
+			Refund:         refund,
+			RefundQuotient: params.RefundQuotientEIP3529,
+			Status:         "success",
+		}, nil
+	}
+
+	tests := []struct {
+		name          string
+		opts          executionOptions
+		wantSimulated uint64
+	}{
+		// 4,800 refund, below the 8,000 cap
+		{name: "EIP-3529", opts: executionOptions{}, wantSimulated: 35200},
+		// 15,000 refund, below the pre-London cap of 20,000
+		{name: "legacy refunds", opts: executionOptions{LegacyRefunds: true}, wantSimulated: 25000},
+		// REFUND_CAP_DIV takes precedence over the pre-London divisor
+		{
+			name: "legacy refunds with refund cap override",
+			opts: executionOptions{
+				LegacyRefunds: true,
+				GasSchedule:   &CustomGasSchedule{Overrides: map[string]uint64{vm.GasKeyRefundCapDiv: 5}},
+			},
+			wantSimulated: 32000,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := runDualExecution(tc.opts, SimulationTracerConfig{}, execute)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.Original.GasUsed != 35200 {
+				t.Errorf("original GasUsed = %d, want 35200", result.Original.GasUsed)
+			}
+
+			if result.Simulated.GasUsed != tc.wantSimulated {
+				t.Errorf("simulated GasUsed = %d, want %d", result.Simulated.GasUsed, tc.wantSimulated)
+			}
+		})
+	}
+}
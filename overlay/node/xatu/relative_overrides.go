@@ -0,0 +1,193 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+// RelativeSpec sets a gas key relative to another key's final value, as
+// round(base * Multiplier) + Offset (e.g. SSTORE_RESET = SLOAD_COLD + 100).
+type RelativeSpec struct {
+	Base       string  `json:"base"`
+	Multiplier float64 `json:"multiplier,omitempty"` // 0 means 1
+	Offset     int64   `json:"offset,omitempty"`
+}
+
+// apply computes the spec's value from the base key's value.
+func (r RelativeSpec) apply(base uint64) (uint64, error) {
+	multiplier := r.Multiplier
+	if multiplier == 0 {
+		multiplier = 1
+	}
+
+	value := math.Round(float64(base)*multiplier) + float64(r.Offset)
+	// float64(math.MaxUint64) rounds up to 2^64, which does not fit in a uint64
+	if value < 0 || value >= math.MaxUint64 {
+		return 0, fmt.Errorf("%d * %g + %d is out of range", base, multiplier, r.Offset)
+	}
+
+	return uint64(value), nil
+}
+
+// validateRelativeOverrides checks a schedule's relative overrides without fork
+// defaults: every spec needs a base and a positive multiplier, a key cannot also
+// have a concrete override, and specs must not form a cycle.
+func (c *CustomGasSchedule) validateRelativeOverrides() error {
+	if c == nil {
+		return nil
+	}
+
+	for key, spec := range c.RelativeOverrides {
+		if spec.Base == "" {
+			return fmt.Errorf("relative override %s has no base key", key)
+		}
+
+		if spec.Multiplier < 0 {
+			return fmt.Errorf("relative override %s has a negative multiplier", key)
+		}
+
+		if _, ok := c.Overrides[key]; ok {
+			return fmt.Errorf("%s has both an override and a relative override", key)
+		}
+	}
+
+	_, err := c.relativeOrder()
+
+	return err
+}
+
+// relativeOrder returns the relative override keys in dependency order, so that
+// each key comes after the relative key it is based on. Keys are visited in sorted
+// order for deterministic errors.
+func (c *CustomGasSchedule) relativeOrder() ([]string, error) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	state := make(map[string]int, len(c.RelativeOverrides))
+	order := make([]string, 0, len(c.RelativeOverrides))
+
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		spec, ok := c.RelativeOverrides[key]
+		if !ok {
+			return nil // a concrete key
+		}
+
+		switch state[key] {
+		case visiting:
+			return fmt.Errorf("relative overrides form a cycle: %s", strings.Join(append(path, key), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[key] = visiting
+		if err := visit(spec.Base, append(path, key)); err != nil {
+			return err
+		}
+		state[key] = visited
+
+		order = append(order, key)
+
+		return nil
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(c.RelativeOverrides)) {
+		if err := visit(key, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// resolveRelative returns a schedule with the relative overrides replaced by
+// concrete overrides. A base key takes its overridden value if set, otherwise its
+// value in defaults (the fork's schedule).
+func (c *CustomGasSchedule) resolveRelative(defaults map[string]uint64) (*CustomGasSchedule, error) {
+	if c == nil || len(c.RelativeOverrides) == 0 {
+		return c, nil
+	}
+
+	order, err := c.relativeOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &CustomGasSchedule{Overrides: make(map[string]uint64, len(c.Overrides)+len(order))}
+	maps.Copy(resolved.Overrides, c.Overrides)
+
+	for _, key := range order {
+		spec := c.RelativeOverrides[key]
+
+		base, ok := resolved.Overrides[spec.Base]
+		if !ok {
+			base, ok = defaults[spec.Base]
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("relative override %s: base key %s has no value at this fork", key, spec.Base)
+		}
+
+		value, err := spec.apply(base)
+		if err != nil {
+			return nil, fmt.Errorf("relative override %s: %w", key, err)
+		}
+
+		resolved.Overrides[key] = value
+	}
+
+	return resolved, nil
+}
+
+// resolveFor returns the schedule with its relative overrides resolved against the
+// defaults of the fork given by rules. It is the single place relative overrides
+// become concrete: executeMessage resolves its schedule with it before building
+// the custom JumpTable and evm.GasSchedule, which only read concrete overrides.
+func (c *CustomGasSchedule) resolveFor(rules *chain.Rules) (*CustomGasSchedule, error) {
+	if c == nil || len(c.RelativeOverrides) == 0 {
+		return c, nil
+	}
+
+	return c.resolveRelative(GasScheduleForRules(rules).Overrides)
+}
+
+// resolveRelativeOverrides replaces the relative overrides of opts.GasSchedule
+// with concrete values, using the defaults of the block's fork for base keys that
+// are not overridden. Handlers resolve up front so that intrinsic gas and the
+// tracers see the same concrete schedule as the execution.
+func (s *Service) resolveRelativeOverrides(ctx context.Context, opts *executionOptions, blockNum, blockTime uint64) error {
+	resolved, err := opts.GasSchedule.resolveFor(s.chainConfigFor(ctx, *opts).Rules(blockNum, blockTime))
+	if err != nil {
+		return err
+	}
+
+	opts.GasSchedule = resolved
+
+	return nil
+}
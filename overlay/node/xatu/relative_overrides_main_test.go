@@ -0,0 +1,46 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestRelativeOverridesExecution runs an unresolved relative override through
+// executeMessage, which must price the cold SLOAD with the resolved value.
+func TestRelativeOverridesExecution(t *testing.T) {
+	// PUSH1 0, SLOAD, POP, STOP: one cold SLOAD
+	code := []byte{0x60, 0x00, 0x54, 0x50, 0x00}
+
+	c := newTestChain(t, map[common.Address][]byte{testContract: code})
+
+	base := c.call(testContract, nil, executionOptions{})
+
+	// SLOAD_COLD = SLOAD_WARM + 1000 = 1100, 1000 less than the Cancun default
+	relative := c.call(testContract, nil, executionOptions{GasSchedule: &CustomGasSchedule{
+		RelativeOverrides: map[string]RelativeSpec{vm.GasKeySloadCold: {Base: vm.GasKeySloadWarm, Offset: 1000}},
+	}})
+
+	if base.GasUsed-relative.GasUsed != 1000 {
+		t.Errorf("gas used = %d with the relative override, want %d", relative.GasUsed, base.GasUsed-1000)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestResolveRelativeOverrides resolves a chain of relative overrides whose root
+// takes its value from the fork defaults.
+func TestResolveRelativeOverrides(t *testing.T) {
+	defaults := map[string]uint64{vm.GasKeySloadCold: 2100, vm.GasKeySloadWarm: 100}
+
+	schedule := &CustomGasSchedule{
+		Overrides: map[string]uint64{vm.GasKeySloadWarm: 200},
+		RelativeOverrides: map[string]RelativeSpec{
+			// Declared out of order: SSTORE_SET depends on SSTORE_RESET
+			vm.GasKeySstoreSet:   {Base: vm.GasKeySstoreReset, Multiplier: 4},
+			vm.GasKeySstoreReset: {Base: vm.GasKeySloadCold, Offset: 100},
+			vm.GasKeySstoreNoop:  {Base: vm.GasKeySloadWarm, Multiplier: 0.5, Offset: -50},
+		},
+	}
+
+	if err := schedule.validateRelativeOverrides(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	resolved, err := schedule.resolveRelative(defaults)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	want := map[string]uint64{
+		vm.GasKeySloadWarm:   200,
+		vm.GasKeySstoreReset: 2200,
+		vm.GasKeySstoreSet:   8800,
+		vm.GasKeySstoreNoop:  50,
+	}
+
+	if len(resolved.Overrides) != len(want) || len(resolved.RelativeOverrides) != 0 {
+		t.Fatalf("resolved = %+v, want overrides %v", resolved, want)
+	}
+
+	for key, value := range want {
+		if got := resolved.Overrides[key]; got != value {
+			t.Errorf("%s = %d, want %d", key, got, value)
+		}
+	}
+
+	// The original schedule is left unresolved
+	if len(schedule.Overrides) != 1 {
+		t.Errorf("original overrides modified: %v", schedule.Overrides)
+	}
+}
+
+// TestRelativeOverridesRejected checks the specs that cannot be resolved.
+func TestRelativeOverridesRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule *CustomGasSchedule
+		wantErr  string
+	}{
+		{
+			name: "cycle",
+			schedule: &CustomGasSchedule{RelativeOverrides: map[string]RelativeSpec{
+				vm.GasKeySstoreSet:   {Base: vm.GasKeySstoreReset},
+				vm.GasKeySstoreReset: {Base: vm.GasKeySloadCold},
+				vm.GasKeySloadCold:   {Base: vm.GasKeySstoreSet, Offset: 1},
+			}},
+			wantErr: "cycle",
+		},
+		{
+			name: "self reference",
+			schedule: &CustomGasSchedule{RelativeOverrides: map[string]RelativeSpec{
+				vm.GasKeySloadCold: {Base: vm.GasKeySloadCold, Multiplier: 2},
+			}},
+			wantErr: "cycle",
+		},
+		{
+			name: "also concrete",
+			schedule: &CustomGasSchedule{
+				Overrides:         map[string]uint64{vm.GasKeySloadCold: 1},
+				RelativeOverrides: map[string]RelativeSpec{vm.GasKeySloadCold: {Base: vm.GasKeySloadWarm}},
+			},
+			wantErr: "both",
+		},
+		{
+			name: "missing base",
+			schedule: &CustomGasSchedule{RelativeOverrides: map[string]RelativeSpec{
+				vm.GasKeySloadCold: {Offset: 1},
+			}},
+			wantErr: "no base",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.validateRelativeOverrides()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Negative results and unknown base keys only fail once resolved
+	negative := &CustomGasSchedule{RelativeOverrides: map[string]RelativeSpec{
+		vm.GasKeySloadWarm: {Base: vm.GasKeySloadCold, Offset: -3000},
+	}}
+	if _, err := negative.resolveRelative(map[string]uint64{vm.GasKeySloadCold: 2100}); err == nil {
+		t.Error("expected an error for a negative value")
+	}
+
+	unknown := &CustomGasSchedule{RelativeOverrides: map[string]RelativeSpec{
+		vm.GasKeySloadWarm: {Base: "NOT_A_KEY"},
+	}}
+	if _, err := unknown.resolveRelative(map[string]uint64{}); err == nil {
+		t.Error("expected an error for an unknown base key")
+	}
+}
+
+// TestRelativeSpecApplyRange checks the bounds of a resolved value, including a
+// result that float64 rounds up to 2^64.
+func TestRelativeSpecApplyRange(t *testing.T) {
+	if got, err := (RelativeSpec{Multiplier: 2, Offset: 1}).apply(100); err != nil || got != 201 {
+		t.Errorf("apply(100) = %d, %v, want 201", got, err)
+	}
+
+	if _, err := (RelativeSpec{}).apply(math.MaxUint64); err == nil {
+		t.Error("expected an error for a value of 2^64")
+	}
+
+	if _, err := (RelativeSpec{Multiplier: 2}).apply(math.MaxUint64 / 2); err == nil {
+		t.Error("expected an error for a value rounding up to 2^64")
+	}
+}
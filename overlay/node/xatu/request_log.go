@@ -0,0 +1,113 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/ethpandaops/execution-processor/pkg/ethereum/execution"
+)
+
+// Simulation requests are logged in two levels: a single line per completed
+// request at info, so a shared node keeps an audit trail of what was simulated,
+// and the request's override keys and failures at debug.
+
+// requestOverrideKeys returns the sorted keys a request's gas schedule overrides,
+// including relative overrides. An invalid compact schedule yields no keys; the
+// request itself reports the error.
+func requestOverrideKeys(schedule *CustomGasSchedule, compact string) []string {
+	schedule, err := resolveGasSchedule(schedule, compact)
+	if err != nil || schedule == nil {
+		return nil
+	}
+
+	keys := slices.Collect(maps.Keys(schedule.Overrides))
+	for key := range schedule.RelativeOverrides {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return slices.Compact(keys)
+}
+
+// logBlockSimulation logs the outcome of a xatu_simulateBlockGas request.
+func (s *Service) logBlockSimulation(req SimulateBlockGasRequest, result *SimulateBlockGasResult, elapsed time.Duration, err error) {
+	if err != nil {
+		s.log.Debug("Block gas simulation failed", "block", req.BlockNumber, "elapsed", elapsed, "err", err)
+		return
+	}
+
+	// Transactions may have been dropped to fit the response size limit
+	var diverged int
+	for _, tx := range result.Transactions {
+		if tx.Diverged {
+			diverged++
+		}
+	}
+
+	s.log.Info("Simulated block gas",
+		"block", req.BlockNumber,
+		"txs", result.Original.TxCount,
+		"overrides", len(requestOverrideKeys(req.GasSchedule, req.CompactSchedule)),
+		"presets", len(req.Presets),
+		"originalGas", result.Original.GasUsed,
+		"simulatedGas", result.Simulated.GasUsed,
+		"deltaPercent", deltaPercent(result.Original.GasUsed, result.Simulated.GasUsed),
+		"diverged", diverged,
+		"elapsed", elapsed)
+}
+
+// logTransactionSimulation logs the outcome of a xatu_simulateTransactionGas request.
+func (s *Service) logTransactionSimulation(req SimulateTransactionGasRequest, result *SimulateTransactionGasResult, dual *dualExecutionResult, elapsed time.Duration, err error) {
+	if err != nil {
+		s.log.Debug("Transaction gas simulation failed", "tx", req.TransactionHash, "elapsed", elapsed, "err", err)
+		return
+	}
+
+	s.log.Info("Simulated transaction gas",
+		"tx", req.TransactionHash,
+		"block", result.BlockNumber,
+		"overrides", len(requestOverrideKeys(req.GasSchedule, req.CompactSchedule)),
+		"presets", len(req.Presets),
+		"status", result.Status,
+		"originalGas", result.Original.GasUsed,
+		"simulatedGas", result.Simulated.GasUsed,
+		"deltaPercent", deltaPercent(result.Original.GasUsed, result.Simulated.GasUsed),
+		"diverged", dual.diverged(),
+		"elapsed", elapsed)
+}
+
+// logTraceTransaction logs the outcome of a DebugTraceTransaction call. The
+// execution-processor traces every processed transaction, so this is debug only.
+func (s *Service) logTraceTransaction(hash string, trace *execution.TraceTransaction, elapsed time.Duration, err error) {
+	if err != nil {
+		s.log.Debug("Transaction trace failed", "tx", hash, "elapsed", elapsed, "err", err)
+		return
+	}
+
+	s.log.Debug("Traced transaction",
+		"tx", hash,
+		"gas", trace.Gas,
+		"failed", trace.Failed,
+		"structLogs", len(trace.Structlogs),
+		"elapsed", elapsed)
+}
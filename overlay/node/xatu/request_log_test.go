@@ -0,0 +1,49 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRequestOverrideKeys(t *testing.T) {
+	schedule := &CustomGasSchedule{
+		Overrides:         map[string]uint64{"SSTORE_SET": 5000, "ADD": 1},
+		RelativeOverrides: map[string]RelativeSpec{"SSTORE_RESET": {Base: "SLOAD_COLD", Offset: 100}},
+	}
+
+	if got, want := requestOverrideKeys(schedule, ""), []string{"ADD", "SSTORE_RESET", "SSTORE_SET"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	compact := mustEncodeCompact(t, &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 2000}})
+	if got, want := requestOverrideKeys(nil, compact), []string{"SLOAD_COLD"}; !slices.Equal(got, want) {
+		t.Errorf("compact: got %v, want %v", got, want)
+	}
+
+	// Invalid requests are logged without keys; the request reports the error
+	if got := requestOverrideKeys(schedule, compact); got != nil {
+		t.Errorf("conflicting schedules: got %v, want nil", got)
+	}
+
+	if got := requestOverrideKeys(nil, ""); got != nil {
+		t.Errorf("no schedule: got %v, want nil", got)
+	}
+}
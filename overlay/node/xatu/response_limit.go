@@ -0,0 +1,167 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Estimated JSON sizes, in bytes, of the fixed parts of result entries (field names,
+// punctuation and typical numeric values). Variable-length strings are added on top.
+const (
+	resultBaseSize         = 512
+	txSummaryBaseSize      = 260
+	callErrorBaseSize      = 48
+	opcodeSummaryBaseSize  = 250
+	eipGasBaseSize         = 48
+	opcodeDeltaBaseSize    = 100
+	presetKeysBaseSize     = 6
+	presetKeyBaseSize      = 3
+	chainRuleBaseSize      = 10
+	blockGasAccountingSize = 250
+	gasPercentilesSize     = 180
+	gasLimitStopSize       = 80
+	truncationReasonSize   = 128
+)
+
+// estimateTxSummarySize estimates the encoded size of a transaction summary.
+func estimateTxSummarySize(tx *TxSummary) uint64 {
+	size := uint64(txSummaryBaseSize + len(tx.Hash) + len(tx.OriginalStatus) + len(tx.SimulatedStatus) +
+		len(tx.OriginalExecError) + len(tx.SimulatedExecError) + len(tx.Error) + len(tx.PanicMessage) +
+		len(tx.FeeDelta))
+
+	for _, errs := range [][]CallError{tx.OriginalErrors, tx.SimulatedErrors} {
+		for i := range errs {
+			size += uint64(callErrorBaseSize + len(errs[i].Type) + len(errs[i].Error) + len(errs[i].Address))
+		}
+	}
+
+	return size
+}
+
+// estimateSize estimates the encoded size of the result without serializing it.
+func (r *SimulateBlockGasResult) estimateSize() uint64 {
+	size := uint64(resultBaseSize + blockGasAccountingSize + gasPercentilesSize +
+		len(r.OpcodeBreakdownCSV) + len(r.BaseFee) + len(r.FeeDelta) + len(r.ChainConfigSource) +
+		len(r.TruncationReason))
+
+	if r.GasLimitStop != nil {
+		size += gasLimitStopSize
+	}
+
+	for i := range r.Transactions {
+		size += estimateTxSummarySize(&r.Transactions[i])
+	}
+
+	for i := range r.TopDeltas {
+		size += estimateTxSummarySize(&r.TopDeltas[i])
+	}
+
+	for name := range r.OpcodeBreakdown {
+		size += uint64(opcodeSummaryBaseSize + len(name))
+	}
+
+	for eip := range r.EIPBreakdown {
+		size += uint64(eipGasBaseSize + len(eip))
+	}
+
+	for preset, keys := range r.PresetKeys {
+		size += uint64(presetKeysBaseSize + len(preset))
+
+		for _, key := range keys {
+			size += uint64(presetKeyBaseSize + len(key))
+		}
+	}
+
+	for i := range r.TopMovers {
+		size += uint64(opcodeDeltaBaseSize + len(r.TopMovers[i].Opcode))
+	}
+
+	for name := range r.ChainRules {
+		size += uint64(chainRuleBaseSize + len(name))
+	}
+
+	return size
+}
+
+// truncate drops the heaviest fields of the result until its estimated size fits
+// within maxBytes: trailing per-transaction summaries first, then the opcode breakdown
+// entries with the least gas. Block totals and TopDeltas are kept. A reason already
+// set (e.g. by stopAtGasLimit) is kept, with this one appended. A maxBytes of 0
+// disables the limit.
+func (r *SimulateBlockGasResult) truncate(maxBytes uint64) {
+	if maxBytes == 0 {
+		return
+	}
+
+	size := r.estimateSize()
+	if size <= maxBytes {
+		return
+	}
+
+	// Leave room for the reason added below
+	size += truncationReasonSize
+
+	totalTxs, totalOpcodes := len(r.Transactions), len(r.OpcodeBreakdown)
+
+	for len(r.Transactions) > 0 && size > maxBytes {
+		size -= estimateTxSummarySize(&r.Transactions[len(r.Transactions)-1])
+		r.Transactions = r.Transactions[:len(r.Transactions)-1]
+	}
+
+	if size > maxBytes {
+		// Keep the opcodes that account for the most gas
+		names := make([]string, 0, len(r.OpcodeBreakdown))
+		for name := range r.OpcodeBreakdown {
+			names = append(names, name)
+		}
+
+		sort.Slice(names, func(i, j int) bool {
+			gi := r.OpcodeBreakdown[names[i]].OriginalGas + r.OpcodeBreakdown[names[i]].SimulatedGas
+			gj := r.OpcodeBreakdown[names[j]].OriginalGas + r.OpcodeBreakdown[names[j]].SimulatedGas
+			if gi != gj {
+				return gi < gj
+			}
+
+			return names[i] > names[j]
+		})
+
+		for _, name := range names {
+			if size <= maxBytes {
+				break
+			}
+
+			size -= uint64(opcodeSummaryBaseSize + len(name))
+			delete(r.OpcodeBreakdown, name)
+		}
+	}
+
+	reason := fmt.Sprintf(
+		"response exceeded %d bytes: kept %d of %d transactions and %d of %d opcode breakdown entries",
+		maxBytes, len(r.Transactions), totalTxs, len(r.OpcodeBreakdown), totalOpcodes,
+	)
+
+	if r.TruncationReason != "" {
+		reason = r.TruncationReason + "; " + reason
+	}
+
+	r.Truncated = true
+	r.TruncationReason = reason
+}
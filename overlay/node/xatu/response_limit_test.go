@@ -0,0 +1,192 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// newLargeBlockResult builds a synthetic block result with txCount transactions,
+// each with a nested call error, and opcodes breakdown entries.
+func newLargeBlockResult(txCount, opcodes int) *SimulateBlockGasResult {
+	result := &SimulateBlockGasResult{
+		BlockNumber:     1,
+		Original:        BlockGasSummary{GasUsed: uint64(txCount) * 21000},
+		Simulated:       BlockGasSummary{GasUsed: uint64(txCount) * 25000},
+		Transactions:    make([]TxSummary, 0, txCount),
+		OpcodeBreakdown: make(map[string]OpcodeSummary, opcodes),
+	}
+
+	for i := 0; i < txCount; i++ {
+		result.Transactions = append(result.Transactions, TxSummary{
+			Hash:            fmt.Sprintf("0x%064x", i),
+			Index:           uint64(i),
+			OriginalStatus:  "success",
+			SimulatedStatus: "failed",
+			OriginalGas:     21000,
+			SimulatedGas:    25000,
+			DeltaPercent:    19.047619047619047,
+			OriginalErrors:  []CallError{},
+			SimulatedErrors: []CallError{{Depth: 1, Type: "CALL", Error: "out of gas", Address: "0x1234567890"}},
+		})
+	}
+
+	for i := 0; i < opcodes; i++ {
+		result.OpcodeBreakdown[fmt.Sprintf("OP_%d", i)] = OpcodeSummary{
+			OriginalCount: uint64(i), OriginalGas: uint64(i) * 1000,
+			SimulatedCount: uint64(i), SimulatedGas: uint64(i) * 1200,
+		}
+	}
+
+	return result
+}
+
+func TestSimulateBlockGasResultTruncate(t *testing.T) {
+	t.Run("estimate tracks encoded size", func(t *testing.T) {
+		result := newLargeBlockResult(1000, 100)
+
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The estimate is used to truncate before serializing; it should be within
+		// a factor of two of the real size.
+		estimate := result.estimateSize()
+		if estimate < uint64(len(encoded))/2 || estimate > uint64(len(encoded))*2 {
+			t.Errorf("estimate %d is far from encoded size %d", estimate, len(encoded))
+		}
+	})
+
+	t.Run("estimate counts every variable-size field", func(t *testing.T) {
+		grow := map[string]func(r *SimulateBlockGasResult){
+			"opcode breakdown CSV": func(r *SimulateBlockGasResult) { r.OpcodeBreakdownCSV += "SSTORE,1,2,3,4,2\n" },
+			"EIP breakdown":        func(r *SimulateBlockGasResult) { r.EIPBreakdown["EIP-7702"] = EIPGas{} },
+			"preset keys":          func(r *SimulateBlockGasResult) { r.PresetKeys["inline"] = append(r.PresetKeys["inline"], "CALL_COLD") },
+			"top movers":           func(r *SimulateBlockGasResult) { r.TopMovers = append(r.TopMovers, OpcodeDelta{Opcode: "CALL"}) },
+			"chain rules":          func(r *SimulateBlockGasResult) { r.ChainRules["IsOsaka"] = true },
+			"fee delta":            func(r *SimulateBlockGasResult) { r.FeeDelta = "0x1234567890" },
+			"tx exec error":        func(r *SimulateBlockGasResult) { r.Transactions[0].SimulatedExecError += ": at pc 7" },
+			"tx fee delta":         func(r *SimulateBlockGasResult) { r.Transactions[0].FeeDelta = "0x1234567890" },
+			"gas limit stop":       func(r *SimulateBlockGasResult) { r.GasLimitStop = &GasLimitStop{} },
+		}
+
+		for name, fn := range grow {
+			result := fullBlockGasResult()
+			result.GasLimitStop = nil
+			before := result.estimateSize()
+
+			fn(result)
+
+			if after := result.estimateSize(); after <= before {
+				t.Errorf("%s: estimate %d did not grow from %d", name, after, before)
+			}
+		}
+	})
+
+	t.Run("transactions are truncated first", func(t *testing.T) {
+		result := newLargeBlockResult(1000, 100)
+		limit := result.estimateSize() / 2
+
+		result.truncate(limit)
+
+		if !result.Truncated || result.TruncationReason == "" {
+			t.Fatal("expected result to be marked truncated")
+		}
+
+		if len(result.Transactions) == 0 || len(result.Transactions) >= 1000 {
+			t.Errorf("kept %d transactions, want a non-empty prefix", len(result.Transactions))
+		}
+
+		if len(result.OpcodeBreakdown) != 100 {
+			t.Errorf("opcode breakdown truncated to %d entries before transactions were exhausted", len(result.OpcodeBreakdown))
+		}
+
+		// Remaining transactions are a prefix, and totals are untouched
+		for i, tx := range result.Transactions {
+			if tx.Index != uint64(i) {
+				t.Fatalf("transaction %d has index %d", i, tx.Index)
+			}
+		}
+
+		if result.Original.GasUsed != 1000*21000 {
+			t.Errorf("Original.GasUsed = %d, want totals to be preserved", result.Original.GasUsed)
+		}
+
+		if result.estimateSize() > limit {
+			t.Errorf("estimated size %d still exceeds limit %d", result.estimateSize(), limit)
+		}
+	})
+
+	t.Run("opcode breakdown keeps the heaviest entries", func(t *testing.T) {
+		result := newLargeBlockResult(10, 100)
+		limit := uint64(resultBaseSize + blockGasAccountingSize + gasPercentilesSize + truncationReasonSize +
+			20*(opcodeSummaryBaseSize+6))
+
+		result.truncate(limit)
+
+		if len(result.Transactions) != 0 {
+			t.Errorf("kept %d transactions, want 0", len(result.Transactions))
+		}
+
+		if len(result.OpcodeBreakdown) == 0 || len(result.OpcodeBreakdown) >= 100 {
+			t.Fatalf("kept %d opcode entries", len(result.OpcodeBreakdown))
+		}
+
+		if _, ok := result.OpcodeBreakdown["OP_99"]; !ok {
+			t.Error("expected the heaviest opcode to be kept")
+		}
+
+		if _, ok := result.OpcodeBreakdown["OP_0"]; ok {
+			t.Error("expected the lightest opcode to be dropped")
+		}
+	})
+
+	t.Run("existing reason is kept", func(t *testing.T) {
+		result := newLargeBlockResult(1000, 100)
+		if !result.stopAtGasLimit(999, 5) {
+			t.Fatal("expected the simulation to stop at the gas limit")
+		}
+
+		stopReason := result.TruncationReason
+		result.truncate(result.estimateSize() / 2)
+
+		if !strings.HasPrefix(result.TruncationReason, stopReason+"; ") || !strings.Contains(result.TruncationReason, "response exceeded") {
+			t.Errorf("reason = %q, want the gas limit stop followed by the size limit", result.TruncationReason)
+		}
+
+		if result.GasLimitStop == nil {
+			t.Error("expected the gas limit stop to be kept")
+		}
+	})
+
+	t.Run("within limit or disabled", func(t *testing.T) {
+		for _, limit := range []uint64{0, 1 << 40} {
+			result := newLargeBlockResult(100, 10)
+			result.truncate(limit)
+
+			if result.Truncated || len(result.Transactions) != 100 {
+				t.Errorf("limit %d: unexpected truncation", limit)
+			}
+		}
+	})
+}
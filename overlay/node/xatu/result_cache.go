@@ -0,0 +1,160 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/diagnostics/metrics"
+)
+
+// defaultResultCacheTTL is how long a cached block result is served when no TTL is configured.
+const defaultResultCacheTTL = 5 * time.Minute
+
+var (
+	resultCacheHits   = metrics.GetOrCreateCounter(`xatu_result_cache_total{result="hit"}`)
+	resultCacheMisses = metrics.GetOrCreateCounter(`xatu_result_cache_total{result="miss"}`)
+)
+
+// resultCacheKey identifies a block simulation: the block number plus a hash of
+// the request's options (schedule overrides, flags, precompile changes, etc).
+type resultCacheKey struct {
+	blockNumber uint64
+	request     [sha256.Size]byte
+}
+
+// newResultCacheKey hashes a block simulation request. The request is hashed via
+// its JSON encoding, which writes map keys in sorted order, so schedules with the
+// same overrides map to the same key whatever order they were built in.
+func newResultCacheKey(req SimulateBlockGasRequest) (resultCacheKey, error) {
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return resultCacheKey{}, err
+	}
+
+	return resultCacheKey{blockNumber: req.BlockNumber, request: sha256.Sum256(encoded)}, nil
+}
+
+// resultCacheEntry is a cached result together with the hash of the block it was
+// computed for, so a reorg at that height invalidates it.
+type resultCacheEntry struct {
+	key       resultCacheKey
+	blockHash common.Hash
+	result    *SimulateBlockGasResult
+	expires   time.Time
+}
+
+// resultCache is an LRU cache of block simulation results for interactive use,
+// where the same block is re-run with the same schedule. Cached results are shared
+// between callers and must not be modified. A nil cache is disabled: lookups miss
+// and stores are dropped.
+type resultCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[resultCacheKey]*list.Element
+	lru     *list.List // front is most recently used
+	now     func() time.Time
+}
+
+// newResultCache creates a cache holding up to size results for ttl each (0 uses
+// defaultResultCacheTTL). Returns nil, a disabled cache, when size is 0 or less.
+func newResultCache(size int, ttl time.Duration) *resultCache {
+	if size <= 0 {
+		return nil
+	}
+
+	if ttl <= 0 {
+		ttl = defaultResultCacheTTL
+	}
+
+	return &resultCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[resultCacheKey]*list.Element, size),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the cached result for key if it was computed for blockHash and has
+// not expired. Entries for a different block hash (reorged out) are dropped.
+func (c *resultCache) get(key resultCacheKey, blockHash common.Hash) (*SimulateBlockGasResult, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		resultCacheMisses.Inc()
+		return nil, false
+	}
+
+	entry := elem.Value.(*resultCacheEntry)
+	if entry.blockHash != blockHash || c.now().After(entry.expires) {
+		c.remove(elem)
+		resultCacheMisses.Inc()
+
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	resultCacheHits.Inc()
+
+	return entry.result, true
+}
+
+// put stores a result, evicting the least recently used entry when full.
+func (c *resultCache) put(key resultCacheKey, blockHash common.Hash, result *SimulateBlockGasResult) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &resultCacheEntry{key: key, blockHash: blockHash, result: result, expires: c.now().Add(c.ttl)}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+
+	if c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove deletes an entry. Callers hold c.mu.
+func (c *resultCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*resultCacheEntry).key)
+}
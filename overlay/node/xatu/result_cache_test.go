@@ -0,0 +1,132 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erigontech/erigon/common"
+)
+
+// TestResultCacheEquivalentSchedules verifies that requests whose schedules hold the
+// same overrides, built in a different order, share a cache entry, while a
+// different override or block number does not.
+func TestResultCacheEquivalentSchedules(t *testing.T) {
+	first := SimulateBlockGasRequest{BlockNumber: 100, GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{}}}
+	for _, k := range []string{"SLOAD_COLD", "CALL_COLD", "ADD", "SSTORE_SET"} {
+		first.GasSchedule.Overrides[k] = uint64(len(k))
+	}
+
+	second := SimulateBlockGasRequest{BlockNumber: 100, GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{}}}
+	for _, k := range []string{"SSTORE_SET", "ADD", "CALL_COLD", "SLOAD_COLD"} {
+		second.GasSchedule.Overrides[k] = uint64(len(k))
+	}
+
+	firstKey, err := newResultCacheKey(first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	secondKey, err := newResultCacheKey(second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cache := newResultCache(4, time.Minute)
+	hash := common.HexToHash("0x01")
+	want := &SimulateBlockGasResult{BlockNumber: 100}
+
+	cache.put(firstKey, hash, want)
+
+	if got, ok := cache.get(secondKey, hash); !ok || got != want {
+		t.Fatal("equivalent schedule missed the cache")
+	}
+
+	second.GasSchedule.Overrides["ADD"] = 99
+	if changedKey, _ := newResultCacheKey(second); changedKey == firstKey {
+		t.Error("different override produced the same cache key")
+	}
+
+	first.BlockNumber = 101
+	if otherBlockKey, _ := newResultCacheKey(first); otherBlockKey == firstKey {
+		t.Error("different block produced the same cache key")
+	}
+}
+
+// TestResultCacheInvalidation verifies reorg, TTL and size-based eviction.
+func TestResultCacheInvalidation(t *testing.T) {
+	key := func(block uint64) resultCacheKey {
+		k, err := newResultCacheKey(SimulateBlockGasRequest{BlockNumber: block})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		return k
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	cache := newResultCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	hashA, hashB := common.HexToHash("0x0a"), common.HexToHash("0x0b")
+
+	// Reorg: the block at this height now has a different hash
+	cache.put(key(1), hashA, &SimulateBlockGasResult{})
+
+	if _, ok := cache.get(key(1), hashB); ok {
+		t.Error("result served for a reorged block")
+	}
+
+	if _, ok := cache.get(key(1), hashA); ok {
+		t.Error("reorged entry was not dropped")
+	}
+
+	// TTL
+	cache.put(key(2), hashA, &SimulateBlockGasResult{})
+	now = now.Add(2 * time.Minute)
+
+	if _, ok := cache.get(key(2), hashA); ok {
+		t.Error("expired result served")
+	}
+
+	// LRU: touching key 3 makes key 4 the eviction candidate
+	cache.put(key(3), hashA, &SimulateBlockGasResult{})
+	cache.put(key(4), hashA, &SimulateBlockGasResult{})
+	cache.get(key(3), hashA)
+	cache.put(key(5), hashA, &SimulateBlockGasResult{})
+
+	if _, ok := cache.get(key(4), hashA); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+
+	for _, block := range []uint64{3, 5} {
+		if _, ok := cache.get(key(block), hashA); !ok {
+			t.Errorf("entry for block %d was evicted", block)
+		}
+	}
+
+	// A zero size disables the cache
+	disabled := newResultCache(0, 0)
+	disabled.put(key(1), hashA, &SimulateBlockGasResult{})
+
+	if _, ok := disabled.get(key(1), hashA); ok {
+		t.Error("disabled cache returned a result")
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	r "github.com/redis/go-redis/v9"
+)
+
+const (
+	// maxSavedScheduleName bounds the length of a saved schedule's name.
+	maxSavedScheduleName = 128
+
+	// maxSavedSchedules caps the schedules kept, in Redis or, when there is no
+	// Redis (simulation-only mode), in memory.
+	maxSavedSchedules = 1000
+)
+
+// SaveScheduleResult is the result of xatu_saveSchedule.
+type SaveScheduleResult struct {
+	Name string `json:"name"`
+	// Overwritten is set when a schedule was already saved under the name.
+	Overwritten bool `json:"overwritten"`
+}
+
+// scheduleStore persists named schedules as their JSON encoding.
+type scheduleStore interface {
+	// save stores a schedule, reporting whether it replaced one of the same name.
+	save(ctx context.Context, name string, data []byte) (bool, error)
+	// load returns the schedule saved under name, reporting whether there is one.
+	load(ctx context.Context, name string) ([]byte, bool, error)
+}
+
+// saveScheduleScript stores a schedule unless the store already holds size
+// schedules and the name is new, so the check and the write are atomic. KEYS are
+// the schedule's key and the set of saved names, ARGV the data, name and size. It
+// returns 1 when the schedule replaced one of the same name, 0 when it was added
+// and -1 when the store is full.
+var saveScheduleScript = r.NewScript(`
+local exists = redis.call('EXISTS', KEYS[1])
+if exists == 0 and redis.call('SCARD', KEYS[2]) >= tonumber(ARGV[3]) then
+	return -1
+end
+redis.call('SET', KEYS[1], ARGV[1])
+redis.call('SADD', KEYS[2], ARGV[2])
+return exists
+`)
+
+// redisScheduleStore keeps up to size schedules in Redis under the configured key
+// prefix, with the set of their names alongside to count them.
+type redisScheduleStore struct {
+	client *r.Client
+	prefix string
+	size   int
+}
+
+func (s redisScheduleStore) key(name string) string {
+	return s.prefix + ":schedule:" + name
+}
+
+func (s redisScheduleStore) namesKey() string {
+	return s.prefix + ":schedules"
+}
+
+func (s redisScheduleStore) save(ctx context.Context, name string, data []byte) (bool, error) {
+	saved, err := saveScheduleScript.Run(ctx, s.client, []string{s.key(name), s.namesKey()}, data, name, s.size).Int()
+	if err != nil {
+		return false, err
+	}
+
+	if saved < 0 {
+		return false, fmt.Errorf("saved schedule limit (%d) reached", s.size)
+	}
+
+	return saved > 0, nil
+}
+
+func (s redisScheduleStore) load(ctx context.Context, name string) ([]byte, bool, error) {
+	data, err := s.client.Get(ctx, s.key(name)).Bytes()
+	if errors.Is(err, r.Nil) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// memoryScheduleStore keeps up to size schedules in memory. They are lost when the
+// node restarts.
+type memoryScheduleStore struct {
+	mu        sync.Mutex
+	size      int
+	schedules map[string][]byte
+}
+
+func newMemoryScheduleStore(size int) *memoryScheduleStore {
+	return &memoryScheduleStore{size: size, schedules: make(map[string][]byte)}
+}
+
+func (s *memoryScheduleStore) save(_ context.Context, name string, data []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.schedules[name]
+	if !exists && len(s.schedules) >= s.size {
+		return false, fmt.Errorf("saved schedule limit (%d) reached", s.size)
+	}
+
+	s.schedules[name] = data
+
+	return exists, nil
+}
+
+func (s *memoryScheduleStore) load(_ context.Context, name string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.schedules[name]
+
+	return data, ok, nil
+}
+
+// scheduleStore returns Redis when the execution-processor pipeline created a
+// client, and the in-memory store otherwise.
+func (s *Service) scheduleStore() scheduleStore {
+	if s.redisClient != nil {
+		return redisScheduleStore{client: s.redisClient, prefix: s.redisPrefix, size: maxSavedSchedules}
+	}
+
+	return s.memorySchedules
+}
+
+// validateScheduleName checks that a saved schedule name is usable as a key.
+func validateScheduleName(name string) error {
+	if name == "" {
+		return errors.New("schedule name is required")
+	}
+
+	if len(name) > maxSavedScheduleName {
+		return fmt.Errorf("schedule name is longer than %d bytes", maxSavedScheduleName)
+	}
+
+	return nil
+}
+
+// SaveSchedule saves a gas schedule under a name for later use with GetSchedule. A
+// schedule already saved under the name is replaced, with a warning. Schedules are
+// kept in Redis, or in memory in simulation-only mode.
+func (s *Service) SaveSchedule(ctx context.Context, name string, schedule *CustomGasSchedule) (*SaveScheduleResult, error) {
+	if err := validateScheduleName(name); err != nil {
+		return nil, err
+	}
+
+	if !schedule.HasOverrides() {
+		return nil, errors.New("schedule has no overrides")
+	}
+
+	if err := schedule.validateRelativeOverrides(); err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schedule: %w", err)
+	}
+
+	overwritten, err := s.scheduleStore().save(ctx, name, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save schedule %q: %w", name, err)
+	}
+
+	if overwritten {
+		s.log.Warn("Overwrote saved gas schedule", "name", name)
+	}
+
+	return &SaveScheduleResult{Name: name, Overwritten: overwritten}, nil
+}
+
+// GetSchedule returns the gas schedule saved under a name by SaveSchedule.
+func (s *Service) GetSchedule(ctx context.Context, name string) (*CustomGasSchedule, error) {
+	if err := validateScheduleName(name); err != nil {
+		return nil, err
+	}
+
+	data, ok, err := s.scheduleStore().load(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schedule %q: %w", name, err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("no schedule saved as %q", name)
+	}
+
+	var schedule CustomGasSchedule
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		return nil, fmt.Errorf("failed to decode schedule %q: %w", name, err)
+	}
+
+	return &schedule, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	r "github.com/redis/go-redis/v9"
+
+	"github.com/erigontech/erigon/common/log/v3"
+	"github.com/erigontech/erigon/db/datadir"
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+// TestSaveSchedule saves a schedule in simulation-only mode, where the in-memory
+// store stands in for Redis, and verifies that it round-trips losslessly, that a
+// second save under the name overwrites it, and that invalid schedules are rejected.
+func TestSaveSchedule(t *testing.T) {
+	svc, err := newService(stubDB{}, nil, &chain.Config{ChainID: big.NewInt(1)}, nil, datadir.Dirs{},
+		Config{SimulationOnly: true}, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	schedule := &CustomGasSchedule{
+		Overrides: map[string]uint64{"SLOAD_COLD": 4200, "ADD": 0},
+		RelativeOverrides: map[string]RelativeSpec{
+			"SLOAD_WARM": {Base: "SLOAD_COLD", Multiplier: 0.05, Offset: -10},
+		},
+	}
+
+	res, err := svc.SaveSchedule(ctx, "double-sload", schedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Overwritten {
+		t.Error("first save reported an overwrite")
+	}
+
+	got, err := svc.GetSchedule(ctx, "double-sload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, schedule) {
+		t.Errorf("GetSchedule = %+v, want %+v", got, schedule)
+	}
+
+	replacement := &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 8400}}
+
+	res, err = svc.SaveSchedule(ctx, "double-sload", replacement)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Overwritten {
+		t.Error("second save did not report an overwrite")
+	}
+
+	if got, err := svc.GetSchedule(ctx, "double-sload"); err != nil || !reflect.DeepEqual(got, replacement) {
+		t.Errorf("GetSchedule after overwrite = %+v, %v, want %+v", got, err, replacement)
+	}
+
+	if _, err := svc.GetSchedule(ctx, "missing"); err == nil {
+		t.Error("expected an error for an unsaved name")
+	}
+
+	for name, invalid := range map[string]*CustomGasSchedule{
+		"nil":      nil,
+		"empty":    {},
+		"cycle":    {RelativeOverrides: map[string]RelativeSpec{"A": {Base: "B"}, "B": {Base: "A"}}},
+		"conflict": {Overrides: map[string]uint64{"A": 1}, RelativeOverrides: map[string]RelativeSpec{"A": {Base: "B"}}},
+	} {
+		if _, err := svc.SaveSchedule(ctx, name, invalid); err == nil {
+			t.Errorf("%s schedule: expected an error", name)
+		}
+	}
+
+	if _, err := svc.SaveSchedule(ctx, "", schedule); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+}
+
+// TestMemoryScheduleStoreLimit verifies that a full in-memory store rejects new
+// names but still accepts overwrites.
+func TestMemoryScheduleStoreLimit(t *testing.T) {
+	store := newMemoryScheduleStore(1)
+	ctx := context.Background()
+
+	if _, err := store.save(ctx, "a", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.save(ctx, "b", []byte("{}")); err == nil {
+		t.Error("expected an error when the store is full")
+	}
+
+	if overwritten, err := store.save(ctx, "a", []byte(`{"overrides":{"ADD":1}}`)); err != nil || !overwritten {
+		t.Errorf("overwrite = %v, %v, want true, nil", overwritten, err)
+	}
+}
+
+// TestRedisScheduleStoreLimit verifies that the Redis store is capped at the same
+// number of schedules as the in-memory one.
+func TestRedisScheduleStoreLimit(t *testing.T) {
+	client := r.NewClient(&r.Options{})
+	defer client.Close()
+
+	s := &Service{redisClient: client, redisPrefix: "xatu"}
+
+	store, ok := s.scheduleStore().(redisScheduleStore)
+	if !ok {
+		t.Fatalf("schedule store = %T, want redisScheduleStore", s.scheduleStore())
+	}
+
+	if store.size != maxSavedSchedules {
+		t.Errorf("Redis store size = %d, want %d", store.size, maxSavedSchedules)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/tracing"
+	"github.com/erigontech/erigon/execution/types/accounts"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// keyScenario is a contract whose measured call pays for a dynamic gas key.
+type keyScenario struct {
+	code  []byte
+	setup [][]byte // calldata of calls made before the measured one
+	data  []byte   // calldata of the measured call
+	fund  bool     // give the contract a balance to transfer
+	value uint64   // override, distinct from the Cancun default
+}
+
+// word returns v as a 32-byte calldata word.
+func word(v byte) []byte {
+	w := make([]byte, 32)
+	w[31] = v
+
+	return w
+}
+
+// Contract code shared by several scenarios.
+var (
+	storeCalldata  = []byte{0x60, 0x00, 0x35, 0x60, 0x01, 0x55, 0x00}       // SSTORE(1, CALLDATALOAD(0))
+	callDeadPrefix = []byte{0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00} // No args or return data
+)
+
+// callDead returns code calling the cold address 0xdead with op, forwarding no gas
+// and, for CALL, transferring value.
+func callDead(op vm.OpCode, value byte) []byte {
+	code := append([]byte{}, callDeadPrefix...)
+	if op == vm.CALL {
+		code = append(code, 0x60, value)
+	}
+
+	return append(code, 0x61, 0xde, 0xad, 0x60, 0x00, byte(op), 0x50, 0x00)
+}
+
+// dynamicKeyScenarios holds a scenario for every dynamic key read by the gas
+// functions. REFUND_CAP_DIV is applied outside the EVM (see refundCapDivisor).
+var dynamicKeyScenarios = map[string]keyScenario{
+	vm.GasKeySloadCold:        {code: []byte{0x60, 0x00, 0x54, 0x50, 0x00}, value: 3000},
+	vm.GasKeySloadWarm:        {code: []byte{0x60, 0x00, 0x54, 0x50, 0x60, 0x00, 0x54, 0x50, 0x00}, value: 300},
+	vm.GasKeySstoreSet:        {code: storeCalldata, data: word(1), value: 30000},
+	vm.GasKeySstoreReset:      {code: storeCalldata, setup: [][]byte{word(1)}, data: word(2), value: 4000},
+	vm.GasKeySstoreNoop:       {code: storeCalldata, setup: [][]byte{word(1)}, data: word(1), value: 800},
+	vm.GasKeyCallCold:         {code: []byte{0x61, 0xde, 0xad, 0x31, 0x50, 0x00}, value: 5000}, // BALANCE(0xdead)
+	vm.GasKeyCallWarm:         {code: []byte{0x61, 0xde, 0xad, 0x31, 0x50, 0x00}, value: 200},
+	vm.GasKeyDelegateCallCold: {code: callDead(vm.DELEGATECALL, 0), value: 5000},
+	vm.GasKeyStaticCallCold:   {code: callDead(vm.STATICCALL, 0), value: 5000},
+	vm.GasKeyCallValueXfer:    {code: callDead(vm.CALL, 1), fund: true, value: 12000},
+	vm.GasKeyCallNewAccount:   {code: callDead(vm.CALL, 1), fund: true, value: 30000},
+	vm.GasKeyKeccak256Word:    {code: []byte{0x60, 0x20, 0x60, 0x00, 0x20, 0x50, 0x00}, value: 60},
+	vm.GasKeyMemory:           {code: []byte{0x60, 0x00, 0x51, 0x50, 0x00}, value: 30},
+	vm.GasKeyCopy:             {code: []byte{0x60, 0x20, 0x60, 0x00, 0x60, 0x00, 0x37, 0x00}, value: 30},
+	vm.GasKeyLog:              {code: []byte{0x60, 0x00, 0x60, 0x01, 0x60, 0x00, 0xa1, 0x00}, value: 500},
+	vm.GasKeyLogTopic:         {code: []byte{0x60, 0x00, 0x60, 0x01, 0x60, 0x00, 0xa1, 0x00}, value: 500},
+	vm.GasKeyLogData:          {code: []byte{0x60, 0x00, 0x60, 0x01, 0x60, 0x00, 0xa1, 0x00}, value: 80},
+	vm.GasKeyExpByte:          {code: []byte{0x60, 0x02, 0x60, 0x02, 0x0a, 0x50, 0x00}, value: 500},
+	// SELFDESTRUCT(0xdead) with a balance creates the beneficiary
+	vm.GasKeyCreateBySelfDestruct: {code: []byte{0x61, 0xde, 0xad, 0xff}, fund: true, value: 50000},
+	// CREATE with 32 bytes of zero init code
+	vm.GasKeyInitCodeWord: {code: []byte{0x60, 0x20, 0x60, 0x00, 0x60, 0x00, 0xf0, 0x50, 0x00}, value: 20},
+	// CREATE with init code returning one byte of code
+	vm.GasKeyCreateData: {code: []byte{0x64, 0x60, 0x01, 0x60, 0x00, 0xf3, 0x60, 0x00, 0x52,
+		0x60, 0x05, 0x60, 0x1b, 0x60, 0x00, 0xf0, 0x50, 0x00}, value: 2000},
+}
+
+// runScenario executes a scenario on a fresh chain and returns the measured call's gas.
+func runScenario(t *testing.T, sc keyScenario, opts executionOptions) uint64 {
+	t.Helper()
+
+	c := newTestChain(t, map[common.Address][]byte{testContract: sc.code})
+
+	if sc.fund {
+		if err := c.statedb.SetBalance(accounts.InternAddress(testContract), *uint256.NewInt(1e9), tracing.BalanceChangeUnspecified); err != nil {
+			t.Fatal(err)
+		}
+
+		c.finalize()
+	}
+
+	for _, data := range sc.setup {
+		c.call(testContract, data, opts)
+	}
+
+	return c.call(testContract, sc.data, opts).GasUsed
+}
+
+// TestDynamicKeysReachExecution overrides each dynamic gas key and runs a contract
+// paying for it through executeMessage, so a key the gas functions do not read, or
+// that the schedule conversion drops, fails here.
+func TestDynamicKeysReachExecution(t *testing.T) {
+	for _, key := range vm.DynamicGasKeys {
+		if key == vm.GasKeyRefundCapDiv {
+			continue
+		}
+
+		t.Run(key, func(t *testing.T) {
+			sc, ok := dynamicKeyScenarios[key]
+			if !ok {
+				t.Fatalf("no scenario exercises %s", key)
+			}
+
+			base := runScenario(t, sc, executionOptions{})
+			overridden := runScenario(t, sc, executionOptions{GasSchedule: &CustomGasSchedule{
+				Overrides: map[string]uint64{key: sc.value},
+			}})
+
+			if overridden == base {
+				t.Errorf("%s = %d left gas used at %d", key, sc.value, base)
+			}
+		})
+	}
+}
+
+// TestScheduleGatingExecution checks the schedule paths that depend on more than
+// the key: relative overrides must price like their resolved value, and cold keys
+// are ignored without the EIP-2929 access list.
+func TestScheduleGatingExecution(t *testing.T) {
+	sload := dynamicKeyScenarios[vm.GasKeySloadCold]
+	delegateCall := dynamicKeyScenarios[vm.GasKeyDelegateCallCold]
+
+	schedule := func(key string, value uint64) *CustomGasSchedule {
+		return &CustomGasSchedule{Overrides: map[string]uint64{key: value}}
+	}
+
+	t.Run("relative override", func(t *testing.T) {
+		relative := runScenario(t, sload, executionOptions{GasSchedule: &CustomGasSchedule{
+			RelativeOverrides: map[string]RelativeSpec{vm.GasKeySloadCold: {Base: vm.GasKeySloadWarm, Multiplier: 30}},
+		}})
+		concrete := runScenario(t, sload, executionOptions{GasSchedule: schedule(vm.GasKeySloadCold, 3000)})
+
+		if relative != concrete {
+			t.Errorf("relative SLOAD_COLD gas used = %d, want %d as with the resolved value", relative, concrete)
+		}
+	})
+
+	t.Run("CALL_COLD reaches DELEGATECALL", func(t *testing.T) {
+		base := runScenario(t, delegateCall, executionOptions{})
+		callCold := runScenario(t, delegateCall, executionOptions{GasSchedule: schedule(vm.GasKeyCallCold, 5000)})
+
+		if callCold != base+5000-2600 {
+			t.Errorf("gas used = %d with CALL_COLD = 5000, want %d", callCold, base+5000-2600)
+		}
+	})
+
+	for _, tc := range []struct {
+		key string
+		sc  keyScenario
+	}{
+		{key: vm.GasKeySloadCold, sc: sload},
+		{key: vm.GasKeyDelegateCallCold, sc: delegateCall},
+	} {
+		t.Run(tc.key+" without access list", func(t *testing.T) {
+			base := runScenario(t, tc.sc, executionOptions{DisableAccessList: true})
+			overridden := runScenario(t, tc.sc, executionOptions{
+				DisableAccessList: true,
+				GasSchedule:       schedule(tc.key, tc.sc.value),
+			})
+
+			if overridden != base {
+				t.Errorf("%s changed gas used without the access list: %d, want %d", tc.key, overridden, base)
+			}
+		})
+	}
+}
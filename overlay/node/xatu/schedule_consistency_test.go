@@ -0,0 +1,182 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// observableGasKeys are the non-opcode keys whose consumers can be run outside the
+// EVM: precompile and intrinsic gas, the SSTORE no-op cost, the refund cap and blob
+// pricing. The remaining dynamic keys are read by the patched gas functions, which
+// TestDynamicGasDrift in the vm package runs directly.
+func observableGasKeys() map[string]bool {
+	keys := map[string]bool{
+		vm.GasKeySstoreNoop:   true,
+		vm.GasKeySloadWarm:    true,
+		vm.GasKeyRefundCapDiv: true,
+	}
+
+	for _, list := range [][]string{vm.PrecompileGasKeys, vm.IntrinsicGasKeys, blobGasKeys} {
+		for _, key := range list {
+			keys[key] = true
+		}
+	}
+
+	return keys
+}
+
+// precompileTestInputs returns inputs that together exercise every parameter of a
+// precompile's gas formula: 36 words, 6 BN254 pairs, 3 BLS12 pairs, 7 G1 and 4 G2
+// MSM points, and 12 BLAKE2F rounds (which requires exactly 213 bytes). MODEXP
+// adds a 288-byte exponent with an 8-byte and a 64-byte base, as the complexity
+// parameters apply to operands either side of 32 bytes.
+func precompileTestInputs(name string) [][]byte {
+	switch name {
+	case "BLAKE2F":
+		input := make([]byte, 213)
+		input[3] = 12
+		return [][]byte{input}
+	case "MODEXP":
+		inputs := [][]byte{make([]byte, 1152)}
+		for _, baseLen := range []byte{8, 64} {
+			header := make([]byte, 96)
+			header[31], header[62], header[63] = baseLen, 0x01, 0x20 // exponent length 288
+			inputs = append(inputs, header)
+		}
+
+		return inputs
+	}
+
+	return [][]byte{make([]byte, 1152)}
+}
+
+// vmScheduleEffects runs every exported consumer of the VM gas schedule (and of the
+// schedule keys applied outside the EVM) and returns what they computed.
+func vmScheduleEffects(rules *chain.Rules, schedule *CustomGasSchedule) []uint64 {
+	gs := schedule.ToVMGasSchedule()
+
+	var effects []uint64
+
+	precompiles := vm.Precompiles(rules)
+	names := make([]string, 0, len(precompiles))
+	byName := make(map[string]vm.PrecompiledContract, len(precompiles))
+	for _, p := range precompiles {
+		names = append(names, p.Name())
+		byName[p.Name()] = p
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, input := range precompileTestInputs(name) {
+			effects = append(effects, vm.PrecompileGasWithOverrides(gs, name, input, byName[name].RequiredGas(input)))
+		}
+	}
+
+	// Calldata with zero and nonzero bytes, an access list and an authorization
+	data := []byte{0, 1, 0, 2, 3}
+	for _, create := range []bool{false, true} {
+		gas, floor := vm.CalcCustomIntrinsicGas(gs, data, 2, 3, create, true, true, true, true, false, 1)
+		effects = append(effects, gas, floor)
+	}
+
+	effects = append(effects, gs.SstoreNoopCost())
+
+	divisor, _ := schedule.refundCapDivisor()
+	blob := schedule.blobPricing(blobPricing{})
+
+	return append(effects, divisor, blob.GasPerBlob, blob.UpdateFraction)
+}
+
+// TestScheduleOverridePathsConsistent checks, for every key with a fork default,
+// that an override reaches the execution paths the key belongs to and no others:
+// opcode keys change that opcode's constant gas in the JumpTable built by
+// applyScheduleOverrides, while all other keys are passed through
+// ToVMGasSchedule (or applied outside the EVM) and leave the JumpTable untouched.
+// A key honored by neither path, or only half handled, fails here. Dynamic keys are
+// only read by the gas functions, so TestDynamicKeysReachExecution runs those.
+func TestScheduleOverridePathsConsistent(t *testing.T) {
+	observable := observableGasKeys()
+
+	vmKeys := make(map[string]bool)
+	for _, list := range [][]string{vm.DynamicGasKeys, vm.PrecompileGasKeys, vm.IntrinsicGasKeys, blobGasKeys} {
+		for _, key := range list {
+			vmKeys[key] = true
+		}
+	}
+
+	for _, fork := range propertyForks() {
+		defaults := GasScheduleForRules(fork.rules).Overrides
+		base := vm.GetBaseJumpTable(fork.rules)
+
+		keys := make([]string, 0, len(defaults))
+		for key := range defaults {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		unchanged := vmScheduleEffects(fork.rules, &CustomGasSchedule{Overrides: map[string]uint64{}})
+
+		for _, key := range keys {
+			op, isOpcode := opcodeFromString(key)
+			if !isOpcode && !vmKeys[key] {
+				t.Errorf("%s: %s has a default but is handled by neither the JumpTable nor the VM gas schedule", fork.name, key)
+				continue
+			}
+
+			// A value distinct from the default, so a dropped override shows
+			value := defaults[key] + 7
+			schedule := &CustomGasSchedule{Overrides: map[string]uint64{key: value}}
+
+			jt := BuildCustomJumpTable(fork.rules, schedule, JumpTableOptions{})
+			for i := range 256 {
+				other := vm.OpCode(i)
+				if !base.IsDefined(other) {
+					continue
+				}
+
+				want := base[other].GetConstantGas()
+				if isOpcode && other == op {
+					want = value
+				}
+
+				if got := jt[other].GetConstantGas(); got != want {
+					t.Errorf("%s: with %s = %d, %s constant gas = %d, want %d", fork.name, key, value, other, got, want)
+				}
+			}
+
+			effects := vmScheduleEffects(fork.rules, schedule)
+			changed := !slices.Equal(effects, unchanged)
+
+			switch {
+			case isOpcode && changed:
+				t.Errorf("%s: opcode key %s changed the VM schedule consumers", fork.name, key)
+			case observable[key] && !changed:
+				t.Errorf("%s: %s = %d is ignored by its VM schedule consumer", fork.name, key, value)
+			}
+		}
+	}
+}
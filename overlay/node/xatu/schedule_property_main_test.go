@@ -0,0 +1,85 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+)
+
+// refundBound exceeds any refund the generated schedules can produce, since every
+// generated value is below 2^32. A larger counter means a refund expression in the
+// SSTORE or SELFDESTRUCT gas functions underflowed.
+const refundBound = 1 << 40
+
+// executableSchedule drops the keys that can make a call fail before execution
+// (intrinsic gas above the gas limit) or that price blobs, which calls do not carry.
+func executableSchedule(schedule *CustomGasSchedule) *CustomGasSchedule {
+	for key := range schedule.Overrides {
+		if strings.HasPrefix(key, "TX_") || slices.Contains(blobGasKeys, key) {
+			delete(schedule.Overrides, key)
+		}
+	}
+
+	return schedule
+}
+
+// TestRandomScheduleRefunds runs storage writes through every SSTORE transition and
+// a SELFDESTRUCT under random schedules and refund regimes, and checks that the
+// refund counter left by the real gas functions never underflows.
+func TestRandomScheduleRefunds(t *testing.T) {
+	const iterations = 50
+
+	r := rand.New(rand.NewSource(propertySeed))
+	keys := propertyKeys()
+
+	destructor := common.HexToAddress("0x00000000000000000000000000000000000de57c")
+
+	for i := range iterations {
+		schedule := executableSchedule(randomGasSchedule(r, keys))
+		opts := executionOptions{
+			GasSchedule:       schedule,
+			DisableAccessList: r.Intn(2) == 0,
+			LegacyRefunds:     r.Intn(2) == 0,
+		}
+
+		label := fmt.Sprintf("%d (schedule %v, opts %+v)", i, schedule.Overrides, opts)
+
+		c := newTestChain(t, map[common.Address][]byte{
+			testContract: storeCalldata,
+			destructor:   {0x61, 0xde, 0xad, 0xff}, // SELFDESTRUCT(0xdead)
+		})
+
+		// Set, reset, clear, then set again
+		for _, value := range []byte{1, 2, 0, 3} {
+			if result := c.call(testContract, word(value), opts); result.Refund >= refundBound {
+				t.Errorf("%s: refund %d after storing %d underflowed", label, result.Refund, value)
+			}
+		}
+
+		if result := c.call(destructor, nil, opts); result.Refund >= refundBound {
+			t.Errorf("%s: refund %d after SELFDESTRUCT underflowed", label, result.Refund)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// propertySeed fixes the random schedules so failures are reproducible.
+const propertySeed = 20240607
+
+// propertyFork names the rules of a fork the property test runs against.
+type propertyFork struct {
+	name  string
+	rules *chain.Rules
+}
+
+// propertyForks returns a spread of forks, oldest first. A slice keeps the
+// generated schedules in a fixed order for the seed.
+func propertyForks() []propertyFork {
+	istanbul := chain.Rules{IsHomestead: true, IsTangerineWhistle: true, IsSpuriousDragon: true, IsByzantium: true,
+		IsConstantinople: true, IsPetersburg: true, IsIstanbul: true}
+
+	berlin := istanbul
+	berlin.IsBerlin = true
+
+	london := berlin
+	london.IsLondon = true
+
+	cancun := london
+	cancun.IsShanghai, cancun.IsCancun = true, true
+
+	osaka := cancun
+	osaka.IsPrague, osaka.IsOsaka = true, true
+
+	return []propertyFork{
+		{"frontier", &chain.Rules{}},
+		{"istanbul", &istanbul},
+		{"berlin", &berlin},
+		{"london", &london},
+		{"cancun", &cancun},
+		{"osaka", &osaka},
+	}
+}
+
+// osakaRules returns the rules of the latest fork in propertyForks.
+func osakaRules() *chain.Rules {
+	forks := propertyForks()
+	return forks[len(forks)-1].rules
+}
+
+// propertyKeys returns every key a generated schedule may set: opcode names,
+// dynamic gas parameters and linear gas model parameters, sorted so that the
+// generator is deterministic for a seed.
+func propertyKeys() []string {
+	seen := make(map[string]struct{})
+	for key := range GasScheduleForRules(osakaRules()).Overrides {
+		seen[key] = struct{}{}
+	}
+
+	for _, key := range vm.DynamicGasKeys {
+		seen[key] = struct{}{}
+	}
+
+	for _, op := range []string{"KECCAK256", "MLOAD", "CALLDATACOPY", "LOG2", "SLOAD"} {
+		for _, param := range []string{linearGasBase, linearGasPerWord, linearGasPerItem, linearGasSizeArg} {
+			seen[linearGasPrefix+op+param] = struct{}{}
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// randomGasValue returns a gas value biased towards the edges: zero, one, values
+// near the standard costs, and large but non-overflowing costs.
+func randomGasValue(r *rand.Rand) uint64 {
+	switch r.Intn(5) {
+	case 0:
+		return 0
+	case 1:
+		return 1
+	case 2:
+		return uint64(r.Intn(100))
+	case 3:
+		return uint64(r.Intn(50_000))
+	default:
+		return uint64(r.Int63n(1 << 32))
+	}
+}
+
+// randomGasSchedule generates a valid schedule overriding a random subset of keys.
+func randomGasSchedule(r *rand.Rand, keys []string) *CustomGasSchedule {
+	overrides := make(map[string]uint64)
+
+	for range r.Intn(len(keys)) {
+		overrides[keys[r.Intn(len(keys))]] = randomGasValue(r)
+	}
+
+	return &CustomGasSchedule{Overrides: overrides}
+}
+
+// TestRandomScheduleProperties builds jump tables from random schedules and checks
+// that the override machinery never panics, never drops an opcode or gas function
+// the fork defines, and that the refund cap holds.
+func TestRandomScheduleProperties(t *testing.T) {
+	const iterations = 200
+
+	r := rand.New(rand.NewSource(propertySeed))
+	keys := propertyKeys()
+
+	for _, fork := range propertyForks() {
+		base := vm.GetBaseJumpTable(fork.rules)
+
+		for i := range iterations {
+			schedule := randomGasSchedule(r, keys)
+			opts := JumpTableOptions{DisableEIP2929: r.Intn(2) == 0}
+
+			if r.Intn(4) == 0 {
+				opts.PCOverrides = map[uint64]map[string]uint64{
+					uint64(r.Intn(64)): {keys[r.Intn(len(keys))]: randomGasValue(r)},
+				}
+			}
+
+			label := fmt.Sprintf("%s/%d", fork.name, i)
+
+			jt := buildWithoutPanic(t, label, fork.rules, schedule, opts)
+			if jt == nil {
+				continue
+			}
+
+			checkJumpTable(t, label, base, jt, schedule, opts)
+			checkRefundCap(t, label, schedule)
+		}
+	}
+}
+
+// buildWithoutPanic builds a jump table, reporting the schedule on panic.
+func buildWithoutPanic(t *testing.T, label string, rules *chain.Rules, schedule *CustomGasSchedule, opts JumpTableOptions) (jt *vm.JumpTable) {
+	t.Helper()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("%s: BuildCustomJumpTable panicked: %v (schedule %v, opts %+v)", label, r, schedule.Overrides, opts)
+			jt = nil
+		}
+	}()
+
+	return BuildCustomJumpTable(rules, schedule, opts)
+}
+
+// checkJumpTable verifies that every opcode the base table defines is still
+// defined with its execute, dynamic gas and memory size functions, and that
+// constant gas overrides took effect.
+func checkJumpTable(t *testing.T, label string, base, jt *vm.JumpTable, schedule *CustomGasSchedule, opts JumpTableOptions) {
+	t.Helper()
+
+	for i := range 256 {
+		op := vm.OpCode(i)
+
+		if base.IsDefined(op) != jt.IsDefined(op) {
+			t.Errorf("%s: %s defined = %v, base defined = %v", label, op, jt.IsDefined(op), base.IsDefined(op))
+		}
+
+		if base[op] != nil && jt[op] == nil {
+			t.Errorf("%s: %s operation is nil", label, op)
+			continue
+		}
+
+		if base.HasDynamicGas(op) && !jt.HasDynamicGas(op) {
+			t.Errorf("%s: %s lost its dynamic gas function", label, op)
+		}
+
+		if base.HasMemorySize(op) && !jt.HasMemorySize(op) {
+			t.Errorf("%s: %s lost its memory size function", label, op)
+		}
+	}
+
+	// PC overrides move constant gas into the dynamic gas function
+	if len(opts.PCOverrides) > 0 {
+		return
+	}
+
+	for key, gas := range schedule.Overrides {
+		op, ok := opcodeFromString(key)
+		if !ok || !jt.IsDefined(op) {
+			continue
+		}
+
+		if got := jt[op].GetConstantGas(); got != gas {
+			t.Errorf("%s: %s constant gas = %d, want %d", label, op, got, gas)
+		}
+	}
+}
+
+// checkRefundCap applies the refund cap with the schedule's divisor, which may
+// not let the refund exceed the gas used. The SSTORE and SELFDESTRUCT refunds
+// themselves are run through their gas functions by TestRandomScheduleRefunds.
+func checkRefundCap(t *testing.T, label string, schedule *CustomGasSchedule) {
+	t.Helper()
+
+	set := schedule.ToVMGasSchedule().GetOr(vm.GasKeySstoreSet, params.SstoreSetGasEIP2200)
+	reset := schedule.ToVMGasSchedule().GetOr(vm.GasKeySstoreReset, params.SstoreResetGasEIP2200)
+
+	divisor, ok := schedule.refundCapDivisor()
+	if !ok {
+		divisor = params.RefundQuotientEIP3529
+	}
+
+	gasUsed := set + reset
+	if got := cappedRefund(gasUsed, set, divisor); got > gasUsed {
+		t.Errorf("%s: refund %d exceeds gas used %d (divisor %d)", label, got, gasUsed, divisor)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/creasty/defaults"
 	"github.com/ethpandaops/execution-processor/pkg/config"
@@ -51,6 +52,24 @@ import (
 type Config struct {
 	ConfigPath     string
 	SimulationOnly bool // If true, only enable simulation RPC endpoints without execution-processor
+
+	// MinSupportedFork rejects gas simulations for blocks before this fork (e.g. "berlin").
+	// Empty allows all forks.
+	MinSupportedFork string
+
+	// MaxResponseBytes truncates block simulation results whose estimated JSON size
+	// exceeds this many bytes. 0 disables the limit.
+	MaxResponseBytes uint64
+
+	// MaxConcurrentExecutions bounds the number of simulation EVM executions running
+	// at once across all requests. 0 defaults to GOMAXPROCS.
+	MaxConcurrentExecutions int
+
+	// ResultCacheSize is the number of block simulation results kept in memory for
+	// repeated identical requests. 0 disables the cache.
+	ResultCacheSize int
+	// ResultCacheTTL is how long a cached result is served. 0 uses a default.
+	ResultCacheTTL time.Duration
 }
 
 // Service implements the Xatu execution processor integration.
@@ -64,6 +83,17 @@ type Service struct {
 	engine      rules.EngineReader
 	dirs        datadir.Dirs
 
+	// minSupportedFork is the index into forkOrder of config.MinSupportedFork,
+	// or -1 if simulations are allowed at any fork.
+	minSupportedFork int
+
+	// executionSlots is a semaphore bounding concurrent EVM executions
+	// (see acquireExecution).
+	executionSlots chan struct{}
+
+	// resultCache serves repeated block simulations (nil when disabled).
+	resultCache *resultCache
+
 	// receiptsGen regenerates receipts on an RCache-domain miss (the same path
 	// the eth_getBlockReceipts RPC uses). Lazily initialised via receiptsGenOnce
 	// by the version-specific datasource (receiptsGenerator()).
@@ -77,12 +107,22 @@ type Service struct {
 	dbChainConfigOnce sync.Once
 	dbChainConfigErr  error
 
+	// chainConfigDiff lists the fields where the database chain config differs from
+	// chainConfig, as of the last check (see runChainConfigCheck).
+	chainConfigMu   sync.Mutex
+	chainConfigDiff []string
+
 	// execution-processor components
 	embeddedNode *execution.EmbeddedNode
 	pool         *ethereum.Pool
 	manager      *processor.Manager
 	stateManager *state.Manager
 	redisClient  *r.Client
+	redisPrefix  string
+
+	// memorySchedules holds saved schedules when there is no Redis client
+	// (see scheduleStore).
+	memorySchedules *memoryScheduleStore
 
 	ctx       context.Context
 	ctxCancel context.CancelFunc
@@ -102,14 +142,9 @@ func New(
 	config Config,
 	logger log.Logger,
 ) (*Service, error) {
-	svc := &Service{
-		config:      config,
-		db:          db,
-		blockReader: blockReader,
-		chainConfig: chainConfig,
-		engine:      engine,
-		dirs:        n.Config().Dirs,
-		log:         logger.New("service", "xatu"),
+	svc, err := newService(db, blockReader, chainConfig, engine, n.Config().Dirs, config, logger)
+	if err != nil {
+		return nil, err
 	}
 
 	n.RegisterLifecycle(svc)
@@ -117,6 +152,39 @@ func New(
 	return svc, nil
 }
 
+// newService creates the service without registering it with a node. Everything
+// the simulation and trace endpoints need is set here; the execution-processor
+// pipeline (config file, Redis, state manager) is only created in Start, and not
+// at all in simulation-only mode.
+func newService(
+	db kv.TemporalRoDB,
+	blockReader services.FullBlockReader,
+	chainConfig *chain.Config,
+	engine rules.EngineReader,
+	dirs datadir.Dirs,
+	config Config,
+	logger log.Logger,
+) (*Service, error) {
+	minSupportedFork, err := parseFork(config.MinSupportedFork)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minimum supported fork: %w", err)
+	}
+
+	return &Service{
+		config:           config,
+		db:               db,
+		blockReader:      blockReader,
+		chainConfig:      chainConfig,
+		engine:           engine,
+		dirs:             dirs,
+		minSupportedFork: minSupportedFork,
+		executionSlots:   make(chan struct{}, executionLimit(config.MaxConcurrentExecutions)),
+		resultCache:      newResultCache(config.ResultCacheSize, config.ResultCacheTTL),
+		memorySchedules:  newMemoryScheduleStore(maxSavedSchedules),
+		log:              logger.New("service", "xatu"),
+	}, nil
+}
+
 // loadConfig loads the config from file.
 func loadConfig(file string) (*config.Config, error) {
 	cfg := &config.Config{}
@@ -145,6 +213,18 @@ func loadConfig(file string) (*config.Config, error) {
 
 // Start implements node.Lifecycle, starting the Xatu service.
 func (s *Service) Start() error {
+	// Create cancellable context for lifecycle management
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	ctx := s.ctx
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		s.runChainConfigCheck(ctx)
+	}()
+
 	// Simulation-only mode: skip execution-processor setup, only enable RPC endpoints
 	if s.config.SimulationOnly {
 		s.log.Info("Xatu service started in simulation-only mode")
@@ -178,9 +258,7 @@ func (s *Service) Start() error {
 		return fmt.Errorf("failed to create redis client: %w", err)
 	}
 
-	// Create cancellable context for lifecycle management
-	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
-	ctx := s.ctx
+	s.redisPrefix = cfg.Redis.Prefix
 
 	s.stateManager, err = state.NewManager(fieldLogger.WithField("component", "state"), &cfg.StateManager)
 	if err != nil {
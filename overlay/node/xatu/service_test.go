@@ -0,0 +1,88 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethpandaops/execution-processor/pkg/ethereum/execution"
+
+	"github.com/erigontech/erigon/common/log/v3"
+	"github.com/erigontech/erigon/db/datadir"
+	"github.com/erigontech/erigon/db/kv"
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+var errStubDB = errors.New("stub database")
+
+// stubDB is a database whose transactions always fail to open. Requests that reach
+// it have passed every check that could depend on the execution-processor pipeline.
+type stubDB struct {
+	kv.TemporalRoDB
+}
+
+func (stubDB) BeginTemporalRo(context.Context) (kv.TemporalTx, error) {
+	return nil, errStubDB
+}
+
+// TestServiceSimulationOnly starts the service in simulation-only mode without a
+// config file or Redis and verifies that the simulation and trace endpoints run up
+// to the database, with none of the processor components created.
+func TestServiceSimulationOnly(t *testing.T) {
+	svc, err := newService(stubDB{}, nil, &chain.Config{ChainID: big.NewInt(1)}, nil, datadir.Dirs{},
+		Config{SimulationOnly: true}, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() = %v, want no error without a config file", err)
+	}
+
+	if svc.embeddedNode != nil || svc.pool != nil || svc.manager != nil || svc.stateManager != nil || svc.redisClient != nil {
+		t.Error("expected no execution-processor components in simulation-only mode")
+	}
+
+	ctx := context.Background()
+
+	if _, err := svc.SimulateBlockGas(ctx, SimulateBlockGasRequest{BlockNumber: 1}); !errors.Is(err, errStubDB) {
+		t.Errorf("SimulateBlockGas error = %v, want the database error", err)
+	}
+
+	if _, err := svc.SimulateTransactionGas(ctx, SimulateTransactionGasRequest{TransactionHash: "0x01"}); !errors.Is(err, errStubDB) {
+		t.Errorf("SimulateTransactionGas error = %v, want the database error", err)
+	}
+
+	if _, err := svc.DebugTraceTransaction(ctx, "0x01", nil, execution.TraceOptions{}); !errors.Is(err, errStubDB) {
+		t.Errorf("DebugTraceTransaction error = %v, want the database error", err)
+	}
+
+	svc.SetSynced(true)
+
+	if !svc.IsSynced() {
+		t.Error("expected service to report synced")
+	}
+
+	if err := svc.Stop(); err != nil {
+		t.Errorf("Stop() = %v", err)
+	}
+}
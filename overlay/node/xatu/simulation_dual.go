@@ -0,0 +1,102 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "fmt"
+
+// executeFunc runs a single execution of a transaction with the given tracer and options.
+type executeFunc func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error)
+
+// runDualExecution runs the original and simulated executions of a transaction and
+// combines their tracer output.
+//
+// When opts is identical to its baseline (no gas overrides and nothing else that only
+// affects the simulated run) both executions would be identical, so the simulated
+// execution is skipped and its fields are copied from the original.
+func runDualExecution(opts executionOptions, tracerCfg SimulationTracerConfig, execute executeFunc) (*dualExecutionResult, error) {
+	// Execute with standard JumpTable (original gas costs)
+	originalTracer := NewSimulationTracer(nil, tracerCfg)
+
+	originalResult, err := execute(originalTracer, opts.baseline())
+	if err != nil {
+		return nil, fmt.Errorf("original execution failed: %w", err)
+	}
+
+	// Capture tracer stats for original execution
+	originalResult.RevertCount = originalTracer.GetRevertCount()
+	originalResult.OpcodeCount = originalTracer.GetTotalOpcodeCount()
+	originalResult.PeakMemory = originalTracer.GetPeakMemoryBytes()
+	originalResult.CallErrors = originalTracer.GetCallErrors()
+	originalResult.ExecutionGas = originalTracer.GetExecutionGas()
+
+	simulatedTracer := originalTracer
+	simulatedResult := originalResult
+
+	if opts.isBaseline() {
+		copied := *originalResult
+		simulatedResult = &copied
+	} else {
+		// Execute with custom JumpTable (simulated gas costs)
+		simulatedTracer = NewSimulationTracer(opts.GasSchedule, tracerCfg)
+
+		simulatedResult, err = execute(simulatedTracer, opts)
+		if err != nil {
+			return nil, fmt.Errorf("simulated execution failed: %w", err)
+		}
+
+		// Capture tracer stats for simulated execution
+		simulatedResult.RevertCount = simulatedTracer.GetRevertCount()
+		simulatedResult.OpcodeCount = simulatedTracer.GetTotalOpcodeCount()
+		simulatedResult.PeakMemory = simulatedTracer.GetPeakMemoryBytes()
+		simulatedResult.CallErrors = simulatedTracer.GetCallErrors()
+		simulatedResult.ExecutionGas = simulatedTracer.GetExecutionGas()
+
+		if divisor, ok := opts.refundCapDivisor(); ok {
+			applyRefundCapOverride(simulatedResult, divisor)
+		}
+	}
+
+	breakdown := combineOpcodeBreakdowns(originalTracer, simulatedTracer)
+	addTxSignatureCost(breakdown, opts.GasSchedule)
+
+	return &dualExecutionResult{
+		Original:        originalResult,
+		Simulated:       simulatedResult,
+		OpcodeBreakdown: breakdown,
+		AccessListDiff:  diffAccessLists(originalTracer.accesses(), simulatedTracer.accesses()),
+		ColdAccessCount: originalTracer.accesses().coldAccessCount(),
+
+		CallClassification: combineCallClassifications(originalTracer, simulatedTracer),
+		WarmAccessOrigins:  combineWarmAccessOrigins(originalTracer, simulatedTracer),
+		CallTree:           combineCallTrees(originalTracer, simulatedTracer),
+		OpcodePairs:        combineOpcodePairs(originalTracer, simulatedTracer),
+		ContractBreakdown:  combineContractBreakdowns(originalTracer, simulatedTracer),
+		FirstSeenPC:        originalTracer.GetFirstSeenPCs(),
+		Logs:               combineEmittedLogs(originalTracer, simulatedTracer),
+		StorageChanges:     combineStorageChanges(originalTracer, simulatedTracer),
+		PrecompileCalls:    combinePrecompileCalls(originalTracer, simulatedTracer),
+		TracerStats:        combineTracerStats(originalTracer, simulatedTracer),
+
+		OriginalAccessListUse:  originalTracer.accessListHits(),
+		SimulatedAccessListUse: simulatedTracer.accessListHits(),
+
+		OriginalSequence:  originalTracer.GetSequence(),
+		SimulatedSequence: simulatedTracer.GetSequence(),
+	}, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestRunDualExecutionBaselineFastPath verifies that requests without overrides
+// execute each transaction only once and report identical original and simulated
+// results, while requests with overrides still execute twice.
+func TestRunDualExecutionBaselineFastPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      executionOptions
+		wantCalls int
+	}{
+		{name: "nil schedule", opts: executionOptions{}, wantCalls: 1},
+		{name: "empty overrides", opts: executionOptions{GasSchedule: &CustomGasSchedule{}}, wantCalls: 1},
+		{name: "with overrides", opts: executionOptions{GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 100}}}, wantCalls: 2},
+		{name: "max gas limit", opts: executionOptions{MaxGasLimit: true}, wantCalls: 2},
+		{name: "access list disabled", opts: executionOptions{DisableAccessList: true}, wantCalls: 2},
+		{name: "legacy refunds", opts: executionOptions{LegacyRefunds: true}, wantCalls: 2},
+		{name: "force all cold", opts: executionOptions{ForceAllCold: true}, wantCalls: 2},
+		{name: "precompile disabled", opts: executionOptions{Precompiles: precompileOverrides{Disabled: []string{"0x01"}}}, wantCalls: 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			execute := func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+				calls++
+				return &executionResult{GasUsed: 21000, IntrinsicGas: 21000, Status: "success"}, nil
+			}
+
+			result, err := runDualExecution(tc.opts, SimulationTracerConfig{}, execute)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if calls != tc.wantCalls {
+				t.Errorf("executed %d times, want %d", calls, tc.wantCalls)
+			}
+
+			if result.Original == result.Simulated {
+				t.Error("expected simulated result to be a separate copy")
+			}
+
+			if result.Original.GasUsed != result.Simulated.GasUsed || result.Original.Status != result.Simulated.Status {
+				t.Errorf("original %+v and simulated %+v differ", result.Original, result.Simulated)
+			}
+		})
+	}
+}
+
+// TestRunDualExecutionErrors verifies that execution errors are attributed to
+// the run that produced them.
+func TestRunDualExecutionErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+	opts := executionOptions{GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 100}}}
+
+	calls := 0
+	_, err := runDualExecution(opts, SimulationTracerConfig{}, func(*SimulationTracer, executionOptions) (*executionResult, error) {
+		calls++
+		if calls == 2 {
+			return nil, errBoom
+		}
+
+		return &executionResult{Status: "success"}, nil
+	})
+
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("expected wrapped error, got %v", err)
+	}
+
+	if err.Error() != "simulated execution failed: boom" {
+		t.Errorf("error = %q", err.Error())
+	}
+}
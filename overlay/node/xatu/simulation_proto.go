@@ -0,0 +1,669 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// SimulateBlockGasProto is xatu_simulateBlockGas with the result encoded as the
+// SimulateBlockGasResult protobuf message (see simulation.proto), for consumers
+// that avoid JSON. Over JSON-RPC the bytes are base64-encoded.
+func (s *Service) SimulateBlockGasProto(ctx context.Context, req SimulateBlockGasRequest) ([]byte, error) {
+	result, err := s.SimulateBlockGas(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.MarshalProto(), nil
+}
+
+// MarshalProto encodes the result as the SimulateBlockGasResult protobuf message.
+// Maps are encoded in key order, so the output is deterministic.
+func (r *SimulateBlockGasResult) MarshalProto() []byte {
+	var e protoEncoder
+
+	e.uint64(1, r.BlockNumber)
+	e.message(2, r.Original.encodeProto)
+	e.message(3, r.Simulated.encodeProto)
+
+	for i := range r.Transactions {
+		e.message(4, r.Transactions[i].encodeProto)
+	}
+
+	for _, op := range slices.Sorted(maps.Keys(r.OpcodeBreakdown)) {
+		summary := r.OpcodeBreakdown[op]
+		e.mapEntry(5, op, summary.encodeProto)
+	}
+
+	e.string(6, r.OpcodeBreakdownCSV)
+
+	for _, eip := range slices.Sorted(maps.Keys(r.EIPBreakdown)) {
+		gas := r.EIPBreakdown[eip]
+		e.mapEntry(7, eip, gas.encodeProto)
+	}
+
+	for _, preset := range slices.Sorted(maps.Keys(r.PresetKeys)) {
+		keys := r.PresetKeys[preset]
+		e.mapEntry(8, preset, func(e *protoEncoder) {
+			for _, key := range keys {
+				e.string(1, key)
+			}
+		})
+	}
+
+	for i := range r.TopDeltas {
+		e.message(9, r.TopDeltas[i].encodeProto)
+	}
+
+	for i := range r.TopMovers {
+		e.message(10, r.TopMovers[i].encodeProto)
+	}
+
+	e.string(11, r.BaseFee)
+	e.string(12, r.FeeDelta)
+	e.string(13, r.ChainConfigSource)
+	e.message(14, r.Accounting.encodeProto)
+	e.bool(15, r.Truncated)
+	e.string(16, r.TruncationReason)
+	e.message(17, r.GasPercentiles.encodeProto)
+
+	if r.GasLimitStop != nil {
+		e.message(18, r.GasLimitStop.encodeProto)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(r.ChainRules)) {
+		active := r.ChainRules[name]
+		e.message(19, func(entry *protoEncoder) {
+			entry.string(1, name)
+			entry.bool(2, active)
+		})
+	}
+
+	return e.buf
+}
+
+// UnmarshalSimulateBlockGasProto decodes a SimulateBlockGasResult protobuf message.
+// Unknown fields are skipped, so older decoders accept newer encodings.
+func UnmarshalSimulateBlockGasProto(b []byte) (*SimulateBlockGasResult, error) {
+	r := &SimulateBlockGasResult{
+		Transactions:    []TxSummary{},
+		OpcodeBreakdown: make(map[string]OpcodeSummary),
+	}
+
+	err := decodeProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			r.BlockNumber = f.varint
+		case 2:
+			return decodeProto(f.bytes, r.Original.decodeProtoField)
+		case 3:
+			return decodeProto(f.bytes, r.Simulated.decodeProtoField)
+		case 4:
+			var tx TxSummary
+			if err := decodeProto(f.bytes, tx.decodeProtoField); err != nil {
+				return err
+			}
+			r.Transactions = append(r.Transactions, tx)
+		case 5:
+			var summary OpcodeSummary
+			op, err := decodeProtoMapEntry(f.bytes, summary.decodeProtoField)
+			if err != nil {
+				return err
+			}
+			r.OpcodeBreakdown[op] = summary
+		case 6:
+			r.OpcodeBreakdownCSV = string(f.bytes)
+		case 7:
+			var gas EIPGas
+			eip, err := decodeProtoMapEntry(f.bytes, gas.decodeProtoField)
+			if err != nil {
+				return err
+			}
+			if r.EIPBreakdown == nil {
+				r.EIPBreakdown = make(map[string]EIPGas)
+			}
+			r.EIPBreakdown[eip] = gas
+		case 8:
+			var keys []string
+			preset, err := decodeProtoMapEntry(f.bytes, func(f protoField) error {
+				if f.num == 1 {
+					keys = append(keys, string(f.bytes))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if r.PresetKeys == nil {
+				r.PresetKeys = make(map[string][]string)
+			}
+			r.PresetKeys[preset] = keys
+		case 9:
+			var tx TxSummary
+			if err := decodeProto(f.bytes, tx.decodeProtoField); err != nil {
+				return err
+			}
+			r.TopDeltas = append(r.TopDeltas, tx)
+		case 10:
+			var delta OpcodeDelta
+			if err := decodeProto(f.bytes, delta.decodeProtoField); err != nil {
+				return err
+			}
+			r.TopMovers = append(r.TopMovers, delta)
+		case 11:
+			r.BaseFee = string(f.bytes)
+		case 12:
+			r.FeeDelta = string(f.bytes)
+		case 13:
+			r.ChainConfigSource = string(f.bytes)
+		case 14:
+			return decodeProto(f.bytes, r.Accounting.decodeProtoField)
+		case 15:
+			r.Truncated = f.varint != 0
+		case 16:
+			r.TruncationReason = string(f.bytes)
+		case 17:
+			return decodeProto(f.bytes, r.GasPercentiles.decodeProtoField)
+		case 18:
+			r.GasLimitStop = &GasLimitStop{}
+			return decodeProto(f.bytes, r.GasLimitStop.decodeProtoField)
+		case 19:
+			var name string
+			var active bool
+			if err := decodeProto(f.bytes, func(f protoField) error {
+				switch f.num {
+				case 1:
+					name = string(f.bytes)
+				case 2:
+					active = f.varint != 0
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if r.ChainRules == nil {
+				r.ChainRules = make(map[string]bool)
+			}
+			r.ChainRules[name] = active
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode simulation result: %w", err)
+	}
+
+	return r, nil
+}
+
+func (b *BlockGasSummary) encodeProto(e *protoEncoder) {
+	e.uint64(1, b.GasUsed)
+	e.uint64(2, b.GasLimit)
+	e.uint64(3, b.GasReverted)
+	e.bool(4, b.WouldExceedLimit)
+	e.uint64(5, b.TxCount)
+	e.uint64(6, b.AvgGasPerTx)
+}
+
+func (b *BlockGasSummary) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		b.GasUsed = f.varint
+	case 2:
+		b.GasLimit = f.varint
+	case 3:
+		b.GasReverted = f.varint
+	case 4:
+		b.WouldExceedLimit = f.varint != 0
+	case 5:
+		b.TxCount = f.varint
+	case 6:
+		b.AvgGasPerTx = f.varint
+	}
+
+	return nil
+}
+
+func (t *TxSummary) encodeProto(e *protoEncoder) {
+	e.string(1, t.Hash)
+	e.uint64(2, t.Index)
+	e.string(3, t.OriginalStatus)
+	e.string(4, t.SimulatedStatus)
+	e.uint64(5, t.OriginalGas)
+	e.uint64(6, t.SimulatedGas)
+	e.double(7, t.DeltaPercent)
+	e.bool(8, t.Diverged)
+	e.uint64(9, t.OriginalReverts)
+	e.uint64(10, t.SimulatedReverts)
+
+	for i := range t.OriginalErrors {
+		e.message(11, t.OriginalErrors[i].encodeProto)
+	}
+
+	for i := range t.SimulatedErrors {
+		e.message(12, t.SimulatedErrors[i].encodeProto)
+	}
+
+	e.uint64(13, t.OriginalPeakMemoryBytes)
+	e.uint64(14, t.SimulatedPeakMemoryBytes)
+	e.uint64(15, t.OriginalGasRemaining)
+	e.uint64(16, t.SimulatedGasRemaining)
+	e.string(17, t.OriginalExecError)
+	e.string(18, t.SimulatedExecError)
+	e.string(19, t.Error)
+	e.bool(20, t.Panicked)
+	e.string(21, t.PanicMessage)
+	e.string(22, t.FeeDelta)
+}
+
+func (t *TxSummary) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		t.Hash = string(f.bytes)
+	case 2:
+		t.Index = f.varint
+	case 3:
+		t.OriginalStatus = string(f.bytes)
+	case 4:
+		t.SimulatedStatus = string(f.bytes)
+	case 5:
+		t.OriginalGas = f.varint
+	case 6:
+		t.SimulatedGas = f.varint
+	case 7:
+		t.DeltaPercent = f.double()
+	case 8:
+		t.Diverged = f.varint != 0
+	case 9:
+		t.OriginalReverts = f.varint
+	case 10:
+		t.SimulatedReverts = f.varint
+	case 11, 12:
+		var callErr CallError
+		if err := decodeProto(f.bytes, callErr.decodeProtoField); err != nil {
+			return err
+		}
+		if f.num == 11 {
+			t.OriginalErrors = append(t.OriginalErrors, callErr)
+		} else {
+			t.SimulatedErrors = append(t.SimulatedErrors, callErr)
+		}
+	case 13:
+		t.OriginalPeakMemoryBytes = f.varint
+	case 14:
+		t.SimulatedPeakMemoryBytes = f.varint
+	case 15:
+		t.OriginalGasRemaining = f.varint
+	case 16:
+		t.SimulatedGasRemaining = f.varint
+	case 17:
+		t.OriginalExecError = string(f.bytes)
+	case 18:
+		t.SimulatedExecError = string(f.bytes)
+	case 19:
+		t.Error = string(f.bytes)
+	case 20:
+		t.Panicked = f.varint != 0
+	case 21:
+		t.PanicMessage = string(f.bytes)
+	case 22:
+		t.FeeDelta = string(f.bytes)
+	}
+
+	return nil
+}
+
+func (c *CallError) encodeProto(e *protoEncoder) {
+	e.int64(1, int64(c.Depth))
+	e.string(2, c.Type)
+	e.string(3, c.Error)
+	e.string(4, c.Address)
+}
+
+func (c *CallError) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		c.Depth = int(int64(f.varint))
+	case 2:
+		c.Type = string(f.bytes)
+	case 3:
+		c.Error = string(f.bytes)
+	case 4:
+		c.Address = string(f.bytes)
+	}
+
+	return nil
+}
+
+func (o *OpcodeSummary) encodeProto(e *protoEncoder) {
+	e.uint64(1, o.OriginalCount)
+	e.uint64(2, o.OriginalGas)
+	e.uint64(3, o.SimulatedCount)
+	e.uint64(4, o.SimulatedGas)
+	e.uint64(5, o.OriginalMinGas)
+	e.uint64(6, o.OriginalMaxGas)
+	e.uint64(7, o.SimulatedMinGas)
+	e.uint64(8, o.SimulatedMaxGas)
+	e.double(9, o.OriginalGasPercent)
+	e.double(10, o.SimulatedGasPercent)
+	e.double(11, o.OriginalGasStdDev)
+	e.double(12, o.SimulatedGasStdDev)
+}
+
+func (o *OpcodeSummary) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		o.OriginalCount = f.varint
+	case 2:
+		o.OriginalGas = f.varint
+	case 3:
+		o.SimulatedCount = f.varint
+	case 4:
+		o.SimulatedGas = f.varint
+	case 5:
+		o.OriginalMinGas = f.varint
+	case 6:
+		o.OriginalMaxGas = f.varint
+	case 7:
+		o.SimulatedMinGas = f.varint
+	case 8:
+		o.SimulatedMaxGas = f.varint
+	case 9:
+		o.OriginalGasPercent = f.double()
+	case 10:
+		o.SimulatedGasPercent = f.double()
+	case 11:
+		o.OriginalGasStdDev = f.double()
+	case 12:
+		o.SimulatedGasStdDev = f.double()
+	}
+
+	return nil
+}
+
+func (g *EIPGas) encodeProto(e *protoEncoder) {
+	e.uint64(1, g.OriginalGas)
+	e.uint64(2, g.SimulatedGas)
+}
+
+func (g *EIPGas) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		g.OriginalGas = f.varint
+	case 2:
+		g.SimulatedGas = f.varint
+	}
+
+	return nil
+}
+
+func (d *OpcodeDelta) encodeProto(e *protoEncoder) {
+	e.string(1, d.Opcode)
+	e.uint64(2, d.OriginalGas)
+	e.uint64(3, d.SimulatedGas)
+	e.int64(4, d.Delta)
+	e.double(5, d.PercentChange)
+}
+
+func (d *OpcodeDelta) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		d.Opcode = string(f.bytes)
+	case 2:
+		d.OriginalGas = f.varint
+	case 3:
+		d.SimulatedGas = f.varint
+	case 4:
+		d.Delta = int64(f.varint)
+	case 5:
+		d.PercentChange = f.double()
+	}
+
+	return nil
+}
+
+func (a *BlockGasAccounting) encodeProto(e *protoEncoder) {
+	e.message(1, a.Original.encodeProto)
+	e.message(2, a.Simulated.encodeProto)
+}
+
+func (a *BlockGasAccounting) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		return decodeProto(f.bytes, a.Original.decodeProtoField)
+	case 2:
+		return decodeProto(f.bytes, a.Simulated.decodeProtoField)
+	}
+
+	return nil
+}
+
+func (p *GasPercentiles) encodeProto(e *protoEncoder) {
+	e.message(1, p.Original.encodeProto)
+	e.message(2, p.Simulated.encodeProto)
+}
+
+func (p *GasPercentiles) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		return decodeProto(f.bytes, p.Original.decodeProtoField)
+	case 2:
+		return decodeProto(f.bytes, p.Simulated.decodeProtoField)
+	}
+
+	return nil
+}
+
+func (g *GasLimitStop) encodeProto(e *protoEncoder) {
+	e.uint64(1, g.TxIndex)
+	e.uint64(2, g.SimulatedGasUsed)
+	e.uint64(3, g.SkippedTxs)
+}
+
+func (g *GasLimitStop) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		g.TxIndex = f.varint
+	case 2:
+		g.SimulatedGasUsed = f.varint
+	case 3:
+		g.SkippedTxs = f.varint
+	}
+
+	return nil
+}
+
+func (d *GasDistribution) encodeProto(e *protoEncoder) {
+	e.uint64(1, d.P50)
+	e.uint64(2, d.P90)
+	e.uint64(3, d.P99)
+	e.uint64(4, d.Max)
+}
+
+func (d *GasDistribution) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		d.P50 = f.varint
+	case 2:
+		d.P90 = f.varint
+	case 3:
+		d.P99 = f.varint
+	case 4:
+		d.Max = f.varint
+	}
+
+	return nil
+}
+
+func (a *GasAccounting) encodeProto(e *protoEncoder) {
+	e.uint64(1, a.OpcodeGas)
+	e.uint64(2, a.IntrinsicGas)
+	e.uint64(3, a.ExecutionGas)
+	e.int64(4, a.UnattributedGas)
+}
+
+func (a *GasAccounting) decodeProtoField(f protoField) error {
+	switch f.num {
+	case 1:
+		a.OpcodeGas = f.varint
+	case 2:
+		a.IntrinsicGas = f.varint
+	case 3:
+		a.ExecutionGas = f.varint
+	case 4:
+		a.UnattributedGas = int64(f.varint)
+	}
+
+	return nil
+}
+
+// protoEncoder appends protobuf fields to a buffer. Following proto3, scalar
+// fields with their zero value are omitted.
+type protoEncoder struct {
+	buf []byte
+}
+
+func (e *protoEncoder) uint64(num protowire.Number, v uint64) {
+	if v == 0 {
+		return
+	}
+
+	e.buf = protowire.AppendTag(e.buf, num, protowire.VarintType)
+	e.buf = protowire.AppendVarint(e.buf, v)
+}
+
+// int64 encodes a signed value as a two's complement varint (proto int64).
+func (e *protoEncoder) int64(num protowire.Number, v int64) {
+	e.uint64(num, uint64(v))
+}
+
+func (e *protoEncoder) bool(num protowire.Number, v bool) {
+	if v {
+		e.uint64(num, 1)
+	}
+}
+
+func (e *protoEncoder) double(num protowire.Number, v float64) {
+	if v == 0 {
+		return
+	}
+
+	e.buf = protowire.AppendTag(e.buf, num, protowire.Fixed64Type)
+	e.buf = protowire.AppendFixed64(e.buf, math.Float64bits(v))
+}
+
+func (e *protoEncoder) string(num protowire.Number, v string) {
+	if v == "" {
+		return
+	}
+
+	e.buf = protowire.AppendTag(e.buf, num, protowire.BytesType)
+	e.buf = protowire.AppendString(e.buf, v)
+}
+
+// message encodes a nested message. It is written even when empty.
+func (e *protoEncoder) message(num protowire.Number, encode func(*protoEncoder)) {
+	var inner protoEncoder
+	encode(&inner)
+
+	e.buf = protowire.AppendTag(e.buf, num, protowire.BytesType)
+	e.buf = protowire.AppendBytes(e.buf, inner.buf)
+}
+
+// mapEntry encodes a map<string, message> entry (key = 1, value = 2).
+func (e *protoEncoder) mapEntry(num protowire.Number, key string, encodeValue func(*protoEncoder)) {
+	e.message(num, func(entry *protoEncoder) {
+		entry.string(1, key)
+		entry.message(2, encodeValue)
+	})
+}
+
+// protoField is a decoded protobuf field. Only the value matching its wire type is set.
+type protoField struct {
+	num     protowire.Number
+	varint  uint64
+	fixed64 uint64
+	bytes   []byte
+}
+
+// double returns a fixed64 field as a float64.
+func (f protoField) double() float64 {
+	return math.Float64frombits(f.fixed64)
+}
+
+// decodeProto calls fn for each field of an encoded message, in wire order.
+func decodeProto(b []byte, fn func(protoField) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := protoField{num: num}
+
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.fixed64, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeProtoMapEntry decodes a map<string, message> entry, passing the value's
+// fields to decodeValue, and returns the key.
+func decodeProtoMapEntry(b []byte, decodeValue func(protoField) error) (string, error) {
+	var key string
+
+	err := decodeProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			key = string(f.bytes)
+		case 2:
+			return decodeProto(f.bytes, decodeValue)
+		}
+
+		return nil
+	})
+
+	return key, err
+}
@@ -0,0 +1,384 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// fullBlockGasResult returns a result with every field set to a nonzero value, so
+// that a field missing from the protobuf mapping fails the round trip.
+func fullBlockGasResult() *SimulateBlockGasResult {
+	tx := func(i uint64) TxSummary {
+		return TxSummary{
+			Hash:                     "0xabc",
+			Index:                    i,
+			OriginalStatus:           "success",
+			SimulatedStatus:          "failed",
+			OriginalGas:              21000 + i,
+			SimulatedGas:             30000 + i,
+			DeltaPercent:             42.5,
+			Diverged:                 true,
+			OriginalReverts:          1,
+			SimulatedReverts:         2,
+			OriginalErrors:           []CallError{{Depth: 1, Type: "CALL", Error: "execution reverted", Address: "0x01"}},
+			SimulatedErrors:          []CallError{{Depth: 2, Type: "STATICCALL", Error: "out of gas", Address: "0x02"}},
+			OriginalPeakMemoryBytes:  64,
+			SimulatedPeakMemoryBytes: 96,
+			OriginalGasRemaining:     100,
+			SimulatedGasRemaining:    50,
+			OriginalExecError:        "execution reverted",
+			SimulatedExecError:       "out of gas",
+			Error:                    "intrinsic gas too low",
+			Panicked:                 true,
+			PanicMessage:             "boom",
+			FeeDelta:                 "-0x10",
+		}
+	}
+
+	accounting := func(base uint64) GasAccounting {
+		return GasAccounting{OpcodeGas: base, IntrinsicGas: base + 1, ExecutionGas: base + 2, UnattributedGas: -3}
+	}
+
+	return &SimulateBlockGasResult{
+		BlockNumber:  19000000,
+		Original:     BlockGasSummary{GasUsed: 1, GasLimit: 2, GasReverted: 3, WouldExceedLimit: true, TxCount: 4, AvgGasPerTx: 5},
+		Simulated:    BlockGasSummary{GasUsed: 6, GasLimit: 7, GasReverted: 8, WouldExceedLimit: true, TxCount: 9, AvgGasPerTx: 10},
+		Transactions: []TxSummary{tx(0), tx(1)},
+		OpcodeBreakdown: map[string]OpcodeSummary{
+			"SSTORE": {
+				OriginalCount: 1, OriginalGas: 2, SimulatedCount: 3, SimulatedGas: 4,
+				OriginalMinGas: 5, OriginalMaxGas: 6, SimulatedMinGas: 7, SimulatedMaxGas: 8,
+				OriginalGasPercent: 9.5, SimulatedGasPercent: 10.25,
+				OriginalGasStdDev: 11.5, SimulatedGasStdDev: 12.75,
+			},
+			"ADD": {OriginalCount: 11, OriginalGas: 33, SimulatedCount: 11, SimulatedGas: 33},
+		},
+		OpcodeBreakdownCSV: "opcode,originalCount\n",
+		EIPBreakdown:       map[string]EIPGas{"EIP-2929": {OriginalGas: 2100, SimulatedGas: 800}},
+		PresetKeys:         map[string][]string{"inline": {"ADD", "SSTORE_SET"}},
+		TopDeltas:          []TxSummary{tx(1)},
+		TopMovers:          []OpcodeDelta{{Opcode: "SSTORE", OriginalGas: 2, SimulatedGas: 4, Delta: 2, PercentChange: 100}},
+		GasPercentiles: GasPercentiles{
+			Original:  GasDistribution{P50: 21000, P90: 50000, P99: 90000, Max: 100000},
+			Simulated: GasDistribution{P50: 21000, P90: 60000, P99: 95000, Max: 120000},
+		},
+		BaseFee:           "0x7",
+		FeeDelta:          "0x8",
+		ChainConfigSource: chainConfigSourceDB,
+		Accounting:        BlockGasAccounting{Original: accounting(10), Simulated: accounting(20)},
+		Truncated:         true,
+		TruncationReason:  "too large",
+		GasLimitStop:      &GasLimitStop{TxIndex: 1, SimulatedGasUsed: 30001, SkippedTxs: 3},
+		ChainRules:        map[string]bool{"IsCancun": true, "IsPrague": false},
+	}
+}
+
+func TestSimulateBlockGasProtoRoundTrip(t *testing.T) {
+	want := fullBlockGasResult()
+
+	// Guard against fields added to the result without a protobuf mapping
+	v := reflect.ValueOf(*want)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsZero() {
+			t.Fatalf("fixture leaves %s unset", v.Type().Field(i).Name)
+		}
+	}
+
+	encoded := want.MarshalProto()
+
+	got, err := UnmarshalSimulateBlockGasProto(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip mismatch:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	// Map entries are encoded in key order
+	if again := fullBlockGasResult().MarshalProto(); !bytes.Equal(encoded, again) {
+		t.Error("encoding is not deterministic")
+	}
+}
+
+func TestSimulateBlockGasProtoEmpty(t *testing.T) {
+	want := &SimulateBlockGasResult{
+		Transactions:    []TxSummary{},
+		OpcodeBreakdown: map[string]OpcodeSummary{},
+	}
+
+	got, err := UnmarshalSimulateBlockGasProto(want.MarshalProto())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := UnmarshalSimulateBlockGasProto([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
+
+// TestSimulateBlockGasProtoSchema decodes the hand-written encoding with a message
+// built from simulation.proto, so a field number, wire type or field missing on
+// either side fails even where the hand-written decoder would agree with it.
+func TestSimulateBlockGasProtoSchema(t *testing.T) {
+	src, err := os.ReadFile("simulation.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := parseProtoSchema(string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := protodesc.NewFile(schema, new(protoregistry.Files))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := fullBlockGasResult()
+
+	msg := dynamicpb.NewMessage(file.Messages().ByName("SimulateBlockGasResult"))
+	if err := proto.Unmarshal(want.MarshalProto(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	compareProtoMessage(t, "SimulateBlockGasResult", msg, reflect.ValueOf(want))
+}
+
+// parseProtoSchema builds a file descriptor from the subset of proto3 used by
+// simulation.proto: messages of scalar, message, repeated and map<string, V> fields.
+func parseProtoSchema(src string) (*descriptorpb.FileDescriptorProto, error) {
+	file := &descriptorpb.FileDescriptorProto{
+		Name:   proto.String("simulation.proto"),
+		Syntax: proto.String("proto3"),
+	}
+
+	var msg *descriptorpb.DescriptorProto
+
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "syntax "):
+		case strings.HasPrefix(line, "package "):
+			file.Package = proto.String(strings.TrimSuffix(strings.TrimPrefix(line, "package "), ";"))
+		case strings.HasPrefix(line, "message "):
+			msg = &descriptorpb.DescriptorProto{Name: proto.String(strings.Fields(line)[1])}
+			file.MessageType = append(file.MessageType, msg)
+		case line == "}":
+			msg = nil
+		case msg != nil:
+			if err := parseProtoField(file.GetPackage(), msg, line); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+	}
+
+	return file, nil
+}
+
+// parseProtoField adds the field declared by line to msg. A map field also adds
+// its entry message.
+func parseProtoField(pkg string, msg *descriptorpb.DescriptorProto, line string) error {
+	decl, num, ok := strings.Cut(strings.TrimSuffix(line, ";"), " = ")
+	if !ok {
+		return fmt.Errorf("unexpected field %q", line)
+	}
+
+	number, err := strconv.ParseInt(num, 10, 32)
+	if err != nil {
+		return fmt.Errorf("field %q: %w", line, err)
+	}
+
+	if rest, ok := strings.CutPrefix(decl, "map<"); ok {
+		types, name, _ := strings.Cut(rest, "> ")
+		keyType, valueType, _ := strings.Cut(types, ", ")
+
+		// protoc names the entry message after the field, e.g. OpcodeBreakdownEntry
+		var entryName string
+		for _, part := range strings.Split(name, "_") {
+			entryName += strings.ToUpper(part[:1]) + part[1:]
+		}
+		entryName += "Entry"
+
+		msg.NestedType = append(msg.NestedType, &descriptorpb.DescriptorProto{
+			Name:    proto.String(entryName),
+			Field:   []*descriptorpb.FieldDescriptorProto{schemaField(pkg, "key", keyType, 1, false), schemaField(pkg, "value", valueType, 2, false)},
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		})
+
+		msg.Field = append(msg.Field, schemaField(pkg, name, msg.GetName()+"."+entryName, int32(number), true))
+
+		return nil
+	}
+
+	fields := strings.Fields(decl)
+	repeated := len(fields) == 3 && fields[0] == "repeated"
+	if repeated {
+		fields = fields[1:]
+	}
+
+	if len(fields) != 2 {
+		return fmt.Errorf("unexpected field %q", line)
+	}
+
+	msg.Field = append(msg.Field, schemaField(pkg, fields[1], fields[0], int32(number), repeated))
+
+	return nil
+}
+
+// protoScalarTypes maps the scalar types used by simulation.proto to their
+// descriptor types. Any other type names a message.
+var protoScalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"uint64": descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"double": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+}
+
+// schemaField returns the descriptor of a field of type typ.
+func schemaField(pkg, name, typ string, number int32, repeated bool) *descriptorpb.FieldDescriptorProto {
+	field := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+
+	if repeated {
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+
+	if scalar, ok := protoScalarTypes[typ]; ok {
+		field.Type = scalar.Enum()
+	} else {
+		field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		field.TypeName = proto.String("." + pkg + "." + typ)
+	}
+
+	return field
+}
+
+// compareProtoMessage checks that msg holds no fields unknown to the schema and
+// that each of its fields matches the Go struct field of the same name, ignoring
+// case and underscores.
+func compareProtoMessage(t *testing.T, path string, msg protoreflect.Message, v reflect.Value) {
+	t.Helper()
+
+	if unknown := msg.GetUnknown(); len(unknown) > 0 {
+		t.Errorf("%s: fields unknown to the schema: %x", path, unknown)
+	}
+
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	fields := msg.Descriptor().Fields()
+	if fields.Len() != v.NumField() {
+		t.Errorf("%s: schema has %d fields, Go struct %d", path, fields.Len(), v.NumField())
+	}
+
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		name := strings.ReplaceAll(string(fd.Name()), "_", "")
+
+		field := v.FieldByNameFunc(func(goName string) bool { return strings.EqualFold(goName, name) })
+		if !field.IsValid() {
+			t.Errorf("%s.%s: no matching Go field", path, fd.Name())
+			continue
+		}
+
+		compareProtoValue(t, path+"."+string(fd.Name()), fd, msg.Get(fd), field)
+	}
+}
+
+// compareProtoValue compares a field's value, element by element for lists and maps.
+func compareProtoValue(t *testing.T, path string, fd protoreflect.FieldDescriptor, pv protoreflect.Value, v reflect.Value) {
+	t.Helper()
+
+	switch {
+	case fd.IsMap():
+		m := pv.Map()
+		if m.Len() != v.Len() {
+			t.Errorf("%s: %d entries, want %d", path, m.Len(), v.Len())
+			return
+		}
+
+		for _, key := range v.MapKeys() {
+			mk := protoreflect.ValueOfString(key.String()).MapKey()
+			if !m.Has(mk) {
+				t.Errorf("%s: missing key %q", path, key.String())
+				continue
+			}
+
+			compareProtoElem(t, path+"["+key.String()+"]", fd.MapValue(), m.Get(mk), v.MapIndex(key))
+		}
+	case fd.IsList():
+		list := pv.List()
+		if list.Len() != v.Len() {
+			t.Errorf("%s: %d elements, want %d", path, list.Len(), v.Len())
+			return
+		}
+
+		for i := range list.Len() {
+			compareProtoElem(t, fmt.Sprintf("%s[%d]", path, i), fd, list.Get(i), v.Index(i))
+		}
+	default:
+		compareProtoElem(t, path, fd, pv, v)
+	}
+}
+
+// compareProtoElem compares a single message or scalar value.
+func compareProtoElem(t *testing.T, path string, fd protoreflect.FieldDescriptor, pv protoreflect.Value, v reflect.Value) {
+	t.Helper()
+
+	switch {
+	case fd.Message() != nil && v.Kind() == reflect.Slice:
+		// A list message (StringList) holds the Go slice in its only field
+		inner := fd.Message().Fields().Get(0)
+		compareProtoValue(t, path, inner, pv.Message().Get(inner), v)
+	case fd.Message() != nil:
+		compareProtoMessage(t, path, pv.Message(), v)
+	default:
+		if got, want := fmt.Sprint(pv.Interface()), fmt.Sprint(v.Interface()); got != want {
+			t.Errorf("%s = %s, want %s", path, got, want)
+		}
+	}
+}
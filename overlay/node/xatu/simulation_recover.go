@@ -0,0 +1,48 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// recoverExecutionPanic converts a panic during transaction execution into a failed
+// execution result. A bug in a custom gas function or the EVM integration then fails
+// a single transaction instead of aborting the whole simulation request.
+//
+// It must be deferred directly (defer s.recoverExecutionPanic(...)) for recover() to
+// take effect.
+func (s *Service) recoverExecutionPanic(txIndex int, result **executionResult, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	s.log.Error("Recovered panic during simulated execution",
+		"txIndex", txIndex, "panic", r, "stack", string(debug.Stack()))
+
+	*result = &executionResult{
+		Status:       "failed",
+		Err:          fmt.Errorf("execution panicked: %v", r),
+		Panicked:     true,
+		PanicMessage: fmt.Sprint(r),
+	}
+	*err = nil
+}
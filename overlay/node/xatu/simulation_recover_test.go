@@ -0,0 +1,88 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/erigontech/erigon/common/log/v3"
+)
+
+// TestRecoverExecutionPanic verifies that a panic during execution is contained
+// and reported as a failed result rather than propagated to the caller.
+func TestRecoverExecutionPanic(t *testing.T) {
+	s := &Service{log: log.Root()}
+
+	// Stands in for executeSingleTransaction with a gas function that panics
+	execute := func() (result *executionResult, err error) {
+		defer s.recoverExecutionPanic(7, &result, &err)
+
+		var schedule map[string]uint64
+		schedule["SLOAD_COLD"] = 1 // assignment to nil map panics
+
+		return &executionResult{Status: "success"}, nil
+	}
+
+	result, err := execute()
+	if err != nil {
+		t.Fatalf("expected panic to be converted to a result, got error: %v", err)
+	}
+
+	if result == nil || !result.Panicked {
+		t.Fatalf("expected Panicked result, got %+v", result)
+	}
+
+	if result.Status != "failed" {
+		t.Errorf("Status = %q, want %q", result.Status, "failed")
+	}
+
+	if !strings.Contains(result.PanicMessage, "nil map") {
+		t.Errorf("PanicMessage = %q, want it to mention the nil map", result.PanicMessage)
+	}
+
+	if result.Err == nil {
+		t.Error("expected Err to be set")
+	}
+
+	// No panic: result passes through untouched
+	passthrough := func() (result *executionResult, err error) {
+		defer s.recoverExecutionPanic(0, &result, &err)
+		return &executionResult{Status: "success"}, nil
+	}
+
+	result, err = passthrough()
+	if err != nil || result.Panicked || result.Status != "success" {
+		t.Errorf("unexpected result without panic: %+v, err=%v", result, err)
+	}
+}
+
+// TestTxSummaryEmptyPanicMessage checks that a recovered panic with an empty message
+// still marks the transaction summary as panicked.
+func TestTxSummaryEmptyPanicMessage(t *testing.T) {
+	dual := &dualExecutionResult{
+		Original:  &executionResult{Status: "success"},
+		Simulated: &executionResult{Status: "failed", Panicked: true},
+	}
+
+	summary := newTxSummary("0x01", 0, dual)
+	if !summary.Panicked {
+		t.Errorf("Panicked = false for a panic with an empty message")
+	}
+}
@@ -21,14 +21,20 @@ package xatu
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/erigontech/erigon/common"
 	"github.com/erigontech/erigon/db/kv"
 	"github.com/erigontech/erigon/db/kv/rawdbv3"
+	"github.com/erigontech/erigon/execution/chain"
 	"github.com/erigontech/erigon/execution/protocol"
+	erigonstate "github.com/erigontech/erigon/execution/state"
 	erigontypes "github.com/erigontech/erigon/execution/types"
+	"github.com/erigontech/erigon/execution/types/accounts"
 	"github.com/erigontech/erigon/execution/vm"
+	"github.com/erigontech/erigon/execution/vm/evmtypes"
 	"github.com/erigontech/erigon/rpc/transactions"
+	"github.com/holiman/uint256"
 )
 
 // SimulateBlockGasRequest is the request for xatu_simulateBlockGas.
@@ -36,13 +42,126 @@ type SimulateBlockGasRequest struct {
 	BlockNumber uint64             `json:"blockNumber"`
 	GasSchedule *CustomGasSchedule `json:"gasSchedule"`
 	MaxGasLimit bool               `json:"maxGasLimit"`
+	// EnforceBalanceCheck keeps the sender balance check with MaxGasLimit. By default
+	// MaxGasLimit skips it, since the sender paid for the original gas limit, not the
+	// block's; with it set, senders that cannot afford a block-gas-limit-sized budget
+	// fail pre-execution ("insufficient funds") as they would on chain. It has no
+	// effect without MaxGasLimit, and a CalldataOverride still skips the check.
+	EnforceBalanceCheck bool `json:"enforceBalanceCheck,omitempty"`
+	// EnforceGasCap sets whether the simulated execution checks the transaction's
+	// gas limit against the EIP-7825 cap (Osaka onwards). Unset keeps the default:
+	// checked, except under MaxGasLimit, whose block-sized gas limit would exceed it.
+	// With MaxGasLimit, true makes transactions fail pre-execution when the block
+	// gas limit is above the cap.
+	EnforceGasCap *bool `json:"enforceGasCap,omitempty"`
+	// ChainConfigOverride replaces the node's chain config for fork rules and EVM
+	// construction in both executions (e.g. to activate a fork at a different block).
+	ChainConfigOverride *chain.Config `json:"chainConfigOverride,omitempty"`
+	// DisableAccessList runs the simulated execution with EIP-2929 disabled: state
+	// access opcodes use pre-Berlin flat costs with no cold/warm distinction. The
+	// block's refund rules are kept; combine with LegacyRefunds for Istanbul's.
+	DisableAccessList bool `json:"disableAccessList,omitempty"`
+	// LegacyRefunds runs the simulated execution with the pre-London refunds of
+	// EIP-2200 and EIP-2929, reverting EIP-3529: a larger SSTORE clearing refund, the
+	// SELFDESTRUCT refund and a refund cap of gas used / 2 (unless REFUND_CAP_DIV is
+	// set). Pre-London blocks already use these refunds. With DisableAccessList, the
+	// pre-Berlin SSTORE and SELFDESTRUCT refunds are used.
+	LegacyRefunds bool `json:"legacyRefunds,omitempty"`
+	// ForceAllCold charges every storage slot and account access in the simulated
+	// execution the EIP-2929 cold cost, even when it is already warm (accessed
+	// earlier, declared in the access list, or the sender, recipient or a
+	// precompile), as a worst case for cold-access repricings. No effect before
+	// Berlin or with DisableAccessList.
+	ForceAllCold bool `json:"forceAllCold,omitempty"`
+	// DisabledPrecompiles lists precompiles (by address or name, e.g. "0x01" or
+	// "ECREC") that execute as regular accounts in the simulated execution.
+	DisabledPrecompiles []string `json:"disabledPrecompiles,omitempty"`
+	// PrecompileOverrides moves precompiles (by address or name) to a new address
+	// in the simulated execution; the original address becomes a regular account.
+	PrecompileOverrides map[string]string `json:"precompileOverrides,omitempty"`
+	// TopN, when > 0, adds the N transactions with the largest absolute gas delta
+	// to the result as TopDeltas.
+	TopN int `json:"topN,omitempty"`
+	// Format selects how the opcode breakdown is returned: "json" (default) or
+	// "csv", which replaces OpcodeBreakdown with OpcodeBreakdownCSV.
+	Format string `json:"format,omitempty"`
+	// TxIndices limits the simulation to these transactions (by index in the block).
+	// Empty simulates every transaction. Block totals cover the selected ones only.
+	TxIndices []uint64 `json:"txIndices,omitempty"`
+	// TxOrder executes the block's transactions in this order, given as a
+	// permutation of all transaction indices. State is threaded through the new
+	// order, so storage writes (and the costs that depend on them) move with it.
+	// Cannot be combined with TxIndices.
+	TxOrder []uint64 `json:"txOrder,omitempty"`
+	// CompactSchedule is a gas schedule in the compact form produced by EncodeCompact,
+	// as an alternative to GasSchedule for schedules shared via URLs. Setting both
+	// is an error.
+	CompactSchedule string `json:"compactSchedule,omitempty"`
+	// Presets lists NamedSchedules entries to apply, merged in order with the
+	// GasSchedule (or CompactSchedule) overrides applied last, so later sources win.
+	Presets []string `json:"presets,omitempty"`
+	// BaseSchedule names a fork (e.g. "london") or preset whose full schedule the
+	// presets and GasSchedule are layered on, to simulate from another fork's
+	// defaults rather than the block's (see resolveBaseSchedule). A fork before
+	// Berlin or London also enables DisableAccessList or LegacyRefunds.
+	BaseSchedule string `json:"baseSchedule,omitempty"`
+	// PCOverrides scopes gas overrides to program counters: PCOverrides[pc] applies
+	// only to the opcode executed at that PC (decimal in JSON), in any frame of the
+	// simulated execution. Keys are opcode names, replacing the constant gas, or
+	// dynamic gas keys such as SSTORE_SET, layered on top of GasSchedule. Opt-in, as
+	// tracking the PC adds a function call to every opcode.
+	PCOverrides map[uint32]map[string]uint64 `json:"pcOverrides,omitempty"`
+	// StopIfExceedsLimit stops the simulation after the transaction that pushes the
+	// simulated gas over the block gas limit, returning a partial result (see
+	// SimulateBlockGasResult.GasLimitStop). Useful to screen repricings that break
+	// blocks without re-executing the rest of each block.
+	StopIfExceedsLimit bool `json:"stopIfExceedsLimit,omitempty"`
+}
+
+// options returns the execution options for the simulated execution.
+func (r SimulateBlockGasRequest) options() executionOptions {
+	return executionOptions{
+		GasSchedule:         r.GasSchedule,
+		MaxGasLimit:         r.MaxGasLimit,
+		EnforceBalanceCheck: r.EnforceBalanceCheck,
+		EnforceGasCap:       r.EnforceGasCap,
+		ChainConfig:         r.ChainConfigOverride,
+
+		DisableAccessList: r.DisableAccessList,
+		LegacyRefunds:     r.LegacyRefunds,
+		ForceAllCold:      r.ForceAllCold,
+		Precompiles: precompileOverrides{
+			Disabled: r.DisabledPrecompiles,
+			Moved:    r.PrecompileOverrides,
+		},
+
+		PCOverrides: r.PCOverrides,
+	}
 }
 
 // BlockGasSummary summarizes gas usage for a block.
 type BlockGasSummary struct {
-	GasUsed          uint64 `json:"gasUsed"`
-	GasLimit         uint64 `json:"gasLimit"`
+	GasUsed  uint64 `json:"gasUsed"`
+	GasLimit uint64 `json:"gasLimit"`
+	// GasReverted is the part of GasUsed consumed by failed (reverted) transactions.
+	// Their state changes roll back, but the gas is still consumed.
+	GasReverted      uint64 `json:"gasReverted"`
 	WouldExceedLimit bool   `json:"wouldExceedLimit"`
+	// TxCount is the number of transactions simulated and AvgGasPerTx their mean
+	// gas used (0 for a block without transactions).
+	TxCount     uint64 `json:"txCount"`
+	AvgGasPerTx uint64 `json:"avgGasPerTx"`
+}
+
+// addTransaction accumulates a transaction's gas into the block summary.
+func (b *BlockGasSummary) addTransaction(gasUsed uint64, status string) {
+	b.GasUsed += gasUsed
+	if status == "failed" {
+		b.GasReverted += gasUsed
+	}
+
+	b.TxCount++
+	b.AvgGasPerTx = b.GasUsed / b.TxCount
 }
 
 // TxSummary summarizes gas impact for a single transaction.
@@ -59,9 +178,29 @@ type TxSummary struct {
 	SimulatedReverts uint64      `json:"simulatedReverts"`
 	OriginalErrors   []CallError `json:"originalErrors"`
 	SimulatedErrors  []CallError `json:"simulatedErrors"`
+	// OriginalPeakMemoryBytes and SimulatedPeakMemoryBytes are the largest memory
+	// size reached by any call frame (see TxGasDetail.PeakMemoryBytes).
+	OriginalPeakMemoryBytes  uint64 `json:"originalPeakMemoryBytes"`
+	SimulatedPeakMemoryBytes uint64 `json:"simulatedPeakMemoryBytes"`
+	// OriginalGasRemaining and SimulatedGasRemaining are the gas returned to the
+	// sender (see TxGasDetail.GasRemaining).
+	OriginalGasRemaining  uint64 `json:"originalGasRemaining"`
+	SimulatedGasRemaining uint64 `json:"simulatedGasRemaining"`
+	// OriginalExecError and SimulatedExecError are the top-level EVM errors (e.g.
+	// "execution reverted" or "out of gas"), as opposed to nested call errors and
+	// pre-execution errors.
+	OriginalExecError  string `json:"originalExecError,omitempty"`
+	SimulatedExecError string `json:"simulatedExecError,omitempty"`
 	// Error is set when execution fails before the EVM runs (e.g. intrinsic gas too low).
 	// It captures the pre-execution error that ApplyMessage returns.
 	Error string `json:"error,omitempty"`
+	// Panicked is set when either execution panicked. The panic is contained to this
+	// transaction and the rest of the block is still simulated.
+	Panicked     bool   `json:"panicked,omitempty"`
+	PanicMessage string `json:"panicMessage,omitempty"`
+	// FeeDelta is the estimated change in fees from the gas delta at the block's
+	// base fee (hex-encoded wei, negative when cheaper). Empty for pre-London blocks.
+	FeeDelta string `json:"feeDelta,omitempty"`
 }
 
 // SimulateBlockGasResult is the result of xatu_simulateBlockGas.
@@ -71,6 +210,49 @@ type SimulateBlockGasResult struct {
 	Simulated       BlockGasSummary          `json:"simulated"`
 	Transactions    []TxSummary              `json:"transactions"`
 	OpcodeBreakdown map[string]OpcodeSummary `json:"opcodeBreakdown"`
+	// OpcodeBreakdownCSV is the opcode breakdown as CSV (columns: opcode,
+	// originalCount, originalGas, simulatedCount, simulatedGas, deltaGas).
+	// Only set when the request's Format is "csv".
+	OpcodeBreakdownCSV string `json:"opcodeBreakdownCsv,omitempty"`
+	// EIPBreakdown re-buckets the opcode breakdown by the EIP that defines each
+	// cost (e.g. cold and warm access under EIP-2929), for educational tooling.
+	EIPBreakdown map[string]EIPGas `json:"eipBreakdown,omitempty"`
+	// PresetKeys lists, per preset (and "inline" for the request's own schedule),
+	// the keys whose value in the merged schedule it supplied. Only set with Presets.
+	PresetKeys map[string][]string `json:"presetKeys,omitempty"`
+	// TopDeltas holds the most affected transactions, sorted by absolute gas
+	// delta (largest first). Only set when the request's TopN is > 0.
+	TopDeltas []TxSummary `json:"topDeltas,omitempty"`
+	// TopMovers lists the opcodes whose gas changed, sorted by absolute gas delta
+	// (largest first). Opcodes whose gas was unaffected are left out.
+	TopMovers []OpcodeDelta `json:"topMovers,omitempty"`
+	// GasPercentiles is the distribution of per-transaction gas under the original
+	// and simulated pricing.
+	GasPercentiles GasPercentiles `json:"gasPercentiles"`
+	// BaseFee is the block's base fee per gas (hex-encoded wei), empty for
+	// pre-London blocks. Simulations report gas units and do not enforce the
+	// base fee; it is only used to derive the fee deltas below.
+	BaseFee string `json:"baseFee,omitempty"`
+	// FeeDelta is the sum of the transactions' fee deltas (hex-encoded wei).
+	FeeDelta string `json:"feeDelta,omitempty"`
+	// ChainConfigSource is the chain config the simulation used for fork rules:
+	// "database", "memory" (the DB read failed) or "override" (see ChainConfigStatus).
+	ChainConfigSource string `json:"chainConfigSource"`
+	// ChainRules are the fork rule flags the simulation ran under, keyed by
+	// chain.Rules field name (e.g. "IsCancun").
+	ChainRules map[string]bool `json:"chainRules"`
+	// Accounting checks that the opcode breakdown accounts for the gas charged by
+	// the EVM: sum(opcode gas) + TX_INTRINSIC gas == intrinsic + execution gas.
+	Accounting BlockGasAccounting `json:"accounting"`
+	// Truncated is set when the response exceeded the service's MaxResponseBytes and
+	// per-transaction details (then opcode breakdown entries) were dropped. Block
+	// totals are always complete.
+	Truncated        bool   `json:"truncated,omitempty"`
+	TruncationReason string `json:"truncationReason,omitempty"`
+	// GasLimitStop is set when StopIfExceedsLimit stopped the simulation early. The
+	// result then only covers the transactions up to GasLimitStop.TxIndex, and
+	// Truncated is set.
+	GasLimitStop *GasLimitStop `json:"gasLimitStop,omitempty"`
 }
 
 // SimulateTransactionGasRequest is the request for xatu_simulateTransactionGas.
@@ -79,6 +261,104 @@ type SimulateTransactionGasRequest struct {
 	BlockNumber     uint64             `json:"blockNumber"`
 	GasSchedule     *CustomGasSchedule `json:"gasSchedule"`
 	MaxGasLimit     bool               `json:"maxGasLimit"`
+	// EnforceBalanceCheck keeps the sender balance check with MaxGasLimit (see
+	// SimulateBlockGasRequest.EnforceBalanceCheck).
+	EnforceBalanceCheck bool `json:"enforceBalanceCheck,omitempty"`
+	// EnforceGasCap sets whether the simulated execution checks the EIP-7825 gas
+	// cap (see SimulateBlockGasRequest.EnforceGasCap).
+	EnforceGasCap *bool `json:"enforceGasCap,omitempty"`
+	// ChainConfigOverride replaces the node's chain config for fork rules and EVM
+	// construction in both executions (e.g. to activate a fork at a different block).
+	ChainConfigOverride *chain.Config `json:"chainConfigOverride,omitempty"`
+	// DisableAccessList runs the simulated execution with EIP-2929 disabled: state
+	// access opcodes use pre-Berlin flat costs with no cold/warm distinction.
+	DisableAccessList bool `json:"disableAccessList,omitempty"`
+	// LegacyRefunds reverts the EIP-3529 refund changes in the simulated execution
+	// (see SimulateBlockGasRequest.LegacyRefunds).
+	LegacyRefunds bool `json:"legacyRefunds,omitempty"`
+	// ForceAllCold charges every state access the cold cost in the simulated
+	// execution (see SimulateBlockGasRequest.ForceAllCold).
+	ForceAllCold bool `json:"forceAllCold,omitempty"`
+	// DisabledPrecompiles lists precompiles (by address or name, e.g. "0x01" or
+	// "ECREC") that execute as regular accounts in the simulated execution.
+	DisabledPrecompiles []string `json:"disabledPrecompiles,omitempty"`
+	// PrecompileOverrides moves precompiles (by address or name) to a new address
+	// in the simulated execution; the original address becomes a regular account.
+	PrecompileOverrides map[string]string `json:"precompileOverrides,omitempty"`
+	// IncludeOpcodePairs adds the adjacent opcode pairs with the most gas to the
+	// result. Off by default since it adds a map update to every opcode.
+	IncludeOpcodePairs bool `json:"includeOpcodePairs,omitempty"`
+	// IncludeLogs adds the logs emitted by each execution, with the gas charged to
+	// each LOG opcode, to the result.
+	IncludeLogs bool `json:"includeLogs,omitempty"`
+	// IncludeStorageChanges adds each execution's SSTOREs, with the slot's original,
+	// current and new values and the gas branch taken (see StorageChange).
+	IncludeStorageChanges bool `json:"includeStorageChanges,omitempty"`
+	// IncludePrecompileCalls adds each execution's precompile calls, with their input
+	// size and computed gas (see PrecompileCall).
+	IncludePrecompileCalls bool `json:"includePrecompileCalls,omitempty"`
+	// IncludeReceipt reads the transaction's on-chain receipt and compares its log
+	// count and gas used with the original execution, to detect divergence.
+	IncludeReceipt bool `json:"includeReceipt,omitempty"`
+	// IncludeTracerStats adds each tracer's own overhead to the result, to diagnose
+	// whether a slow request spends its time in the EVM or in the tracer.
+	IncludeTracerStats bool `json:"includeTracerStats,omitempty"`
+	// IncludeAccessListValue adds an estimate of whether the transaction's declared
+	// access list saved more gas than it cost (see AccessListValue).
+	IncludeAccessListValue bool `json:"includeAccessListValue,omitempty"`
+	// IncludeWarmAccessOrigins counts each execution's warm accesses by whether the
+	// transaction's access list declared them or execution warmed them earlier.
+	IncludeWarmAccessOrigins bool `json:"includeWarmAccessOrigins,omitempty"`
+	// IncludeCallTree adds each execution's nested call tree, with every frame's
+	// type, target, gas used and status (see CallFrame).
+	IncludeCallTree bool `json:"includeCallTree,omitempty"`
+	// IncludeGasStdDev adds the standard deviation of each opcode's charges to the
+	// opcode breakdown, e.g. to see how often SLOAD is cold rather than warm.
+	IncludeGasStdDev bool `json:"includeGasStdDev,omitempty"`
+	// SampleRate, when > 1, records only every SampleRate-th opcode in the breakdown
+	// and scales it up to estimate totals, for very large transactions. Gas used and
+	// status are always exact. At most maxSampleRate.
+	SampleRate int `json:"sampleRate,omitempty"`
+	// CalldataOverride replaces the transaction's calldata (hex) in both executions,
+	// keeping the sender, value, target and state, e.g. to study how gas varies with
+	// the input to the same contract.
+	CalldataOverride *string `json:"calldataOverride,omitempty"`
+	// CompactSchedule is a gas schedule in the compact form produced by EncodeCompact,
+	// as an alternative to GasSchedule for schedules shared via URLs. Setting both
+	// is an error.
+	CompactSchedule string `json:"compactSchedule,omitempty"`
+	// Presets lists NamedSchedules entries to apply, merged in order with the
+	// GasSchedule (or CompactSchedule) overrides applied last, so later sources win.
+	Presets []string `json:"presets,omitempty"`
+	// BaseSchedule names a fork (e.g. "london") or preset whose full schedule the
+	// presets and GasSchedule are layered on, to simulate from another fork's
+	// defaults rather than the block's (see resolveBaseSchedule). A fork before
+	// Berlin or London also enables DisableAccessList or LegacyRefunds.
+	BaseSchedule string `json:"baseSchedule,omitempty"`
+	// PCOverrides scopes gas overrides to program counters (see
+	// SimulateBlockGasRequest.PCOverrides).
+	PCOverrides map[uint32]map[string]uint64 `json:"pcOverrides,omitempty"`
+}
+
+// options returns the execution options for the simulated execution.
+func (r SimulateTransactionGasRequest) options() executionOptions {
+	return executionOptions{
+		GasSchedule:         r.GasSchedule,
+		MaxGasLimit:         r.MaxGasLimit,
+		EnforceBalanceCheck: r.EnforceBalanceCheck,
+		EnforceGasCap:       r.EnforceGasCap,
+		ChainConfig:         r.ChainConfigOverride,
+
+		DisableAccessList: r.DisableAccessList,
+		LegacyRefunds:     r.LegacyRefunds,
+		ForceAllCold:      r.ForceAllCold,
+		Precompiles: precompileOverrides{
+			Disabled: r.DisabledPrecompiles,
+			Moved:    r.PrecompileOverrides,
+		},
+
+		PCOverrides: r.PCOverrides,
+	}
 }
 
 // TxGasDetail provides detailed gas breakdown for a transaction.
@@ -86,6 +366,46 @@ type TxGasDetail struct {
 	GasUsed      uint64 `json:"gasUsed"`
 	IntrinsicGas uint64 `json:"intrinsicGas"`
 	ExecutionGas uint64 `json:"executionGas"`
+	// CalldataGas is the part of IntrinsicGas charged for calldata: TX_DATA_ZERO per
+	// zero byte plus TX_DATA_NONZERO per non-zero byte.
+	CalldataGas uint64 `json:"calldataGas"`
+	// PeakMemoryBytes is the largest memory size reached by any call frame. Memory
+	// expansion gas is quadratic in it, so high values mark memory-bound transactions.
+	PeakMemoryBytes uint64 `json:"peakMemoryBytes"`
+	// GasLimit is the gas limit the transaction executed with (the block gas limit
+	// with MaxGasLimit), and GasRemaining the part of it returned to the sender, so
+	// that GasLimit = GasUsed + GasRemaining.
+	GasLimit     uint64 `json:"gasLimit"`
+	GasRemaining uint64 `json:"gasRemaining"`
+	// BlobGasUsed is the blob gas of a blob transaction under the executed blob
+	// pricing (see the BLOB_* keys), charged at BlobBaseFee (hex-encoded wei) for a
+	// BlobFee of BlobGasUsed * BlobBaseFee. Unset for transactions without blobs.
+	BlobGasUsed uint64 `json:"blobGasUsed,omitempty"`
+	BlobBaseFee string `json:"blobBaseFee,omitempty"`
+	BlobFee     string `json:"blobFee,omitempty"`
+}
+
+// newTxGasDetail splits an execution's gas into intrinsic and execution gas.
+// GasUsed from ApplyMessage already includes intrinsic gas. Execution gas is
+// clamped to 0 when GasUsed < IntrinsicGas, which happens when a tx fails
+// pre-execution (e.g. intrinsic gas too low).
+func newTxGasDetail(r *executionResult) TxGasDetail {
+	detail := TxGasDetail{
+		GasUsed:         r.GasUsed,
+		IntrinsicGas:    r.IntrinsicGas,
+		CalldataGas:     r.CalldataGas,
+		PeakMemoryBytes: r.PeakMemory,
+		GasLimit:        r.GasLimit,
+		GasRemaining:    r.gasRemaining(),
+	}
+
+	if r.GasUsed > r.IntrinsicGas {
+		detail.ExecutionGas = r.GasUsed - r.IntrinsicGas
+	}
+
+	detail.setBlobGas(r)
+
+	return detail
 }
 
 // SimulateTransactionGasResult is the result of xatu_simulateTransactionGas.
@@ -96,27 +416,169 @@ type SimulateTransactionGasResult struct {
 	Original        TxGasDetail              `json:"original"`
 	Simulated       TxGasDetail              `json:"simulated"`
 	OpcodeBreakdown map[string]OpcodeSummary `json:"opcodeBreakdown"`
+	AccessListDiff  *AccessListDiff          `json:"accessListDiff,omitempty"`
+	// ColdAccessCount is the number of accesses the original execution made that
+	// are cold under EIP-2929 (Berlin+) rules: first accesses to distinct addresses
+	// and storage slots that were not warm from the start of the transaction.
+	// Pre-Berlin all accesses cost the same, so this is the number of accesses
+	// EIP-2929 repriced, independent of the gas values.
+	ColdAccessCount uint64 `json:"coldAccessCount"`
+	// TopMovers lists the opcodes whose gas changed, sorted by absolute gas delta
+	// (largest first). Opcodes whose gas was unaffected are left out.
+	TopMovers []OpcodeDelta `json:"topMovers,omitempty"`
+	// EIPBreakdown re-buckets the opcode breakdown by the EIP that defines each
+	// cost (e.g. cold and warm access under EIP-2929), for educational tooling.
+	EIPBreakdown map[string]EIPGas `json:"eipBreakdown,omitempty"`
+	// PresetKeys lists, per preset (and "inline" for the request's own schedule),
+	// the keys whose value in the merged schedule it supplied. Only set with Presets.
+	PresetKeys map[string][]string `json:"presetKeys,omitempty"`
+	// CallClassification counts CALL-family opcodes by target: self, warm or cold.
+	CallClassification *CallClassifications `json:"callClassification,omitempty"`
+	// WarmAccessOrigins counts warm accesses by origin (see IncludeWarmAccessOrigins).
+	WarmAccessOrigins *WarmAccessOrigins `json:"warmAccessOrigins,omitempty"`
+	// CallTree is each execution's nested call tree (see IncludeCallTree).
+	CallTree *CallTrees `json:"callTree,omitempty"`
+	// OpcodePairs lists the adjacent opcode pairs with the most gas (see IncludeOpcodePairs).
+	OpcodePairs *OpcodePairs `json:"opcodePairs,omitempty"`
+	// ContractBreakdown is the gas used per contract address, keyed by the address
+	// whose code ran in each frame.
+	ContractBreakdown map[string]ContractGas `json:"contractBreakdown,omitempty"`
+	// Sender is the sender's nonce and balance before the transaction executed.
+	Sender *SenderState `json:"sender,omitempty"`
+	// FirstSeenPC is the PC at which each opcode was first executed in the original
+	// execution, to correlate the breakdown with locations in the bytecode.
+	FirstSeenPC map[string]uint32 `json:"firstSeenPC,omitempty"`
+	// Logs are the logs emitted by each execution (see IncludeLogs).
+	Logs *EmittedLogs `json:"logs,omitempty"`
+	// StorageChanges are the SSTOREs of each execution (see IncludeStorageChanges).
+	StorageChanges *StorageChanges `json:"storageChanges,omitempty"`
+	// PrecompileCalls are the precompile calls of each execution (see IncludePrecompileCalls).
+	PrecompileCalls *PrecompileCalls `json:"precompileCalls,omitempty"`
+	// Receipt compares the original execution with the on-chain receipt (see IncludeReceipt).
+	Receipt *ReceiptComparison `json:"receipt,omitempty"`
+	// TracerStats is debug output on the tracers' overhead (see IncludeTracerStats).
+	TracerStats *TracerStatsComparison `json:"tracerStats,omitempty"`
+	// ChainConfigSource is the chain config the simulation used for fork rules:
+	// "database", "memory" (the DB read failed) or "override" (see ChainConfigStatus).
+	ChainConfigSource string `json:"chainConfigSource"`
+	// ChainRules are the fork rule flags the simulation ran under, keyed by
+	// chain.Rules field name (e.g. "IsCancun").
+	ChainRules map[string]bool `json:"chainRules"`
+	// AccessListValue weighs the declared access list's intrinsic cost against the
+	// cold-access gas it saved (see IncludeAccessListValue). Nil when the
+	// transaction has no access list.
+	AccessListValue *AccessListAnalysis `json:"accessListValue,omitempty"`
+	// Sampled is set when the opcode breakdown was estimated from every
+	// SampleRate-th opcode and is approximate.
+	Sampled    bool `json:"sampled,omitempty"`
+	SampleRate int  `json:"sampleRate,omitempty"`
 }
 
 // executionResult holds the result of a single EVM execution.
 type executionResult struct {
 	GasUsed      uint64
+	GasLimit     uint64 // Gas limit of the executed message
 	IntrinsicGas uint64
-	Err          error // EVM execution error (from ExecResult.Err)
-	ApplyErr     error // Pre-execution error (from ApplyMessage return, e.g. intrinsic gas too low)
+	CalldataGas  uint64 // Calldata part of IntrinsicGas
+	FloorGas     uint64 // EIP-7623 calldata floor (0 before Prague)
+	Err          error  // EVM execution error (from ExecResult.Err)
+	ApplyErr     error  // Pre-execution error (from ApplyMessage return, e.g. intrinsic gas too low)
 	Status       string
-	RevertCount  uint64      // Number of REVERT opcodes executed (includes nested calls)
-	OpcodeCount  uint64      // Total number of opcodes executed
-	CallErrors   []CallError // Errors from nested calls
+	RevertCount  uint64       // Number of REVERT opcodes executed (includes nested calls)
+	OpcodeCount  uint64       // Total number of opcodes executed
+	PeakMemory   uint64       // Largest memory size reached by any call frame, in bytes
+	CallErrors   []CallError  // Errors from nested calls
+	Panicked     bool         // True if execution panicked (recovered)
+	PanicMessage string       // Recovered panic value
+	Sender       *SenderState // Sender state before execution (nil unless CaptureSenderState is enabled)
+	BlobGasUsed  uint64       // Blob gas used under the executed blob pricing
+	BlobBaseFee  *uint256.Int // Blob base fee the blob gas was priced at (nil before Cancun)
+
+	// Refund inputs, used to re-apply the refund cap with a custom divisor
+	ExecutionGas   uint64 // Gas used by the top-level frame, before refunds
+	Refund         uint64 // Refund counter at the end of execution, before capping
+	RefundQuotient uint64 // Refund cap divisor applied by the EVM
 }
 
 // SimulateBlockGas re-executes a block with a custom gas schedule.
-// It runs two parallel EVM executions per transaction: one with standard gas costs
+// It runs two EVM executions per transaction: one with standard gas costs
 // and one with the custom gas schedule. This ensures accurate gas accounting.
+//
+// Identical requests produce identical results: transactions execute sequentially,
+// totals are integer sums and DeltaPercent is computed from integer gas values. The
+// only intentional source of variation is the node's view of the chain, e.g. a block
+// that is reorged between two requests.
 func (s *Service) SimulateBlockGas(
 	ctx context.Context,
 	req SimulateBlockGasRequest,
 ) (*SimulateBlockGasResult, error) {
+	s.log.Debug("Simulating block gas", "block", req.BlockNumber,
+		"overrides", requestOverrideKeys(req.GasSchedule, req.CompactSchedule), "presets", req.Presets)
+
+	start := time.Now()
+	result, err := s.simulateBlockGas(ctx, req)
+	s.logBlockSimulation(req, result, time.Since(start), err)
+
+	return result, err
+}
+
+// simulateBlockGas implements SimulateBlockGas.
+func (s *Service) simulateBlockGas(
+	ctx context.Context,
+	req SimulateBlockGasRequest,
+) (*SimulateBlockGasResult, error) {
+	if err := validateChainConfigOverride(req.ChainConfigOverride); err != nil {
+		return nil, err
+	}
+
+	if err := validateBreakdownFormat(req.Format); err != nil {
+		return nil, err
+	}
+
+	// Normalize to the JSON form, so both forms share cache entries
+	schedule, err := resolveGasSchedule(req.GasSchedule, req.CompactSchedule)
+	if err != nil {
+		return nil, err
+	}
+	req.GasSchedule, req.CompactSchedule = schedule, ""
+
+	// Presets stay in the request, so the cache key still tells merged and inline
+	// schedules apart (their PresetKeys differ)
+	schedule, presetKeys, err := resolvePresets(req.Presets, req.GasSchedule)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err = resolveBaseSchedule(req.BaseSchedule, schedule)
+	if err != nil {
+		return nil, err
+	}
+	req.GasSchedule = schedule
+
+	// A pre-Berlin or pre-London base prices the fork's access and refund rules, so
+	// it runs under them (see baseScheduleModes)
+	disableAccessList, legacyRefunds := baseScheduleModes(req.BaseSchedule)
+	req.DisableAccessList = req.DisableAccessList || disableAccessList
+	req.LegacyRefunds = req.LegacyRefunds || legacyRefunds
+
+	opts := req.options()
+	if err := opts.Precompiles.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := validatePCOverrides(opts.PCOverrides); err != nil {
+		return nil, err
+	}
+
+	if err := opts.GasSchedule.validateRelativeOverrides(); err != nil {
+		return nil, err
+	}
+
+	cacheKey, err := newResultCacheKey(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash request: %w", err)
+	}
+
 	tx, err := s.db.BeginTemporalRo(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
@@ -134,8 +596,41 @@ func (s *Service) SimulateBlockGas(
 	}
 
 	header := block.Header()
+
+	if err := s.checkSupportedFork(ctx, opts, req.BlockNumber, header.Time); err != nil {
+		return nil, err
+	}
+
+	if err := s.resolveRelativeOverrides(ctx, &opts, req.BlockNumber, header.Time); err != nil {
+		return nil, err
+	}
+
+	if cached, ok := s.resultCache.get(cacheKey, block.Hash()); ok {
+		return cached, nil
+	}
+
+	txs := block.Transactions()
+
+	txIndices, err := selectTransactions(req.TxIndices, len(txs))
+	if err != nil {
+		return nil, err
+	}
+
 	txNumReader := s.blockReader.TxnumReader()
 
+	// A custom order threads state between transactions instead of reading the
+	// historical state at each transaction's position
+	var ordered *orderedExecutor
+	if len(req.TxOrder) > 0 {
+		txIndices, err = orderTransactions(req.TxOrder, req.TxIndices, len(txs))
+		if err != nil {
+			return nil, err
+		}
+
+		ordered = s.newOrderedExecutor(ctx, header, block, txNumReader)
+		defer ordered.close()
+	}
+
 	// Initialize result
 	result := &SimulateBlockGasResult{
 		BlockNumber: req.BlockNumber,
@@ -145,87 +640,57 @@ func (s *Service) SimulateBlockGas(
 		Simulated: BlockGasSummary{
 			GasLimit: header.GasLimit,
 		},
-		Transactions:    make([]TxSummary, 0, len(block.Transactions())),
+		Transactions:    make([]TxSummary, 0, len(txIndices)),
 		OpcodeBreakdown: make(map[string]OpcodeSummary, 64),
+		PresetKeys:      presetKeys,
 	}
 
-	// Execute each transaction with dual parallel execution
-	for txIndex, txn := range block.Transactions() {
-		// Run both executions in parallel
-		dualResult, err := s.executeTransactionDual(
-			ctx, tx, header, block, txIndex, txNumReader, req.GasSchedule, req.MaxGasLimit,
-		)
+	// Execute each selected transaction in order; the executions are sequential, so
+	// results do not depend on goroutine scheduling
+	for i, txIndex := range txIndices {
+		txn := txs[txIndex]
+
+		var dualResult *dualExecutionResult
+		if ordered != nil {
+			dualResult, err = runDualExecution(opts, SimulationTracerConfig{}, ordered.executeFunc(txIndex))
+		} else {
+			dualResult, err = s.executeTransactionDual(
+				ctx, tx, header, block, txIndex, txNumReader, opts, SimulationTracerConfig{},
+			)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute tx %d: %w", txIndex, err)
 		}
 
-		// GasUsed from ApplyMessage already includes intrinsic gas
-		originalGas := dualResult.Original.GasUsed
-		simulatedGas := dualResult.Simulated.GasUsed
+		result.addDualResult(txn.Hash().Hex(), txIndex, dualResult)
 
-		// Calculate delta percent
-		var deltaPercent float64
-		if originalGas > 0 {
-			deltaPercent = (float64(simulatedGas) - float64(originalGas)) / float64(originalGas) * 100
+		if req.StopIfExceedsLimit && result.stopAtGasLimit(txIndex, len(txIndices)-i-1) {
+			break
 		}
+	}
 
-		// Determine if execution paths diverged
-		// Divergence occurs when opcode counts differ OR status changed between original and simulated
-		diverged := dualResult.Original.OpcodeCount != dualResult.Simulated.OpcodeCount ||
-			dualResult.Original.Status != dualResult.Simulated.Status
-
-		// Surface pre-execution errors (e.g. "intrinsic gas too low") from either execution
-		var txError string
-		if dualResult.Original.ApplyErr != nil {
-			txError = "original: " + dualResult.Original.ApplyErr.Error()
-		} else if dualResult.Simulated.ApplyErr != nil {
-			txError = dualResult.Simulated.ApplyErr.Error()
-		}
+	// Derive the fee impact of the gas deltas at the block's base fee
+	if baseFee := block.BaseFee(); baseFee != nil {
+		result.applyBaseFee(baseFee.ToBig())
+	}
 
-		// Add transaction summary
-		txSummary := TxSummary{
-			Hash:             txn.Hash().Hex(),
-			Index:            uint64(txIndex),
-			OriginalStatus:   dualResult.Original.Status,
-			SimulatedStatus:  dualResult.Simulated.Status,
-			OriginalGas:      originalGas,
-			SimulatedGas:     simulatedGas,
-			DeltaPercent:     deltaPercent,
-			Diverged:         diverged,
-			OriginalReverts:  dualResult.Original.RevertCount,
-			SimulatedReverts: dualResult.Simulated.RevertCount,
-			OriginalErrors:   dualResult.Original.CallErrors,
-			SimulatedErrors:  dualResult.Simulated.CallErrors,
-			Error:            txError,
-		}
-		result.Transactions = append(result.Transactions, txSummary)
-
-		// Accumulate totals
-		result.Original.GasUsed += originalGas
-		result.Simulated.GasUsed += simulatedGas
-
-		// Aggregate opcode breakdown from both executions
-		for opcode, summary := range dualResult.OpcodeBreakdown {
-			existing := result.OpcodeBreakdown[opcode]
-			existing.OriginalCount += summary.OriginalCount
-			existing.OriginalGas += summary.OriginalGas
-			existing.SimulatedCount += summary.SimulatedCount
-			existing.SimulatedGas += summary.SimulatedGas
-			result.OpcodeBreakdown[opcode] = existing
+	result.summarize(req.TopN)
+	result.ChainConfigSource = s.chainConfigSource(opts)
+	result.ChainRules = chainRulesFlags(s.chainConfigFor(ctx, opts).Rules(req.BlockNumber, header.Time))
+
+	result.truncate(s.config.MaxResponseBytes)
+
+	if req.Format == breakdownFormatCSV {
+		breakdownCSV, err := formatBreakdownCSV(result.OpcodeBreakdown)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format opcode breakdown: %w", err)
 		}
 
-		// Add intrinsic gas to opcode breakdown so it's visible in the Gas Breakdown tab
-		intrinsic := result.OpcodeBreakdown["TX_INTRINSIC"]
-		intrinsic.OriginalCount++
-		intrinsic.OriginalGas += dualResult.Original.IntrinsicGas
-		intrinsic.SimulatedCount++
-		intrinsic.SimulatedGas += dualResult.Simulated.IntrinsicGas
-		result.OpcodeBreakdown["TX_INTRINSIC"] = intrinsic
+		result.OpcodeBreakdownCSV = breakdownCSV
+		result.OpcodeBreakdown = nil
 	}
 
-	// Check if gas would exceed limit
-	result.Original.WouldExceedLimit = result.Original.GasUsed > header.GasLimit
-	result.Simulated.WouldExceedLimit = result.Simulated.GasUsed > header.GasLimit
+	s.resultCache.put(cacheKey, block.Hash(), result)
 
 	return result, nil
 }
@@ -235,162 +700,310 @@ func (s *Service) SimulateTransactionGas(
 	ctx context.Context,
 	req SimulateTransactionGasRequest,
 ) (*SimulateTransactionGasResult, error) {
-	tx, err := s.db.BeginTemporalRo(ctx)
+	s.log.Debug("Simulating transaction gas", "tx", req.TransactionHash,
+		"overrides", requestOverrideKeys(req.GasSchedule, req.CompactSchedule), "presets", req.Presets)
+
+	start := time.Now()
+	result, dual, err := s.simulateTransaction(ctx, req, SimulationTracerConfig{
+		TrackAccessList: true,
+		ClassifyCalls:   true,
+		TrackContracts:  true,
+
+		CaptureSenderState: true,
+	})
+	s.logTransactionSimulation(req, result, dual, time.Since(start), err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, err
 	}
-	defer tx.Rollback()
 
-	txHash := common.HexToHash(req.TransactionHash)
+	return result, nil
+}
 
-	// Look up transaction
-	blockNum, txNum, ok, err := s.blockReader.TxnLookup(ctx, tx, txHash)
+// simulateTransaction runs the dual execution for a single transaction and builds
+// the summary result. The raw dual execution result is also returned so callers can
+// expose optional tracer data enabled through tracerCfg.
+func (s *Service) simulateTransaction(
+	ctx context.Context,
+	req SimulateTransactionGasRequest,
+	tracerCfg SimulationTracerConfig,
+) (*SimulateTransactionGasResult, *dualExecutionResult, error) {
+	if err := validateChainConfigOverride(req.ChainConfigOverride); err != nil {
+		return nil, nil, err
+	}
+
+	schedule, err := resolveGasSchedule(req.GasSchedule, req.CompactSchedule)
 	if err != nil {
-		return nil, fmt.Errorf("failed to lookup transaction: %w", err)
+		return nil, nil, err
 	}
+	req.GasSchedule, req.CompactSchedule = schedule, ""
 
-	if !ok {
-		return nil, fmt.Errorf("transaction %s not found", req.TransactionHash)
+	schedule, presetKeys, err := resolvePresets(req.Presets, req.GasSchedule)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Verify block number matches if provided
-	if req.BlockNumber != 0 && req.BlockNumber != blockNum {
-		return nil, fmt.Errorf("transaction %s is in block %d, not %d", req.TransactionHash, blockNum, req.BlockNumber)
+	schedule, err = resolveBaseSchedule(req.BaseSchedule, schedule)
+	if err != nil {
+		return nil, nil, err
 	}
+	req.GasSchedule = schedule
 
-	txNumReader := s.blockReader.TxnumReader()
+	// A pre-Berlin or pre-London base prices the fork's access and refund rules, so
+	// it runs under them (see baseScheduleModes)
+	disableAccessList, legacyRefunds := baseScheduleModes(req.BaseSchedule)
+	req.DisableAccessList = req.DisableAccessList || disableAccessList
+	req.LegacyRefunds = req.LegacyRefunds || legacyRefunds
 
-	// Calculate txIndex
-	txNumMin, err := txNumReader.Min(ctx, tx, blockNum)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get min txNum: %w", err)
+	opts := req.options()
+	if err := opts.Precompiles.validate(); err != nil {
+		return nil, nil, err
 	}
 
-	if txNumMin+1 > txNum {
-		return nil, fmt.Errorf("txNum underflow: txNum=%d, txNumMin=%d", txNum, txNumMin)
+	if err := validatePCOverrides(opts.PCOverrides); err != nil {
+		return nil, nil, err
 	}
 
-	txIndex := int(txNum - txNumMin - 1)
+	if err := opts.GasSchedule.validateRelativeOverrides(); err != nil {
+		return nil, nil, err
+	}
 
-	// Get block
-	block, err := s.blockReader.BlockByNumber(ctx, tx, blockNum)
+	calldata, err := parseCalldataOverride(req.CalldataOverride)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get block %d: %w", blockNum, err)
+		return nil, nil, err
+	}
+	opts.Calldata = calldata
+
+	tracerCfg.TrackOpcodePairs = req.IncludeOpcodePairs
+	// Logs are also needed to compare against the receipt's log count
+	tracerCfg.CaptureLogs = req.IncludeLogs || req.IncludeReceipt
+	tracerCfg.CaptureStorage = req.IncludeStorageChanges
+	tracerCfg.CapturePrecompiles = req.IncludePrecompileCalls
+	tracerCfg.CollectStats = req.IncludeTracerStats
+	tracerCfg.TrackAccessListUse = req.IncludeAccessListValue
+	tracerCfg.ClassifyWarmAccess = req.IncludeWarmAccessOrigins
+	tracerCfg.TrackCallTree = req.IncludeCallTree
+	tracerCfg.TrackGasStdDev = req.IncludeGasStdDev
+
+	if req.SampleRate < 0 || req.SampleRate > maxSampleRate {
+		return nil, nil, fmt.Errorf("sample rate must be between 0 and %d", maxSampleRate)
 	}
+	tracerCfg.SampleRate = req.SampleRate
 
-	if block == nil {
-		return nil, fmt.Errorf("block %d not found", blockNum)
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	header := block.Header()
+	txNumReader := s.txNumsReader(ctx)
 
-	// Run both executions in parallel
-	dualResult, err := s.executeTransactionDual(
-		ctx, tx, header, block, txIndex, txNumReader, req.GasSchedule, req.MaxGasLimit,
-	)
+	block, txIndex, err := s.lookupTransaction(ctx, tx, txNumReader, req.TransactionHash, req.BlockNumber)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute transaction: %w", err)
+		return nil, nil, err
 	}
 
-	// Build result
-	// Note: GasUsed from ApplyMessage already includes intrinsic gas
-	// Intrinsic gas is calculated by Erigon and returned in the execution result
-	// Safely calculate execution gas (avoid uint64 underflow when GasUsed < IntrinsicGas,
-	// which happens when a tx fails pre-execution e.g. intrinsic gas too low)
-	originalExecGas := uint64(0)
-	if dualResult.Original.GasUsed > dualResult.Original.IntrinsicGas {
-		originalExecGas = dualResult.Original.GasUsed - dualResult.Original.IntrinsicGas
+	blockNum := block.NumberU64()
+	header := block.Header()
+
+	if err := s.checkSupportedFork(ctx, opts, blockNum, header.Time); err != nil {
+		return nil, nil, err
 	}
 
-	simulatedExecGas := uint64(0)
-	if dualResult.Simulated.GasUsed > dualResult.Simulated.IntrinsicGas {
-		simulatedExecGas = dualResult.Simulated.GasUsed - dualResult.Simulated.IntrinsicGas
+	if err := s.resolveRelativeOverrides(ctx, &opts, blockNum, header.Time); err != nil {
+		return nil, nil, err
+	}
+
+	// Run the original and simulated executions
+	dualResult, err := s.executeTransactionDual(
+		ctx, tx, header, block, txIndex, txNumReader, opts, tracerCfg,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute transaction: %w", err)
 	}
 
 	result := &SimulateTransactionGasResult{
 		TransactionHash: req.TransactionHash,
 		BlockNumber:     blockNum,
 		Status:          dualResult.Original.Status,
-		Original: TxGasDetail{
-			GasUsed:      dualResult.Original.GasUsed,
-			IntrinsicGas: dualResult.Original.IntrinsicGas,
-			ExecutionGas: originalExecGas,
-		},
-		Simulated: TxGasDetail{
-			GasUsed:      dualResult.Simulated.GasUsed,
-			IntrinsicGas: dualResult.Simulated.IntrinsicGas,
-			ExecutionGas: simulatedExecGas,
-		},
+		Original:        newTxGasDetail(dualResult.Original),
+		Simulated:       newTxGasDetail(dualResult.Simulated),
 		OpcodeBreakdown: dualResult.OpcodeBreakdown,
+		AccessListDiff:  dualResult.AccessListDiff,
+		ColdAccessCount: dualResult.ColdAccessCount,
+
+		CallClassification: dualResult.CallClassification,
+		WarmAccessOrigins:  dualResult.WarmAccessOrigins,
+		CallTree:           dualResult.CallTree,
+		OpcodePairs:        dualResult.OpcodePairs,
+		ContractBreakdown:  dualResult.ContractBreakdown,
+		Sender:             dualResult.Original.Sender,
+		FirstSeenPC:        dualResult.FirstSeenPC,
+		PresetKeys:         presetKeys,
+		TracerStats:        dualResult.TracerStats,
 	}
 
-	return result, nil
-}
+	if req.IncludeLogs {
+		result.Logs = dualResult.Logs
+	}
 
-// dualExecutionResult holds the combined results from both EVM executions.
-type dualExecutionResult struct {
-	Original        *executionResult
-	Simulated       *executionResult
-	OpcodeBreakdown map[string]OpcodeSummary
+	result.StorageChanges = dualResult.StorageChanges
+	result.PrecompileCalls = dualResult.PrecompileCalls
+
+	if req.IncludeReceipt {
+		receipts, err := s.blockReceipts(ctx, tx, block)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read receipts: %w", err)
+		}
+
+		receipt, err := receiptAt(receipts, len(block.Transactions()), txIndex)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if dualResult.Logs == nil {
+			return nil, nil, fmt.Errorf("logs of tx %d were not captured", txIndex)
+		}
+
+		result.Receipt = newReceiptComparison(receipt, dualResult.Logs.Original, dualResult.Original.GasUsed)
+	}
+
+	result.TopMovers = topMovers(dualResult.OpcodeBreakdown)
+	result.ChainConfigSource = s.chainConfigSource(opts)
+
+	rules := s.chainConfigFor(ctx, opts).Rules(blockNum, header.Time)
+	result.ChainRules = chainRulesFlags(rules)
+
+	if original := dualResult.OriginalAccessListUse; original != nil && original.declaredAddresses > 0 {
+		defaults := GasScheduleForRules(rules).Overrides
+
+		result.AccessListValue = &AccessListAnalysis{
+			Original:  original.value(nil, defaults),
+			Simulated: dualResult.SimulatedAccessListUse.value(opts.GasSchedule, defaults),
+		}
+	}
+
+	// Intrinsic gas is not part of a transaction's opcode breakdown
+	result.EIPBreakdown = eipBreakdown(dualResult.OpcodeBreakdown,
+		dualResult.Original.chargedIntrinsicGas(), dualResult.Simulated.chargedIntrinsicGas())
+
+	if req.SampleRate > 1 {
+		result.Sampled = true
+		result.SampleRate = req.SampleRate
+	}
+
+	return result, dualResult, nil
 }
 
-// executeTransactionDual runs two EVM executions for a transaction:
-// one with standard gas costs (original) and one with custom gas schedule (simulated).
-// Both executions have tracers attached to capture per-opcode gas breakdown.
-func (s *Service) executeTransactionDual(
+// lookupTransaction locates a transaction by hash and returns its block and index
+// within the block. If blockNumber is non-zero, it must match the block containing
+// the transaction.
+func (s *Service) lookupTransaction(
 	ctx context.Context,
-	_ kv.TemporalTx, // unused - we open fresh transactions for each execution
-	header *erigontypes.Header,
-	block *erigontypes.Block,
-	txIndex int,
+	tx kv.TemporalTx,
 	txNumReader rawdbv3.TxNumsReader,
-	gasSchedule *CustomGasSchedule,
-	maxGasLimit bool,
-) (*dualExecutionResult, error) {
-	// Execute with standard JumpTable (original gas costs)
-	dbTx1, err := s.db.BeginTemporalRo(ctx)
+	txHashHex string,
+	blockNumber uint64,
+) (*erigontypes.Block, int, error) {
+	txHash := common.HexToHash(txHashHex)
+
+	// Look up transaction
+	blockNum, txNum, ok, err := s.blockReader.TxnLookup(ctx, tx, txHash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction for original: %w", err)
+		return nil, 0, fmt.Errorf("failed to lookup transaction: %w", err)
 	}
-	defer dbTx1.Rollback()
 
-	originalTracer := NewSimulationTracer(nil)
-	originalResult, err := s.executeSingleTransaction(ctx, dbTx1, header, block, txIndex, txNumReader, nil, originalTracer, false)
-	if err != nil {
-		return nil, fmt.Errorf("original execution failed: %w", err)
+	if !ok {
+		return nil, 0, fmt.Errorf("transaction %s not found", txHashHex)
 	}
 
-	// Capture tracer stats for original execution
-	originalResult.RevertCount = originalTracer.GetRevertCount()
-	originalResult.OpcodeCount = originalTracer.GetTotalOpcodeCount()
-	originalResult.CallErrors = originalTracer.GetCallErrors()
+	// Verify block number matches if provided
+	if blockNumber != 0 && blockNumber != blockNum {
+		return nil, 0, fmt.Errorf("transaction %s is in block %d, not %d", txHashHex, blockNum, blockNumber)
+	}
 
-	// Execute with custom JumpTable (simulated gas costs)
-	dbTx2, err := s.db.BeginTemporalRo(ctx)
+	// Calculate txIndex
+	txNumMin, err := txNumReader.Min(ctx, tx, blockNum)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction for simulated: %w", err)
+		return nil, 0, fmt.Errorf("failed to get min txNum: %w", err)
 	}
-	defer dbTx2.Rollback()
 
-	simulatedTracer := NewSimulationTracer(gasSchedule)
-	simulatedResult, err := s.executeSingleTransaction(ctx, dbTx2, header, block, txIndex, txNumReader, gasSchedule, simulatedTracer, maxGasLimit)
+	if txNumMin+1 > txNum {
+		return nil, 0, fmt.Errorf("txNum underflow: txNum=%d, txNumMin=%d", txNum, txNumMin)
+	}
+
+	txIndex := int(txNum - txNumMin - 1)
+
+	// Get block
+	block, err := s.blockReader.BlockByNumber(ctx, tx, blockNum)
 	if err != nil {
-		return nil, fmt.Errorf("simulated execution failed: %w", err)
+		return nil, 0, fmt.Errorf("failed to get block %d: %w", blockNum, err)
 	}
 
-	// Capture tracer stats for simulated execution
-	simulatedResult.RevertCount = simulatedTracer.GetRevertCount()
-	simulatedResult.OpcodeCount = simulatedTracer.GetTotalOpcodeCount()
-	simulatedResult.CallErrors = simulatedTracer.GetCallErrors()
+	if block == nil {
+		return nil, 0, fmt.Errorf("block %d not found", blockNum)
+	}
 
-	// Combine opcode breakdowns from both tracers
-	opcodeBreakdown := combineOpcodeBreakdowns(originalTracer, simulatedTracer)
+	return block, txIndex, nil
+}
 
-	return &dualExecutionResult{
-		Original:        originalResult,
-		Simulated:       simulatedResult,
-		OpcodeBreakdown: opcodeBreakdown,
-	}, nil
+// txNumsReader returns the reader used to map block numbers to txNums.
+func (s *Service) txNumsReader(ctx context.Context) rawdbv3.TxNumsReader {
+	return s.blockReader.TxnumReader()
+}
+
+// dualExecutionResult holds the combined results from both EVM executions.
+type dualExecutionResult struct {
+	Original           *executionResult
+	Simulated          *executionResult
+	OpcodeBreakdown    map[string]OpcodeSummary
+	AccessListDiff     *AccessListDiff        // nil unless access tracking is enabled
+	ColdAccessCount    uint64                 // From the original execution; 0 unless access tracking is enabled
+	CallClassification *CallClassifications   // nil unless ClassifyCalls is enabled
+	WarmAccessOrigins  *WarmAccessOrigins     // nil unless ClassifyWarmAccess is enabled
+	CallTree           *CallTrees             // nil unless TrackCallTree is enabled
+	OpcodePairs        *OpcodePairs           // nil unless TrackOpcodePairs is enabled
+	ContractBreakdown  map[string]ContractGas // nil unless TrackContracts is enabled
+	FirstSeenPC        map[string]uint32      // From the original execution
+	Logs               *EmittedLogs           // nil unless CaptureLogs is enabled
+	StorageChanges     *StorageChanges        // nil unless CaptureStorage is enabled
+	PrecompileCalls    *PrecompileCalls       // nil unless CapturePrecompiles is enabled
+	TracerStats        *TracerStatsComparison // nil unless CollectStats is enabled
+
+	// Declared access list entry hits (nil unless TrackAccessListUse is enabled)
+	OriginalAccessListUse  *accessListUsage
+	SimulatedAccessListUse *accessListUsage
+
+	// Ordered opcode traces (nil unless RecordSequence is enabled)
+	OriginalSequence  []OpcodeStep
+	SimulatedSequence []OpcodeStep
+}
+
+// executeTransactionDual runs two EVM executions for a transaction:
+// one with standard gas costs (original) and one with custom gas schedule (simulated).
+// The original execution uses opts.baseline(), so environment options such as a chain
+// config override apply to both. Both executions have tracers attached to capture
+// per-opcode gas breakdown; tracerCfg enables optional tracking on both tracers.
+// Requests without overrides skip the simulated execution (see runDualExecution).
+func (s *Service) executeTransactionDual(
+	ctx context.Context,
+	_ kv.TemporalTx, // unused - we open fresh transactions for each execution
+	header *erigontypes.Header,
+	block *erigontypes.Block,
+	txIndex int,
+	txNumReader rawdbv3.TxNumsReader,
+	opts executionOptions,
+	tracerCfg SimulationTracerConfig,
+) (*dualExecutionResult, error) {
+	return runDualExecution(opts, tracerCfg, func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+		// Each execution gets a fresh transaction so state changes don't leak between runs
+		dbTx, err := s.db.BeginTemporalRo(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer dbTx.Rollback()
+
+		return s.executeSingleTransaction(ctx, dbTx, header, block, txIndex, txNumReader, tracer, opts)
+	})
 }
 
 // combineOpcodeBreakdowns merges the per-opcode gas data from both tracers.
@@ -408,6 +1021,9 @@ func combineOpcodeBreakdowns(originalTracer, simulatedTracer *SimulationTracer)
 		entry := result[opcode]
 		entry.OriginalCount = data.Count
 		entry.OriginalGas = data.Gas
+		entry.OriginalMinGas = data.MinGas
+		entry.OriginalMaxGas = data.MaxGas
+		entry.OriginalGasStdDev = data.GasStdDev
 		result[opcode] = entry
 	}
 
@@ -416,14 +1032,25 @@ func combineOpcodeBreakdowns(originalTracer, simulatedTracer *SimulationTracer)
 		entry := result[opcode]
 		entry.SimulatedCount = data.Count
 		entry.SimulatedGas = data.Gas
+		entry.SimulatedMinGas = data.MinGas
+		entry.SimulatedMaxGas = data.MaxGas
+		entry.SimulatedGasStdDev = data.GasStdDev
 		result[opcode] = entry
 	}
 
 	return result
 }
 
-// executeSingleTransaction executes a transaction with the given gas schedule.
-// If gasSchedule is nil, uses the standard gas costs.
+// precompileAddress is the key type of vm.PrecompiledContracts.
+type precompileAddress = accounts.Address
+
+// precompileKey converts an address to a vm.PrecompiledContracts key.
+func precompileKey(addr common.Address) precompileAddress {
+	return accounts.InternAddress(addr)
+}
+
+// executeSingleTransaction executes a transaction with the given options.
+// If opts.GasSchedule is nil, uses the standard gas costs.
 // Returns the execution result with gas used.
 func (s *Service) executeSingleTransaction(
 	ctx context.Context,
@@ -432,21 +1059,39 @@ func (s *Service) executeSingleTransaction(
 	block *erigontypes.Block,
 	txIndex int,
 	txNumReader rawdbv3.TxNumsReader,
-	gasSchedule *CustomGasSchedule,
 	tracer *SimulationTracer,
-	maxGasLimit bool,
-) (*executionResult, error) {
-	// Use chain config from DB to match what the RPC handler sees.
-	execChainConfig := s.chainConfigForExecution(ctx)
+	opts executionOptions,
+) (result *executionResult, err error) {
+	// Contain panics from custom gas functions to this transaction
+	defer s.recoverExecutionPanic(txIndex, &result, &err)
 
-	// Compute block context (creates fresh in-memory state)
-	statedb, blockCtx, _, chainRules, signer, err := transactions.ComputeBlockContext(
-		ctx, s.engine, header, execChainConfig, s.blockReader, nil, txNumReader, dbTx, txIndex,
-	)
+	// Use chain config from DB to match what the RPC handler sees,
+	// unless the request overrides it.
+	execChainConfig := s.chainConfigFor(ctx, opts)
+
+	statedb, blockCtx, chainRules, signer, err := s.computeBlockContext(ctx, dbTx, header, txIndex, txNumReader, execChainConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compute block context: %w", err)
+		return nil, err
 	}
 
+	return s.executeOnState(ctx, statedb, blockCtx, chainRules, signer, execChainConfig, header, block, txIndex, tracer, opts)
+}
+
+// executeOnState executes the transaction at txIndex against statedb, which holds
+// the state the transaction runs on.
+func (s *Service) executeOnState(
+	ctx context.Context,
+	statedb *erigonstate.IntraBlockState,
+	blockCtx evmtypes.BlockContext,
+	chainRules *chain.Rules,
+	signer *erigontypes.Signer,
+	execChainConfig *chain.Config,
+	header *erigontypes.Header,
+	block *erigontypes.Block,
+	txIndex int,
+	tracer *SimulationTracer,
+	opts executionOptions,
+) (result *executionResult, err error) {
 	// Compute tx context
 	msg, txCtx, err := transactions.ComputeTxContext(statedb, s.engine, chainRules, signer, block, execChainConfig, txIndex)
 	if err != nil {
@@ -458,47 +1103,251 @@ func (s *Service) executeSingleTransaction(
 		typedMsg.SetCheckNonce(false)
 	}
 
+	txn := block.Transactions()[txIndex]
+	intrinsicGas := calcIntrinsicGasForTx(txn, chainRules, opts.GasSchedule)
+
+	if opts.Calldata != nil {
+		intrinsicGas = calcIntrinsicGas(opts.Calldata, txn.GetAccessList(), txn.GetTo() == nil, chainRules, opts.GasSchedule)
+	}
+
+	// Read the sender's state before ApplyMessage charges gas and bumps the nonce
+	var sender *SenderState
+	if tracer != nil && tracer.captureSender {
+		sender, err = readSenderState(statedb, msg.From())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err = s.executeMessage(ctx, statedb, blockCtx, txCtx, msg, header, chainRules, execChainConfig, intrinsicGas, false, tracer, opts)
+	if result != nil {
+		result.Sender = sender
+	}
+
+	return result, err
+}
+
+// blockState is the state a reordered block's transactions run on (see
+// orderedExecutor). It starts from the state before the block's first transaction
+// and keeps each transaction's writes for the next one.
+type blockState struct {
+	s           *Service
+	ctx         context.Context
+	dbTx        kv.TemporalTx
+	statedb     *erigonstate.IntraBlockState
+	blockCtx    evmtypes.BlockContext
+	chainRules  *chain.Rules
+	signer      *erigontypes.Signer
+	chainConfig *chain.Config
+	header      *erigontypes.Header
+	block       *erigontypes.Block
+}
+
+// newBlockState opens a fresh transaction and computes the state before the
+// block's first transaction.
+func (s *Service) newBlockState(
+	ctx context.Context,
+	header *erigontypes.Header,
+	block *erigontypes.Block,
+	txNumReader rawdbv3.TxNumsReader,
+	opts executionOptions,
+) (*blockState, error) {
+	dbTx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	chainConfig := s.chainConfigFor(ctx, opts)
+
+	statedb, blockCtx, chainRules, signer, err := s.computeBlockContext(ctx, dbTx, header, 0, txNumReader, chainConfig)
+	if err != nil {
+		dbTx.Rollback()
+		return nil, err
+	}
+
+	return &blockState{
+		s:           s,
+		ctx:         ctx,
+		dbTx:        dbTx,
+		statedb:     statedb,
+		blockCtx:    blockCtx,
+		chainRules:  chainRules,
+		signer:      signer,
+		chainConfig: chainConfig,
+		header:      header,
+		block:       block,
+	}, nil
+}
+
+// execute runs the transaction at txIndex and commits its writes to the state,
+// so that the next transaction sees them.
+func (b *blockState) execute(txIndex int, tracer *SimulationTracer, opts executionOptions) (result *executionResult, err error) {
+	defer b.s.recoverExecutionPanic(txIndex, &result, &err)
+
+	result, err = b.s.executeOnState(b.ctx, b.statedb, b.blockCtx, b.chainRules, b.signer, b.chainConfig, b.header, b.block, txIndex, tracer, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.statedb.FinalizeTx(b.chainRules, erigonstate.NewNoopWriter()); err != nil {
+		return nil, fmt.Errorf("failed to finalize tx %d: %w", txIndex, err)
+	}
+
+	return result, nil
+}
+
+// close releases the state's database transaction.
+func (b *blockState) close() {
+	b.dbTx.Rollback()
+}
+
+// readSenderState reads the sender's nonce and balance from statedb.
+func readSenderState(statedb *erigonstate.IntraBlockState, from accounts.Address) (*SenderState, error) {
+	nonce, err := statedb.GetNonce(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sender nonce: %w", err)
+	}
+
+	balance, err := statedb.GetBalance(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sender balance: %w", err)
+	}
+
+	return &SenderState{
+		Address: normalizeAddress(from.String()),
+		Nonce:   nonce,
+		Balance: balance.Hex(),
+	}, nil
+}
+
+// computeBlockContext computes the state and block context for executing the
+// transaction at txIndex. A txIndex of len(block.Transactions()) yields the state
+// after the whole block.
+func (s *Service) computeBlockContext(
+	ctx context.Context,
+	dbTx kv.TemporalTx,
+	header *erigontypes.Header,
+	txIndex int,
+	txNumReader rawdbv3.TxNumsReader,
+	chainConfig *chain.Config,
+) (*erigonstate.IntraBlockState, evmtypes.BlockContext, *chain.Rules, *erigontypes.Signer, error) {
+	// Compute block context (creates fresh in-memory state)
+	statedb, blockCtx, _, chainRules, signer, err := transactions.ComputeBlockContext(
+		ctx, s.engine, header, chainConfig, s.blockReader, nil, txNumReader, dbTx, txIndex,
+	)
+	if err != nil {
+		return nil, evmtypes.BlockContext{}, nil, nil, fmt.Errorf("failed to compute block context: %w", err)
+	}
+
+	return statedb, blockCtx, chainRules, signer, nil
+}
+
+// executeMessage runs msg against statedb with the EVM configured for opts.
+// intrinsicGas is reported as-is; gasBailout skips the sender balance check.
+func (s *Service) executeMessage(
+	ctx context.Context,
+	statedb *erigonstate.IntraBlockState,
+	blockCtx evmtypes.BlockContext,
+	txCtx evmtypes.TxContext,
+	msg protocol.Message,
+	header *erigontypes.Header,
+	chainRules *chain.Rules,
+	execChainConfig *chain.Config,
+	intrinsicGas uint64,
+	gasBailout bool,
+	tracer *SimulationTracer,
+	opts executionOptions,
+) (*executionResult, error) {
 	// Build VM config
 	vmConfig := vm.Config{
 		NoBaseFee: true,
 	}
 
+	// Resolve the active precompiles, applying any disabled or moved precompiles
+	precompiles, err := opts.Precompiles.apply(vm.Precompiles(chainRules))
+	if err != nil {
+		return nil, err
+	}
+
 	// Set tracer if provided
 	if tracer != nil {
-		tracer.precompiles = vm.Precompiles(chainRules)
+		tracer.precompiles = precompiles
 		statedb.SetHooks(tracer.Hooks())
 		vmConfig.Tracer = tracer.Hooks()
 	}
 
-	// Build custom JumpTable if gas schedule has overrides
-	if gasSchedule != nil && gasSchedule.HasOverrides() {
-		customJT := BuildCustomJumpTable(chainRules, gasSchedule)
+	// Relative overrides become concrete here, so the JumpTable and evm.GasSchedule
+	// never drop them (a no-op when the handler already resolved them)
+	opts.GasSchedule, err = opts.GasSchedule.resolveFor(chainRules)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build custom JumpTable if gas schedule has overrides or gas functions are swapped
+	jtOpts := JumpTableOptions{
+		DisableEIP2929: opts.DisableAccessList,
+		LegacyRefunds:  opts.LegacyRefunds,
+		ForceAllCold:   opts.ForceAllCold,
+		WarmAddresses:  opts.Precompiles.movedAddresses(),
+		PCOverrides:    vmPCOverrides(opts.PCOverrides),
+	}
+	if opts.GasSchedule.HasOverrides() || jtOpts.enabled() {
+		customJT := BuildCustomJumpTable(chainRules, opts.GasSchedule, jtOpts)
 		vmConfig.CustomJumpTable = customJT
 	}
 
+	// Blob gas is priced outside the EVM; blob overrides reprice it in the block context
+	blobGasUsed, err := applyBlobPricing(&blockCtx, msg, header, execChainConfig, opts.GasSchedule)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create EVM
 	evm := vm.NewEVM(blockCtx, txCtx, statedb, execChainConfig, vmConfig)
+	if opts.Precompiles.enabled() {
+		evm.SetPrecompiles(precompiles)
+	}
 
 	// Set GasSchedule for dynamic gas overrides (patched gas functions read from this)
-	if gasSchedule != nil && gasSchedule.HasOverrides() {
-		evm.GasSchedule = gasSchedule.ToVMGasSchedule()
-	}
-
-	// When maxGasLimit is enabled, override the transaction's gas limit with the block's
-	// gas limit. This removes the gas limit as a constraining factor so the simulation
-	// shows the true gas cost under the new pricing, without artificial OOG failures.
-	if maxGasLimit {
-		if typedMsg, ok := msg.(*erigontypes.Message); ok {
-			typedMsg.ChangeGas(0, header.GasLimit)
-			// Disable gas validation (EIP-7825 cap check) since this is a simulation.
-			typedMsg.SetCheckGas(false)
+	if opts.GasSchedule != nil && opts.GasSchedule.HasOverrides() {
+		evm.GasSchedule = opts.GasSchedule.ToVMGasSchedule()
+	}
+
+	// When MaxGasLimit is enabled, override the transaction's gas limit with the block's
+	// gas limit (or opts.GasLimit). This removes the gas limit as a constraining factor so
+	// the simulation shows the true gas cost under the new pricing, without artificial
+	// OOG failures.
+	if typedMsg, ok := msg.(*erigontypes.Message); ok {
+		if opts.MaxGasLimit {
+			typedMsg.ChangeGas(0, opts.txGasLimit(header.GasLimit))
+		}
+
+		// MaxGasLimit disables the EIP-7825 gas cap check unless EnforceGasCap keeps
+		// it (see executionOptions.gasCapCheck)
+		if check, ok := opts.gasCapCheck(); ok {
+			typedMsg.SetCheckGas(check)
 		}
 	}
 
-	// When maxGasLimit is enabled, also enable gasBailout to skip the sender balance
-	// check — the sender's balance was sufficient for the original gas limit, not the
-	// overridden one.
-	gasBailout := maxGasLimit
+	// MaxGasLimit and calldata overrides skip the sender balance check, unless
+	// EnforceBalanceCheck keeps it for MaxGasLimit (see executionOptions.gasBailout)
+	gasBailout = opts.gasBailout(gasBailout)
+
+	// Replace the calldata last, as the MaxGasLimit adjustment needs the concrete
+	// message type
+	if opts.Calldata != nil {
+		msg = calldataMessage{Message: msg, data: opts.Calldata}
+	}
+
+	// Charge the repriced blob gas, replaced last for the same reason
+	msg = withBlobGas(msg, blobGasUsed)
+
+	release, err := s.acquireExecution(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	gp := new(protocol.GasPool).AddGas(msg.Gas()).AddBlobGas(msg.BlobGas())
 	execResult, err := protocol.ApplyMessage(evm, msg, gp, true, gasBailout, s.engine)
 
@@ -508,14 +1357,19 @@ func (s *Service) executeSingleTransaction(
 		status = "failed"
 	}
 
-	// Calculate intrinsic gas
-	txn := block.Transactions()[txIndex]
-	intrinsicGas := calcIntrinsicGasForTx(txn, chainRules, gasSchedule)
-
 	result := &executionResult{
 		Status:       status,
+		GasLimit:     msg.Gas(),
 		IntrinsicGas: intrinsicGas,
+		CalldataGas:  calcCalldataGas(msg.Data(), chainRules, opts.GasSchedule),
+		FloorGas:     calcFloorGas(msg.Data(), chainRules, opts.GasSchedule),
 		ApplyErr:     err, // Captures pre-execution errors (e.g. intrinsic gas too low)
+
+		Refund:         getRefundValue(statedb),
+		RefundQuotient: refundQuotient(chainRules.IsLondon),
+
+		BlobGasUsed: blobGasUsed,
+		BlobBaseFee: blockCtx.BlobBaseFee,
 	}
 
 	if execResult != nil {
@@ -546,19 +1400,6 @@ func (s *Service) GetGasSchedule(ctx context.Context, blockNumber uint64) (*GasS
 		return nil, fmt.Errorf("block %d not found", blockNumber)
 	}
 
-	header := block.Header()
-	txNumReader := s.blockReader.TxnumReader()
-
-	// Get chain rules for this block (use DB chain config for correct fork rules)
-	execChainConfig := s.chainConfigForExecution(ctx)
-
-	_, blockCtx, _, chainRules, _, err := transactions.ComputeBlockContext(
-		ctx, s.engine, header, execChainConfig, s.blockReader, nil, txNumReader, tx, 0,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to compute block context: %w", err)
-	}
-	_ = blockCtx // Not needed, just used to get chainRules
-
-	return GasScheduleResponseForRules(chainRules), nil
+	// Use DB chain config for correct fork rules
+	return gasScheduleForBlock(s.chainConfigForExecution(ctx), blockNumber, block.Time()), nil
 }
@@ -0,0 +1,142 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestSimulateBlockGasDeterminism simulates the same block of transactions twice,
+// as simulateBlockGas does, and verifies the encoded results are byte-identical
+// regardless of the iteration order of the map-valued breakdowns, and that the
+// rankings are sorted.
+func TestSimulateBlockGasDeterminism(t *testing.T) {
+	sloader := common.HexToAddress("0x0000000000000000000000000000000000000510")
+	hasher := common.HexToAddress("0x00000000000000000000000000000000000000a5")
+
+	contracts := map[common.Address][]byte{
+		testContract: storeCalldata,
+		sloader:      {0x60, 0x00, 0x54, 0x50, 0x00},             // SLOAD(0)
+		hasher:       {0x60, 0x40, 0x60, 0x00, 0x20, 0x50, 0x00}, // KECCAK256(0, 64)
+	}
+
+	txs := []struct {
+		to   common.Address
+		data []byte
+	}{
+		{testContract, word(1)}, // SSTORE set
+		{hasher, nil},
+		{testContract, word(2)}, // SSTORE reset
+		{sloader, nil},
+		{testContract, word(2)}, // SSTORE no-op
+		{hasher, nil},
+		{testContract, word(0)}, // SSTORE clear
+		{sloader, nil},
+	}
+
+	opts := executionOptions{GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{
+		vm.GasKeySstoreSet:     30000,
+		vm.GasKeySstoreReset:   4000,
+		vm.GasKeySloadCold:     5000,
+		vm.GasKeyKeccak256Word: 60,
+	}}}
+
+	// Runs transaction txIndex on the state left by the transactions before it, as
+	// computeBlockContext provides it from history
+	historical := func(txIndex int) executeFunc {
+		return func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+			c := newTestChain(t, contracts)
+			for _, tx := range txs[:txIndex] {
+				c.call(tx.to, tx.data, executionOptions{})
+			}
+
+			return c.callTraced(txs[txIndex].to, txs[txIndex].data, tracer, opts), nil
+		}
+	}
+
+	run := func() (*SimulateBlockGasResult, []byte, []byte) {
+		c := newTestChain(t, contracts)
+
+		result := &SimulateBlockGasResult{
+			BlockNumber:     c.header.Number.Uint64(),
+			Original:        BlockGasSummary{GasLimit: c.header.GasLimit},
+			Simulated:       BlockGasSummary{GasLimit: c.header.GasLimit},
+			OpcodeBreakdown: make(map[string]OpcodeSummary, 64),
+		}
+
+		for i := range txs {
+			dual, err := runDualExecution(opts, SimulationTracerConfig{}, historical(i))
+			if err != nil {
+				t.Fatalf("tx %d: %v", i, err)
+			}
+
+			result.addDualResult(fmt.Sprintf("0x%064x", i), i, dual)
+		}
+
+		result.applyBaseFee(big.NewInt(7))
+		result.summarize(4)
+		result.ChainRules = chainRulesFlags(c.rules)
+
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("failed to encode result: %v", err)
+		}
+
+		return result, encoded, result.MarshalProto()
+	}
+
+	result, firstJSON, firstProto := run()
+	if _, againJSON, againProto := run(); !bytes.Equal(firstJSON, againJSON) || !bytes.Equal(firstProto, againProto) {
+		t.Fatal("simulating the block again produced a different result")
+	}
+
+	if len(result.EIPBreakdown) == 0 || len(result.ChainRules) == 0 {
+		t.Fatalf("expected map-valued breakdowns, got %d EIP and %d chain rule entries",
+			len(result.EIPBreakdown), len(result.ChainRules))
+	}
+
+	if len(result.TopDeltas) != 4 {
+		t.Fatalf("got %d top deltas, want 4", len(result.TopDeltas))
+	}
+
+	for i := 1; i < len(result.TopDeltas); i++ {
+		prev, cur := result.TopDeltas[i-1], result.TopDeltas[i]
+		if prev.gasDelta() < cur.gasDelta() || (prev.gasDelta() == cur.gasDelta() && prev.Index > cur.Index) {
+			t.Errorf("top deltas not sorted at %d: %+v before %+v", i, prev, cur)
+		}
+	}
+
+	if len(result.TopMovers) < 3 {
+		t.Fatalf("got %d top movers, want the SSTORE, SLOAD and KECCAK256 repricing", len(result.TopMovers))
+	}
+
+	for i := 1; i < len(result.TopMovers); i++ {
+		prev, cur := result.TopMovers[i-1], result.TopMovers[i]
+		if absDelta(prev.Delta) < absDelta(cur.Delta) || (absDelta(prev.Delta) == absDelta(cur.Delta) && prev.Opcode > cur.Opcode) {
+			t.Errorf("top movers not sorted at %d: %+v before %+v", i, prev, cur)
+		}
+	}
+}
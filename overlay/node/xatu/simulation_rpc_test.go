@@ -0,0 +1,389 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestBlockGasSummaryRevertedGas verifies that gas from failed transactions is
+// tracked separately while still counting towards the block's total.
+func TestBlockGasSummaryRevertedGas(t *testing.T) {
+	txs := []struct {
+		gasUsed uint64
+		status  string
+	}{
+		{21000, "success"},
+		{45000, "failed"},
+		{120000, "success"},
+		{30000, "failed"},
+	}
+
+	var summary BlockGasSummary
+	for _, tx := range txs {
+		summary.addTransaction(tx.gasUsed, tx.status)
+	}
+
+	if summary.GasUsed != 216000 {
+		t.Errorf("GasUsed = %d, want 216000", summary.GasUsed)
+	}
+
+	if summary.GasReverted != 75000 {
+		t.Errorf("GasReverted = %d, want 75000", summary.GasReverted)
+	}
+
+	if summary.TxCount != 4 || summary.AvgGasPerTx != 54000 {
+		t.Errorf("TxCount = %d, AvgGasPerTx = %d, want 4 and 54000", summary.TxCount, summary.AvgGasPerTx)
+	}
+}
+
+func TestValidateChainConfigOverride(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *chain.Config
+		wantErr bool
+	}{
+		{name: "nil uses node config", cfg: nil},
+		{
+			name: "well-formed",
+			cfg:  &chain.Config{ChainID: big.NewInt(1337), HomesteadBlock: big.NewInt(0), TangerineWhistleBlock: big.NewInt(5)},
+		},
+		{
+			name:    "missing chain id",
+			cfg:     &chain.Config{HomesteadBlock: big.NewInt(0)},
+			wantErr: true,
+		},
+		{
+			name:    "non-monotonic fork schedule",
+			cfg:     &chain.Config{ChainID: big.NewInt(1337), HomesteadBlock: big.NewInt(10), TangerineWhistleBlock: big.NewInt(5)},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateChainConfigOverride(tc.cfg)
+			if tc.wantErr && err == nil {
+				t.Error("expected error")
+			}
+
+			if !tc.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestTopDeltas(t *testing.T) {
+	txs := []TxSummary{
+		{Index: 0, OriginalGas: 21000, SimulatedGas: 21000},
+		{Index: 1, OriginalGas: 100000, SimulatedGas: 150000}, // +50000
+		{Index: 2, OriginalGas: 80000, SimulatedGas: 10000},   // -70000
+		{Index: 3, OriginalGas: 50000, SimulatedGas: 100000},  // +50000
+		{Index: 4, OriginalGas: 30000, SimulatedGas: 31000},   // +1000
+	}
+
+	tests := []struct {
+		name string
+		n    int
+		want []uint64
+	}{
+		{name: "disabled", n: 0, want: nil},
+		{name: "top two", n: 2, want: []uint64{2, 1}},
+		{name: "ties broken by index", n: 3, want: []uint64{2, 1, 3}},
+		{name: "n exceeds count", n: 10, want: []uint64{2, 1, 3, 4, 0}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := topDeltas(txs, tc.n)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d transactions, want %d", len(got), len(tc.want))
+			}
+
+			for i, idx := range tc.want {
+				if got[i].Index != idx {
+					t.Errorf("position %d: got tx %d, want tx %d", i, got[i].Index, idx)
+				}
+			}
+		})
+	}
+
+	// Input order must be preserved
+	if txs[0].Index != 0 || txs[2].Index != 2 {
+		t.Error("topDeltas modified its input")
+	}
+}
+
+// TestTopMovers verifies that a pure SSTORE repricing puts SSTORE at the top of
+// the movers list and leaves out opcodes whose gas was unaffected.
+func TestTopMovers(t *testing.T) {
+	breakdown := map[string]OpcodeSummary{
+		"ADD":    {OriginalCount: 40, OriginalGas: 120, SimulatedCount: 40, SimulatedGas: 120},
+		"PUSH1":  {OriginalCount: 90, OriginalGas: 270, SimulatedCount: 90, SimulatedGas: 270},
+		"SLOAD":  {OriginalCount: 2, OriginalGas: 4200, SimulatedCount: 2, SimulatedGas: 4200},
+		"SSTORE": {OriginalCount: 2, OriginalGas: 40000, SimulatedCount: 2, SimulatedGas: 10000},
+	}
+
+	movers := topMovers(breakdown)
+	if len(movers) != 1 {
+		t.Fatalf("got %d movers, want 1: %+v", len(movers), movers)
+	}
+
+	want := OpcodeDelta{Opcode: "SSTORE", OriginalGas: 40000, SimulatedGas: 10000, Delta: -30000, PercentChange: -75}
+	if movers[0] != want {
+		t.Errorf("got %+v, want %+v", movers[0], want)
+	}
+
+	// Sorted by magnitude, not sign; ties broken by name
+	breakdown["CALL"] = OpcodeSummary{OriginalGas: 2600, SimulatedGas: 5200}
+	breakdown["ADD"] = OpcodeSummary{OriginalGas: 120, SimulatedGas: 2720}
+
+	var got []string
+	for _, m := range topMovers(breakdown) {
+		got = append(got, m.Opcode)
+	}
+
+	if wantOrder := []string{"SSTORE", "ADD", "CALL"}; fmt.Sprint(got) != fmt.Sprint(wantOrder) {
+		t.Errorf("got order %v, want %v", got, wantOrder)
+	}
+}
+
+// TestGasPercentiles checks the nearest-rank percentiles of a block of varied
+// transactions, with the original and simulated distributions computed separately.
+func TestGasPercentiles(t *testing.T) {
+	// 100 transactions: 21000 * (1..100) originally, with the largest ten doubled
+	// by the repricing. Input order is shuffled to check the values are sorted.
+	txs := make([]TxSummary, 0, 100)
+	for i := 100; i >= 1; i-- {
+		gas := uint64(21000 * i)
+		simulated := gas
+		if i > 90 {
+			simulated *= 2
+		}
+
+		txs = append(txs, TxSummary{Index: uint64(100 - i), OriginalGas: gas, SimulatedGas: simulated})
+	}
+
+	got := gasPercentiles(txs)
+	want := GasPercentiles{
+		Original:  GasDistribution{P50: 21000 * 50, P90: 21000 * 90, P99: 21000 * 99, Max: 21000 * 100},
+		Simulated: GasDistribution{P50: 21000 * 50, P90: 21000 * 90, P99: 2 * 21000 * 99, Max: 2 * 21000 * 100},
+	}
+
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// The input is not reordered
+	if txs[0].OriginalGas != 21000*100 {
+		t.Errorf("transactions were reordered: first = %d", txs[0].OriginalGas)
+	}
+
+	// A single transaction is every percentile; an empty block has none
+	single := gasPercentiles([]TxSummary{{OriginalGas: 50000, SimulatedGas: 40000}})
+	if single.Original != (GasDistribution{P50: 50000, P90: 50000, P99: 50000, Max: 50000}) ||
+		single.Simulated.P50 != 40000 {
+		t.Errorf("single transaction = %+v", single)
+	}
+
+	if empty := gasPercentiles(nil); empty != (GasPercentiles{}) {
+		t.Errorf("empty block = %+v, want zero values", empty)
+	}
+}
+
+func TestDeltaPercent(t *testing.T) {
+	tests := []struct {
+		original, simulated uint64
+		want                float64
+	}{
+		{original: 0, simulated: 100, want: 0},
+		{original: 100, simulated: 150, want: 50},
+		{original: 200, simulated: 50, want: -75},
+		{original: 21000, simulated: 21000, want: 0},
+	}
+
+	for _, tc := range tests {
+		if got := deltaPercent(tc.original, tc.simulated); got != tc.want {
+			t.Errorf("deltaPercent(%d, %d) = %v, want %v", tc.original, tc.simulated, got, tc.want)
+		}
+	}
+}
+
+// TestOpcodeSummaryMerge verifies that merging per-transaction summaries widens
+// the cost range and ignores the range of a side that never executed.
+func TestOpcodeSummaryMerge(t *testing.T) {
+	var block OpcodeSummary
+
+	block.merge(OpcodeSummary{
+		OriginalCount: 2, OriginalGas: 2200, OriginalMinGas: 100, OriginalMaxGas: 2100,
+	})
+	block.merge(OpcodeSummary{
+		OriginalCount: 1, OriginalGas: 2100, OriginalMinGas: 2100, OriginalMaxGas: 2100,
+		SimulatedCount: 1, SimulatedGas: 500, SimulatedMinGas: 500, SimulatedMaxGas: 500,
+	})
+	block.merge(OpcodeSummary{
+		SimulatedCount: 2, SimulatedGas: 5500, SimulatedMinGas: 500, SimulatedMaxGas: 5000,
+	})
+
+	want := OpcodeSummary{
+		OriginalCount: 3, OriginalGas: 4300, OriginalMinGas: 100, OriginalMaxGas: 2100,
+		SimulatedCount: 3, SimulatedGas: 6000, SimulatedMinGas: 500, SimulatedMaxGas: 5000,
+	}
+
+	if block != want {
+		t.Errorf("merged = %+v, want %+v", block, want)
+	}
+}
+
+// TestGasAccountingInvariant verifies that the breakdown entries, TX_INTRINSIC
+// included, sum to the intrinsic plus execution gas of both executions, and that a
+// transaction rejected before execution contributes no intrinsic gas.
+func TestGasAccountingInvariant(t *testing.T) {
+	result := &SimulateBlockGasResult{OpcodeBreakdown: make(map[string]OpcodeSummary)}
+
+	result.addDualResult("0x01", 0, &dualExecutionResult{
+		Original:  &executionResult{GasUsed: 23103, IntrinsicGas: 21000, ExecutionGas: 2103, Status: "success"},
+		Simulated: &executionResult{GasUsed: 25103, IntrinsicGas: 21000, ExecutionGas: 4103, Status: "success"},
+		OpcodeBreakdown: map[string]OpcodeSummary{
+			"SLOAD": {OriginalCount: 1, OriginalGas: 2100, SimulatedCount: 1, SimulatedGas: 4100},
+			"PUSH1": {OriginalCount: 1, OriginalGas: 3, SimulatedCount: 1, SimulatedGas: 3},
+		},
+	})
+
+	// Simulated intrinsic gas override exceeds the gas limit: nothing is charged
+	result.addDualResult("0x02", 1, &dualExecutionResult{
+		Original:  &executionResult{GasUsed: 21000, IntrinsicGas: 21000, Status: "success"},
+		Simulated: &executionResult{IntrinsicGas: 90000, Status: "failed", ApplyErr: errors.New("intrinsic gas too low")},
+	})
+
+	for _, side := range []struct {
+		name       string
+		accounting GasAccounting
+		gas        func(OpcodeSummary) uint64
+		want       uint64
+	}{
+		{"original", result.Accounting.Original, func(s OpcodeSummary) uint64 { return s.OriginalGas }, 23103 + 21000},
+		{"simulated", result.Accounting.Simulated, func(s OpcodeSummary) uint64 { return s.SimulatedGas }, 25103},
+	} {
+		var sum uint64
+		for _, summary := range result.OpcodeBreakdown {
+			sum += side.gas(summary)
+		}
+
+		if sum != side.want {
+			t.Errorf("%s: breakdown sums to %d, want %d", side.name, sum, side.want)
+		}
+
+		a := side.accounting
+		if a.OpcodeGas+a.IntrinsicGas != sum || a.IntrinsicGas+a.ExecutionGas != side.want {
+			t.Errorf("%s: accounting %+v does not match breakdown sum %d", side.name, a, sum)
+		}
+
+		if a.UnattributedGas != 0 {
+			t.Errorf("%s: unattributed gas = %d, want 0", side.name, a.UnattributedGas)
+		}
+	}
+
+	// Gas consumed outside traced opcodes shows up as unattributed
+	var a GasAccounting
+	a.add(&executionResult{IntrinsicGas: 21000, ExecutionGas: 5000}, 3000)
+	if a.UnattributedGas != 2000 {
+		t.Errorf("unattributed gas = %d, want 2000", a.UnattributedGas)
+	}
+}
+
+// TestTxSummaryExecError verifies that a transaction that runs out of gas at the
+// top level only under the simulated schedule reports the EVM error separately from
+// nested call errors and pre-execution errors.
+func TestTxSummaryExecError(t *testing.T) {
+	summary := newTxSummary("0x01", 0, &dualExecutionResult{
+		Original:  &executionResult{GasUsed: 50000, IntrinsicGas: 21000, Status: "success"},
+		Simulated: &executionResult{GasUsed: 60000, IntrinsicGas: 21000, Status: "failed", Err: vm.ErrOutOfGas},
+	})
+
+	if summary.OriginalExecError != "" {
+		t.Errorf("OriginalExecError = %q, want empty", summary.OriginalExecError)
+	}
+
+	if summary.SimulatedExecError != vm.ErrOutOfGas.Error() {
+		t.Errorf("SimulatedExecError = %q, want %q", summary.SimulatedExecError, vm.ErrOutOfGas.Error())
+	}
+
+	if summary.Error != "" || len(summary.SimulatedErrors) != 0 {
+		t.Errorf("top-level EVM error leaked into Error %q or SimulatedErrors %v", summary.Error, summary.SimulatedErrors)
+	}
+}
+
+// TestComputeGasPercents verifies that, without refunds, the opcode shares sum to
+// 100% minus the intrinsic gas share, and to 100% with TX_INTRINSIC included.
+func TestComputeGasPercents(t *testing.T) {
+	newDual := func(sload, add uint64) *dualExecutionResult {
+		return &dualExecutionResult{
+			Original:  &executionResult{GasUsed: 21000 + sload + add, IntrinsicGas: 21000, Status: "success"},
+			Simulated: &executionResult{GasUsed: 21000 + 2*sload + add, IntrinsicGas: 21000, Status: "success"},
+			OpcodeBreakdown: map[string]OpcodeSummary{
+				"SLOAD": {OriginalCount: 1, OriginalGas: sload, SimulatedCount: 1, SimulatedGas: 2 * sload},
+				"ADD":   {OriginalCount: 1, OriginalGas: add, SimulatedCount: 1, SimulatedGas: add},
+			},
+		}
+	}
+
+	result := &SimulateBlockGasResult{OpcodeBreakdown: make(map[string]OpcodeSummary)}
+	result.addDualResult("0x01", 0, newDual(2100, 3))
+	result.addDualResult("0x02", 1, newDual(100, 9))
+	result.computeGasPercents()
+
+	var original, simulated float64
+	for opcode, summary := range result.OpcodeBreakdown {
+		if opcode == "TX_INTRINSIC" {
+			continue
+		}
+
+		original += summary.OriginalGasPercent
+		simulated += summary.SimulatedGasPercent
+	}
+
+	intrinsic := result.OpcodeBreakdown["TX_INTRINSIC"]
+
+	const epsilon = 1e-9
+
+	if want := 100 - intrinsic.OriginalGasPercent; math.Abs(original-want) > epsilon {
+		t.Errorf("original shares sum to %v, want %v", original, want)
+	}
+
+	if want := 100 - intrinsic.SimulatedGasPercent; math.Abs(simulated-want) > epsilon {
+		t.Errorf("simulated shares sum to %v, want %v", simulated, want)
+	}
+
+	if want := gasPercent(42000, result.Original.GasUsed); intrinsic.OriginalGasPercent != want {
+		t.Errorf("intrinsic share = %v, want %v", intrinsic.OriginalGasPercent, want)
+	}
+
+	if gasPercent(100, 0) != 0 {
+		t.Error("expected 0% of an empty total")
+	}
+}
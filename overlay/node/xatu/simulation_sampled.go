@@ -0,0 +1,222 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/erigontech/erigon/execution/chain"
+)
+
+// maxSampledBlocks bounds the number of blocks a single sampled simulation may
+// execute, so a small stride over a large range cannot tie up the node.
+const maxSampledBlocks = 1000
+
+// SimulateSampledGasRequest is the request for xatu_simulateSampledGas.
+// Blocks StartBlock, StartBlock+Stride, StartBlock+2*Stride, ... up to and
+// including EndBlock are simulated.
+type SimulateSampledGasRequest struct {
+	StartBlock  uint64             `json:"startBlock"`
+	EndBlock    uint64             `json:"endBlock"`
+	Stride      uint64             `json:"stride"`
+	GasSchedule *CustomGasSchedule `json:"gasSchedule"`
+	MaxGasLimit bool               `json:"maxGasLimit"`
+	// EnforceBalanceCheck is passed through to each sampled block simulation.
+	EnforceBalanceCheck bool `json:"enforceBalanceCheck,omitempty"`
+	// EnforceGasCap is passed through to each sampled block simulation.
+	EnforceGasCap *bool `json:"enforceGasCap,omitempty"`
+	// ChainConfigOverride is passed through to each sampled block simulation.
+	ChainConfigOverride *chain.Config `json:"chainConfigOverride,omitempty"`
+	// DisableAccessList is passed through to each sampled block simulation.
+	DisableAccessList bool `json:"disableAccessList,omitempty"`
+	// LegacyRefunds is passed through to each sampled block simulation.
+	LegacyRefunds bool `json:"legacyRefunds,omitempty"`
+	// ForceAllCold is passed through to each sampled block simulation.
+	ForceAllCold bool `json:"forceAllCold,omitempty"`
+	// DisabledPrecompiles and PrecompileOverrides are passed through to each
+	// sampled block simulation.
+	DisabledPrecompiles []string          `json:"disabledPrecompiles,omitempty"`
+	PrecompileOverrides map[string]string `json:"precompileOverrides,omitempty"`
+	// BaseSchedule is passed through to each sampled block simulation.
+	BaseSchedule string `json:"baseSchedule,omitempty"`
+}
+
+// SampledBlockSummary summarizes the simulation of one sampled block.
+type SampledBlockSummary struct {
+	BlockNumber      uint64  `json:"blockNumber"`
+	TxCount          int     `json:"txCount"`
+	OriginalGas      uint64  `json:"originalGas"`
+	SimulatedGas     uint64  `json:"simulatedGas"`
+	DeltaPercent     float64 `json:"deltaPercent"`
+	WouldExceedLimit bool    `json:"wouldExceedLimit"`
+}
+
+// DeltaDistribution describes the spread of per-block gas delta percentages.
+type DeltaDistribution struct {
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// SimulateSampledGasResult is the result of xatu_simulateSampledGas.
+type SimulateSampledGasResult struct {
+	SampledBlocks int `json:"sampledBlocks"`
+	// EmptyBlocks counts sampled blocks without gas usage; they are excluded
+	// from the delta distribution since their delta is undefined.
+	EmptyBlocks       int                   `json:"emptyBlocks"`
+	OriginalGasTotal  uint64                `json:"originalGasTotal"`
+	SimulatedGasTotal uint64                `json:"simulatedGasTotal"`
+	DeltaPercent      DeltaDistribution     `json:"deltaPercent"`
+	Blocks            []SampledBlockSummary `json:"blocks"`
+}
+
+// SimulateSampledGas estimates the chain-wide impact of a gas schedule by simulating
+// every Stride-th block in a range. Each sampled block is simulated with
+// SimulateBlockGas, and the distribution of per-block deltas is computed server-side.
+func (s *Service) SimulateSampledGas(
+	ctx context.Context,
+	req SimulateSampledGasRequest,
+) (*SimulateSampledGasResult, error) {
+	if req.Stride == 0 {
+		return nil, fmt.Errorf("stride must be greater than zero")
+	}
+
+	if req.EndBlock < req.StartBlock {
+		return nil, fmt.Errorf("end block %d is before start block %d", req.EndBlock, req.StartBlock)
+	}
+
+	if err := validateChainConfigOverride(req.ChainConfigOverride); err != nil {
+		return nil, err
+	}
+
+	// Compare before adding the first block: a full uint64 range overflows the count
+	if intervals := (req.EndBlock - req.StartBlock) / req.Stride; intervals >= maxSampledBlocks {
+		return nil, fmt.Errorf("range would sample more than %d blocks (increase the stride)", maxSampledBlocks)
+	}
+
+	samples := (req.EndBlock-req.StartBlock)/req.Stride + 1
+
+	result := &SimulateSampledGasResult{
+		Blocks: make([]SampledBlockSummary, 0, samples),
+	}
+
+	deltas := make([]float64, 0, samples)
+
+	for blockNum := req.StartBlock; blockNum <= req.EndBlock; blockNum += req.Stride {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		blockResult, err := s.SimulateBlockGas(ctx, SimulateBlockGasRequest{
+			BlockNumber:         blockNum,
+			GasSchedule:         req.GasSchedule,
+			MaxGasLimit:         req.MaxGasLimit,
+			EnforceBalanceCheck: req.EnforceBalanceCheck,
+			EnforceGasCap:       req.EnforceGasCap,
+			ChainConfigOverride: req.ChainConfigOverride,
+			DisableAccessList:   req.DisableAccessList,
+			LegacyRefunds:       req.LegacyRefunds,
+			ForceAllCold:        req.ForceAllCold,
+			DisabledPrecompiles: req.DisabledPrecompiles,
+			PrecompileOverrides: req.PrecompileOverrides,
+			BaseSchedule:        req.BaseSchedule,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to simulate block %d: %w", blockNum, err)
+		}
+
+		summary := SampledBlockSummary{
+			BlockNumber:      blockNum,
+			TxCount:          len(blockResult.Transactions),
+			OriginalGas:      blockResult.Original.GasUsed,
+			SimulatedGas:     blockResult.Simulated.GasUsed,
+			WouldExceedLimit: blockResult.Simulated.WouldExceedLimit,
+		}
+
+		if summary.OriginalGas > 0 {
+			summary.DeltaPercent = deltaPercent(summary.OriginalGas, summary.SimulatedGas)
+			deltas = append(deltas, summary.DeltaPercent)
+		} else {
+			result.EmptyBlocks++
+		}
+
+		result.OriginalGasTotal += summary.OriginalGas
+		result.SimulatedGasTotal += summary.SimulatedGas
+		result.Blocks = append(result.Blocks, summary)
+
+		// Guard against overflow when EndBlock is near the top of the uint64 range
+		if blockNum > math.MaxUint64-req.Stride {
+			break
+		}
+	}
+
+	result.SampledBlocks = len(result.Blocks)
+	result.DeltaPercent = deltaDistribution(deltas)
+
+	return result, nil
+}
+
+// deltaDistribution computes summary statistics over a set of delta percentages.
+// Percentiles use the nearest-rank method. Returns zero values for an empty set.
+func deltaDistribution(deltas []float64) DeltaDistribution {
+	if len(deltas) == 0 {
+		return DeltaDistribution{}
+	}
+
+	sorted := make([]float64, len(deltas))
+	copy(sorted, deltas)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, d := range sorted {
+		sum += d
+	}
+
+	return DeltaDistribution{
+		Mean:   sum / float64(len(sorted)),
+		Median: percentile(sorted, 50),
+		P95:    percentile(sorted, 95),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the p-th percentile of sorted values using the nearest-rank method.
+func percentile[T any](sorted []T, p float64) T {
+	if len(sorted) == 0 {
+		var zero T
+		return zero
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+
+	return sorted[rank-1]
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestDeltaDistribution(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []float64
+		want   DeltaDistribution
+	}{
+		{
+			name:   "empty",
+			deltas: nil,
+			want:   DeltaDistribution{},
+		},
+		{
+			name:   "single",
+			deltas: []float64{4.5},
+			want:   DeltaDistribution{Mean: 4.5, Median: 4.5, P95: 4.5, Min: 4.5, Max: 4.5},
+		},
+		{
+			name:   "unsorted input",
+			deltas: []float64{10, -2, 4, 0, 8},
+			want:   DeltaDistribution{Mean: 4, Median: 4, P95: 10, Min: -2, Max: 10},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := deltaDistribution(tc.deltas); got != tc.want {
+				t.Errorf("deltaDistribution() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPercentileNearestRank(t *testing.T) {
+	sorted := make([]float64, 100)
+	for i := range sorted {
+		sorted[i] = float64(i + 1)
+	}
+
+	for p, want := range map[float64]float64{0: 1, 50: 50, 95: 95, 100: 100} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestSimulateSampledGasValidation(t *testing.T) {
+	s := &Service{}
+
+	tests := []struct {
+		name string
+		req  SimulateSampledGasRequest
+	}{
+		{name: "zero stride", req: SimulateSampledGasRequest{StartBlock: 1, EndBlock: 10}},
+		{name: "inverted range", req: SimulateSampledGasRequest{StartBlock: 10, EndBlock: 1, Stride: 1}},
+		{name: "too many samples", req: SimulateSampledGasRequest{StartBlock: 0, EndBlock: maxSampledBlocks, Stride: 1}},
+		{name: "full uint64 range", req: SimulateSampledGasRequest{StartBlock: 0, EndBlock: math.MaxUint64, Stride: 1}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := s.SimulateSampledGas(context.Background(), tc.req); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "context"
+
+// OpcodeStep is a single executed opcode in an ordered simulation trace.
+// Cost is the gas charged by the EVM for the opcode, as in debug_traceTransaction:
+// for CALL-family opcodes it includes the gas forwarded to the child frame.
+type OpcodeStep struct {
+	PC    uint64 `json:"pc"`
+	Op    string `json:"op"`
+	Gas   uint64 `json:"gas"` // Gas remaining before the opcode executed
+	Cost  uint64 `json:"cost"`
+	Depth int    `json:"depth"`
+}
+
+// SimulateTransactionGasDetailedResult is the result of xatu_simulateTransactionGasDetailed.
+// It extends the summary with the full ordered opcode sequence of both executions.
+type SimulateTransactionGasDetailedResult struct {
+	*SimulateTransactionGasResult
+	OriginalSequence  []OpcodeStep `json:"originalSequence"`
+	SimulatedSequence []OpcodeStep `json:"simulatedSequence"`
+}
+
+// SimulateTransactionGasDetailed re-executes a single transaction with a custom gas
+// schedule and returns every executed opcode in order, for both executions.
+//
+// The response size is proportional to the number of executed opcodes (tens of bytes
+// per step), so large transactions can produce responses of hundreds of megabytes.
+// Prefer xatu_simulateTransactionGas unless the per-step trace is needed.
+func (s *Service) SimulateTransactionGasDetailed(
+	ctx context.Context,
+	req SimulateTransactionGasRequest,
+) (*SimulateTransactionGasDetailedResult, error) {
+	result, dualResult, err := s.simulateTransaction(ctx, req, SimulationTracerConfig{
+		TrackAccessList: true,
+		ClassifyCalls:   true,
+		TrackContracts:  true,
+		RecordSequence:  true,
+
+		CaptureSenderState: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SimulateTransactionGasDetailedResult{
+		SimulateTransactionGasResult: result,
+		OriginalSequence:             dualResult.OriginalSequence,
+		SimulatedSequence:            dualResult.SimulatedSequence,
+	}, nil
+}
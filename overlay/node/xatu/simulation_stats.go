@@ -0,0 +1,324 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"slices"
+	"sort"
+)
+
+// gasDelta returns the absolute difference between simulated and original gas.
+func (t *TxSummary) gasDelta() uint64 {
+	if t.SimulatedGas > t.OriginalGas {
+		return t.SimulatedGas - t.OriginalGas
+	}
+
+	return t.OriginalGas - t.SimulatedGas
+}
+
+// topDeltas returns the n transactions with the largest absolute gas delta,
+// largest first. Ties are broken by transaction index. The input is not modified.
+func topDeltas(txs []TxSummary, n int) []TxSummary {
+	if n <= 0 {
+		return nil
+	}
+
+	sorted := make([]TxSummary, len(txs))
+	copy(sorted, txs)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		di, dj := sorted[i].gasDelta(), sorted[j].gasDelta()
+		if di != dj {
+			return di > dj
+		}
+
+		return sorted[i].Index < sorted[j].Index
+	})
+
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+
+	return sorted
+}
+
+// GasDistribution summarizes per-transaction gas under one pricing. Percentiles
+// use the nearest-rank method.
+type GasDistribution struct {
+	P50 uint64 `json:"p50"`
+	P90 uint64 `json:"p90"`
+	P99 uint64 `json:"p99"`
+	Max uint64 `json:"max"`
+}
+
+// GasPercentiles is the distribution of per-transaction gas in a block under the
+// original and simulated pricing.
+type GasPercentiles struct {
+	Original  GasDistribution `json:"original"`
+	Simulated GasDistribution `json:"simulated"`
+}
+
+// gasPercentiles computes the per-transaction gas distributions of txs. Returns zero
+// values for an empty block.
+func gasPercentiles(txs []TxSummary) GasPercentiles {
+	original := make([]uint64, len(txs))
+	simulated := make([]uint64, len(txs))
+
+	for i := range txs {
+		original[i] = txs[i].OriginalGas
+		simulated[i] = txs[i].SimulatedGas
+	}
+
+	return GasPercentiles{
+		Original:  gasDistribution(original),
+		Simulated: gasDistribution(simulated),
+	}
+}
+
+// gasDistribution sorts gas in place and returns its percentiles.
+func gasDistribution(gas []uint64) GasDistribution {
+	if len(gas) == 0 {
+		return GasDistribution{}
+	}
+
+	slices.Sort(gas)
+
+	return GasDistribution{
+		P50: percentile(gas, 50),
+		P90: percentile(gas, 90),
+		P99: percentile(gas, 99),
+		Max: gas[len(gas)-1],
+	}
+}
+
+// OpcodeDelta is the change in an opcode's total gas between the original and
+// simulated executions.
+type OpcodeDelta struct {
+	Opcode        string  `json:"opcode"`
+	OriginalGas   uint64  `json:"originalGas"`
+	SimulatedGas  uint64  `json:"simulatedGas"`
+	Delta         int64   `json:"delta"`         // SimulatedGas - OriginalGas
+	PercentChange float64 `json:"percentChange"` // Relative to OriginalGas (0 if it was 0)
+}
+
+// topMovers returns the opcodes whose gas changed, sorted by absolute gas delta
+// (largest first). Ties are broken by opcode name. Opcodes whose gas was
+// unaffected are left out.
+func topMovers(breakdown map[string]OpcodeSummary) []OpcodeDelta {
+	movers := make([]OpcodeDelta, 0, len(breakdown))
+
+	for op, summary := range breakdown {
+		if summary.OriginalGas == summary.SimulatedGas {
+			continue
+		}
+
+		movers = append(movers, OpcodeDelta{
+			Opcode:        op,
+			OriginalGas:   summary.OriginalGas,
+			SimulatedGas:  summary.SimulatedGas,
+			Delta:         int64(summary.SimulatedGas) - int64(summary.OriginalGas),
+			PercentChange: deltaPercent(summary.OriginalGas, summary.SimulatedGas),
+		})
+	}
+
+	sort.Slice(movers, func(i, j int) bool {
+		di, dj := absDelta(movers[i].Delta), absDelta(movers[j].Delta)
+		if di != dj {
+			return di > dj
+		}
+
+		return movers[i].Opcode < movers[j].Opcode
+	})
+
+	return movers
+}
+
+// absDelta returns the magnitude of a signed gas delta.
+func absDelta(d int64) uint64 {
+	if d < 0 {
+		return uint64(-d)
+	}
+
+	return uint64(d)
+}
+
+// deltaPercent returns the relative change from original to simulated gas, in percent.
+// It is computed directly from the integer totals rather than accumulated from
+// per-opcode values, so the result is independent of iteration order.
+func deltaPercent(original, simulated uint64) float64 {
+	if original == 0 {
+		return 0
+	}
+
+	return (float64(simulated) - float64(original)) / float64(original) * 100
+}
+
+// diverged reports whether the execution paths diverged: the opcode counts differ
+// or the status changed between the original and simulated executions.
+func (d *dualExecutionResult) diverged() bool {
+	return d.Original.OpcodeCount != d.Simulated.OpcodeCount ||
+		d.Original.Status != d.Simulated.Status
+}
+
+// newTxSummary summarizes the gas impact of a transaction's dual execution.
+func newTxSummary(hash string, txIndex int, dual *dualExecutionResult) TxSummary {
+	// GasUsed from ApplyMessage already includes intrinsic gas
+	originalGas := dual.Original.GasUsed
+	simulatedGas := dual.Simulated.GasUsed
+
+	// Surface pre-execution errors (e.g. "intrinsic gas too low") from either execution
+	var txError string
+	if dual.Original.ApplyErr != nil {
+		txError = "original: " + dual.Original.ApplyErr.Error()
+	} else if dual.Simulated.ApplyErr != nil {
+		txError = dual.Simulated.ApplyErr.Error()
+	}
+
+	// Surface recovered panics from either execution. The flag is tracked apart
+	// from the message, which may be empty (e.g. panic(""))
+	panicked := dual.Original.Panicked || dual.Simulated.Panicked
+
+	var panicMsg string
+	if dual.Original.Panicked {
+		panicMsg = "original: " + dual.Original.PanicMessage
+	} else if dual.Simulated.Panicked {
+		panicMsg = dual.Simulated.PanicMessage
+	}
+
+	return TxSummary{
+		Hash:                     hash,
+		Index:                    uint64(txIndex),
+		OriginalStatus:           dual.Original.Status,
+		SimulatedStatus:          dual.Simulated.Status,
+		OriginalGas:              originalGas,
+		SimulatedGas:             simulatedGas,
+		DeltaPercent:             deltaPercent(originalGas, simulatedGas),
+		Diverged:                 dual.diverged(),
+		OriginalReverts:          dual.Original.RevertCount,
+		SimulatedReverts:         dual.Simulated.RevertCount,
+		OriginalErrors:           dual.Original.CallErrors,
+		SimulatedErrors:          dual.Simulated.CallErrors,
+		OriginalPeakMemoryBytes:  dual.Original.PeakMemory,
+		SimulatedPeakMemoryBytes: dual.Simulated.PeakMemory,
+		OriginalGasRemaining:     dual.Original.gasRemaining(),
+		SimulatedGasRemaining:    dual.Simulated.gasRemaining(),
+		OriginalExecError:        errorString(dual.Original.Err),
+		SimulatedExecError:       errorString(dual.Simulated.Err),
+		Error:                    txError,
+		Panicked:                 panicked,
+		PanicMessage:             panicMsg,
+	}
+}
+
+// gasRemaining returns the gas returned to the sender: the gas limit minus the
+// gas used, or 0 if a refund cap override left GasUsed above the limit.
+func (r *executionResult) gasRemaining() uint64 {
+	if r.GasLimit > r.GasUsed {
+		return r.GasLimit - r.GasUsed
+	}
+
+	return 0
+}
+
+// errorString returns the error's message, or "" for a nil error.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+// addDualResult appends a transaction's summary to the block result and accumulates
+// its gas and opcode breakdown into the block totals. All accumulation is integer
+// arithmetic, so the totals do not depend on map iteration order.
+func (r *SimulateBlockGasResult) addDualResult(hash string, txIndex int, dual *dualExecutionResult) {
+	r.Transactions = append(r.Transactions, newTxSummary(hash, txIndex, dual))
+
+	// Accumulate totals
+	r.Original.addTransaction(dual.Original.GasUsed, dual.Original.Status)
+	r.Simulated.addTransaction(dual.Simulated.GasUsed, dual.Simulated.Status)
+
+	// Reconcile the opcode gas with the EVM's execution gas
+	originalOpcodeGas, simulatedOpcodeGas := opcodeGasTotals(dual.OpcodeBreakdown)
+	r.Accounting.Original.add(dual.Original, originalOpcodeGas)
+	r.Accounting.Simulated.add(dual.Simulated, simulatedOpcodeGas)
+
+	// Aggregate opcode breakdown from both executions
+	for opcode, summary := range dual.OpcodeBreakdown {
+		existing := r.OpcodeBreakdown[opcode]
+		existing.merge(summary)
+		r.OpcodeBreakdown[opcode] = existing
+	}
+
+	// Add intrinsic gas to opcode breakdown so it's visible in the Gas Breakdown tab.
+	// Opcode entries exclude intrinsic gas, so this is its only contribution (see GasAccounting).
+	originalIntrinsic := dual.Original.chargedIntrinsicGas()
+	simulatedIntrinsic := dual.Simulated.chargedIntrinsicGas()
+
+	intrinsic := r.OpcodeBreakdown["TX_INTRINSIC"]
+	intrinsic.merge(OpcodeSummary{
+		OriginalCount:   1,
+		OriginalGas:     originalIntrinsic,
+		OriginalMinGas:  originalIntrinsic,
+		OriginalMaxGas:  originalIntrinsic,
+		SimulatedCount:  1,
+		SimulatedGas:    simulatedIntrinsic,
+		SimulatedMinGas: simulatedIntrinsic,
+		SimulatedMaxGas: simulatedIntrinsic,
+	})
+	r.OpcodeBreakdown["TX_INTRINSIC"] = intrinsic
+}
+
+// summarize derives the block-level fields from the added transactions: whether
+// the gas used exceeds the limit, each opcode's share of the totals, the EIP
+// breakdown and the rankings of the most affected transactions (the topN largest
+// deltas) and opcodes. It must run after all transactions have been added, and
+// after applyBaseFee so the ranked transactions carry their fee deltas.
+func (r *SimulateBlockGasResult) summarize(topN int) {
+	r.Original.WouldExceedLimit = r.Original.GasUsed > r.Original.GasLimit
+	r.Simulated.WouldExceedLimit = r.Simulated.GasUsed > r.Simulated.GasLimit
+
+	r.computeGasPercents()
+	r.EIPBreakdown = eipBreakdown(r.OpcodeBreakdown, 0, 0)
+
+	// Rank the most affected transactions (no re-execution needed)
+	r.TopDeltas = topDeltas(r.Transactions, topN)
+	r.TopMovers = topMovers(r.OpcodeBreakdown)
+	r.GasPercentiles = gasPercentiles(r.Transactions)
+}
+
+// gasPercent returns gas as a percentage of total, or 0 if total is 0.
+func gasPercent(gas, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(gas) / float64(total) * 100
+}
+
+// computeGasPercents sets each opcode's share of the block's original and simulated
+// gas used. It must run after all transactions have been added.
+func (r *SimulateBlockGasResult) computeGasPercents() {
+	for opcode, summary := range r.OpcodeBreakdown {
+		summary.OriginalGasPercent = gasPercent(summary.OriginalGas, r.Original.GasUsed)
+		summary.SimulatedGasPercent = gasPercent(summary.SimulatedGas, r.Simulated.GasUsed)
+		r.OpcodeBreakdown[opcode] = summary
+	}
+}
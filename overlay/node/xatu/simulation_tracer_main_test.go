@@ -0,0 +1,918 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/common/hexutil"
+	"github.com/erigontech/erigon/execution/chain"
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/tracing"
+	"github.com/erigontech/erigon/execution/types/accounts"
+	"github.com/erigontech/erigon/execution/vm"
+	"github.com/erigontech/erigon/rpc/ethapi"
+)
+
+// =============================================================================
+// SimulationTracer Unit Tests
+// =============================================================================
+
+// TestSimulationTracerAccessTracking verifies that account and storage accesses
+// are recorded from the stack when access tracking is enabled.
+func TestSimulationTracerAccessTracking(t *testing.T) {
+	ctx := newMockOpContext(10)
+	ctx.stack[len(ctx.stack)-1].SetUint64(0xaa) // BALANCE target / SLOAD slot
+	ctx.stack[len(ctx.stack)-2].SetUint64(0xbb) // CALL target
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{TrackAccessList: true})
+	tracer.OnOpcode(0, byte(vm.BALANCE), 100000, 2600, ctx, nil, 1, nil)
+	tracer.OnOpcode(1, byte(vm.SLOAD), 100000, 2100, ctx, nil, 1, nil)
+	tracer.OnOpcode(2, byte(vm.STATICCALL), 100000, 2600, ctx, nil, 1, nil)
+
+	access := tracer.accesses()
+	if access == nil {
+		t.Fatal("expected access tracker to be enabled")
+	}
+
+	for _, addr := range []string{
+		"0x00000000000000000000000000000000000000aa",
+		"0x00000000000000000000000000000000000000bb",
+		normalizeAddress(ctx.addr.String()),
+	} {
+		if _, ok := access.addresses[addr]; !ok {
+			t.Errorf("expected address %s to be recorded", addr)
+		}
+	}
+
+	slot := "0x00000000000000000000000000000000000000000000000000000000000000aa"
+	if _, ok := access.slots[normalizeAddress(ctx.addr.String())][slot]; !ok {
+		t.Errorf("expected slot %s to be recorded", slot)
+	}
+
+	// Disabled by default
+	if NewSimulationTracer(nil, SimulationTracerConfig{}).accesses() != nil {
+		t.Error("expected access tracking to be disabled by default")
+	}
+}
+
+// TestSimulationTracerColdAccessCount verifies that each distinct address and
+// slot is counted once, on its first access, and that the sender, recipient and
+// precompiles are not counted.
+func TestSimulationTracerColdAccessCount(t *testing.T) {
+	eoa := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000e0"))
+	contract := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000c1"))
+	other := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000bb"))
+	ecrecover := accounts.InternAddress(common.HexToAddress("0x0000000000000000000000000000000000000001"))
+
+	op := func(tracer *SimulationTracer, opcode vm.OpCode, top uint64) {
+		ctx := &mockOpContext{addr: contract, stack: make([]uint256.Int, 2)}
+		ctx.stack[1].SetUint64(top)
+		ctx.stack[0].SetUint64(top) // CALL-family targets are second from the top
+		tracer.OnOpcode(0, byte(opcode), 90000, 100, ctx, nil, 1, nil)
+	}
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{TrackAccessList: true})
+	tracer.precompiles = vm.PrecompiledContracts{ecrecover: nil}
+	tracer.OnTxStart(nil, nil, eoa)
+	tracer.OnEnter(0, byte(vm.CALL), eoa, contract, false, nil, 100000, uint256.Int{}, nil)
+
+	op(tracer, vm.SLOAD, 1)      // cold
+	op(tracer, vm.SLOAD, 1)      // warm
+	op(tracer, vm.SSTORE, 2)     // cold
+	op(tracer, vm.SLOAD, 3)      // cold
+	op(tracer, vm.BALANCE, 0xaa) // cold
+	op(tracer, vm.BALANCE, 0xaa) // warm
+	op(tracer, vm.BALANCE, 0xe0) // sender, warm
+	op(tracer, vm.STATICCALL, 0xbb)
+	tracer.OnEnter(1, byte(vm.STATICCALL), contract, other, false, nil, 10000, uint256.Int{}, nil) // cold
+	tracer.OnExit(1, nil, 100, nil, false)
+	op(tracer, vm.STATICCALL, 0x01)
+	tracer.OnEnter(1, byte(vm.STATICCALL), contract, ecrecover, false, nil, 10000, uint256.Int{}, nil) // precompile, warm
+	tracer.OnExit(1, nil, 3000, nil, false)
+	tracer.OnExit(0, nil, 50000, nil, false)
+
+	// Slots 1-3, address 0xaa and address 0xbb
+	if got := tracer.accesses().coldAccessCount(); got != 5 {
+		t.Errorf("cold access count = %d, want 5", got)
+	}
+
+	tracer.Reset()
+	if got := tracer.accesses().coldAccessCount(); got != 0 {
+		t.Errorf("cold access count after Reset = %d, want 0", got)
+	}
+}
+
+// TestColdAccessCountWarmAtStart verifies that the coinbase is warm from Shanghai
+// (EIP-3651) and that an address created by CREATE/CREATE2 is not counted as cold.
+func TestColdAccessCountWarmAtStart(t *testing.T) {
+	eoa := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000e0"))
+	contract := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000c1"))
+	created := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000c2"))
+	coinbase := common.HexToAddress("0x00000000000000000000000000000000000000cb")
+
+	for _, tc := range []struct {
+		name     string
+		shanghai bool
+		want     uint64
+	}{
+		{name: "before Shanghai", shanghai: false, want: 1},
+		{name: "Shanghai", shanghai: true, want: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &chain.Config{ChainID: big.NewInt(1)}
+			if tc.shanghai {
+				config.ShanghaiTime = big.NewInt(0)
+			}
+
+			env := &tracing.VMContext{Coinbase: accounts.InternAddress(coinbase), Time: 1, ChainConfig: config}
+
+			tracer := NewSimulationTracer(nil, SimulationTracerConfig{TrackAccessList: true})
+			tracer.OnTxStart(env, nil, eoa)
+			tracer.OnEnter(0, byte(vm.CALL), eoa, contract, false, nil, 100000, uint256.Int{}, nil)
+
+			ctx := &mockOpContext{addr: contract, stack: make([]uint256.Int, 1)}
+			ctx.stack[0].SetBytes(coinbase[:])
+			tracer.OnOpcode(0, byte(vm.BALANCE), 90000, 100, ctx, nil, 1, nil) // coinbase
+
+			for _, typ := range []vm.OpCode{vm.CREATE, vm.CREATE2} {
+				tracer.OnEnter(1, byte(typ), contract, created, false, nil, 10000, uint256.Int{}, nil)
+				tracer.OnExit(1, nil, 100, nil, false)
+			}
+
+			tracer.OnExit(0, nil, 50000, nil, false)
+
+			if got := tracer.accesses().coldAccessCount(); got != tc.want {
+				t.Errorf("cold access count = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestDiffAccessLists verifies bucketing of addresses and slots into
+// original-only, simulated-only and shared sets.
+func TestDiffAccessLists(t *testing.T) {
+	original := newAccessTracker()
+	original.touchAddress("0x01")
+	original.touchSlot("0x02", "0xa")
+	original.touchSlot("0x02", "0xb")
+
+	simulated := newAccessTracker()
+	simulated.touchSlot("0x02", "0xb")
+	simulated.touchSlot("0x02", "0xc")
+	simulated.touchAddress("0x03")
+
+	diff := diffAccessLists(original, simulated)
+
+	tests := []struct {
+		name    string
+		entries []AccessListEntry
+		want    map[string][]string
+	}{
+		{
+			name:    "original only",
+			entries: diff.OriginalOnly,
+			want:    map[string][]string{"0x01": {}, "0x02": {"0xa"}},
+		},
+		{
+			name:    "simulated only",
+			entries: diff.SimulatedOnly,
+			want:    map[string][]string{"0x02": {"0xc"}, "0x03": {}},
+		},
+		{
+			name:    "both",
+			entries: diff.Both,
+			want:    map[string][]string{"0x02": {"0xb"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.entries) != len(tc.want) {
+				t.Fatalf("got %d entries, want %d: %+v", len(tc.entries), len(tc.want), tc.entries)
+			}
+
+			for _, entry := range tc.entries {
+				keys, ok := tc.want[entry.Address]
+				if !ok {
+					t.Errorf("unexpected address %s", entry.Address)
+					continue
+				}
+
+				if len(entry.StorageKeys) != len(keys) {
+					t.Errorf("%s: got keys %v, want %v", entry.Address, entry.StorageKeys, keys)
+					continue
+				}
+
+				for i := range keys {
+					if entry.StorageKeys[i] != keys[i] {
+						t.Errorf("%s: got keys %v, want %v", entry.Address, entry.StorageKeys, keys)
+					}
+				}
+			}
+		})
+	}
+
+	if diffAccessLists(nil, simulated) != nil {
+		t.Error("expected nil diff when tracking is disabled")
+	}
+}
+
+// callAddr returns bytecode that calls the 2-byte address addr with op, forwarding
+// all gas and no data and, for CALL, transferring value wei.
+func callAddr(op vm.OpCode, addr, value uint16) []byte {
+	code := []byte{0x5f, 0x5f, 0x5f, 0x5f} // No args or return data
+	if op == vm.CALL {
+		code = append(code, 0x61, byte(value>>8), byte(value))
+	}
+
+	return append(code, 0x61, byte(addr>>8), byte(addr), 0x5a, byte(op), 0x50)
+}
+
+// TestSimulationTracerValueTransfers sends 1000 wei to A, which forwards 600 to B
+// and STATICCALLs a sink. B sends 300 to a contract that reverts, DELEGATECALLs
+// the sink with its inherited value, and selfdestructs to A. Only real balance
+// moves are recorded, with the reverted send flagged.
+func TestSimulationTracerValueTransfers(t *testing.T) {
+	a := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	b := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	reverter := common.HexToAddress("0x00000000000000000000000000000000000000c3")
+	sink := common.HexToAddress("0x00000000000000000000000000000000000000d4")
+
+	codeA := append(callAddr(vm.CALL, 0xb2, 600), callAddr(vm.STATICCALL, 0xd4, 0)...)
+	codeB := append(callAddr(vm.CALL, 0xc3, 300), callAddr(vm.DELEGATECALL, 0xd4, 0)...)
+	codeB = append(codeB, 0x60, 0xa1, 0xff) // SELFDESTRUCT(A)
+
+	c := newTestChain(t, map[common.Address][]byte{
+		a:        append(codeA, 0x00),
+		b:        codeB,
+		reverter: {0x5f, 0x5f, 0xfd}, // REVERT(0, 0)
+		sink:     {0x00},
+	})
+
+	gas := hexutil.Uint64(1_000_000)
+	value := (*hexutil.Big)(big.NewInt(1000))
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{TrackValueTransfers: true})
+
+	result := c.execute(ethapi.CallArgs{From: &testSender, To: &a, Gas: &gas, Value: value}, true, tracer, executionOptions{})
+	if result.ApplyErr != nil || result.Status != "success" {
+		t.Fatalf("transaction failed: status %s, err %v", result.Status, result.ApplyErr)
+	}
+
+	want := []ValueTransfer{
+		{Depth: 0, Type: "CALL", From: normalizeAddress(testSender.Hex()), To: normalizeAddress(a.Hex()), Value: "0x3e8"},
+		{Depth: 1, Type: "CALL", From: normalizeAddress(a.Hex()), To: normalizeAddress(b.Hex()), Value: "0x258"},
+		{Depth: 2, Type: "CALL", From: normalizeAddress(b.Hex()), To: normalizeAddress(reverter.Hex()), Value: "0x12c", Reverted: true},
+		{Depth: 2, Type: "SELFDESTRUCT", From: normalizeAddress(b.Hex()), To: normalizeAddress(a.Hex()), Value: "0x258"},
+	}
+
+	got := tracer.GetValueTransfers()
+	if len(got) != len(want) {
+		t.Fatalf("got %d transfers, want %d: %+v", len(got), len(want), got)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("transfer[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// B's reverted send stayed with it, and its selfdestruct returned all 600 to A
+	if balance, err := c.statedb.GetBalance(accounts.InternAddress(a)); err != nil || balance.Uint64() != 1000 {
+		t.Errorf("balance of A = %d (err %v), want 1000", balance.Uint64(), err)
+	}
+}
+
+// TestSimulationTracerRecordSequence verifies that the ordered opcode trace is
+// only recorded when enabled and preserves execution order.
+func TestSimulationTracerRecordSequence(t *testing.T) {
+	ctx := newMockOpContext(10)
+
+	steps := []OpcodeStep{
+		{PC: 0, Op: "PUSH1", Gas: 100000, Cost: 3, Depth: 1},
+		{PC: 2, Op: "SLOAD", Gas: 99997, Cost: 2100, Depth: 1},
+		{PC: 0, Op: "ADD", Gas: 50000, Cost: 3, Depth: 2},
+	}
+
+	opcodes := map[string]vm.OpCode{"PUSH1": vm.PUSH1, "SLOAD": vm.SLOAD, "ADD": vm.ADD}
+
+	run := func(tracer *SimulationTracer) {
+		for _, step := range steps {
+			tracer.OnOpcode(step.PC, byte(opcodes[step.Op]), step.Gas, step.Cost, ctx, nil, step.Depth, nil)
+		}
+	}
+
+	disabled := NewSimulationTracer(nil, SimulationTracerConfig{})
+	run(disabled)
+
+	if seq := disabled.GetSequence(); seq != nil {
+		t.Errorf("expected no sequence when disabled, got %d steps", len(seq))
+	}
+
+	enabled := NewSimulationTracer(nil, SimulationTracerConfig{RecordSequence: true})
+	run(enabled)
+
+	seq := enabled.GetSequence()
+	if len(seq) != len(steps) {
+		t.Fatalf("got %d steps, want %d", len(seq), len(steps))
+	}
+
+	for i := range steps {
+		if seq[i] != steps[i] {
+			t.Errorf("step[%d] = %+v, want %+v", i, seq[i], steps[i])
+		}
+	}
+
+	// Aggregates are unaffected by sequence recording
+	if enabled.GetTotalGasUsed() != disabled.GetTotalGasUsed() {
+		t.Errorf("total gas differs: %d vs %d", enabled.GetTotalGasUsed(), disabled.GetTotalGasUsed())
+	}
+}
+
+// TestSimulationTracerGasRange verifies that mixed warm and cold SLOADs report
+// the warm cost as the minimum and the cold cost as the maximum.
+func TestSimulationTracerGasRange(t *testing.T) {
+	ctx := newMockOpContext(10)
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{})
+	for i, cost := range []uint64{2100, 100, 100, 2100, 100} {
+		tracer.OnOpcode(uint64(i), byte(vm.SLOAD), 100000, cost, ctx, nil, 1, nil)
+	}
+	tracer.OnOpcode(5, byte(vm.ADD), 100000, 3, ctx, nil, 1, nil)
+
+	breakdown := tracer.GetRawBreakdown()
+
+	sload := breakdown["SLOAD"]
+	if sload.MinGas != 100 || sload.MaxGas != 2100 {
+		t.Errorf("SLOAD range = %d..%d, want 100..2100", sload.MinGas, sload.MaxGas)
+	}
+
+	if sload.Count != 5 || sload.Gas != 4500 {
+		t.Errorf("SLOAD count/gas = %d/%d, want 5/4500", sload.Count, sload.Gas)
+	}
+
+	if add := breakdown["ADD"]; add.MinGas != 3 || add.MaxGas != 3 {
+		t.Errorf("ADD range = %d..%d, want 3..3", add.MinGas, add.MaxGas)
+	}
+
+	tracer.Reset()
+	tracer.OnOpcode(0, byte(vm.SLOAD), 100000, 2100, ctx, nil, 1, nil)
+
+	if sload := tracer.GetRawBreakdown()["SLOAD"]; sload.MinGas != 2100 || sload.MaxGas != 2100 {
+		t.Errorf("after Reset SLOAD range = %d..%d, want 2100..2100", sload.MinGas, sload.MaxGas)
+	}
+}
+
+// TestSimulationTracerCallClassification executes a proxy: called without data,
+// it DELEGATECALLs its implementation, whose code (running as the proxy) calls back
+// into the proxy twice with data, which stops, and calls an external token twice.
+func TestSimulationTracerCallClassification(t *testing.T) {
+	proxy := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	impl := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	token := common.HexToAddress("0x00000000000000000000000000000000000000c3")
+
+	// JUMPI(15, CALLDATASIZE), DELEGATECALL(impl), STOP, JUMPDEST, STOP
+	proxyCode := append([]byte{0x36, 0x60, 0x0f, 0x57}, callAddr(vm.DELEGATECALL, 0xb2, 0)...)
+	proxyCode = append(proxyCode, 0x00, 0x5b, 0x00)
+
+	// STATICCALL and CALL to ADDRESS with one byte of calldata
+	implCode := []byte{
+		0x5f, 0x5f, 0x60, 0x01, 0x5f, 0x30, 0x5a, 0xfa, 0x50,
+		0x5f, 0x5f, 0x60, 0x01, 0x5f, 0x5f, 0x30, 0x5a, 0xf1, 0x50,
+	}
+	implCode = append(implCode, callAddr(vm.CALL, 0xc3, 0)...)
+	implCode = append(implCode, callAddr(vm.STATICCALL, 0xc3, 0)...)
+	implCode = append(implCode, 0x00)
+
+	c := newTestChain(t, map[common.Address][]byte{proxy: proxyCode, impl: implCode, token: {0x00}})
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{ClassifyCalls: true})
+	c.callTraced(proxy, nil, tracer, executionOptions{})
+
+	// The implementation and the token are cold on first call, the token warm after
+	want := CallClassification{Self: 2, Warm: 1, Cold: 2}
+	if got := tracer.GetCallClassification(); got == nil || *got != want {
+		t.Errorf("classification = %+v, want %+v", got, want)
+	}
+
+	tracer.Reset()
+	if got := tracer.GetCallClassification(); *got != (CallClassification{}) {
+		t.Errorf("classification after Reset = %+v, want zero", got)
+	}
+
+	if NewSimulationTracer(nil, SimulationTracerConfig{}).GetCallClassification() != nil {
+		t.Error("expected call classification to be disabled by default")
+	}
+}
+
+// TestSimulationTracerOpcodePairs verifies that pairs are counted within a frame,
+// that CALL pairs receive the call overhead, and that pairs do not span frames.
+func TestSimulationTracerOpcodePairs(t *testing.T) {
+	ctx := newMockOpContext(10)
+	caller := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000a1"))
+	callee := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000b2"))
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{TrackOpcodePairs: true})
+
+	tracer.OnOpcode(0, byte(vm.PUSH1), 100000, 3, ctx, nil, 1, nil)
+	tracer.OnOpcode(2, byte(vm.MSTORE), 100000, 6, ctx, nil, 1, nil)
+	tracer.OnOpcode(3, byte(vm.PUSH1), 100000, 3, ctx, nil, 1, nil)
+	tracer.OnOpcode(5, byte(vm.MSTORE), 100000, 6, ctx, nil, 1, nil)
+	tracer.OnOpcode(6, byte(vm.PUSH1), 100000, 3, ctx, nil, 1, nil)
+	tracer.OnOpcode(8, byte(vm.CALL), 100000, 10000, ctx, nil, 1, nil)
+	tracer.OnEnter(1, byte(vm.CALL), caller, callee, false, nil, 7400, uint256.Int{}, nil)
+	tracer.OnOpcode(0, byte(vm.PUSH1), 7400, 3, ctx, nil, 2, nil)
+	tracer.OnExit(1, nil, 3, nil, false)
+	tracer.OnOpcode(9, byte(vm.POP), 97000, 2, ctx, nil, 1, nil)
+
+	want := []OpcodePair{
+		{Prev: "PUSH1", Curr: "CALL", Count: 1, Gas: 2600},
+		{Prev: "PUSH1", Curr: "MSTORE", Count: 2, Gas: 12},
+		{Prev: "MSTORE", Curr: "PUSH1", Count: 2, Gas: 6},
+	}
+
+	got := tracer.GetOpcodePairs(10)
+	if len(got) != len(want) {
+		t.Fatalf("got %d pairs (%+v), want %d", len(got), got, len(want))
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("pair[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if top := tracer.GetOpcodePairs(1); len(top) != 1 || top[0] != want[0] {
+		t.Errorf("top 1 = %+v, want %+v", top, want[:1])
+	}
+
+	tracer.Reset()
+	if got := tracer.GetOpcodePairs(10); len(got) != 0 {
+		t.Errorf("expected no pairs after Reset, got %+v", got)
+	}
+
+	if NewSimulationTracer(nil, SimulationTracerConfig{}).GetOpcodePairs(10) != nil {
+		t.Error("expected opcode pairs to be disabled by default")
+	}
+}
+
+// TestBigramTrackerBound verifies that once the tracker is full, a new pair
+// replaces the pair with the least gas, so a late pair with the most gas is kept.
+func TestBigramTrackerBound(t *testing.T) {
+	b := newBigramTracker()
+
+	for i := 0; len(b.pairs) < maxTrackedOpcodePairs; i++ {
+		b.breakSequence()
+		b.observe(byte(i>>8), 2)
+		b.observe(byte(i), 2)
+	}
+
+	// The pair with the least gas is the one evicted
+	b.pairs[0x0102].gas = 1
+
+	b.breakSequence()
+	b.observe(0xFE, 1)
+	b.observe(0xFE, 1000)
+
+	if len(b.pairs) != maxTrackedOpcodePairs {
+		t.Errorf("tracked %d pairs, want %d", len(b.pairs), maxTrackedOpcodePairs)
+	}
+
+	if _, ok := b.pairs[0x0102]; ok {
+		t.Error("expected the pair with the least gas to be evicted")
+	}
+
+	if top := b.top(1); len(top) != 1 || top[0].Prev != opcodeStrings[0xFE] || top[0].Gas != 1000 {
+		t.Errorf("top pair = %+v, want the late 0xFE pair with 1000 gas", top)
+	}
+}
+
+// TestSimulationTracerContractBreakdown executes contract A, which reads a cold
+// slot and calls contract B, which sets a slot, and verifies the gas is split
+// between the two addresses with A paying the CALL overhead and none of B's
+// opcodes, though A's CALL forwards the gas B spends.
+func TestSimulationTracerContractBreakdown(t *testing.T) {
+	a := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	b := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+
+	codeA := append([]byte{0x60, 0x00, 0x54, 0x50}, callAddr(vm.CALL, 0xb2, 0)...) // SLOAD(0), CALL(B)
+	codeB := []byte{0x60, 0x01, 0x60, 0x01, 0x55, 0x00}                            // SSTORE(1, 1)
+
+	c := newTestChain(t, map[common.Address][]byte{a: append(codeA, 0x00), b: codeB})
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{TrackContracts: true})
+	c.callTraced(a, nil, tracer, executionOptions{})
+
+	got := tracer.GetContractBreakdown()
+	want := map[string]uint64{
+		// PUSH1 SLOAD POP, 4 PUSH0 PUSH2 PUSH2 GAS, the cold CALL's overhead, POP
+		normalizeAddress(a.Hex()): 3 + 2100 + 2 + 4*2 + 3 + 3 + 2 + 2600 + 2,
+		// PUSH1 PUSH1, SSTORE of a new value to a cold slot
+		normalizeAddress(b.Hex()): 3 + 3 + 22100,
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("breakdown = %v, want %v", got, want)
+	}
+
+	for addr, gas := range want {
+		if got[addr] != gas {
+			t.Errorf("gas for %s = %d, want %d", addr, got[addr], gas)
+		}
+	}
+
+	// Every opcode charge is attributed to exactly one contract
+	if total := got[normalizeAddress(a.Hex())] + got[normalizeAddress(b.Hex())]; total != tracer.GetTotalGasUsed() {
+		t.Errorf("attributed gas = %d, want total %d", total, tracer.GetTotalGasUsed())
+	}
+
+	tracer.Reset()
+	if got := tracer.GetContractBreakdown(); len(got) != 0 {
+		t.Errorf("expected empty breakdown after Reset, got %v", got)
+	}
+
+	if NewSimulationTracer(nil, SimulationTracerConfig{}).GetContractBreakdown() != nil {
+		t.Error("expected contract tracking to be disabled by default")
+	}
+}
+
+// TestSimulationTracerFirstSeenPC runs a contract that stores a word and returns it
+// (PUSH1 PUSH1 MSTORE PUSH1 PUSH1 RETURN) and verifies each opcode keeps the PC of
+// its first execution, in execution order.
+func TestSimulationTracerFirstSeenPC(t *testing.T) {
+	ctx := newMockOpContext(10)
+
+	steps := []struct {
+		pc uint64
+		op vm.OpCode
+	}{
+		{0, vm.PUSH1}, {2, vm.PUSH1}, {4, vm.MSTORE}, {5, vm.PUSH1}, {7, vm.PUSH1}, {9, vm.RETURN},
+	}
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{})
+	for _, step := range steps {
+		tracer.OnOpcode(step.pc, byte(step.op), 100000, 3, ctx, nil, 1, nil)
+	}
+
+	want := map[string]uint32{"PUSH1": 0, "MSTORE": 4, "RETURN": 9}
+
+	got := tracer.GetFirstSeenPCs()
+	if len(got) != len(want) {
+		t.Fatalf("first-seen PCs = %v, want %v", got, want)
+	}
+
+	for op, pc := range want {
+		if got[op] != pc {
+			t.Errorf("first-seen PC of %s = %d, want %d", op, got[op], pc)
+		}
+	}
+
+	// In order of first execution, the PCs increase
+	if !(got["PUSH1"] < got["MSTORE"] && got["MSTORE"] < got["RETURN"]) {
+		t.Errorf("first-seen PCs not in execution order: %v", got)
+	}
+
+	tracer.Reset()
+	tracer.OnOpcode(5, byte(vm.PUSH1), 100000, 3, ctx, nil, 1, nil)
+
+	if got := tracer.GetFirstSeenPCs(); len(got) != 1 || got["PUSH1"] != 5 {
+		t.Errorf("first-seen PCs after Reset = %v, want map[PUSH1:5]", got)
+	}
+}
+
+// TestSimulationTracerLogs verifies that an ERC-20 Transfer event (LOG3) is captured
+// with its topics and data size, that the gas charged to the LOG3 matches those
+// dimensions, and that logs of a reverted frame are flagged.
+func TestSimulationTracerLogs(t *testing.T) {
+	eoa := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000e0"))
+	token := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000a1"))
+
+	transferSig := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	from := common.HexToHash("0x000000000000000000000000000000000000000000000000000000000000beef")
+	to := common.HexToHash("0x000000000000000000000000000000000000000000000000000000000000cafe")
+
+	// LOG3 operands, top of stack last: offset, size, topic0, topic1, topic2
+	ctx := &mockOpContext{addr: token, stack: make([]uint256.Int, 5)}
+	ctx.stack[4].SetUint64(0x80)
+	ctx.stack[3].SetUint64(32)
+	ctx.stack[2].SetBytes(transferSig[:])
+	ctx.stack[1].SetBytes(from[:])
+	ctx.stack[0].SetBytes(to[:])
+
+	// Memory is already expanded, so the charge is the LOG formula alone
+	const logGas = params.LogGas + 3*params.LogTopicGas + 32*params.LogDataGas
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{CaptureLogs: true})
+	tracer.OnEnter(0, byte(vm.CALL), eoa, token, false, nil, 100000, uint256.Int{}, nil)
+	tracer.OnOpcode(10, byte(vm.LOG3), 90000, logGas, ctx, nil, 1, nil)
+	tracer.OnExit(0, nil, 10000, nil, false)
+
+	logs := tracer.GetLogs()
+	if len(logs) != 1 {
+		t.Fatalf("captured %d logs, want 1", len(logs))
+	}
+
+	got := logs[0]
+	if got.Address != normalizeAddress(token.String()) || got.Depth != 0 || got.Reverted {
+		t.Errorf("log = %+v, want an unreverted depth-0 log from %s", got, token)
+	}
+
+	wantTopics := []string{transferSig.Hex(), from.Hex(), to.Hex()}
+	if len(got.Topics) != len(wantTopics) {
+		t.Fatalf("topics = %v, want %v", got.Topics, wantTopics)
+	}
+
+	for i := range wantTopics {
+		if got.Topics[i] != wantTopics[i] {
+			t.Errorf("topic[%d] = %s, want %s", i, got.Topics[i], wantTopics[i])
+		}
+	}
+
+	// The charged gas is what the log's dimensions predict
+	if want := params.LogGas + uint64(len(got.Topics))*params.LogTopicGas + got.DataSize*params.LogDataGas; got.Gas != want || got.DataSize != 32 {
+		t.Errorf("gas = %d for %d topics and %d data bytes, want %d", got.Gas, len(got.Topics), got.DataSize, want)
+	}
+
+	// Logs of a frame that reverts are discarded
+	tracer.Reset()
+	tracer.OnEnter(0, byte(vm.CALL), eoa, token, false, nil, 100000, uint256.Int{}, nil)
+	tracer.OnOpcode(10, byte(vm.LOG3), 90000, logGas, ctx, nil, 1, nil)
+	tracer.OnExit(0, nil, 10000, nil, true)
+
+	if logs := tracer.GetLogs(); len(logs) != 1 || !logs[0].Reverted {
+		t.Errorf("logs after revert = %+v, want one reverted log", logs)
+	}
+}
+
+// TestSimulationTracerStorageChanges verifies that SSTOREs are captured with the
+// slot's values and operation, that a slot's original value is kept across writes
+// in the transaction, and that writes of a failed frame are marked reverted.
+func TestSimulationTracerStorageChanges(t *testing.T) {
+	eoa := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000e0"))
+	contract := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000c1"))
+
+	key := func(slot uint64) accounts.StorageKey {
+		return accounts.InternKey(uint256.NewInt(slot).Bytes32())
+	}
+
+	state := &mockIntraBlockState{slots: map[accounts.StorageKey]uint256.Int{
+		key(2): *uint256.NewInt(7),
+		key(3): *uint256.NewInt(9),
+		key(4): *uint256.NewInt(4),
+	}}
+
+	sstore := func(tracer *SimulationTracer, slot, value uint64) {
+		// SSTORE operands, top of stack last: value, slot
+		ctx := &mockOpContext{addr: contract, stack: make([]uint256.Int, 2)}
+		ctx.stack[1].SetUint64(slot)
+		ctx.stack[0].SetUint64(value)
+		tracer.OnOpcode(0, byte(vm.SSTORE), 90000, 2900, ctx, nil, 1, nil)
+	}
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{CaptureStorage: true})
+	tracer.OnTxStart(&tracing.VMContext{IntraBlockState: state}, nil, eoa)
+	tracer.OnEnter(0, byte(vm.CALL), eoa, contract, false, nil, 100000, uint256.Int{}, nil)
+
+	sstore(tracer, 1, 5)
+	state.slots[key(1)] = *uint256.NewInt(5) // The write lands
+	sstore(tracer, 1, 6)
+	sstore(tracer, 2, 8)
+	sstore(tracer, 3, 0)
+	sstore(tracer, 4, 4)
+	tracer.OnExit(0, nil, 10000, nil, false)
+
+	want := []struct {
+		original, current, next uint64
+		operation               string
+	}{
+		{0, 0, 5, StorageOpCreate},
+		{0, 5, 6, StorageOpDirty}, // The original stays the transaction's starting value
+		{7, 7, 8, StorageOpModify},
+		{9, 9, 0, StorageOpClear},
+		{4, 4, 4, StorageOpNoop},
+	}
+
+	changes := tracer.GetStorageChanges()
+	if len(changes) != len(want) {
+		t.Fatalf("captured %d storage changes, want %d", len(changes), len(want))
+	}
+
+	for i, w := range want {
+		got := changes[i]
+		if got.Original != storageWordHex(uint256.NewInt(w.original)) ||
+			got.Current != storageWordHex(uint256.NewInt(w.current)) ||
+			got.New != storageWordHex(uint256.NewInt(w.next)) ||
+			got.Operation != w.operation {
+			t.Errorf("change[%d] = %+v, want %d -> %d -> %d (%s)", i, got, w.original, w.current, w.next, w.operation)
+		}
+
+		if got.Address != normalizeAddress(contract.String()) || got.Depth != 0 || got.Gas != 2900 || got.Reverted {
+			t.Errorf("change[%d] = %+v, want an unreverted depth-0 write by %s costing 2900", i, got, contract)
+		}
+	}
+
+	// Writes of a frame that reverts are undone
+	tracer.Reset()
+	tracer.OnTxStart(&tracing.VMContext{IntraBlockState: state}, nil, eoa)
+	tracer.OnEnter(0, byte(vm.CALL), eoa, contract, false, nil, 100000, uint256.Int{}, nil)
+	sstore(tracer, 2, 8)
+	tracer.OnExit(0, nil, 10000, nil, true)
+
+	if changes := tracer.GetStorageChanges(); len(changes) != 1 || !changes[0].Reverted {
+		t.Errorf("storage changes after revert = %+v, want one reverted change", changes)
+	}
+}
+
+// TestSimulationTracerPrecompileCalls verifies that a MODEXP call is captured with
+// its input size and the EIP-2565 gas for that input, and that the simulated
+// tracer applies the schedule's overrides.
+func TestSimulationTracerPrecompileCalls(t *testing.T) {
+	cancun, err := parseFork("cancun")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	precompiles := vm.Precompiles(forkRules(cancun))
+	eoa := accounts.InternAddress(common.HexToAddress("0x00000000000000000000000000000000000000e0"))
+	modexp := accounts.InternAddress(common.BytesToAddress([]byte{0x05}))
+
+	// 64-byte base and modulus with a 32-byte all-ones exponent:
+	// complexity ceil(64/8)^2 = 64, iterations 8*(32-32) + 255 = 255,
+	// gas max(200, 64*255/3) = 5440
+	input := make([]byte, 96+64+32+64)
+	input[31], input[63], input[95] = 64, 32, 64
+	for i := 96 + 64; i < 96+64+32; i++ {
+		input[i] = 0xff
+	}
+
+	const wantGas = 5440
+
+	run := func(schedule *CustomGasSchedule) PrecompileCall {
+		tracer := NewSimulationTracer(schedule, SimulationTracerConfig{CapturePrecompiles: true})
+		tracer.precompiles = precompiles
+		tracer.OnEnter(1, byte(vm.STATICCALL), eoa, modexp, true, input, 100000, uint256.Int{}, nil)
+		tracer.OnExit(1, nil, 7000, nil, false)
+
+		calls := tracer.GetPrecompileCalls()
+		if len(calls) != 1 {
+			t.Fatalf("captured %d precompile calls, want 1", len(calls))
+		}
+
+		return calls[0]
+	}
+
+	got := run(nil)
+	if got.Name != "MODEXP" || got.InputSize != len(input) || got.Depth != 1 {
+		t.Errorf("call = %+v, want a depth-1 MODEXP call with %d input bytes", got, len(input))
+	}
+
+	if got.DefaultGas != wantGas || got.Gas != wantGas {
+		t.Errorf("gas = %d (default %d), want %d", got.Gas, got.DefaultGas, wantGas)
+	}
+
+	if got.GasUsed != 7000 || got.Failed {
+		t.Errorf("outcome = %d gas used, failed %v; want 7000, false", got.GasUsed, got.Failed)
+	}
+
+	// A raised minimum applies to the simulated execution only
+	got = run(&CustomGasSchedule{Overrides: map[string]uint64{vm.GasKeyPCModexpMinGas: 10000}})
+	if got.DefaultGas != wantGas || got.Gas != 10000 {
+		t.Errorf("overridden gas = %d (default %d), want 10000 (default %d)", got.Gas, got.DefaultGas, wantGas)
+	}
+}
+
+// TestSimulationTracerSampling verifies that with a sample rate the breakdown
+// estimates the full totals for a uniform opcode sequence, while CALL-family
+// opcodes are still recorded exactly.
+func TestSimulationTracerSampling(t *testing.T) {
+	ctx := newMockOpContext(10)
+
+	pattern := []struct {
+		op   vm.OpCode
+		cost uint64
+	}{
+		{vm.PUSH1, 3}, {vm.PUSH1, 3}, {vm.ADD, 3}, {vm.MSTORE, 6}, {vm.SLOAD, 100},
+	}
+
+	const repeats = 2000
+
+	run := func(sampleRate int) *SimulationTracer {
+		tracer := NewSimulationTracer(nil, SimulationTracerConfig{SampleRate: sampleRate})
+		for i := 0; i < repeats; i++ {
+			for j, step := range pattern {
+				tracer.OnOpcode(uint64(j), byte(step.op), 1_000_000, step.cost, ctx, nil, 1, nil)
+			}
+		}
+
+		// An unresolved CALL at the end is attributed in full
+		tracer.OnOpcode(99, byte(vm.CALL), 1_000_000, 2600, ctx, nil, 1, nil)
+		tracer.OnOpcode(100, byte(vm.STOP), 1_000_000, 0, ctx, nil, 1, nil)
+
+		return tracer
+	}
+
+	full := run(0)
+	sampled := run(7) // coprime with the pattern length, so every opcode is sampled
+
+	const tolerance = 0.02
+
+	within := func(got, want uint64) bool {
+		return math.Abs(float64(got)-float64(want)) <= tolerance*float64(want)
+	}
+
+	if got, want := sampled.GetTotalGasUsed(), full.GetTotalGasUsed(); !within(got, want) {
+		t.Errorf("sampled total gas = %d, want %d within %.0f%%", got, want, tolerance*100)
+	}
+
+	fullBreakdown := full.GetRawBreakdown()
+	sampledBreakdown := sampled.GetRawBreakdown()
+
+	for _, op := range []string{"PUSH1", "ADD", "MSTORE", "SLOAD"} {
+		if got, want := sampledBreakdown[op].Gas, fullBreakdown[op].Gas; !within(got, want) {
+			t.Errorf("%s sampled gas = %d, want %d within %.0f%%", op, got, want, tolerance*100)
+		}
+
+		if got, want := sampledBreakdown[op].Count, fullBreakdown[op].Count; !within(got, want) {
+			t.Errorf("%s sampled count = %d, want %d within %.0f%%", op, got, want, tolerance*100)
+		}
+	}
+
+	if got := sampledBreakdown["CALL"]; got.Count != 1 || got.Gas != 2600 {
+		t.Errorf("CALL = %+v, want exactly one charge of 2600", got)
+	}
+}
+
+// TestSimulationTracerSampleRateCap checks that a sample rate above maxSampleRate
+// is capped, so a sampled charge is weighted by at most maxSampleRate.
+func TestSimulationTracerSampleRateCap(t *testing.T) {
+	ctx := newMockOpContext(10)
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{SampleRate: math.MaxInt})
+	for i := 0; i < maxSampleRate; i++ {
+		tracer.OnOpcode(uint64(i), byte(vm.SLOAD), 1_000_000, 2100, ctx, nil, 1, nil)
+	}
+
+	if got, want := tracer.GetTotalGasUsed(), uint64(2100*maxSampleRate); got != want {
+		t.Errorf("total gas = %d, want one sample weighted by the capped rate, %d", got, want)
+	}
+}
+
+// TestSimulationTracerPeakMemory verifies that the peak memory is the largest frame
+// memory observed, including the expansion made by a frame's final RETURN, and that
+// it is exact under sampling.
+func TestSimulationTracerPeakMemory(t *testing.T) {
+	fresh := &mockOpContext{}
+	expanded := &mockOpContext{memory: make([]byte, 4096)}
+
+	// RETURN operands, top of stack last: size, offset. Returns 32 bytes at 0x2000,
+	// expanding memory to 0x2020 bytes.
+	ret := &mockOpContext{memory: make([]byte, 64), stack: make([]uint256.Int, 2)}
+	ret.stack[1].SetUint64(0x2000)
+	ret.stack[0].SetUint64(32)
+
+	for _, sampleRate := range []int{0, 5} {
+		tracer := NewSimulationTracer(nil, SimulationTracerConfig{SampleRate: sampleRate})
+
+		tracer.OnOpcode(0, byte(vm.MSTORE), 100000, 3+384, fresh, nil, 1, nil)
+		tracer.OnOpcode(1, byte(vm.POP), 100000, 2, expanded, nil, 1, nil)
+
+		if got := tracer.GetPeakMemoryBytes(); got != 4096 {
+			t.Errorf("sample rate %d: peak after MSTORE = %d, want 4096", sampleRate, got)
+		}
+
+		// A child frame starts with empty memory and ends with an expanding RETURN
+		tracer.OnOpcode(0, byte(vm.PUSH1), 90000, 3, fresh, nil, 2, nil)
+		tracer.OnOpcode(2, byte(vm.RETURN), 90000, 800, ret, nil, 2, nil)
+
+		if got := tracer.GetPeakMemoryBytes(); got != 0x2020 {
+			t.Errorf("sample rate %d: peak after RETURN = %d, want %d", sampleRate, got, 0x2020)
+		}
+
+		tracer.Reset()
+
+		if got := tracer.GetPeakMemoryBytes(); got != 0 {
+			t.Errorf("sample rate %d: peak after Reset = %d, want 0", sampleRate, got)
+		}
+	}
+}
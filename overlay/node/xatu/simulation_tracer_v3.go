@@ -34,6 +34,23 @@ type OpcodeSummary struct {
 	OriginalGas    uint64 `json:"originalGas"`
 	SimulatedCount uint64 `json:"simulatedCount"`
 	SimulatedGas   uint64 `json:"simulatedGas"`
+
+	// Cheapest and most expensive single charge observed. Zero when the opcode
+	// did not execute on that side.
+	OriginalMinGas  uint64 `json:"originalMinGas"`
+	OriginalMaxGas  uint64 `json:"originalMaxGas"`
+	SimulatedMinGas uint64 `json:"simulatedMinGas"`
+	SimulatedMaxGas uint64 `json:"simulatedMaxGas"`
+
+	// Share of the block's gas used, in percent (block results only). Opcode gas is
+	// counted before refunds, so shares can sum to slightly more than 100.
+	OriginalGasPercent  float64 `json:"originalGasPercent,omitempty"`
+	SimulatedGasPercent float64 `json:"simulatedGasPercent,omitempty"`
+
+	// Standard deviation of the single charges (transaction results with
+	// IncludeGasStdDev only). Zero when every charge cost the same.
+	OriginalGasStdDev  float64 `json:"originalGasStdDev,omitempty"`
+	SimulatedGasStdDev float64 `json:"simulatedGasStdDev,omitempty"`
 }
 
 // CallError represents an error that occurred during a nested call.
@@ -46,9 +63,47 @@ type CallError struct {
 
 // callFrame tracks the current call being executed.
 type callFrame struct {
-	depth   int
-	typ     string
-	address string
+	depth          int
+	typ            string
+	address        string
+	transfersStart int    // Index of the first value transfer made within this frame
+	logsStart      int    // Index of the first log emitted within this frame
+	storageStart   int    // Index of the first storage change made within this frame
+	contract       string // Normalized address of the code running in this frame
+}
+
+// SimulationTracerConfig enables optional tracking in the simulation tracer.
+// Optional tracking is off by default to keep block-level simulation cheap.
+type SimulationTracerConfig struct {
+	TrackAccessList     bool // Record accessed addresses and storage slots
+	TrackAccessListUse  bool // Record which declared access list entries are accessed
+	TrackValueTransfers bool // Record nonzero-value call frames (ETH flows)
+	ClassifyCalls       bool // Count CALL-family opcodes by target (self/warm/cold)
+	ClassifyWarmAccess  bool // Count warm accesses by origin (pre-declared/execution-warmed)
+	TrackCallTree       bool // Build the nested call tree with each frame's gas and status
+	TrackGasStdDev      bool // Keep a running variance of each opcode's charges
+	TrackOpcodePairs    bool // Count adjacent opcode pairs weighted by gas (see bigramTracker)
+	TrackContracts      bool // Attribute gas to the contract executing each opcode
+	CaptureSenderState  bool // Read the sender's nonce and balance before execution
+	CaptureLogs         bool // Record LOG opcodes with their topics, data size and gas
+	CaptureStorage      bool // Record SSTORE opcodes with the slot's values and operation
+	CapturePrecompiles  bool // Record precompile calls with their input size and gas
+
+	// SampleRate, when > 1, records only every SampleRate-th opcode in the
+	// breakdown, weighting its count and gas by SampleRate to estimate totals.
+	// CALL-family opcodes are always recorded. Results are approximate, and other
+	// per-opcode tracking (access list, pairs, logs, sequence) only sees the
+	// sampled opcodes. Rates above maxSampleRate are capped.
+	SampleRate int
+
+	// RecordSequence keeps every executed opcode in order (see OpcodeStep).
+	// Memory grows linearly with the number of executed opcodes: a transaction
+	// using the full block gas limit can execute millions of opcodes, so only
+	// enable this for single-transaction analysis.
+	RecordSequence bool
+
+	// CollectStats records the tracer's own overhead (see TracerStats).
+	CollectStats bool
 }
 
 // SimulationTracer tracks opcode execution during gas simulation.
@@ -58,11 +113,15 @@ type SimulationTracer struct {
 	schedule *CustomGasSchedule
 
 	// Per-opcode tracking
-	gasUsed      map[string]uint64 // opcode -> total gas used
-	opcodeCounts map[string]uint64 // opcode -> count
+	gasUsed      map[string]uint64   // opcode -> total gas used
+	opcodeCounts map[string]uint64   // opcode -> count
+	gasRanges    map[string]gasRange // opcode -> min/max single charge (absent until first charge)
+	firstSeenPC  map[string]uint32   // opcode -> PC of its first execution
 
 	// Total tracking
 	totalGasUsed uint64
+	executionGas uint64 // Gas used by the top-level frame, before refunds
+	peakMemory   uint64 // Largest memory size reached by any frame, in bytes
 
 	// Call error tracking
 	callStack  []callFrame // Stack of active calls
@@ -82,29 +141,162 @@ type SimulationTracer struct {
 	// Precompile address->name lookup for gas breakdown attribution
 	precompiles vm.PrecompiledContracts
 
+	// Accessed addresses and slots (nil unless TrackAccessList is enabled)
+	access *accessTracker
+
+	// Declared access list entry hits (nil unless TrackAccessListUse is enabled)
+	accessListUse *accessListUsage
+
+	// Call target classification (nil unless ClassifyCalls is enabled)
+	calls *callClassifier
+
+	// Warm access origins (nil unless ClassifyWarmAccess is enabled)
+	warmAccess *warmAccessTracker
+
+	// Nested call frames (nil unless TrackCallTree is enabled)
+	callTree *callTreeBuilder
+
+	// Adjacent opcode pair stats (nil unless TrackOpcodePairs is enabled)
+	bigrams *bigramTracker
+
+	// Opcode -> running variance of its charges (nil unless TrackGasStdDev is enabled)
+	gasVariances map[string]gasVariance
+
+	// Contract address -> gas used by its frames (nil unless TrackContracts is enabled)
+	contractGas map[string]uint64
+
+	// Read the sender's pre-execution state (see SenderState)
+	captureSender bool
+
+	// Opcode sampling (sampleRate <= 1 records every opcode)
+	sampleRate    uint64
+	sampleCounter uint64
+
+	// Internal ETH transfers (only populated if TrackValueTransfers is enabled)
+	trackTransfers bool
+	transfers      []ValueTransfer
+
+	// Emitted logs (only populated if CaptureLogs is enabled)
+	trackLogs bool
+	logs      []EmittedLog
+
+	// Storage writes (only populated if CaptureStorage is enabled). The originals
+	// hold each written slot's value at the start of the transaction.
+	trackStorage     bool
+	storageChanges   []StorageChange
+	storageOriginals map[string]uint256.Int
+
+	// Precompile calls (only populated if CapturePrecompiles is enabled)
+	trackPrecompileCalls bool
+	precompileCalls      []PrecompileCall
+
+	// Ordered opcode trace (only populated if RecordSequence is enabled)
+	recordSequence bool
+	sequence       []OpcodeStep
+
+	// Tracer overhead (nil unless CollectStats is enabled)
+	stats *TracerStats
+
 	// VM context
 	env *tracing.VMContext
 }
 
 // NewSimulationTracer creates a new simulation tracer.
-func NewSimulationTracer(schedule *CustomGasSchedule) *SimulationTracer {
-	return &SimulationTracer{
+func NewSimulationTracer(schedule *CustomGasSchedule, cfg SimulationTracerConfig) *SimulationTracer {
+	t := &SimulationTracer{
 		schedule:     schedule,
 		gasUsed:      make(map[string]uint64, 64),
 		opcodeCounts: make(map[string]uint64, 64),
+		gasRanges:    make(map[string]gasRange, 64),
+		firstSeenPC:  make(map[string]uint32, 64),
 		callStack:    make([]callFrame, 0, 16),
 		callErrors:   make([]CallError, 0, 8),
 	}
+
+	if cfg.TrackAccessList {
+		t.access = newAccessTracker()
+	}
+
+	if cfg.TrackAccessListUse {
+		t.accessListUse = newAccessListUsage()
+	}
+
+	if cfg.ClassifyCalls {
+		t.calls = newCallClassifier()
+	}
+
+	if cfg.ClassifyWarmAccess {
+		t.warmAccess = newWarmAccessTracker()
+	}
+
+	if cfg.TrackCallTree {
+		t.callTree = newCallTreeBuilder()
+	}
+
+	if cfg.TrackOpcodePairs {
+		t.bigrams = newBigramTracker()
+	}
+
+	if cfg.TrackGasStdDev {
+		t.gasVariances = make(map[string]gasVariance, 64)
+	}
+
+	if cfg.TrackContracts {
+		t.contractGas = make(map[string]uint64, 8)
+	}
+
+	t.captureSender = cfg.CaptureSenderState
+
+	if cfg.SampleRate > 1 {
+		t.sampleRate = uint64(min(cfg.SampleRate, maxSampleRate))
+	}
+
+	if cfg.TrackValueTransfers {
+		t.trackTransfers = true
+		t.transfers = make([]ValueTransfer, 0, 8)
+	}
+
+	if cfg.CaptureLogs {
+		t.trackLogs = true
+		t.logs = make([]EmittedLog, 0, 8)
+	}
+
+	if cfg.CaptureStorage {
+		t.trackStorage = true
+		t.storageChanges = make([]StorageChange, 0, 8)
+		t.storageOriginals = make(map[string]uint256.Int, 8)
+	}
+
+	if cfg.CapturePrecompiles {
+		t.trackPrecompileCalls = true
+		t.precompileCalls = make([]PrecompileCall, 0, 8)
+	}
+
+	if cfg.RecordSequence {
+		t.recordSequence = true
+		t.sequence = make([]OpcodeStep, 0, 1024)
+	}
+
+	if cfg.CollectStats {
+		t.stats = &TracerStats{}
+	}
+
+	return t
 }
 
 // Hooks returns the tracing hooks for the EVM.
 func (t *SimulationTracer) Hooks() *tracing.Hooks {
+	onOpcode := t.OnOpcode
+	if t.stats != nil {
+		onOpcode = t.stats.instrument(t.OnOpcode, func() int { return cap(t.sequence) })
+	}
+
 	return &tracing.Hooks{
 		OnTxStart: t.OnTxStart,
 		OnTxEnd:   t.OnTxEnd,
 		OnEnter:   t.OnEnter,
 		OnExit:    t.OnExit,
-		OnOpcode:  t.OnOpcode,
+		OnOpcode:  onOpcode,
 	}
 }
 
@@ -113,17 +305,32 @@ func (t *SimulationTracer) Hooks() *tracing.Hooks {
 func (t *SimulationTracer) OnTxStart(env *tracing.VMContext, txn types.Transaction, from common.Address) {
 	t.env = env
 	t.totalGasUsed = 0
+	clear(t.storageOriginals)
+
+	coinbase := warmCoinbase(env)
+
+	if t.calls != nil {
+		t.calls.startTx(txn, t.precompiles, coinbase)
+	}
+
+	if t.access != nil {
+		t.access.startTx(txn, t.precompiles, coinbase)
+	}
+
+	if t.accessListUse != nil && txn != nil {
+		t.accessListUse.start(txn.GetAccessList(), t.precompiles, coinbase)
+	}
+
+	if t.warmAccess != nil && txn != nil {
+		t.warmAccess.start(txn.GetAccessList(), t.precompiles, coinbase)
+	}
 }
 
 // OnTxEnd is called when a transaction ends.
 func (t *SimulationTracer) OnTxEnd(_ *types.Receipt, _ error) {
 	// Flush any unresolved pending CALL (edge case: tx ends abnormally after CALL)
 	if t.pendingCallCost > 0 {
-		t.gasUsed[t.pendingCallType] += t.pendingCallCost
-		t.totalGasUsed += t.pendingCallCost
-		t.pendingCallCost = 0
-		t.pendingCallDepth = 0
-		t.pendingCallType = ""
+		t.resolvePendingCall(t.pendingCallCost)
 	}
 }
 
@@ -144,13 +351,8 @@ func (t *SimulationTracer) OnEnter(depth int, typ byte, from common.Address, to
 		if t.pendingCallCost > gas {
 			overhead = t.pendingCallCost - gas
 		}
-		// Attribute overhead to the CALL opcode
-		t.gasUsed[t.pendingCallType] += overhead
-		t.totalGasUsed += overhead
-		// Clear pending
-		t.pendingCallCost = 0
-		t.pendingCallDepth = 0
-		t.pendingCallType = ""
+		// Attribute overhead to the CALL opcode and clear pending
+		t.resolvePendingCall(overhead)
 	}
 
 	// Track precompile calls for gas breakdown attribution
@@ -158,9 +360,39 @@ func (t *SimulationTracer) OnEnter(depth int, typ byte, from common.Address, to
 		if p, ok := t.precompiles[to]; ok {
 			t.pendingPrecompile = true
 			t.pendingPrecompileName = "PC_" + p.Name()
+
+			if t.trackPrecompileCalls {
+				t.recordPrecompileCall(depth, to.String(), p, input)
+			}
 		}
 	}
 
+	// Record the call target as accessed
+	if t.access != nil {
+		t.access.enter(depth, typ, from.String(), to.String())
+	}
+
+	if t.accessListUse != nil {
+		t.accessListUse.enter(depth, from.String(), to.String())
+	}
+
+	if t.calls != nil {
+		t.calls.enter(depth, typ, from.String(), to.String())
+	}
+
+	if t.warmAccess != nil {
+		t.warmAccess.enter(depth, typ, from.String(), to.String())
+	}
+
+	if t.callTree != nil {
+		t.callTree.enter(typName, from.String(), to.String(), gas)
+	}
+
+	// Opcode pairs do not span call frames
+	if t.bigrams != nil {
+		t.bigrams.breakSequence()
+	}
+
 	// Truncate address to first 20 chars (0x + 18 hex chars)
 	addrStr := to.String()
 	if len(addrStr) > 20 {
@@ -169,31 +401,62 @@ func (t *SimulationTracer) OnEnter(depth int, typ byte, from common.Address, to
 
 	// Push call frame onto stack
 	t.callStack = append(t.callStack, callFrame{
-		depth:   depth,
-		typ:     typName,
-		address: addrStr,
+		depth:          depth,
+		typ:            typName,
+		address:        addrStr,
+		transfersStart: len(t.transfers),
+		logsStart:      len(t.logs),
+		storageStart:   len(t.storageChanges),
+		contract:       normalizeAddress(to.String()),
 	})
+
+	// Record ETH moved by this frame (includes SELFDESTRUCT, which the EVM
+	// reports as a frame entered with the beneficiary and the destroyed balance)
+	if t.trackTransfers && !value.IsZero() {
+		t.recordValueTransfer(depth, typ, from.String(), to.String(), &value)
+	}
 }
 
 // OnExit is called when a call frame exits.
 func (t *SimulationTracer) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
-	// Pop from call stack
 	if len(t.callStack) == 0 {
 		return
 	}
 
-	frame := t.callStack[len(t.callStack)-1]
-	t.callStack = t.callStack[:len(t.callStack)-1]
-
-	// Record precompile gas in the opcode breakdown
+	// Record precompile gas in the opcode breakdown, while the precompile's
+	// frame is still on the stack so contract attribution goes to its address
 	if t.pendingPrecompile {
-		t.gasUsed[t.pendingPrecompileName] += gasUsed
+		t.recordGas(t.pendingPrecompileName, gasUsed)
 		t.opcodeCounts[t.pendingPrecompileName]++
-		t.totalGasUsed += gasUsed
 		t.pendingPrecompile = false
+
+		if t.trackPrecompileCalls {
+			t.finishPrecompileCall(gasUsed, err != nil || reverted)
+		}
 		t.pendingPrecompileName = ""
 	}
 
+	// Pop from call stack
+	frame := t.callStack[len(t.callStack)-1]
+	t.callStack = t.callStack[:len(t.callStack)-1]
+
+	if depth == 0 {
+		t.executionGas = gasUsed
+	}
+
+	if t.callTree != nil {
+		t.callTree.exit(gasUsed, err, reverted)
+	}
+
+	if t.warmAccess != nil {
+		t.warmAccess.exit(err != nil || reverted)
+	}
+
+	// The parent frame resumes without a previous opcode
+	if t.bigrams != nil {
+		t.bigrams.breakSequence()
+	}
+
 	// Record error if call failed
 	if err != nil || reverted {
 		errMsg := "execution reverted"
@@ -207,6 +470,19 @@ func (t *SimulationTracer) OnExit(depth int, output []byte, gasUsed uint64, err
 			Error:   errMsg,
 			Address: frame.address,
 		})
+
+		// Transfers made by a failed frame (and its children) are rolled back
+		if t.trackTransfers {
+			t.markTransfersReverted(frame.transfersStart)
+		}
+
+		if t.trackLogs {
+			t.markLogsReverted(frame.logsStart)
+		}
+
+		if t.trackStorage {
+			t.markStorageChangesReverted(frame.storageStart)
+		}
 	}
 }
 
@@ -224,33 +500,92 @@ func (t *SimulationTracer) OnOpcode(pc uint64, opcode byte, gas, cost uint64, sc
 	// This happens when a CALL fails before OnEnter (e.g., insufficient balance)
 	if t.pendingCallCost > 0 && t.pendingCallDepth == depth {
 		// Previous CALL failed without creating child frame - attribute full cost
-		t.gasUsed[t.pendingCallType] += t.pendingCallCost
-		t.totalGasUsed += t.pendingCallCost
-		t.pendingCallCost = 0
-		t.pendingCallDepth = 0
-		t.pendingCallType = ""
+		t.resolvePendingCall(t.pendingCallCost)
+	}
+
+	isCall := opcode == 0xF1 || opcode == 0xF2 || opcode == 0xF4 || opcode == 0xFA
+
+	// Memory is tracked ahead of sampling, so the peak is exact
+	t.peakMemory = max(t.peakMemory, opcodeMemorySize(opcode, scope))
+
+	// Storage writes too, since a missed SSTORE would misstate slot originals
+	if t.trackStorage && opcode == 0x55 { // SSTORE
+		t.recordStorageChange(depth, cost, scope)
+	}
+
+	// When sampling, skip all but every sampleRate-th opcode and weight the
+	// recorded one by the rate. CALL-family gas is resolved in OnEnter, so
+	// those are always recorded.
+	weight := uint64(1)
+	if t.sampleRate > 1 && !isCall {
+		t.sampleCounter++
+		if t.sampleCounter%t.sampleRate != 0 {
+			return
+		}
+
+		weight = t.sampleRate
 	}
 
-	// Always track opcode counts
-	t.opcodeCounts[opName]++
+	// Always track opcode counts, and the PC of each opcode's first execution
+	count := t.opcodeCounts[opName]
+	if count == 0 {
+		t.firstSeenPC[opName] = uint32(pc)
+	}
+	t.opcodeCounts[opName] = count + weight
+
+	if t.access != nil {
+		t.access.recordOpcode(opcode, scope)
+	}
+
+	if t.accessListUse != nil {
+		recordOpcodeAccess(t.accessListUse, opcode, scope)
+	}
+
+	if t.calls != nil {
+		t.calls.recordOpcode(opcode, scope)
+	}
+
+	if t.warmAccess != nil {
+		recordOpcodeAccess(t.warmAccess, opcode, scope)
+	}
+
+	if t.bigrams != nil {
+		t.bigrams.observe(opcode, cost)
+	}
+
+	if t.trackLogs && opcode >= 0xA0 && opcode <= 0xA4 { // LOG0-LOG4
+		t.recordLog(opcode, depth, cost, scope)
+	}
+
+	if t.recordSequence {
+		t.sequence = append(t.sequence, OpcodeStep{
+			PC:    pc,
+			Op:    opName,
+			Gas:   gas,
+			Cost:  cost,
+			Depth: depth,
+		})
+	}
 
 	// For CALL-family opcodes, defer gas tracking to OnEnter
 	// Opcodes: CALL=0xF1, CALLCODE=0xF2, DELEGATECALL=0xF4, STATICCALL=0xFA
-	if opcode == 0xF1 || opcode == 0xF2 || opcode == 0xF4 || opcode == 0xFA {
+	if isCall {
 		t.pendingCallCost = cost
 		t.pendingCallDepth = depth
 		t.pendingCallType = opName
 		return
 	}
 
-	t.gasUsed[opName] += cost
-	t.totalGasUsed += cost
+	t.recordWeightedGas(opName, cost, weight)
 }
 
 // TracerBreakdown is the raw data from a single tracer execution.
 type TracerBreakdown struct {
-	Count uint64
-	Gas   uint64
+	Count     uint64
+	Gas       uint64
+	MinGas    uint64
+	MaxGas    uint64
+	GasStdDev float64 // 0 unless TrackGasStdDev is enabled
 }
 
 // GetRawBreakdown returns the raw per-opcode data from this tracer's execution.
@@ -260,9 +595,13 @@ func (t *SimulationTracer) GetRawBreakdown() map[string]TracerBreakdown {
 
 	for opcode, count := range t.opcodeCounts {
 		gas := t.gasUsed[opcode]
+		minGas, maxGas := t.gasRangeOf(opcode)
 		result[opcode] = TracerBreakdown{
-			Count: count,
-			Gas:   gas,
+			Count:     count,
+			Gas:       gas,
+			MinGas:    minGas,
+			MaxGas:    maxGas,
+			GasStdDev: t.gasStdDevOf(opcode),
 		}
 	}
 
@@ -274,6 +613,19 @@ func (t *SimulationTracer) GetTotalGasUsed() uint64 {
 	return t.totalGasUsed
 }
 
+// GetExecutionGas returns the gas used by the top-level call frame, excluding
+// intrinsic gas and before refunds are applied.
+func (t *SimulationTracer) GetExecutionGas() uint64 {
+	return t.executionGas
+}
+
+// GetPeakMemoryBytes returns the largest memory size reached by any call frame.
+// Frames have separate memories, so this is the peak of a single frame, which is
+// what the quadratic memory expansion cost grows with.
+func (t *SimulationTracer) GetPeakMemoryBytes() uint64 {
+	return t.peakMemory
+}
+
 // GetRevertCount returns the number of REVERT opcodes executed.
 // This includes reverts from nested calls, not just the top-level transaction.
 func (t *SimulationTracer) GetRevertCount() uint64 {
@@ -294,6 +646,101 @@ func (t *SimulationTracer) GetCallErrors() []CallError {
 	return t.callErrors
 }
 
+// TracerStats returns the tracer's overhead, or nil unless CollectStats is enabled.
+func (t *SimulationTracer) TracerStats() *TracerStats {
+	return t.stats.snapshot()
+}
+
+// GetSequence returns the ordered opcode trace, or nil if RecordSequence is disabled.
+func (t *SimulationTracer) GetSequence() []OpcodeStep {
+	return t.sequence
+}
+
+// GetLogs returns the logs emitted during execution, in order.
+func (t *SimulationTracer) GetLogs() []EmittedLog {
+	return t.logs
+}
+
+// GetPrecompileCalls returns the precompile calls made, in order.
+func (t *SimulationTracer) GetPrecompileCalls() []PrecompileCall {
+	return t.precompileCalls
+}
+
+// GetStorageChanges returns the SSTOREs executed, in order.
+func (t *SimulationTracer) GetStorageChanges() []StorageChange {
+	return t.storageChanges
+}
+
+// GetValueTransfers returns the internal ETH transfers recorded during execution.
+func (t *SimulationTracer) GetValueTransfers() []ValueTransfer {
+	return t.transfers
+}
+
+// GetCallClassification returns the call target counts, or nil if ClassifyCalls is disabled.
+func (t *SimulationTracer) GetCallClassification() *CallClassification {
+	if t.calls == nil {
+		return nil
+	}
+
+	counts := t.calls.counts
+	return &counts
+}
+
+// GetWarmAccessOrigin returns the warm access counts by origin, or nil if
+// ClassifyWarmAccess is disabled.
+func (t *SimulationTracer) GetWarmAccessOrigin() *WarmAccessOrigin {
+	if t.warmAccess == nil {
+		return nil
+	}
+
+	counts := t.warmAccess.counts
+	return &counts
+}
+
+// GetCallTree returns the top-level call frame, or nil if TrackCallTree is
+// disabled or no frame was entered.
+func (t *SimulationTracer) GetCallTree() *CallFrame {
+	if t.callTree == nil {
+		return nil
+	}
+
+	return t.callTree.root
+}
+
+// GetOpcodePairs returns the n adjacent opcode pairs with the most gas, or nil if
+// TrackOpcodePairs is disabled.
+func (t *SimulationTracer) GetOpcodePairs(n int) []OpcodePair {
+	if t.bigrams == nil {
+		return nil
+	}
+
+	return t.bigrams.top(n)
+}
+
+// GetFirstSeenPCs returns the PC at which each opcode was first executed. PCs are
+// relative to the code of the frame that executed the opcode.
+func (t *SimulationTracer) GetFirstSeenPCs() map[string]uint32 {
+	return t.firstSeenPC
+}
+
+// GetContractBreakdown returns the gas used by each contract's frames, keyed by
+// normalized address, or nil if TrackContracts is disabled. Gas charged outside
+// any frame (e.g. intrinsic gas) is not attributed to a contract.
+func (t *SimulationTracer) GetContractBreakdown() map[string]uint64 {
+	return t.contractGas
+}
+
+// accesses returns the recorded accesses, or nil if access tracking is disabled.
+func (t *SimulationTracer) accesses() *accessTracker {
+	return t.access
+}
+
+// accessListHits returns the declared access list entry hits, or nil if
+// TrackAccessListUse is disabled.
+func (t *SimulationTracer) accessListHits() *accessListUsage {
+	return t.accessListUse
+}
+
 // Reset clears the tracer state for reuse.
 func (t *SimulationTracer) Reset() {
 	for k := range t.gasUsed {
@@ -302,7 +749,13 @@ func (t *SimulationTracer) Reset() {
 	for k := range t.opcodeCounts {
 		delete(t.opcodeCounts, k)
 	}
+	clear(t.gasRanges)
+	clear(t.gasVariances)
+	clear(t.firstSeenPC)
 	t.totalGasUsed = 0
+	t.executionGas = 0
+	t.peakMemory = 0
+	t.sampleCounter = 0
 	t.callStack = t.callStack[:0]
 	t.callErrors = t.callErrors[:0]
 	t.pendingCallCost = 0
@@ -310,6 +763,34 @@ func (t *SimulationTracer) Reset() {
 	t.pendingCallType = ""
 	t.pendingPrecompile = false
 	t.pendingPrecompileName = ""
+	if t.access != nil {
+		t.access.reset()
+	}
+	if t.accessListUse != nil {
+		t.accessListUse.reset()
+	}
+	if t.calls != nil {
+		t.calls.reset()
+	}
+	if t.warmAccess != nil {
+		t.warmAccess.reset()
+	}
+	if t.callTree != nil {
+		t.callTree.reset()
+	}
+	if t.bigrams != nil {
+		t.bigrams.reset()
+	}
+	clear(t.contractGas)
+	t.transfers = t.transfers[:0]
+	t.logs = t.logs[:0]
+	t.storageChanges = t.storageChanges[:0]
+	clear(t.storageOriginals)
+	t.precompileCalls = t.precompileCalls[:0]
+	t.sequence = t.sequence[:0]
+	if t.stats != nil {
+		*t.stats = TracerStats{}
+	}
 }
 
 // Note: opcodeStrings is defined in tracer.go and shared across the package.
@@ -0,0 +1,143 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/hex"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/execution/tracing"
+)
+
+// SSTORE operations, as classified by the EIP-2200 SSTORE gas function.
+const (
+	StorageOpCreate = "create" // Original and current are zero, new is nonzero (SSTORE_SET)
+	StorageOpModify = "modify" // Original equals current and is nonzero, new is nonzero (SSTORE_RESET)
+	StorageOpClear  = "clear"  // Original equals current and is nonzero, new is zero (SSTORE_RESET plus refund)
+	StorageOpNoop   = "noop"   // New equals current (SLOAD_GAS)
+	StorageOpDirty  = "dirty"  // Slot already written in this transaction (SLOAD_GAS, refunds adjusted)
+)
+
+// StorageChange is an SSTORE with the slot's values around it and the gas it was
+// charged. Original is the value at the start of the transaction, Current the
+// value just before the SSTORE, and New the value written. Operation is the branch
+// of the SSTORE gas function the write took, so the charged gas can be checked.
+type StorageChange struct {
+	Depth     int    `json:"depth"` // Call frame depth, 0 for the top-level frame
+	Address   string `json:"address"`
+	Slot      string `json:"slot"`
+	Original  string `json:"original"` // Hex-encoded 32-byte values
+	Current   string `json:"current"`
+	New       string `json:"new"`
+	Operation string `json:"operation"`
+	Gas       uint64 `json:"gas"`
+	Reverted  bool   `json:"reverted"` // True if the frame (or an ancestor) failed, undoing the write
+}
+
+// StorageChanges holds the storage writes of both executions.
+type StorageChanges struct {
+	Original  []StorageChange `json:"original"`
+	Simulated []StorageChange `json:"simulated"`
+}
+
+// classifyStorageWrite returns the SSTORE operation for a write of value to a slot
+// holding current, whose value at the start of the transaction was original. It
+// mirrors the branches of the EIP-2200 SSTORE gas function.
+func classifyStorageWrite(original, current, value *uint256.Int) string {
+	switch {
+	case current.Eq(value):
+		return StorageOpNoop
+	case !original.Eq(current):
+		return StorageOpDirty
+	case original.IsZero():
+		return StorageOpCreate
+	case value.IsZero():
+		return StorageOpClear
+	default:
+		return StorageOpModify
+	}
+}
+
+// recordStorageChange captures an SSTORE from its stack operands (slot, value)
+// before it executes. depth is the opcode depth, one more than its frame's depth.
+//
+// The tracer's state view has no committed-state read, so a slot's original value
+// is the value seen by its first SSTORE in the transaction: nothing can have
+// written the slot before then.
+func (t *SimulationTracer) recordStorageChange(depth int, cost uint64, scope tracing.OpContext) {
+	stack := scope.StackData()
+	if len(stack) < 2 || t.env == nil || t.env.IntraBlockState == nil {
+		return
+	}
+
+	slot := stack[len(stack)-1]
+	value := stack[len(stack)-2]
+	current := getStorageValue(t.env.IntraBlockState, scope.Address(), &slot)
+
+	address := normalizeAddress(scope.Address().String())
+	slotBytes := slot.Bytes32()
+	slotHex := "0x" + hex.EncodeToString(slotBytes[:])
+
+	key := address + slotHex
+	original, ok := t.storageOriginals[key]
+	if !ok {
+		original = current
+		t.storageOriginals[key] = original
+	}
+
+	t.storageChanges = append(t.storageChanges, StorageChange{
+		Depth:     depth - 1,
+		Address:   address,
+		Slot:      slotHex,
+		Original:  storageWordHex(&original),
+		Current:   storageWordHex(&current),
+		New:       storageWordHex(&value),
+		Operation: classifyStorageWrite(&original, &current, &value),
+		Gas:       cost,
+	})
+}
+
+// storageWordHex formats a storage value as a 0x-prefixed 32-byte hex string.
+func storageWordHex(v *uint256.Int) string {
+	word := v.Bytes32()
+	return "0x" + hex.EncodeToString(word[:])
+}
+
+// markStorageChangesReverted flags all storage changes from index start onwards
+// as reverted. Called when a frame fails, since its writes and those of its
+// children are undone.
+func (t *SimulationTracer) markStorageChangesReverted(start int) {
+	for i := start; i < len(t.storageChanges); i++ {
+		t.storageChanges[i].Reverted = true
+	}
+}
+
+// combineStorageChanges pairs the storage changes of both executions, or returns
+// nil if storage change capture is disabled.
+func combineStorageChanges(original, simulated *SimulationTracer) *StorageChanges {
+	if !original.trackStorage || !simulated.trackStorage {
+		return nil
+	}
+
+	return &StorageChanges{
+		Original:  original.GetStorageChanges(),
+		Simulated: simulated.GetStorageChanges(),
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/holiman/uint256"
+)
+
+// TestClassifyStorageWrite checks that each SSTORE is classified by the branch of
+// the EIP-2200 gas function it takes.
+func TestClassifyStorageWrite(t *testing.T) {
+	tests := []struct {
+		name                    string
+		original, current, next uint64
+		want                    string
+	}{
+		{"create", 0, 0, 5, StorageOpCreate},
+		{"modify", 7, 7, 8, StorageOpModify},
+		{"clear", 9, 9, 0, StorageOpClear},
+		{"noop", 4, 4, 4, StorageOpNoop},
+		{"noop of a dirty slot", 0, 5, 5, StorageOpNoop},
+		{"dirty", 0, 5, 6, StorageOpDirty},
+		{"restore", 7, 0, 7, StorageOpDirty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original, current, next := uint256.NewInt(tt.original), uint256.NewInt(tt.current), uint256.NewInt(tt.next)
+			if got := classifyStorageWrite(original, current, next); got != tt.want {
+				t.Errorf("classifyStorageWrite(%d, %d, %d) = %s, want %s", tt.original, tt.current, tt.next, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/execution/tracing"
+	"github.com/erigontech/erigon/execution/types/accounts"
+)
+
+// getStorageValue reads a storage slot's current value from IntraBlockState.
+// A read error leaves the value zero.
+func getStorageValue(ibs tracing.IntraBlockState, addr accounts.Address, slot *uint256.Int) uint256.Int {
+	value, _ := ibs.GetState(addr, accounts.InternKey(slot.Bytes32()))
+	return value
+}
@@ -0,0 +1,20 @@
+//go:build embedded && !erigon_main
+
+package xatu
+
+import (
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/tracing"
+)
+
+// getStorageValue reads a storage slot's current value from IntraBlockState.
+// On v3, GetState takes the key as a hash and fills an output value.
+// A read error leaves the value zero.
+func getStorageValue(ibs tracing.IntraBlockState, addr common.Address, slot *uint256.Int) uint256.Int {
+	var value uint256.Int
+	key := common.Hash(slot.Bytes32())
+	_ = ibs.GetState(addr, key, &value)
+	return value
+}
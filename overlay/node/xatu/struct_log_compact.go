@@ -0,0 +1,84 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+
+	"github.com/ethpandaops/execution-processor/pkg/ethereum/execution"
+)
+
+// CompactStructLog is a struct log abbreviated for size. It is serialized as a
+// JSON array rather than an object:
+//
+//	[pc, op, gas, gasCost]
+//
+// where op is the opcode byte (e.g. 0x54 for SLOAD) and gas and gasCost are as in
+// execution.StructLog. Other struct log fields (depth, memory size, call target,
+// stack, storage, ...) are dropped, and no field names are repeated per entry,
+// which makes large traces several times smaller.
+type CompactStructLog [4]uint64
+
+// Column indices of a CompactStructLog.
+const (
+	compactLogPC = iota
+	compactLogOp
+	compactLogGas
+	compactLogGasCost
+)
+
+// compactStructLogs abbreviates struct logs to the CompactStructLog columns. It
+// fails on an opcode name it cannot map back to a byte, rather than encoding it
+// as STOP.
+func compactStructLogs(logs []execution.StructLog) ([]CompactStructLog, error) {
+	compact := make([]CompactStructLog, len(logs))
+
+	for i := range logs {
+		op, ok := opcodeFromString(logs[i].Op)
+		if !ok {
+			return nil, fmt.Errorf("struct log %d: unknown opcode %q", i, logs[i].Op)
+		}
+
+		compact[i] = CompactStructLog{
+			compactLogPC:      uint64(logs[i].PC),
+			compactLogOp:      uint64(op),
+			compactLogGas:     logs[i].Gas,
+			compactLogGasCost: logs[i].GasCost,
+		}
+	}
+
+	return compact, nil
+}
+
+// expandCompactStructLogs restores the struct log fields kept by compactStructLogs.
+// Fields the compact form drops are left zero.
+func expandCompactStructLogs(compact []CompactStructLog) []execution.StructLog {
+	logs := make([]execution.StructLog, len(compact))
+
+	for i, log := range compact {
+		logs[i] = execution.StructLog{
+			PC:      uint32(log[compactLogPC]), //nolint:gosec // PCs fit uint32
+			Op:      opcodeStrings[byte(log[compactLogOp])],
+			Gas:     log[compactLogGas],
+			GasCost: log[compactLogGasCost],
+		}
+	}
+
+	return logs
+}
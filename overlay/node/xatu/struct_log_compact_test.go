@@ -0,0 +1,81 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ethpandaops/execution-processor/pkg/ethereum/execution"
+)
+
+// TestCompactStructLogsRoundTrip verifies that the compact form serializes as
+// arrays and deserializes back to the struct log fields it keeps.
+func TestCompactStructLogsRoundTrip(t *testing.T) {
+	logs := []execution.StructLog{
+		{PC: 0, Op: "PUSH1", Gas: 79000, GasCost: 3, GasUsed: 3, Depth: 1},
+		{PC: 2, Op: "SLOAD", Gas: 78997, GasCost: 2100, GasUsed: 2100, Depth: 1, MemorySize: 64},
+		{PC: 3, Op: "STATICCALL", Gas: 76897, GasCost: 75000, GasUsed: 2700, Depth: 1},
+		{PC: 0, Op: "STOP", Gas: 72000, GasCost: 0, Depth: 2},
+	}
+
+	compact, err := compactStructLogs(logs)
+	if err != nil {
+		t.Fatalf("compactStructLogs: %v", err)
+	}
+
+	data, err := json.Marshal(compact)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	want := `[[0,96,79000,3],[2,84,78997,2100],[3,250,76897,75000],[0,0,72000,0]]`
+	if string(data) != want {
+		t.Errorf("compact JSON = %s, want %s", data, want)
+	}
+
+	var decoded []CompactStructLog
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	// Only the compact columns survive the round trip
+	expected := make([]execution.StructLog, len(logs))
+	for i, log := range logs {
+		expected[i] = execution.StructLog{PC: log.PC, Op: log.Op, Gas: log.Gas, GasCost: log.GasCost}
+	}
+
+	if got := expandCompactStructLogs(decoded); !reflect.DeepEqual(got, expected) {
+		t.Errorf("round trip = %+v, want %+v", got, expected)
+	}
+}
+
+// TestCompactStructLogsUnknownOpcode verifies that an opcode name with no byte is
+// rejected rather than encoded as STOP.
+func TestCompactStructLogsUnknownOpcode(t *testing.T) {
+	logs := []execution.StructLog{
+		{PC: 0, Op: "PUSH1", Gas: 79000, GasCost: 3},
+		{PC: 2, Op: "NOT_AN_OPCODE", Gas: 78997, GasCost: 3},
+	}
+
+	if compact, err := compactStructLogs(logs); err == nil {
+		t.Errorf("compactStructLogs() = %v, want an error for the unknown opcode", compact)
+	}
+}
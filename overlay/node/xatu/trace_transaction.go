@@ -0,0 +1,121 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethpandaops/execution-processor/pkg/ethereum/execution"
+)
+
+// TraceTransactionRequest is the request for xatu_traceTransaction.
+type TraceTransactionRequest struct {
+	TransactionHash  string `json:"transactionHash"`
+	DisableStorage   bool   `json:"disableStorage,omitempty"`
+	DisableStack     bool   `json:"disableStack,omitempty"`
+	DisableMemory    bool   `json:"disableMemory,omitempty"`
+	EnableReturnData bool   `json:"enableReturnData,omitempty"`
+	// StopAfterOpcodes, when > 0, returns only the first StopAfterOpcodes struct
+	// logs and halts execution there, to inspect the start of a long transaction
+	// cheaply.
+	StopAfterOpcodes uint64 `json:"stopAfterOpcodes,omitempty"`
+	// Compact returns the struct logs as CompactStructLogs ([pc, op, gas, gasCost]
+	// arrays) in CompactStructLogs instead of full objects, for bandwidth-constrained
+	// clients.
+	Compact bool `json:"compact,omitempty"`
+	// IncludeTracerStats adds the tracer's own overhead to the result, to diagnose
+	// whether a slow trace spends its time in the EVM or in the tracer.
+	IncludeTracerStats bool `json:"includeTracerStats,omitempty"`
+	// RawGasCost reports each struct log's GasUsed as its GasCost, as geth's struct
+	// logs do, instead of the gas difference to the next opcode in the same frame.
+	RawGasCost bool `json:"rawGasCost,omitempty"`
+}
+
+// TraceTransactionResult is the result of xatu_traceTransaction: the struct log
+// trace of DebugTraceTransaction, with whether it was cut short.
+type TraceTransactionResult struct {
+	*execution.TraceTransaction
+	// Partial is set when StopAfterOpcodes halted execution before the transaction
+	// finished. Gas and Failed then describe the halted execution.
+	Partial bool `json:"partial,omitempty"`
+	// CompactStructLogs replaces the struct logs when Compact is set.
+	CompactStructLogs []CompactStructLog `json:"compactStructLogs,omitempty"`
+	// Refund is the refund counter when the top-level call returned, before the
+	// refund cap, so gas before refunds can be reconciled with the receipt.
+	Refund uint64 `json:"refund"`
+	// TracerStats is debug output on the tracer's overhead (see IncludeTracerStats).
+	TracerStats *TracerStats `json:"tracerStats,omitempty"`
+}
+
+// TraceTransaction returns the struct log trace of a transaction, as
+// DebugTraceTransaction does, optionally stopping after a number of opcodes.
+func (s *Service) TraceTransaction(ctx context.Context, req TraceTransactionRequest) (*TraceTransactionResult, error) {
+	if req.TransactionHash == "" {
+		return nil, fmt.Errorf("transaction hash is required")
+	}
+
+	tracer := NewStructLogTracer(req.tracerConfig())
+
+	trace, err := s.debugTraceTransaction(ctx, req.TransactionHash, tracer)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTraceTransactionResult(req, trace, tracer)
+}
+
+// tracerConfig returns the struct log tracer configuration for the request.
+func (req TraceTransactionRequest) tracerConfig() StructLogConfig {
+	return StructLogConfig{
+		DisableStorage:   req.DisableStorage,
+		DisableStack:     req.DisableStack,
+		DisableMemory:    req.DisableMemory,
+		EnableReturnData: req.EnableReturnData,
+		RawGasCost:       req.RawGasCost,
+		StopAfterOpcodes: req.StopAfterOpcodes,
+		CollectStats:     req.IncludeTracerStats,
+	}
+}
+
+// newTraceTransactionResult builds the response for a trace captured by tracer.
+func newTraceTransactionResult(
+	req TraceTransactionRequest,
+	trace *execution.TraceTransaction,
+	tracer *StructLogTracer,
+) (*TraceTransactionResult, error) {
+	result := &TraceTransactionResult{
+		TraceTransaction: trace,
+		Partial:          tracer.Partial(),
+		Refund:           tracer.Refund(),
+		TracerStats:      tracer.TracerStats(),
+	}
+
+	if req.Compact {
+		compact, err := compactStructLogs(trace.Structlogs)
+		if err != nil {
+			return nil, err
+		}
+
+		result.CompactStructLogs = compact
+		trace.Structlogs = nil
+	}
+
+	return result, nil
+}
@@ -49,6 +49,19 @@ type StructLogConfig struct {
 	DisableStack     bool
 	DisableStorage   bool
 	EnableReturnData bool
+
+	// RawGasCost leaves GasUsed equal to the opcode's (sanitized) GasCost instead
+	// of computing it from the gas difference to the next opcode at the same depth,
+	// matching geth's struct logs. It also skips the pending-index bookkeeping.
+	RawGasCost bool
+
+	// StopAfterOpcodes, when > 0, ends the trace after that many opcodes and halts
+	// the EVM, marking the trace partial. The execution result (gas, failure) then
+	// describes the aborted execution, not the transaction's.
+	StopAfterOpcodes uint64
+
+	// CollectStats records the tracer's own overhead (see TracerStats).
+	CollectStats bool
 }
 
 // pendingCreate tracks a CREATE/CREATE2 opcode waiting for its result address.
@@ -87,6 +100,7 @@ type StructLogTracer struct {
 	err        error
 	env        *tracing.VMContext
 	gasUsed    uint64
+	refund     uint64
 	returnData []byte
 
 	// pendingIdx tracks the index of the pending (last seen) log at each call depth.
@@ -97,25 +111,44 @@ type StructLogTracer struct {
 	// pendingCreates tracks CREATE/CREATE2 opcodes waiting for their result address.
 	// When execution returns to the CREATE's depth, the created address is on the stack.
 	pendingCreates []pendingCreate
+
+	// Tracer overhead (nil unless CollectStats is enabled)
+	stats *TracerStats
+
+	// abort halts the EVM (set to its Cancel by executeWithTracer), and partial
+	// records that StopAfterOpcodes cut the trace short.
+	abort   func()
+	partial bool
 }
 
 // NewStructLogTracer creates a new structlog tracer.
 func NewStructLogTracer(cfg StructLogConfig) *StructLogTracer {
-	return &StructLogTracer{
+	t := &StructLogTracer{
 		cfg:            cfg,
 		logs:           make([]execution.StructLog, 0, 256),
 		pendingIdx:     make([]int, 0, 16), // EVM max depth is 1024, but 16 is typical
 		pendingCreates: nil,
 	}
+
+	if cfg.CollectStats {
+		t.stats = &TracerStats{}
+	}
+
+	return t
 }
 
 // Hooks returns the tracing hooks for the EVM.
 func (t *StructLogTracer) Hooks() *tracing.Hooks {
+	onOpcode := t.OnOpcode
+	if t.stats != nil {
+		onOpcode = t.stats.instrument(t.OnOpcode, func() int { return cap(t.logs) })
+	}
+
 	return &tracing.Hooks{
 		OnTxStart: t.OnTxStart,
 		OnTxEnd:   t.OnTxEnd,
 		OnExit:    t.OnExit,
-		OnOpcode:  t.OnOpcode,
+		OnOpcode:  onOpcode,
 	}
 }
 
@@ -181,8 +214,23 @@ func isCreateOpcode(op vm.OpCode) bool {
 func (t *StructLogTracer) OnOpcode(pc uint64, opcode byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
 	op := vm.OpCode(opcode)
 
+	// The EVM only checks for cancellation periodically, so opcodes can still
+	// arrive after the limit was reached
+	if t.cfg.StopAfterOpcodes > 0 && uint64(len(t.logs)) >= t.cfg.StopAfterOpcodes {
+		// The first opcode past the limit still finalizes the GasUsed of the last
+		// log at its depth, as it would if the trace went on
+		if !t.partial && !t.cfg.RawGasCost {
+			t.updatePendingGasUsed(depth, gas)
+		}
+
+		t.stop()
+		return
+	}
+
 	// Compute GasUsed for the pending log at this depth before adding new log.
-	t.updatePendingGasUsed(depth, gas)
+	if !t.cfg.RawGasCost {
+		t.updatePendingGasUsed(depth, gas)
+	}
 
 	// Resolve any pending CREATEs that have completed.
 	// When execution returns to the CREATE's depth (or lower), the created address
@@ -279,7 +327,10 @@ func (t *StructLogTracer) OnOpcode(pc uint64, opcode byte, gas, cost uint64, sco
 	// Track this log as pending at current depth for GasUsed computation.
 	logIdx := len(t.logs)
 	t.logs = append(t.logs, log)
-	t.setPendingIdx(depth, logIdx)
+
+	if !t.cfg.RawGasCost {
+		t.setPendingIdx(depth, logIdx)
+	}
 
 	// Track CREATE/CREATE2 opcodes for address resolution.
 	// The created address will be extracted when execution returns to this depth.
@@ -291,6 +342,18 @@ func (t *StructLogTracer) OnOpcode(pc uint64, opcode byte, gas, cost uint64, sco
 	}
 }
 
+// stop marks the trace partial and halts the EVM, once.
+func (t *StructLogTracer) stop() {
+	if t.partial {
+		return
+	}
+
+	t.partial = true
+	if t.abort != nil {
+		t.abort()
+	}
+}
+
 // updatePendingGasUsed updates the GasUsed field for the pending log at the given depth.
 // GasUsed = pendingLog.Gas - currentGas (the gas consumed by that opcode).
 func (t *StructLogTracer) updatePendingGasUsed(depth int, currentGas uint64) {
@@ -321,8 +384,13 @@ func (t *StructLogTracer) setPendingIdx(depth, logIdx int) {
 }
 
 // resolvePendingCreates resolves any pending CREATE/CREATE2 opcodes that have completed.
-// When execution returns to the CREATE's depth (or lower), the created address (or 0 on
-// failure) is at the top of the current opcode's stack.
+// When execution returns to the CREATE's depth, the created address is at the top of the
+// current opcode's stack. A failed creation (e.g. a reverted constructor) pushes 0, so its
+// CallToAddress is the zero address.
+//
+// If execution returns to a lower depth instead, the CREATE itself aborted its frame (e.g.
+// out of gas) and pushed nothing: the stack belongs to the parent frame, so CallToAddress
+// is left nil.
 func (t *StructLogTracer) resolvePendingCreates(currentDepth int, scope tracing.OpContext) {
 	for len(t.pendingCreates) > 0 {
 		last := t.pendingCreates[len(t.pendingCreates)-1]
@@ -331,7 +399,7 @@ func (t *StructLogTracer) resolvePendingCreates(currentDepth int, scope tracing.
 		if currentDepth <= last.depth {
 			// Extract created address from top of stack.
 			stack := scope.StackData()
-			if len(stack) > 0 {
+			if currentDepth == last.depth && len(stack) > 0 {
 				addr := &stack[len(stack)-1]
 				addrBytes := addr.Bytes20()
 				addrStr := "0x" + hex.EncodeToString(addrBytes[:])
@@ -354,6 +422,13 @@ func (t *StructLogTracer) OnExit(depth int, output []byte, _ uint64, err error,
 	t.output = make([]byte, len(output))
 	copy(t.output, output)
 	t.err = err
+
+	// The refund counter is final once the top-level call returns. It is read here
+	// rather than in OnTxEnd, which runs after the state transition has consumed it
+	// (and is not called at all when a message is applied directly).
+	if t.env != nil {
+		t.refund = getRefundValue(t.env.IntraBlockState)
+	}
 }
 
 // GetTraceTransaction returns the trace result in execution-processor format.
@@ -372,11 +447,29 @@ func (t *StructLogTracer) GetTraceTransaction() *execution.TraceTransaction {
 	return trace
 }
 
+// Refund returns the transaction-final refund counter, before the refund cap
+// (gasUsed/5 since London) is applied. execution.TraceTransaction is defined by
+// execution-processor and has no refund field, so it is exposed separately.
+func (t *StructLogTracer) Refund() uint64 {
+	return t.refund
+}
+
+// TracerStats returns the tracer's overhead, or nil unless CollectStats is enabled.
+func (t *StructLogTracer) TracerStats() *TracerStats {
+	return t.stats.snapshot()
+}
+
 // StructLogs returns the captured log entries.
 func (t *StructLogTracer) StructLogs() []execution.StructLog {
 	return t.logs
 }
 
+// Partial reports whether StopAfterOpcodes ended the trace before the transaction
+// finished.
+func (t *StructLogTracer) Partial() bool {
+	return t.partial
+}
+
 // Error returns the VM error captured by the trace.
 func (t *StructLogTracer) Error() error {
 	return t.err
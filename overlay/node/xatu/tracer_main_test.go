@@ -23,9 +23,15 @@ import (
 
 	"github.com/holiman/uint256"
 
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/common/hexutil"
+	"github.com/erigontech/erigon/execution/protocol"
+	"github.com/erigontech/erigon/execution/protocol/params"
 	"github.com/erigontech/erigon/execution/tracing"
+	"github.com/erigontech/erigon/execution/types"
 	"github.com/erigontech/erigon/execution/types/accounts"
 	"github.com/erigontech/erigon/execution/vm"
+	"github.com/erigontech/erigon/rpc/ethapi"
 )
 
 // =============================================================================
@@ -35,6 +41,7 @@ import (
 // mockIntraBlockState implements tracing.IntraBlockState for testing.
 type mockIntraBlockState struct {
 	refund uint64
+	slots  map[accounts.StorageKey]uint256.Int
 }
 
 func (m *mockIntraBlockState) GetBalance(accounts.Address) (uint256.Int, error) {
@@ -45,8 +52,8 @@ func (m *mockIntraBlockState) GetCode(accounts.Address) ([]byte, error)  { retur
 func (m *mockIntraBlockState) GetCodeHash(accounts.Address) (accounts.CodeHash, error) {
 	return accounts.CodeHash{}, nil
 }
-func (m *mockIntraBlockState) GetState(accounts.Address, accounts.StorageKey) (uint256.Int, error) {
-	return uint256.Int{}, nil
+func (m *mockIntraBlockState) GetState(_ accounts.Address, key accounts.StorageKey) (uint256.Int, error) {
+	return m.slots[key], nil
 }
 func (m *mockIntraBlockState) Exist(accounts.Address) (bool, error) { return false, nil }
 func (m *mockIntraBlockState) GetRefund() uint64                    { return m.refund }
@@ -190,6 +197,48 @@ func TestRefundCaptureAcrossOpcodes(t *testing.T) {
 	}
 }
 
+// TestFinalRefundCapture simulates a transaction that clears a storage slot from a
+// nested call and verifies the refund counter at the end of the top-level call is
+// reported, so intrinsic + execution - refund reconciles with the receipt.
+func TestFinalRefundCapture(t *testing.T) {
+	tracer := NewStructLogTracer(StructLogConfig{})
+	ctx := newMockOpContext(10)
+
+	mockState := &mockIntraBlockState{}
+	tracer.OnTxStart(&tracing.VMContext{IntraBlockState: mockState}, nil, accounts.Address{})
+
+	tracer.OnOpcode(0, byte(vm.CALL), 100000, 50000, ctx, nil, 1, nil)
+	tracer.OnOpcode(0, byte(vm.SSTORE), 45000, 5000, ctx, nil, 2, nil)
+
+	// SSTORE to zero adds the clearing refund once the opcode executes
+	mockState.refund = 4800
+	tracer.OnExit(1, nil, 5000, nil, false)
+
+	if got := tracer.Refund(); got != 0 {
+		t.Errorf("refund after nested exit = %d, want 0 (only the top-level exit is final)", got)
+	}
+
+	tracer.OnOpcode(1, byte(vm.STOP), 90000, 0, ctx, nil, 1, nil)
+	tracer.OnExit(0, nil, 10000, nil, false)
+
+	// The state transition consumes the counter before OnTxEnd
+	mockState.refund = 0
+	tracer.OnTxEnd(&types.Receipt{GasUsed: 30000}, nil)
+
+	if got := tracer.Refund(); got != 4800 {
+		t.Errorf("final refund = %d, want 4800", got)
+	}
+
+	result, err := newTraceTransactionResult(TraceTransactionRequest{}, tracer.GetTraceTransaction(), tracer)
+	if err != nil {
+		t.Fatalf("newTraceTransactionResult: %v", err)
+	}
+
+	if result.Refund != 4800 {
+		t.Errorf("trace result refund = %d, want 4800", result.Refund)
+	}
+}
+
 // TestGasCostSanitization verifies that corrupted gasCost values from
 // Erigon's unsigned integer underflow bug are sanitized.
 //
@@ -442,6 +491,106 @@ func TestGasUsedAcrossDepths(t *testing.T) {
 	}
 }
 
+// TestRawGasCost verifies that with RawGasCost set on an xatu_traceTransaction
+// request, GasUsed is the sanitized GasCost of every log rather than the gas
+// difference to the next opcode.
+func TestRawGasCost(t *testing.T) {
+	tracer := NewStructLogTracer(TraceTransactionRequest{RawGasCost: true}.tracerConfig())
+	ctx := newMockOpContext(10)
+
+	// Same call sequence as TestGasUsedAcrossDepths, ending in an OOG opcode
+	tracer.OnOpcode(0, byte(vm.CALL), 10000, 100, ctx, nil, 1, nil)
+	tracer.OnOpcode(1, byte(vm.ADD), 9000, 50, ctx, nil, 2, nil)
+	tracer.OnOpcode(2, byte(vm.MUL), 8950, 30, ctx, nil, 2, nil)
+	tracer.OnOpcode(3, byte(vm.POP), 8900, 20, ctx, nil, 1, nil)
+	tracer.OnOpcode(4, byte(vm.MLOAD), 8880, 3688376207808, ctx, nil, 1, vm.ErrOutOfGas)
+
+	logs := tracer.StructLogs()
+	if len(logs) != 5 {
+		t.Fatalf("expected 5 logs, got %d", len(logs))
+	}
+
+	for i, log := range logs {
+		if log.GasUsed != log.GasCost {
+			t.Errorf("log[%d].GasUsed = %d, want GasCost %d", i, log.GasUsed, log.GasCost)
+		}
+	}
+
+	// The CALL keeps its own cost, not the 1100 gas consumed by the subcall
+	if logs[0].GasUsed != 100 {
+		t.Errorf("log[0].GasUsed = %d, want 100", logs[0].GasUsed)
+	}
+
+	if logs[4].GasCost != 8880 {
+		t.Errorf("log[4].GasCost = %d, want the sanitized 8880", logs[4].GasCost)
+	}
+}
+
+// TestStopAfterOpcodes verifies that a 1000-opcode execution traced with a limit
+// of 50 keeps exactly 50 logs, halts the EVM once and is marked partial.
+func TestStopAfterOpcodes(t *testing.T) {
+	aborts := 0
+
+	tracer := NewStructLogTracer(StructLogConfig{StopAfterOpcodes: 50})
+	tracer.abort = func() { aborts++ }
+	ctx := newMockOpContext(2)
+
+	// The EVM only notices the cancellation later, so opcodes keep arriving
+	gas := uint64(100000)
+	for pc := range uint64(1000) {
+		tracer.OnOpcode(pc, byte(vm.ADD), gas, 3, ctx, nil, 1, nil)
+		gas -= 3
+	}
+
+	if logs := tracer.StructLogs(); len(logs) != 50 {
+		t.Fatalf("expected 50 logs, got %d", len(logs))
+	}
+
+	if !tracer.Partial() {
+		t.Error("trace not marked partial")
+	}
+
+	if aborts != 1 {
+		t.Errorf("EVM aborted %d times, want 1", aborts)
+	}
+
+	// Without a limit the trace is complete
+	full := NewStructLogTracer(StructLogConfig{})
+	for pc := range uint64(1000) {
+		full.OnOpcode(pc, byte(vm.ADD), 100000, 3, ctx, nil, 1, nil)
+	}
+
+	if len(full.StructLogs()) != 1000 || full.Partial() {
+		t.Errorf("unlimited trace: %d logs, partial %v; want 1000, false", len(full.StructLogs()), full.Partial())
+	}
+}
+
+// TestStopAfterOpcodesFinalizesGasUsed verifies that the last log kept by
+// StopAfterOpcodes gets its GasUsed from the opcode that hit the limit, and is not
+// changed by opcodes arriving after the EVM was halted.
+func TestStopAfterOpcodesFinalizesGasUsed(t *testing.T) {
+	tracer := NewStructLogTracer(StructLogConfig{StopAfterOpcodes: 2})
+	ctx := newMockOpContext(2)
+
+	// Each opcode reports a cost of 3 but consumes 5
+	gas := uint64(100000)
+	for pc := range uint64(4) {
+		tracer.OnOpcode(pc, byte(vm.ADD), gas, 3, ctx, nil, 1, nil)
+		gas -= 5
+	}
+
+	logs := tracer.StructLogs()
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(logs))
+	}
+
+	for i, log := range logs {
+		if log.GasUsed != 5 {
+			t.Errorf("log %d GasUsed = %d, want 5", i, log.GasUsed)
+		}
+	}
+}
+
 // TestGasUsedOOGAtDepth verifies that an OOG opcode at a nested depth
 // has its GasUsed correctly capped.
 func TestGasUsedOOGAtDepth(t *testing.T) {
@@ -484,6 +633,146 @@ func TestGasUsedOOGAtDepth(t *testing.T) {
 	}
 }
 
+// TestCreateRevertedConstructor verifies a CREATE whose constructor reverts: the
+// CREATE is charged its base and init code gas plus the gas the constructor used,
+// with no code-deposit gas, and its CallToAddress is the zero address pushed for
+// the failed creation.
+func TestCreateRevertedConstructor(t *testing.T) {
+	tracer := NewStructLogTracer(StructLogConfig{})
+	parent := newMockOpContext(3)
+	child := newMockOpContext(2)
+
+	// CREATE (depth 1): 32000 base + 2 for one word of init code. The constructor
+	// (depth 2) runs PUSH1 0, PUSH1 0, REVERT, returning its unused gas, and the
+	// parent resumes with 0 on the stack.
+	tracer.OnOpcode(0, byte(vm.CREATE), 100000, 32002, parent, nil, 1, nil)
+	tracer.OnOpcode(0, byte(vm.PUSH1), 67000, 3, child, nil, 2, nil)
+	tracer.OnOpcode(2, byte(vm.PUSH1), 66997, 3, child, nil, 2, nil)
+	tracer.OnOpcode(4, byte(vm.REVERT), 66994, 0, child, nil, 2, nil)
+
+	resumed := newMockOpContext(1)
+	resumed.stack[0].Clear()
+	tracer.OnOpcode(1, byte(vm.POP), 67992, 2, resumed, nil, 1, nil)
+
+	logs := tracer.StructLogs()
+	if len(logs) != 5 {
+		t.Fatalf("expected 5 logs, got %d", len(logs))
+	}
+
+	create := logs[0]
+
+	// Init code gas (32002) and constructor execution (6) only
+	if create.GasUsed != 32008 {
+		t.Errorf("CREATE GasUsed = %d, want 32008", create.GasUsed)
+	}
+
+	if create.CallToAddress == nil || *create.CallToAddress != "0x0000000000000000000000000000000000000000" {
+		t.Errorf("CREATE CallToAddress = %v, want the zero address", create.CallToAddress)
+	}
+}
+
+// TestCreateRevertedConstructorExecution runs a contract whose CREATE deploys a
+// constructor that reverts with 32 bytes of return data, and checks that the
+// receipt charges the init code and constructor gas but no code deposit, and that
+// the CREATE's struct log reports the same with the zero address pushed.
+func TestCreateRevertedConstructorExecution(t *testing.T) {
+	// MSTORE(0, PUSH1 32, PUSH0, REVERT), CREATE(0, 28, 4), POP
+	code := []byte{
+		0x63, 0x60, 0x20, 0x5f, 0xfd, 0x60, 0x00, 0x52,
+		0x60, 0x04, 0x60, 0x1c, 0x60, 0x00, 0xf0, 0x50, 0x00,
+	}
+
+	c := newTestChain(t, map[common.Address][]byte{testContract: code})
+
+	gas := hexutil.Uint64(1_000_000)
+	args := ethapi.CallArgs{From: &testSender, To: &testContract, Gas: &gas}
+
+	msg, err := args.ToMessage(c.header.GasLimit, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracer := NewStructLogTracer(StructLogConfig{})
+
+	result, err := c.s.executeWithTracer(c.statedb, c.blockCtx, protocol.NewEVMTxContext(msg), msg, tracer, nil, c.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		createGas      = params.CreateGas + params.InitCodeWordGas // One word of init code
+		constructorGas = 3 + 2 + 3                                 // PUSH1, PUSH0, REVERT expanding one word
+	)
+
+	// PUSH4, PUSH1, MSTORE expanding one word, 3 PUSH1, CREATE, POP
+	want := params.TxGas + 3 + 3 + 6 + 3*3 + createGas + constructorGas + 2
+	if result.Failed() || result.ReceiptGasUsed != want {
+		t.Errorf("receipt gas = %d (failed %v), want %d without the %d of a code deposit",
+			result.ReceiptGasUsed, result.Failed(), want, 32*params.CreateDataGas)
+	}
+
+	logs := tracer.StructLogs()
+
+	var found bool
+	for _, log := range logs {
+		if log.Op != "CREATE" {
+			continue
+		}
+
+		found = true
+
+		if log.Depth != 1 || log.GasUsed != createGas+constructorGas {
+			t.Errorf("CREATE at depth %d used %d gas, want depth 1 and %d", log.Depth, log.GasUsed, createGas+constructorGas)
+		}
+
+		if log.CallToAddress == nil || *log.CallToAddress != "0x0000000000000000000000000000000000000000" {
+			t.Errorf("CREATE CallToAddress = %v, want the zero address", log.CallToAddress)
+		}
+	}
+
+	if !found {
+		t.Fatalf("no CREATE in %d struct logs", len(logs))
+	}
+}
+
+// TestCreateAddressResolution verifies that a successful CREATE reports the created
+// address, and that a CREATE which aborts its own frame reports none rather than
+// the parent frame's stack top.
+func TestCreateAddressResolution(t *testing.T) {
+	tracer := NewStructLogTracer(StructLogConfig{})
+	ctx := newMockOpContext(3)
+
+	created := newMockOpContext(1)
+	created.stack[0].SetUint64(0xc0ffee)
+
+	// Successful CREATE2 at depth 1
+	tracer.OnOpcode(0, byte(vm.CREATE2), 100000, 32006, ctx, nil, 1, nil)
+	tracer.OnOpcode(0, byte(vm.STOP), 60000, 0, ctx, nil, 2, nil)
+	tracer.OnOpcode(1, byte(vm.POP), 60000, 2, created, nil, 1, nil)
+
+	// CREATE at depth 2 running out of gas: its frame ends and the parent resumes
+	// at depth 1 with the (non-zero) result of its own CALL on the stack
+	tracer.OnOpcode(2, byte(vm.CALL), 59998, 2600, ctx, nil, 1, nil)
+	tracer.OnOpcode(0, byte(vm.CREATE), 100, 32000, ctx, nil, 2, vm.ErrOutOfGas)
+
+	callResult := newMockOpContext(1)
+	callResult.stack[0].SetOne()
+	tracer.OnOpcode(3, byte(vm.POP), 20000, 2, callResult, nil, 1, nil)
+
+	logs := tracer.StructLogs()
+	if len(logs) != 6 {
+		t.Fatalf("expected 6 logs, got %d", len(logs))
+	}
+
+	if got := logs[0].CallToAddress; got == nil || *got != "0x0000000000000000000000000000000000c0ffee" {
+		t.Errorf("CREATE2 CallToAddress = %v, want the created address", got)
+	}
+
+	if got := logs[4].CallToAddress; got != nil {
+		t.Errorf("aborted CREATE CallToAddress = %q, want nil", *got)
+	}
+}
+
 // TestMemorySizeCapture verifies that MemorySize is captured for all opcodes.
 func TestMemorySizeCapture(t *testing.T) {
 	tracer := NewStructLogTracer(StructLogConfig{})
@@ -939,3 +1228,76 @@ func generateOpcodeSequence(count, callFrequency int) []byte {
 
 	return opcodes
 }
+
+// TestTracerStats verifies that both tracers count their opcodes and track the
+// capacity of their per-opcode records only when stats are enabled.
+func TestTracerStats(t *testing.T) {
+	ctx := newMockOpContext(10)
+
+	if stats := NewStructLogTracer(StructLogConfig{}).TracerStats(); stats != nil {
+		t.Errorf("structlog stats without CollectStats = %+v, want nil", stats)
+	}
+
+	if stats := NewSimulationTracer(nil, SimulationTracerConfig{}).TracerStats(); stats != nil {
+		t.Errorf("simulation stats without CollectStats = %+v, want nil", stats)
+	}
+
+	const opcodes = 300 // more than the initial struct log capacity
+
+	structLogs := NewStructLogTracer(StructLogConfig{CollectStats: true})
+	onOpcode := structLogs.Hooks().OnOpcode
+
+	for i := range opcodes {
+		onOpcode(uint64(i), byte(vm.ADD), 100000-uint64(i)*3, 3, ctx, nil, 1, nil)
+	}
+
+	stats := structLogs.TracerStats()
+	if stats.Opcodes != opcodes || stats.TracerTimeNs <= 0 {
+		t.Errorf("structlog stats = %+v, want %d opcodes and nonzero time", stats, opcodes)
+	}
+
+	if stats.PeakLogCapacity < opcodes {
+		t.Errorf("structlog peak capacity = %d, want >= %d", stats.PeakLogCapacity, opcodes)
+	}
+
+	simulation := NewSimulationTracer(nil, SimulationTracerConfig{CollectStats: true, RecordSequence: true})
+	onOpcode = simulation.Hooks().OnOpcode
+
+	onOpcode(0, byte(vm.ADD), 100000, 3, ctx, nil, 1, nil)
+	onOpcode(1, byte(vm.POP), 99997, 2, ctx, nil, 1, nil)
+
+	if stats := simulation.TracerStats(); stats.Opcodes != 2 || stats.PeakLogCapacity < 2 {
+		t.Errorf("simulation stats = %+v, want 2 opcodes", stats)
+	}
+
+	simulation.Reset()
+
+	if stats := simulation.TracerStats(); stats.Opcodes != 0 {
+		t.Errorf("simulation stats after Reset = %+v, want zero", stats)
+	}
+}
+
+// TestTraceTransactionResultTracerStats verifies that xatu_traceTransaction returns
+// the tracer's stats only when IncludeTracerStats is set.
+func TestTraceTransactionResultTracerStats(t *testing.T) {
+	ctx := newMockOpContext(10)
+
+	for _, include := range []bool{false, true} {
+		req := TraceTransactionRequest{IncludeTracerStats: include}
+		tracer := NewStructLogTracer(StructLogConfig{CollectStats: req.IncludeTracerStats})
+
+		tracer.Hooks().OnOpcode(0, byte(vm.ADD), 100000, 3, ctx, nil, 1, nil)
+
+		result, err := newTraceTransactionResult(req, tracer.GetTraceTransaction(), tracer)
+		if err != nil {
+			t.Fatalf("newTraceTransactionResult: %v", err)
+		}
+
+		switch {
+		case !include && result.TracerStats != nil:
+			t.Errorf("TracerStats without IncludeTracerStats = %+v, want nil", result.TracerStats)
+		case include && (result.TracerStats == nil || result.TracerStats.Opcodes != 1):
+			t.Errorf("TracerStats with IncludeTracerStats = %+v, want 1 opcode", result.TracerStats)
+		}
+	}
+}
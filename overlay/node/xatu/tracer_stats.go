@@ -0,0 +1,84 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"time"
+
+	"github.com/erigontech/erigon/execution/tracing"
+)
+
+// TracerStats reports a tracer's own overhead for a transaction, to tell whether a
+// slow trace spends its time in the EVM or in the tracer.
+type TracerStats struct {
+	Opcodes      uint64 `json:"opcodes"`      // OnOpcode calls
+	TracerTimeNs int64  `json:"tracerTimeNs"` // Wall time spent in OnOpcode
+	// PeakLogCapacity is the largest capacity reached by the tracer's per-opcode
+	// records: struct logs, or the opcode sequence of a SimulationTracer.
+	PeakLogCapacity int `json:"peakLogCapacity"`
+}
+
+// TracerStatsComparison holds the tracer stats of both executions.
+type TracerStatsComparison struct {
+	Original  *TracerStats `json:"original"`
+	Simulated *TracerStats `json:"simulated"`
+}
+
+// opcodeHook is the signature of tracing.Hooks.OnOpcode.
+type opcodeHook = func(pc uint64, opcode byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error)
+
+// instrument wraps an OnOpcode hook to count and time its calls. capacity returns
+// the current capacity of the tracer's per-opcode records.
+//
+// Tracers install the wrapper in Hooks() only when stats are enabled, so the check
+// happens once per transaction and disabled tracers pay nothing per opcode.
+func (s *TracerStats) instrument(hook opcodeHook, capacity func() int) opcodeHook {
+	return func(pc uint64, opcode byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+		start := time.Now()
+		hook(pc, opcode, gas, cost, scope, rData, depth, err)
+		s.TracerTimeNs += time.Since(start).Nanoseconds()
+
+		s.Opcodes++
+		s.PeakLogCapacity = max(s.PeakLogCapacity, capacity())
+	}
+}
+
+// snapshot returns a copy of the stats, or nil when they are not collected.
+func (s *TracerStats) snapshot() *TracerStats {
+	if s == nil {
+		return nil
+	}
+
+	copied := *s
+
+	return &copied
+}
+
+// combineTracerStats pairs the stats of both tracers, or returns nil when they are
+// not collected.
+func combineTracerStats(originalTracer, simulatedTracer *SimulationTracer) *TracerStatsComparison {
+	if originalTracer.stats == nil {
+		return nil
+	}
+
+	return &TracerStatsComparison{
+		Original:  originalTracer.TracerStats(),
+		Simulated: simulatedTracer.TracerStats(),
+	}
+}
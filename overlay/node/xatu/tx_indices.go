@@ -0,0 +1,57 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"fmt"
+	"slices"
+)
+
+// selectTransactions returns the indices of the transactions to simulate, in block
+// order with duplicates removed. An empty selection means every transaction.
+//
+// Skipping transactions does not change the state the selected ones run against:
+// each transaction is executed on the historical state at its position in the block
+// (ComputeBlockContext reads the state left by transactions 0..N-1), not on the state
+// produced by the previous simulated execution. A selected transaction therefore has
+// the same result as in a full block simulation, and earlier transactions need not
+// be replayed.
+func selectTransactions(indices []uint64, txCount int) ([]int, error) {
+	if len(indices) == 0 {
+		all := make([]int, txCount)
+		for i := range all {
+			all[i] = i
+		}
+
+		return all, nil
+	}
+
+	selected := make([]int, 0, len(indices))
+	for _, idx := range indices {
+		if idx >= uint64(txCount) {
+			return nil, fmt.Errorf("transaction index %d out of range (block has %d transactions)", idx, txCount)
+		}
+
+		selected = append(selected, int(idx))
+	}
+
+	slices.Sort(selected)
+
+	return slices.Compact(selected), nil
+}
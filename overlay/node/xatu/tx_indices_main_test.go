@@ -0,0 +1,109 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestSubsetMatchesFullBlock executes a block of transactions that each overwrite
+// the slot the previous one set, so every transaction depends on the ones before
+// it. Simulating only transaction 5 must report the same summary as simulating the
+// full block, whose original gas must match executing the block in order.
+func TestSubsetMatchesFullBlock(t *testing.T) {
+	const txCount = 10
+
+	contracts := map[common.Address][]byte{testContract: storeCalldata}
+
+	txs := make([][]byte, txCount)
+	for i := range txs {
+		txs[i] = word(byte(i + 1))
+	}
+
+	opts := executionOptions{GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{
+		vm.GasKeySstoreSet:   30000,
+		vm.GasKeySstoreReset: 4000,
+	}}}
+
+	// Runs transaction txIndex on the state left by the transactions before it, as
+	// computeBlockContext provides it from history
+	historical := func(txIndex int) func(*SimulationTracer, executionOptions) (*executionResult, error) {
+		return func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+			c := newTestChain(t, contracts)
+			for i := range txIndex {
+				c.call(testContract, txs[i], executionOptions{})
+			}
+
+			return c.callTraced(testContract, txs[txIndex], tracer, opts), nil
+		}
+	}
+
+	simulate := func(indices []uint64) *SimulateBlockGasResult {
+		selected, err := selectTransactions(indices, txCount)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		result := &SimulateBlockGasResult{OpcodeBreakdown: make(map[string]OpcodeSummary)}
+		for _, i := range selected {
+			dual, err := runDualExecution(opts, SimulationTracerConfig{}, historical(i))
+			if err != nil {
+				t.Fatalf("tx %d: %v", i, err)
+			}
+
+			result.addDualResult(fmt.Sprintf("0x%064x", i), i, dual)
+		}
+
+		return result
+	}
+
+	full := simulate(nil)
+
+	// The block executed in order on one state
+	block := newTestChain(t, contracts)
+	for i, data := range txs {
+		if got, want := full.Transactions[i].OriginalGas, block.call(testContract, data, executionOptions{}).GasUsed; got != want {
+			t.Errorf("full simulation tx %d original gas = %d, block execution used %d", i, got, want)
+		}
+	}
+
+	subset := simulate([]uint64{5})
+
+	if len(subset.Transactions) != 1 {
+		t.Fatalf("subset simulated %d transactions, want 1", len(subset.Transactions))
+	}
+
+	if !reflect.DeepEqual(subset.Transactions[0], full.Transactions[5]) {
+		t.Errorf("subset tx = %+v, full block tx 5 = %+v", subset.Transactions[0], full.Transactions[5])
+	}
+
+	if subset.Original.GasUsed != full.Transactions[5].OriginalGas || subset.Simulated.GasUsed != full.Transactions[5].SimulatedGas {
+		t.Errorf("subset totals %d/%d do not match tx 5", subset.Original.GasUsed, subset.Simulated.GasUsed)
+	}
+
+	// Transaction 5 resets the slot, so only the SSTORE_RESET override applies
+	if full.Transactions[5].SimulatedGas == full.Transactions[5].OriginalGas {
+		t.Error("tx 5 simulated gas unchanged, want the SSTORE_RESET override to apply")
+	}
+}
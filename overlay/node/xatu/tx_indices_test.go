@@ -0,0 +1,60 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectTransactions(t *testing.T) {
+	tests := []struct {
+		name    string
+		indices []uint64
+		txCount int
+		want    []int
+		wantErr bool
+	}{
+		{name: "empty selects all", txCount: 3, want: []int{0, 1, 2}},
+		{name: "empty block", txCount: 0, want: []int{}},
+		{name: "sorted and deduplicated", indices: []uint64{7, 5, 7, 6}, txCount: 10, want: []int{5, 6, 7}},
+		{name: "out of range", indices: []uint64{3}, txCount: 3, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := selectTransactions(tc.indices, tc.txCount)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("selectTransactions() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// DecodeTxInputRequest is the request for xatu_decodeTxInput.
+type DecodeTxInputRequest struct {
+	TransactionHash string `json:"transactionHash"`
+	BlockNumber     uint64 `json:"blockNumber"` // Optional; must match the transaction's block if set
+	// ABI is an optional contract ABI (standard JSON format). When set, the selector is
+	// matched against its functions to resolve the method name and signature.
+	ABI json.RawMessage `json:"abi,omitempty"`
+}
+
+// DecodeTxInputResult is the result of xatu_decodeTxInput.
+type DecodeTxInputResult struct {
+	TransactionHash string `json:"transactionHash"`
+	BlockNumber     uint64 `json:"blockNumber"`
+	// Selector is the 4-byte function selector. Empty when the input is shorter than
+	// 4 bytes (plain transfers) or the transaction creates a contract.
+	Selector string `json:"selector,omitempty"`
+	// Method and Signature are set when an ABI is provided and contains the selector.
+	Method    string `json:"method,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// Args holds the input after the selector split into 32-byte words. Dynamic
+	// arguments appear as offsets into the same words, as in the raw ABI encoding.
+	Args []string `json:"args"`
+	// Trailing holds leftover bytes that do not fill a full word (non-ABI input).
+	Trailing string `json:"trailing,omitempty"`
+}
+
+// abiArgument is an input of an ABI function entry. Components are set for tuples.
+type abiArgument struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Components []abiArgument `json:"components,omitempty"`
+}
+
+// abiEntry is a single entry of a JSON ABI. Only functions are used; an entry
+// without a type is a function, as in the ABI specification.
+type abiEntry struct {
+	Type   string        `json:"type"`
+	Name   string        `json:"name"`
+	Inputs []abiArgument `json:"inputs"`
+}
+
+// DecodeTxInput returns a transaction's function selector and its arguments split into
+// words. Decoding against an ABI is optional, since contract ABIs aren't available to
+// the node.
+func (s *Service) DecodeTxInput(ctx context.Context, req DecodeTxInputRequest) (*DecodeTxInputResult, error) {
+	var entries []abiEntry
+	if len(req.ABI) > 0 {
+		if err := json.Unmarshal(req.ABI, &entries); err != nil {
+			return nil, fmt.Errorf("invalid ABI: %w", err)
+		}
+	}
+
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	block, txIndex, err := s.lookupTransaction(ctx, tx, s.txNumsReader(ctx), req.TransactionHash, req.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := block.Transactions()[txIndex]
+
+	result := &DecodeTxInputResult{
+		TransactionHash: req.TransactionHash,
+		BlockNumber:     block.NumberU64(),
+		Args:            []string{},
+	}
+
+	// Contract creation input is init code, not a call
+	if txn.GetTo() == nil {
+		return result, nil
+	}
+
+	decodeInput(result, txn.GetData(), entries)
+
+	return result, nil
+}
+
+// decodeInput fills the selector, argument words and (if the ABI matches) the method
+// of result from raw call data.
+func decodeInput(result *DecodeTxInputResult, data []byte, entries []abiEntry) {
+	if len(data) < 4 {
+		return
+	}
+
+	selector := data[:4]
+	result.Selector = "0x" + hex.EncodeToString(selector)
+
+	args := data[4:]
+	for len(args) >= 32 {
+		result.Args = append(result.Args, "0x"+hex.EncodeToString(args[:32]))
+		args = args[32:]
+	}
+
+	if len(args) > 0 {
+		result.Trailing = "0x" + hex.EncodeToString(args)
+	}
+
+	for _, entry := range entries {
+		if entry.Type != "" && entry.Type != "function" {
+			continue
+		}
+
+		signature := abiSignature(entry)
+		if string(functionSelector(signature)) == string(selector) {
+			result.Method = entry.Name
+			result.Signature = signature
+			return
+		}
+	}
+}
+
+// abiSignature returns the canonical signature of a function, e.g. "transfer(address,uint256)".
+func abiSignature(entry abiEntry) string {
+	return entry.Name + canonicalTypes(entry.Inputs)
+}
+
+// canonicalTypes renders a parenthesised, comma-separated list of canonical types.
+// Tuples are expanded into their component types, keeping any array suffix.
+func canonicalTypes(args []abiArgument) string {
+	types := make([]string, len(args))
+
+	for i, arg := range args {
+		if suffix, ok := strings.CutPrefix(arg.Type, "tuple"); ok {
+			types[i] = canonicalTypes(arg.Components) + suffix
+		} else {
+			types[i] = canonicalType(arg.Type)
+		}
+	}
+
+	return "(" + strings.Join(types, ",") + ")"
+}
+
+// abiTypeAliases maps the ABI's shorthand types to the canonical types that
+// selectors are computed from.
+var abiTypeAliases = map[string]string{
+	"uint":   "uint256",
+	"int":    "int256",
+	"fixed":  "fixed128x18",
+	"ufixed": "ufixed128x18",
+}
+
+// canonicalType resolves a shorthand type, keeping any array suffix (e.g. "uint[2]"
+// becomes "uint256[2]").
+func canonicalType(typ string) string {
+	base, suffix := typ, ""
+	if i := strings.IndexByte(typ, '['); i >= 0 {
+		base, suffix = typ[:i], typ[i:]
+	}
+
+	if canonical, ok := abiTypeAliases[base]; ok {
+		return canonical + suffix
+	}
+
+	return typ
+}
+
+// functionSelector returns the first 4 bytes of the Keccak-256 hash of a signature.
+func functionSelector(signature string) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signature))
+
+	return h.Sum(nil)[:4]
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const erc20ABI = `[
+	{"type": "event", "name": "Transfer", "inputs": [{"name": "from", "type": "address"}, {"name": "to", "type": "address"}, {"name": "value", "type": "uint256"}]},
+	{"type": "function", "name": "approve", "inputs": [{"name": "spender", "type": "address"}, {"name": "value", "type": "uint256"}]},
+	{"type": "function", "name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "value", "type": "uint256"}]}
+]`
+
+func TestDecodeInput(t *testing.T) {
+	var entries []abiEntry
+	if err := json.Unmarshal([]byte(erc20ABI), &entries); err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+
+	to := strings.Repeat("00", 12) + strings.Repeat("ab", 20)
+	value := strings.Repeat("00", 31) + "64"
+
+	data, err := hex.DecodeString("a9059cbb" + to + value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		entries    []abiEntry
+		wantMethod string
+		wantArgs   int
+	}{
+		{name: "with ABI", data: data, entries: entries, wantMethod: "transfer", wantArgs: 2},
+		{name: "without ABI", data: data, wantArgs: 2},
+		{name: "selector not in ABI", data: append([]byte{0xde, 0xad, 0xbe, 0xef}, data[4:]...), entries: entries, wantArgs: 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := &DecodeTxInputResult{Args: []string{}}
+			decodeInput(result, tc.data, tc.entries)
+
+			if result.Selector != "0x"+hex.EncodeToString(tc.data[:4]) {
+				t.Errorf("Selector = %s", result.Selector)
+			}
+
+			if result.Method != tc.wantMethod {
+				t.Errorf("Method = %q, want %q", result.Method, tc.wantMethod)
+			}
+
+			if len(result.Args) != tc.wantArgs {
+				t.Fatalf("got %d args, want %d", len(result.Args), tc.wantArgs)
+			}
+
+			if result.Args[0] != "0x"+to || result.Args[1] != "0x"+value {
+				t.Errorf("Args = %v", result.Args)
+			}
+		})
+	}
+
+	// Plain transfers have no selector; trailing bytes are reported separately
+	short := &DecodeTxInputResult{Args: []string{}}
+	decodeInput(short, []byte{0x01, 0x02}, entries)
+
+	if short.Selector != "" || len(short.Args) != 0 {
+		t.Errorf("expected empty decode for short input, got %+v", short)
+	}
+
+	partial := &DecodeTxInputResult{Args: []string{}}
+	decodeInput(partial, []byte{0xa9, 0x05, 0x9c, 0xbb, 0x01, 0x02}, nil)
+
+	if partial.Trailing != "0x0102" || len(partial.Args) != 0 {
+		t.Errorf("expected trailing bytes, got %+v", partial)
+	}
+}
+
+// TestDecodeInputABIShorthand matches entries written with the ABI's defaults: an
+// entry without a type is a function, and uint/int stand for uint256/int256.
+func TestDecodeInputABIShorthand(t *testing.T) {
+	var entries []abiEntry
+	if err := json.Unmarshal([]byte(`[
+		{"name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "value", "type": "uint"}]},
+		{"type": "function", "name": "adjust", "inputs": [{"name": "deltas", "type": "int[]"}]}
+	]`), &entries); err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+
+	tests := []struct {
+		selector      string
+		wantMethod    string
+		wantSignature string
+	}{
+		{selector: "a9059cbb", wantMethod: "transfer", wantSignature: "transfer(address,uint256)"},
+		{selector: hex.EncodeToString(functionSelector("adjust(int256[])")), wantMethod: "adjust", wantSignature: "adjust(int256[])"},
+	}
+
+	for _, tc := range tests {
+		data, err := hex.DecodeString(tc.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := &DecodeTxInputResult{Args: []string{}}
+		decodeInput(result, data, entries)
+
+		if result.Method != tc.wantMethod || result.Signature != tc.wantSignature {
+			t.Errorf("selector %s decoded as %q %q, want %q %q",
+				tc.selector, result.Method, result.Signature, tc.wantMethod, tc.wantSignature)
+		}
+	}
+}
+
+func TestAbiSignature(t *testing.T) {
+	entry := abiEntry{
+		Type: "function",
+		Name: "submit",
+		Inputs: []abiArgument{
+			{Name: "orders", Type: "tuple[]", Components: []abiArgument{
+				{Name: "maker", Type: "address"},
+				{Name: "amounts", Type: "uint256[2]"},
+				{Name: "inner", Type: "tuple", Components: []abiArgument{{Name: "flag", Type: "bool"}}},
+			}},
+			{Name: "data", Type: "bytes"},
+		},
+	}
+
+	want := "submit((address,uint256[2],(bool))[],bytes)"
+	if got := abiSignature(entry); got != want {
+		t.Errorf("abiSignature() = %s, want %s", got, want)
+	}
+
+	if got := hex.EncodeToString(functionSelector("transfer(address,uint256)")); got != "a9059cbb" {
+		t.Errorf("transfer selector = %s, want a9059cbb", got)
+	}
+}
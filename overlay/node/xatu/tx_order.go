@@ -0,0 +1,121 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erigontech/erigon/db/kv/rawdbv3"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// orderTransactions validates that order is a permutation of a block's transaction
+// indices and returns it as the execution order. TxOrder reorders the whole block,
+// so it cannot be combined with a TxIndices selection.
+func orderTransactions(order, indices []uint64, txCount int) ([]int, error) {
+	if len(indices) > 0 {
+		return nil, fmt.Errorf("txOrder and txIndices cannot be combined")
+	}
+
+	if len(order) != txCount {
+		return nil, fmt.Errorf("txOrder has %d entries, block has %d transactions", len(order), txCount)
+	}
+
+	seen := make([]bool, txCount)
+	ordered := make([]int, 0, txCount)
+
+	for _, idx := range order {
+		if idx >= uint64(txCount) {
+			return nil, fmt.Errorf("transaction index %d out of range (block has %d transactions)", idx, txCount)
+		}
+
+		if seen[idx] {
+			return nil, fmt.Errorf("transaction index %d appears more than once in txOrder", idx)
+		}
+
+		seen[idx] = true
+		ordered = append(ordered, int(idx))
+	}
+
+	return ordered, nil
+}
+
+// executionState executes transactions on state carried from one to the next.
+type executionState interface {
+	execute(txIndex int, tracer *SimulationTracer, opts executionOptions) (*executionResult, error)
+	close()
+}
+
+// orderedExecutor runs a block's transactions in a custom order, threading state
+// from each transaction to the next. Unlike a regular block simulation, where each
+// transaction runs on the historical state at its position, a transaction sees the
+// writes of the transactions executed before it in the new order. The original and
+// simulated executions each carry their own state, since their writes may differ.
+type orderedExecutor struct {
+	newState func(opts executionOptions) (executionState, error)
+	states   [2]executionState // original, simulated; created on first use
+}
+
+// newOrderedExecutor creates an orderedExecutor whose states start before the
+// block's first transaction.
+func (s *Service) newOrderedExecutor(
+	ctx context.Context,
+	header *erigontypes.Header,
+	block *erigontypes.Block,
+	txNumReader rawdbv3.TxNumsReader,
+) *orderedExecutor {
+	return &orderedExecutor{
+		newState: func(opts executionOptions) (executionState, error) {
+			return s.newBlockState(ctx, header, block, txNumReader, opts)
+		},
+	}
+}
+
+// executeFunc returns the executeFunc for the dual execution of a transaction.
+// runDualExecution runs the original execution first, so the first call executes
+// on the original state and the second on the simulated state.
+func (o *orderedExecutor) executeFunc(txIndex int) executeFunc {
+	calls := 0
+
+	return func(tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+		i := min(calls, len(o.states)-1)
+		calls++
+
+		if o.states[i] == nil {
+			state, err := o.newState(opts)
+			if err != nil {
+				return nil, err
+			}
+
+			o.states[i] = state
+		}
+
+		return o.states[i].execute(txIndex, tracer, opts)
+	}
+}
+
+// close releases the states.
+func (o *orderedExecutor) close() {
+	for _, state := range o.states {
+		if state != nil {
+			state.close()
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded && erigon_main
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// chainState is an executionState on a testChain: transaction i calls the test
+// contract with calldata txs[i], and its writes are committed for the next one.
+type chainState struct {
+	c   *testChain
+	txs [][]byte
+}
+
+func (s *chainState) execute(txIndex int, tracer *SimulationTracer, opts executionOptions) (*executionResult, error) {
+	return s.c.callTraced(testContract, s.txs[txIndex], tracer, opts), nil
+}
+
+func (s *chainState) close() {}
+
+// TestOrderedExecutorRealState runs two transactions writing the same slot through
+// executeMessage on a real IntraBlockState, in both orders. The second transaction
+// reads the slot the first one wrote, so only the first pays to set it.
+func TestOrderedExecutorRealState(t *testing.T) {
+	txs := [][]byte{word(1), word(2)}
+
+	opts := executionOptions{GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{vm.GasKeySstoreSet: 40000}}}
+
+	// The cold SSTORE_RESET is charged as the cold SLOAD plus the rest of the reset cost
+	const setOverReset = params.SstoreSetGasEIP2200 - (params.SstoreResetGasEIP2200 - params.ColdSloadCostEIP2929)
+
+	for _, order := range [][]int{{0, 1}, {1, 0}} {
+		executor := &orderedExecutor{newState: func(executionOptions) (executionState, error) {
+			return &chainState{c: newTestChain(t, map[common.Address][]byte{testContract: storeCalldata}), txs: txs}, nil
+		}}
+
+		results := make(map[int]*dualExecutionResult, len(order))
+		for _, txIndex := range order {
+			result, err := runDualExecution(opts, SimulationTracerConfig{}, executor.executeFunc(txIndex))
+			if err != nil {
+				t.Fatalf("order %v: tx %d: %v", order, txIndex, err)
+			}
+
+			results[txIndex] = result
+		}
+
+		executor.close()
+
+		first, later := results[order[0]], results[order[1]]
+
+		if got := first.Original.GasUsed - later.Original.GasUsed; got != setOverReset {
+			t.Errorf("order %v: first writer paid %d more than the later one, want %d", order, got, setOverReset)
+		}
+
+		// Only the first writer sets the slot in the simulated state too
+		if got := first.Simulated.GasUsed - first.Original.GasUsed; got != 40000-params.SstoreSetGasEIP2200 {
+			t.Errorf("order %v: first writer simulated delta = %d, want %d", order, got, 40000-params.SstoreSetGasEIP2200)
+		}
+
+		if later.Simulated.GasUsed != later.Original.GasUsed {
+			t.Errorf("order %v: later writer simulated gas = %d, want %d", order, later.Simulated.GasUsed, later.Original.GasUsed)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/erigontech/erigon/execution/protocol/params"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// TestOrderTransactions verifies that only complete permutations are accepted.
+func TestOrderTransactions(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []uint64
+		indices []uint64
+		want    []int
+		wantErr bool
+	}{
+		{name: "identity", order: []uint64{0, 1, 2}, want: []int{0, 1, 2}},
+		{name: "reversed", order: []uint64{2, 1, 0}, want: []int{2, 1, 0}},
+		{name: "incomplete", order: []uint64{1, 0}, wantErr: true},
+		{name: "duplicate", order: []uint64{0, 0, 1}, wantErr: true},
+		{name: "out of range", order: []uint64{0, 1, 3}, wantErr: true},
+		{name: "with tx indices", order: []uint64{2, 1, 0}, indices: []uint64{1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := orderTransactions(tt.order, tt.indices, 3)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// slotState is an executionState in which every transaction writes the same
+// storage slot. The first writer pays SSTORE_SET to the cold slot; later writers
+// find it set and pay SSTORE_RESET, as the state is carried between them.
+type slotState struct {
+	written bool
+	closed  bool
+}
+
+func (s *slotState) execute(_ int, _ *SimulationTracer, opts executionOptions) (*executionResult, error) {
+	gs := opts.GasSchedule.ToVMGasSchedule()
+	gas := params.TxGas + gs.GetOr(vm.GasKeySloadCold, params.ColdSloadCostEIP2929)
+
+	if s.written {
+		gas += gs.GetOr(vm.GasKeySstoreReset, params.SstoreResetGasEIP2200) - params.ColdSloadCostEIP2929
+	} else {
+		gas += gs.GetOr(vm.GasKeySstoreSet, params.SstoreSetGasEIP2200)
+	}
+
+	s.written = true
+
+	return &executionResult{GasUsed: gas, Status: "success"}, nil
+}
+
+func (s *slotState) close() { s.closed = true }
+
+// TestOrderedExecutorThreadsState runs two transactions writing the same slot in
+// both orders and checks that the first one executed pays the cost of setting it,
+// with separate state for the original and simulated executions.
+func TestOrderedExecutorThreadsState(t *testing.T) {
+	const (
+		firstWriter = params.TxGas + params.ColdSloadCostEIP2929 + params.SstoreSetGasEIP2200
+		laterWriter = params.TxGas + params.SstoreResetGasEIP2200
+	)
+
+	opts := executionOptions{GasSchedule: &CustomGasSchedule{Overrides: map[string]uint64{vm.GasKeySstoreSet: 40000}}}
+
+	for _, order := range [][]int{{0, 1}, {1, 0}} {
+		var states []*slotState
+
+		executor := &orderedExecutor{newState: func(executionOptions) (executionState, error) {
+			state := &slotState{}
+			states = append(states, state)
+
+			return state, nil
+		}}
+
+		results := make(map[int]*dualExecutionResult, len(order))
+		for _, txIndex := range order {
+			result, err := runDualExecution(opts, SimulationTracerConfig{}, executor.executeFunc(txIndex))
+			if err != nil {
+				t.Fatalf("order %v: tx %d: %v", order, txIndex, err)
+			}
+
+			results[txIndex] = result
+		}
+
+		executor.close()
+
+		first, later := results[order[0]], results[order[1]]
+
+		if first.Original.GasUsed != firstWriter || later.Original.GasUsed != laterWriter {
+			t.Errorf("order %v: original gas = %d, %d, want %d, %d", order,
+				first.Original.GasUsed, later.Original.GasUsed, firstWriter, laterWriter)
+		}
+
+		if want := firstWriter - params.SstoreSetGasEIP2200 + 40000; first.Simulated.GasUsed != want || later.Simulated.GasUsed != laterWriter {
+			t.Errorf("order %v: simulated gas = %d, %d, want %d, %d", order,
+				first.Simulated.GasUsed, later.Simulated.GasUsed, want, laterWriter)
+		}
+
+		if len(states) != 2 || !states[0].closed || !states[1].closed {
+			t.Errorf("order %v: got %d states, want 2 closed states", order, len(states))
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+// GasKeyTxSignature prices sender recovery (ecrecover of the transaction
+// signature), which no fork meters. It is synthetic: the EVM never reads it, and
+// the cost only appears as a breakdown entry for proposals that would charge it.
+const GasKeyTxSignature = "TX_SIGNATURE"
+
+// syntheticGasKeys lists the override keys that add non-consensus breakdown
+// entries without changing execution.
+var syntheticGasKeys = []string{GasKeyTxSignature}
+
+// txSignatureCost returns the TX_SIGNATURE override, if set.
+func (c *CustomGasSchedule) txSignatureCost() (uint64, bool) {
+	if c == nil {
+		return 0, false
+	}
+
+	cost, ok := c.Overrides[GasKeyTxSignature]
+
+	return cost, ok
+}
+
+// addTxSignatureCost adds the synthetic TX_SIGNATURE entry to a transaction's
+// breakdown when the schedule prices it. The original execution has no such
+// charge, so only the simulated side counts it. The entry is left out of the gas
+// accounting (see opcodeGasTotals) and of gas used, as nothing is charged.
+func addTxSignatureCost(breakdown map[string]OpcodeSummary, schedule *CustomGasSchedule) {
+	cost, ok := schedule.txSignatureCost()
+	if !ok {
+		return
+	}
+
+	entry := breakdown[GasKeyTxSignature]
+	entry.merge(OpcodeSummary{
+		SimulatedCount:  1,
+		SimulatedGas:    cost,
+		SimulatedMinGas: cost,
+		SimulatedMaxGas: cost,
+	})
+	breakdown[GasKeyTxSignature] = entry
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import "testing"
+
+// TestTxSignatureBreakdown verifies that the synthetic TX_SIGNATURE entry appears
+// in the breakdown only when the override is set, counts only on the simulated
+// side, and is kept out of the gas accounting.
+func TestTxSignatureBreakdown(t *testing.T) {
+	execute := func(*SimulationTracer, executionOptions) (*executionResult, error) {
+		return &executionResult{GasUsed: 21000, IntrinsicGas: 21000, Status: "success"}, nil
+	}
+
+	tests := []struct {
+		name     string
+		schedule *CustomGasSchedule
+		want     bool
+	}{
+		{name: "no schedule"},
+		{name: "other overrides", schedule: &CustomGasSchedule{Overrides: map[string]uint64{"SLOAD_COLD": 100}}},
+		{name: "signature override", schedule: &CustomGasSchedule{Overrides: map[string]uint64{GasKeyTxSignature: 3000}}, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := runDualExecution(executionOptions{GasSchedule: tc.schedule}, SimulationTracerConfig{}, execute)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			entry, ok := result.OpcodeBreakdown[GasKeyTxSignature]
+			if ok != tc.want {
+				t.Fatalf("TX_SIGNATURE in breakdown = %v, want %v", ok, tc.want)
+			}
+
+			if !ok {
+				return
+			}
+
+			want := OpcodeSummary{SimulatedCount: 1, SimulatedGas: 3000, SimulatedMinGas: 3000, SimulatedMaxGas: 3000}
+			if entry != want {
+				t.Errorf("TX_SIGNATURE = %+v, want %+v", entry, want)
+			}
+
+			// Nothing is charged, so gas used and the accounting are unchanged
+			if result.Simulated.GasUsed != 21000 {
+				t.Errorf("simulated gas used = %d, want 21000", result.Simulated.GasUsed)
+			}
+
+			if _, simulated := opcodeGasTotals(result.OpcodeBreakdown); simulated != 0 {
+				t.Errorf("simulated opcode gas = %d, want 0", simulated)
+			}
+		})
+	}
+}
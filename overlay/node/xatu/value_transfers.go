@@ -0,0 +1,105 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/holiman/uint256"
+)
+
+// ValueTransfer is an ETH transfer made by a call frame.
+// The top-level transaction value is included at depth 0.
+type ValueTransfer struct {
+	Depth    int    `json:"depth"`
+	Type     string `json:"type"` // "CALL", "CREATE", "CREATE2", "SELFDESTRUCT", etc.
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Value    string `json:"value"`    // Hex-encoded wei
+	Reverted bool   `json:"reverted"` // True if the frame (or an ancestor) failed, undoing the transfer
+}
+
+// TraceValueTransfersResult is the result of xatu_traceValueTransfers.
+type TraceValueTransfersResult struct {
+	TransactionHash string          `json:"transactionHash"`
+	BlockNumber     uint64          `json:"blockNumber"`
+	Status          string          `json:"status"`
+	Transfers       []ValueTransfer `json:"transfers"`
+}
+
+// recordValueTransfer appends a transfer for a frame entered with nonzero value.
+// DELEGATECALL and CALLCODE carry a value but do not move ETH between accounts.
+func (t *SimulationTracer) recordValueTransfer(depth int, typ byte, from, to string, value *uint256.Int) {
+	if typ == 0xF4 || typ == 0xF2 { // DELEGATECALL, CALLCODE
+		return
+	}
+
+	typName := opcodeStrings[typ]
+	if typName == "" {
+		typName = "UNKNOWN"
+	}
+
+	t.transfers = append(t.transfers, ValueTransfer{
+		Depth: depth,
+		Type:  typName,
+		From:  normalizeAddress(from),
+		To:    normalizeAddress(to),
+		Value: value.Hex(),
+	})
+}
+
+// markTransfersReverted flags all transfers from index start onwards as reverted.
+// Called when a frame fails, since its transfers and those of its children are undone.
+func (t *SimulationTracer) markTransfersReverted(start int) {
+	for i := start; i < len(t.transfers); i++ {
+		t.transfers[i].Reverted = true
+	}
+}
+
+// TraceValueTransfers re-executes a transaction with standard gas costs and returns
+// the internal ETH transfers it made (value-bearing calls, creates and selfdestructs).
+func (s *Service) TraceValueTransfers(ctx context.Context, txHash string) (*TraceValueTransfersResult, error) {
+	tx, err := s.db.BeginTemporalRo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	txNumReader := s.txNumsReader(ctx)
+
+	block, txIndex, err := s.lookupTransaction(ctx, tx, txNumReader, txHash, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tracer := NewSimulationTracer(nil, SimulationTracerConfig{TrackValueTransfers: true})
+
+	execResult, err := s.executeSingleTransaction(ctx, tx, block.Header(), block, txIndex, txNumReader, tracer, executionOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute transaction: %w", err)
+	}
+
+	return &TraceValueTransfersResult{
+		TransactionHash: txHash,
+		BlockNumber:     block.NumberU64(),
+		Status:          execResult.Status,
+		Transfers:       tracer.GetValueTransfers(),
+	}, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	erigontypes "github.com/erigontech/erigon/execution/types"
+	"github.com/erigontech/erigon/execution/vm"
+)
+
+// WarmAccessOrigin counts warm state accesses by why the address or storage slot
+// was already warm. Pre-declared accesses hit an entry of the transaction's
+// EIP-2930 access list; execution-warmed ones hit an entry that an earlier access
+// in the same transaction added. The split shows how much of a transaction's warm
+// access a cold/warm repricing depends on its declared list.
+//
+// Addresses warm from the start of the transaction (sender, recipient, precompiles
+// and, from Shanghai, the coinbase) are counted separately, even when the access
+// list declares them.
+type WarmAccessOrigin struct {
+	PreDeclaredAddresses     uint64 `json:"preDeclaredAddresses"`
+	PreDeclaredSlots         uint64 `json:"preDeclaredSlots"`
+	ExecutionWarmedAddresses uint64 `json:"executionWarmedAddresses"`
+	ExecutionWarmedSlots     uint64 `json:"executionWarmedSlots"`
+	ImplicitAddresses        uint64 `json:"implicitAddresses"` // Sender, recipient, precompiles and coinbase
+}
+
+// WarmAccessOrigins holds the warm access origins of both executions.
+type WarmAccessOrigins struct {
+	Original  WarmAccessOrigin `json:"original"`
+	Simulated WarmAccessOrigin `json:"simulated"`
+}
+
+// warmAccessTracker classifies warm accesses against the access list state at the
+// start of the transaction. The declared entries are captured before execution,
+// and entries added at runtime are kept apart from them, so a repeated access can
+// be attributed to whichever one made it warm. As in the EVM, entries added by a
+// frame that reverts are cold again afterwards.
+type warmAccessTracker struct {
+	implicit *accessTracker // Addresses warm regardless of the access list
+	declared *accessTracker // Access list entries declared by the transaction
+	warmed   *accessTracker // Entries added during execution
+	journal  []warmEntry    // Entries added to warmed, in order
+	frames   []int          // Journal length when each open frame was entered
+	counts   WarmAccessOrigin
+}
+
+// warmEntry is an address, or a storage slot of it, added to the warm set.
+type warmEntry struct {
+	addr string
+	slot string // Empty for the address itself
+}
+
+// newWarmAccessTracker creates an empty warm access tracker.
+func newWarmAccessTracker() *warmAccessTracker {
+	return &warmAccessTracker{
+		implicit: newAccessTracker(),
+		declared: newAccessTracker(),
+		warmed:   newAccessTracker(),
+	}
+}
+
+// start captures the transaction's declared access list, and the precompiles and
+// coinbase (see warmCoinbase), which are warm from the start of the transaction.
+func (w *warmAccessTracker) start(accessList erigontypes.AccessList, precompiles vm.PrecompiledContracts, coinbase string) {
+	w.implicit.warmImplicit(precompiles, coinbase)
+	w.declared.warmAccessList(accessList)
+}
+
+// enter records a call frame. The top-level frame's sender and recipient are warm
+// from the start; CREATE frames warm the new contract without accessing it, before
+// the frame starts, so a failed creation leaves it warm. Other call targets are
+// classified by the CALL-family opcode that made the call.
+func (w *warmAccessTracker) enter(depth int, typ byte, from, to string) {
+	switch {
+	case depth == 0:
+		w.implicit.warmAddresses[normalizeAddress(from)] = struct{}{}
+		w.implicit.warmAddresses[normalizeAddress(to)] = struct{}{}
+	case typ == 0xF0 || typ == 0xF5: // CREATE, CREATE2
+		w.warmAddress(normalizeAddress(to))
+	}
+
+	w.frames = append(w.frames, len(w.journal))
+}
+
+// exit closes the innermost call frame. If it reverted, the entries it and its
+// children warmed are removed again.
+func (w *warmAccessTracker) exit(reverted bool) {
+	if len(w.frames) == 0 {
+		return
+	}
+
+	start := w.frames[len(w.frames)-1]
+	w.frames = w.frames[:len(w.frames)-1]
+
+	if !reverted {
+		return
+	}
+
+	for _, entry := range w.journal[start:] {
+		if entry.slot == "" {
+			delete(w.warmed.warmAddresses, entry.addr)
+		} else {
+			delete(w.warmed.warmSlots[entry.addr], entry.slot)
+		}
+	}
+
+	w.journal = w.journal[:start]
+}
+
+// touchAddress classifies an address access, warming the address if it was cold.
+func (w *warmAccessTracker) touchAddress(addr string) {
+	if _, ok := w.implicit.warmAddresses[addr]; ok {
+		w.counts.ImplicitAddresses++
+		return
+	}
+
+	if _, ok := w.declared.warmAddresses[addr]; ok {
+		w.counts.PreDeclaredAddresses++
+		return
+	}
+
+	if _, ok := w.warmed.warmAddresses[addr]; ok {
+		w.counts.ExecutionWarmedAddresses++
+		return
+	}
+
+	w.warmAddress(addr)
+}
+
+// touchSlot classifies a storage slot access, warming the slot if it was cold.
+// Storage access does not access the account itself.
+func (w *warmAccessTracker) touchSlot(addr, slot string) {
+	if _, ok := w.declared.warmSlots[addr][slot]; ok {
+		w.counts.PreDeclaredSlots++
+		return
+	}
+
+	if _, ok := w.warmed.warmSlots[addr][slot]; ok {
+		w.counts.ExecutionWarmedSlots++
+		return
+	}
+
+	addSlot(w.warmed.warmSlots, addr, slot)
+	w.journal = append(w.journal, warmEntry{addr: addr, slot: slot})
+}
+
+// warmAddress adds an address to the execution-warmed set, if it is not in it yet.
+func (w *warmAccessTracker) warmAddress(addr string) {
+	if _, ok := w.warmed.warmAddresses[addr]; ok {
+		return
+	}
+
+	w.warmed.warmAddresses[addr] = struct{}{}
+	w.journal = append(w.journal, warmEntry{addr: addr})
+}
+
+// reset clears the tracker for the next transaction.
+func (w *warmAccessTracker) reset() {
+	w.implicit.reset()
+	w.declared.reset()
+	w.warmed.reset()
+	w.journal = w.journal[:0]
+	w.frames = w.frames[:0]
+	w.counts = WarmAccessOrigin{}
+}
+
+// combineWarmAccessOrigins pairs the warm access origins of both tracers, or
+// returns nil if classification is disabled.
+func combineWarmAccessOrigins(original, simulated *SimulationTracer) *WarmAccessOrigins {
+	o, s := original.GetWarmAccessOrigin(), simulated.GetWarmAccessOrigin()
+	if o == nil || s == nil {
+		return nil
+	}
+
+	return &WarmAccessOrigins{Original: *o, Simulated: *s}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build embedded
+
+package xatu
+
+import (
+	"testing"
+
+	"github.com/erigontech/erigon/common"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// TestWarmAccessOrigins traces a transaction whose access list declares a contract
+// and one of its slots. The contract reads the declared slot and an undeclared one
+// twice each, then checks the balance of an undeclared address twice.
+func TestWarmAccessOrigins(t *testing.T) {
+	var (
+		sender      = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		recipient   = common.HexToAddress("0xcccccccccccccccccccccccccccccccccccccccc")
+		contract    = common.HexToAddress("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+		other       = common.HexToAddress("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+		declaredKey = common.HexToHash("0x01")
+		runtimeKey  = common.HexToHash("0x02")
+	)
+
+	accessList := erigontypes.AccessList{
+		{Address: contract, StorageKeys: []common.Hash{declaredKey}},
+	}
+
+	tracker := newWarmAccessTracker()
+	tracker.start(accessList, nil, "")
+
+	contractAddr := normalizeAddress(contract.String())
+	otherAddr := normalizeAddress(other.String())
+
+	// The recipient calls the declared contract
+	tracker.enter(0, 0xF1, sender.String(), recipient.String())
+	tracker.touchAddress(contractAddr)
+	tracker.enter(1, 0xF1, recipient.String(), contract.String())
+
+	for range 2 {
+		tracker.touchSlot(contractAddr, declaredKey.Hex())
+		tracker.touchSlot(contractAddr, runtimeKey.Hex())
+	}
+
+	tracker.touchAddress(otherAddr)
+	tracker.touchAddress(otherAddr)
+	tracker.touchAddress(normalizeAddress(recipient.String()))
+
+	// The declared slot is pre-declared warm on both reads; the undeclared one is
+	// cold once and then execution-warmed
+	want := WarmAccessOrigin{
+		PreDeclaredAddresses:     1,
+		PreDeclaredSlots:         2,
+		ExecutionWarmedAddresses: 1,
+		ExecutionWarmedSlots:     1,
+		ImplicitAddresses:        1,
+	}
+
+	if tracker.counts != want {
+		t.Errorf("got %+v, want %+v", tracker.counts, want)
+	}
+
+	tracker.reset()
+	tracker.touchSlot(contractAddr, declaredKey.Hex())
+
+	if tracker.counts != (WarmAccessOrigin{}) {
+		t.Errorf("after reset got %+v, want a cold access", tracker.counts)
+	}
+}
+
+// TestWarmAccessOriginsRevert checks that entries warmed by a reverted frame are
+// cold again after it exits, while those warmed by a successful frame, and the
+// target of a failed creation, stay warm.
+func TestWarmAccessOriginsRevert(t *testing.T) {
+	var (
+		sender    = "0x1111111111111111111111111111111111111111"
+		recipient = "0xcccccccccccccccccccccccccccccccccccccccc"
+		reverted  = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		kept      = "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		created   = "0xdddddddddddddddddddddddddddddddddddddddd"
+		slot      = common.HexToHash("0x01").Hex()
+	)
+
+	tracker := newWarmAccessTracker()
+	tracker.start(nil, nil, "")
+	tracker.enter(0, 0xF1, sender, recipient)
+
+	// A successful child warms an address, and the target of a creation that fails
+	tracker.enter(1, 0xF1, recipient, recipient)
+	tracker.touchAddress(kept)
+	tracker.enter(2, 0xF0, recipient, created)
+	tracker.exit(true)
+	tracker.exit(false)
+
+	// A reverted child warms an address and a slot
+	tracker.enter(1, 0xF1, recipient, recipient)
+	tracker.touchAddress(reverted)
+	tracker.touchSlot(recipient, slot)
+	tracker.exit(true)
+
+	// The reverted entries are cold again; the rest are warm
+	tracker.touchAddress(reverted)
+	tracker.touchSlot(recipient, slot)
+	tracker.touchAddress(kept)
+	tracker.touchAddress(created)
+
+	want := WarmAccessOrigin{ExecutionWarmedAddresses: 2}
+	if tracker.counts != want {
+		t.Errorf("after revert got %+v, want %+v", tracker.counts, want)
+	}
+
+	// The cold accesses warmed them again
+	tracker.touchAddress(reverted)
+	tracker.touchSlot(recipient, slot)
+
+	want = WarmAccessOrigin{ExecutionWarmedAddresses: 3, ExecutionWarmedSlots: 1}
+	if tracker.counts != want {
+		t.Errorf("after rewarming got %+v, want %+v", tracker.counts, want)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build embedded && !erigon_main
+
+package xatu
+
+import (
+	"fmt"
+
+	"github.com/holiman/uint256"
+
+	erigonstate "github.com/erigontech/erigon/execution/state"
+	"github.com/erigontech/erigon/execution/tracing"
+	erigontypes "github.com/erigontech/erigon/execution/types"
+)
+
+// applyWithdrawals credits a block's withdrawals to statedb, as the consensus engine
+// does after the block's transactions. Amounts are in gwei.
+func applyWithdrawals(statedb *erigonstate.IntraBlockState, withdrawals []*erigontypes.Withdrawal) error {
+	for _, w := range withdrawals {
+		amount := new(uint256.Int).Mul(uint256.NewInt(w.Amount), uint256.NewInt(1e9))
+		if err := statedb.AddBalance(w.Address, *amount, tracing.BalanceIncreaseWithdrawal); err != nil {
+			return fmt.Errorf("failed to apply withdrawal %d: %w", w.Index, err)
+		}
+	}
+
+	return nil
+}